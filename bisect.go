@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/nbedos/citop/tui"
+	"github.com/nbedos/citop/utils"
+)
+
+type bisectArguments struct {
+	repository string
+	job        string
+	first      string
+	last       string
+}
+
+func parseBisectArguments(args []string, defaultRepository string) (bisectArguments, error) {
+	a := bisectArguments{}
+	f := flag.NewFlagSet("citop bisect", flag.ContinueOnError)
+	f.SetOutput(bytes.NewBuffer(nil))
+	f.StringVar(&a.repository, "repository", defaultRepository, "")
+	f.StringVar(&a.repository, "r", defaultRepository, "")
+	f.StringVar(&a.job, "job", "", "")
+	if err := f.Parse(args); err != nil {
+		return a, err
+	}
+
+	if f.NArg() != 1 {
+		return a, errors.New("exactly one range of commits must be specified")
+	}
+	commits := strings.Split(f.Arg(0), "..")
+	if len(commits) != 2 || commits[0] == "" || commits[1] == "" {
+		return a, fmt.Errorf("invalid range %q (expected GOOD..BAD)", f.Arg(0))
+	}
+	a.first, a.last = commits[0], commits[1]
+
+	return a, nil
+}
+
+// Look for the first failing commit of a range and return the exit status of citop
+func runBisect(args []string) int {
+	defaultRepository, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	a, err := parseBisectArguments(args, defaultRepository)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		fmt.Fprintln(os.Stderr, usage())
+		return exitError
+	}
+
+	paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
+	config, err := loadConfiguration(paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	ctx := context.Background()
+	sourceProviders, ciProviders, err := config.Providers.Providers(ctx, http.DefaultTransport)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+
+	err = tui.RunBisect(ctx, os.Stdout, a.repository, a.first, a.last, a.job, ciProviders, sourceProviders)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseBisectArguments(t *testing.T) {
+	testCases := []struct {
+		args      []string
+		arguments bisectArguments
+	}{
+		{
+			args:      []string{"v0.1.0..master"},
+			arguments: bisectArguments{repository: "repo", first: "v0.1.0", last: "master"},
+		},
+		{
+			args:      []string{"-r", "../citop", "--job", "test/unit", "HEAD~10..HEAD"},
+			arguments: bisectArguments{repository: "../citop", job: "test/unit", first: "HEAD~10", last: "HEAD"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(strings.Join(testCase.args, " "), func(t *testing.T) {
+			a, err := parseBisectArguments(testCase.args, "repo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.arguments, a, cmp.AllowUnexported(bisectArguments{})); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"HEAD"},
+		{"..HEAD"},
+		{"HEAD~1..HEAD", "HEAD~2..HEAD"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			if _, err := parseBisectArguments(args, "repo"); err == nil {
+				t.Fatal("expected error but got nil")
+			}
+		})
+	}
+}
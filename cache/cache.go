@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -29,6 +30,173 @@ type SourceProvider interface {
 	Commit(ctx context.Context, repo string, sha string) (utils.Commit, error)
 }
 
+// AuthenticationChecker is implemented by providers able to verify that the credentials
+// supplied by the user are accepted by the online service
+type AuthenticationChecker interface {
+	CheckAuthentication(ctx context.Context) error
+}
+
+// OwnerMatcher is implemented by accounts restricted to the repositories of some owners, such as
+// the organizations of an employer when another account of the same provider is used for
+// personal repositories. Other accounts are used for every repository.
+type OwnerMatcher interface {
+	MatchesOwner(owner string) bool
+}
+
+// RepositoryMatcher is implemented by providers restricted to some repositories, such as a CI
+// service only building some of the repositories of an organization. Other providers are used
+// for every repository.
+type RepositoryMatcher interface {
+	MatchesRepository(owner string, repo string) bool
+}
+
+// UsedFor returns true if provider 'p' is used for the repository 'repo' of 'owner'
+func UsedFor(p interface{}, owner string, repo string) bool {
+	if m, ok := p.(OwnerMatcher); ok && !m.MatchesOwner(owner) {
+		return false
+	}
+	m, ok := p.(RepositoryMatcher)
+	return !ok || m.MatchesRepository(owner, repo)
+}
+
+// ErrNoPullRequest is returned by PullRequestFinder when no pull request contains the commit
+var ErrNoPullRequest = errors.New("no pull request found")
+
+// PullRequestFinder is implemented by source providers able to find the pull request, or merge
+// request, containing a commit. Open pull requests are preferred over closed ones.
+type PullRequestFinder interface {
+	PullRequest(ctx context.Context, repo string, sha string) (utils.PullRequest, error)
+}
+
+// HistoryProvider is implemented by providers able to list the pipelines that passed on the
+// same ref before a given pipeline. They are used to compare the duration of jobs with their
+// recent average.
+type HistoryProvider interface {
+	PreviousBuilds(ctx context.Context, build Build, limit int) ([]Build, error)
+}
+
+// DeploymentManager is implemented by providers able to act on the deployments of pipelines.
+// Actions are only attempted if the deployment permits them (see Deployment.CanStop and
+// Deployment.CanRetry).
+type DeploymentManager interface {
+	// StopEnvironment stops the environment the deployment was made to
+	StopEnvironment(ctx context.Context, repository Repository, deployment Deployment) error
+	// RetryDeployment runs the job of the deployment again
+	RetryDeployment(ctx context.Context, repository Repository, deployment Deployment) error
+}
+
+// PipelineCanceler is implemented by providers able to cancel the pipelines and the jobs that
+// are still active. Providers only able to cancel whole pipelines return ErrUnsupportedAction
+// from CancelJob.
+type PipelineCanceler interface {
+	CancelBuild(ctx context.Context, build Build) error
+	CancelJob(ctx context.Context, build Build, job Job) error
+}
+
+// PipelineRestarter is implemented by providers able to run again a finished pipeline
+type PipelineRestarter interface {
+	// RestartBuild runs 'build' again and returns the web page of the pipeline started. Some
+	// providers retry the jobs of the same pipeline, in which case the web page of 'build' is
+	// returned.
+	RestartBuild(ctx context.Context, build Build) (string, error)
+}
+
+// JobRetrier is implemented by providers able to run again a single finished job
+type JobRetrier interface {
+	// RetryJob runs 'job' of 'build' again and returns the identifier of the job started, which
+	// replaces 'job' in the pipeline. Providers restarting the job in place return its
+	// identifier.
+	RetryJob(ctx context.Context, build Build, job Job) (string, error)
+}
+
+// ManualJobStarter is implemented by providers able to start the jobs waiting for the action of
+// a user, such as the manual jobs of GitLab and the approvals of Azure Pipelines
+type ManualJobStarter interface {
+	StartManualJob(ctx context.Context, build Build, job Job) error
+}
+
+// PipelineTrigger is implemented by providers able to start a new pipeline on a branch or tag of
+// a repository. ErrUnknownURL is returned if the repository is not hosted by the provider and
+// ErrRepositoryNotFound if the provider does not know it.
+type PipelineTrigger interface {
+	// TriggerPipeline starts a pipeline on 'ref' of the repository at 'repositoryURL' with
+	// additional environment variables and returns the web page of the pipeline. Providers
+	// creating pipelines asynchronously return the web page of the repository instead.
+	TriggerPipeline(ctx context.Context, repositoryURL string, ref string, variables []Variable) (string, error)
+}
+
+// Artifact is a file kept by a CI provider at the end of a job
+type Artifact struct {
+	// Path of the file relative to the root of the artifacts of the job
+	Path string
+	// Size in bytes, -1 if unknown
+	Size int64
+	// Web page of the file, empty if there is none
+	WebURL string
+}
+
+// ArtifactProvider is implemented by providers able to list and download the artifacts of jobs
+type ArtifactProvider interface {
+	// Artifacts lists the artifacts of 'job', an empty list if the job has none
+	Artifacts(ctx context.Context, build Build, job Job) ([]Artifact, error)
+	// WriteArtifact writes the content of the artifact of 'job' at 'path' to 'w'
+	WriteArtifact(ctx context.Context, build Build, job Job, path string, w io.Writer) error
+}
+
+// TestReport summarizes the results of the tests run by a job
+type TestReport struct {
+	Total int
+	// Number of tests that failed or could not run because of an error
+	Failed  int
+	Skipped int
+	// Tests that failed, in the order of the report
+	Failures []TestCase
+}
+
+// TestCase is a test that failed
+type TestCase struct {
+	// Suite or class of the test
+	Suite   string
+	Name    string
+	Message string
+}
+
+// Add the results of 'other' to the report
+func (r *TestReport) merge(other TestReport) {
+	r.Total += other.Total
+	r.Failed += other.Failed
+	r.Skipped += other.Skipped
+	r.Failures = append(r.Failures, other.Failures...)
+}
+
+// ErrNoTestReport is returned for jobs whose provider knows no test results
+var ErrNoTestReport = errors.New("no test report found")
+
+// TestReportProvider is implemented by providers collecting the results of the tests run by
+// jobs, such as the test reports of GitLab and the test runs of Azure Pipelines. The test results
+// of other providers are read from the JUnit reports found among the artifacts of jobs.
+type TestReportProvider interface {
+	// TestReport returns the results of the tests run by 'job', or ErrNoTestReport if the
+	// job published none
+	TestReport(ctx context.Context, build Build, job Job) (TestReport, error)
+}
+
+// Notification tells that a pipeline of a commit was created or updated
+type Notification struct {
+	Sha string
+	// Web page of the pipeline
+	URL string
+}
+
+// Notifier is implemented by source providers notified by online services every time a pipeline
+// changes, such as receivers of webhooks. Once a notifier is configured, pipelines are fetched
+// again on notification instead of being polled, which spares the rate limits of providers.
+type Notifier interface {
+	// Notifications returns a channel receiving every notification. The channel is closed once
+	// 'ctx' is canceled.
+	Notifications(ctx context.Context) (<-chan Notification, error)
+}
+
 type State string
 
 func (s State) IsActive() bool {
@@ -103,8 +271,11 @@ type Commit struct {
 }
 
 type Build struct {
-	Repository      *Repository
-	ID              string
+	Repository *Repository
+	ID         string
+	// Name of the pipeline that stays the same from one run to the next, such as the name of a
+	// GitHub workflow or check. Empty if the provider does not name its pipelines.
+	Name            string
 	Commit          Commit
 	Ref             string
 	IsTag           bool
@@ -118,6 +289,20 @@ type Build struct {
 	WebURL          string
 	Stages          map[int]*Stage
 	Jobs            []*Job
+	// Deployments made by the jobs of the pipeline
+	Deployments []*Deployment
+	// Summaries of the results of the pipeline published by the CI service
+	Annotations []Annotation
+}
+
+// Annotation is a summary of the results of a pipeline written by the CI service or by the
+// pipeline itself, e.g. the output of a GitHub check run
+type Annotation struct {
+	Title string
+	// "error", "warning", "info" or "success"
+	Style string
+	// Markdown, possibly mixed with HTML
+	Body string
 }
 
 func (b Build) Status() State        { return b.State }
@@ -163,11 +348,67 @@ type Job struct {
 	Log          utils.NullString
 	WebURL       string
 	AllowFailure bool
+	// Commands executed by the job, empty if the provider does not expose them
+	Steps []Step
+	// Environment variables and parameters of the job, secrets excluded
+	Variables []Variable
+	// Dimensions of the build matrix, empty if the provider does not expose them
+	OS       string
+	Arch     string
+	Language string
+	// Errors and warnings reported by the job on the code of the repository
+	Annotations []CodeAnnotation
+}
+
+// CodeAnnotation is an error, a warning or a notice reported by a job on a range of lines of a
+// file of the repository, e.g. an annotation of a GitHub check run
+type CodeAnnotation struct {
+	// "failure", "warning" or "notice"
+	Level     string
+	Path      string
+	StartLine int
+	EndLine   int
+	Title     string
+	Message   string
+	// URL of the lines in the web interface of the repository, empty if unknown
+	WebURL string
+}
+
+// Variable is an environment variable or a parameter set for a job
+type Variable struct {
+	Name  string
+	Value string
+}
+
+// Step is a command executed by a job
+type Step struct {
+	// Name of the step or of the phase of the job the command belongs to
+	Name    string
+	Command string
 }
 
 func (j Job) Status() State        { return j.State }
 func (j Job) AllowedFailure() bool { return j.AllowFailure }
 
+// Deployment is the deployment of the code of a pipeline to an environment
+type Deployment struct {
+	ID            string
+	Environment   string
+	EnvironmentID string
+	// State of the job running the deployment
+	State      State
+	CreatedAt  utils.NullTime
+	FinishedAt utils.NullTime
+	// URL of the environment
+	WebURL string
+	// Identifier of the job of the pipeline running the deployment
+	JobID string
+	// Whether the environment is still running this deployment and can be stopped
+	CanStop bool
+	// Whether the job of the deployment finished and can be run again
+	CanRetry bool
+}
+
 type buildKey struct {
 	AccountID string
 	BuildID   string
@@ -175,11 +416,31 @@ type buildKey struct {
 
 type Cache struct {
 	builds          map[buildKey]*Build
+	averages        map[buildKey]map[string]time.Duration
 	mutex           *sync.Mutex
 	ciProvidersById map[string]CIProvider
 	sourceProviders []SourceProvider
+	// Jobs kept when saving a pipeline, nil to keep all jobs
+	jobFilter JobFilter
+	// Pipelines kept when fetching the pipelines of a commit, nil to keep all pipelines
+	pipelineFilter PipelineFilter
+	// Outcome of the last request made to each provider while monitoring pipelines, by
+	// provider identifier
+	health map[string]ProviderHealth
+	// Pipelines acted upon by the user, such as pipelines restarted or started, monitored along
+	// with the pipelines of the commit
+	tracked chan trackedPipeline
 }
 
+// trackedPipeline designates a pipeline by the URL of its web page and the provider hosting it
+type trackedPipeline struct {
+	provider CIProvider
+	url      string
+}
+
+// Number of pipelines acted upon by the user waiting to be monitored
+const trackedBufferSize = 16
+
 func NewCache(CIProviders []CIProvider, sourceProviders []SourceProvider) Cache {
 	providersByAccountID := make(map[string]CIProvider, len(CIProviders))
 	for _, provider := range CIProviders {
@@ -188,14 +449,108 @@ func NewCache(CIProviders []CIProvider, sourceProviders []SourceProvider) Cache
 
 	return Cache{
 		builds:          make(map[buildKey]*Build),
+		averages:        make(map[buildKey]map[string]time.Duration),
 		mutex:           &sync.Mutex{},
 		ciProvidersById: providersByAccountID,
 		sourceProviders: sourceProviders,
+		health:          make(map[string]ProviderHealth),
+		tracked:         make(chan trackedPipeline, trackedBufferSize),
 	}
 }
 
 var ErrOlderBuild = errors.New("build to save is older than current build in cache")
 
+// JobFilter tells whether the job named 'job' of the stage named 'stage' must be kept. 'stage' is
+// empty for jobs outside of a stage.
+type JobFilter func(stage string, job string) bool
+
+// PipelineFilter tells whether a pipeline must be kept
+type PipelineFilter func(build Build) bool
+
+// SetJobFilter restricts the jobs of the pipelines fetched by the cache to those accepted by
+// 'filter'. Pipelines whose jobs are all rejected are ignored. A nil filter keeps all jobs.
+func (c *Cache) SetJobFilter(filter JobFilter) {
+	c.jobFilter = filter
+}
+
+// SetPipelineFilter restricts the pipelines fetched by the cache to those accepted by 'filter'. A
+// nil filter keeps all pipelines.
+func (c *Cache) SetPipelineFilter(filter PipelineFilter) {
+	c.pipelineFilter = filter
+}
+
+// Return 'build' without the jobs rejected by the job filter of the cache, and false if the
+// pipeline is rejected by the pipeline filter or if the build had jobs and all of them were
+// rejected. Stages left without jobs are removed. The state of the pipeline and of its stages is
+// computed again from the remaining jobs so that rejected jobs do not influence it.
+func (c *Cache) filter(build Build) (Build, bool) {
+	if c.pipelineFilter != nil && !c.pipelineFilter(build) {
+		return build, false
+	}
+	if c.jobFilter == nil {
+		return build, true
+	}
+
+	total := len(build.Jobs)
+	jobs := make([]*Job, 0, len(build.Jobs))
+	for _, job := range build.Jobs {
+		if c.jobFilter("", job.Name) {
+			jobs = append(jobs, job)
+		}
+	}
+	kept := len(jobs)
+	build.Jobs = jobs
+
+	stages := make(map[int]*Stage, len(build.Stages))
+	for id, stage := range build.Stages {
+		total += len(stage.Jobs)
+		s := *stage
+		s.Jobs = make([]*Job, 0, len(stage.Jobs))
+		for _, job := range stage.Jobs {
+			if c.jobFilter(stage.Name, job.Name) {
+				s.Jobs = append(s.Jobs, job)
+			}
+		}
+		kept += len(s.Jobs)
+		if len(s.Jobs) < len(stage.Jobs) && len(s.Jobs) > 0 {
+			s.State = AggregateStatuses(latestRuns(s.Jobs))
+		}
+		if len(s.Jobs) > 0 || len(stage.Jobs) == 0 {
+			stages[id] = &s
+		}
+	}
+	build.Stages = stages
+
+	if kept < total && kept > 0 {
+		statusers := latestRuns(build.Jobs)
+		for _, stage := range build.Stages {
+			statusers = append(statusers, latestRuns(stage.Jobs)...)
+		}
+		build.State = AggregateStatuses(statusers)
+	}
+
+	return build, total == 0 || kept > 0
+}
+
+// Return the last run of each job of 'jobs'. Earlier runs of a job, identified by its name,
+// must not influence the state of the stage or pipeline it belongs to.
+func latestRuns(jobs []*Job) []Statuser {
+	latest := make(map[string]*Job, len(jobs))
+	for _, job := range jobs {
+		// Dates may be NULL so we have to rely on IDs to find out which job is older
+		if previous, exists := latest[job.Name]; !exists || previous.ID < job.ID {
+			latest[job.Name] = job
+		}
+	}
+	statusers := make([]Statuser, 0, len(latest))
+	for _, job := range jobs {
+		if latest[job.Name] == job {
+			statusers = append(statusers, *job)
+		}
+	}
+	return statusers
+}
+
 func (c *Cache) Save(build Build) error {
 	if build.Repository == nil {
 		return errors.New("build.repository must not be nil")
@@ -267,6 +622,84 @@ func (c *Cache) SaveJob(accountID string, buildID string, stageID int, job Job)
 	return nil
 }
 
+// Number of previous pipelines used to compute the average duration of jobs
+const historySize = 5
+
+// DurationKey returns the name under which the average duration of a job is stored. The average
+// duration of the pipeline itself is stored under the key of an empty job name.
+func DurationKey(stageName string, jobName string) string {
+	if stageName == "" {
+		return jobName
+	}
+	return stageName + "/" + jobName
+}
+
+// Return the average duration of the pipelines and jobs of 'builds' indexed by DurationKey. Only
+// passed pipelines and jobs are taken into account, failures often being shorter.
+func averageDurations(builds []Build) map[string]time.Duration {
+	sums := make(map[string]time.Duration)
+	counts := make(map[string]int)
+	add := func(key string, state State, d utils.NullDuration) {
+		if state == Passed && d.Valid && d.Duration > 0 {
+			sums[key] += d.Duration
+			counts[key]++
+		}
+	}
+
+	for _, build := range builds {
+		add("", build.State, build.Duration)
+		for _, job := range build.Jobs {
+			if job.Name != "" {
+				add(DurationKey("", job.Name), job.State, job.Duration)
+			}
+		}
+		for _, stage := range build.Stages {
+			for _, job := range stage.Jobs {
+				if job.Name != "" {
+					add(DurationKey(stage.Name, job.Name), job.State, job.Duration)
+				}
+			}
+		}
+	}
+
+	averages := make(map[string]time.Duration, len(sums))
+	for key, sum := range sums {
+		averages[key] = sum / time.Duration(counts[key])
+	}
+	return averages
+}
+
+// Compute the average duration of the pipelines that passed on the same ref before 'build'
+func (c *Cache) fetchHistory(ctx context.Context, h HistoryProvider, build Build) error {
+	builds, err := h.PreviousBuilds(ctx, build, historySize)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.averages[buildKey{
+		AccountID: build.Repository.Provider.ID,
+		BuildID:   build.ID,
+	}] = averageDurations(builds)
+
+	return nil
+}
+
+// AverageDuration returns the average duration of the job 'jobName' of stage 'stageName' over the
+// pipelines that passed on the same ref before the pipeline 'buildID'. An empty job name
+// designates the pipeline itself.
+func (c Cache) AverageDuration(accountID string, buildID string, stageName string, jobName string) (time.Duration, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	averages, exists := c.averages[buildKey{AccountID: accountID, BuildID: buildID}]
+	if !exists {
+		return 0, false
+	}
+	d, exists := averages[DurationKey(stageName, jobName)]
+	return d, exists
+}
+
 func (c Cache) Builds() []Build {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -278,8 +711,34 @@ func (c Cache) Builds() []Build {
 	return builds
 }
 
-func (c *Cache) MonitorPipeline(ctx context.Context, p CIProvider, u string, updates chan time.Time) error {
-	b := backoff.ExponentialBackOff{
+// Return the source providers and the CI providers used for the repository 'repo' of 'owner'.
+// An error is returned if all source providers are restricted to other repositories.
+func (c Cache) providersFor(owner string, repo string) ([]SourceProvider, []CIProvider, error) {
+	sourceProviders := make([]SourceProvider, 0, len(c.sourceProviders))
+	for _, p := range c.sourceProviders {
+		if UsedFor(p, owner, repo) {
+			sourceProviders = append(sourceProviders, p)
+		}
+	}
+	if len(sourceProviders) == 0 && len(c.sourceProviders) > 0 {
+		return nil, nil, fmt.Errorf("no account is configured for the repository %s/%s", owner, repo)
+	}
+	ciProviders := make([]CIProvider, 0, len(c.ciProvidersById))
+	for _, p := range c.ciProvidersById {
+		if UsedFor(p, owner, repo) {
+			ciProviders = append(ciProviders, p)
+		}
+	}
+	return sourceProviders, ciProviders, nil
+}
+
+// Return the policy used for polling providers. Requests are only made once if a notifier
+// reports the updates of pipelines.
+func pollingBackOff(notified bool) backoff.BackOff {
+	if notified {
+		return &backoff.StopBackOff{}
+	}
+	b := &backoff.ExponentialBackOff{
 		InitialInterval:     5 * time.Second,
 		RandomizationFactor: backoff.DefaultRandomizationFactor,
 		Multiplier:          backoff.DefaultMultiplier,
@@ -288,7 +747,12 @@ func (c *Cache) MonitorPipeline(ctx context.Context, p CIProvider, u string, upd
 		Clock:               backoff.SystemClock,
 	}
 	b.Reset()
+	return b
+}
 
+// Poll the pipeline at URL 'u' until the backoff policy 'b' expires and send an event to
+// 'updates' every time the pipeline is saved to the cache
+func (c *Cache) monitorPipeline(ctx context.Context, p CIProvider, u string, b backoff.BackOff, updates chan<- Event) error {
 	for waitTime := time.Duration(0); waitTime != backoff.Stop; waitTime = b.NextBackOff() {
 		select {
 		case <-time.After(waitTime):
@@ -298,18 +762,62 @@ func (c *Cache) MonitorPipeline(ctx context.Context, p CIProvider, u string, upd
 		}
 
 		build, err := p.BuildFromURL(ctx, u)
+		if err == ErrUnknownURL {
+			// The pipeline is hosted by another provider
+			return err
+		}
+		// Connectivity problems are reported to the user instead of stopping the monitoring of
+		// all pipelines. Requests are retried until the backoff policy expires unless the
+		// credentials of the user were rejected.
+		switch c.recordRequest(p.ID(), p, err) {
+		case ProviderRateLimited, ProviderUnreachable:
+			continue
+		case ProviderAuthFailed:
+			return nil
+		}
 		if err != nil {
 			return err
 		}
+		build, kept := c.filter(build)
+		if !kept {
+			continue
+		}
+
+		eventType := PipelineAdded
+		if build.Repository != nil {
+			if _, exists := c.fetchBuild(build.Repository.Provider.ID, build.ID); exists {
+				eventType = PipelineUpdated
+			}
+		}
 
 		switch err := c.Save(build); err {
 		case nil:
-			go func() {
+			event := Event{
+				Type:  eventType,
+				Time:  time.Now(),
+				Build: build,
+			}
+			select {
+			case updates <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			// The history of the pipeline is only used to show trends so failing to fetch it
+			// must not stop monitoring
+			h, ok := p.(HistoryProvider)
+			if ok && eventType == PipelineAdded && c.fetchHistory(ctx, h, build) == nil {
+				event = Event{
+					Type:  PipelineUpdated,
+					Time:  time.Now(),
+					Build: build,
+				}
 				select {
-				case updates <- time.Now():
+				case updates <- event:
 				case <-ctx.Done():
+					return ctx.Err()
 				}
-			}()
+			}
 		case ErrOlderBuild:
 			// Do nothing. This is useful to avoid deleting logs on an existing build since
 			// p.BuildFromURL() will always return build without logs.
@@ -321,31 +829,88 @@ func (c *Cache) MonitorPipeline(ctx context.Context, p CIProvider, u string, upd
 	return nil
 }
 
-func (c *Cache) GetPipelines(ctx context.Context, repositoryURL string, commit utils.Commit, updates chan time.Time) error {
+// Monitor the pipelines associated to commit 'sha' of the repository at 'repositoryURL' and
+// send an event to 'updates' every time one of them is saved to the cache. All sends to
+// 'updates' are complete by the time this function returns.
+func (c *Cache) monitorPipelines(ctx context.Context, repositoryURL string, sha string, updates chan<- Event) error {
 	var err error
 	_, owner, repo, err := utils.RepoHostOwnerAndName(repositoryURL)
 	if err != nil {
 		return err
 	}
 
+	providers, ciProviders, err := c.providersFor(owner, repo)
+	if err != nil {
+		return err
+	}
+	sourceProviders := make([]SourceProvider, 0, len(providers))
+	notifiers := make([]SourceProvider, 0)
+	for _, p := range providers {
+		if _, ok := p.(Notifier); ok {
+			notifiers = append(notifiers, p)
+		} else {
+			sourceProviders = append(sourceProviders, p)
+		}
+	}
+	notified := len(notifiers) > 0
+
 	errc := make(chan error)
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	wg := sync.WaitGroup{}
-	for _, p := range c.sourceProviders {
+	// Pipelines are fetched by every CI provider since all providers but 1 should return
+	// ErrUnknownURL
+	monitor := func(u string, b func() backoff.BackOff) {
+		for _, p := range ciProviders {
+			wg.Add(1)
+			go func(p CIProvider) {
+				defer wg.Done()
+				err := c.monitorPipeline(ctx, p, u, b(), updates)
+				if err != nil && err != ErrUnknownURL {
+					errc <- fmt.Errorf("provider %s: monitoring failed with %v (%s)", p.ID(), err, u)
+					return
+				}
+			}(p)
+		}
+	}
+	once := func() backoff.BackOff { return &backoff.StopBackOff{} }
+
+	// Goroutines looking for the pipelines of the commit
+	sources := sync.WaitGroup{}
+	for _, p := range notifiers {
+		notifications, err := p.(Notifier).Notifications(ctx)
+		if err != nil {
+			return fmt.Errorf("provider %s: %v", p.ID(), err)
+		}
 		wg.Add(1)
-		go func(p SourceProvider) {
+		sources.Add(1)
+		go func(p SourceProvider, notifications <-chan Notification) {
 			defer wg.Done()
+			defer sources.Done()
 
-			b := backoff.ExponentialBackOff{
-				InitialInterval:     5 * time.Second,
-				RandomizationFactor: backoff.DefaultRandomizationFactor,
-				Multiplier:          backoff.DefaultMultiplier,
-				MaxInterval:         5 * time.Minute,
-				MaxElapsedTime:      15 * time.Minute,
-				Clock:               backoff.SystemClock,
+			// Notifications received before monitoring started
+			if us, err := p.BuildURLs(ctx, owner, repo, sha); err == nil {
+				for _, u := range us {
+					monitor(u, once)
+				}
 			}
-			b.Reset()
+			for notification := range notifications {
+				if notification.Sha == sha {
+					monitor(notification.URL, once)
+				}
+			}
+			errc <- ctx.Err()
+		}(p, notifications)
+	}
+
+	for _, p := range sourceProviders {
+		wg.Add(1)
+		sources.Add(1)
+		go func(p SourceProvider) {
+			defer wg.Done()
+			defer sources.Done()
 
+			b := pollingBackOff(notified)
 			for waitTime := time.Duration(0); waitTime != backoff.Stop; waitTime = b.NextBackOff() {
 				select {
 				case <-time.After(waitTime):
@@ -355,29 +920,57 @@ func (c *Cache) GetPipelines(ctx context.Context, repositoryURL string, commit u
 					return
 				}
 
-				us, err := p.BuildURLs(ctx, owner, repo, commit.Sha)
+				us, err := p.BuildURLs(ctx, owner, repo, sha)
+				switch c.recordRequest(p.ID(), p, err) {
+				case ProviderRateLimited, ProviderUnreachable:
+					continue
+				case ProviderAuthFailed:
+					errc <- nil
+					return
+				}
+				if err == ErrRepositoryNotFound {
+					// Only fail if the repository is unknown to all source providers
+					errc <- err
+					return
+				}
 				if err != nil {
-					errc <- fmt.Errorf("provider %s: %v (%s@%s/%s)", p.ID(), err, commit.Sha, owner, repo)
+					errc <- fmt.Errorf("provider %s: %v (%s@%s/%s)", p.ID(), err, sha, owner, repo)
 					return
 				}
 				for _, u := range us {
-					// All providers but 1 should return ErrRepositoryNotFound
-					for _, p := range c.ciProvidersById {
-						wg.Add(1)
-						go func(p CIProvider, u string) {
-							defer wg.Done()
-							err := c.MonitorPipeline(ctx, p, u, updates)
-							if err != nil && err != ErrUnknownURL {
-								errc <- fmt.Errorf("provider %s: MonitorPipeline failed with %v (%s)", p.ID(), err, u)
-								return
-							}
-						}(p, u)
-					}
+					monitor(u, func() backoff.BackOff { return pollingBackOff(notified) })
 				}
 			}
 		}(p)
 	}
 
+	// Pipelines acted upon by the user are monitored for as long as the pipelines of the commit
+	// are looked for. Failing to monitor them is not an error since the URL returned by some
+	// providers only designates the repository.
+	sourcesDone := make(chan struct{})
+	go func() {
+		sources.Wait()
+		close(sourcesDone)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case t := <-c.tracked:
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_ = c.monitorPipeline(ctx, t.provider, t.url, pollingBackOff(false), updates)
+				}()
+			case <-sourcesDone:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
 	go func() {
 		wg.Wait()
 		close(errc)
@@ -391,8 +984,9 @@ errLoop:
 		case nil:
 			continue errLoop
 		case ErrRepositoryNotFound:
+			// Pipelines of repositories unknown to source providers may still be notified
 			count++
-			if count < len(c.sourceProviders) {
+			if count < len(sourceProviders) || notified {
 				continue errLoop
 			}
 		}
@@ -406,6 +1000,135 @@ errLoop:
 	return err
 }
 
+// MonitorPipeline polls the pipeline at URL 'u' until it stops changing and sends the time of
+// every update of the pipeline to 'updates'.
+//
+// Deprecated: Use Engine, whose events also describe the pipeline updated.
+func (c *Cache) MonitorPipeline(ctx context.Context, p CIProvider, u string, updates chan time.Time) error {
+	return forwardTimes(ctx, updates, func(events chan<- Event) error {
+		return c.monitorPipeline(ctx, p, u, pollingBackOff(false), events)
+	})
+}
+
+// GetPipelines monitors the pipelines associated to 'commit' of the repository at
+// 'repositoryURL' and sends the time of every update of one of them to 'updates'.
+//
+// Deprecated: Use Engine, whose events also describe the pipeline updated.
+func (c *Cache) GetPipelines(ctx context.Context, repositoryURL string, commit utils.Commit, updates chan time.Time) error {
+	return forwardTimes(ctx, updates, func(events chan<- Event) error {
+		return c.monitorPipelines(ctx, repositoryURL, commit.Sha, events)
+	})
+}
+
+// Run 'monitor' and send the time of the events it produces to 'updates'. As required by the
+// deprecated methods relying on it, sends do not wait for 'updates' to be received.
+func forwardTimes(ctx context.Context, updates chan time.Time, monitor func(events chan<- Event) error) error {
+	events := make(chan Event)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- monitor(events)
+	}()
+
+	for {
+		select {
+		case event := <-events:
+			go func(t time.Time) {
+				select {
+				case updates <- t:
+				case <-ctx.Done():
+				}
+			}(event.Time)
+		case err := <-errc:
+			// 'monitor' has no pending send to 'events' once it has returned
+			return err
+		}
+	}
+}
+
+// Pipelines queries every provider once and returns the pipelines associated to the commit
+// 'sha' of the repository at 'repositoryURL'. Unlike Engine, it does not keep monitoring active
+// pipelines. 'sha' must be the full SHA-1 identifier of the commit.
+func (c *Cache) Pipelines(ctx context.Context, repositoryURL string, sha string) ([]Build, error) {
+	_, owner, repo, err := utils.RepoHostOwnerAndName(repositoryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceProviders, ciProviders, err := c.providersFor(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0)
+	notFound := 0
+	for _, p := range sourceProviders {
+		us, err := p.BuildURLs(ctx, owner, repo, sha)
+		switch err {
+		case nil:
+			urls = append(urls, us...)
+		case ErrRepositoryNotFound:
+			notFound++
+		default:
+			return nil, fmt.Errorf("provider %s: %v (%s@%s/%s)", p.ID(), err, sha, owner, repo)
+		}
+	}
+	if notFound > 0 && notFound == len(sourceProviders) {
+		return nil, ErrRepositoryNotFound
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	mutex := sync.Mutex{}
+	builds := make([]Build, 0)
+	errc := make(chan error)
+	wg := sync.WaitGroup{}
+	for _, u := range urls {
+		// All providers but 1 should return ErrUnknownURL
+		for _, p := range ciProviders {
+			wg.Add(1)
+			go func(p CIProvider, u string) {
+				defer wg.Done()
+				build, err := p.BuildFromURL(ctx, u)
+				kept := false
+				if err == nil {
+					if build, kept = c.filter(build); kept {
+						if err = c.Save(build); err == ErrOlderBuild {
+							err = nil
+						}
+					}
+				}
+				switch err {
+				case nil:
+					if kept {
+						mutex.Lock()
+						builds = append(builds, build)
+						mutex.Unlock()
+					}
+				case ErrUnknownURL:
+					// Do nothing
+				default:
+					errc <- fmt.Errorf("provider %s: %v (%s)", p.ID(), err, u)
+				}
+			}(p, u)
+		}
+	}
+
+	go func() {
+		wg.Wait()
+		close(errc)
+	}()
+
+	for e := range errc {
+		if err == nil {
+			cancel()
+			err = e
+		}
+	}
+
+	return builds, err
+}
+
 func (c *Cache) fetchBuild(accountID string, buildID string) (Build, bool) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -430,41 +1153,468 @@ func (c *Cache) fetchJob(accountID string, buildID string, stageID int, jobID st
 	return build.Get(stageID, jobID)
 }
 
-var ErrIncompleteLog = errors.New("log not complete")
+// Build returns the pipeline designated by its identifiers, if it is in the cache
+func (c *Cache) Build(accountID string, buildID string) (Build, bool) {
+	return c.fetchBuild(accountID, buildID)
+}
 
-func (c *Cache) WriteLog(ctx context.Context, accountID string, buildID string, stageID int, jobID string, writer io.Writer) error {
+// Job returns the job designated by its identifiers, if it is in the cache
+func (c *Cache) Job(accountID string, buildID string, stageID int, jobID string) (Job, bool) {
+	return c.fetchJob(accountID, buildID, stageID, jobID)
+}
+
+// Deployment returns the deployment designated by its identifiers, if it is in the cache
+func (c *Cache) Deployment(accountID string, buildID string, deploymentID string) (Deployment, bool) {
+	build, exists := c.fetchBuild(accountID, buildID)
+	if !exists {
+		return Deployment{}, false
+	}
+	for _, deployment := range build.Deployments {
+		if deployment.ID == deploymentID {
+			return *deployment, true
+		}
+	}
+	return Deployment{}, false
+}
+
+var ErrUnsupportedAction = errors.New("action not supported by the provider")
+var ErrActionNotPermitted = errors.New("action not permitted")
+
+// Return the deployment designated by its identifiers along with its pipeline and the provider
+// able to act on it
+func (c *Cache) deploymentManager(accountID string, buildID string, deploymentID string) (DeploymentManager, Build, Deployment, error) {
+	build, exists := c.fetchBuild(accountID, buildID)
+	if !exists {
+		return nil, Build{}, Deployment{}, fmt.Errorf("no matching build for %v %v", accountID, buildID)
+	}
+	deployment, exists := c.Deployment(accountID, buildID, deploymentID)
+	if !exists {
+		return nil, Build{}, Deployment{}, fmt.Errorf("no matching deployment for %v %v %v", accountID, buildID, deploymentID)
+	}
+	provider, exists := c.ciProvidersById[accountID]
+	if !exists {
+		return nil, Build{}, Deployment{}, fmt.Errorf("no matching provider found in cache for account ID %q", accountID)
+	}
+	manager, ok := provider.(DeploymentManager)
+	if !ok {
+		return nil, Build{}, Deployment{}, ErrUnsupportedAction
+	}
+
+	return manager, build, deployment, nil
+}
+
+// StopEnvironment stops the environment of the deployment designated by its identifiers
+func (c *Cache) StopEnvironment(ctx context.Context, accountID string, buildID string, deploymentID string) error {
+	manager, build, deployment, err := c.deploymentManager(accountID, buildID, deploymentID)
+	if err != nil {
+		return err
+	}
+	if !deployment.CanStop {
+		return ErrActionNotPermitted
+	}
+	return manager.StopEnvironment(ctx, *build.Repository, deployment)
+}
+
+// RetryDeployment runs again the job of the deployment designated by its identifiers
+func (c *Cache) RetryDeployment(ctx context.Context, accountID string, buildID string, deploymentID string) error {
+	manager, build, deployment, err := c.deploymentManager(accountID, buildID, deploymentID)
+	if err != nil {
+		return err
+	}
+	if !deployment.CanRetry {
+		return ErrActionNotPermitted
+	}
+	return manager.RetryDeployment(ctx, *build.Repository, deployment)
+}
+
+// Return the pipeline designated by its identifiers along with the provider able to cancel it
+func (c *Cache) canceler(accountID string, buildID string) (PipelineCanceler, Build, error) {
+	build, exists := c.fetchBuild(accountID, buildID)
+	if !exists {
+		return nil, Build{}, fmt.Errorf("no matching build for %v %v", accountID, buildID)
+	}
+	provider, exists := c.ciProvidersById[accountID]
+	if !exists {
+		return nil, Build{}, fmt.Errorf("no matching provider found in cache for account ID %q", accountID)
+	}
+	canceler, ok := provider.(PipelineCanceler)
+	if !ok {
+		return nil, Build{}, ErrUnsupportedAction
+	}
+
+	return canceler, build, nil
+}
+
+// CancelBuild cancels the pipeline designated by its identifiers if it is still active
+func (c *Cache) CancelBuild(ctx context.Context, accountID string, buildID string) error {
+	canceler, build, err := c.canceler(accountID, buildID)
+	if err != nil {
+		return err
+	}
+	if !build.State.IsActive() {
+		return ErrActionNotPermitted
+	}
+	return canceler.CancelBuild(ctx, build)
+}
+
+// CancelJob cancels the job designated by its identifiers if it is still active
+func (c *Cache) CancelJob(ctx context.Context, accountID string, buildID string, stageID int, jobID string) error {
+	canceler, build, err := c.canceler(accountID, buildID)
+	if err != nil {
+		return err
+	}
+	job, exists := build.Get(stageID, jobID)
+	if !exists {
+		return fmt.Errorf("no matching job for %v %v %v %v", accountID, buildID, stageID, jobID)
+	}
+	if !job.State.IsActive() {
+		return ErrActionNotPermitted
+	}
+	return canceler.CancelJob(ctx, build, job)
+}
+
+// RestartBuild runs again the pipeline designated by its identifiers if it is finished and
+// returns the web page of the pipeline started. The pipeline started is saved to the cache and
+// monitored along with the pipelines of the commit.
+func (c *Cache) RestartBuild(ctx context.Context, accountID string, buildID string) (string, error) {
+	build, exists := c.fetchBuild(accountID, buildID)
+	if !exists {
+		return "", fmt.Errorf("no matching build for %v %v", accountID, buildID)
+	}
+	provider, exists := c.ciProvidersById[accountID]
+	if !exists {
+		return "", fmt.Errorf("no matching provider found in cache for account ID %q", accountID)
+	}
+	restarter, ok := provider.(PipelineRestarter)
+	if !ok {
+		return "", ErrUnsupportedAction
+	}
+	if build.State.IsActive() {
+		return "", ErrActionNotPermitted
+	}
+
+	u, err := restarter.RestartBuild(ctx, build)
+	if err != nil {
+		return "", err
+	}
+
+	c.refetch(ctx, provider, u)
+
+	return u, nil
+}
+
+// Save the pipeline at URL 'u' after the user acted on it to show its new state right away, and
+// monitor it along with the pipelines of the commit. The pipeline is monitored even if fetching
+// it fails since its creation may not be complete yet.
+func (c *Cache) refetch(ctx context.Context, p CIProvider, u string) {
+	if build, err := p.BuildFromURL(ctx, u); err == nil {
+		if build, kept := c.filter(build); kept {
+			_ = c.Save(build)
+		}
+	}
+	select {
+	case c.tracked <- trackedPipeline{provider: p, url: u}:
+	default:
+		// Too many pipelines were acted upon at once, or pipelines are not monitored anymore
+	}
+}
+
+// RetryJob runs again the job designated by its identifiers if it is finished and returns the
+// identifier of the job started. The pipeline of the job is updated in the cache and monitored
+// along with the pipelines of the commit.
+func (c *Cache) RetryJob(ctx context.Context, accountID string, buildID string, stageID int, jobID string) (string, error) {
+	build, exists := c.fetchBuild(accountID, buildID)
+	if !exists {
+		return "", fmt.Errorf("no matching build for %v %v", accountID, buildID)
+	}
+	job, exists := build.Get(stageID, jobID)
+	if !exists {
+		return "", fmt.Errorf("no matching job for %v %v %v %v", accountID, buildID, stageID, jobID)
+	}
+	provider, exists := c.ciProvidersById[accountID]
+	if !exists {
+		return "", fmt.Errorf("no matching provider found in cache for account ID %q", accountID)
+	}
+	retrier, ok := provider.(JobRetrier)
+	if !ok {
+		return "", ErrUnsupportedAction
+	}
+	if job.State.IsActive() {
+		return "", ErrActionNotPermitted
+	}
+
+	id, err := retrier.RetryJob(ctx, build, job)
+	if err != nil {
+		return "", err
+	}
+	c.refetch(ctx, provider, build.WebURL)
+
+	return id, nil
+}
+
+// StartManualJob starts the job designated by its identifiers if it waits for the action of a
+// user. The pipeline of the job is updated in the cache and monitored along with the pipelines
+// of the commit.
+func (c *Cache) StartManualJob(ctx context.Context, accountID string, buildID string, stageID int, jobID string) error {
 	build, exists := c.fetchBuild(accountID, buildID)
 	if !exists {
 		return fmt.Errorf("no matching build for %v %v", accountID, buildID)
 	}
-	job, exists := c.fetchJob(accountID, buildID, stageID, jobID)
+	job, exists := build.Get(stageID, jobID)
 	if !exists {
 		return fmt.Errorf("no matching job for %v %v %v %v", accountID, buildID, stageID, jobID)
 	}
+	provider, exists := c.ciProvidersById[accountID]
+	if !exists {
+		return fmt.Errorf("no matching provider found in cache for account ID %q", accountID)
+	}
+	starter, ok := provider.(ManualJobStarter)
+	if !ok {
+		return ErrUnsupportedAction
+	}
+	if job.State != Manual {
+		return ErrActionNotPermitted
+	}
+
+	if err := starter.StartManualJob(ctx, build, job); err != nil {
+		return err
+	}
+	c.refetch(ctx, provider, build.WebURL)
+
+	return nil
+}
+
+var ErrNoPipelineStarted = errors.New("no provider started a pipeline")
+
+// TriggerPipelines starts a pipeline on 'ref' of the repository at 'repositoryURL' with every
+// provider able to do so that knows the repository, and returns the web pages of the pipelines
+// started. The pipelines are monitored along with the pipelines of the commit.
+func (c *Cache) TriggerPipelines(ctx context.Context, repositoryURL string, ref string, variables []Variable) ([]string, error) {
+	_, owner, repo, err := utils.RepoHostOwnerAndName(repositoryURL)
+	if err != nil {
+		return nil, err
+	}
+	_, ciProviders, err := c.providersFor(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0)
+	for _, p := range ciProviders {
+		trigger, ok := p.(PipelineTrigger)
+		if !ok {
+			continue
+		}
+		u, err := trigger.TriggerPipeline(ctx, repositoryURL, ref, variables)
+		switch err {
+		case nil:
+			urls = append(urls, u)
+			c.refetch(ctx, p, u)
+		case ErrUnknownURL, ErrRepositoryNotFound, ErrUnsupportedAction:
+			// The provider cannot start pipelines of the repository
+		default:
+			return urls, fmt.Errorf("provider %s: %v", p.ID(), err)
+		}
+	}
+	if len(urls) == 0 {
+		return nil, ErrNoPipelineStarted
+	}
+
+	return urls, nil
+}
+
+// Return the job of a build designated by its identifiers along with the build and the
+// provider of the build
+func (c *Cache) jobProvider(accountID string, buildID string, stageID int, jobID string) (CIProvider, Build, Job, error) {
+	build, exists := c.fetchBuild(accountID, buildID)
+	if !exists {
+		return nil, Build{}, Job{}, fmt.Errorf("no matching build for %v %v", accountID, buildID)
+	}
+	job, exists := build.Get(stageID, jobID)
+	if !exists {
+		return nil, Build{}, Job{}, fmt.Errorf("no matching job for %v %v %v %v", accountID, buildID, stageID, jobID)
+	}
+	provider, exists := c.ciProvidersById[accountID]
+	if !exists {
+		return nil, Build{}, Job{}, fmt.Errorf("no matching provider found in cache for account ID %q", accountID)
+	}
+	return provider, build, job, nil
+}
+
+// Same as jobProvider for providers able to download artifacts
+func (c *Cache) artifactProvider(accountID string, buildID string, stageID int, jobID string) (ArtifactProvider, Build, Job, error) {
+	provider, build, job, err := c.jobProvider(accountID, buildID, stageID, jobID)
+	if err != nil {
+		return nil, Build{}, Job{}, err
+	}
+	artifacts, ok := provider.(ArtifactProvider)
+	if !ok {
+		return nil, Build{}, Job{}, ErrUnsupportedAction
+	}
+	return artifacts, build, job, nil
+}
+
+// Artifacts lists the artifacts of a job. Artifacts are always fetched from the provider since
+// they may be uploaded until the job finishes.
+func (c *Cache) Artifacts(ctx context.Context, accountID string, buildID string, stageID int, jobID string) ([]Artifact, error) {
+	provider, build, job, err := c.artifactProvider(accountID, buildID, stageID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Artifacts(ctx, build, job)
+}
+
+// WriteArtifact writes the content of the artifact of a job at 'path' to 'w'
+func (c *Cache) WriteArtifact(ctx context.Context, accountID string, buildID string, stageID int, jobID string, path string, w io.Writer) error {
+	provider, build, job, err := c.artifactProvider(accountID, buildID, stageID, jobID)
+	if err != nil {
+		return err
+	}
+	return provider.WriteArtifact(ctx, build, job, path, w)
+}
+
+// Largest artifact read as a JUnit report
+const maxJUnitArtifactSize = 10 << 20
+
+// Return true if 'artifact' may be a JUnit report
+func isJUnitCandidate(artifact Artifact) bool {
+	p := strings.ToLower(artifact.Path)
+	if !strings.HasSuffix(p, ".xml") || artifact.Size > maxJUnitArtifactSize {
+		return false
+	}
+	return strings.Contains(p, "junit") || strings.Contains(p, "test")
+}
+
+// Read the results of the tests run by 'job' from the JUnit reports found among its artifacts.
+// XML files that are not JUnit reports are ignored.
+func junitReport(ctx context.Context, provider ArtifactProvider, build Build, job Job) (TestReport, error) {
+	artifacts, err := provider.Artifacts(ctx, build, job)
+	if err != nil {
+		return TestReport{}, err
+	}
+
+	report := TestReport{}
+	found := false
+	for _, artifact := range artifacts {
+		if !isJUnitCandidate(artifact) {
+			continue
+		}
+		buf := bytes.Buffer{}
+		if err := provider.WriteArtifact(ctx, build, job, artifact.Path, &buf); err != nil {
+			return TestReport{}, err
+		}
+		r, err := ParseJUnit(&buf)
+		if err != nil {
+			continue
+		}
+		report.merge(r)
+		found = true
+	}
+	if !found {
+		return TestReport{}, ErrNoTestReport
+	}
+
+	return report, nil
+}
+
+// TestReport returns the results of the tests run by a job. Test results are collected by the
+// provider of the job if it supports it, otherwise they are read from the JUnit reports found
+// among the artifacts of the job. ErrNoTestReport is returned if there are none.
+func (c *Cache) TestReport(ctx context.Context, accountID string, buildID string, stageID int, jobID string) (TestReport, error) {
+	provider, build, job, err := c.jobProvider(accountID, buildID, stageID, jobID)
+	if err != nil {
+		return TestReport{}, err
+	}
+
+	reporter, isReporter := provider.(TestReportProvider)
+	if isReporter {
+		report, err := reporter.TestReport(ctx, build, job)
+		if err != ErrNoTestReport {
+			return report, err
+		}
+	}
+	if artifacts, ok := provider.(ArtifactProvider); ok {
+		return junitReport(ctx, artifacts, build, job)
+	}
+	if isReporter {
+		return TestReport{}, ErrNoTestReport
+	}
+	return TestReport{}, ErrUnsupportedAction
+}
+
+var ErrIncompleteLog = errors.New("log not complete")
+var ErrNoLogHere = errors.New("no log is associated to this row")
+
+// Log returns the log of a job as written by its provider. The log is fetched from the provider
+// unless the cache holds it already. The log of a finished job is kept in cache.
+func (c *Cache) Log(ctx context.Context, accountID string, buildID string, stageID int, jobID string) (string, error) {
+	build, exists := c.fetchBuild(accountID, buildID)
+	if !exists {
+		return "", fmt.Errorf("no matching build for %v %v", accountID, buildID)
+	}
+	job, exists := c.fetchJob(accountID, buildID, stageID, jobID)
+	if !exists {
+		return "", fmt.Errorf("no matching job for %v %v %v %v", accountID, buildID, stageID, jobID)
+	}
 
 	if !job.Log.Valid {
 		provider, exists := c.ciProvidersById[accountID]
 		if !exists {
-			return fmt.Errorf("no matching provider found in cache for account ID %q", accountID)
+			return "", fmt.Errorf("no matching provider found in cache for account ID %q", accountID)
 		}
 		log, err := provider.Log(ctx, *build.Repository, job.ID)
 		if err != nil {
-			return err
+			return "", err
 		}
 
 		job.Log = utils.NullString{String: log, Valid: true}
 		if !job.State.IsActive() {
 			if err = c.SaveJob(accountID, buildID, stageID, job); err != nil {
-				return err
+				return "", err
 			}
 		}
 	}
 
-	log := job.Log.String
+	return job.Log.String, nil
+}
+
+// RefreshJob fetches again the pipeline of the job designated by its identifiers, saves it to
+// the cache and returns the job. This keeps the state of a job up to date while its log is
+// followed.
+func (c *Cache) RefreshJob(ctx context.Context, accountID string, buildID string, stageID int, jobID string) (Job, error) {
+	provider, build, _, err := c.jobProvider(accountID, buildID, stageID, jobID)
+	if err != nil {
+		return Job{}, err
+	}
+	build, err = provider.BuildFromURL(ctx, build.WebURL)
+	if err != nil {
+		return Job{}, err
+	}
+	if build, kept := c.filter(build); kept {
+		if err := c.Save(build); err != nil && err != ErrOlderBuild {
+			return Job{}, err
+		}
+	}
+
+	job, exists := c.fetchJob(accountID, buildID, stageID, jobID)
+	if !exists {
+		return Job{}, fmt.Errorf("no matching job for %v %v %v %v", accountID, buildID, stageID, jobID)
+	}
+	return job, nil
+}
+
+// WriteLog writes the log of a job to 'writer' once the escape sequences erasing parts of lines
+// have been applied
+func (c *Cache) WriteLog(ctx context.Context, accountID string, buildID string, stageID int, jobID string, writer io.Writer) error {
+	log, err := c.Log(ctx, accountID, buildID, stageID, jobID)
+	if err != nil {
+		return err
+	}
+
 	if !strings.HasSuffix(log, "\n") {
 		log = log + "\n"
 	}
 	processedLog := utils.PostProcess(log)
-	_, err := writer.Write([]byte(processedLog))
+	_, err = writer.Write([]byte(processedLog))
 	return err
 }
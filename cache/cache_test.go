@@ -3,7 +3,12 @@ package cache
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -331,7 +336,288 @@ func (p mockProvider) Log(ctx context.Context, repository Repository, jobID stri
 	return "log\n", nil
 }
 func (p mockProvider) BuildFromURL(ctx context.Context, u string) (Build, error) {
-	return Build{}, nil
+	for _, build := range p.builds {
+		if build.WebURL == u {
+			return build, nil
+		}
+	}
+	return Build{}, ErrUnknownURL
+}
+
+type mockSourceProvider struct {
+	id   string
+	urls []string
+	err  error
+	// Owners of the repositories the provider is used for, empty for all owners
+	owners []string
+	// Repositories the provider is not used for, such as "owner/repo"
+	excluded []string
+}
+
+func (p mockSourceProvider) ID() string { return p.id }
+func (p mockSourceProvider) MatchesOwner(owner string) bool {
+	for _, o := range p.owners {
+		if o == owner {
+			return true
+		}
+	}
+	return len(p.owners) == 0
+}
+func (p mockSourceProvider) MatchesRepository(owner string, repo string) bool {
+	for _, r := range p.excluded {
+		if r == owner+"/"+repo {
+			return false
+		}
+	}
+	return true
+}
+func (p mockSourceProvider) BuildURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
+	return p.urls, p.err
+}
+func (p mockSourceProvider) Commit(ctx context.Context, repo string, sha string) (utils.Commit, error) {
+	return utils.Commit{Sha: sha}, p.err
+}
+
+func TestCache_Pipelines(t *testing.T) {
+	newBuild := func(providerID string, id string) Build {
+		return Build{
+			Repository: &Repository{
+				Provider: Provider{
+					ID: providerID,
+				},
+			},
+			ID:     id,
+			WebURL: fmt.Sprintf("https://example.com/%s/%s", providerID, id),
+		}
+	}
+	builds := []Build{
+		newBuild("provider1", "1"),
+		newBuild("provider1", "2"),
+		newBuild("provider2", "1"),
+	}
+	ciProviders := []CIProvider{
+		mockProvider{
+			id:     "provider1",
+			builds: builds[:2],
+		},
+		mockProvider{
+			id:     "provider2",
+			builds: builds[2:],
+		},
+	}
+
+	t.Run("builds of all providers must be returned", func(t *testing.T) {
+		sourceProviders := []SourceProvider{
+			mockSourceProvider{
+				id:   "source1",
+				urls: []string{builds[0].WebURL, builds[2].WebURL},
+			},
+			mockSourceProvider{
+				id:   "source2",
+				urls: []string{builds[1].WebURL, "https://example.com/unknown"},
+			},
+		}
+		c := NewCache(ciProviders, sourceProviders)
+
+		pipelines, err := c.Pipelines(context.Background(), "github.com/owner/repo", "sha")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Slice(pipelines, func(i, j int) bool {
+			return pipelines[i].WebURL < pipelines[j].WebURL
+		})
+		if diff := cmp.Diff(builds, pipelines); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+		if n := len(c.Builds()); n != len(builds) {
+			t.Fatalf("expected %d builds in cache but got %d", len(builds), n)
+		}
+	})
+
+	t.Run("repository unknown to all source providers", func(t *testing.T) {
+		sourceProviders := []SourceProvider{
+			mockSourceProvider{id: "source1", err: ErrRepositoryNotFound},
+			mockSourceProvider{id: "source2", err: ErrRepositoryNotFound},
+		}
+		c := NewCache(ciProviders, sourceProviders)
+
+		_, err := c.Pipelines(context.Background(), "github.com/owner/repo", "sha")
+		if err != ErrRepositoryNotFound {
+			t.Fatalf("expected %v but got %v", ErrRepositoryNotFound, err)
+		}
+	})
+
+	t.Run("repository unknown to a single source provider", func(t *testing.T) {
+		sourceProviders := []SourceProvider{
+			mockSourceProvider{id: "source1", err: ErrRepositoryNotFound},
+			mockSourceProvider{id: "source2", urls: []string{builds[0].WebURL}},
+		}
+		c := NewCache(ciProviders, sourceProviders)
+
+		pipelines, err := c.Pipelines(context.Background(), "github.com/owner/repo", "sha")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pipelines) != 1 {
+			t.Fatalf("expected 1 pipeline but got %d", len(pipelines))
+		}
+	})
+
+	t.Run("accounts restricted to other owners must not be used", func(t *testing.T) {
+		sourceProviders := []SourceProvider{
+			mockSourceProvider{id: "work", urls: []string{builds[0].WebURL}, owners: []string{"acme"}},
+			mockSourceProvider{id: "personal", urls: []string{builds[1].WebURL}, owners: []string{"owner"}},
+		}
+		c := NewCache(ciProviders, sourceProviders)
+
+		pipelines, err := c.Pipelines(context.Background(), "github.com/acme/repo", "sha")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(builds[:1], pipelines); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+
+		if _, err := c.Pipelines(context.Background(), "github.com/other/repo", "sha"); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+
+	t.Run("providers restricted to other repositories must not be used", func(t *testing.T) {
+		sourceProviders := []SourceProvider{
+			mockSourceProvider{id: "source1", urls: []string{builds[0].WebURL}},
+			mockSourceProvider{id: "source2", urls: []string{builds[1].WebURL}, excluded: []string{"owner/repo"}},
+		}
+		c := NewCache(ciProviders, sourceProviders)
+
+		pipelines, err := c.Pipelines(context.Background(), "github.com/owner/repo", "sha")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(builds[:1], pipelines); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestCache_SetJobFilter(t *testing.T) {
+	newBuild := func(id string, jobs []*Job, stages map[int]*Stage) Build {
+		return Build{
+			Repository: &Repository{
+				Provider: Provider{
+					ID: "provider1",
+				},
+			},
+			ID:     id,
+			WebURL: fmt.Sprintf("https://example.com/provider1/%s", id),
+			Jobs:   jobs,
+			Stages: stages,
+		}
+	}
+	builds := []Build{
+		newBuild("1", []*Job{{ID: "1", Name: "deploy-staging"}, {ID: "2", Name: "lint"}}, map[int]*Stage{
+			1: {ID: 1, Name: "tests", Jobs: []*Job{{ID: "3", Name: "unit"}, {ID: "4", Name: "integration"}}},
+			2: {ID: 2, Name: "docs", Jobs: []*Job{{ID: "5", Name: "pages"}}},
+		}),
+		newBuild("2", []*Job{{ID: "1", Name: "lint"}}, map[int]*Stage{}),
+		newBuild("3", nil, map[int]*Stage{}),
+	}
+	ciProviders := []CIProvider{
+		mockProvider{
+			id:     "provider1",
+			builds: builds,
+		},
+	}
+	sourceProviders := []SourceProvider{
+		mockSourceProvider{
+			id:   "source1",
+			urls: []string{builds[0].WebURL, builds[1].WebURL, builds[2].WebURL},
+		},
+	}
+	c := NewCache(ciProviders, sourceProviders)
+	c.SetJobFilter(func(stage string, job string) bool {
+		return job == "deploy-staging" || (stage == "tests" && job == "unit")
+	})
+
+	pipelines, err := c.Pipelines(context.Background(), "github.com/owner/repo", "sha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(pipelines, func(i, j int) bool {
+		return pipelines[i].ID < pipelines[j].ID
+	})
+	expected := []Build{
+		newBuild("1", []*Job{{ID: "1", Name: "deploy-staging"}}, map[int]*Stage{
+			1: {ID: 1, Name: "tests", Jobs: []*Job{{ID: "3", Name: "unit"}}},
+		}),
+		newBuild("3", []*Job{}, map[int]*Stage{}),
+	}
+	if diff := cmp.Diff(expected, pipelines); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if n := len(c.Builds()); n != len(expected) {
+		t.Fatalf("expected %d builds in cache but got %d", len(expected), n)
+	}
+	// The build returned by the provider must be left untouched
+	if n := len(builds[0].Stages[1].Jobs); n != 2 {
+		t.Fatalf("expected 2 jobs in the stage of the original build but got %d", n)
+	}
+}
+
+func TestCache_filter(t *testing.T) {
+	build := Build{
+		Repository: &Repository{Provider: Provider{ID: "provider1", Name: "provider1"}},
+		ID:         "1",
+		State:      Failed,
+		Jobs: []*Job{
+			{ID: "1", Name: "lint", State: Passed},
+			{ID: "2", Name: "canary", State: Failed},
+		},
+		Stages: map[int]*Stage{
+			1: {ID: 1, Name: "tests", State: Failed, Jobs: []*Job{
+				{ID: "3", Name: "unit", State: Failed},
+				{ID: "4", Name: "unit", State: Running},
+				{ID: "5", Name: "nightly-canary", State: Failed},
+			}},
+		},
+	}
+
+	t.Run("states must be computed without rejected jobs", func(t *testing.T) {
+		c := NewCache(nil, nil)
+		c.SetJobFilter(func(stage string, job string) bool {
+			return !strings.Contains(job, "canary")
+		})
+		filtered, kept := c.filter(build)
+		if !kept {
+			t.Fatal("expected build to be kept")
+		}
+		if filtered.State != Running {
+			t.Fatalf("expected state %q for the pipeline but got %q", Running, filtered.State)
+		}
+		if state := filtered.Stages[1].State; state != Running {
+			t.Fatalf("expected state %q for the stage but got %q", Running, state)
+		}
+		if build.State != Failed || build.Stages[1].State != Failed || len(build.Jobs) != 2 {
+			t.Fatal("original build must not be modified")
+		}
+	})
+
+	t.Run("state must not change if all jobs are kept", func(t *testing.T) {
+		c := NewCache(nil, nil)
+		c.SetJobFilter(func(stage string, job string) bool { return true })
+		filtered, kept := c.filter(build)
+		if !kept || filtered.State != Failed {
+			t.Fatalf("expected failed build to be kept but got %q (kept: %v)", filtered.State, kept)
+		}
+	})
+
+	t.Run("rejected pipelines", func(t *testing.T) {
+		c := NewCache(nil, nil)
+		c.SetPipelineFilter(func(b Build) bool { return b.ID != "1" })
+		if _, kept := c.filter(build); kept {
+			t.Fatal("expected build to be rejected")
+		}
+	})
 }
 
 func TestCache_WriteLog(t *testing.T) {
@@ -539,3 +825,871 @@ func TestCache_WriteLog(t *testing.T) {
 		}
 	})
 }
+
+func TestEngine(t *testing.T) {
+	build := Build{
+		Repository: &Repository{
+			Provider: Provider{
+				ID: "provider1",
+			},
+		},
+		ID:     "1",
+		State:  Passed,
+		WebURL: "https://example.com/provider1/1",
+	}
+	ciProviders := []CIProvider{
+		mockProvider{
+			id:     "provider1",
+			builds: []Build{build},
+		},
+	}
+	sourceProviders := []SourceProvider{
+		mockSourceProvider{
+			id:   "source1",
+			urls: []string{build.WebURL},
+		},
+	}
+
+	t.Run("subscribers must receive events", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		e := NewEngine(ciProviders, sourceProviders)
+		subscriptions := []<-chan Event{e.Subscribe(), e.Subscribe()}
+		if err := e.Start(ctx, "github.com/owner/repo", "sha"); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, events := range subscriptions {
+			select {
+			case event := <-events:
+				if event.Type != PipelineAdded {
+					t.Fatalf("expected event of type %q but got %q", PipelineAdded, event.Type)
+				}
+				if diff := cmp.Diff(build, event.Build); len(diff) > 0 {
+					t.Fatal(diff)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no event received")
+			}
+		}
+
+		if _, exists := e.Cache().fetchBuild("provider1", "1"); !exists {
+			t.Fatal("build not found in cache")
+		}
+
+		// Monitoring goes on until the backoff policy expires so stop it right away
+		cancel()
+		if err := e.Wait(); err != context.Canceled {
+			t.Fatalf("expected %v but got %v", context.Canceled, err)
+		}
+		for _, events := range subscriptions {
+			for range events {
+			}
+		}
+		select {
+		case _, ok := <-e.Subscribe():
+			if ok {
+				t.Fatal("expected closed channel")
+			}
+		default:
+			t.Fatal("subscription to a stopped engine must return a closed channel")
+		}
+	})
+
+	t.Run("engine can only be started once", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		e := NewEngine(ciProviders, sourceProviders)
+		if err := e.Start(ctx, "github.com/owner/repo", "sha"); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Start(ctx, "github.com/owner/repo", "sha"); err != ErrEngineStarted {
+			t.Fatalf("expected %v but got %v", ErrEngineStarted, err)
+		}
+	})
+
+	t.Run("engine must stop on error", func(t *testing.T) {
+		e := NewEngine(ciProviders, []SourceProvider{
+			mockSourceProvider{id: "source1", err: ErrRepositoryNotFound},
+		})
+		events := e.Subscribe()
+		if err := e.Start(context.Background(), "github.com/owner/repo", "sha"); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Wait(); err != ErrRepositoryNotFound {
+			t.Fatalf("expected %v but got %v", ErrRepositoryNotFound, err)
+		}
+		if _, ok := <-events; ok {
+			t.Fatal("expected closed channel")
+		}
+	})
+
+	t.Run("engine must not stop when credentials are rejected", func(t *testing.T) {
+		e := NewEngine(ciProviders, []SourceProvider{
+			mockSourceProvider{id: "source1", err: mockHTTPError(401)},
+		})
+		if err := e.Start(context.Background(), "github.com/owner/repo", "sha"); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		expected := []ProviderHealth{
+			{ID: "provider1"},
+			{ID: "source1", Status: ProviderAuthFailed, Err: mockHTTPError(401)},
+		}
+		if diff := cmp.Diff(expected, e.Cache().Health()); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
+
+type mockNotifier struct {
+	mockSourceProvider
+	notifications chan Notification
+}
+
+func (p mockNotifier) Notifications(ctx context.Context) (<-chan Notification, error) {
+	go func() {
+		<-ctx.Done()
+		close(p.notifications)
+	}()
+	return p.notifications, nil
+}
+
+func TestCache_GetPipelines(t *testing.T) {
+	build := Build{
+		Repository: &Repository{
+			Provider: Provider{
+				ID: "provider1",
+			},
+		},
+		ID:     "1",
+		State:  Passed,
+		WebURL: "https://example.com/provider1/1",
+	}
+	ciProviders := []CIProvider{
+		mockProvider{
+			id:     "provider1",
+			builds: []Build{build},
+		},
+	}
+	sourceProviders := []SourceProvider{
+		mockSourceProvider{
+			id:   "source1",
+			urls: []string{build.WebURL},
+		},
+	}
+	c := NewCache(ciProviders, sourceProviders)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan time.Time)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.GetPipelines(ctx, "github.com/owner/repo", utils.Commit{Sha: "sha"}, updates)
+	}()
+
+	select {
+	case <-updates:
+	case <-time.After(time.Second):
+		t.Fatal("no update received")
+	}
+	if _, exists := c.fetchBuild("provider1", "1"); !exists {
+		t.Fatal("build not found in cache")
+	}
+
+	// Monitoring goes on until the backoff policy expires so stop it right away
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("expected %v but got %v", context.Canceled, err)
+	}
+}
+
+func TestEngine_Notifier(t *testing.T) {
+	build := Build{
+		Repository: &Repository{
+			Provider: Provider{
+				ID: "provider1",
+			},
+		},
+		ID:     "1",
+		State:  Running,
+		WebURL: "https://example.com/provider1/1",
+	}
+	ciProviders := []CIProvider{
+		mockProvider{
+			id:     "provider1",
+			builds: []Build{build},
+		},
+	}
+	notifier := mockNotifier{
+		mockSourceProvider: mockSourceProvider{id: "webhook", err: ErrRepositoryNotFound},
+		notifications:      make(chan Notification),
+	}
+	// The repository is unknown to the source provider but its pipelines are notified
+	sourceProviders := []SourceProvider{
+		mockSourceProvider{id: "source1", err: ErrRepositoryNotFound},
+		notifier,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := NewEngine(ciProviders, sourceProviders)
+	events := e.Subscribe()
+	if err := e.Start(ctx, "github.com/owner/repo", "sha"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Notifications of other commits are ignored
+	notifier.notifications <- Notification{Sha: "other", URL: build.WebURL}
+	notifier.notifications <- Notification{Sha: "sha", URL: build.WebURL}
+
+	select {
+	case event := <-events:
+		if event.Type != PipelineAdded {
+			t.Fatalf("expected event of type %q but got %q", PipelineAdded, event.Type)
+		}
+		if diff := cmp.Diff(build, event.Build); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+
+	cancel()
+	if err := e.Wait(); err != context.Canceled {
+		t.Fatalf("expected %v but got %v", context.Canceled, err)
+	}
+}
+
+type mockHTTPError int
+
+func (err mockHTTPError) Error() string   { return fmt.Sprintf("status %d", int(err)) }
+func (err mockHTTPError) StatusCode() int { return int(err) }
+
+func TestCache_Health(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected ProviderStatus
+	}{
+		{nil, ProviderOK},
+		{mockHTTPError(401), ProviderAuthFailed},
+		{mockHTTPError(403), ProviderAuthFailed},
+		{mockHTTPError(429), ProviderRateLimited},
+		{mockHTTPError(503), ProviderUnreachable},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, ProviderUnreachable},
+		// The provider answered
+		{mockHTTPError(404), ProviderOK},
+		{ErrRepositoryNotFound, ProviderOK},
+	}
+	for _, testCase := range testCases {
+		t.Run(fmt.Sprintf("%v", testCase.err), func(t *testing.T) {
+			c := NewCache(nil, nil)
+			if status := c.recordRequest("provider1", nil, testCase.err); status != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, status)
+			}
+			health := c.Health()
+			if len(health) != 1 || health[0].Status != testCase.expected {
+				t.Fatalf("expected status %q but got %+v", testCase.expected, health)
+			}
+			if succeeded := !health[0].LastSuccess.IsZero(); succeeded != (testCase.expected == ProviderOK) {
+				t.Fatalf("unexpected time of last success: %v", health[0].LastSuccess)
+			}
+		})
+	}
+}
+
+type mockHistoryProvider struct {
+	builds []Build
+}
+
+func (p mockHistoryProvider) PreviousBuilds(ctx context.Context, build Build, limit int) ([]Build, error) {
+	return p.builds, nil
+}
+
+func TestCache_AverageDuration(t *testing.T) {
+	duration := func(d time.Duration) utils.NullDuration {
+		return utils.NullDuration{Duration: d, Valid: true}
+	}
+	previous := func(state State, d time.Duration, testState State, test time.Duration) Build {
+		return Build{
+			State:    state,
+			Duration: duration(d),
+			Jobs:     []*Job{{Name: "lint", State: Passed, Duration: duration(10 * time.Second)}},
+			Stages: map[int]*Stage{
+				1: {ID: 1, Name: "test", Jobs: []*Job{{Name: "unit", State: testState, Duration: duration(test)}}},
+			},
+		}
+	}
+
+	c := NewCache(nil, nil)
+	build := Build{
+		Repository: &Repository{Provider: Provider{ID: "gitlab-0"}},
+		ID:         "42",
+	}
+	h := mockHistoryProvider{
+		builds: []Build{
+			previous(Passed, 2*time.Minute, Passed, time.Minute),
+			previous(Passed, 4*time.Minute, Passed, 3*time.Minute),
+			// Failures are ignored
+			previous(Failed, time.Second, Failed, time.Second),
+		},
+	}
+	if err := c.fetchHistory(context.Background(), h, build); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		stage    string
+		job      string
+		average  time.Duration
+		expected bool
+	}{
+		{stage: "", job: "", average: 3 * time.Minute, expected: true},
+		{stage: "", job: "lint", average: 10 * time.Second, expected: true},
+		{stage: "test", job: "unit", average: 2 * time.Minute, expected: true},
+		{stage: "", job: "unit", expected: false},
+	}
+	for _, testCase := range testCases {
+		t.Run(DurationKey(testCase.stage, testCase.job), func(t *testing.T) {
+			average, exists := c.AverageDuration("gitlab-0", "42", testCase.stage, testCase.job)
+			if exists != testCase.expected || average != testCase.average {
+				t.Fatalf("expected (%v, %v) but got (%v, %v)", testCase.average, testCase.expected, average, exists)
+			}
+		})
+	}
+
+	if _, exists := c.AverageDuration("gitlab-0", "43", "", ""); exists {
+		t.Fatal("expected no average for a pipeline without history")
+	}
+}
+
+type mockDeploymentManager struct {
+	mockProvider
+	actions *[]string
+}
+
+func (p mockDeploymentManager) StopEnvironment(ctx context.Context, repository Repository, deployment Deployment) error {
+	*p.actions = append(*p.actions, "stop "+deployment.Environment)
+	return nil
+}
+
+func (p mockDeploymentManager) RetryDeployment(ctx context.Context, repository Repository, deployment Deployment) error {
+	*p.actions = append(*p.actions, "retry "+deployment.Environment)
+	return nil
+}
+
+func TestCache_StopEnvironment(t *testing.T) {
+	actions := make([]string, 0)
+	c := NewCache([]CIProvider{
+		mockDeploymentManager{mockProvider: mockProvider{id: "provider1"}, actions: &actions},
+		mockProvider{id: "provider2"},
+	}, nil)
+	for _, providerID := range []string{"provider1", "provider2"} {
+		build := Build{
+			Repository: &Repository{Provider: Provider{ID: providerID}},
+			ID:         "1",
+			Deployments: []*Deployment{
+				{ID: "1", Environment: "staging", CanStop: true, CanRetry: true},
+				{ID: "2", Environment: "production"},
+			},
+		}
+		if err := c.Save(build); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+
+	if err := c.StopEnvironment(ctx, "provider1", "1", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RetryDeployment(ctx, "provider1", "1", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"stop staging", "retry staging"}, actions); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	if err := c.StopEnvironment(ctx, "provider1", "1", "2"); err != ErrActionNotPermitted {
+		t.Fatalf("expected %v but got %v", ErrActionNotPermitted, err)
+	}
+	if err := c.RetryDeployment(ctx, "provider1", "1", "2"); err != ErrActionNotPermitted {
+		t.Fatalf("expected %v but got %v", ErrActionNotPermitted, err)
+	}
+	if err := c.StopEnvironment(ctx, "provider2", "1", "1"); err != ErrUnsupportedAction {
+		t.Fatalf("expected %v but got %v", ErrUnsupportedAction, err)
+	}
+	if err := c.StopEnvironment(ctx, "provider1", "1", "3"); err == nil {
+		t.Fatal("expected an error for an unknown deployment")
+	}
+}
+
+type mockPipelineCanceler struct {
+	mockProvider
+	actions *[]string
+}
+
+func (p mockPipelineCanceler) CancelBuild(ctx context.Context, build Build) error {
+	*p.actions = append(*p.actions, "cancel build "+build.ID)
+	return nil
+}
+
+func (p mockPipelineCanceler) CancelJob(ctx context.Context, build Build, job Job) error {
+	*p.actions = append(*p.actions, "cancel job "+job.ID)
+	return nil
+}
+
+func TestCache_CancelBuild(t *testing.T) {
+	actions := make([]string, 0)
+	c := NewCache([]CIProvider{
+		mockPipelineCanceler{mockProvider: mockProvider{id: "provider1"}, actions: &actions},
+		mockProvider{id: "provider2"},
+	}, nil)
+	for _, providerID := range []string{"provider1", "provider2"} {
+		builds := []Build{
+			{
+				Repository: &Repository{Provider: Provider{ID: providerID}},
+				ID:         "1",
+				State:      Running,
+				Jobs: []*Job{
+					{ID: "1", State: Running},
+					{ID: "2", State: Passed},
+				},
+			},
+			{
+				Repository: &Repository{Provider: Provider{ID: providerID}},
+				ID:         "2",
+				State:      Failed,
+			},
+		}
+		for _, build := range builds {
+			if err := c.Save(build); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	ctx := context.Background()
+
+	if err := c.CancelBuild(ctx, "provider1", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CancelJob(ctx, "provider1", "1", 0, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"cancel build 1", "cancel job 1"}, actions); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	if err := c.CancelBuild(ctx, "provider1", "2"); err != ErrActionNotPermitted {
+		t.Fatalf("expected %v but got %v", ErrActionNotPermitted, err)
+	}
+	if err := c.CancelJob(ctx, "provider1", "1", 0, "2"); err != ErrActionNotPermitted {
+		t.Fatalf("expected %v but got %v", ErrActionNotPermitted, err)
+	}
+	if err := c.CancelBuild(ctx, "provider2", "1"); err != ErrUnsupportedAction {
+		t.Fatalf("expected %v but got %v", ErrUnsupportedAction, err)
+	}
+	if err := c.CancelJob(ctx, "provider1", "1", 0, "3"); err == nil {
+		t.Fatal("expected an error for an unknown job")
+	}
+}
+
+type mockPipelineRestarter struct {
+	mockProvider
+	url string
+}
+
+func (p mockPipelineRestarter) RestartBuild(ctx context.Context, build Build) (string, error) {
+	return p.url, nil
+}
+
+func TestCache_RestartBuild(t *testing.T) {
+	restarted := Build{
+		Repository: &Repository{Provider: Provider{ID: "provider1"}},
+		ID:         "3",
+		State:      Pending,
+		WebURL:     "example.com/3",
+	}
+	c := NewCache([]CIProvider{
+		mockPipelineRestarter{
+			mockProvider: mockProvider{id: "provider1", builds: []Build{restarted}},
+			url:          restarted.WebURL,
+		},
+		mockProvider{id: "provider2"},
+	}, nil)
+	for _, providerID := range []string{"provider1", "provider2"} {
+		builds := []Build{
+			{
+				Repository: &Repository{Provider: Provider{ID: providerID}},
+				ID:         "1",
+				State:      Running,
+			},
+			{
+				Repository: &Repository{Provider: Provider{ID: providerID}},
+				ID:         "2",
+				State:      Failed,
+			},
+		}
+		for _, build := range builds {
+			if err := c.Save(build); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	ctx := context.Background()
+
+	u, err := c.RestartBuild(ctx, "provider1", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != restarted.WebURL {
+		t.Fatalf("expected %q but got %q", restarted.WebURL, u)
+	}
+	if _, exists := c.Build("provider1", "3"); !exists {
+		t.Fatal("the pipeline restarted must be saved to the cache")
+	}
+	select {
+	case tracked := <-c.tracked:
+		if tracked.url != restarted.WebURL {
+			t.Fatalf("expected %q but got %q", restarted.WebURL, tracked.url)
+		}
+	default:
+		t.Fatal("the pipeline restarted must be monitored")
+	}
+
+	if _, err := c.RestartBuild(ctx, "provider1", "1"); err != ErrActionNotPermitted {
+		t.Fatalf("expected %v but got %v", ErrActionNotPermitted, err)
+	}
+	if _, err := c.RestartBuild(ctx, "provider2", "2"); err != ErrUnsupportedAction {
+		t.Fatalf("expected %v but got %v", ErrUnsupportedAction, err)
+	}
+}
+
+type mockJobRetrier struct {
+	mockProvider
+}
+
+func (p mockJobRetrier) RetryJob(ctx context.Context, build Build, job Job) (string, error) {
+	return job.ID + "-retry", nil
+}
+
+func TestCache_RetryJob(t *testing.T) {
+	build := Build{
+		Repository: &Repository{Provider: Provider{ID: "provider1"}},
+		ID:         "1",
+		State:      Failed,
+		WebURL:     "example.com/1",
+		Jobs: []*Job{
+			{ID: "1", State: Failed},
+			{ID: "2", State: Running},
+		},
+	}
+	retried := build
+	retried.State = Running
+	retried.Jobs = []*Job{
+		{ID: "1-retry", State: Pending},
+		{ID: "2", State: Running},
+	}
+	c := NewCache([]CIProvider{
+		mockJobRetrier{mockProvider: mockProvider{id: "provider1", builds: []Build{retried}}},
+	}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	id, err := c.RetryJob(ctx, "provider1", "1", 0, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "1-retry" {
+		t.Fatalf("expected %q but got %q", "1-retry", id)
+	}
+	if _, exists := c.Job("provider1", "1", 0, "1-retry"); !exists {
+		t.Fatal("the pipeline of the job retried must be updated")
+	}
+	select {
+	case tracked := <-c.tracked:
+		if tracked.url != build.WebURL {
+			t.Fatalf("expected %q but got %q", build.WebURL, tracked.url)
+		}
+	default:
+		t.Fatal("the pipeline of the job retried must be monitored")
+	}
+
+	if _, err := c.RetryJob(ctx, "provider1", "1", 0, "2"); err != ErrActionNotPermitted {
+		t.Fatalf("expected %v but got %v", ErrActionNotPermitted, err)
+	}
+	if _, err := c.RetryJob(ctx, "provider1", "1", 0, "3"); err == nil {
+		t.Fatal("expected an error for an unknown job")
+	}
+}
+
+func TestCache_RefreshJob(t *testing.T) {
+	build := Build{
+		Repository: &Repository{Provider: Provider{ID: "provider1"}},
+		ID:         "1",
+		State:      Running,
+		WebURL:     "example.com/1",
+		Jobs: []*Job{
+			{ID: "1", State: Running},
+		},
+	}
+	finished := build
+	finished.State = Passed
+	finished.UpdatedAt = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	finished.Jobs = []*Job{
+		{ID: "1", State: Passed},
+	}
+	c := NewCache([]CIProvider{
+		mockProvider{id: "provider1", builds: []Build{finished}},
+	}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	job, err := c.RefreshJob(ctx, "provider1", "1", 0, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != Passed {
+		t.Fatalf("expected state %q but got %q", Passed, job.State)
+	}
+	if b, _ := c.Build("provider1", "1"); b.State != Passed {
+		t.Fatal("the pipeline of the job must be updated in the cache")
+	}
+
+	if _, err := c.RefreshJob(ctx, "provider1", "1", 0, "2"); err == nil {
+		t.Fatal("expected an error for an unknown job")
+	}
+}
+
+type mockManualJobStarter struct {
+	mockProvider
+	started *[]string
+}
+
+func (p mockManualJobStarter) StartManualJob(ctx context.Context, build Build, job Job) error {
+	*p.started = append(*p.started, job.ID)
+	return nil
+}
+
+func TestCache_StartManualJob(t *testing.T) {
+	started := make([]string, 0)
+	c := NewCache([]CIProvider{
+		mockManualJobStarter{mockProvider: mockProvider{id: "provider1"}, started: &started},
+	}, nil)
+	build := Build{
+		Repository: &Repository{Provider: Provider{ID: "provider1"}},
+		ID:         "1",
+		State:      Manual,
+		Jobs: []*Job{
+			{ID: "1", State: Manual},
+			{ID: "2", State: Passed},
+		},
+	}
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := c.StartManualJob(ctx, "provider1", "1", 0, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"1"}, started); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if err := c.StartManualJob(ctx, "provider1", "1", 0, "2"); err != ErrActionNotPermitted {
+		t.Fatalf("expected %v but got %v", ErrActionNotPermitted, err)
+	}
+}
+
+type mockPipelineTrigger struct {
+	mockProvider
+	err error
+}
+
+func (p mockPipelineTrigger) TriggerPipeline(ctx context.Context, repositoryURL string, ref string, variables []Variable) (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+	return fmt.Sprintf("example.com/%s/%s", p.id, ref), nil
+}
+
+func TestCache_TriggerPipelines(t *testing.T) {
+	ctx := context.Background()
+	repositoryURL := "github.com/owner/repo"
+
+	c := NewCache([]CIProvider{
+		mockPipelineTrigger{mockProvider: mockProvider{id: "provider1"}},
+		mockPipelineTrigger{mockProvider: mockProvider{id: "provider2"}, err: ErrUnknownURL},
+		mockProvider{id: "provider3"},
+	}, nil)
+	urls, err := c.TriggerPipelines(ctx, repositoryURL, "master", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"example.com/provider1/master"}, urls); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	c = NewCache([]CIProvider{mockProvider{id: "provider3"}}, nil)
+	if _, err := c.TriggerPipelines(ctx, repositoryURL, "master", nil); err != ErrNoPipelineStarted {
+		t.Fatalf("expected %v but got %v", ErrNoPipelineStarted, err)
+	}
+}
+
+type mockArtifactProvider struct {
+	mockProvider
+	files map[string]string
+}
+
+func (p mockArtifactProvider) Artifacts(ctx context.Context, build Build, job Job) ([]Artifact, error) {
+	artifacts := make([]Artifact, 0)
+	for path, content := range p.files {
+		artifacts = append(artifacts, Artifact{Path: job.ID + "/" + path, Size: int64(len(content))})
+	}
+	return artifacts, nil
+}
+
+func (p mockArtifactProvider) WriteArtifact(ctx context.Context, build Build, job Job, path string, w io.Writer) error {
+	_, err := w.Write([]byte(p.files[strings.TrimPrefix(path, job.ID+"/")]))
+	return err
+}
+
+func TestCache_Artifacts(t *testing.T) {
+	c := NewCache([]CIProvider{
+		mockArtifactProvider{mockProvider: mockProvider{id: "provider1"}, files: map[string]string{"report.txt": "ok\n"}},
+		mockProvider{id: "provider2"},
+	}, nil)
+	for _, id := range []string{"provider1", "provider2"} {
+		build := Build{
+			Repository: &Repository{Provider: Provider{ID: id}},
+			ID:         "1",
+			Jobs:       []*Job{{ID: "2", State: Passed}},
+		}
+		if err := c.Save(build); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+
+	artifacts, err := c.Artifacts(ctx, "provider1", "1", 0, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]Artifact{{Path: "2/report.txt", Size: 3}}, artifacts); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	buf := bytes.Buffer{}
+	if err := c.WriteArtifact(ctx, "provider1", "1", 0, "2", "2/report.txt", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "ok\n" {
+		t.Fatalf("unexpected content %q", buf.String())
+	}
+
+	if _, err := c.Artifacts(ctx, "provider2", "1", 0, "2"); err != ErrUnsupportedAction {
+		t.Fatalf("expected %v but got %v", ErrUnsupportedAction, err)
+	}
+	if _, err := c.Artifacts(ctx, "provider1", "1", 0, "3"); err == nil {
+		t.Fatal("expected error for unknown job")
+	}
+}
+
+func TestParseJUnit(t *testing.T) {
+	t.Run("testsuites", func(t *testing.T) {
+		report, err := ParseJUnit(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="tests" tests="4">
+    <testcase classname="tests.test_anim" name="test_frames">
+      <failure message="AssertionError: assert 3 == 4">Traceback...</failure>
+    </testcase>
+    <testcase classname="tests.test_anim" name="test_render"/>
+    <testcase classname="tests.test_term" name="test_record">
+      <error>OSError: no such device
+at record()</error>
+    </testcase>
+    <testcase classname="tests.test_term" name="test_replay"><skipped/></testcase>
+  </testsuite>
+</testsuites>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := TestReport{
+			Total:   4,
+			Failed:  2,
+			Skipped: 1,
+			Failures: []TestCase{
+				{Suite: "tests.test_anim", Name: "test_frames", Message: "AssertionError: assert 3 == 4"},
+				{Suite: "tests.test_term", Name: "test_record", Message: "OSError: no such device"},
+			},
+		}
+		if diff := cmp.Diff(expected, report); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("testsuite", func(t *testing.T) {
+		report, err := ParseJUnit(strings.NewReader(`<testsuite name="go"><testcase name="TestA"/><testsuite name="nested"><testcase name="TestB"><failure message="boom"/></testcase></testsuite></testsuite>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := TestReport{
+			Total:    2,
+			Failed:   1,
+			Failures: []TestCase{{Suite: "nested", Name: "TestB", Message: "boom"}},
+		}
+		if diff := cmp.Diff(expected, report); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("not a JUnit report", func(t *testing.T) {
+		if _, err := ParseJUnit(strings.NewReader(`<coverage line-rate="0.9"/>`)); err != ErrNoTestReport {
+			t.Fatalf("expected %v but got %v", ErrNoTestReport, err)
+		}
+	})
+}
+
+func TestCache_TestReport(t *testing.T) {
+	c := NewCache([]CIProvider{
+		mockArtifactProvider{mockProvider: mockProvider{id: "provider1"}, files: map[string]string{
+			"test-results/junit.xml": `<testsuite name="s"><testcase name="a"><failure message="m"/></testcase></testsuite>`,
+			"coverage.xml":           `<coverage/>`,
+			"test-results/notes.xml": `not xml`,
+		}},
+		mockArtifactProvider{mockProvider: mockProvider{id: "provider2"}, files: map[string]string{}},
+		mockProvider{id: "provider3"},
+	}, nil)
+	for _, id := range []string{"provider1", "provider2", "provider3"} {
+		build := Build{
+			Repository: &Repository{Provider: Provider{ID: id}},
+			ID:         "1",
+			Jobs:       []*Job{{ID: "2", State: Failed}},
+		}
+		if err := c.Save(build); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+
+	report, err := c.TestReport(ctx, "provider1", "1", 0, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := TestReport{Total: 1, Failed: 1, Failures: []TestCase{{Suite: "s", Name: "a", Message: "m"}}}
+	if diff := cmp.Diff(expected, report); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if _, err := c.TestReport(ctx, "provider2", "1", 0, "2"); err != ErrNoTestReport {
+		t.Fatalf("expected %v but got %v", ErrNoTestReport, err)
+	}
+	if _, err := c.TestReport(ctx, "provider3", "1", 0, "2"); err != ErrUnsupportedAction {
+		t.Fatalf("expected %v but got %v", ErrUnsupportedAction, err)
+	}
+}
@@ -0,0 +1,38 @@
+// Package cache aggregates the pipelines of a commit across CI providers.
+//
+// Packages cache and providers make up the public Go API of citop and can be imported by other
+// programs independently of the user interface. The API follows semantic versioning: exported
+// identifiers of both packages are only removed or changed in backward incompatible ways by a
+// new major version of citop. Other packages of the module are internal to citop and may change
+// at any time.
+//
+// Source providers (GitHub, GitLab) list the pipelines associated to a commit while CI
+// providers (Travis CI, AppVeyor, CircleCI, GitLab, Azure Pipelines) describe each pipeline.
+// Both are implemented in package providers. Cache ties them together:
+//
+//	ctx := context.Background()
+//	token := "github-token"
+//	github := providers.NewGitHubClient(ctx, "github", &token)
+//	travis := providers.NewTravisClient("travis", "travis", "travis-token", providers.TravisOrgURL, time.Second/20)
+//
+//	c := cache.NewCache([]cache.CIProvider{travis}, []cache.SourceProvider{github})
+//	builds, err := c.Pipelines(ctx, "github.com/nbedos/citop", sha)
+//	if err != nil {
+//		return err
+//	}
+//	for _, build := range builds {
+//		fmt.Println(build.Repository.Provider.Name, build.ID, build.State)
+//	}
+//
+// Engine keeps monitoring the pipelines and notifies subscribers of every update:
+//
+//	engine := cache.NewEngine([]cache.CIProvider{travis}, []cache.SourceProvider{github})
+//	events := engine.Subscribe()
+//	if err := engine.Start(ctx, "github.com/nbedos/citop", sha); err != nil {
+//		return err
+//	}
+//	for event := range events {
+//		fmt.Println(event.Type, event.Build.ID, event.Build.State)
+//	}
+//	return engine.Wait()
+package cache
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// EventType tells whether an event is about a new pipeline or a pipeline already known
+type EventType string
+
+const (
+	PipelineAdded   EventType = "added"
+	PipelineUpdated EventType = "updated"
+)
+
+// Event describes an update of a pipeline monitored by an Engine
+type Event struct {
+	Type EventType
+	Time time.Time
+	// State of the pipeline after the update
+	Build Build
+}
+
+// Size of the buffer of each subscription
+const subscriptionBufferSize = 16
+
+var ErrEngineStarted = errors.New("engine already started")
+
+// Engine monitors the pipelines of a commit across providers and notifies subscribers every
+// time a pipeline is updated. It allows other programs to embed the aggregation performed by
+// citop without running the user interface.
+type Engine struct {
+	cache       *Cache
+	mutex       *sync.Mutex
+	subscribers []chan Event
+	started     bool
+	stopped     bool
+	done        chan struct{}
+	err         error
+}
+
+func NewEngine(CIProviders []CIProvider, sourceProviders []SourceProvider) *Engine {
+	c := NewCache(CIProviders, sourceProviders)
+	return &Engine{
+		cache: &c,
+		mutex: &sync.Mutex{},
+		done:  make(chan struct{}),
+	}
+}
+
+// Cache returns the cache storing the pipelines monitored by the engine. It gives access to
+// the logs of jobs.
+func (e *Engine) Cache() *Cache {
+	return e.cache
+}
+
+// Subscribe returns a channel receiving an event for every update of a pipeline. The channel is
+// closed once the engine stops. Subscribe before calling Start to receive every event.
+//
+// The engine waits for each subscriber to receive an event before processing the next one so
+// subscribers must drain their channel promptly.
+func (e *Engine) Subscribe() <-chan Event {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	events := make(chan Event, subscriptionBufferSize)
+	if e.stopped {
+		close(events)
+	} else {
+		e.subscribers = append(e.subscribers, events)
+	}
+
+	return events
+}
+
+// Start monitors in the background the pipelines associated to commit 'sha' of the repository
+// at 'repositoryURL' until they stop changing or 'ctx' is canceled. An engine can only be
+// started once.
+func (e *Engine) Start(ctx context.Context, repositoryURL string, sha string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.started {
+		return ErrEngineStarted
+	}
+	e.started = true
+
+	updates := make(chan Event)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- e.cache.monitorPipelines(ctx, repositoryURL, sha, updates)
+	}()
+
+	go func() {
+		for {
+			select {
+			case event := <-updates:
+				e.publish(ctx, event)
+			case err := <-errc:
+				// monitorPipelines has no pending send to 'updates' once it has returned
+				e.stop(err)
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (e *Engine) publish(ctx context.Context, event Event) {
+	e.mutex.Lock()
+	subscribers := make([]chan Event, len(e.subscribers))
+	copy(subscribers, e.subscribers)
+	e.mutex.Unlock()
+
+	for _, s := range subscribers {
+		select {
+		case s <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *Engine) stop(err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.err = err
+	e.stopped = true
+	for _, s := range e.subscribers {
+		close(s)
+	}
+	e.subscribers = nil
+	close(e.done)
+}
+
+// Wait blocks until the engine stops and returns the error that caused it to stop, if any
+func (e *Engine) Wait() error {
+	<-e.done
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.err
+}
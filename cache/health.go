@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ProviderStatus tells whether the last request made to a provider succeeded and, if it did not,
+// why
+type ProviderStatus string
+
+const (
+	// No request was made to the provider yet
+	ProviderUnknown     ProviderStatus = ""
+	ProviderOK          ProviderStatus = "ok"
+	ProviderRateLimited ProviderStatus = "rate-limited"
+	ProviderAuthFailed  ProviderStatus = "auth failed"
+	ProviderUnreachable ProviderStatus = "unreachable"
+)
+
+// ProviderHealth describes the outcome of the requests made to a provider
+type ProviderHealth struct {
+	ID     string
+	Status ProviderStatus
+	// Time of the last successful request, zero if no request succeeded
+	LastSuccess time.Time
+	// Error returned by the last request, nil if it succeeded
+	Err error
+}
+
+// StatusCoder is implemented by errors caused by an HTTP response with an unexpected status code
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// ErrorStatusCoder is implemented by providers able to extract the HTTP status code from the
+// errors returned by the client library they rely on
+type ErrorStatusCoder interface {
+	ErrorStatusCode(err error) (int, bool)
+}
+
+// ErrorStatus returns the status of provider 'p' after it returned 'err'. The boolean is false
+// if the error is not caused by a connectivity problem, in which case the provider was reached.
+func ErrorStatus(p interface{}, err error) (ProviderStatus, bool) {
+	code, ok := 0, false
+	if e, isCoder := err.(StatusCoder); isCoder {
+		code, ok = e.StatusCode(), true
+	} else if coder, isCoder := p.(ErrorStatusCoder); isCoder {
+		code, ok = coder.ErrorStatusCode(err)
+	}
+	if ok {
+		switch {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return ProviderAuthFailed, true
+		case code == http.StatusTooManyRequests:
+			return ProviderRateLimited, true
+		case code >= 500:
+			return ProviderUnreachable, true
+		}
+		return ProviderOK, false
+	}
+
+	// Errors of the HTTP client such as DNS failures, refused connections and timeouts
+	if _, isNetError := err.(net.Error); isNetError {
+		return ProviderUnreachable, true
+	}
+	return ProviderOK, false
+}
+
+// Record the outcome of a request made to the provider identified by 'id'
+func (c *Cache) recordHealth(id string, status ProviderStatus, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	h := c.health[id]
+	h.ID, h.Status, h.Err = id, status, err
+	if status == ProviderOK {
+		h.LastSuccess = time.Now()
+	}
+	c.health[id] = h
+}
+
+// Record the outcome of a request made to provider 'p' that returned 'err' and return the
+// resulting status of the provider. Errors not caused by a connectivity problem leave the
+// provider in the state ProviderOK since it answered the request.
+func (c *Cache) recordRequest(id string, p interface{}, err error) ProviderStatus {
+	status := ProviderOK
+	if err != nil {
+		if s, connectivity := ErrorStatus(p, err); connectivity {
+			status = s
+		} else {
+			err = nil
+		}
+	}
+	c.recordHealth(id, status, err)
+	return status
+}
+
+// Health returns the outcome of the requests made to every provider of the cache, sorted by
+// identifier. Providers not queried yet have the status ProviderUnknown.
+func (c Cache) Health() []ProviderHealth {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	healthByID := make(map[string]ProviderHealth)
+	for id := range c.ciProvidersById {
+		healthByID[id] = ProviderHealth{ID: id}
+	}
+	for _, p := range c.sourceProviders {
+		healthByID[p.ID()] = ProviderHealth{ID: p.ID()}
+	}
+	for id, h := range c.health {
+		healthByID[id] = h
+	}
+
+	health := make([]ProviderHealth, 0, len(healthByID))
+	for _, h := range healthByID {
+		health = append(health, h)
+	}
+	sort.Slice(health, func(i, j int) bool {
+		return health[i].ID < health[j].ID
+	})
+
+	return health
+}
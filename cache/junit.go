@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Suites and test cases of a JUnit XML report. Nested suites are allowed.
+type junitSuites struct {
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name   string          `xml:"name,attr"`
+	Suites []junitSuite    `xml:"testsuite"`
+	Cases  []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	Error     *junitFailure `xml:"error"`
+	Skipped   *struct{}     `xml:"skipped"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Return the message of a failure, or the first line of its description if it has no message
+func (f junitFailure) message() string {
+	if f.Message != "" {
+		return f.Message
+	}
+	return strings.SplitN(strings.TrimSpace(f.Text), "\n", 2)[0]
+}
+
+func (s junitSuite) addTo(report *TestReport) {
+	for _, suite := range s.Suites {
+		suite.addTo(report)
+	}
+	for _, c := range s.Cases {
+		report.Total++
+		var failure *junitFailure
+		switch {
+		case c.Failure != nil:
+			failure = c.Failure
+		case c.Error != nil:
+			failure = c.Error
+		case c.Skipped != nil:
+			report.Skipped++
+			continue
+		default:
+			continue
+		}
+		report.Failed++
+		suite := s.Name
+		if c.Classname != "" {
+			suite = c.Classname
+		}
+		report.Failures = append(report.Failures, TestCase{
+			Suite:   suite,
+			Name:    c.Name,
+			Message: failure.message(),
+		})
+	}
+}
+
+// ParseJUnit reads a test report in the JUnit XML format. The root element of the report is
+// either <testsuites> or <testsuite>.
+func ParseJUnit(r io.Reader) (TestReport, error) {
+	var root struct {
+		XMLName xml.Name
+		junitSuite
+	}
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return TestReport{}, err
+	}
+
+	report := TestReport{}
+	switch root.XMLName.Local {
+	case "testsuites":
+		// A <testsuites> element only holds suites, its name is not relevant
+		for _, suite := range root.Suites {
+			suite.addTo(&report)
+		}
+	case "testsuite":
+		root.junitSuite.addTo(&report)
+	default:
+		return TestReport{}, ErrNoTestReport
+	}
+
+	return report, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/nbedos/citop/tui"
+	"github.com/nbedos/citop/utils"
+)
+
+type compareArguments struct {
+	repository string
+	noColor    bool
+	before     string
+	after      string
+}
+
+func parseCompareArguments(args []string, defaultRepository string) (compareArguments, error) {
+	a := compareArguments{}
+	f := flag.NewFlagSet("citop compare", flag.ContinueOnError)
+	f.SetOutput(bytes.NewBuffer(nil))
+	f.StringVar(&a.repository, "repository", defaultRepository, "")
+	f.StringVar(&a.repository, "r", defaultRepository, "")
+	f.BoolVar(&a.noColor, "no-color", false, "")
+	if err := f.Parse(args); err != nil {
+		return a, err
+	}
+
+	if f.NArg() != 2 {
+		return a, errors.New("exactly two commits must be specified")
+	}
+	a.before, a.after = f.Arg(0), f.Arg(1)
+
+	return a, nil
+}
+
+// Return true if 'f' is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Print the pipelines of two commits side by side and return the exit status of citop
+func runCompare(args []string) int {
+	defaultRepository, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	a, err := parseCompareArguments(args, defaultRepository)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		fmt.Fprintln(os.Stderr, usage())
+		return exitError
+	}
+
+	paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
+	config, err := loadConfiguration(paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	ctx := context.Background()
+	sourceProviders, ciProviders, err := config.Providers.Providers(ctx, http.DefaultTransport)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+
+	colors := !noColor(a.noColor) && isTerminal(os.Stdout)
+	err = tui.RunCompare(ctx, os.Stdout, a.repository, a.before, a.after, ciProviders, sourceProviders, colors)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCompareArguments(t *testing.T) {
+	testCases := []struct {
+		args      []string
+		arguments compareArguments
+	}{
+		{
+			args:      []string{"HEAD~1", "HEAD"},
+			arguments: compareArguments{repository: "repo", before: "HEAD~1", after: "HEAD"},
+		},
+		{
+			args:      []string{"-r", "github.com/nbedos/citop", "--no-color", "v0.1.0", "master"},
+			arguments: compareArguments{repository: "github.com/nbedos/citop", noColor: true, before: "v0.1.0", after: "master"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(strings.Join(testCase.args, " "), func(t *testing.T) {
+			a, err := parseCompareArguments(testCase.args, "repo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.arguments, a, cmp.AllowUnexported(compareArguments{})); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"HEAD"},
+		{"HEAD~2", "HEAD~1", "HEAD"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			if _, err := parseCompareArguments(args, "repo"); err == nil {
+				t.Fatal("expected error but got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,636 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nbedos/citop/tui"
+)
+
+// option describes a command, a positional argument or an option of the command line. The usage
+// message and the manual page are generated from these descriptions so that they always match
+// the flags actually accepted by citop.
+type option struct {
+	names    []string
+	argument string
+	// Inline code is delimited by backquotes
+	paragraphs   []string
+	exampleTitle string
+	exampleLang  string
+	example      string
+}
+
+var synopsis = []string{
+	"citop [-r REPOSITORY | --repository REPOSITORY] [--no-color] [--anonymous] [--jobs PATTERNS] [--record DIRECTORY | --replay DIRECTORY] [COMMIT]",
+	"citop [-r REPOSITORY | --repository REPOSITORY] (--accessible | --output ndjson [--follow] | --quiet) [--anonymous] [--jobs PATTERNS] [--fail-on STATES] [--ignore EXCEPTIONS] [--timeout DURATION] [--record DIRECTORY | --replay DIRECTORY] [COMMIT]",
+	"citop status [-r REPOSITORY | --repository REPOSITORY] [--format FORMAT] [--interval DURATION] [COMMIT]",
+	"citop compare [-r REPOSITORY | --repository REPOSITORY] [--no-color] COMMIT COMMIT",
+	"citop bisect [-r REPOSITORY | --repository REPOSITORY] [--job JOB] GOOD..BAD",
+	"citop grep [-r REPOSITORY | --repository REPOSITORY] [-i | --ignore-case] PATTERN [COMMIT]",
+	"citop hook pre-push [--fail-on STATES] [--ignore EXCEPTIONS] REMOTE URL",
+	"citop (trigger | run) [-r REPOSITORY | --repository REPOSITORY] [--provider PROVIDERS] [--var NAME=VALUE]... [REF]",
+	"citop login [PROVIDER...]",
+	"citop man | docs | doctor | update",
+	"citop -h | --help",
+	"citop --version",
+}
+
+var commands = []option{
+	{
+		names:      []string{"man"},
+		paragraphs: []string{"Print the manual page of citop in roff format."},
+		example:    "citop man | man -l -",
+	},
+	{
+		names:      []string{"docs"},
+		paragraphs: []string{"Print the manual page of citop in Markdown format."},
+	},
+	{
+		names: []string{"doctor"},
+		paragraphs: []string{
+			"Diagnose common problems preventing citop from working: detection of the git " +
+				"repository in the current directory, parsing of the URL of its remote, " +
+				"authentication with each provider of the configuration file, clock skew " +
+				"between the local machine and the providers, and capabilities of the terminal.",
+			"Each check is reported as PASS, WARN, FAIL or SKIP along with a hint on how to fix " +
+				"the problem. The exit status is 1 if at least one check failed.",
+		},
+		exampleTitle: "Example output:",
+		example: `PASS  git repository: /home/user/repos/citop (origin: git@github.com:nbedos/citop.git)
+PASS  remote URL: github.com/nbedos/citop
+PASS  configuration: configuration file loaded
+FAIL  provider github: GET https://api.github.com/user: 401 Bad credentials []
+      hint: check the token of this provider in the configuration file and make sure it has not expired, or run 'citop login' to enter a new token
+PASS  clock: clock differs from https://api.github.com by 0s
+PASS  terminal: TERM=xterm-256color, 256 colors, true colors: no, UTF-8 locale: true`,
+	},
+	{
+		names:    []string{"status"},
+		argument: "[--format FORMAT] [--interval DURATION]",
+		paragraphs: []string{
+			"Print a single line summarizing the state of the pipelines of the commit: the " +
+				"number of pipelines in each state preceded by the icon of the state (see key " +
+				"`icons` of table `[style]`). FORMAT is either `plain` (default), `tmux` which " +
+				"adds colors using the syntax of the status line of tmux, or `waybar` which " +
+				"prints the JSON object expected by the custom modules of Waybar. Options " +
+				"`--repository` and COMMIT are accepted.",
+			"With `--format waybar`, the CSS class of the module is the most significant " +
+				"state of the pipelines (`failed`, `canceled`, `running`, `pending`, `manual`, " +
+				"`passed` or `skipped`) or `none` if the commit has no pipeline. Set " +
+				"`\"return-type\": \"json\"` in the configuration of the module.",
+			"If `--interval` is specified, a new line is printed every DURATION (e.g. `30s`) " +
+				"until citop is killed, which suits bars reading the output of a continuously " +
+				"running command.",
+			"The summary is stored in `$XDG_CACHE_HOME/citop` and reused for 30 seconds, or " +
+				"indefinitely once all pipelines are complete, so that the command is cheap " +
+				"enough to be run by a status line or a shell prompt.",
+		},
+		exampleTitle: "Example:",
+		exampleLang:  "shell",
+		example: `# Show the state of the pipelines of the current repository in the status line of tmux
+set -g status-right '#(cd #{pane_current_path} && citop status --format tmux)'`,
+	},
+	{
+		names:    []string{"compare"},
+		argument: "COMMIT COMMIT",
+		paragraphs: []string{
+			"Print the pipelines and jobs of the first commit next to their counterparts on " +
+				"the second commit to find out what broke between both commits. Pipelines are " +
+				"matched by provider and order of creation, jobs by stage and name. Rows whose " +
+				"state differs are marked with `!` and the new state is colored unless " +
+				"`--no-color` is specified or the output is not a terminal. Option " +
+				"`--repository` is accepted.",
+		},
+		exampleTitle: "Example:",
+		exampleLang:  "shell",
+		example: `# Compare the pipelines of the last commit with those of its parent
+citop compare HEAD~1 HEAD`,
+	},
+	{
+		names:    []string{"bisect"},
+		argument: "[--job JOB] GOOD..BAD",
+		paragraphs: []string{
+			"Find the first commit of the range GOOD..BAD of the local repository on which " +
+				"the job JOB failed, or any pipeline if `--job` is not specified, by looking up " +
+				"the pipelines already recorded by the providers. No build is run. JOB is either " +
+				"the name of the job or the name of its stage and the name of the job separated " +
+				"by a slash (e.g. `test/unit`).",
+			"Commits are inspected by binary search along the first parent of merge commits. " +
+				"Commits on which the job did not run, is still running or was canceled are " +
+				"skipped, in which case the first failure may only be narrowed down to a few " +
+				"commits. The job must have failed on BAD. Option `--repository` is accepted " +
+				"but must designate a local repository.",
+		},
+		exampleTitle: "Example:",
+		exampleLang:  "shell",
+		example: `# Find the commit that broke the job "unit" of stage "test" since the last release
+citop bisect --job test/unit v0.1.0..master`,
+	},
+	{
+		names:    []string{"grep"},
+		argument: "[-i | --ignore-case] PATTERN [COMMIT]",
+		paragraphs: []string{
+			"Print the lines matching the regular expression PATTERN in the logs of all the " +
+				"jobs of the pipelines of COMMIT, or of the commit referenced by HEAD if COMMIT " +
+				"is missing. Each line is prefixed with the provider, the pipeline, the stage " +
+				"and the job it comes from separated by slashes, followed by its line number: " +
+				"`PROVIDER/PIPELINE/[STAGE/]JOB:LINE:TEXT`. Logs that cannot be fetched are " +
+				"reported on the standard error. Option `--repository` is accepted.",
+			"PATTERN uses the syntax of regular expressions of Go. `--ignore-case` makes the " +
+				"search case insensitive. The logs of all jobs are fetched concurrently.",
+		},
+		exampleTitle: "Example:",
+		exampleLang:  "shell",
+		example: `# List the jobs of the last commit that ran out of memory
+citop grep -i 'out of memory' | cut -d: -f1 | sort -u`,
+	},
+	{
+		names:    []string{"hook pre-push"},
+		argument: "REMOTE URL",
+		paragraphs: []string{
+			"Implement the pre-push hook of git. For each ref being pushed, check the pipelines " +
+				"of the commit the remote ref points to or, if the ref does not exist on the " +
+				"remote yet, the pipelines of the parent of the commit being pushed. The push is " +
+				"blocked and the failing jobs are listed if the final state of a job is one of " +
+				"the states listed by `--fail-on` (see options `--fail-on` and `--ignore`).",
+			"REMOTE and URL are the name and the URL of the remote passed by git to the hook.",
+		},
+		exampleTitle: "Example:",
+		exampleLang:  "shell",
+		example: `# Install the hook in the current repository
+printf '#!/bin/sh\nexec citop hook pre-push "$@"\n' > .git/hooks/pre-push
+chmod +x .git/hooks/pre-push`,
+	},
+	{
+		names:    []string{"trigger"},
+		argument: "[--provider PROVIDERS] [--var NAME=VALUE]... [REF]",
+		paragraphs: []string{
+			"Start a new pipeline on the branch or tag REF, or on the branch checked out if " +
+				"REF is not specified, with each provider able to do so that knows the " +
+				"repository, then monitor the pipelines of REF in the user interface. GitLab " +
+				"creates a pipeline, Travis CI receives a build request, CircleCI triggers a " +
+				"build of the branch, GitHub sends the event `workflow_dispatch` to the " +
+				"workflows of GitHub Actions accepting it on REF and Azure Pipelines queues a " +
+				"build of each definition building the Azure Repos repository. Other providers " +
+				"cannot start pipelines. `run` is another name of the command.",
+			"`--provider` restricts the command to a comma-separated list of provider " +
+				"identifiers (e.g. `gitlab-0`). `--var` passes an environment variable to the " +
+				"pipeline, or an input to the workflows of GitHub Actions, and may be repeated. " +
+				"Option `--repository` is accepted. The exit status is 1 if no pipeline was " +
+				"started.",
+			"Pressing `Ctrl-R` in the user interface also starts a new pipeline, without " +
+				"variables, on the branch checked out or on the only branch or tag pointing to " +
+				"the commit.",
+		},
+		exampleTitle: "Example:",
+		exampleLang:  "shell",
+		example: `# Run the pipeline of the branch checked out with GitLab and deploy it
+citop trigger --provider gitlab-0 --var DEPLOY=true`,
+	},
+	{
+		names:    []string{"login"},
+		argument: "[PROVIDER...]",
+		paragraphs: []string{
+			"Check that each provider of the configuration file accepts its token and ask " +
+				"for a new token for every provider rejecting it, such as a provider whose " +
+				"token expired. The token is read from the terminal without being echoed. " +
+				"Entering an empty token skips the provider. PROVIDER restricts the check to " +
+				"the providers with this identifier, as shown by the user interface (e.g. " +
+				"`gitlab-0` for the first GitLab account of the configuration file).",
+			"Tokens accepted by their provider are stored in `$XDG_STATE_HOME/citop/tokens.json` " +
+				"and take precedence over the tokens of the configuration file. The exit status " +
+				"is 1 if a provider still rejects its token.",
+			"While citop runs, a provider rejecting the token is no longer queried, its status " +
+				"is shown as `auth failed` below the table and the status bar suggests running " +
+				"this command.",
+		},
+	},
+	{
+		names: []string{"update"},
+		paragraphs: []string{
+			"Replace the executable of citop by the latest release published on GitHub if it " +
+				"is more recent than the version being run. The executable is only replaced if " +
+				"its SHA-256 checksum matches the one published with the release.",
+			"Self-update can be disabled in the configuration file, which is useful for " +
+				"packagers distributing citop through a package manager (see table `[update]`).",
+		},
+	},
+}
+
+var positionalArguments = []option{
+	{
+		names: []string{"COMMIT"},
+		paragraphs: []string{
+			"Specify the commit to monitor. COMMIT is expected to be the SHA identifier of a " +
+				"commit, or the name of a tag or a branch. If this option is missing citop will " +
+				"monitor the commit referenced by HEAD.",
+			"When monitoring HEAD of a local repository, citop follows HEAD: creating, " +
+				"amending or checking out a commit switches the user interface to the " +
+				"pipelines of the new commit. When COMMIT is the name of a branch, citop " +
+				"periodically checks whether the remote branch advanced and offers to switch " +
+				"to its new tip (see table `[follow]` of the configuration file).",
+		},
+		exampleTitle: "Example:",
+		exampleLang:  "shell",
+		example: `# Show pipelines for commit 64be3c6
+citop 64be3c6
+# Show pipelines for the commit referenced by the tag '0.9.0'
+citop 0.9.0
+# Show pipelines for the commit at the tip of a branch
+citop feature/doc
+# Show pipelines for the commit at the tip of a branch named like a command
+citop -- man`,
+	},
+}
+
+var options = []option{
+	{
+		names:    []string{"-r", "--repository"},
+		argument: "REPOSITORY",
+		paragraphs: []string{
+			"Specify the git repository to work with. REPOSITORY can be either a path to a " +
+				"local git repository, or the URL of an online repository hosted at GitHub or " +
+				"GitLab. Both web URLs and git URLs are accepted.",
+			"In the absence of this option, citop will work with the git repository located in " +
+				"the current directory. If there is no such repository, citop will fail.",
+		},
+		exampleTitle: "Examples:",
+		exampleLang:  "shell",
+		example: `# Work with the git repository in the current directory
+citop
+# Work with the repository specified by a web URL
+citop -r https://gitlab.com/nbedos/citop
+citop -r github.com/nbedos/citop
+# Git URLs are accepted
+citop -r git@github.com:nbedos/citop.git
+# Paths to a local repository are accepted too
+citop -r /home/user/repos/myrepo`,
+	},
+	{
+		names: []string{"--no-color"},
+		paragraphs: []string{
+			"Do not use colors in the user interface. Text attributes such as bold and reverse " +
+				"video are still used to distinguish between elements.",
+			"Colors are also disabled if the environment variable `NO_COLOR` is set to a " +
+				"non-empty value.",
+		},
+	},
+	{
+		names: []string{"--accessible"},
+		paragraphs: []string{
+			"Do not start the interactive user interface. Instead, write a plain text " +
+				"description of each pipeline, stage and job to the standard output, followed by " +
+				"a new line every time one of them changes state. The output contains no " +
+				"box-drawing characters, colors or reverse video which makes it suitable for " +
+				"terminal screen readers. Press Ctrl-C to exit.",
+		},
+		exampleTitle: "Example output:",
+		example: `gitlab pipeline #97604657: running
+gitlab pipeline #97604657, stage tests: running
+gitlab pipeline #97604657, stage tests, job go1.13: running
+gitlab pipeline #97604657, stage tests, job go1.13: running -> passed`,
+	},
+	{
+		names:    []string{"--output"},
+		argument: "FORMAT",
+		paragraphs: []string{
+			"Do not start the interactive user interface. Instead, write a description of " +
+				"each pipeline, stage and job to the standard output in the format FORMAT. The " +
+				"only format supported is `ndjson`: one JSON object per line with the keys " +
+				"`time`, `type` (`pipeline`, `stage` or `job`), `provider`, `pipeline`, " +
+				"`stage`, `job`, `name`, `state`, `previous_state`, `ref`, `tag`, `sha` and " +
+				"`url`.",
+		},
+		exampleTitle: "Example:",
+		exampleLang:  "shell",
+		example: `# List failed jobs
+citop --output ndjson | jq -r 'select(.type == "job" and .state == "failed") | .name'`,
+	},
+	{
+		names: []string{"--follow"},
+		paragraphs: []string{
+			"Keep monitoring pipelines after writing their current state and write a new " +
+				"object every time a pipeline, a stage or a job changes state. The key " +
+				"`previous_state` holds the state before the change. Requires `--output`.",
+		},
+	},
+	{
+		names: []string{"--quiet"},
+		paragraphs: []string{
+			"Do not start the interactive user interface. Instead, wait for the end of all " +
+				"pipelines without writing anything, then write a line for each job whose " +
+				"final state is one of the states listed by `--fail-on`. Nothing is written if " +
+				"all jobs succeed which makes this option suitable for git hooks and scripts.",
+		},
+		exampleTitle: "Example output:",
+		example:      `gitlab pipeline #97604657, stage tests, job go1.13: failed https://gitlab.com/nbedos/citop/-/jobs/350322218`,
+	},
+	{
+		names:    []string{"--jobs"},
+		argument: "PATTERNS",
+		paragraphs: []string{
+			"Only fetch and show the jobs matching one of the comma-separated PATTERNS. A " +
+				"pattern is a glob such as `deploy-*` or, if written between slashes, a regular " +
+				"expression such as `/^(unit|integration)-tests$/`. Patterns are matched " +
+				"against the name of the job and against `stage/job` for jobs belonging to a " +
+				"stage. Pipelines without any matching job are not shown. Takes precedence " +
+				"over the `jobs` setting of the `[filter]` section of the configuration file.",
+		},
+		exampleTitle: "Example:",
+		exampleLang:  "shell",
+		example: `# Only monitor the deployment jobs
+citop --jobs 'deploy-*,/^release$/'`,
+	},
+	{
+		names:    []string{"--fail-on"},
+		argument: "STATES",
+		paragraphs: []string{
+			"Specify the comma-separated list of states that cause citop to exit with status 2 " +
+				"when run with `--output`, `--accessible` or `--quiet`. The final state of each " +
+				"job is checked, as well as the state of pipelines without any job. Valid states " +
+				"are `pending`, `running`, `passed`, `failed`, `canceled`, `manual` and " +
+				"`skipped`. Defaults to `failed,canceled`.",
+		},
+		exampleTitle: "Example:",
+		exampleLang:  "shell",
+		example: `# Also fail if a job is still running or waiting for manual action
+citop --output ndjson --fail-on failed,canceled,running,manual`,
+	},
+	{
+		names:    []string{"--ignore"},
+		argument: "EXCEPTIONS",
+		paragraphs: []string{
+			"Specify the comma-separated list of jobs that never cause citop to exit with " +
+				"status 2. The only exception supported is `allow_failure` which designates " +
+				"jobs allowed to fail by the configuration of the pipeline.",
+		},
+	},
+	{
+		names:    []string{"--timeout"},
+		argument: "DURATION",
+		paragraphs: []string{
+			"Stop monitoring pipelines after DURATION and exit with status 3 if pipelines are " +
+				"still running. DURATION is a sequence of decimal numbers followed by a unit " +
+				"such as `90s`, `30m` or `1h30m`. Requires `--output`, `--accessible` or " +
+				"`--quiet`.",
+		},
+		exampleTitle: "Example:",
+		exampleLang:  "shell",
+		example: `# Wait for the end of pipelines for at most 30 minutes
+citop --output ndjson --follow --timeout 30m`,
+	},
+	{
+		names:    []string{"--record"},
+		argument: "DIRECTORY",
+		paragraphs: []string{
+			"Save every response received from providers in DIRECTORY, which is created if " +
+				"needed. Credentials passed in the URL of requests are redacted and request " +
+				"headers are not saved, but responses are saved as is. Files of a previous " +
+				"recording are overwritten.",
+		},
+	},
+	{
+		names:    []string{"--replay"},
+		argument: "DIRECTORY",
+		paragraphs: []string{
+			"Answer requests to providers with the responses saved in DIRECTORY by " +
+				"`--record` instead of sending them over the network. Successive requests for " +
+				"the same URL receive the responses in the order they were recorded so that " +
+				"pipelines progress as they did during the recording. The configuration file " +
+				"must define the same providers as during the recording.",
+		},
+		exampleTitle: "Example:",
+		exampleLang:  "shell",
+		example: `# Record the pipelines of a commit and replay them later without network access
+citop --record /tmp/citop-recording 4fc2a5e
+citop --replay /tmp/citop-recording 4fc2a5e`,
+	},
+	{
+		names: []string{"--anonymous"},
+		paragraphs: []string{
+			"Send unauthenticated requests to providers instead of using the credentials of the " +
+				"configuration file. Providers that cannot be used without credentials are " +
+				"skipped and citop lists the data that is unavailable as a result, such as " +
+				"private repositories or the logs of GitHub Actions, at the top of the screen. " +
+				"This is equivalent to setting `anonymous = true` in the `[providers]` table of " +
+				"the configuration file.",
+		},
+	},
+	{
+		names:      []string{"-h", "--help"},
+		paragraphs: []string{"Show usage of citop"},
+	},
+	{
+		names:      []string{"--version"},
+		paragraphs: []string{"Print the version of citop being run"},
+	},
+}
+
+// Return the names of the option joined by 'sep' and each followed by the argument of the option
+func (o option) title(sep string) string {
+	names := make([]string, 0, len(o.names))
+	for _, name := range o.names {
+		if o.argument != "" {
+			name += sep + o.argument
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Split 's' in lines no longer than 'width' characters unless a single word exceeds that length
+func wrap(s string, width int) []string {
+	lines := make([]string, 0)
+	line := ""
+	for _, word := range strings.Fields(s) {
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) > width:
+			lines = append(lines, line)
+			line = word
+		default:
+			line += " " + word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func usageSection(b *strings.Builder, title string, opts []option) {
+	const indent = 16
+	const width = 72
+
+	fmt.Fprintf(b, "\n%s:\n", title)
+	for i, o := range opts {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		name := "  " + o.title(" ")
+		for j, paragraph := range o.paragraphs {
+			if j > 0 {
+				b.WriteString("\n")
+			}
+			for k, line := range wrap(strings.Replace(paragraph, "`", "", -1), width-indent) {
+				switch {
+				case j > 0 || k > 0:
+					b.WriteString(strings.Repeat(" ", indent))
+				case len(name) < indent:
+					b.WriteString(name + strings.Repeat(" ", indent-len(name)))
+				default:
+					b.WriteString(name + "\n" + strings.Repeat(" ", indent))
+				}
+				b.WriteString(line + "\n")
+			}
+		}
+	}
+}
+
+// Return the message shown by 'citop --help'
+func usage() string {
+	b := strings.Builder{}
+	for i, line := range synopsis {
+		if i == 0 {
+			b.WriteString("usage: " + line + "\n")
+		} else {
+			b.WriteString("       " + line + "\n")
+		}
+	}
+	b.WriteString("\nMonitor CI pipelines associated to a specific commit of a git repository\n")
+	usageSection(&b, "Commands", commands)
+	usageSection(&b, "Positional arguments", positionalArguments)
+	usageSection(&b, "Options", options)
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func markdownSection(opts []option) string {
+	b := strings.Builder{}
+	for _, o := range opts {
+		fmt.Fprintf(&b, "## `%s`\n", o.title("="))
+		b.WriteString(strings.Join(o.paragraphs, "\n\n") + "\n\n")
+		if o.example != "" {
+			if o.exampleTitle != "" {
+				b.WriteString(o.exampleTitle + "\n")
+			}
+			fmt.Fprintf(&b, "```%s\n%s\n```\n\n", o.exampleLang, o.example)
+		}
+	}
+	return b.String()
+}
+
+func markdownKeyBindings(bindings []tui.KeyBinding) string {
+	width := len("Key")
+	for _, binding := range bindings {
+		if l := len(binding.KeysString()); l > width {
+			width = l
+		}
+	}
+	width += 2
+
+	b := strings.Builder{}
+	b.WriteString(strings.Repeat("-", 58) + "\n")
+	b.WriteString("Key" + strings.Repeat(" ", width-len("Key")) + "Action\n")
+	b.WriteString(strings.Repeat("-", width-2) + "  " + strings.Repeat("-", 58-width) + "\n")
+	for _, binding := range bindings {
+		keys := binding.KeysString()
+		b.WriteString(keys + strings.Repeat(" ", width-len(keys)) + binding.Description + "\n\n")
+	}
+	b.WriteString(strings.Repeat("-", 58) + "\n")
+
+	return b.String()
+}
+
+// Escape characters having a special meaning for roff
+func roffEscape(s string) string {
+	s = strings.NewReplacer(
+		`\`, `\[rs]`,
+		`"`, `\[dq]`,
+		`'`, `\[aq]`,
+		`@`, `\[at]`,
+	).Replace(s)
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Escape 's' and turn inline code delimited by backquotes into fixed-width text
+func roffText(s string) string {
+	parts := strings.Split(roffEscape(s), "`")
+	for i := 1; i < len(parts); i += 2 {
+		parts[i] = `\f[C]` + parts[i] + `\f[R]`
+	}
+	return strings.Join(parts, "")
+}
+
+func roffSection(opts []option) string {
+	b := strings.Builder{}
+	for _, o := range opts {
+		fmt.Fprintf(&b, ".SS \\f[C]%s\\f[R]\n", roffEscape(o.title("=")))
+		for _, paragraph := range o.paragraphs {
+			b.WriteString(".PP\n" + roffText(paragraph) + "\n")
+		}
+		if o.example != "" {
+			if o.exampleTitle != "" {
+				b.WriteString(".PP\n" + roffText(o.exampleTitle) + "\n")
+			}
+			fmt.Fprintf(&b, ".IP\n.nf\n\\f[C]\n%s\n\\f[R]\n.fi\n", roffEscape(o.example))
+		}
+	}
+	return b.String()
+}
+
+func roffKeyBindings(bindings []tui.KeyBinding) string {
+	b := strings.Builder{}
+	b.WriteString(".PP\n.TS\ntab(@);\nlw(10.7n) lw(46.7n).\n")
+	b.WriteString("T{\nKey\nT}@T{\nAction\nT}\n_\n")
+	for _, binding := range bindings {
+		fmt.Fprintf(&b, "T{\n%s\nT}@T{\n%s\nT}\n", roffEscape(binding.KeysString()), roffText(binding.Description))
+	}
+	b.WriteString(".TE\n")
+
+	return b.String()
+}
+
+// Return the manual page in roff format
+func manualPage() string {
+	synopsisLines := make([]string, 0, len(synopsis))
+	for _, line := range synopsis {
+		synopsisLines = append(synopsisLines, ".PP\n\\f[C]"+roffEscape(line)+"\\f[R]\n")
+	}
+
+	return strings.NewReplacer(
+		"<version>", Version,
+		".PP\n{{synopsis}}\n", strings.Join(synopsisLines, ""),
+		".PP\n{{commands}}\n", roffSection(commands),
+		".PP\n{{arguments}}\n", roffSection(positionalArguments),
+		".PP\n{{options}}\n", roffSection(options),
+		".PP\n{{key-bindings}}\n", roffKeyBindings(tui.KeyBindings),
+		".PP\n{{prompt-key-bindings}}\n", roffKeyBindings(tui.PromptKeyBindings),
+	).Replace(manRoffTemplate)
+}
+
+// Return the manual page in Markdown format
+func manualMarkdown() string {
+	synopsisLines := make([]string, 0, len(synopsis))
+	for _, line := range synopsis {
+		synopsisLines = append(synopsisLines, "`"+line+"`\n")
+	}
+
+	return strings.NewReplacer(
+		`\<version\>`, Version,
+		"{{synopsis}}\n", strings.Join(synopsisLines, "\n"),
+		"{{commands}}\n", markdownSection(commands),
+		"{{arguments}}\n", markdownSection(positionalArguments),
+		"{{options}}\n", markdownSection(options),
+		"{{key-bindings}}\n", markdownKeyBindings(tui.KeyBindings),
+		"{{prompt-key-bindings}}\n", markdownKeyBindings(tui.PromptKeyBindings),
+	).Replace(manMarkdownTemplate)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/tui"
+)
+
+func TestOptions(t *testing.T) {
+	documented := make(map[string]bool)
+	for _, o := range options {
+		for _, name := range o.names {
+			documented[name] = true
+		}
+	}
+
+	f := newFlagSet(&arguments{}, "")
+	f.VisitAll(func(fl *flag.Flag) {
+		name := "--" + fl.Name
+		if len(fl.Name) == 1 {
+			name = "-" + fl.Name
+		}
+		if !documented[name] {
+			t.Errorf("flag %q is missing from the documentation", name)
+		}
+		delete(documented, name)
+	})
+
+	for name := range documented {
+		t.Errorf("documented option %q is not a flag", name)
+	}
+}
+
+func TestParseArguments(t *testing.T) {
+	testCases := []struct {
+		args      []string
+		arguments arguments
+	}{
+		{
+			args:      nil,
+			arguments: arguments{repository: "repo", failOn: "failed,canceled", commit: "HEAD"},
+		},
+		{
+			args:      []string{"-r", "github.com/nbedos/citop", "--no-color", "0.9.0"},
+			arguments: arguments{repository: "github.com/nbedos/citop", noColor: true, failOn: "failed,canceled", commit: "0.9.0"},
+		},
+		{
+			args:      []string{"--", "man"},
+			arguments: arguments{repository: "repo", failOn: "failed,canceled", commit: "man"},
+		},
+		{
+			args:      []string{"--accessible", "--fail-on", "failed", "--ignore", "allow_failure"},
+			arguments: arguments{repository: "repo", accessible: true, failOn: "failed", ignore: "allow_failure", commit: "HEAD"},
+		},
+		{
+			args:      []string{"--output", "ndjson", "--follow"},
+			arguments: arguments{repository: "repo", output: "ndjson", follow: true, failOn: "failed,canceled", commit: "HEAD"},
+		},
+		{
+			args:      []string{"--output", "ndjson", "--follow", "--timeout", "30m"},
+			arguments: arguments{repository: "repo", output: "ndjson", follow: true, failOn: "failed,canceled", timeout: 30 * time.Minute, commit: "HEAD"},
+		},
+		{
+			args:      []string{"--quiet", "--timeout", "1h"},
+			arguments: arguments{repository: "repo", quiet: true, failOn: "failed,canceled", timeout: time.Hour, commit: "HEAD"},
+		},
+		{
+			args:      []string{"--jobs", "deploy-*,/^tests$/"},
+			arguments: arguments{repository: "repo", failOn: "failed,canceled", jobs: "deploy-*,/^tests$/", commit: "HEAD"},
+		},
+		{
+			args:      []string{"--replay", "recording"},
+			arguments: arguments{repository: "repo", failOn: "failed,canceled", replay: "recording", commit: "HEAD"},
+		},
+		{
+			args:      []string{"--anonymous", "master"},
+			arguments: arguments{repository: "repo", failOn: "failed,canceled", anonymous: true, commit: "master"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(strings.Join(testCase.args, " "), func(t *testing.T) {
+			a, err := parseArguments(testCase.args, "repo", "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.arguments, a, cmp.AllowUnexported(arguments{})); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	for _, args := range [][]string{
+		{"HEAD", "HEAD~1"},
+		{"--output", "xml"},
+		{"--output", "ndjson", "--accessible"},
+		{"--follow"},
+		{"--fail-on", "broken"},
+		{"--ignore", "manual"},
+		{"--jobs", "/(/"},
+		{"--jobs", "deploy-["},
+		{"--quiet", "--accessible"},
+		{"--quiet", "--output", "ndjson"},
+		{"--timeout", "30m"},
+		{"--accessible", "--timeout", "-1m"},
+		{"--record", "recording", "--replay", "recording"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			if _, err := parseArguments(args, "repo", "HEAD"); err == nil {
+				t.Fatal("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	lines := wrap("a bb ccc dddd", 6)
+	expected := []string{"a bb", "ccc", "dddd"}
+	if diff := cmp.Diff(expected, lines); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestManualPage(t *testing.T) {
+	pages := map[string]string{
+		"roff":     manualPage(),
+		"markdown": manualMarkdown(),
+	}
+
+	for format, page := range pages {
+		t.Run(format, func(t *testing.T) {
+			for _, placeholder := range []string{"{{synopsis}}", "{{commands}}", "{{arguments}}", "{{options}}", "{{key-bindings}}", "{{prompt-key-bindings}}", "<version>"} {
+				if strings.Contains(page, placeholder) {
+					t.Fatalf("placeholder %q was not replaced", placeholder)
+				}
+			}
+
+			for _, binding := range append(tui.KeyBindings, tui.PromptKeyBindings...) {
+				if !strings.Contains(page, binding.Description) {
+					t.Fatalf("key binding %q is missing from the manual page", binding.Description)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/gdamore/tcell/terminfo"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/providers"
+	"github.com/nbedos/citop/utils"
+)
+
+type checkStatus string
+
+const (
+	checkPass checkStatus = "PASS"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+	checkSkip checkStatus = "SKIP"
+)
+
+type checkResult struct {
+	name    string
+	status  checkStatus
+	details string
+	// Remediation shown if the check does not pass
+	hint string
+}
+
+func (r checkResult) write(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%s  %s: %s\n", r.status, r.name, r.details); err != nil {
+		return err
+	}
+	if r.hint != "" && r.status != checkPass {
+		if _, err := fmt.Fprintf(w, "      hint: %s\n", r.hint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Maximum difference between the local clock and the clock of CI providers before durations
+// and dates shown by citop become misleading
+const maxClockSkew = time.Minute
+
+// Timeout of each check involving network requests
+const checkTimeout = 10 * time.Second
+
+// runDoctor diagnoses common problems preventing citop from working and writes a report to 'w'.
+// It returns false if at least one check failed.
+func runDoctor(ctx context.Context, w io.Writer, repo string, confPaths []string) bool {
+	results := make([]checkResult, 0)
+	results = append(results, checkRepository(repo)...)
+
+	config, err := loadConfiguration(confPaths...)
+	if err != nil {
+		hint := "check the syntax of the configuration file"
+		if err == ErrMissingConf && len(confPaths) > 0 {
+			hint = fmt.Sprintf("create a configuration file at %q (see 'citop man')", confPaths[0])
+		}
+		results = append(results, checkResult{
+			name:    "configuration",
+			status:  checkFail,
+			details: err.Error(),
+			hint:    hint,
+		})
+	} else {
+		results = append(results, checkResult{
+			name:    "configuration",
+			status:  checkPass,
+			details: "configuration file loaded",
+		})
+		results = append(results, checkProviders(ctx, config.Providers)...)
+		results = append(results, checkClock(ctx, http.DefaultClient, referenceURLs(config.Providers)))
+	}
+
+	results = append(results, checkTerminal(os.Getenv("TERM")))
+
+	success := true
+	for _, result := range results {
+		if err := result.write(w); err != nil {
+			return false
+		}
+		success = success && result.status != checkFail
+	}
+
+	return success
+}
+
+func checkRepository(repo string) []checkResult {
+	result := checkResult{name: "git repository"}
+	origin, _, err := utils.GitOriginURL(repo, "HEAD")
+	if err != nil {
+		result.status = checkFail
+		result.details = err.Error()
+		result.hint = "run 'citop doctor' from a git repository having a remote named 'origin'"
+		return []checkResult{result, {
+			name:    "remote URL",
+			status:  checkSkip,
+			details: "no remote to parse",
+		}}
+	}
+	result.status = checkPass
+	result.details = fmt.Sprintf("%s (origin: %s)", repo, origin)
+
+	remote := checkResult{name: "remote URL"}
+	host, owner, name, err := utils.RepoHostOwnerAndName(origin)
+	if err != nil {
+		remote.status = checkFail
+		remote.details = err.Error()
+		remote.hint = "citop expects a web URL or a git URL such as " +
+			"'git@github.com:owner/repository.git'"
+	} else {
+		remote.status = checkPass
+		remote.details = path.Join(host, owner, name)
+	}
+
+	return []checkResult{result, remote}
+}
+
+func checkProviders(ctx context.Context, c ProvidersConfiguration) []checkResult {
+	sourceProviders, ciProviders, err := c.Providers(ctx, http.DefaultTransport)
+	if err != nil {
+		return []checkResult{{
+			name:    "providers",
+			status:  checkFail,
+			details: err.Error(),
+			hint:    "check the [providers] table of the configuration file",
+		}}
+	}
+
+	if len(sourceProviders) == 0 || len(ciProviders) == 0 {
+		return []checkResult{{
+			name:    "providers",
+			status:  checkFail,
+			details: "at least one source provider and one CI provider are required",
+			hint:    "add credentials for GitHub or GitLab and for your CI provider to the configuration file",
+		}}
+	}
+
+	ps := make([]interface{ ID() string }, 0)
+	seen := make(map[string]bool)
+	for _, p := range sourceProviders {
+		ps = append(ps, p)
+		seen[p.ID()] = true
+	}
+	for _, p := range ciProviders {
+		if !seen[p.ID()] {
+			ps = append(ps, p)
+		}
+	}
+
+	results := make([]checkResult, 0, len(ps))
+	for _, p := range ps {
+		result := checkResult{name: fmt.Sprintf("provider %s", p.ID())}
+		if c.Anonymous {
+			result.status = checkSkip
+			result.details = "anonymous mode, credentials are not used"
+			results = append(results, result)
+			continue
+		}
+		checker, ok := p.(cache.AuthenticationChecker)
+		if !ok {
+			result.status = checkSkip
+			result.details = "authentication cannot be checked for this provider"
+			results = append(results, result)
+			continue
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := checker.CheckAuthentication(checkCtx)
+		cancel()
+		if err != nil {
+			result.status = checkFail
+			result.details = err.Error()
+			result.hint = "check the token of this provider in the configuration file and make " +
+				"sure it has not expired, or run 'citop login' to enter a new token"
+		} else {
+			result.status = checkPass
+			result.details = "authenticated"
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// Return the URLs of the services configured by the user
+func referenceURLs(c ProvidersConfiguration) []string {
+	urls := make([]string, 0)
+	for _, conf := range c.GitLab {
+		u := "https://gitlab.com"
+		if conf.Url != "" {
+			u = conf.Url
+		}
+		urls = append(urls, u)
+	}
+	if len(c.GitHub) > 0 {
+		urls = append(urls, "https://api.github.com")
+	}
+	if len(c.CircleCI) > 0 {
+		urls = append(urls, "https://"+providers.CircleCIURL.Host)
+	}
+	if len(c.AppVeyor) > 0 {
+		urls = append(urls, "https://ci.appveyor.com")
+	}
+	for _, conf := range c.Travis {
+		switch strings.ToLower(conf.Url) {
+		case "org":
+			urls = append(urls, providers.TravisOrgURL.String())
+		case "com":
+			urls = append(urls, providers.TravisComURL.String())
+		default:
+			urls = append(urls, conf.Url)
+		}
+	}
+	if len(c.Azure) > 0 {
+		urls = append(urls, "https://dev.azure.com")
+	}
+
+	return urls
+}
+
+// Return the difference between the local clock and the clock of the server at 'u' based on
+// the Date header of the response
+func clockSkew(ctx context.Context, client *http.Client, u string) (time.Duration, error) {
+	req, err := http.NewRequest("HEAD", u, nil)
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	end := time.Now()
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid Date header in response from %s: %v", u, err)
+	}
+
+	// Assume the server set the header halfway through the request
+	return start.Add(end.Sub(start) / 2).Sub(date), nil
+}
+
+func checkClock(ctx context.Context, client *http.Client, urls []string) checkResult {
+	result := checkResult{
+		name:   "clock",
+		status: checkSkip,
+	}
+
+	for _, u := range urls {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		skew, err := clockSkew(checkCtx, client, u)
+		cancel()
+		if err != nil {
+			result.details = err.Error()
+			continue
+		}
+
+		if skew < 0 {
+			skew = -skew
+		}
+		result.details = fmt.Sprintf("clock differs from %s by %s", u, skew.Truncate(time.Second))
+		if skew > maxClockSkew {
+			result.status = checkFail
+			result.hint = "synchronize the system clock, for example by enabling NTP"
+		} else {
+			result.status = checkPass
+		}
+		return result
+	}
+
+	if result.details == "" {
+		result.details = "no provider to compare the local clock against"
+	}
+	return result
+}
+
+func checkTerminal(term string) checkResult {
+	result := checkResult{
+		name: "terminal",
+		hint: "set the environment variable TERM to a value known to the terminfo database such " +
+			"as 'xterm-256color'",
+	}
+
+	if term == "" {
+		result.status = checkFail
+		result.details = "TERM is not set"
+		return result
+	}
+
+	// Creating the screen loads the terminfo entry of the terminal without initializing it
+	if _, err := tcell.NewTerminfoScreen(); err != nil {
+		result.status = checkFail
+		result.details = fmt.Sprintf("TERM=%s: %s", term, err.Error())
+		return result
+	}
+	ti, err := terminfo.LookupTerminfo(term)
+	if err != nil {
+		result.status = checkFail
+		result.details = fmt.Sprintf("TERM=%s: %s", term, err.Error())
+		return result
+	}
+
+	trueColor := "no"
+	if ti.SetFgBgRGB != "" || ti.SetFgRGB != "" {
+		trueColor = "yes"
+	}
+	result.details = fmt.Sprintf("TERM=%s, %d colors, true colors: %s, UTF-8 locale: %v", term,
+		ti.Colors, trueColor, utf8Locale())
+
+	switch {
+	case ti.Colors < 8:
+		result.status = checkWarn
+		result.hint = "use a terminal supporting colors or run citop with --no-color"
+	case !utf8Locale():
+		result.status = checkWarn
+		result.hint = "set LANG to a UTF-8 locale such as 'en_US.UTF-8' to display box-drawing " +
+			"characters and icons"
+	default:
+		result.status = checkPass
+	}
+
+	return result
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckResult_write(t *testing.T) {
+	testCases := []struct {
+		name     string
+		result   checkResult
+		expected string
+	}{
+		{
+			name: "passing check hides hint",
+			result: checkResult{
+				name:    "clock",
+				status:  checkPass,
+				details: "ok",
+				hint:    "synchronize the clock",
+			},
+			expected: "PASS  clock: ok\n",
+		},
+		{
+			name: "failing check shows hint",
+			result: checkResult{
+				name:    "clock",
+				status:  checkFail,
+				details: "skew",
+				hint:    "synchronize the clock",
+			},
+			expected: "FAIL  clock: skew\n      hint: synchronize the clock\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			b := strings.Builder{}
+			if err := testCase.result.write(&b); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, b.String())
+			}
+		})
+	}
+}
+
+func TestCheckClock(t *testing.T) {
+	testCases := []struct {
+		name   string
+		offset time.Duration
+		status checkStatus
+	}{
+		{
+			name:   "synchronized clock",
+			offset: 0,
+			status: checkPass,
+		},
+		{
+			name:   "clock ahead",
+			offset: -time.Hour,
+			status: checkFail,
+		},
+		{
+			name:   "clock behind",
+			offset: 5 * time.Minute,
+			status: checkFail,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Date", time.Now().Add(testCase.offset).UTC().Format(http.TimeFormat))
+			}))
+			defer ts.Close()
+
+			result := checkClock(context.Background(), ts.Client(), []string{ts.URL})
+			if result.status != testCase.status {
+				t.Fatalf("expected status %s but got %s (%s)", testCase.status, result.status, result.details)
+			}
+		})
+	}
+
+	t.Run("no reachable server", func(t *testing.T) {
+		result := checkClock(context.Background(), http.DefaultClient, nil)
+		if result.status != checkSkip {
+			t.Fatalf("expected status %s but got %s", checkSkip, result.status)
+		}
+	})
+}
+
+func TestCheckTerminal(t *testing.T) {
+	t.Run("unset TERM", func(t *testing.T) {
+		if result := checkTerminal(""); result.status != checkFail {
+			t.Fatalf("expected status %s but got %s", checkFail, result.status)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+
+	"github.com/nbedos/citop/tui"
+	"github.com/nbedos/citop/utils"
+)
+
+type grepArguments struct {
+	repository string
+	ignoreCase bool
+	pattern    string
+	commit     string
+}
+
+func parseGrepArguments(args []string, defaultRepository string, defaultCommit string) (grepArguments, error) {
+	a := grepArguments{commit: defaultCommit}
+	f := flag.NewFlagSet("citop grep", flag.ContinueOnError)
+	f.SetOutput(bytes.NewBuffer(nil))
+	f.StringVar(&a.repository, "repository", defaultRepository, "")
+	f.StringVar(&a.repository, "r", defaultRepository, "")
+	f.BoolVar(&a.ignoreCase, "ignore-case", false, "")
+	f.BoolVar(&a.ignoreCase, "i", false, "")
+	if err := f.Parse(args); err != nil {
+		return a, err
+	}
+
+	switch f.NArg() {
+	case 2:
+		a.commit = f.Arg(1)
+		fallthrough
+	case 1:
+		a.pattern = f.Arg(0)
+	default:
+		return a, errors.New("a pattern and at most one commit must be specified")
+	}
+	if a.pattern == "" {
+		return a, errors.New("the pattern must not be empty")
+	}
+
+	return a, nil
+}
+
+// Return the regular expression searched in logs
+func (a grepArguments) regexp() (*regexp.Regexp, error) {
+	pattern := a.pattern
+	if a.ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// Print the lines of the logs of the jobs of a commit matching a pattern and return the exit
+// status of citop
+func runGrep(args []string) int {
+	defaultRepository, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	a, err := parseGrepArguments(args, defaultRepository, "HEAD")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		fmt.Fprintln(os.Stderr, usage())
+		return exitError
+	}
+	pattern, err := a.regexp()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+
+	paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
+	config, err := loadConfiguration(paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	ctx := context.Background()
+	sourceProviders, ciProviders, err := config.Providers.Providers(ctx, http.DefaultTransport)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+
+	matches, err := tui.RunGrep(ctx, os.Stdout, os.Stderr, a.repository, a.commit, pattern, ciProviders, sourceProviders)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	if matches == 0 {
+		return exitNoMatch
+	}
+	return 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseGrepArguments(t *testing.T) {
+	testCases := []struct {
+		args      []string
+		arguments grepArguments
+	}{
+		{
+			args:      []string{"panic:"},
+			arguments: grepArguments{repository: "repo", pattern: "panic:", commit: "HEAD"},
+		},
+		{
+			args:      []string{"-r", "github.com/nbedos/citop", "-i", "out of memory", "v0.1.0"},
+			arguments: grepArguments{repository: "github.com/nbedos/citop", ignoreCase: true, pattern: "out of memory", commit: "v0.1.0"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(strings.Join(testCase.args, " "), func(t *testing.T) {
+			a, err := parseGrepArguments(testCase.args, "repo", "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.arguments, a, cmp.AllowUnexported(grepArguments{})); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{""},
+		{"panic:", "HEAD~1", "HEAD"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			if _, err := parseGrepArguments(args, "repo", "HEAD"); err == nil {
+				t.Fatal("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestGrepArguments_Regexp(t *testing.T) {
+	a := grepArguments{pattern: "Out of memory", ignoreCase: true}
+	r, err := a.regexp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.MatchString("fatal error: out of memory") {
+		t.Fatalf("expected %q to match case insensitively", r.String())
+	}
+}
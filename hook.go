@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/tui"
+	"github.com/nbedos/citop/utils"
+)
+
+// Object name used by git for the remote side of a ref that does not exist yet and for the
+// local side of a ref being deleted
+const zeroSha = "0000000000000000000000000000000000000000"
+
+// ErrPushBlocked is returned by the pre-push hook if pipelines of the commit being pushed onto
+// did not succeed
+var ErrPushBlocked = errors.New("push blocked by citop: pipelines of the upstream commit did " +
+	"not succeed (use 'git push --no-verify' to push anyway)")
+
+// pushedRef is a line of the standard input of the pre-push hook of git
+type pushedRef struct {
+	localRef  string
+	localSha  string
+	remoteRef string
+	remoteSha string
+}
+
+// Parse the standard input of the pre-push hook. Each line has the form
+// "<local ref> <local sha> <remote ref> <remote sha>".
+func parsePushedRefs(r io.Reader) ([]pushedRef, error) {
+	refs := make([]pushedRef, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid pre-push hook input: %q", scanner.Text())
+		}
+		refs = append(refs, pushedRef{
+			localRef:  fields[0],
+			localSha:  fields[1],
+			remoteRef: fields[2],
+			remoteSha: fields[3],
+		})
+	}
+	return refs, scanner.Err()
+}
+
+// Return the revision whose pipelines must be checked before pushing the ref: the commit the
+// remote ref currently points to or, for a ref that does not exist on the remote yet, the parent
+// of the commit being pushed. Deleting a ref requires no check.
+func (r pushedRef) upstream() (string, bool) {
+	switch {
+	case r.localSha == zeroSha:
+		return "", false
+	case r.remoteSha == zeroSha:
+		return r.localSha + "~1", true
+	default:
+		return r.remoteSha, true
+	}
+}
+
+type hookArguments struct {
+	failOn    string
+	ignore    string
+	remote    string
+	remoteURL string
+}
+
+func parseHookArguments(args []string) (hookArguments, error) {
+	a := hookArguments{}
+	f := flag.NewFlagSet("citop hook pre-push", flag.ContinueOnError)
+	f.SetOutput(bytes.NewBuffer(nil))
+	f.StringVar(&a.failOn, "fail-on", "failed,canceled", "")
+	f.StringVar(&a.ignore, "ignore", "", "")
+	if err := f.Parse(args); err != nil {
+		return a, err
+	}
+	if f.NArg() != 2 {
+		return a, errors.New("expected the name and the URL of the remote as arguments")
+	}
+	a.remote, a.remoteURL = f.Arg(0), f.Arg(1)
+
+	return a, nil
+}
+
+// Check the pipelines of the commits that the refs read from 'stdin' are pushed onto. Failures
+// are reported to 'w' and ErrPushBlocked is returned if the state of a pipeline violates
+// 'policy'. Commits unknown to the providers, like those of a repository without any pipeline,
+// do not block the push.
+func runPrePushHook(ctx context.Context, w io.Writer, stdin io.Reader, repo string, remoteURL string, CIProviders []cache.CIProvider, SourceProviders []cache.SourceProvider, policy tui.FailurePolicy) error {
+	refs, err := parsePushedRefs(stdin)
+	if err != nil {
+		return err
+	}
+
+	// Pushing to a local repository or to an unknown host requires no check
+	if _, _, _, err := utils.RepoHostOwnerAndName(remoteURL); err != nil {
+		return nil
+	}
+
+	c := cache.NewCache(CIProviders, SourceProviders)
+	checked := make(map[string]bool)
+	failures := make([]tui.Failure, 0)
+	for _, ref := range refs {
+		revision, exists := ref.upstream()
+		if !exists {
+			continue
+		}
+		sha := revision
+		if revision != ref.remoteSha {
+			// The parent of a root commit does not exist and there is nothing to check
+			_, commit, err := utils.GitOriginURL(repo, revision)
+			if err != nil {
+				continue
+			}
+			sha = commit.Sha
+		}
+		if checked[sha] {
+			continue
+		}
+		checked[sha] = true
+
+		builds, err := c.Pipelines(ctx, remoteURL, sha)
+		switch err {
+		case nil:
+			failures = append(failures, policy.Failures(builds)...)
+		case cache.ErrRepositoryNotFound:
+			continue
+		default:
+			return err
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	for _, failure := range failures {
+		if _, err := fmt.Fprintln(w, failure.String()); err != nil {
+			return err
+		}
+	}
+	return ErrPushBlocked
+}
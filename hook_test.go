@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParsePushedRefs(t *testing.T) {
+	input := "refs/heads/master 67890f2a0eb5d8f1e0ff1e46e9e1a3a7f5fdc2c1 refs/heads/master 12345f2a0eb5d8f1e0ff1e46e9e1a3a7f5fdc2c1\n" +
+		"\n" +
+		"refs/heads/feature 67890f2a0eb5d8f1e0ff1e46e9e1a3a7f5fdc2c1 refs/heads/feature " + zeroSha + "\n" +
+		"(delete) " + zeroSha + " refs/heads/old 12345f2a0eb5d8f1e0ff1e46e9e1a3a7f5fdc2c1\n"
+
+	refs, err := parsePushedRefs(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		revision string
+		exists   bool
+	}{
+		{"12345f2a0eb5d8f1e0ff1e46e9e1a3a7f5fdc2c1", true},
+		{"67890f2a0eb5d8f1e0ff1e46e9e1a3a7f5fdc2c1~1", true},
+		{"", false},
+	}
+	if len(refs) != len(expected) {
+		t.Fatalf("expected %d refs but got %d", len(expected), len(refs))
+	}
+	for i, ref := range refs {
+		revision, exists := ref.upstream()
+		if revision != expected[i].revision || exists != expected[i].exists {
+			t.Fatalf("expected (%q, %v) but got (%q, %v)", expected[i].revision,
+				expected[i].exists, revision, exists)
+		}
+	}
+
+	t.Run("invalid input", func(t *testing.T) {
+		if _, err := parsePushedRefs(strings.NewReader("refs/heads/master\n")); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestParseHookArguments(t *testing.T) {
+	a, err := parseHookArguments([]string{"--fail-on", "failed", "origin", "git@github.com:nbedos/citop.git"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := hookArguments{
+		failOn:    "failed",
+		remote:    "origin",
+		remoteURL: "git@github.com:nbedos/citop.git",
+	}
+	if diff := cmp.Diff(expected, a, cmp.AllowUnexported(hookArguments{})); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	if _, err := parseHookArguments([]string{"origin"}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
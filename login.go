@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Name of the file storing the tokens entered with 'citop login', relative to the state
+// directory of citop
+const tokensFilename = "tokens.json"
+
+// Return the path of the file storing the tokens entered with 'citop login'
+func tokensPath() string {
+	return path.Join(utils.XDGStateHome(), ConfDir, tokensFilename)
+}
+
+// Return the tokens stored at 'p' by provider identifier. A missing file holds no token.
+func loadTokens(p string) (map[string]string, error) {
+	tokens := make(map[string]string)
+	bs, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return tokens, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(bs, &tokens); err != nil {
+		return nil, fmt.Errorf("invalid token file %q: %v", p, err)
+	}
+	return tokens, nil
+}
+
+func saveTokens(p string, tokens map[string]string) error {
+	bs, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomically(p, bs)
+}
+
+// Return a copy of the configuration where the tokens of providers are replaced by 'tokens',
+// indexed by provider identifier
+func (c ProvidersConfiguration) withTokens(tokens map[string]string) ProvidersConfiguration {
+	confsByPrefix := map[string]*[]ProviderConfiguration{
+		"gitlab":     &c.GitLab,
+		"github":     &c.GitHub,
+		"circleci":   &c.CircleCI,
+		"travis":     &c.Travis,
+		"appveyor":   &c.AppVeyor,
+		"azure":      &c.Azure,
+		"prow":       &c.Prow,
+		"lighthouse": &c.Lighthouse,
+		"buildbot":   &c.Buildbot,
+		"semaphore":  &c.Semaphore,
+		"concourse":  &c.Concourse,
+		"gitea":      &c.Gitea,
+		"bamboo":     &c.Bamboo,
+		"gerrit":     &c.Gerrit,
+		"webhook":    &c.Webhook,
+		"custom":     &c.Custom,
+	}
+	for prefix, confs := range confsByPrefix {
+		if len(*confs) == 0 {
+			continue
+		}
+		copied := make([]ProviderConfiguration, len(*confs))
+		copy(copied, *confs)
+		for i := range copied {
+			if token, exists := tokens[fmt.Sprintf("%s-%d", prefix, i)]; exists {
+				copied[i].Token = token
+			}
+		}
+		*confs = copied
+	}
+	return c
+}
+
+// loadConfiguration returns the first configuration file found among 'paths' (see
+// ConfigFromPaths) with the tokens of providers replaced by the tokens entered with
+// 'citop login'
+func loadConfiguration(paths ...string) (Configuration, error) {
+	config, err := ConfigFromPaths(paths...)
+	if err != nil {
+		return config, err
+	}
+	tokens, err := loadTokens(tokensPath())
+	if err != nil {
+		return config, err
+	}
+	config.Providers = config.Providers.withTokens(tokens)
+	return config, nil
+}
+
+// Return the source providers and CI providers of 'c' without duplicates, in this order
+func uniqueProviders(c ProvidersConfiguration, base http.RoundTripper) ([]interface{ ID() string }, error) {
+	sourceProviders, ciProviders, err := c.Providers(context.Background(), base)
+	if err != nil {
+		return nil, err
+	}
+
+	ps := make([]interface{ ID() string }, 0)
+	seen := make(map[string]bool)
+	for _, p := range sourceProviders {
+		ps = append(ps, p)
+		seen[p.ID()] = true
+	}
+	for _, p := range ciProviders {
+		if !seen[p.ID()] {
+			ps = append(ps, p)
+		}
+	}
+	return ps, nil
+}
+
+var ErrLoginFailed = errors.New("authentication failed for at least one provider")
+
+// Return a function writing a prompt to 'w' and reading a token from 'r'. The token is not
+// echoed if 'r' is a terminal.
+func tokenReader(r *os.File, w io.Writer) func(prompt string) (string, error) {
+	lines := bufio.NewReader(r)
+	return func(prompt string) (string, error) {
+		if _, err := fmt.Fprint(w, prompt); err != nil {
+			return "", err
+		}
+		if terminal.IsTerminal(int(r.Fd())) {
+			bs, err := terminal.ReadPassword(int(r.Fd()))
+			fmt.Fprintln(w)
+			return strings.TrimSpace(string(bs)), err
+		}
+		line, err := lines.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+}
+
+// Return the provider of 'c' identified by 'id'
+func providerByID(c ProvidersConfiguration, id string, base http.RoundTripper) (interface{ ID() string }, error) {
+	ps, err := uniqueProviders(c, base)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range ps {
+		if p.ID() == id {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("no provider with identifier %q in the configuration file", id)
+}
+
+// runLogin checks that each provider of 'config' accepts its token and asks for a new token with
+// 'readToken' for every provider rejecting it. New tokens are stored in 'tokensFile' once accepted by
+// their provider and replace the tokens of the configuration file from then on. Only the
+// providers whose identifier is listed in 'ids' are checked, unless 'ids' is empty. Entering an
+// empty token skips the provider.
+func runLogin(ctx context.Context, w io.Writer, readToken func(prompt string) (string, error), config ProvidersConfiguration, tokensFile string, ids []string, base http.RoundTripper) error {
+	tokens, err := loadTokens(tokensFile)
+	if err != nil {
+		return err
+	}
+	config = config.withTokens(tokens)
+
+	ps, err := uniqueProviders(config, base)
+	if err != nil {
+		return err
+	}
+	selected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, err := providerByID(config, id, base); err != nil {
+			return err
+		}
+		selected[id] = true
+	}
+
+	success := true
+	for _, p := range ps {
+		id := p.ID()
+		if len(ids) > 0 && !selected[id] {
+			continue
+		}
+		if _, ok := p.(cache.AuthenticationChecker); !ok {
+			fmt.Fprintf(w, "%s: authentication cannot be checked for this provider\n", id)
+			continue
+		}
+
+		token := ""
+		for {
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			err := p.(cache.AuthenticationChecker).CheckAuthentication(checkCtx)
+			cancel()
+			if err == nil {
+				fmt.Fprintf(w, "%s: authenticated\n", id)
+				if token != "" {
+					tokens[id] = token
+					if err := saveTokens(tokensFile, tokens); err != nil {
+						return err
+					}
+				}
+				break
+			}
+			if status, _ := cache.ErrorStatus(p, err); status != cache.ProviderAuthFailed {
+				fmt.Fprintf(w, "%s: %v\n", id, err)
+				success = false
+				break
+			}
+
+			fmt.Fprintf(w, "%s: token rejected (%v)\n", id, err)
+			if token, err = readToken(fmt.Sprintf("New token for %s (empty to skip): ", id)); err != nil {
+				return err
+			}
+			if token == "" {
+				success = false
+				break
+			}
+			candidate := config.withTokens(map[string]string{id: token})
+			if p, err = providerByID(candidate, id, base); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !success {
+		return ErrLoginFailed
+	}
+	return nil
+}
+
+// Run 'citop login' with the provider identifiers passed on the command line
+func runLoginCommand(args []string) int {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			fmt.Fprintf(os.Stderr, "Error: unknown option %q\n", arg)
+			fmt.Fprintln(os.Stderr, usage())
+			return exitError
+		}
+	}
+	paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
+	config, err := ConfigFromPaths(paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+
+	err = runLogin(context.Background(), os.Stdout, tokenReader(os.Stdin, os.Stdout), config.Providers, tokensPath(), args, http.DefaultTransport)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	return 0
+}
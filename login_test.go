@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Transport answering requests in place of GitHub. Only the token "valid" is accepted.
+type fakeGitHubTransport struct{}
+
+func (fakeGitHubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status, body := http.StatusOK, `{"login": "nbedos"}`
+	if req.Header.Get("Authorization") != "Bearer valid" {
+		status, body = http.StatusUnauthorized, `{"message": "Bad credentials"}`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestProvidersConfiguration_withTokens(t *testing.T) {
+	c := ProvidersConfiguration{
+		GitLab: []ProviderConfiguration{{Token: "a"}, {Token: "b"}},
+		GitHub: []ProviderConfiguration{{Token: "c"}},
+	}
+	replaced := c.withTokens(map[string]string{"gitlab-1": "d", "travis-0": "e"})
+
+	expected := ProvidersConfiguration{
+		GitLab: []ProviderConfiguration{{Token: "a"}, {Token: "d"}},
+		GitHub: []ProviderConfiguration{{Token: "c"}},
+	}
+	if diff := cmp.Diff(expected, replaced); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if c.GitLab[1].Token != "b" {
+		t.Fatal("the original configuration must not be modified")
+	}
+}
+
+func TestRunLogin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokensFile := path.Join(dir, "tokens.json")
+	config := ProvidersConfiguration{
+		GitHub: []ProviderConfiguration{{Token: "expired"}},
+	}
+
+	// Return the tokens of 'tokens' one after the other
+	reader := func(tokens ...string) func(string) (string, error) {
+		return func(prompt string) (string, error) {
+			token := tokens[0]
+			tokens = tokens[1:]
+			return token, nil
+		}
+	}
+
+	t.Run("empty token skips the provider", func(t *testing.T) {
+		w := bytes.Buffer{}
+		err := runLogin(context.Background(), &w, reader(""), config, tokensFile, nil, fakeGitHubTransport{})
+		if err != ErrLoginFailed {
+			t.Fatalf("expected %v but got %v", ErrLoginFailed, err)
+		}
+		if _, err := os.Stat(tokensFile); !os.IsNotExist(err) {
+			t.Fatal("no token must be stored")
+		}
+	})
+
+	t.Run("rejected tokens are not stored", func(t *testing.T) {
+		w := bytes.Buffer{}
+		err := runLogin(context.Background(), &w, reader("wrong", "valid"), config, tokensFile, []string{"github-0"}, fakeGitHubTransport{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(w.String(), "github-0: authenticated\n") {
+			t.Fatalf("unexpected output %q", w.String())
+		}
+		tokens, err := loadTokens(tokensFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(map[string]string{"github-0": "valid"}, tokens); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("stored tokens replace the tokens of the configuration", func(t *testing.T) {
+		w := bytes.Buffer{}
+		err := runLogin(context.Background(), &w, reader(), config, tokensFile, nil, fakeGitHubTransport{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.String() != "github-0: authenticated\n" {
+			t.Fatalf("unexpected output %q", w.String())
+		}
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		err := runLogin(context.Background(), &bytes.Buffer{}, reader(), config, tokensFile, []string{"gitlab-0"}, fakeGitHubTransport{})
+		if err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
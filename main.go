@@ -7,24 +7,41 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
 	"path"
+	"strconv"
 	"strings"
 	"syscall"
+	"text/template"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/gdamore/tcell"
 	"github.com/nbedos/citop/cache"
 	"github.com/nbedos/citop/providers"
+	"github.com/nbedos/citop/text"
 	"github.com/nbedos/citop/tui"
 	"github.com/nbedos/citop/utils"
 	"github.com/pelletier/go-toml"
+	"golang.org/x/oauth2/google"
+	"gopkg.in/yaml.v2"
 )
 
 var Version = "undefined"
 
+// Exit statuses of non-interactive modes
+const (
+	exitError           = 1
+	exitPipelinesFailed = 2
+	exitTimeout         = 3
+	// No line of the logs matched the pattern of citop grep
+	exitNoMatch = 4
+)
+
 const ConfDir = "citop"
 const ConfFilename = "citop.toml"
 
@@ -33,19 +50,682 @@ type ProviderConfiguration struct {
 	Url               string  `toml:"url"`
 	Token             string  `toml:"token"`
 	RequestsPerSecond float64 `toml:"max_requests_per_second"`
+	// Extra headers sent with every request, for instances sitting behind an authenticating
+	// proxy
+	Headers map[string]string `toml:"headers"`
+	// JSON endpoint of the status page of the service, "none" to disable the check
+	StatusURL string `toml:"status_url"`
+	// GitHub only: source of pipelines kept when a CI service reports a pipeline both as a
+	// commit status and as a check run, and contexts of the commit statuses to ignore
+	Prefer       string   `toml:"prefer"`
+	HideStatuses []string `toml:"hide_statuses"`
+	// GitHub and GitLab only: patterns of the owners of the repositories the account is used
+	// for, empty to use the account for every repository
+	Owners []string `toml:"owners"`
+	// Patterns of the repositories the provider is used for and of those it must not be used
+	// for, such as "nbedos/citop" or "acme/*"
+	Only   []string `toml:"only"`
+	Except []string `toml:"except"`
+	// Prow only: URL of the storage where job artifacts are uploaded
+	StorageURL string `toml:"storage_url"`
+	// Lighthouse only: URL of the API server of the Kubernetes cluster running the pipelines
+	// and namespace of the pipelines
+	KubernetesURL string `toml:"kubernetes_url"`
+	Namespace     string `toml:"namespace"`
+	// Concourse only: team of the pipelines and target of fly whose URL, team and token are
+	// used unless specified
+	Team   string `toml:"team"`
+	Target string `toml:"target"`
+	// CodeBuild only: region of the projects and profile of the shared AWS configuration whose
+	// credentials are used instead of the default credential chain
+	Region  string `toml:"region"`
+	Profile string `toml:"profile"`
+	// Cloud Build only: identifier of the Google Cloud project running the builds
+	Project string `toml:"project"`
+	// Gerrit only: user whose HTTP password is the token
+	Username string `toml:"username"`
+	// Webhook only: local address the receiver listens on, e.g. "localhost:8080"
+	Address string `toml:"address"`
+	// Custom only: URL templates of the endpoints of the API, paths of the fields of its
+	// responses and states of citop by state of the service
+	Endpoints map[string]string `toml:"endpoints"`
+	Fields    map[string]string `toml:"fields"`
+	States    map[string]string `toml:"states"`
+}
+
+// Return the policy applied to the commit statuses and check runs of GitHub
+func (c ProviderConfiguration) statusPolicy() (providers.StatusPolicy, error) {
+	policy := providers.StatusPolicy{
+		HiddenContexts: c.HideStatuses,
+	}
+	switch prefer := strings.ToLower(c.Prefer); prefer {
+	case "", "both":
+	case providers.PreferChecks, providers.PreferStatuses:
+		policy.Prefer = prefer
+	default:
+		return policy, fmt.Errorf("invalid value %q for key 'prefer' (expected \"checks\", \"statuses\" or \"both\")", c.Prefer)
+	}
+	for _, pattern := range c.HideStatuses {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return policy, fmt.Errorf("invalid pattern %q for key 'hide_statuses': %v", pattern, err)
+		}
+	}
+	return policy, nil
+}
+
+// Return the owners of the repositories the account is used for
+func (c ProviderConfiguration) owners() (providers.OwnerPatterns, error) {
+	for _, pattern := range c.Owners {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q for key 'owners': %v", pattern, err)
+		}
+	}
+	return providers.OwnerPatterns(c.Owners), nil
+}
+
+// Return the repositories the provider is used for
+func (c ProviderConfiguration) repositories() (providers.RepositoryPatterns, error) {
+	keys := []struct {
+		name     string
+		patterns []string
+	}{{"only", c.Only}, {"except", c.Except}}
+	for _, key := range keys {
+		for _, pattern := range key.patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return providers.RepositoryPatterns{}, fmt.Errorf("invalid pattern %q for key '%s': %v", pattern, key.name, err)
+			}
+		}
+	}
+	return providers.RepositoryPatterns{Only: c.Only, Except: c.Except}, nil
+}
+
+// Return the path of the configuration file of fly, the command line interface of Concourse
+func flyrcPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(home, ".flyrc")
+}
+
+// Return a copy of the configuration where the URL, the team and the token left empty are
+// replaced by those of the target of fly named by the key 'target'. 'flyrc' is the path of the
+// configuration file of fly.
+func (c ProviderConfiguration) withFlyTarget(flyrc string) (ProviderConfiguration, error) {
+	if c.Target == "" {
+		return c, nil
+	}
+	bs, err := ioutil.ReadFile(flyrc)
+	if err != nil {
+		return c, fmt.Errorf("cannot read target %q of fly: %v", c.Target, err)
+	}
+	var conf struct {
+		Targets map[string]struct {
+			API   string `yaml:"api"`
+			Team  string `yaml:"team"`
+			Token struct {
+				Type  string `yaml:"type"`
+				Value string `yaml:"value"`
+			} `yaml:"token"`
+		} `yaml:"targets"`
+	}
+	if err := yaml.Unmarshal(bs, &conf); err != nil {
+		return c, fmt.Errorf("invalid configuration file of fly %q: %v", flyrc, err)
+	}
+	target, exists := conf.Targets[c.Target]
+	if !exists {
+		return c, fmt.Errorf("no target %q in the configuration file of fly %q", c.Target, flyrc)
+	}
+
+	if c.Url == "" {
+		c.Url = target.API
+	}
+	if c.Team == "" {
+		c.Team = target.Team
+	}
+	if c.Token == "" && strings.EqualFold(target.Token.Type, "bearer") {
+		c.Token = target.Token.Value
+	}
+	return c, nil
+}
+
+// Return the value of the User-Agent header of the requests sent by citop
+func userAgent() string {
+	return fmt.Sprintf("citop/%s", Version)
+}
+
+// Return the headers sent with every request to the provider. Headers of the configuration
+// take precedence over the default User-Agent.
+func (c ProviderConfiguration) header() http.Header {
+	header := http.Header{}
+	header.Set("User-Agent", userAgent())
+	for key, value := range c.Headers {
+		header.Set(key, value)
+	}
+	return header
+}
+
+// Return the options of the client of the provider sending requests with 'base'
+func (c ProviderConfiguration) clientOptions(base http.RoundTripper) []providers.ClientOption {
+	return []providers.ClientOption{
+		providers.WithTransport(base),
+		providers.WithHeader(c.header()),
+	}
 }
 
 type ProvidersConfiguration struct {
-	GitLab   []ProviderConfiguration
-	GitHub   []ProviderConfiguration
-	CircleCI []ProviderConfiguration
-	Travis   []ProviderConfiguration
-	AppVeyor []ProviderConfiguration
-	Azure    []ProviderConfiguration
+	// Skip the providers requiring credentials and send unauthenticated requests to the others
+	Anonymous  bool `toml:"anonymous"`
+	GitLab     []ProviderConfiguration
+	GitHub     []ProviderConfiguration
+	CircleCI   []ProviderConfiguration
+	Travis     []ProviderConfiguration
+	AppVeyor   []ProviderConfiguration
+	Azure      []ProviderConfiguration
+	Prow       []ProviderConfiguration
+	Lighthouse []ProviderConfiguration
+	Buildbot   []ProviderConfiguration
+	Semaphore  []ProviderConfiguration
+	Concourse  []ProviderConfiguration
+	CodeBuild  []ProviderConfiguration
+	CloudBuild []ProviderConfiguration
+	Gitea      []ProviderConfiguration
+	Bamboo     []ProviderConfiguration
+	Gerrit     []ProviderConfiguration
+	Webhook    []ProviderConfiguration
+	Custom     []ProviderConfiguration
+}
+
+// ElementStyle overrides the built-in style of an element of the user interface
+type ElementStyle struct {
+	Foreground string `toml:"foreground"`
+	Background string `toml:"background"`
+	Bold       bool   `toml:"bold"`
+	Underline  bool   `toml:"underline"`
+	Reverse    bool   `toml:"reverse"`
+}
+
+// Colors are specified either by name ("red", "darkcyan"...), by index in the 256-color
+// palette or in hexadecimal notation ("#ff8700"). Hexadecimal colors are rendered as is by
+// terminals supporting true colors and are approximated by the nearest color of the palette
+// on other terminals.
+func parseColor(s string) (tcell.Color, error) {
+	if s == "" {
+		return tcell.ColorDefault, nil
+	}
+	s = strings.ToLower(s)
+	if c, exists := tcell.ColorNames[s]; exists {
+		return c, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n < 256 {
+		return tcell.Color(n), nil
+	}
+	if len(s) == 7 && s[0] == '#' {
+		if v, err := strconv.ParseInt(s[1:], 16, 32); err == nil {
+			return tcell.NewHexColor(int32(v)), nil
+		}
+	}
+
+	return tcell.ColorDefault, fmt.Errorf("invalid color %q (expected color name, number between 0 and 255 or hexadecimal value such as \"#ff8700\")", s)
+}
+
+func (e ElementStyle) styleFunc() (func(tcell.Style) tcell.Style, error) {
+	fg, err := parseColor(e.Foreground)
+	if err != nil {
+		return nil, err
+	}
+	bg, err := parseColor(e.Background)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(s tcell.Style) tcell.Style {
+		if e.Foreground != "" {
+			s = s.Foreground(fg)
+		}
+		if e.Background != "" {
+			s = s.Background(bg)
+		}
+		return s.Bold(e.Bold).Underline(e.Underline).Reverse(e.Reverse)
+	}, nil
+}
+
+// StateStyles overrides the built-in style of each state of pipelines, stages and jobs
+type StateStyles struct {
+	Pending  *ElementStyle `toml:"pending"`
+	Running  *ElementStyle `toml:"running"`
+	Passed   *ElementStyle `toml:"passed"`
+	Failed   *ElementStyle `toml:"failed"`
+	Canceled *ElementStyle `toml:"canceled"`
+	Skipped  *ElementStyle `toml:"skipped"`
+	Manual   *ElementStyle `toml:"manual"`
+}
+
+type StyleConfiguration struct {
+	Theme     string        `toml:"theme"`
+	Header    *ElementStyle `toml:"header"`
+	ActiveRow *ElementStyle `toml:"active_row"`
+	Match     *ElementStyle `toml:"match"`
+	Provider  *ElementStyle `toml:"provider"`
+	Sha       *ElementStyle `toml:"sha"`
+	Branch    *ElementStyle `toml:"branch"`
+	Tag       *ElementStyle `toml:"tag"`
+	Head      *ElementStyle `toml:"head"`
+	States    StateStyles   `toml:"states"`
+	Icons     string        `toml:"icons"`
+}
+
+// Return true if the locale of the user indicates that the terminal handles UTF-8
+func utf8Locale() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			value = strings.ToLower(value)
+			return strings.Contains(value, "utf-8") || strings.Contains(value, "utf8")
+		}
+	}
+	return false
+}
+
+// Return the glyphs shown next to the state of pipelines, stages and jobs. Unicode and Nerd
+// Font glyphs are replaced by ASCII characters if the locale does not support UTF-8.
+func (c StyleConfiguration) StateIcons() (tui.StateIcons, error) {
+	switch strings.ToLower(c.Icons) {
+	case "", "none":
+		return nil, nil
+	case "ascii":
+		return tui.ASCIIStateIcons, nil
+	case "unicode":
+		if !utf8Locale() {
+			return tui.ASCIIStateIcons, nil
+		}
+		return tui.UnicodeStateIcons, nil
+	case "nerdfont":
+		if !utf8Locale() {
+			return tui.ASCIIStateIcons, nil
+		}
+		return tui.NerdFontStateIcons, nil
+	default:
+		return nil, fmt.Errorf("invalid icon set %q (expected \"none\", \"ascii\", \"unicode\" or \"nerdfont\")", c.Icons)
+	}
+}
+
+// TemplatesConfiguration holds user-defined templates overriding parts of the user interface
+type TemplatesConfiguration struct {
+	Header   string `toml:"header"`
+	Pipeline string `toml:"pipeline"`
+	Stage    string `toml:"stage"`
+	Job      string `toml:"job"`
+}
+
+// Return the templates of the NAME column for each type of row
+func (c TemplatesConfiguration) RowTemplates() (tui.RowTemplates, error) {
+	var templates tui.RowTemplates
+	for _, t := range []struct {
+		name     string
+		s        string
+		template **template.Template
+	}{
+		{name: "pipeline", s: c.Pipeline, template: &templates.Pipeline},
+		{name: "stage", s: c.Stage, template: &templates.Stage},
+		{name: "job", s: c.Job, template: &templates.Job},
+	} {
+		if t.s == "" {
+			continue
+		}
+		tmpl, err := tui.NewRowTemplate(t.name, t.s)
+		if err != nil {
+			return templates, err
+		}
+		*t.template = tmpl
+	}
+
+	return templates, nil
+}
+
+// Return the template describing the commit at the top of the screen or nil if the default
+// description should be used
+func (c TemplatesConfiguration) HeaderTemplate() (*template.Template, error) {
+	if c.Header == "" {
+		return nil, nil
+	}
+	return tui.NewHeaderTemplate(c.Header)
+}
+
+// FollowConfiguration controls how the user interface reacts to new commits
+type FollowConfiguration struct {
+	// What to do when the remote branch being monitored advances: "ask", "auto" or "off"
+	Branch string `toml:"branch"`
+}
+
+// LogsConfiguration controls how logs are shown
+type LogsConfiguration struct {
+	// Presentation of the timestamps prefixing the lines of logs: "keep", "hide" or "relative"
+	Timestamps string `toml:"timestamps"`
+	// Drop the colors of logs instead of showing them
+	StripColors bool `toml:"strip_colors"`
+	// Template of the path of the files logs are saved to
+	SavePath string `toml:"save_path"`
+}
+
+// FilterConfiguration restricts the pipelines and jobs fetched and shown by citop
+type FilterConfiguration struct {
+	// Patterns of the jobs to keep, all jobs are kept if empty
+	Jobs []string `toml:"jobs"`
+	// Patterns of the jobs to hide
+	IgnoreJobs []string `toml:"ignore_jobs"`
+	// Patterns of the pipelines to hide
+	IgnorePipelines []string `toml:"ignore_pipelines"`
+}
+
+// Filter returns the filter of pipelines and jobs described by the configuration
+func (c FilterConfiguration) Filter() (tui.Filter, error) {
+	var f tui.Filter
+	var err error
+	if f.Jobs, err = tui.NewJobFilter(c.Jobs, c.IgnoreJobs); err != nil {
+		return f, err
+	}
+	f.Pipelines, err = tui.NewPipelineFilter(c.IgnorePipelines)
+	return f, err
+}
+
+// TableConfiguration controls the columns of the table of pipelines
+type TableConfiguration struct {
+	// Optional columns shown in addition to the default ones
+	Columns []string `toml:"columns"`
+	// Sizing mode of columns by name: "auto", a number of screen columns or a percentage of
+	// the width of the terminal
+	Widths map[string]string `toml:"widths"`
+	// Minimum width of columns by name
+	MinWidths map[string]int `toml:"min_widths"`
+	// Priority of columns by name. Columns of low priority are hidden first on narrow terminals.
+	Priorities map[string]int `toml:"priorities"`
+	// Whether the NAME column of the row at the cursor is wrapped across several lines
+	Wrap bool `toml:"wrap"`
+	// Numbering of rows: "off", "absolute" or "relative"
+	LineNumbers string `toml:"line_numbers"`
+	// Column used to sort rows when citop starts, empty for the default order
+	Sort string `toml:"sort"`
+	// Whether rows are sorted in descending order
+	Reverse bool `toml:"reverse"`
+}
+
+// Return the upper case name of 'column' if it designates a column of the table of pipelines.
+// 'table' is the name of the configuration table where the column is listed.
+func columnName(column string, table string) (string, error) {
+	column = strings.ToUpper(column)
+	for _, name := range tui.Columns {
+		if column == name {
+			return column, nil
+		}
+	}
+	return "", fmt.Errorf("invalid column %q in [%s] (expected one of %s)", column, table, strings.Join(tui.Columns, ", "))
+}
+
+// ColumnWidths returns the sizing mode of the columns listed by the user by upper case column name
+func (c TableConfiguration) ColumnWidths() (map[string]tui.ColumnWidth, error) {
+	widths := make(map[string]tui.ColumnWidth, len(c.Widths))
+	for column, s := range c.Widths {
+		column, err := columnName(column, "table.widths")
+		if err != nil {
+			return nil, err
+		}
+		width, err := tui.ParseColumnWidth(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid width of column %q: %v", column, err)
+		}
+		widths[column] = width
+	}
+	for column, min := range c.MinWidths {
+		column, err := columnName(column, "table.min_widths")
+		if err != nil {
+			return nil, err
+		}
+		if min < 0 {
+			return nil, fmt.Errorf("invalid minimum width of column %q: %d (expected a number greater than or equal to 0)", column, min)
+		}
+		width := widths[column]
+		width.Min = min
+		widths[column] = width
+	}
+	return widths, nil
+}
+
+// SortColumn returns the upper case name of the column used to sort rows when citop starts, or
+// an empty string for the default order
+func (c TableConfiguration) SortColumn() (string, error) {
+	if c.Sort == "" {
+		return "", nil
+	}
+	return columnName(c.Sort, "table")
+}
+
+// ColumnPriorities returns the priorities of the columns listed by the user by upper case column
+// name
+func (c TableConfiguration) ColumnPriorities() (map[string]int, error) {
+	priorities := make(map[string]int, len(c.Priorities))
+	for column, priority := range c.Priorities {
+		column, err := columnName(column, "table.priorities")
+		if err != nil {
+			return nil, err
+		}
+		priorities[column] = priority
+	}
+	return priorities, nil
+}
+
+// OptionalColumns returns the names of the optional columns requested by the user in upper case
+func (c TableConfiguration) OptionalColumns() ([]string, error) {
+	columns := make([]string, 0, len(c.Columns))
+	for _, column := range c.Columns {
+		column = strings.ToUpper(column)
+		valid := false
+		for _, optional := range tui.OptionalColumns {
+			valid = valid || column == optional
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid column %q (expected one of %s)", column, strings.Join(tui.OptionalColumns, ", "))
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
 }
 
 type Configuration struct {
-	Providers ProvidersConfiguration
+	Providers     ProvidersConfiguration
+	Style         StyleConfiguration
+	Table         TableConfiguration
+	Templates     TemplatesConfiguration
+	Update        UpdateConfiguration
+	Follow        FollowConfiguration
+	Logs          LogsConfiguration
+	Filter        FilterConfiguration
+	Notifications NotificationsConfiguration
+	MQTT          MQTTConfiguration
+	// Problem matchers looking for problems in logs in addition to the default ones
+	ProblemMatchers ProblemMatchersConfiguration `toml:"problem_matchers"`
+}
+
+// ProblemMatcherConfiguration describes the lines of logs reporting a problem
+type ProblemMatcherConfiguration struct {
+	Name    string `toml:"name"`
+	Pattern string `toml:"pattern"`
+}
+
+type ProblemMatchersConfiguration []ProblemMatcherConfiguration
+
+// ProblemMatchers returns the problem matchers defined by the user followed by the default
+// ones. A line of a log matched by a user-defined matcher is not checked against the default
+// matchers.
+func (c ProblemMatchersConfiguration) ProblemMatchers() ([]tui.ProblemMatcher, error) {
+	matchers := make([]tui.ProblemMatcher, 0, len(c)+len(tui.DefaultProblemMatchers))
+	for i, m := range c {
+		if m.Name == "" {
+			m.Name = fmt.Sprintf("problem_matchers[%d]", i)
+		}
+		matcher, err := tui.NewProblemMatcher(m.Name, m.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+
+	return append(matchers, tui.DefaultProblemMatchers...), nil
+}
+
+// Final states of pipelines reported by notifications unless specified otherwise
+const defaultNotificationStates = "passed,failed,canceled"
+
+// Message submission port of SMTP servers
+const defaultSMTPPort = 587
+
+// WebhookConfiguration describes a webhook receiving a message every time a pipeline reaches
+// one of the states listed by 'On'
+type WebhookConfiguration struct {
+	Url string `toml:"url"`
+	On  string `toml:"on"`
+}
+
+// MatrixConfiguration describes a Matrix room receiving a message every time a pipeline
+// reaches one of the states listed by 'On'
+type MatrixConfiguration struct {
+	Url   string `toml:"url"`
+	Room  string `toml:"room"`
+	Token string `toml:"token"`
+	On    string `toml:"on"`
+}
+
+// EmailConfiguration describes an SMTP server and the recipients of a message sent every time
+// a pipeline reaches one of the states listed by 'On'
+type EmailConfiguration struct {
+	Host     string   `toml:"host"`
+	Port     int      `toml:"port"`
+	Username string   `toml:"username"`
+	Password string   `toml:"password"`
+	From     string   `toml:"from"`
+	To       []string `toml:"to"`
+	On       string   `toml:"on"`
+}
+
+// NotificationsConfiguration lists the chat services and the mailboxes told about pipelines
+// finishing while the user interface is running
+type NotificationsConfiguration struct {
+	Slack   []WebhookConfiguration
+	Discord []WebhookConfiguration
+	Matrix  []MatrixConfiguration
+	Email   []EmailConfiguration
+}
+
+func notificationStates(on string) (map[cache.State]bool, error) {
+	if on == "" {
+		on = defaultNotificationStates
+	}
+	return tui.ParseStates(on)
+}
+
+// Return the notifications described by the configuration
+func (c NotificationsConfiguration) Notifications(client *http.Client) ([]tui.Notification, error) {
+	notifications := make([]tui.Notification, 0)
+
+	webhooks := []struct {
+		name    string
+		confs   []WebhookConfiguration
+		newFunc func(*http.Client, string) tui.Notifier
+	}{
+		{
+			name:  "slack",
+			confs: c.Slack,
+			newFunc: func(client *http.Client, u string) tui.Notifier {
+				return tui.NewSlackNotifier(client, u)
+			},
+		},
+		{
+			name:  "discord",
+			confs: c.Discord,
+			newFunc: func(client *http.Client, u string) tui.Notifier {
+				return tui.NewDiscordNotifier(client, u)
+			},
+		},
+	}
+	for _, webhook := range webhooks {
+		for _, conf := range webhook.confs {
+			if conf.Url == "" {
+				return nil, fmt.Errorf("missing key \"url\" in table [[notifications.%s]]", webhook.name)
+			}
+			states, err := notificationStates(conf.On)
+			if err != nil {
+				return nil, err
+			}
+			notifications = append(notifications, tui.Notification{
+				Notifier: webhook.newFunc(client, conf.Url),
+				States:   states,
+			})
+		}
+	}
+
+	for _, conf := range c.Matrix {
+		if conf.Url == "" || conf.Room == "" || conf.Token == "" {
+			return nil, errors.New("keys \"url\", \"room\" and \"token\" are required in table [[notifications.matrix]]")
+		}
+		states, err := notificationStates(conf.On)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, tui.Notification{
+			Notifier: tui.NewMatrixNotifier(client, conf.Url, conf.Room, conf.Token),
+			States:   states,
+		})
+	}
+
+	for _, conf := range c.Email {
+		if conf.Host == "" || conf.From == "" || len(conf.To) == 0 {
+			return nil, errors.New("keys \"host\", \"from\" and \"to\" are required in table [[notifications.email]]")
+		}
+		port := conf.Port
+		if port == 0 {
+			port = defaultSMTPPort
+		}
+		states, err := notificationStates(conf.On)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, tui.Notification{
+			Notifier: tui.NewEmailNotifier(conf.Host, port, conf.Username, conf.Password, conf.From, conf.To),
+			States:   states,
+		})
+	}
+
+	return notifications, nil
+}
+
+// Topic receiving state changes unless specified otherwise
+const defaultMQTTTopic = "citop"
+
+// MQTTConfiguration describes the MQTT broker receiving the state changes of pipelines
+type MQTTConfiguration struct {
+	Broker   string `toml:"broker"`
+	Topic    string `toml:"topic"`
+	ClientID string `toml:"client_id"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+}
+
+// Return the publishers described by the configuration. State changes are not published if no
+// broker is configured.
+func (c MQTTConfiguration) Publishers() []tui.StatePublisher {
+	if c.Broker == "" {
+		return nil
+	}
+	topic := c.Topic
+	if topic == "" {
+		topic = defaultMQTTTopic
+	}
+	clientID := c.ClientID
+	if clientID == "" {
+		clientID = fmt.Sprintf("citop-%d", os.Getpid())
+	}
+	return []tui.StatePublisher{
+		tui.NewMQTTPublisher(c.Broker, topic, clientID, c.Username, c.Password),
+	}
 }
 
 var ErrMissingConf = errors.New("missing configuration file")
@@ -74,9 +754,66 @@ func ConfigFromPaths(paths ...string) (Configuration, error) {
 	return c, ErrMissingConf
 }
 
-func (c ProvidersConfiguration) Providers(ctx context.Context) ([]cache.SourceProvider, []cache.CIProvider, error) {
+// Return the style sheet matching the theme selected by the user. The "auto" theme selects
+// a dark or light palette depending on the background color of the terminal. Elements styled
+// in the configuration file take precedence over the theme.
+func (c StyleConfiguration) StyleSheet() (text.StyleSheet, error) {
+	var base text.StyleSheet
+	switch strings.ToLower(c.Theme) {
+	case "", "auto":
+		base = tui.StyleSheetForBackground(tui.DetectBackground())
+	case "dark":
+		base = tui.DefaultStyleSheet
+	case "light":
+		base = tui.LightStyleSheet
+	default:
+		return nil, fmt.Errorf("invalid theme %q (expected \"auto\", \"dark\" or \"light\")", c.Theme)
+	}
+
+	styleSheet := make(text.StyleSheet, len(base))
+	for class, f := range base {
+		styleSheet[class] = f
+	}
+
+	elements := map[text.Class]*ElementStyle{
+		text.TableHeader:    c.Header,
+		text.ActiveRow:      c.ActiveRow,
+		text.SearchMatch:    c.Match,
+		text.Provider:       c.Provider,
+		text.GitSha:         c.Sha,
+		text.GitBranch:      c.Branch,
+		text.GitTag:         c.Tag,
+		text.GitHead:        c.Head,
+		text.StatusPending:  c.States.Pending,
+		text.StatusRunning:  c.States.Running,
+		text.StatusPassed:   c.States.Passed,
+		text.StatusFailed:   c.States.Failed,
+		text.StatusCanceled: c.States.Canceled,
+		text.StatusSkipped:  c.States.Skipped,
+		text.StatusManual:   c.States.Manual,
+	}
+	for class, element := range elements {
+		if element == nil {
+			continue
+		}
+		f, err := element.styleFunc()
+		if err != nil {
+			return nil, err
+		}
+		styleSheet[class] = f
+	}
+
+	return styleSheet, nil
+}
+
+// Return the providers described by the configuration. Requests are sent with the transport
+// 'base'.
+func (c ProvidersConfiguration) Providers(ctx context.Context, base http.RoundTripper) ([]cache.SourceProvider, []cache.CIProvider, error) {
 	source := make([]cache.SourceProvider, 0)
 	ci := make([]cache.CIProvider, 0)
+	if c.Anonymous {
+		c, _ = c.anonymous()
+	}
 
 	for i, conf := range c.GitLab {
 		rateLimit := time.Second / 10
@@ -89,15 +826,36 @@ func (c ProvidersConfiguration) Providers(ctx context.Context) ([]cache.SourcePr
 		if conf.Name != "" {
 			name = conf.Name
 		}
-		client := providers.NewGitLabClient(id, name, conf.Token, rateLimit)
+		owners, err := conf.owners()
+		if err != nil {
+			return nil, nil, err
+		}
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewGitLabClient(id, name, conf.Token, rateLimit, conf.clientOptions(base)...).WithOwners(owners).WithRepositories(repositories)
 		source = append(source, client)
 		ci = append(ci, client)
 	}
 
 	for i, conf := range c.GitHub {
 		id := fmt.Sprintf("github-%d", i)
-		client := providers.NewGitHubClient(ctx, id, &conf.Token)
+		policy, err := conf.statusPolicy()
+		if err != nil {
+			return nil, nil, err
+		}
+		owners, err := conf.owners()
+		if err != nil {
+			return nil, nil, err
+		}
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewGitHubClient(ctx, id, &conf.Token, conf.clientOptions(base)...).WithStatusPolicy(policy).WithOwners(owners).WithRepositories(repositories)
 		source = append(source, client)
+		ci = append(ci, client)
 	}
 
 	for i, conf := range c.CircleCI {
@@ -110,7 +868,11 @@ func (c ProvidersConfiguration) Providers(ctx context.Context) ([]cache.SourcePr
 		if conf.Name != "" {
 			name = conf.Name
 		}
-		client := providers.NewCircleCIClient(id, name, conf.Token, providers.CircleCIURL, rateLimit)
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewCircleCIClient(id, name, conf.Token, providers.CircleCIURL, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
 		ci = append(ci, client)
 	}
 
@@ -124,7 +886,11 @@ func (c ProvidersConfiguration) Providers(ctx context.Context) ([]cache.SourcePr
 		if conf.Name != "" {
 			name = conf.Name
 		}
-		client := providers.NewAppVeyorClient(id, name, conf.Token, rateLimit)
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewAppVeyorClient(id, name, conf.Token, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
 		ci = append(ci, client)
 	}
 
@@ -152,7 +918,11 @@ func (c ProvidersConfiguration) Providers(ctx context.Context) ([]cache.SourcePr
 		if conf.Name != "" {
 			name = conf.Name
 		}
-		client := providers.NewTravisClient(id, name, conf.Token, *u, rateLimit)
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewTravisClient(id, name, conf.Token, *u, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
 		ci = append(ci, client)
 	}
 
@@ -166,105 +936,988 @@ func (c ProvidersConfiguration) Providers(ctx context.Context) ([]cache.SourcePr
 		if conf.Name != "" {
 			name = conf.Name
 		}
-		client := providers.NewAzurePipelinesClient(id, name, conf.Token, rateLimit)
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewAzurePipelinesClient(id, name, conf.Token, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
 		ci = append(ci, client)
 	}
-	return source, ci, nil
-}
-
-const usage = `usage: citop [-r REPOSITORY | --repository REPOSITORY] [COMMIT]
-       citop -h | --help
-       citop --version
-
-Monitor CI pipelines associated to a specific commit of a git repository
-
-Positional arguments:
-  COMMIT        Specify the commit to monitor. COMMIT is expected to be
-                the SHA identifier of a commit, or the name of a tag or
-                a branch. If this option is missing citop will monitor
-                the commit referenced by HEAD.
-
-Options:
-  -r REPOSITORY, --repository REPOSITORY
-                Specify the git repository to work with. REPOSITORY can
-                be either a path to a local git repository, or the URL
-                of an online repository hosted at GitHub or GitLab.
-                Both web URLs and git URLs are accepted.
-
-                In the absence of this option, citop will work with the
-                git repository located in the current directory. If
-                there is no such repository, citop will fail.
-
-  -h, --help    Show usage
-
-  --version     Print the version of citop being run`
-
-func main() {
-	signal.Ignore(syscall.SIGINT)
-	// FIXME Do not ignore SIGTSTP/SIGCONT
-	signal.Ignore(syscall.SIGTSTP)
-
-	f := flag.NewFlagSet("citop", flag.ContinueOnError)
-	null := bytes.NewBuffer(nil)
-	f.SetOutput(null)
-
-	defaultCommit := "HEAD"
-	defaultRepository, err := os.Getwd()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
-		os.Exit(1)
-	}
-	versionFlag := f.Bool("version", false, "")
-	helpFlagShort := f.Bool("h", false, "")
-	helpFlag := f.Bool("help", false, "")
-	repoFlag := f.String("repository", defaultRepository, "")
-	repoFlagShort := f.String("r", defaultRepository, "")
 
-	if err := f.Parse(os.Args[1:]); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
-		fmt.Fprintln(os.Stderr, usage)
-		os.Exit(1)
-	}
-
-	if *versionFlag {
-		fmt.Fprintf(os.Stderr, "citop %s\n", Version)
-		os.Exit(0)
+	for i, conf := range c.Prow {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("prow-%d", i)
+		name := "prow"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		deckURL, storageURL := providers.ProwURL, providers.GCSURL
+		if conf.Url != "" {
+			u, err := url.Parse(conf.Url)
+			if err != nil {
+				return nil, nil, err
+			}
+			deckURL = *u
+		}
+		if conf.StorageURL != "" {
+			u, err := url.Parse(conf.StorageURL)
+			if err != nil {
+				return nil, nil, err
+			}
+			storageURL = *u
+		}
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewProwClient(id, name, deckURL, storageURL, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
+		ci = append(ci, client)
 	}
 
-	if *helpFlag || *helpFlagShort {
-		fmt.Fprintln(os.Stderr, usage)
-		os.Exit(0)
+	for i, conf := range c.Lighthouse {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("lighthouse-%d", i)
+		name := "lighthouse"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		if conf.Url == "" || conf.KubernetesURL == "" {
+			return nil, nil, fmt.Errorf("missing key 'url' or 'kubernetes_url' in configuration of Lighthouse provider %q", name)
+		}
+		dashboardURL, err := url.Parse(conf.Url)
+		if err != nil {
+			return nil, nil, err
+		}
+		kubernetesURL, err := url.Parse(conf.KubernetesURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		namespace := providers.LighthouseNamespace
+		if conf.Namespace != "" {
+			namespace = conf.Namespace
+		}
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewLighthouseClient(id, name, conf.Token, *dashboardURL, *kubernetesURL, namespace, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
+		ci = append(ci, client)
+	}
+
+	for i, conf := range c.Buildbot {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("buildbot-%d", i)
+		name := "buildbot"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		if conf.Url == "" {
+			return nil, nil, fmt.Errorf("missing key 'url' in configuration of Buildbot provider %q", name)
+		}
+		u, err := url.Parse(conf.Url)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Buildbot lists the builds of a commit by itself so it is also a source provider
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewBuildbotClient(id, name, *u, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
+		source = append(source, client)
+		ci = append(ci, client)
+	}
+
+	for i, conf := range c.Semaphore {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("semaphore-%d", i)
+		name := "semaphore"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		if conf.Url == "" {
+			return nil, nil, fmt.Errorf("missing key 'url' in configuration of Semaphore provider %q", name)
+		}
+		u, err := url.Parse(conf.Url)
+		if err != nil {
+			return nil, nil, err
+		}
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewSemaphoreClient(id, name, conf.Token, *u, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
+		ci = append(ci, client)
+	}
+
+	for i, conf := range c.Concourse {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("concourse-%d", i)
+		name := "concourse"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		conf, err := conf.withFlyTarget(flyrcPath())
+		if err != nil {
+			return nil, nil, err
+		}
+		if c.Anonymous {
+			// Ignore the token of the target of fly
+			conf.Token = ""
+		}
+		if conf.Url == "" {
+			return nil, nil, fmt.Errorf("missing key 'url' or 'target' in configuration of Concourse provider %q", name)
+		}
+		u, err := url.Parse(conf.Url)
+		if err != nil {
+			return nil, nil, err
+		}
+		team := conf.Team
+		if team == "" {
+			team = "main"
+		}
+		// Concourse does not report builds to the host of the repository, the builds of a
+		// commit are found by the client itself so it is also a source provider
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewConcourseClient(id, name, conf.Token, *u, team, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
+		source = append(source, client)
+		ci = append(ci, client)
+	}
+
+	for i, conf := range c.CodeBuild {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("codebuild-%d", i)
+		name := "codebuild"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		config := aws.NewConfig()
+		if conf.Region != "" {
+			config = config.WithRegion(conf.Region)
+		}
+		sess, err := session.NewSessionWithOptions(session.Options{
+			Config:            *config,
+			Profile:           conf.Profile,
+			SharedConfigState: session.SharedConfigEnable,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if aws.StringValue(sess.Config.Region) == "" {
+			return nil, nil, fmt.Errorf("missing key 'region' in configuration of CodeBuild provider %q", name)
+		}
+		// Like Concourse, CodeBuild only reports builds to the host of the repository if the
+		// project is configured to do so
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewCodeBuildClient(id, name, sess, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
+		source = append(source, client)
+		ci = append(ci, client)
+	}
+
+	for i, conf := range c.CloudBuild {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("cloudbuild-%d", i)
+		name := "cloudbuild"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		credentials, err := google.FindDefaultCredentials(ctx, providers.CloudBuildScope)
+		if err != nil {
+			return nil, nil, fmt.Errorf("no credentials found for Cloud Build provider %q: %v", name, err)
+		}
+		project := conf.Project
+		if project == "" {
+			project = credentials.ProjectID
+		}
+		if project == "" {
+			return nil, nil, fmt.Errorf("missing key 'project' in configuration of Cloud Build provider %q", name)
+		}
+		// Builds are found through the triggers of the project so the client is also a source
+		// provider
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewCloudBuildClient(id, name, credentials.TokenSource, project, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
+		source = append(source, client)
+		ci = append(ci, client)
+	}
+
+	for i, conf := range c.Gitea {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("gitea-%d", i)
+		name := "gitea"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		if conf.Url == "" {
+			return nil, nil, fmt.Errorf("missing key 'url' in configuration of Gitea provider %q", name)
+		}
+		u, err := url.Parse(conf.Url)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Workflow runs of a commit are listed by the client itself so it is also a source
+		// provider
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewGiteaClient(id, name, conf.Token, *u, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
+		source = append(source, client)
+		ci = append(ci, client)
+	}
+
+	for i, conf := range c.Bamboo {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("bamboo-%d", i)
+		name := "bamboo"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		if conf.Url == "" {
+			return nil, nil, fmt.Errorf("missing key 'url' in configuration of Bamboo provider %q", name)
+		}
+		u, err := url.Parse(conf.Url)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Bamboo finds the results of the plans that built a revision by itself so it is also
+		// a source provider
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewBambooClient(id, name, conf.Token, *u, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
+		source = append(source, client)
+		ci = append(ci, client)
+	}
+
+	for i, conf := range c.Gerrit {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("gerrit-%d", i)
+		name := "gerrit"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		if conf.Url == "" {
+			return nil, nil, fmt.Errorf("missing key 'url' in configuration of Gerrit provider %q", name)
+		}
+		if conf.Token != "" && conf.Username == "" {
+			return nil, nil, fmt.Errorf("missing key 'username' in configuration of Gerrit provider %q", name)
+		}
+		u, err := url.Parse(conf.Url)
+		if err != nil {
+			return nil, nil, err
+		}
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client := providers.NewGerritClient(id, name, conf.Username, conf.Token, *u, rateLimit, conf.clientOptions(base)...).WithRepositories(repositories)
+		source = append(source, client)
+	}
+
+	for i, conf := range c.Webhook {
+		id := fmt.Sprintf("webhook-%d", i)
+		name := "webhook"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		if conf.Address == "" {
+			return nil, nil, fmt.Errorf("missing key 'address' in configuration of webhook provider %q", name)
+		}
+		if conf.Token == "" {
+			return nil, nil, fmt.Errorf("missing key 'token' in configuration of webhook provider %q", name)
+		}
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		source = append(source, providers.NewWebhookReceiver(id, name, conf.Address, conf.Token).WithRepositories(repositories))
+	}
+
+	for i, conf := range c.Custom {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("custom-%d", i)
+		name := "custom"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		if conf.Url == "" {
+			return nil, nil, fmt.Errorf("missing key 'url' in configuration of custom provider %q", name)
+		}
+		u, err := url.Parse(conf.Url)
+		if err != nil {
+			return nil, nil, err
+		}
+		api := providers.CustomAPI{
+			Endpoints: conf.Endpoints,
+			Fields:    conf.Fields,
+			States:    conf.States,
+		}
+		repositories, err := conf.repositories()
+		if err != nil {
+			return nil, nil, err
+		}
+		client, err := providers.NewCustomClient(id, name, conf.Token, *u, api, rateLimit, conf.clientOptions(base)...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid configuration of custom provider %q: %v", name, err)
+		}
+		client = client.WithRepositories(repositories)
+		source = append(source, client)
+		ci = append(ci, client)
+	}
+	return source, ci, nil
+}
+
+// Value of the key 'status_url' disabling the check of the status page of a provider
+const noStatusPage = "none"
+
+// StatusPages returns the status pages checked for incidents affecting providers. The status
+// page of a provider defaults to the page of its online service if there is one. Providers
+// sharing the same status page are only checked once. Custom headers of providers are not sent
+// to status pages.
+func (c ProvidersConfiguration) StatusPages(base http.RoundTripper) []tui.StatusPage {
+	pages := make([]tui.StatusPage, 0)
+	seen := make(map[string]bool)
+	add := func(confs []ProviderConfiguration, defaultName string, defaultURL func(conf ProviderConfiguration) string) {
+		for _, conf := range confs {
+			u := conf.StatusURL
+			if u == "" {
+				u = defaultURL(conf)
+			}
+			if u == "" || u == noStatusPage || seen[u] {
+				continue
+			}
+			seen[u] = true
+			name := defaultName
+			if conf.Name != "" {
+				name = conf.Name
+			}
+			header := http.Header{}
+			header.Set("User-Agent", userAgent())
+			pages = append(pages, providers.NewStatusPage(name, u, providers.NewHeaderTransport(header, base)))
+		}
+	}
+	constant := func(u string) func(ProviderConfiguration) string {
+		return func(ProviderConfiguration) string { return u }
+	}
+
+	add(c.GitLab, "gitlab", constant(providers.GitLabStatusURL))
+	add(c.GitHub, "github", constant(providers.GitHubStatusURL))
+	add(c.CircleCI, "circleci", constant(providers.CircleCIStatusURL))
+	add(c.AppVeyor, "appveyor", constant(""))
+	add(c.Travis, "travis", func(conf ProviderConfiguration) string {
+		// Instances of Travis CI Enterprise are not covered by the status page of travis-ci.com
+		switch strings.ToLower(conf.Url) {
+		case "org", "com":
+			return providers.TravisStatusURL
+		}
+		return ""
+	})
+	add(c.Azure, "azure", constant(""))
+	add(c.Prow, "prow", constant(""))
+	add(c.Lighthouse, "lighthouse", constant(""))
+	add(c.Buildbot, "buildbot", constant(""))
+	add(c.Semaphore, "semaphore", constant(""))
+	add(c.Concourse, "concourse", constant(""))
+	add(c.CodeBuild, "codebuild", constant(""))
+	add(c.CloudBuild, "cloudbuild", constant(""))
+	add(c.Gitea, "gitea", constant(""))
+	add(c.Bamboo, "bamboo", constant(""))
+	add(c.Gerrit, "gerrit", constant(""))
+	add(c.Webhook, "webhook", constant(""))
+	add(c.Custom, "custom", constant(""))
+
+	return pages
+}
+
+// Return a copy of the configuration without credentials along with a description of the data
+// unavailable to unauthenticated requests, one line per provider. Providers that cannot be used
+// without credentials are removed. Secrets of webhook receivers are kept since they
+// authenticate the requests received by citop, not the requests it sends.
+func (c ProvidersConfiguration) anonymous() (ProvidersConfiguration, []string) {
+	notes := make([]string, 0)
+	note := func(conf ProviderConfiguration, defaultName string, format string, a ...interface{}) {
+		name := defaultName
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		notes = append(notes, name+": "+fmt.Sprintf(format, a...))
+	}
+	// Remove the credentials of the providers of 'confs' and describe 'unavailable' data for
+	// those that had some
+	strip := func(confs []ProviderConfiguration, defaultName string, unavailable string) []ProviderConfiguration {
+		var stripped []ProviderConfiguration
+		for _, conf := range confs {
+			if conf.Token != "" || conf.Username != "" {
+				note(conf, defaultName, "credentials ignored, %s unavailable", unavailable)
+			}
+			conf.Token, conf.Username = "", ""
+			stripped = append(stripped, conf)
+		}
+		return stripped
+	}
+	// Remove the providers of 'confs' since 'service' requires 'credentials'
+	skip := func(confs []ProviderConfiguration, defaultName string, service string, credentials string) []ProviderConfiguration {
+		for _, conf := range confs {
+			note(conf, defaultName, "skipped since %s requires %s", service, credentials)
+		}
+		return nil
+	}
+
+	c.GitLab = strip(c.GitLab, "gitlab", "private projects are")
+	var github []ProviderConfiguration
+	for _, conf := range c.GitHub {
+		// The rate limit of unauthenticated requests is low enough to be worth mentioning
+		// even to users without a token
+		note(conf, "github", "limited to 60 requests per hour, private repositories and logs of GitHub Actions unavailable")
+		conf.Token = ""
+		github = append(github, conf)
+	}
+	c.GitHub = github
+	c.CircleCI = strip(c.CircleCI, "circleci", "private projects are")
+	c.Travis = strip(c.Travis, "travis", "private repositories are")
+	c.AppVeyor = skip(c.AppVeyor, "appveyor", "AppVeyor", "a token")
+	c.Azure = strip(c.Azure, "azure", "private projects are")
+	c.Prow = strip(c.Prow, "prow", "restricted jobs are")
+	c.Lighthouse = skip(c.Lighthouse, "lighthouse", "Lighthouse", "the credentials of a Kubernetes cluster")
+	c.Buildbot = strip(c.Buildbot, "buildbot", "restricted builders are")
+	c.Semaphore = skip(c.Semaphore, "semaphore", "Semaphore", "a token")
+	c.Concourse = strip(c.Concourse, "concourse", "pipelines that are not public are")
+	c.CodeBuild = skip(c.CodeBuild, "codebuild", "AWS CodeBuild", "AWS credentials")
+	c.CloudBuild = skip(c.CloudBuild, "cloudbuild", "Cloud Build", "Google Cloud credentials")
+	c.Gitea = strip(c.Gitea, "gitea", "private repositories are")
+	c.Bamboo = strip(c.Bamboo, "bamboo", "plans restricted to authenticated users are")
+	c.Gerrit = strip(c.Gerrit, "gerrit", "changes restricted to authenticated users are")
+	c.Custom = strip(c.Custom, "custom", "data restricted to authenticated users is")
+
+	return c, notes
+}
+
+// Colors are disabled if the NO_COLOR environment variable is set to a non-empty value
+// (see https://no-color.org/) or if the user explicitly asks for it
+func noColor(flag bool) bool {
+	return flag || os.Getenv("NO_COLOR") != ""
+}
+
+type arguments struct {
+	version    bool
+	help       bool
+	repository string
+	noColor    bool
+	accessible bool
+	output     string
+	follow     bool
+	quiet      bool
+	failOn     string
+	ignore     string
+	jobs       string
+	timeout    time.Duration
+	record     string
+	replay     string
+	commit     string
+	anonymous  bool
+}
+
+// Return the flag set parsing the options of the command line. Every flag must be described
+// in 'options'.
+func newFlagSet(a *arguments, defaultRepository string) *flag.FlagSet {
+	f := flag.NewFlagSet("citop", flag.ContinueOnError)
+	f.SetOutput(bytes.NewBuffer(nil))
+
+	f.BoolVar(&a.version, "version", false, "")
+	f.BoolVar(&a.help, "h", false, "")
+	f.BoolVar(&a.help, "help", false, "")
+	f.StringVar(&a.repository, "repository", defaultRepository, "")
+	f.StringVar(&a.repository, "r", defaultRepository, "")
+	f.BoolVar(&a.noColor, "no-color", false, "")
+	f.BoolVar(&a.accessible, "accessible", false, "")
+	f.StringVar(&a.output, "output", "", "")
+	f.BoolVar(&a.follow, "follow", false, "")
+	f.BoolVar(&a.quiet, "quiet", false, "")
+	f.StringVar(&a.failOn, "fail-on", "failed,canceled", "")
+	f.StringVar(&a.ignore, "ignore", "", "")
+	f.StringVar(&a.jobs, "jobs", "", "")
+	f.DurationVar(&a.timeout, "timeout", 0, "")
+	f.StringVar(&a.record, "record", "", "")
+	f.StringVar(&a.replay, "replay", "", "")
+	f.BoolVar(&a.anonymous, "anonymous", false, "")
+
+	return f
+}
+
+// Return the patterns of the jobs to keep: those given by --jobs if any, those of the
+// configuration file otherwise
+func (a arguments) jobPatterns(config FilterConfiguration) []string {
+	if a.jobs == "" {
+		return config.Jobs
+	}
+	patterns := make([]string, 0)
+	for _, pattern := range strings.Split(a.jobs, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// Return true if citop must run without taking control of the terminal
+func (a arguments) headless() bool {
+	return a.output != "" || a.accessible || a.quiet
+}
+
+// Return the transport of the requests sent to providers. Responses are saved to disk with
+// --record and read from disk with --replay.
+func (a arguments) transport() (http.RoundTripper, error) {
+	switch {
+	case a.record != "":
+		if err := os.MkdirAll(a.record, 0700); err != nil {
+			return nil, err
+		}
+		return providers.NewRecordingTransport(a.record, http.DefaultTransport), nil
+	case a.replay != "":
+		info, err := os.Stat(a.replay)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", a.replay)
+		}
+		return providers.NewReplayTransport(a.replay), nil
+	default:
+		return http.DefaultTransport, nil
+	}
+}
+
+func parseArguments(args []string, defaultRepository string, defaultCommit string) (arguments, error) {
+	a := arguments{commit: defaultCommit}
+	f := newFlagSet(&a, defaultRepository)
+	if err := f.Parse(args); err != nil {
+		return a, err
 	}
 
-	sha := defaultCommit
 	if commits := f.Args(); len(commits) == 1 {
-		sha = commits[0]
+		a.commit = commits[0]
 	} else if len(commits) > 1 {
-		fmt.Fprintln(os.Stderr, "Error: at most one commit can be specified")
-		fmt.Fprintln(os.Stderr, usage)
+		return a, errors.New("at most one commit can be specified")
+	}
+
+	switch {
+	case a.output != "" && a.output != "ndjson":
+		return a, fmt.Errorf("invalid output format %q (expected \"ndjson\")", a.output)
+	case a.output != "" && a.accessible:
+		return a, errors.New("--output and --accessible are mutually exclusive")
+	case a.quiet && (a.output != "" || a.accessible):
+		return a, errors.New("--quiet is mutually exclusive with --output and --accessible")
+	case a.follow && a.output == "":
+		return a, errors.New("--follow requires --output")
+	case a.timeout < 0:
+		return a, errors.New("--timeout must not be negative")
+	case a.timeout > 0 && !a.headless():
+		return a, errors.New("--timeout requires --output, --accessible or --quiet")
+	case a.record != "" && a.replay != "":
+		return a, errors.New("--record and --replay are mutually exclusive")
+	}
+
+	if _, err := tui.ParseFailurePolicy(a.failOn, a.ignore); err != nil {
+		return a, err
+	}
+	if _, err := tui.NewJobFilter(a.jobPatterns(FilterConfiguration{}), nil); err != nil {
+		return a, err
+	}
+
+	return a, nil
+}
+
+// Run the pre-push hook of git and return the exit status of citop
+func runHook(args []string) int {
+	a, err := parseHookArguments(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		fmt.Fprintln(os.Stderr, usage())
+		return exitError
+	}
+	policy, err := tui.ParseFailurePolicy(a.failOn, a.ignore)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	repo, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+
+	paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
+	config, err := loadConfiguration(paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	ctx := context.Background()
+	sourceProviders, ciProviders, err := config.Providers.Providers(ctx, http.DefaultTransport)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+
+	err = runPrePushHook(ctx, os.Stderr, os.Stdin, repo, a.remoteURL, ciProviders, sourceProviders, policy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		if err == ErrPushBlocked {
+			return exitPipelinesFailed
+		}
+		return exitError
+	}
+	return 0
+}
+
+func main() {
+	if len(os.Args) == 2 {
+		switch os.Args[1] {
+		case "man":
+			fmt.Print(manualPage())
+			os.Exit(0)
+		case "docs":
+			fmt.Print(manualMarkdown())
+			os.Exit(0)
+		case "doctor":
+			repo, err := os.Getwd()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+				os.Exit(1)
+			}
+			paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
+			if !runDoctor(context.Background(), os.Stdout, repo, paths) {
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case "update":
+			paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
+			config, err := ConfigFromPaths(paths...)
+			if err != nil && err != ErrMissingConf {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := selfUpdate(context.Background(), os.Stdout, http.DefaultClient, config.Update); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+	}
+
+	if len(os.Args) > 2 && os.Args[1] == "hook" && os.Args[2] == "pre-push" {
+		os.Exit(runHook(os.Args[3:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "status" {
+		os.Exit(runStatus(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		os.Exit(runCompare(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bisect" {
+		os.Exit(runBisect(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "grep" {
+		os.Exit(runGrep(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "login" {
+		os.Exit(runLoginCommand(os.Args[2:]))
+	}
+	commandLine := os.Args[1:]
+	if len(os.Args) > 1 && (os.Args[1] == "trigger" || os.Args[1] == "run") {
+		// Monitor the pipelines just started
+		var status int
+		if commandLine, status = runTriggerCommand(os.Args[2:]); status != 0 {
+			os.Exit(status)
+		}
+	}
+
+	defaultCommit := "HEAD"
+	defaultRepository, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
 		os.Exit(1)
 	}
 
-	repo := *repoFlag
-	if repo == defaultRepository {
-		repo = *repoFlagShort
+	args, err := parseArguments(commandLine, defaultRepository, defaultCommit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		fmt.Fprintln(os.Stderr, usage())
+		os.Exit(1)
 	}
 
+	if args.version {
+		fmt.Fprintf(os.Stderr, "citop %s\n", Version)
+		os.Exit(0)
+	}
+
+	if args.help {
+		fmt.Fprintln(os.Stderr, usage())
+		os.Exit(0)
+	}
+
+	sha := args.commit
+	repo := args.repository
+
 	paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
-	config, err := ConfigFromPaths(paths...)
+	config, err := loadConfiguration(paths...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	transport, err := args.transport()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	// Tell the user which data is unavailable instead of letting requests fail
+	var notices []string
+	if args.anonymous {
+		config.Providers.Anonymous = true
+	}
+	if config.Providers.Anonymous {
+		_, notices = config.Providers.anonymous()
+	}
+
+	filterConfiguration := config.Filter
+	filterConfiguration.Jobs = args.jobPatterns(config.Filter)
+	filter, err := filterConfiguration.Filter()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
 
 	ctx := context.Background()
-	sourceProviders, ciProviders, err := config.Providers.Providers(ctx)
+	sourceProviders, ciProviders, err := config.Providers.Providers(ctx, transport)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
-	if err := tui.RunApplication(ctx, tcell.NewScreen, repo, sha, ciProviders, sourceProviders, time.Local, manualPage()); err != nil {
+	if args.headless() {
+		policy, err := tui.ParseFailurePolicy(args.failOn, args.ignore)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(exitError)
+		}
+		if !args.quiet {
+			for _, notice := range notices {
+				fmt.Fprintf(os.Stderr, "anonymous mode: %s\n", notice)
+			}
+		}
+		if args.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, args.timeout)
+			defer cancel()
+		}
+		switch {
+		case args.output == "ndjson":
+			err = tui.RunNDJSON(ctx, os.Stdout, repo, sha, ciProviders, sourceProviders, filter, args.follow, policy)
+		case args.quiet:
+			err = tui.RunQuiet(ctx, os.Stdout, repo, sha, ciProviders, sourceProviders, filter, policy)
+		default:
+			err = tui.RunAccessible(ctx, os.Stdout, repo, sha, ciProviders, sourceProviders, filter, time.Local, policy)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			if _, ok := err.(tui.PolicyError); ok {
+				os.Exit(exitPipelinesFailed)
+			}
+			if err == tui.ErrTimeout {
+				os.Exit(exitTimeout)
+			}
+			os.Exit(exitError)
+		}
+		os.Exit(0)
+	}
+
+	signal.Ignore(syscall.SIGINT)
+	// FIXME Do not ignore SIGTSTP/SIGCONT
+	signal.Ignore(syscall.SIGTSTP)
+
+	styleSheet := tui.MonochromeStyleSheet
+	if !noColor(args.noColor) {
+		if styleSheet, err = config.Style.StyleSheet(); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	}
+
+	icons, err := config.Style.StateIcons()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	header, err := config.Templates.HeaderTemplate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	rowTemplates, err := config.Templates.RowTemplates()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	columns, err := config.Table.OptionalColumns()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	widths, err := config.Table.ColumnWidths()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
+
+	priorities, err := config.Table.ColumnPriorities()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	sortColumn, err := config.Table.SortColumn()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	lineNumbers, err := tui.ParseLineNumbers(config.Table.LineNumbers)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	matchers, err := config.ProblemMatchers.ProblemMatchers()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	followBranch, err := tui.ParseFollowMode(config.Follow.Branch)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	timestamps, err := tui.ParseTimestampMode(config.Logs.Timestamps)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	logPathTemplate, err := tui.NewLogPathTemplate(config.Logs.SavePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	notifications, err := config.Notifications.Notifications(http.DefaultClient)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	publishers := config.MQTT.Publishers()
+
+	// Look for a newer version while the user interface is running and tell the user about
+	// it on exit
+	notice := make(chan string, 1)
+	if !config.Update.DisableCheck {
+		go func() {
+			checkCtx, cancel := context.WithTimeout(ctx, updateCheckTimeout)
+			defer cancel()
+			notice <- newVersionNotice(checkCtx, http.DefaultClient, latestReleaseURL, config.Update)
+		}()
+	}
+
+	options := tui.Options{
+		NewScreen:       tcell.NewScreen,
+		Repository:      repo,
+		Sha:             sha,
+		CIProviders:     ciProviders,
+		SourceProviders: sourceProviders,
+		Filter:          filter,
+		StyleSheet:      styleSheet,
+		Icons:           icons,
+		Header:          header,
+		RowTemplates:    rowTemplates,
+		Columns:         columns,
+		Widths:          widths,
+		Priorities:      priorities,
+		Wrap:            config.Table.Wrap,
+		LineNumbers:     lineNumbers,
+		SortColumn:      sortColumn,
+		Reverse:         config.Table.Reverse,
+		Matchers:        matchers,
+		Timestamps:      timestamps,
+		StripColors:     config.Logs.StripColors || noColor(args.noColor),
+		LogPathTemplate: logPathTemplate,
+		Location:        time.Local,
+		Help:            manualPage(),
+		FollowBranch:    followBranch,
+		Notifications:   notifications,
+		Publishers:      publishers,
+		StatusPages:     config.Providers.StatusPages(transport),
+		Notices:         notices,
+		StateDir:        path.Join(utils.XDGStateHome(), ConfDir),
+	}
+	if err := tui.RunApplication(ctx, options); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	select {
+	case s := <-notice:
+		if s != "" {
+			fmt.Fprintln(os.Stderr, s)
+		}
+	default:
+	}
 }
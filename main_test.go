@@ -2,9 +2,17 @@ package main
 
 import (
 	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
 	"testing"
 
+	"github.com/gdamore/tcell"
 	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/providers"
+	"github.com/nbedos/citop/text"
+	"github.com/nbedos/citop/tui"
 )
 
 func TestConfiguration(t *testing.T) {
@@ -16,6 +24,9 @@ func TestConfiguration(t *testing.T) {
 
 	t.Run("full configuration", func(t *testing.T) {
 		s := `
+			[providers]
+			anonymous = true
+
 			[[providers.gitlab]]
 			url = "https://gitlab.com"
 			token = "token"
@@ -25,15 +36,123 @@ func TestConfiguration(t *testing.T) {
 			url = "https://gitlab.org"
 			token = "token"
 			max_requests_per_second = 1
+
+			[providers.gitlab.headers]
+			X-Proxy-Token = "secret"
 			
 			[[providers.github]]
 			url = "https://github.com"
 			token = "token"
 			max_requests_per_second = 20.2
+
+			[[providers.prow]]
+			url = "https://prow.example.com"
+			storage_url = "https://storage.example.com"
+
+			[[providers.lighthouse]]
+			url = "https://dashboard-jx.example.com"
+			kubernetes_url = "https://kubernetes.example.com"
+			namespace = "jx-staging"
+			token = "token"
+
+			[[providers.buildbot]]
+			url = "https://buildbot.example.com"
+			only = ["nbedos/*"]
+			except = ["nbedos/legacy"]
+
+			[[providers.semaphore]]
+			url = "https://example.semaphoreci.com"
+			token = "token"
+
+			[[providers.concourse]]
+			target = "ci"
+			team = "citop"
+
+			[[providers.codebuild]]
+			region = "eu-west-3"
+			profile = "ci"
+
+			[[providers.cloudbuild]]
+			project = "citop-ci"
+
+			[[providers.gitea]]
+			url = "https://gitea.example.com"
+			token = "token"
+
+			[[providers.bamboo]]
+			url = "https://bamboo.example.com"
+			token = "token"
+
+			[[providers.gerrit]]
+			url = "https://gerrit.example.com"
+			username = "nbedos"
+			token = "token"
+
+			[[providers.webhook]]
+			address = "localhost:8080"
+			token = "secret"
+
+			[[providers.custom]]
+			url = "https://ci.example.com"
+			token = "token"
+			endpoints = { pipelines = "/api/commits/{sha}/pipelines", pipeline = "/api/pipelines/{id}" }
+			fields = { pipelines = "data" }
+			states = { success = "passed" }
+
+			[style]
+			theme = "light"
+
+			[style.sha]
+			foreground = "#ff8700"
+			bold = true
+
+			[style.states.failed]
+			foreground = "red"
+			reverse = true
+
+			[templates]
+			header = "{{ .Sha }}"
+
+			[update]
+			disable_self_update = true
+
+			[logs]
+			timestamps = "relative"
+			strip_colors = true
+			save_path = "logs/{{.Job}}.log"
+
+			[filter]
+			jobs = ["deploy-*", "/^tests$/"]
+			ignore_jobs = ["*-canary"]
+			ignore_pipelines = ["Scheduled *"]
+
+			[[notifications.slack]]
+			url = "https://hooks.slack.com/services/T0/B0/X"
+			on = "failed"
+
+			[[notifications.matrix]]
+			url = "https://matrix.org"
+			room = "!room:matrix.org"
+			token = "token"
+
+			[[notifications.email]]
+			host = "smtp.example.com"
+			port = 25
+			from = "citop@example.com"
+			to = ["alice@example.com", "bob@example.com"]
+
+			[mqtt]
+			broker = "tcp://localhost:1883"
+			topic = "ci/citop"
+
+			[[problem_matchers]]
+			name = "eslint"
+			pattern = '^(?P<file>\S+): line (?P<line>\d+), (?P<message>.+)$'
 		`
 
 		expected := Configuration{
 			Providers: ProvidersConfiguration{
+				Anonymous: true,
 				GitLab: []ProviderConfiguration{
 					{
 						Url:               "https://gitlab.com",
@@ -44,6 +163,9 @@ func TestConfiguration(t *testing.T) {
 						Url:               "https://gitlab.org",
 						Token:             "token",
 						RequestsPerSecond: 1,
+						Headers: map[string]string{
+							"X-Proxy-Token": "secret",
+						},
 					},
 				},
 				GitHub: []ProviderConfiguration{
@@ -53,6 +175,149 @@ func TestConfiguration(t *testing.T) {
 						RequestsPerSecond: 20.2,
 					},
 				},
+				Prow: []ProviderConfiguration{
+					{
+						Url:        "https://prow.example.com",
+						StorageURL: "https://storage.example.com",
+					},
+				},
+				Lighthouse: []ProviderConfiguration{
+					{
+						Url:           "https://dashboard-jx.example.com",
+						KubernetesURL: "https://kubernetes.example.com",
+						Namespace:     "jx-staging",
+						Token:         "token",
+					},
+				},
+				Buildbot: []ProviderConfiguration{
+					{
+						Url:    "https://buildbot.example.com",
+						Only:   []string{"nbedos/*"},
+						Except: []string{"nbedos/legacy"},
+					},
+				},
+				Semaphore: []ProviderConfiguration{
+					{
+						Url:   "https://example.semaphoreci.com",
+						Token: "token",
+					},
+				},
+				Concourse: []ProviderConfiguration{
+					{
+						Target: "ci",
+						Team:   "citop",
+					},
+				},
+				CodeBuild: []ProviderConfiguration{
+					{
+						Region:  "eu-west-3",
+						Profile: "ci",
+					},
+				},
+				CloudBuild: []ProviderConfiguration{
+					{
+						Project: "citop-ci",
+					},
+				},
+				Gitea: []ProviderConfiguration{
+					{
+						Url:   "https://gitea.example.com",
+						Token: "token",
+					},
+				},
+				Bamboo: []ProviderConfiguration{
+					{
+						Url:   "https://bamboo.example.com",
+						Token: "token",
+					},
+				},
+				Gerrit: []ProviderConfiguration{
+					{
+						Url:      "https://gerrit.example.com",
+						Username: "nbedos",
+						Token:    "token",
+					},
+				},
+				Webhook: []ProviderConfiguration{
+					{
+						Address: "localhost:8080",
+						Token:   "secret",
+					},
+				},
+				Custom: []ProviderConfiguration{
+					{
+						Url:   "https://ci.example.com",
+						Token: "token",
+						Endpoints: map[string]string{
+							"pipelines": "/api/commits/{sha}/pipelines",
+							"pipeline":  "/api/pipelines/{id}",
+						},
+						Fields: map[string]string{"pipelines": "data"},
+						States: map[string]string{"success": "passed"},
+					},
+				},
+			},
+			Style: StyleConfiguration{
+				Theme: "light",
+				Sha: &ElementStyle{
+					Foreground: "#ff8700",
+					Bold:       true,
+				},
+				States: StateStyles{
+					Failed: &ElementStyle{
+						Foreground: "red",
+						Reverse:    true,
+					},
+				},
+			},
+			Templates: TemplatesConfiguration{
+				Header: "{{ .Sha }}",
+			},
+			Update: UpdateConfiguration{
+				DisableSelfUpdate: true,
+			},
+			Logs: LogsConfiguration{
+				Timestamps:  "relative",
+				StripColors: true,
+				SavePath:    "logs/{{.Job}}.log",
+			},
+			Filter: FilterConfiguration{
+				Jobs:            []string{"deploy-*", "/^tests$/"},
+				IgnoreJobs:      []string{"*-canary"},
+				IgnorePipelines: []string{"Scheduled *"},
+			},
+			Notifications: NotificationsConfiguration{
+				Slack: []WebhookConfiguration{
+					{
+						Url: "https://hooks.slack.com/services/T0/B0/X",
+						On:  "failed",
+					},
+				},
+				Matrix: []MatrixConfiguration{
+					{
+						Url:   "https://matrix.org",
+						Room:  "!room:matrix.org",
+						Token: "token",
+					},
+				},
+				Email: []EmailConfiguration{
+					{
+						Host: "smtp.example.com",
+						Port: 25,
+						From: "citop@example.com",
+						To:   []string{"alice@example.com", "bob@example.com"},
+					},
+				},
+			},
+			MQTT: MQTTConfiguration{
+				Broker: "tcp://localhost:1883",
+				Topic:  "ci/citop",
+			},
+			ProblemMatchers: ProblemMatchersConfiguration{
+				{
+					Name:    "eslint",
+					Pattern: `^(?P<file>\S+): line (?P<line>\d+), (?P<message>.+)$`,
+				},
 			},
 		}
 
@@ -72,3 +337,570 @@ func TestConfiguration(t *testing.T) {
 		}
 	})
 }
+
+func TestStyleConfiguration_StyleSheet(t *testing.T) {
+	for _, theme := range []string{"dark", "light", "Light"} {
+		t.Run(theme, func(t *testing.T) {
+			c := StyleConfiguration{Theme: theme}
+			if _, err := c.StyleSheet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	t.Run("invalid theme", func(t *testing.T) {
+		c := StyleConfiguration{Theme: "solarized"}
+		if _, err := c.StyleSheet(); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+
+	t.Run("element style overrides theme", func(t *testing.T) {
+		c := StyleConfiguration{
+			Theme: "dark",
+			Sha: &ElementStyle{
+				Foreground: "#ff8700",
+				Underline:  true,
+			},
+		}
+		styleSheet, err := c.StyleSheet()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := tcell.StyleDefault.Foreground(tcell.NewRGBColor(0xff, 0x87, 0x00)).Underline(true)
+		if style := styleSheet[text.GitSha](tcell.StyleDefault); style != expected {
+			t.Fatalf("expected %v but got %v", expected, style)
+		}
+
+		// The theme must be left untouched
+		if style := tui.DefaultStyleSheet[text.GitSha](tcell.StyleDefault); style == expected {
+			t.Fatal("theme was modified")
+		}
+	})
+
+	t.Run("state style overrides theme", func(t *testing.T) {
+		c := StyleConfiguration{
+			Theme: "light",
+			States: StateStyles{
+				Manual: &ElementStyle{Foreground: "purple", Bold: true},
+			},
+		}
+		styleSheet, err := c.StyleSheet()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := tcell.StyleDefault.Foreground(tcell.ColorPurple).Bold(true)
+		if style := styleSheet[text.StatusManual](tcell.StyleDefault); style != expected {
+			t.Fatalf("expected %v but got %v", expected, style)
+		}
+	})
+
+	t.Run("invalid element color", func(t *testing.T) {
+		c := StyleConfiguration{
+			Theme: "dark",
+			Head:  &ElementStyle{Background: "blurple"},
+		}
+		if _, err := c.StyleSheet(); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
+
+func TestTemplatesConfiguration_HeaderTemplate(t *testing.T) {
+	t.Run("default header", func(t *testing.T) {
+		tmpl, err := TemplatesConfiguration{}.HeaderTemplate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tmpl != nil {
+			t.Fatalf("expected nil template but got %v", tmpl)
+		}
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		if _, err := (TemplatesConfiguration{Header: "{{ .Sha "}).HeaderTemplate(); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
+
+func TestTemplatesConfiguration_RowTemplates(t *testing.T) {
+	t.Run("default templates", func(t *testing.T) {
+		templates, err := TemplatesConfiguration{}.RowTemplates()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if templates.Pipeline != nil || templates.Stage != nil || templates.Job != nil {
+			t.Fatalf("expected nil templates but got %+v", templates)
+		}
+	})
+
+	t.Run("job template", func(t *testing.T) {
+		templates, err := TemplatesConfiguration{Job: "{{ .Stage }}: {{ .Name }}"}.RowTemplates()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if templates.Job == nil {
+			t.Fatal("expected job template but got nil")
+		}
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		if _, err := (TemplatesConfiguration{Stage: "{{ .Duration }}"}).RowTemplates(); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
+
+func TestTableConfiguration_OptionalColumns(t *testing.T) {
+	t.Run("default columns", func(t *testing.T) {
+		columns, err := TableConfiguration{}.OptionalColumns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(columns) != 0 {
+			t.Fatalf("expected no column but got %v", columns)
+		}
+	})
+
+	t.Run("case insensitive names", func(t *testing.T) {
+		columns, err := TableConfiguration{Columns: []string{"os", "Language"}}.OptionalColumns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"OS", "LANGUAGE"}, columns); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("invalid column", func(t *testing.T) {
+		if _, err := (TableConfiguration{Columns: []string{"compiler"}}).OptionalColumns(); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
+
+func TestTableConfiguration_ColumnWidths(t *testing.T) {
+	t.Run("case insensitive names", func(t *testing.T) {
+		c := TableConfiguration{Widths: map[string]string{"name": "40%", "Created": "12", "REF": "auto"}}
+		widths, err := c.ColumnWidths()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]tui.ColumnWidth{
+			"NAME":    {Value: 40, Percent: true},
+			"CREATED": {Value: 12},
+			"REF":     tui.AutoWidth,
+		}
+		if diff := cmp.Diff(expected, widths); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("invalid column", func(t *testing.T) {
+		if _, err := (TableConfiguration{Widths: map[string]string{"compiler": "10"}}).ColumnWidths(); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+
+	t.Run("invalid width", func(t *testing.T) {
+		if _, err := (TableConfiguration{Widths: map[string]string{"name": "wide"}}).ColumnWidths(); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+
+	t.Run("minimum widths", func(t *testing.T) {
+		c := TableConfiguration{
+			Widths:    map[string]string{"ref": "10%"},
+			MinWidths: map[string]int{"ref": 8, "name": 30},
+		}
+		widths, err := c.ColumnWidths()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]tui.ColumnWidth{
+			"REF":  {Value: 10, Percent: true, Min: 8},
+			"NAME": {Min: 30},
+		}
+		if diff := cmp.Diff(expected, widths); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestTableConfiguration_SortColumn(t *testing.T) {
+	testCases := []struct {
+		sort     string
+		expected string
+	}{
+		{"", ""},
+		{"duration", "DURATION"},
+		{"Created", "CREATED"},
+	}
+	for _, testCase := range testCases {
+		column, err := TableConfiguration{Sort: testCase.sort}.SortColumn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if column != testCase.expected {
+			t.Fatalf("expected %q but got %q", testCase.expected, column)
+		}
+	}
+
+	if _, err := (TableConfiguration{Sort: "compiler"}).SortColumn(); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+}
+
+func TestTableConfiguration_ColumnPriorities(t *testing.T) {
+	priorities, err := TableConfiguration{Priorities: map[string]int{"eta": 10}}.ColumnPriorities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]int{"ETA": 10}, priorities); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	if _, err := (TableConfiguration{Priorities: map[string]int{"compiler": 1}}).ColumnPriorities(); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+}
+
+func TestStyleConfiguration_StateIcons(t *testing.T) {
+	testCases := []struct {
+		icons  string
+		lang   string
+		result tui.StateIcons
+	}{
+		{icons: "", lang: "en_US.UTF-8", result: nil},
+		{icons: "none", lang: "en_US.UTF-8", result: nil},
+		{icons: "ascii", lang: "en_US.UTF-8", result: tui.ASCIIStateIcons},
+		{icons: "unicode", lang: "en_US.UTF-8", result: tui.UnicodeStateIcons},
+		{icons: "unicode", lang: "C", result: tui.ASCIIStateIcons},
+		{icons: "nerdfont", lang: "fr_FR.utf8", result: tui.NerdFontStateIcons},
+		{icons: "nerdfont", lang: "", result: tui.ASCIIStateIcons},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.icons+"/"+testCase.lang, func(t *testing.T) {
+			for _, name := range []string{"LC_ALL", "LC_CTYPE"} {
+				defer os.Setenv(name, os.Getenv(name))
+				if err := os.Unsetenv(name); err != nil {
+					t.Fatal(err)
+				}
+			}
+			defer os.Setenv("LANG", os.Getenv("LANG"))
+			if err := os.Setenv("LANG", testCase.lang); err != nil {
+				t.Fatal(err)
+			}
+
+			icons, err := StyleConfiguration{Icons: testCase.icons}.StateIcons()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.result, icons); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("invalid icon set", func(t *testing.T) {
+		if _, err := (StyleConfiguration{Icons: "emoji"}).StateIcons(); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
+
+func TestParseColor(t *testing.T) {
+	testCases := []struct {
+		s     string
+		color tcell.Color
+	}{
+		{s: "", color: tcell.ColorDefault},
+		{s: "red", color: tcell.ColorRed},
+		{s: "DarkCyan", color: tcell.ColorDarkCyan},
+		{s: "208", color: tcell.Color208},
+		{s: "#ff8700", color: tcell.NewRGBColor(0xff, 0x87, 0x00)},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.s, func(t *testing.T) {
+			color, err := parseColor(testCase.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if color != testCase.color {
+				t.Fatalf("expected %v but got %v", testCase.color, color)
+			}
+		})
+	}
+
+	for _, s := range []string{"reddish", "256", "-1", "#ff87", "#gg8700"} {
+		t.Run(s, func(t *testing.T) {
+			if _, err := parseColor(s); err == nil {
+				t.Fatal("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestNoColor(t *testing.T) {
+	testCases := []struct {
+		name   string
+		env    string
+		flag   bool
+		result bool
+	}{
+		{name: "default", env: "", flag: false, result: false},
+		{name: "flag", env: "", flag: true, result: true},
+		{name: "environment", env: "1", flag: false, result: true},
+		{name: "flag and environment", env: "1", flag: true, result: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := os.Setenv("NO_COLOR", testCase.env); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Unsetenv("NO_COLOR")
+
+			if result := noColor(testCase.flag); result != testCase.result {
+				t.Fatalf("expected %v but got %v", testCase.result, result)
+			}
+		})
+	}
+}
+
+func TestProviderConfiguration_header(t *testing.T) {
+	defer func(version string) { Version = version }(Version)
+	Version = "0.2.0"
+	c := ProviderConfiguration{
+		Headers: map[string]string{
+			"x-proxy-token": "secret",
+		},
+	}
+	header := c.header()
+	if value := header.Get("User-Agent"); value != "citop/0.2.0" {
+		t.Fatalf("expected User-Agent %q but got %q", "citop/0.2.0", value)
+	}
+	if value := header.Get("X-Proxy-Token"); value != "secret" {
+		t.Fatalf("expected X-Proxy-Token %q but got %q", "secret", value)
+	}
+
+	c.Headers["User-Agent"] = "curl/7.67.0"
+	if value := c.header().Get("User-Agent"); value != "curl/7.67.0" {
+		t.Fatalf("expected User-Agent %q but got %q", "curl/7.67.0", value)
+	}
+}
+
+func TestProviderConfiguration_statusPolicy(t *testing.T) {
+	t.Run("default policy", func(t *testing.T) {
+		policy, err := ProviderConfiguration{}.statusPolicy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(providers.StatusPolicy{}, policy); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("prefer checks", func(t *testing.T) {
+		c := ProviderConfiguration{Prefer: "Checks", HideStatuses: []string{"ci/circleci:*"}}
+		policy, err := c.statusPolicy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := providers.StatusPolicy{
+			Prefer:         providers.PreferChecks,
+			HiddenContexts: []string{"ci/circleci:*"},
+		}
+		if diff := cmp.Diff(expected, policy); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("invalid preference", func(t *testing.T) {
+		if _, err := (ProviderConfiguration{Prefer: "latest"}).statusPolicy(); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		if _, err := (ProviderConfiguration{HideStatuses: []string{"ci/["}}).statusPolicy(); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
+
+func TestProviderConfiguration_owners(t *testing.T) {
+	owners, err := ProviderConfiguration{Owners: []string{"acme", "acme-*"}}.owners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(providers.OwnerPatterns{"acme", "acme-*"}, owners); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	if _, err := (ProviderConfiguration{Owners: []string{"acme-["}}).owners(); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+}
+
+func TestProviderConfiguration_repositories(t *testing.T) {
+	repositories, err := ProviderConfiguration{Only: []string{"acme/*"}, Except: []string{"acme/legacy"}}.repositories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := providers.RepositoryPatterns{Only: []string{"acme/*"}, Except: []string{"acme/legacy"}}
+	if diff := cmp.Diff(expected, repositories); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	for _, c := range []ProviderConfiguration{{Only: []string{"acme/["}}, {Except: []string{"acme/["}}} {
+		if _, err := c.repositories(); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	}
+}
+
+func TestProviderConfiguration_withFlyTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	flyrc := path.Join(dir, ".flyrc")
+	s := `targets:
+  ci:
+    api: https://ci.example.com
+    team: main
+    token:
+      type: bearer
+      value: fly_token
+`
+	if err := ioutil.WriteFile(flyrc, []byte(s), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("keys left empty", func(t *testing.T) {
+		c, err := ProviderConfiguration{Target: "ci"}.withFlyTarget(flyrc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ProviderConfiguration{Target: "ci", Url: "https://ci.example.com", Team: "main", Token: "fly_token"}
+		if diff := cmp.Diff(expected, c); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("keys of the configuration file take precedence", func(t *testing.T) {
+		c, err := ProviderConfiguration{Target: "ci", Team: "citop", Token: "token"}.withFlyTarget(flyrc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ProviderConfiguration{Target: "ci", Url: "https://ci.example.com", Team: "citop", Token: "token"}
+		if diff := cmp.Diff(expected, c); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("unknown target", func(t *testing.T) {
+		if _, err := (ProviderConfiguration{Target: "other"}).withFlyTarget(flyrc); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
+
+func TestProvidersConfiguration_StatusPages(t *testing.T) {
+	c := ProvidersConfiguration{
+		GitHub: []ProviderConfiguration{{}, {Token: "token"}},
+		GitLab: []ProviderConfiguration{{StatusURL: "none"}},
+		Travis: []ProviderConfiguration{{Url: "org"}, {Url: "https://travis.example.com"}},
+		Azure:  []ProviderConfiguration{{Name: "devops", StatusURL: "https://status.example.com/api/v2/status.json"}},
+	}
+
+	names := make([]string, 0)
+	for _, page := range c.StatusPages(nil) {
+		names = append(names, page.Name())
+	}
+	expected := []string{"github", "travis", "devops"}
+	if diff := cmp.Diff(expected, names); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestProvidersConfiguration_anonymous(t *testing.T) {
+	c := ProvidersConfiguration{
+		GitHub:    []ProviderConfiguration{{Token: "token", Owners: []string{"nbedos"}}},
+		GitLab:    []ProviderConfiguration{{Name: "work", Url: "https://gitlab.example.com", Token: "token"}, {}},
+		AppVeyor:  []ProviderConfiguration{{Token: "token"}},
+		CodeBuild: []ProviderConfiguration{{Region: "eu-west-1"}},
+		Gerrit:    []ProviderConfiguration{{Url: "https://gerrit.example.com", Username: "nbedos", Token: "password"}},
+		Webhook:   []ProviderConfiguration{{Address: "localhost:8080", Token: "secret"}},
+	}
+
+	anonymous, notes := c.anonymous()
+	expected := ProvidersConfiguration{
+		GitHub:  []ProviderConfiguration{{Owners: []string{"nbedos"}}},
+		GitLab:  []ProviderConfiguration{{Name: "work", Url: "https://gitlab.example.com"}, {}},
+		Gerrit:  []ProviderConfiguration{{Url: "https://gerrit.example.com"}},
+		Webhook: []ProviderConfiguration{{Address: "localhost:8080", Token: "secret"}},
+	}
+	if diff := cmp.Diff(expected, anonymous); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	expectedNotes := []string{
+		"work: credentials ignored, private projects are unavailable",
+		"github: limited to 60 requests per hour, private repositories and logs of GitHub Actions unavailable",
+		"appveyor: skipped since AppVeyor requires a token",
+		"codebuild: skipped since AWS CodeBuild requires AWS credentials",
+		"gerrit: credentials ignored, changes restricted to authenticated users are unavailable",
+	}
+	if diff := cmp.Diff(expectedNotes, notes); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	// The original configuration is left untouched
+	if c.GitHub[0].Token != "token" || c.Gerrit[0].Username != "nbedos" {
+		t.Fatal("expected credentials of the original configuration to be kept")
+	}
+}
+
+func TestNotificationsConfiguration_Notifications(t *testing.T) {
+	t.Run("default states", func(t *testing.T) {
+		c := NotificationsConfiguration{
+			Discord: []WebhookConfiguration{{Url: "https://discord.com/api/webhooks/0/x"}},
+		}
+		notifications, err := c.Notifications(http.DefaultClient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notifications) != 1 {
+			t.Fatalf("expected 1 notification but got %d", len(notifications))
+		}
+		expected := map[cache.State]bool{
+			cache.Passed:   true,
+			cache.Failed:   true,
+			cache.Canceled: true,
+		}
+		if diff := cmp.Diff(expected, notifications[0].States); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	for name, c := range map[string]NotificationsConfiguration{
+		"missing url":       {Slack: []WebhookConfiguration{{On: "failed"}}},
+		"invalid state":     {Slack: []WebhookConfiguration{{Url: "https://example.com", On: "broken"}}},
+		"missing token":     {Matrix: []MatrixConfiguration{{Url: "https://matrix.org", Room: "!room:matrix.org"}}},
+		"missing recipient": {Email: []EmailConfiguration{{Host: "smtp.example.com", From: "citop@example.com"}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := c.Notifications(http.DefaultClient); err == nil {
+				t.Fatal("expected error but got nil")
+			}
+		})
+	}
+}
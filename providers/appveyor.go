@@ -23,6 +23,7 @@ type AppVeyorClient struct {
 	rateLimiter <-chan time.Time
 	token       string
 	provider    cache.Provider
+	repositoryRestriction
 }
 
 var appVeyorURL = url.URL{
@@ -32,10 +33,10 @@ var appVeyorURL = url.URL{
 	RawPath: "/api",
 }
 
-func NewAppVeyorClient(id string, name string, token string, rateLimit time.Duration) AppVeyorClient {
+func NewAppVeyorClient(id string, name string, token string, rateLimit time.Duration, options ...ClientOption) AppVeyorClient {
 	return AppVeyorClient{
 		url:         appVeyorURL,
-		client:      &http.Client{Timeout: 10 * time.Second},
+		client:      newHTTPClient(requestTimeout, options),
 		rateLimiter: time.Tick(rateLimit),
 		token:       token,
 		provider: cache.Provider{
@@ -49,6 +50,26 @@ func (c AppVeyorClient) ID() string {
 	return c.provider.ID
 }
 
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c AppVeyorClient) WithRepositories(repositories RepositoryPatterns) AppVeyorClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// CheckAuthentication returns an error if AppVeyor rejects the API token
+func (c AppVeyorClient) CheckAuthentication(ctx context.Context) error {
+	endpoint := c.url
+	endpoint.Path += "/projects"
+	endpoint.RawPath += "/projects"
+
+	body, err := c.get(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
 func (c AppVeyorClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
 	endpoint := c.url
 	endpoint.Path += fmt.Sprintf("/buildjobs/%s/log", jobID)
@@ -97,7 +118,12 @@ func (c AppVeyorClient) getJSON(ctx context.Context, u url.URL, v interface{}) e
 }
 
 func (c AppVeyorClient) get(ctx context.Context, u url.URL) (io.ReadCloser, error) {
-	req, err := http.NewRequest("GET", u.String(), nil)
+	return c.send(ctx, "GET", u)
+}
+
+// Send a request without payload and return the body of the response
+func (c AppVeyorClient) send(ctx context.Context, method string, u url.URL) (io.ReadCloser, error) {
+	req, err := http.NewRequest(method, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -131,12 +157,18 @@ func (c AppVeyorClient) get(ctx context.Context, u url.URL) (io.ReadCloser, erro
 	return resp.Body, err
 }
 
-func (c AppVeyorClient) fetchBuild(ctx context.Context, owner string, repoName string, id int) (cache.Build, error) {
-	// We only have the build ID and need a build object. We have to query two endpoints:
-	// 		1. /projects/owner/repoName/history with startBuildId = id gives us a build object with
-	//      an empty job list but with a version number
-	//      2. /projects/owner/repoName/build/<version> using the version number from the last call
-	//      gives us a build with a complete job list
+type appVeyorHistory struct {
+	Project struct {
+		ID    int    `json:"projectId"`
+		Owner string `json:"accountName"`
+		Name  string `json:"name"`
+	}
+	Builds []appVeyorBuild `json:"builds"`
+}
+
+// Return the history of the project starting at build 'id'. The build it describes has an empty
+// job list but gives the version number of the build.
+func (c AppVeyorClient) history(ctx context.Context, owner string, repoName string, id int) (appVeyorHistory, error) {
 	history := c.url
 	historyFormat := "/projects/%s/%s/history"
 	history.Path += fmt.Sprintf(historyFormat, owner, repoName)
@@ -146,23 +178,29 @@ func (c AppVeyorClient) fetchBuild(ctx context.Context, owner string, repoName s
 	params.Add("startBuildId", strconv.Itoa(id+1))
 	history.RawQuery = params.Encode()
 
-	var b struct {
-		Project struct {
-			ID    int    `json:"projectId"`
-			Owner string `json:"accountName"`
-			Name  string `json:"name"`
-		}
-		Builds []appVeyorBuild `json:"builds"`
-	}
+	var b appVeyorHistory
 	if err := c.getJSON(ctx, history, &b); err != nil {
-		return cache.Build{}, err
+		return b, err
 	}
 
 	if len(b.Builds) != 1 {
-		return cache.Build{}, fmt.Errorf("found no build with id %d", id)
+		return b, fmt.Errorf("found no build with id %d", id)
 	}
 	if b.Builds[0].ID != id {
-		return cache.Build{}, fmt.Errorf("expected build #%d but got %d", id, b.Builds[0].ID)
+		return b, fmt.Errorf("expected build #%d but got %d", id, b.Builds[0].ID)
+	}
+	return b, nil
+}
+
+func (c AppVeyorClient) fetchBuild(ctx context.Context, owner string, repoName string, id int) (cache.Build, error) {
+	// We only have the build ID and need a build object. We have to query two endpoints:
+	// 		1. /projects/owner/repoName/history with startBuildId = id gives us a build object with
+	//      an empty job list but with a version number
+	//      2. /projects/owner/repoName/build/<version> using the version number from the last call
+	//      gives us a build with a complete job list
+	b, err := c.history(ctx, owner, repoName, id)
+	if err != nil {
+		return cache.Build{}, err
 	}
 	version := b.Builds[0].Version
 
@@ -189,6 +227,35 @@ func (c AppVeyorClient) fetchBuild(ctx context.Context, owner string, repoName s
 	return bVersion.Build.toCacheBuild(c.provider.ID, &repository)
 }
 
+// CancelBuild cancels the build along with its active jobs
+func (c AppVeyorClient) CancelBuild(ctx context.Context, build cache.Build) error {
+	owner, repoName, id, err := parseAppVeyorURL(build.WebURL)
+	if err != nil {
+		return err
+	}
+	// Builds are canceled by version
+	b, err := c.history(ctx, owner, repoName, id)
+	if err != nil {
+		return err
+	}
+
+	endpoint := c.url
+	pathFormat := "/builds/%s/%s/%s"
+	endpoint.Path += fmt.Sprintf(pathFormat, owner, repoName, b.Builds[0].Version)
+	endpoint.RawPath += fmt.Sprintf(pathFormat, url.PathEscape(owner), url.PathEscape(repoName),
+		url.PathEscape(b.Builds[0].Version))
+	body, err := c.send(ctx, "DELETE", endpoint)
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+// CancelJob is not supported since AppVeyor only cancels whole builds
+func (c AppVeyorClient) CancelJob(ctx context.Context, build cache.Build, job cache.Job) error {
+	return cache.ErrUnsupportedAction
+}
+
 // Extract owner, repository and build ID from web URL of build
 func parseAppVeyorURL(u string) (string, string, int, error) {
 	v, err := url.Parse(u)
@@ -309,6 +376,7 @@ func (b appVeyorBuild) toCacheBuild(accountID string, repo *cache.Repository) (c
 type appVeyorJob struct {
 	ID           string `json:"jobId"`
 	Name         string `json:"name"`
+	OSType       string `json:"osType"`
 	AllowFailure bool   `json:"allowFailure"`
 	Status       string `json:"status"`
 	CreatedAt    string `json:"created"`
@@ -326,6 +394,7 @@ func (j appVeyorJob) toCacheJob(id string, buildURL string) (cache.Job, error) {
 		Name:         j.Name,
 		WebURL:       fmt.Sprintf("%s/job/%s", buildURL, url.PathEscape(j.ID)),
 		AllowFailure: j.AllowFailure,
+		OS:           j.OSType,
 	}
 
 	var err error
@@ -239,3 +239,47 @@ func TestCircleCIClient_Log(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestAppVeyorClient_CancelBuild(t *testing.T) {
+	canceled := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/projects/nbedos/citop/history":
+			bs, err := ioutil.ReadFile("test_data/appveyor_history_29070120.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(bs)
+		case r.Method == "DELETE" && r.URL.Path == "/api/builds/nbedos/citop/1.0.22":
+			canceled = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	tsu, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsu.Path += "/api"
+	tsu.RawPath += "/api"
+	client := NewAppVeyorClient("id", "name", "token", time.Millisecond)
+	client.url = *tsu
+
+	build := cache.Build{
+		ID:     "29070120",
+		WebURL: "https://ci.appveyor.com/project/nbedos/citop/builds/29070120",
+	}
+	if err := client.CancelBuild(context.Background(), build); err != nil {
+		t.Fatal(err)
+	}
+	if !canceled {
+		t.Fatal("expected the build to be canceled")
+	}
+
+	if err := client.CancelJob(context.Background(), build, cache.Job{ID: "1"}); err != cache.ErrUnsupportedAction {
+		t.Fatalf("expected %v but got %v", cache.ErrUnsupportedAction, err)
+	}
+}
@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -28,6 +29,7 @@ type AzurePipelinesClient struct {
 	version       string
 	logURLByJobID map[string]url.URL
 	mux           *sync.Mutex
+	repositoryRestriction
 }
 
 var azureURL = url.URL{
@@ -35,10 +37,10 @@ var azureURL = url.URL{
 	Host:   "dev.azure.com",
 }
 
-func NewAzurePipelinesClient(id string, name string, token string, rateLimit time.Duration) AzurePipelinesClient {
+func NewAzurePipelinesClient(id string, name string, token string, rateLimit time.Duration, options ...ClientOption) AzurePipelinesClient {
 	return AzurePipelinesClient{
 		baseURL:     azureURL,
-		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		httpClient:  newHTTPClient(requestTimeout, options),
 		rateLimiter: time.Tick(rateLimit),
 		token:       token,
 		provider: cache.Provider{
@@ -55,6 +57,26 @@ func (c AzurePipelinesClient) ID() string {
 	return c.provider.ID
 }
 
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c AzurePipelinesClient) WithRepositories(repositories RepositoryPatterns) AzurePipelinesClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// CheckAuthentication returns an error if Azure DevOps rejects the API token. Requests without
+// token are accepted since public projects can be accessed anonymously.
+func (c AzurePipelinesClient) CheckAuthentication(ctx context.Context) error {
+	endpoint := c.baseURL
+	endpoint.Path += "/_apis/connectionData"
+
+	body, err := c.get(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
 func (c AzurePipelinesClient) parseAzureWebURL(s string) (string, string, string, error) {
 	// https://dev.azure.com/nicolasbedos/5190ee7b-d826-445e-b19e-6dc098be0436/_build/results?buildId=16
 	u, err := url.Parse(s)
@@ -88,6 +110,270 @@ func (c AzurePipelinesClient) BuildFromURL(ctx context.Context, u string) (cache
 	return c.fetchBuild(ctx, owner, repo, buildID)
 }
 
+// CancelBuild cancels the build along with its active jobs
+func (c AzurePipelinesClient) CancelBuild(ctx context.Context, build cache.Build) error {
+	owner, repo, id, err := c.parseAzureWebURL(build.WebURL)
+	if err != nil {
+		return err
+	}
+	u := c.baseURL
+	u.Path += fmt.Sprintf("/%s/%s/_apis/build/builds/%s", owner, repo, id)
+	body, err := c.send(ctx, "PATCH", u, []byte(`{"status": "cancelling"}`))
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+// RestartBuild retries the failed jobs of the build and returns its web page
+func (c AzurePipelinesClient) RestartBuild(ctx context.Context, build cache.Build) (string, error) {
+	owner, repo, id, err := c.parseAzureWebURL(build.WebURL)
+	if err != nil {
+		return "", err
+	}
+	u := c.baseURL
+	u.Path += fmt.Sprintf("/%s/%s/_apis/build/builds/%s", owner, repo, id)
+	params := u.Query()
+	params.Add("retry", "true")
+	u.RawQuery = params.Encode()
+	body, err := c.send(ctx, "PATCH", u, []byte("{}"))
+	if err != nil {
+		return "", err
+	}
+	return build.WebURL, body.Close()
+}
+
+// Return the organization, the project and the name of the Azure Repos repository at
+// 'repositoryURL', e.g. "https://dev.azure.com/organization/project/_git/repository"
+func (c AzurePipelinesClient) parseRepositoryURL(repositoryURL string) (string, string, string, error) {
+	u, err := url.Parse(strings.TrimSuffix(repositoryURL, ".git"))
+	if err != nil || u.Hostname() != c.baseURL.Hostname() {
+		return "", "", "", cache.ErrUnknownURL
+	}
+	// Clone URLs may include the name of the user, e.g. "https://organization@dev.azure.com/..."
+	cs := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(cs) != 4 || cs[2] != "_git" {
+		return "", "", "", cache.ErrUnknownURL
+	}
+	return cs[0], cs[1], cs[3], nil
+}
+
+// TriggerPipeline queues a build of each definition of the project building the repository at
+// 'repositoryURL'. The build runs on the branch 'ref', or on 'ref' itself if it starts with
+// "refs/" such as "refs/tags/v1.0". The web page of the build is returned if a single definition
+// builds the repository, the list of the builds of the project otherwise.
+func (c AzurePipelinesClient) TriggerPipeline(ctx context.Context, repositoryURL string, ref string, variables []cache.Variable) (string, error) {
+	owner, project, repo, err := c.parseRepositoryURL(repositoryURL)
+	if err != nil {
+		return "", err
+	}
+
+	u := c.baseURL
+	u.Path += fmt.Sprintf("/%s/%s/_apis/build/definitions", owner, project)
+	params := u.Query()
+	params.Add("includeAllProperties", "true")
+	u.RawQuery = params.Encode()
+	definitions := struct {
+		Value []struct {
+			ID         int `json:"id"`
+			Repository struct {
+				Name string `json:"name"`
+				Type string `json:"type"`
+			} `json:"repository"`
+		} `json:"value"`
+	}{}
+	if err := c.getJSON(ctx, u, &definitions); err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == 404 {
+			return "", cache.ErrRepositoryNotFound
+		}
+		return "", err
+	}
+
+	request := struct {
+		Definition struct {
+			ID int `json:"id"`
+		} `json:"definition"`
+		SourceBranch string `json:"sourceBranch"`
+		Parameters   string `json:"parameters,omitempty"`
+	}{
+		SourceBranch: ref,
+	}
+	if !strings.HasPrefix(ref, "refs/") {
+		request.SourceBranch = "refs/heads/" + ref
+	}
+	if len(variables) > 0 {
+		parameters := make(map[string]string, len(variables))
+		for _, v := range variables {
+			parameters[v.Name] = v.Value
+		}
+		bs, err := json.Marshal(parameters)
+		if err != nil {
+			return "", err
+		}
+		request.Parameters = string(bs)
+	}
+
+	webURLs := make([]string, 0)
+	for _, definition := range definitions.Value {
+		if definition.Repository.Type != "TfsGit" || !strings.EqualFold(definition.Repository.Name, repo) {
+			continue
+		}
+		request.Definition.ID = definition.ID
+		payload, err := json.Marshal(request)
+		if err != nil {
+			return "", err
+		}
+		u := c.baseURL
+		u.Path += fmt.Sprintf("/%s/%s/_apis/build/builds", owner, project)
+		body, err := c.send(ctx, "POST", u, payload)
+		if err != nil {
+			return "", fmt.Errorf("definition %d: %v", definition.ID, err)
+		}
+		var build azureBuild
+		err = json.NewDecoder(body).Decode(&build)
+		if errClose := body.Close(); err == nil {
+			err = errClose
+		}
+		if err != nil {
+			return "", err
+		}
+		webURLs = append(webURLs, build.Links.Web.Href)
+	}
+
+	switch len(webURLs) {
+	case 0:
+		return "", cache.ErrRepositoryNotFound
+	case 1:
+		return webURLs[0], nil
+	default:
+		u := c.baseURL
+		u.Path += fmt.Sprintf("/%s/%s/_build", owner, project)
+		return u.String(), nil
+	}
+}
+
+// Version of the API managing approvals, which is not available in the version used otherwise
+const azureApprovalsVersion = "7.1-preview.1"
+
+// StartManualJob approves the deployment gated by an approval of the build. Approvals are the
+// only jobs of Azure Pipelines waiting for the action of a user.
+func (c AzurePipelinesClient) StartManualJob(ctx context.Context, build cache.Build, job cache.Job) error {
+	owner, repo, _, err := c.parseAzureWebURL(build.WebURL)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal([]struct {
+		ID      string `json:"approvalId"`
+		Status  string `json:"status"`
+		Comment string `json:"comment"`
+	}{
+		{ID: job.ID, Status: "approved", Comment: "Approved with citop"},
+	})
+	if err != nil {
+		return err
+	}
+
+	u := c.baseURL
+	u.Path += fmt.Sprintf("/%s/%s/_apis/pipelines/approvals", owner, repo)
+	params := u.Query()
+	params.Set("api-version", azureApprovalsVersion)
+	u.RawQuery = params.Encode()
+	body, err := c.send(ctx, "PATCH", u, payload)
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+type azureTestRun struct {
+	ID                 int `json:"id"`
+	TotalTests         int `json:"totalTests"`
+	NotApplicableTests int `json:"notApplicableTests"`
+	PipelineReference  struct {
+		PhaseReference struct {
+			PhaseName string `json:"phaseName"`
+		} `json:"phaseReference"`
+		JobReference struct {
+			JobName string `json:"jobName"`
+		} `json:"jobReference"`
+	} `json:"pipelineReference"`
+}
+
+// Return the number of jobs of 'build'
+func countJobs(build cache.Build) int {
+	n := len(build.Jobs)
+	for _, stage := range build.Stages {
+		n += len(stage.Jobs)
+	}
+	return n
+}
+
+// TestReport returns the results of the test runs published by 'job'. Test runs are attributed
+// to jobs by the name of their job or phase, except for builds made of a single job.
+func (c AzurePipelinesClient) TestReport(ctx context.Context, build cache.Build, job cache.Job) (cache.TestReport, error) {
+	owner, repo, id, err := c.parseAzureWebURL(build.WebURL)
+	if err != nil {
+		return cache.TestReport{}, err
+	}
+	u := c.baseURL
+	u.Path += fmt.Sprintf("/%s/%s/_apis/test/runs", owner, repo)
+	params := u.Query()
+	params.Add("buildUri", "vstfs:///Build/Build/"+id)
+	u.RawQuery = params.Encode()
+	runs := struct {
+		Value []azureTestRun `json:"value"`
+	}{}
+	if err := c.getJSON(ctx, u, &runs); err != nil {
+		return cache.TestReport{}, err
+	}
+
+	report := cache.TestReport{}
+	found := false
+	for _, run := range runs.Value {
+		reference := run.PipelineReference
+		if countJobs(build) > 1 && reference.JobReference.JobName != job.Name && reference.PhaseReference.PhaseName != job.Name {
+			continue
+		}
+		found = true
+		report.Total += run.TotalTests
+		report.Skipped += run.NotApplicableTests
+
+		u := c.baseURL
+		u.Path += fmt.Sprintf("/%s/%s/_apis/test/Runs/%d/results", owner, repo, run.ID)
+		params := u.Query()
+		params.Add("outcomes", "Failed,Aborted,Error,Timeout")
+		u.RawQuery = params.Encode()
+		results := struct {
+			Value []struct {
+				Title        string `json:"testCaseTitle"`
+				Storage      string `json:"automatedTestStorage"`
+				ErrorMessage string `json:"errorMessage"`
+			} `json:"value"`
+		}{}
+		if err := c.getJSON(ctx, u, &results); err != nil {
+			return cache.TestReport{}, err
+		}
+		for _, result := range results.Value {
+			report.Failed++
+			report.Failures = append(report.Failures, cache.TestCase{
+				Suite:   result.Storage,
+				Name:    result.Title,
+				Message: strings.SplitN(strings.TrimSpace(result.ErrorMessage), "\n", 2)[0],
+			})
+		}
+	}
+	if !found {
+		return cache.TestReport{}, cache.ErrNoTestReport
+	}
+
+	return report, nil
+}
+
+// CancelJob is not supported since Azure Pipelines only cancels whole builds
+func (c AzurePipelinesClient) CancelJob(ctx context.Context, build cache.Build, job cache.Job) error {
+	return cache.ErrUnsupportedAction
+}
+
 func (c AzurePipelinesClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
 	c.mux.Lock()
 	logURL, exists := c.logURLByJobID[jobID]
@@ -144,6 +430,24 @@ type azureBuild struct {
 	Repository struct {
 		ID string `json:"id"`
 	} `json:"repository"`
+	Definition struct {
+		// URL of the revision of the definition used by the build
+		URL string `json:"url"`
+	} `json:"definition"`
+	// JSON object of the variables set when the build was queued
+	Parameters string `json:"parameters"`
+}
+
+// Return the variables set when the build was queued
+func (b azureBuild) variables() ([]cache.Variable, error) {
+	if b.Parameters == "" {
+		return nil, nil
+	}
+	parameters := make(map[string]string)
+	if err := json.Unmarshal([]byte(b.Parameters), &parameters); err != nil {
+		return nil, err
+	}
+	return sortedVariables(parameters), nil
 }
 
 func (b azureBuild) toCacheBuild(p cache.Provider) (cache.Build, error) {
@@ -236,9 +540,19 @@ func (c AzurePipelinesClient) fetchBuild(ctx context.Context, owner string, repo
 		return cache.Build{}, err
 	}
 
+	variables, err := azureBuild.variables()
+	if err != nil {
+		return cache.Build{}, err
+	}
+	steps, err := c.definitionSteps(ctx, azureBuild.Definition.URL)
+	if err != nil {
+		return cache.Build{}, err
+	}
 	for _, stage := range stages {
 		for _, job := range stage.Jobs {
 			job.CreatedAt = build.CreatedAt
+			job.Variables = variables
+			job.Steps = steps[job.Name]
 		}
 	}
 
@@ -261,6 +575,85 @@ func (c AzurePipelinesClient) fetchBuild(ctx context.Context, owner string, repo
 	return build, err
 }
 
+// Return the commands of the tasks of the classic pipeline whose definition is at 'u' indexed by
+// the name of their phase. No command is returned if the definition cannot be read.
+func (c AzurePipelinesClient) definitionSteps(ctx context.Context, u string) (map[string][]cache.Step, error) {
+	if u == "" {
+		return nil, nil
+	}
+	definitionURL, err := url.Parse(u)
+	if err != nil {
+		return nil, nil
+	}
+	var definition azureDefinition
+	if err := c.getJSON(ctx, *definitionURL, &definition); err != nil {
+		return nil, ctx.Err()
+	}
+	return definition.steps(), nil
+}
+
+// Definition of a pipeline. The tasks of classic pipelines are part of the definition along with
+// their inputs whereas the tasks of YAML pipelines are defined by a file of the repository, which
+// is not read.
+type azureDefinition struct {
+	Process struct {
+		Phases []struct {
+			Name  string `json:"name"`
+			Steps []struct {
+				DisplayName string            `json:"displayName"`
+				Enabled     bool              `json:"enabled"`
+				Inputs      map[string]string `json:"inputs"`
+			} `json:"steps"`
+		} `json:"phases"`
+	} `json:"process"`
+}
+
+// Return the commands of the enabled tasks of each phase of the definition indexed by the name
+// of the phase
+func (d azureDefinition) steps() map[string][]cache.Step {
+	steps := make(map[string][]cache.Step)
+	for _, phase := range d.Process.Phases {
+		phaseSteps := make([]cache.Step, 0, len(phase.Steps))
+		for _, step := range phase.Steps {
+			if !step.Enabled {
+				continue
+			}
+			if command := azureTaskCommand(step.Inputs); command != "" {
+				phaseSteps = append(phaseSteps, cache.Step{Name: step.DisplayName, Command: command})
+			}
+		}
+		steps[phase.Name] = phaseSteps
+	}
+	return steps
+}
+
+// Inputs holding the inline script of the tasks running scripts, such as CmdLine, Bash or
+// PowerShell
+var azureScriptInputs = []string{"script", "inlineScript", "Inline"}
+
+// Return the command run by a task given its inputs: the inline script of tasks running scripts,
+// or the non-empty inputs of other tasks, one per line
+func azureTaskCommand(inputs map[string]string) string {
+	for _, name := range azureScriptInputs {
+		if script := strings.TrimSpace(inputs[name]); script != "" {
+			return script
+		}
+	}
+
+	names := make([]string, 0, len(inputs))
+	for name, value := range inputs {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s: %s", name, inputs[name]))
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (c AzurePipelinesClient) getTimeline(ctx context.Context, u string) (map[int]*cache.Stage, error) {
 	timelineURL, err := url.Parse(u)
 	if err != nil {
@@ -279,7 +672,7 @@ func (c AzurePipelinesClient) getTimeline(ctx context.Context, u string) (map[in
 
 	for _, record := range timeline.Records {
 		switch strings.ToLower(record.Type) {
-		case "stage", "phase", "job":
+		case "stage", "phase", "job", "checkpoint", "checkpoint.approval":
 			record := record // kill me now
 			recordsByID[record.ID] = &record
 		}
@@ -361,6 +754,27 @@ func (r azureRecord) ToCacheStage() (cache.Stage, error) {
 
 	stageJobs := make([]*cache.Job, 0)
 	for _, record := range r.children {
+		if strings.ToLower(record.Type) == "checkpoint" {
+			// Approvals gating the stage are shown as jobs
+			for _, approval := range record.children {
+				if strings.ToLower(approval.Type) != "checkpoint.approval" {
+					continue
+				}
+				job, err := approval.ToCacheJob()
+				if err != nil {
+					return cache.Stage{}, err
+				}
+				if job.State == cache.Running {
+					// The approval waits for a user
+					job.State = cache.Manual
+				}
+				if job.Name == "" {
+					job.Name = "Approval"
+				}
+				stageJobs = append(stageJobs, &job)
+			}
+			continue
+		}
 		jobs, err := record.ToCacheJobs()
 		if err != nil {
 			return cache.Stage{}, err
@@ -444,17 +858,31 @@ func (c AzurePipelinesClient) getJSON(ctx context.Context, u url.URL, v interfac
 }
 
 func (c AzurePipelinesClient) get(ctx context.Context, u url.URL) (io.ReadCloser, error) {
+	return c.send(ctx, "GET", u, nil)
+}
+
+// Send a request with a JSON payload unless 'payload' is nil
+func (c AzurePipelinesClient) send(ctx context.Context, method string, u url.URL, payload []byte) (io.ReadCloser, error) {
 	if u.Hostname() != c.baseURL.Hostname() {
 		return nil, fmt.Errorf("expected URL host to be %q but got %q", u.Hostname(), c.baseURL.Hostname())
 	}
 	params := u.Query()
-	params.Add("api-version", c.version)
+	if params.Get("api-version") == "" {
+		params.Set("api-version", c.version)
+	}
 	u.RawQuery = params.Encode()
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, u.String(), reqBody)
 	if err != nil {
 		return nil, err
 	}
+	if payload != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
 	req.WithContext(ctx)
 
 	if c.token != "" {
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -27,6 +28,59 @@ func Setup() (AzurePipelinesClient, func(), error) {
 			filename = "test_data/azure_build_16_timeline.json"
 		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/build/builds/16/logs/1234":
 			filename = "test_data/azure_build_16_job_log.txt"
+		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/test/runs" && r.URL.Query().Get("buildUri") == "vstfs:///Build/Build/16":
+			filename = "test_data/azure_build_16_test_runs.json"
+		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/test/Runs/7/results":
+			filename = "test_data/azure_test_run_7_results.json"
+		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/test/Runs/8/results":
+			fmt.Fprint(w, `{"count": 0, "value": []}`)
+			return
+		case r.Method == "PATCH" && r.URL.Path == "/owner/repo/_apis/pipelines/approvals":
+			var approvals []struct {
+				ID     string `json:"approvalId"`
+				Status string `json:"status"`
+			}
+			if r.URL.Query().Get("api-version") != azureApprovalsVersion || json.NewDecoder(r.Body).Decode(&approvals) != nil ||
+				len(approvals) != 1 || approvals[0].ID != "42" || approvals[0].Status != "approved" {
+				w.WriteHeader(400)
+				return
+			}
+			w.WriteHeader(200)
+			return
+		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/build/definitions":
+			fmt.Fprint(w, `{"count": 3, "value": [
+				{"id": 1, "repository": {"name": "repo", "type": "TfsGit"}},
+				{"id": 2, "repository": {"name": "other", "type": "TfsGit"}},
+				{"id": 3, "repository": {"name": "owner/repo", "type": "GitHub"}}
+			]}`)
+			return
+		case r.Method == "POST" && r.URL.Path == "/owner/repo/_apis/build/builds":
+			var request struct {
+				Definition struct {
+					ID int `json:"id"`
+				} `json:"definition"`
+				SourceBranch string `json:"sourceBranch"`
+				Parameters   string `json:"parameters"`
+			}
+			if json.NewDecoder(r.Body).Decode(&request) != nil || request.Definition.ID != 1 ||
+				request.SourceBranch != "refs/heads/master" || request.Parameters != `{"DEBUG":"1"}` {
+				w.WriteHeader(400)
+				return
+			}
+			fmt.Fprintf(w, `{"id": 18, "_links": {"web": {"href": "http://%s/owner/repo/_build/results?buildId=18"}}}`, r.Host)
+			return
+		case r.Method == "PATCH" && r.URL.Path == "/owner/repo/_apis/build/builds/16":
+			expected := `{"status": "cancelling"}`
+			if r.URL.Query().Get("retry") == "true" {
+				expected = "{}"
+			}
+			bs, err := ioutil.ReadAll(r.Body)
+			if err != nil || string(bs) != expected {
+				w.WriteHeader(400)
+				return
+			}
+			w.WriteHeader(200)
+			return
 		default:
 			w.WriteHeader(404)
 			return
@@ -274,3 +328,228 @@ func TestAzurePipelinesClient_Log(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestAzurePipelinesClient_CancelBuild(t *testing.T) {
+	client, teardown, err := Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	ctx := context.Background()
+	build := cache.Build{
+		ID:     "16",
+		WebURL: "http://" + client.baseURL.Host + "/owner/repo/_build/results?buildId=16",
+	}
+	if err := client.CancelBuild(ctx, build); err != nil {
+		t.Fatal(err)
+	}
+
+	build.WebURL = "http://" + client.baseURL.Host + "/owner/repo/_build/results?buildId=17"
+	if err := client.CancelBuild(ctx, build); err == nil {
+		t.Fatal("expected an error for an unknown build")
+	}
+	if err := client.CancelJob(ctx, build, cache.Job{}); err != cache.ErrUnsupportedAction {
+		t.Fatalf("expected %v but got %v", cache.ErrUnsupportedAction, err)
+	}
+}
+
+func TestAzurePipelinesClient_RestartBuild(t *testing.T) {
+	client, teardown, err := Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	ctx := context.Background()
+	build := cache.Build{
+		ID:     "16",
+		WebURL: "http://" + client.baseURL.Host + "/owner/repo/_build/results?buildId=16",
+	}
+	u, err := client.RestartBuild(ctx, build)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != build.WebURL {
+		t.Fatalf("expected %q but got %q", build.WebURL, u)
+	}
+
+	build.WebURL = "http://" + client.baseURL.Host + "/owner/repo/_build/results?buildId=17"
+	if _, err := client.RestartBuild(ctx, build); err == nil {
+		t.Fatal("expected an error for an unknown build")
+	}
+}
+
+func TestAzurePipelinesClient_TriggerPipeline(t *testing.T) {
+	client, teardown, err := Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	ctx := context.Background()
+	variables := []cache.Variable{{Name: "DEBUG", Value: "1"}}
+	repositoryURL := "http://" + client.baseURL.Host + "/owner/repo/_git/repo"
+	u, err := client.TriggerPipeline(ctx, repositoryURL, "master", variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "http://" + client.baseURL.Host + "/owner/repo/_build/results?buildId=18"
+	if u != expected {
+		t.Fatalf("expected %q but got %q", expected, u)
+	}
+
+	repositoryURL = "http://" + client.baseURL.Host + "/owner/repo/_git/unknown"
+	if _, err := client.TriggerPipeline(ctx, repositoryURL, "master", variables); err != cache.ErrRepositoryNotFound {
+		t.Fatalf("expected %v but got %v", cache.ErrRepositoryNotFound, err)
+	}
+	if _, err := client.TriggerPipeline(ctx, "https://github.com/owner/repo", "master", variables); err != cache.ErrUnknownURL {
+		t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+	}
+}
+
+func TestAzurePipelinesClient_StartManualJob(t *testing.T) {
+	client, teardown, err := Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	ctx := context.Background()
+	build := cache.Build{
+		ID:     "16",
+		WebURL: "http://" + client.baseURL.Host + "/owner/repo/_build/results?buildId=16",
+	}
+	if err := client.StartManualJob(ctx, build, cache.Job{ID: "42"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StartManualJob(ctx, build, cache.Job{ID: "43"}); err == nil {
+		t.Fatal("expected an error for an unknown approval")
+	}
+}
+
+func TestAzurePipelinesClient_TestReport(t *testing.T) {
+	client, teardown, err := Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	ctx := context.Background()
+	build := cache.Build{
+		ID:     "16",
+		WebURL: "http://" + client.baseURL.Host + "/owner/repo/_build/results?buildId=16",
+		Jobs: []*cache.Job{
+			{ID: "1", Name: "Linux"},
+			{ID: "2", Name: "Windows"},
+			{ID: "3", Name: "Publish"},
+		},
+	}
+	report, err := client.TestReport(ctx, build, *build.Jobs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := cache.TestReport{
+		Total:   3,
+		Failed:  1,
+		Skipped: 1,
+		Failures: []cache.TestCase{
+			{Suite: "tests.test_term", Name: "test_record", Message: "OSError: no such device"},
+		},
+	}
+	if diff := cmp.Diff(expected, report); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	if _, err := client.TestReport(ctx, build, *build.Jobs[2]); err != cache.ErrNoTestReport {
+		t.Fatalf("expected %v but got %v", cache.ErrNoTestReport, err)
+	}
+
+	// The test runs of a build made of a single job belong to that job
+	build.Jobs = build.Jobs[2:]
+	report, err = client.TestReport(ctx, build, *build.Jobs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 8 || report.Failed != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestAzureRecord_ToCacheStage(t *testing.T) {
+	approval := &azureRecord{
+		ID:    "42",
+		Type:  "Checkpoint.Approval",
+		State: "inProgress",
+	}
+	checkpoint := &azureRecord{
+		ID:       "41",
+		Type:     "Checkpoint",
+		State:    "inProgress",
+		children: []*azureRecord{approval},
+	}
+	stage := azureRecord{
+		ID:       "40",
+		Type:     "Stage",
+		Name:     "deploy",
+		State:    "pending",
+		Order:    2,
+		children: []*azureRecord{checkpoint},
+	}
+
+	s, err := stage.ToCacheStage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*cache.Job{
+		{ID: "42", Name: "Approval", State: cache.Manual},
+	}
+	if diff := cmp.Diff(expected, s.Jobs); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestAzureDefinition_steps(t *testing.T) {
+	payload := `{
+		"process": {
+			"type": 1,
+			"phases": [
+				{
+					"name": "Agent job 1",
+					"steps": [
+						{
+							"displayName": "Build",
+							"enabled": true,
+							"task": {"id": "d9bafed4-0b18-4f58-968d-86655b4d2ce9", "definitionType": "task"},
+							"inputs": {"script": "go build ./...\n", "workingDirectory": "", "failOnStderr": "false"}
+						},
+						{
+							"displayName": "Disabled",
+							"enabled": false,
+							"inputs": {"script": "exit 1"}
+						},
+						{
+							"displayName": "Publish test results",
+							"enabled": true,
+							"inputs": {"testResultsFormat": "JUnit", "testResultsFiles": "**/TEST-*.xml", "searchFolder": ""}
+						}
+					]
+				}
+			]
+		}
+	}`
+	var definition azureDefinition
+	if err := json.Unmarshal([]byte(payload), &definition); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]cache.Step{
+		"Agent job 1": {
+			{Name: "Build", Command: "go build ./..."},
+			{Name: "Publish test results", Command: "testResultsFiles: **/TEST-*.xml\ntestResultsFormat: JUnit"},
+		},
+	}
+	if diff := cmp.Diff(expected, definition.steps()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
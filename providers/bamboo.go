@@ -0,0 +1,347 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// BambooClient reads the plans of an instance of Bamboo Server or Bamboo Data Center. The builds
+// of a commit are the results of the plans that built the revision so the client is also a
+// source provider. Results are shown as pipelines whose stages contain the jobs of the plan.
+type BambooClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    cache.Provider
+	repositoryRestriction
+}
+
+// NewBambooClient returns a client for the instance whose web interface is at 'baseURL', e.g.
+// https://bamboo.example.com, authenticated by a personal access token
+func NewBambooClient(id string, name string, token string, baseURL url.URL, rateLimit time.Duration, options ...ClientOption) BambooClient {
+	return BambooClient{
+		baseURL:     baseURL,
+		httpClient:  newHTTPClient(requestTimeout, options),
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (c BambooClient) ID() string {
+	return c.provider.ID
+}
+
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c BambooClient) WithRepositories(repositories RepositoryPatterns) BambooClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// Return the URL of 'path' relative to the web interface of the instance
+func (c BambooClient) url(path string, query url.Values) url.URL {
+	u := c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+	return u
+}
+
+// Send a GET request to 'path' and return the body of the response
+func (c BambooClient) get(ctx context.Context, path string, query url.Values) (*bytes.Buffer, error) {
+	u := c.url(path, query)
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: body.String(),
+		}
+	}
+
+	return body, nil
+}
+
+// Send a GET request to the endpoint 'path' of the REST API and decode the response into 'v'
+func (c BambooClient) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	body, err := c.get(ctx, "/rest/api/latest"+path, query)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body.Bytes(), v)
+}
+
+// CheckAuthentication returns an error if Bamboo rejects the personal access token
+func (c BambooClient) CheckAuthentication(ctx context.Context) error {
+	var user struct {
+		Name string `json:"name"`
+	}
+	return c.getJSON(ctx, "/currentUser", nil, &user)
+}
+
+// Commit is not supported since Bamboo does not host repositories
+func (c BambooClient) Commit(ctx context.Context, repo string, sha string) (utils.Commit, error) {
+	return utils.Commit{}, cache.ErrRepositoryNotFound
+}
+
+// BuildURLs returns the web pages of the results of the plans that built revision 'sha'
+func (c BambooClient) BuildURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
+	urls := make([]string, 0)
+	for start := 0; ; {
+		var resp struct {
+			Results struct {
+				Size   int `json:"size"`
+				Result []struct {
+					BuildResultKey string `json:"buildResultKey"`
+				} `json:"result"`
+			} `json:"results"`
+		}
+		query := url.Values{
+			"max-result":  []string{"100"},
+			"start-index": []string{strconv.Itoa(start)},
+		}
+		if err := c.getJSON(ctx, "/result/byChangeset/"+url.PathEscape(sha), query, &resp); err != nil {
+			if err, ok := err.(HTTPError); ok && err.Status == http.StatusNotFound {
+				return urls, nil
+			}
+			return nil, err
+		}
+		for _, result := range resp.Results.Result {
+			u := c.url("/browse/"+result.BuildResultKey, nil)
+			urls = append(urls, u.String())
+		}
+		start += len(resp.Results.Result)
+		if len(resp.Results.Result) == 0 || start >= resp.Results.Size {
+			break
+		}
+	}
+
+	return urls, nil
+}
+
+// Key of the result of a plan, e.g. "PROJ-PLAN-12"
+var bambooPlanResultKey = regexp.MustCompile(`^[A-Z][A-Z0-9]*-[A-Z0-9]+-\d+$`)
+
+// Key of the result of a job, e.g. "PROJ-PLAN-JOB1-12"
+var bambooJobResultKey = regexp.MustCompile(`^([A-Z][A-Z0-9]*-[A-Z0-9]+-[A-Z0-9]+)-(\d+)$`)
+
+// Return the key of the result of a plan given the URL of its web page, e.g.
+// https://bamboo.example.com/browse/PROJ-PLAN-12
+func (c BambooClient) parseWebURL(u string) (string, error) {
+	v, err := url.Parse(u)
+	if err != nil || v.Hostname() != c.baseURL.Hostname() {
+		return "", cache.ErrUnknownURL
+	}
+	p := strings.TrimPrefix(v.Path, strings.TrimSuffix(c.baseURL.Path, "/"))
+	cs := strings.Split(strings.Trim(p, "/"), "/")
+	if len(cs) < 2 || cs[0] != "browse" || !bambooPlanResultKey.MatchString(cs[1]) {
+		return "", cache.ErrUnknownURL
+	}
+
+	return cs[1], nil
+}
+
+// BuildFromURL returns the result of a plan whose stages contain the results of the jobs of the
+// plan
+func (c BambooClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	key, err := c.parseWebURL(u)
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	var result bambooResult
+	query := url.Values{"expand": []string{"stages.stage.results.result,vcsRevisions"}}
+	if err := c.getJSON(ctx, "/result/"+url.PathEscape(key), query, &result); err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == http.StatusNotFound {
+			return cache.Build{}, cache.ErrUnknownURL
+		}
+		return cache.Build{}, err
+	}
+
+	return c.fromBambooResult(result), nil
+}
+
+// Log returns the log of the result of a job without the type and the time prefixing each line
+func (c BambooClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	cs := bambooJobResultKey.FindStringSubmatch(jobID)
+	if cs == nil {
+		return "", fmt.Errorf("invalid job identifier %q", jobID)
+	}
+	body, err := c.get(ctx, fmt.Sprintf("/download/%s/build_logs/%s.log", cs[1], jobID), nil)
+	if err != nil {
+		return "", err
+	}
+
+	// Lines are formatted as "type\tdd-MMM-yyyy HH:mm:ss\tmessage"
+	b := strings.Builder{}
+	for _, line := range strings.SplitAfter(body.String(), "\n") {
+		if fields := strings.SplitN(line, "\t", 3); len(fields) == 3 {
+			line = fields[2]
+		}
+		b.WriteString(line)
+	}
+
+	return b.String(), nil
+}
+
+// Result of a plan or of a job
+type bambooResult struct {
+	BuildResultKey     string `json:"buildResultKey"`
+	BuildNumber        int    `json:"buildNumber"`
+	PlanName           string `json:"planName"`
+	State              string `json:"state"`
+	LifeCycleState     string `json:"lifeCycleState"`
+	BuildStartedTime   string `json:"buildStartedTime"`
+	BuildCompletedTime string `json:"buildCompletedTime"`
+	VcsRevisionKey     string `json:"vcsRevisionKey"`
+	Plan               struct {
+		Key       string `json:"key"`
+		ShortName string `json:"shortName"`
+		// Plan of the default branch if this plan is the plan of another branch
+		Master *struct {
+			Key string `json:"key"`
+		} `json:"master"`
+	} `json:"plan"`
+	Stages struct {
+		Stage []struct {
+			Name           string `json:"name"`
+			State          string `json:"state"`
+			LifeCycleState string `json:"lifeCycleState"`
+			Results        struct {
+				Result []bambooResult `json:"result"`
+			} `json:"results"`
+		} `json:"stage"`
+	} `json:"stages"`
+	VcsRevisions struct {
+		VcsRevision []struct {
+			RepositoryName string `json:"repositoryName"`
+			VcsRevisionKey string `json:"vcsRevisionKey"`
+		} `json:"vcsRevision"`
+	} `json:"vcsRevisions"`
+}
+
+func bambooTime(s string) utils.NullTime {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return utils.NullTime{}
+	}
+	return utils.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// Return the state of a result, a stage or a job
+func fromBambooState(lifeCycleState string, state string) cache.State {
+	switch lifeCycleState {
+	case "Pending", "Queued":
+		return cache.Pending
+	case "InProgress":
+		return cache.Running
+	case "NotBuilt":
+		return cache.Skipped
+	case "Finished":
+		switch state {
+		case "Successful":
+			return cache.Passed
+		case "Failed":
+			return cache.Failed
+		}
+	}
+	return cache.Unknown
+}
+
+func (c BambooClient) fromBambooResult(result bambooResult) cache.Build {
+	webURL := c.url("/browse/"+result.BuildResultKey, nil)
+	repository := cache.Repository{
+		Provider: c.provider,
+		Name:     result.PlanName,
+	}
+	if revisions := result.VcsRevisions.VcsRevision; len(revisions) > 0 {
+		repository.Name = revisions[0].RepositoryName
+	}
+
+	build := cache.Build{
+		Repository:      &repository,
+		ID:              result.BuildResultKey,
+		Commit:          cache.Commit{Sha: result.VcsRevisionKey},
+		RepoBuildNumber: fmt.Sprintf("%s #%d", result.PlanName, result.BuildNumber),
+		State:           fromBambooState(result.LifeCycleState, result.State),
+		StartedAt:       bambooTime(result.BuildStartedTime),
+		FinishedAt:      bambooTime(result.BuildCompletedTime),
+		WebURL:          webURL.String(),
+		Stages:          make(map[int]*cache.Stage),
+	}
+	if result.Plan.Master != nil {
+		build.Ref = result.Plan.ShortName
+	}
+	build.CreatedAt = build.StartedAt
+	build.UpdatedAt = utils.MaxNullTime(build.StartedAt, build.FinishedAt).Time
+	build.Duration = utils.NullSub(build.FinishedAt, build.StartedAt)
+
+	for i, s := range result.Stages.Stage {
+		stage := cache.Stage{
+			ID:    i + 1,
+			Name:  s.Name,
+			State: fromBambooState(s.LifeCycleState, s.State),
+		}
+		for _, r := range s.Results.Result {
+			jobURL := c.url("/browse/"+r.BuildResultKey, nil)
+			job := cache.Job{
+				ID:         r.BuildResultKey,
+				State:      fromBambooState(r.LifeCycleState, r.State),
+				Name:       r.Plan.ShortName,
+				StartedAt:  bambooTime(r.BuildStartedTime),
+				FinishedAt: bambooTime(r.BuildCompletedTime),
+				WebURL:     jobURL.String(),
+			}
+			if job.Name == "" {
+				job.Name = r.PlanName
+			}
+			job.CreatedAt = job.StartedAt
+			job.Duration = utils.NullSub(job.FinishedAt, job.StartedAt)
+			stage.Jobs = append(stage.Jobs, &job)
+		}
+		build.Stages[stage.ID] = &stage
+	}
+
+	return build
+}
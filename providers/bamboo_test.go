@@ -0,0 +1,204 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func newBambooTestClient(t *testing.T) (BambooClient, *httptest.Server) {
+	files := map[string]string{
+		"/rest/api/latest/currentUser": "bamboo_current_user.json",
+		"/rest/api/latest/result/byChangeset/a24840cf94b395af69da4a1001d32e3694637e20": "bamboo_results_by_changeset.json",
+		"/rest/api/latest/result/CITOP-CI-12":                                          "bamboo_result.json",
+		"/download/CITOP-CI-TEST/build_logs/CITOP-CI-TEST-12.log":                      "bamboo_job_log.txt",
+	}
+
+	ts := newFixtureServer(t, files, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(401)
+			return true
+		}
+		return false
+	})
+
+	baseURL, err := url.Parse(ts.URL)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+
+	return NewBambooClient("bamboo", "bamboo", "token", *baseURL, time.Millisecond), ts
+}
+
+func TestBambooClient_BuildURLs(t *testing.T) {
+	client, ts := newBambooTestClient(t)
+	defer ts.Close()
+	ctx := context.Background()
+
+	urls, err := client.BuildURLs(ctx, "nbedos", "citop", "a24840cf94b395af69da4a1001d32e3694637e20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		ts.URL + "/browse/CITOP-CI-12",
+		ts.URL + "/browse/CITOP-CI0-3",
+	}
+	if diff := cmp.Diff(expected, urls); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("Unknown revision", func(t *testing.T) {
+		urls, err := client.BuildURLs(ctx, "nbedos", "citop", "0000000000000000000000000000000000000000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(urls) > 0 {
+			t.Fatalf("expected no URL but got %v", urls)
+		}
+	})
+}
+
+func TestBambooClient_BuildFromURL(t *testing.T) {
+	client, ts := newBambooTestClient(t)
+	defer ts.Close()
+
+	build, err := client.BuildFromURL(context.Background(), ts.URL+"/browse/CITOP-CI-12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(min int, sec int) utils.NullTime {
+		return utils.NullTime{Time: time.Date(2020, 2, 4, 10, min, sec, 0, time.UTC), Valid: true}
+	}
+	job := func(key string, state cache.State, name string, startedAt utils.NullTime, finishedAt utils.NullTime) *cache.Job {
+		return &cache.Job{
+			ID:         key,
+			State:      state,
+			Name:       name,
+			CreatedAt:  startedAt,
+			StartedAt:  startedAt,
+			FinishedAt: finishedAt,
+			Duration:   utils.NullSub(finishedAt, startedAt),
+			WebURL:     ts.URL + "/browse/" + key,
+		}
+	}
+	expected := cache.Build{
+		Repository: &cache.Repository{
+			Provider: cache.Provider{ID: "bamboo", Name: "bamboo"},
+			Name:     "citop",
+		},
+		ID:              "CITOP-CI-12",
+		Commit:          cache.Commit{Sha: "a24840cf94b395af69da4a1001d32e3694637e20"},
+		RepoBuildNumber: "CI #12",
+		State:           cache.Failed,
+		CreatedAt:       at(0, 0),
+		StartedAt:       at(0, 0),
+		FinishedAt:      at(2, 30),
+		UpdatedAt:       at(2, 30).Time,
+		Duration:        utils.NullDuration{Duration: 150 * time.Second, Valid: true},
+		WebURL:          ts.URL + "/browse/CITOP-CI-12",
+		Stages: map[int]*cache.Stage{
+			1: {
+				ID:    1,
+				Name:  "Build",
+				State: cache.Failed,
+				Jobs: []*cache.Job{
+					job("CITOP-CI-COMP-12", cache.Passed, "Compile", at(0, 5), at(1, 0)),
+					job("CITOP-CI-TEST-12", cache.Failed, "Test", at(0, 5), at(2, 25)),
+				},
+			},
+			2: {
+				ID:    2,
+				Name:  "Deploy",
+				State: cache.Skipped,
+				Jobs: []*cache.Job{
+					job("CITOP-CI-REL-12", cache.Skipped, "Release", utils.NullTime{}, utils.NullTime{}),
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, build); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("Unknown result", func(t *testing.T) {
+		_, err := client.BuildFromURL(context.Background(), ts.URL+"/browse/CITOP-CI-13")
+		if err != cache.ErrUnknownURL {
+			t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+		}
+	})
+}
+
+func TestBambooClient_Log(t *testing.T) {
+	client, ts := newBambooTestClient(t)
+	defer ts.Close()
+
+	log, err := client.Log(context.Background(), cache.Repository{}, "CITOP-CI-TEST-12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Build citop - CI - Test #12 (CITOP-CI-TEST-12) started building on agent agent-1\n" +
+		"+ go test ./...\n" +
+		"--- FAIL: TestBambooClient (0.00s)\n" +
+		"Failing task since return code of [go test ./...] was 1 while expected 0\n"
+	if diff := cmp.Diff(expected, log); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestBambooClient_CheckAuthentication(t *testing.T) {
+	client, ts := newBambooTestClient(t)
+	defer ts.Close()
+
+	if err := client.CheckAuthentication(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	client.token = "invalid"
+	if err := client.CheckAuthentication(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestParseBambooURL(t *testing.T) {
+	baseURL, err := url.Parse("https://example.com/bamboo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewBambooClient("bamboo", "bamboo", "", *baseURL, time.Millisecond)
+
+	for _, u := range []string{
+		"https://example.com/bamboo/browse/CITOP-CI-12",
+		"https://example.com/bamboo/browse/CITOP-CI-12/log",
+	} {
+		t.Run(u, func(t *testing.T) {
+			key, err := client.parseWebURL(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key != "CITOP-CI-12" {
+				t.Fatalf("expected %q but got %q", "CITOP-CI-12", key)
+			}
+		})
+	}
+
+	for _, u := range []string{
+		"https://github.com/browse/CITOP-CI-12",
+		"https://example.com/bamboo/browse/CITOP-CI",
+		"https://example.com/bamboo/browse/CITOP-CI-TEST-12",
+	} {
+		t.Run(u, func(t *testing.T) {
+			if _, err := client.parseWebURL(u); err != cache.ErrUnknownURL {
+				t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,387 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// Number of recent builds searched for the builds of a commit
+const buildbotSearchLimit = 200
+
+// BuildbotClient reads builds from the REST API of Buildbot (version 0.9 and later). Buildbot
+// does not necessarily report the results of builds to the host of the repository, so the
+// client is also a source provider listing the builds whose "revision" property designates a
+// commit.
+type BuildbotClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	provider    cache.Provider
+	repositoryRestriction
+}
+
+func NewBuildbotClient(id string, name string, baseURL url.URL, rateLimit time.Duration, options ...ClientOption) BuildbotClient {
+	return BuildbotClient{
+		baseURL:     baseURL,
+		httpClient:  newHTTPClient(requestTimeout, options),
+		rateLimiter: time.Tick(rateLimit),
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (c BuildbotClient) ID() string {
+	return c.provider.ID
+}
+
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c BuildbotClient) WithRepositories(repositories RepositoryPatterns) BuildbotClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// Send a GET request to the endpoint 'path' of the REST API and decode the response into 'v'
+func (c BuildbotClient) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	u := c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v2" + path
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: body.String(),
+		}
+	}
+
+	return json.Unmarshal(body.Bytes(), v)
+}
+
+// Properties of a build. Each property is a pair made of the value and of the source of the
+// property.
+type buildbotProperties map[string][]interface{}
+
+// Return the value of the first property of 'names' set to a non-empty string
+func (p buildbotProperties) get(names ...string) string {
+	for _, name := range names {
+		if values := p[name]; len(values) > 0 {
+			if s, ok := values[0].(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+type buildbotBuild struct {
+	BuildID     int                `json:"buildid"`
+	BuilderID   int                `json:"builderid"`
+	Number      int                `json:"number"`
+	StartedAt   *int64             `json:"started_at"`
+	CompleteAt  *int64             `json:"complete_at"`
+	Complete    bool               `json:"complete"`
+	StateString string             `json:"state_string"`
+	Results     *int               `json:"results"`
+	Properties  buildbotProperties `json:"properties"`
+}
+
+type buildbotBuilder struct {
+	BuilderID int    `json:"builderid"`
+	Name      string `json:"name"`
+}
+
+type buildbotStep struct {
+	StepID      int    `json:"stepid"`
+	Number      int    `json:"number"`
+	Name        string `json:"name"`
+	StartedAt   *int64 `json:"started_at"`
+	CompleteAt  *int64 `json:"complete_at"`
+	Complete    bool   `json:"complete"`
+	StateString string `json:"state_string"`
+	Results     *int   `json:"results"`
+	Hidden      bool   `json:"hidden"`
+}
+
+type buildbotLog struct {
+	LogID int    `json:"logid"`
+	Name  string `json:"name"`
+	// "s" for the standard streams of a command, "t" for text and "h" for HTML
+	Type string `json:"type"`
+}
+
+type buildbotLogChunk struct {
+	FirstLine int    `json:"firstline"`
+	Content   string `json:"content"`
+}
+
+// Return the URL of the page of a build in the web interface
+func (c BuildbotClient) webURL(builderID int, number int) string {
+	u := c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/"
+	u.Fragment = fmt.Sprintf("builders/%d/builds/%d", builderID, number)
+	return u.String()
+}
+
+var buildbotFragment = regexp.MustCompile(`^/?builders/(\d+)/builds/(\d+)/?$`)
+
+// Return the builder ID and the number of the build whose page is at 'u', e.g.
+// https://buildbot.example.com/#builders/12/builds/345
+func parseBuildbotURL(baseURL url.URL, u string) (int, int, error) {
+	v, err := url.Parse(u)
+	if err != nil || v.Hostname() != baseURL.Hostname() {
+		return 0, 0, cache.ErrUnknownURL
+	}
+	if strings.TrimSuffix(v.Path, "/") != strings.TrimSuffix(baseURL.Path, "/") {
+		return 0, 0, cache.ErrUnknownURL
+	}
+
+	cs := buildbotFragment.FindStringSubmatch(v.Fragment)
+	if cs == nil {
+		return 0, 0, cache.ErrUnknownURL
+	}
+	builderID, err := strconv.Atoi(cs[1])
+	if err != nil {
+		return 0, 0, cache.ErrUnknownURL
+	}
+	number, err := strconv.Atoi(cs[2])
+	if err != nil {
+		return 0, 0, cache.ErrUnknownURL
+	}
+
+	return builderID, number, nil
+}
+
+// BuildURLs returns the URLs of the recent builds of commit 'sha' of the repository. Builds
+// whose "repository" property is not set are assumed to belong to the repository.
+// ErrRepositoryNotFound is returned if none of the recent builds belongs to the repository.
+func (c BuildbotClient) BuildURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
+	query := url.Values{
+		"property": {"revision", "got_revision", "repository"},
+		"order":    {"-buildid"},
+		"limit":    {strconv.Itoa(buildbotSearchLimit)},
+	}
+	var response struct {
+		Builds []buildbotBuild `json:"builds"`
+	}
+	if err := c.getJSON(ctx, "/builds", query, &response); err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0)
+	repositoryFound := false
+	for _, build := range response.Builds {
+		if repository := build.Properties.get("repository"); repository != "" {
+			_, o, r, err := utils.RepoHostOwnerAndName(repository)
+			if err != nil || !strings.EqualFold(o, owner) || !strings.EqualFold(r, repo) {
+				continue
+			}
+		}
+		repositoryFound = true
+		if build.Properties.get("revision", "got_revision") == sha {
+			urls = append(urls, c.webURL(build.BuilderID, build.Number))
+		}
+	}
+	if !repositoryFound {
+		return nil, cache.ErrRepositoryNotFound
+	}
+
+	return urls, nil
+}
+
+// Commit is not supported since Buildbot does not host repositories
+func (c BuildbotClient) Commit(ctx context.Context, repo string, sha string) (utils.Commit, error) {
+	return utils.Commit{}, cache.ErrRepositoryNotFound
+}
+
+func (c BuildbotClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	builderID, number, err := parseBuildbotURL(c.baseURL, u)
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	var builders struct {
+		Builders []buildbotBuilder `json:"builders"`
+	}
+	if err := c.getJSON(ctx, fmt.Sprintf("/builders/%d", builderID), nil, &builders); err != nil {
+		return cache.Build{}, err
+	}
+	if len(builders.Builders) == 0 {
+		return cache.Build{}, fmt.Errorf("builder %d not found", builderID)
+	}
+
+	var builds struct {
+		Builds []buildbotBuild `json:"builds"`
+	}
+	path := fmt.Sprintf("/builders/%d/builds/%d", builderID, number)
+	if err := c.getJSON(ctx, path, url.Values{"property": {"*"}}, &builds); err != nil {
+		return cache.Build{}, err
+	}
+	if len(builds.Builds) == 0 {
+		return cache.Build{}, fmt.Errorf("build %d of builder %d not found", number, builderID)
+	}
+
+	var steps struct {
+		Steps []buildbotStep `json:"steps"`
+	}
+	path = fmt.Sprintf("/builds/%d/steps", builds.Builds[0].BuildID)
+	if err := c.getJSON(ctx, path, nil, &steps); err != nil {
+		return cache.Build{}, err
+	}
+
+	return fromBuildbotBuild(c.provider, u, builders.Builders[0], builds.Builds[0], steps.Steps), nil
+}
+
+// Return the state of a build or of a step given its results code
+func fromBuildbotResults(complete bool, startedAt *int64, results *int) cache.State {
+	if !complete || results == nil {
+		if startedAt == nil {
+			return cache.Pending
+		}
+		return cache.Running
+	}
+
+	switch *results {
+	case 0, 1: // SUCCESS, WARNINGS
+		return cache.Passed
+	case 2, 4: // FAILURE, EXCEPTION
+		return cache.Failed
+	case 3: // SKIPPED
+		return cache.Skipped
+	case 5, 6: // RETRY, CANCELLED
+		return cache.Canceled
+	default:
+		return cache.Unknown
+	}
+}
+
+func fromBuildbotTimestamp(t *int64) utils.NullTime {
+	if t == nil {
+		return utils.NullTime{}
+	}
+	return utils.NullTime{Time: time.Unix(*t, 0).UTC(), Valid: true}
+}
+
+func fromBuildbotBuild(provider cache.Provider, webURL string, builder buildbotBuilder, b buildbotBuild, steps []buildbotStep) cache.Build {
+	repository := cache.Repository{
+		Provider: provider,
+	}
+	if u := b.Properties.get("repository"); u != "" {
+		if host, owner, name, err := utils.RepoHostOwnerAndName(u); err == nil {
+			repository.URL = fmt.Sprintf("https://%s/%s/%s", host, owner, name)
+			repository.Owner, repository.Name = owner, name
+		}
+	}
+
+	startedAt := fromBuildbotTimestamp(b.StartedAt)
+	finishedAt := fromBuildbotTimestamp(b.CompleteAt)
+	build := cache.Build{
+		Repository: &repository,
+		ID:         fmt.Sprintf("%s #%d", builder.Name, b.Number),
+		Commit: cache.Commit{
+			Sha: b.Properties.get("revision", "got_revision"),
+		},
+		Ref:        b.Properties.get("branch"),
+		State:      fromBuildbotResults(b.Complete, b.StartedAt, b.Results),
+		CreatedAt:  startedAt,
+		StartedAt:  startedAt,
+		FinishedAt: finishedAt,
+		UpdatedAt:  utils.MaxNullTime(startedAt, finishedAt).Time,
+		Duration:   utils.NullSub(finishedAt, startedAt),
+		WebURL:     webURL,
+		Stages:     map[int]*cache.Stage{},
+		Jobs:       make([]*cache.Job, 0, len(steps)),
+	}
+
+	for _, step := range steps {
+		if step.Hidden {
+			continue
+		}
+		stepStartedAt := fromBuildbotTimestamp(step.StartedAt)
+		stepFinishedAt := fromBuildbotTimestamp(step.CompleteAt)
+		build.Jobs = append(build.Jobs, &cache.Job{
+			ID:         strconv.Itoa(step.StepID),
+			State:      fromBuildbotResults(step.Complete, step.StartedAt, step.Results),
+			Name:       step.Name,
+			CreatedAt:  stepStartedAt,
+			StartedAt:  stepStartedAt,
+			FinishedAt: stepFinishedAt,
+			Duration:   utils.NullSub(stepFinishedAt, stepStartedAt),
+			WebURL:     webURL,
+		})
+	}
+
+	return build
+}
+
+// Log returns the logs of the step whose ID is 'jobID'. Logs of the standard streams of
+// commands are stripped of the character identifying the stream at the start of each line.
+func (c BuildbotClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	var logs struct {
+		Logs []buildbotLog `json:"logs"`
+	}
+	if err := c.getJSON(ctx, fmt.Sprintf("/steps/%s/logs", url.PathEscape(jobID)), nil, &logs); err != nil {
+		return "", err
+	}
+
+	b := strings.Builder{}
+	for _, log := range logs.Logs {
+		var contents struct {
+			LogChunks []buildbotLogChunk `json:"logchunks"`
+		}
+		if err := c.getJSON(ctx, fmt.Sprintf("/logs/%d/contents", log.LogID), nil, &contents); err != nil {
+			return "", err
+		}
+		if len(logs.Logs) > 1 {
+			fmt.Fprintf(&b, "--- %s\n", log.Name)
+		}
+		for _, chunk := range contents.LogChunks {
+			if log.Type != "s" {
+				b.WriteString(chunk.Content)
+				continue
+			}
+			for _, line := range strings.SplitAfter(chunk.Content, "\n") {
+				if len(line) > 0 {
+					b.WriteString(line[1:])
+				}
+			}
+		}
+	}
+
+	return b.String(), nil
+}
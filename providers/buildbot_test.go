@@ -0,0 +1,187 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func TestParseBuildbotURL(t *testing.T) {
+	baseURL := url.URL{Scheme: "https", Host: "buildbot.example.com", Path: "/bb/"}
+
+	t.Run("Build URLs", func(t *testing.T) {
+		urls := []string{
+			"https://buildbot.example.com/bb/#builders/2/builds/124",
+			"https://buildbot.example.com/bb#/builders/2/builds/124/",
+		}
+		for _, u := range urls {
+			builderID, number, err := parseBuildbotURL(baseURL, u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if builderID != 2 || number != 124 {
+				t.Fatalf("expected builder 2 and build 124 but got %d and %d", builderID, number)
+			}
+		}
+	})
+
+	t.Run("Unknown URLs", func(t *testing.T) {
+		urls := []string{
+			"https://gitlab.com/nbedos/citop/pipelines/97604657",
+			"https://buildbot.example.com/bb/#builders/2",
+			"https://buildbot.example.com/bb/#builders/2/builds/124/steps/1",
+			"https://buildbot.example.com/other/#builders/2/builds/124",
+		}
+		for _, u := range urls {
+			if _, _, err := parseBuildbotURL(baseURL, u); err != cache.ErrUnknownURL {
+				t.Fatalf("expected %v but got %v for URL %q", cache.ErrUnknownURL, err, u)
+			}
+		}
+	})
+}
+
+func newBuildbotTestServer() *httptest.Server {
+	files := map[string]string{
+		"/api/v2/builds":                "buildbot_builds.json",
+		"/api/v2/builders/2":            "buildbot_builder.json",
+		"/api/v2/builders/2/builds/124": "buildbot_build.json",
+		"/api/v2/builds/1042/steps":     "buildbot_steps.json",
+		"/api/v2/steps/5212/logs":       "buildbot_logs.json",
+		"/api/v2/logs/8801/contents":    "buildbot_logchunks.json",
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filename, exists := files[r.URL.Path]
+		if !exists {
+			w.WriteHeader(404)
+			return
+		}
+		bs, err := ioutil.ReadFile(fmt.Sprintf("test_data/%s", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		w.Write(bs)
+	}))
+}
+
+func TestBuildbotClient_BuildURLs(t *testing.T) {
+	ts := newBuildbotTestServer()
+	defer ts.Close()
+
+	baseURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewBuildbotClient("buildbot", "buildbot", *baseURL, time.Millisecond)
+	ctx := context.Background()
+
+	t.Run("Builds of a commit", func(t *testing.T) {
+		urls, err := client.BuildURLs(ctx, "nbedos", "citop", "a24840cf94b395af69da4a1001d32e3694637e20")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{
+			ts.URL + "/#builders/3/builds/57",
+			ts.URL + "/#builders/2/builds/124",
+		}
+		if diff := cmp.Diff(expected, urls); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Unknown repository", func(t *testing.T) {
+		_, err := client.BuildURLs(ctx, "nbedos", "unknown", "a24840cf94b395af69da4a1001d32e3694637e20")
+		if err != cache.ErrRepositoryNotFound {
+			t.Fatalf("expected %v but got %v", cache.ErrRepositoryNotFound, err)
+		}
+	})
+}
+
+func TestBuildbotClient_BuildFromURL(t *testing.T) {
+	ts := newBuildbotTestServer()
+	defer ts.Close()
+
+	baseURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewBuildbotClient("buildbot", "buildbot", *baseURL, time.Millisecond)
+	ctx := context.Background()
+
+	webURL := ts.URL + "/#builders/2/builds/124"
+	build, err := client.BuildFromURL(ctx, webURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := func(seconds int64) utils.NullTime {
+		return utils.NullTime{Time: time.Unix(1580724000+seconds, 0).UTC(), Valid: true}
+	}
+	duration := func(seconds int64) utils.NullDuration {
+		return utils.NullDuration{Duration: time.Duration(seconds) * time.Second, Valid: true}
+	}
+	expected := cache.Build{
+		Repository: &cache.Repository{
+			Provider: cache.Provider{ID: "buildbot", Name: "buildbot"},
+			URL:      "https://github.com/nbedos/citop",
+			Owner:    "nbedos",
+			Name:     "citop",
+		},
+		ID:         "linux-tests #124",
+		Commit:     cache.Commit{Sha: "a24840cf94b395af69da4a1001d32e3694637e20"},
+		Ref:        "master",
+		State:      cache.Failed,
+		CreatedAt:  date(0),
+		StartedAt:  date(0),
+		FinishedAt: date(240),
+		UpdatedAt:  date(240).Time,
+		Duration:   duration(240),
+		WebURL:     webURL,
+		Stages:     map[int]*cache.Stage{},
+		Jobs: []*cache.Job{
+			{
+				ID:         "5211",
+				State:      cache.Passed,
+				Name:       "git",
+				CreatedAt:  date(1),
+				StartedAt:  date(1),
+				FinishedAt: date(10),
+				Duration:   duration(9),
+				WebURL:     webURL,
+			},
+			{
+				ID:         "5212",
+				State:      cache.Failed,
+				Name:       "test",
+				CreatedAt:  date(10),
+				StartedAt:  date(10),
+				FinishedAt: date(240),
+				Duration:   duration(230),
+				WebURL:     webURL,
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, build); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	log, err := client.Log(ctx, *build.Repository, "5212")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLog := "go test ./...\nok  \tgithub.com/nbedos/citop/cache\t0.021s\n--- FAIL: TestGitOriginURL (0.00s)\nprogram finished with exit code 1\n"
+	if log != expectedLog {
+		t.Fatalf("expected log %q but got %q", expectedLog, log)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -22,6 +23,7 @@ type CircleCIClient struct {
 	rateLimiter <-chan time.Time
 	token       string
 	provider    cache.Provider
+	repositoryRestriction
 }
 
 var CircleCIURL = url.URL{
@@ -31,10 +33,10 @@ var CircleCIURL = url.URL{
 	RawPath: "api/v1.1",
 }
 
-func NewCircleCIClient(id string, name string, token string, URL url.URL, rateLimit time.Duration) CircleCIClient {
+func NewCircleCIClient(id string, name string, token string, URL url.URL, rateLimit time.Duration, options ...ClientOption) CircleCIClient {
 	return CircleCIClient{
 		baseURL:     URL,
-		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		httpClient:  newHTTPClient(requestTimeout, options),
 		rateLimiter: time.Tick(rateLimit),
 		token:       token,
 		provider: cache.Provider{
@@ -45,15 +47,27 @@ func NewCircleCIClient(id string, name string, token string, URL url.URL, rateLi
 }
 
 func (c CircleCIClient) get(ctx context.Context, resourceURL url.URL) (*bytes.Buffer, error) {
+	return c.send(ctx, "GET", resourceURL, nil)
+}
+
+// Send a request with a JSON payload unless 'payload' is nil
+func (c CircleCIClient) send(ctx context.Context, method string, resourceURL url.URL, payload []byte) (*bytes.Buffer, error) {
 	parameters := resourceURL.Query()
 	parameters.Add("circle-token", c.token)
 	resourceURL.RawQuery = parameters.Encode()
 
-	req, err := http.NewRequest("GET", resourceURL.String(), nil)
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, resourceURL.String(), reqBody)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Add("Accept", "application/json")
+	if payload != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
 	req.WithContext(ctx)
 
 	select {
@@ -161,6 +175,23 @@ func (c CircleCIClient) ID() string {
 	return c.provider.ID
 }
 
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c CircleCIClient) WithRepositories(repositories RepositoryPatterns) CircleCIClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// CheckAuthentication returns an error if CircleCI rejects the API token
+func (c CircleCIClient) CheckAuthentication(ctx context.Context) error {
+	endpoint := c.baseURL
+	endpoint.Path += "/me"
+	endpoint.RawPath += "/me"
+
+	_, err := c.get(ctx, endpoint)
+	return err
+}
+
 func (c CircleCIClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
 	owner, repo, id, err := parseCircleCIWebURL(&c.baseURL, u)
 	if err != nil {
@@ -176,6 +207,224 @@ func (c CircleCIClient) BuildFromURL(ctx context.Context, u string) (cache.Build
 	return c.fetchBuild(ctx, endPoint, &repository, id, false)
 }
 
+// CancelBuild cancels the build
+func (c CircleCIClient) CancelBuild(ctx context.Context, build cache.Build) error {
+	endpoint := c.projectEndpoint(build.Repository.Owner, build.Repository.Name)
+	endpoint.Path += fmt.Sprintf("/%s/cancel", build.ID)
+	endpoint.RawPath += fmt.Sprintf("/%s/cancel", url.PathEscape(build.ID))
+	_, err := c.send(ctx, "POST", endpoint, nil)
+	return err
+}
+
+// CancelJob is not supported since the steps of a build cannot be canceled on their own
+func (c CircleCIClient) CancelJob(ctx context.Context, build cache.Build, job cache.Job) error {
+	return cache.ErrUnsupportedAction
+}
+
+// RestartBuild starts a new build identical to 'build' and returns its web page
+func (c CircleCIClient) RestartBuild(ctx context.Context, build cache.Build) (string, error) {
+	endpoint := c.projectEndpoint(build.Repository.Owner, build.Repository.Name)
+	endpoint.Path += fmt.Sprintf("/%s/retry", build.ID)
+	endpoint.RawPath += fmt.Sprintf("/%s/retry", url.PathEscape(build.ID))
+	body, err := c.send(ctx, "POST", endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var retried struct {
+		BuildURL string `json:"build_url"`
+	}
+	if err := json.Unmarshal(body.Bytes(), &retried); err != nil {
+		return "", err
+	}
+	return retried.BuildURL, nil
+}
+
+// Return the endpoint of 'resource' of a workflow in version 2 of the API, which is served next
+// to version 1.1
+func (c CircleCIClient) workflowEndpoint(workflowID string, resource string) url.URL {
+	endpoint := c.baseURL
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/v1.1") + fmt.Sprintf("/v2/workflow/%s/%s", workflowID, resource)
+	endpoint.RawPath = strings.TrimSuffix(endpoint.RawPath, "/v1.1") + fmt.Sprintf("/v2/workflow/%s/%s", url.PathEscape(workflowID), resource)
+	return endpoint
+}
+
+// Return the identifier of the workflow of 'build' and the identifier of 'build' in version 2
+// of the API, where it is a job of the workflow
+func (c CircleCIClient) workflowJob(ctx context.Context, build cache.Build) (string, string, error) {
+	endpoint := c.projectEndpoint(build.Repository.Owner, build.Repository.Name)
+	endpoint.Path += fmt.Sprintf("/%s", build.ID)
+	endpoint.RawPath += fmt.Sprintf("/%s", url.PathEscape(build.ID))
+	body, err := c.get(ctx, endpoint)
+	if err != nil {
+		return "", "", err
+	}
+	var circleCIBuild circleCIBuild
+	if err := json.Unmarshal(body.Bytes(), &circleCIBuild); err != nil {
+		return "", "", err
+	}
+	workflowID := circleCIBuild.Workflows.ID
+	if workflowID == "" {
+		return "", "", cache.ErrUnsupportedAction
+	}
+
+	pageToken := ""
+	for {
+		endpoint := c.workflowEndpoint(workflowID, "job")
+		if pageToken != "" {
+			endpoint.RawQuery = url.Values{"page-token": []string{pageToken}}.Encode()
+		}
+		body, err := c.get(ctx, endpoint)
+		if err != nil {
+			return "", "", err
+		}
+		var page struct {
+			Items []struct {
+				ID     string `json:"id"`
+				Number int    `json:"job_number"`
+			} `json:"items"`
+			NextPageToken string `json:"next_page_token"`
+		}
+		if err := json.Unmarshal(body.Bytes(), &page); err != nil {
+			return "", "", err
+		}
+		for _, job := range page.Items {
+			if strconv.Itoa(job.Number) == build.ID {
+				return workflowID, job.ID, nil
+			}
+		}
+		if pageToken = page.NextPageToken; pageToken == "" {
+			return "", "", fmt.Errorf("no job of workflow %q matches build %s", workflowID, build.ID)
+		}
+	}
+}
+
+// RetryJob reruns the build in its workflow. The steps of a build cannot be run on their own so
+// retrying any of them reruns the whole build. CircleCI numbers the new build once it starts so
+// its identifier is not known yet and an empty string is returned.
+func (c CircleCIClient) RetryJob(ctx context.Context, build cache.Build, job cache.Job) (string, error) {
+	workflowID, jobID, err := c.workflowJob(ctx, build)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(struct {
+		Jobs []string `json:"jobs"`
+	}{
+		Jobs: []string{jobID},
+	})
+	if err != nil {
+		return "", err
+	}
+	_, err = c.send(ctx, "POST", c.workflowEndpoint(workflowID, "rerun"), payload)
+	return "", err
+}
+
+// TriggerPipeline starts a build of branch 'ref' of the GitHub repository at 'repositoryURL'
+// with additional build parameters and returns its web page
+func (c CircleCIClient) TriggerPipeline(ctx context.Context, repositoryURL string, ref string, variables []cache.Variable) (string, error) {
+	host, owner, repo, err := utils.RepoHostOwnerAndName(repositoryURL)
+	if err != nil || host != "github.com" {
+		return "", cache.ErrUnknownURL
+	}
+
+	var request struct {
+		BuildParameters map[string]string `json:"build_parameters,omitempty"`
+	}
+	if len(variables) > 0 {
+		request.BuildParameters = make(map[string]string, len(variables))
+		for _, v := range variables {
+			request.BuildParameters[v.Name] = v.Value
+		}
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := c.projectEndpoint(owner, repo)
+	endpoint.Path += fmt.Sprintf("/tree/%s", ref)
+	endpoint.RawPath += fmt.Sprintf("/tree/%s", url.PathEscape(ref))
+	body, err := c.send(ctx, "POST", endpoint, payload)
+	if err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == 404 {
+			return "", cache.ErrRepositoryNotFound
+		}
+		return "", err
+	}
+
+	var build struct {
+		BuildURL string `json:"build_url"`
+	}
+	if err := json.Unmarshal(body.Bytes(), &build); err != nil {
+		return "", err
+	}
+	return build.BuildURL, nil
+}
+
+type circleCIArtifact struct {
+	Path string `json:"path"`
+	URL  string `json:"url"`
+}
+
+// Return the artifacts of a build. The steps of a build share its artifacts.
+func (c CircleCIClient) artifacts(ctx context.Context, build cache.Build) ([]circleCIArtifact, error) {
+	endpoint := c.projectEndpoint(build.Repository.Owner, build.Repository.Name)
+	endpoint.Path += fmt.Sprintf("/%s/artifacts", build.ID)
+	endpoint.RawPath += fmt.Sprintf("/%s/artifacts", url.PathEscape(build.ID))
+	body, err := c.get(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make([]circleCIArtifact, 0)
+	if err := json.Unmarshal(body.Bytes(), &artifacts); err != nil {
+		return nil, err
+	}
+	return artifacts, nil
+}
+
+// Artifacts lists the artifacts of the build of 'job'. CircleCI does not tell their size.
+func (c CircleCIClient) Artifacts(ctx context.Context, build cache.Build, job cache.Job) ([]cache.Artifact, error) {
+	artifacts, err := c.artifacts(ctx, build)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheArtifacts := make([]cache.Artifact, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		cacheArtifacts = append(cacheArtifacts, cache.Artifact{
+			Path:   artifact.Path,
+			Size:   -1,
+			WebURL: artifact.URL,
+		})
+	}
+	return cacheArtifacts, nil
+}
+
+// WriteArtifact writes the content of the artifact of the build of 'job' at 'path' to 'w'
+func (c CircleCIClient) WriteArtifact(ctx context.Context, build cache.Build, job cache.Job, path string, w io.Writer) error {
+	artifacts, err := c.artifacts(ctx, build)
+	if err != nil {
+		return err
+	}
+	for _, artifact := range artifacts {
+		if artifact.Path != path {
+			continue
+		}
+		u, err := url.Parse(artifact.URL)
+		if err != nil {
+			return err
+		}
+		body, err := c.get(ctx, *u)
+		if err != nil {
+			return err
+		}
+		_, err = body.WriteTo(w)
+		return err
+	}
+	return fmt.Errorf("no artifact found at %q", path)
+}
+
 // Extract owner, repository and build ID from web URL of build
 func parseCircleCIWebURL(baseURL *url.URL, u string) (string, string, int, error) {
 	v, err := url.Parse(u)
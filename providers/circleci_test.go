@@ -1,8 +1,18 @@
 package providers
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
 )
 
 func TestParseCircleCIWebURL(t *testing.T) {
@@ -21,3 +31,101 @@ func TestParseCircleCIWebURL(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestCircleCIClient_Artifacts(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("circle-token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/project/gh/nbedos/citop/36/artifacts":
+			fmt.Fprintf(w, `[{"path": "coverage/report.txt", "url": "%s/artifacts/36/report.txt"}]`, ts.URL)
+		case "/artifacts/36/report.txt":
+			fmt.Fprint(w, "coverage: 87%\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	URL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewCircleCIClient("id", "name", "token", *URL, time.Millisecond)
+	build := cache.Build{
+		Repository: &cache.Repository{Owner: "nbedos", Name: "citop"},
+		ID:         "36",
+	}
+	ctx := context.Background()
+
+	artifacts, err := client.Artifacts(ctx, build, cache.Job{ID: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []cache.Artifact{
+		{Path: "coverage/report.txt", Size: -1, WebURL: ts.URL + "/artifacts/36/report.txt"},
+	}
+	if diff := cmp.Diff(expected, artifacts); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	buf := bytes.Buffer{}
+	if err := client.WriteArtifact(ctx, build, cache.Job{ID: "1"}, "coverage/report.txt", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "coverage: 87%\n" {
+		t.Fatalf("unexpected content %q", buf.String())
+	}
+	if err := client.WriteArtifact(ctx, build, cache.Job{ID: "1"}, "missing.txt", &buf); err == nil {
+		t.Fatal("expected an error for an unknown artifact")
+	}
+}
+
+func TestCircleCIClient_RetryJob(t *testing.T) {
+	rerun := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("circle-token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/project/gh/nbedos/citop/36":
+			fmt.Fprint(w, `{"build_num": 36, "workflows": {"job_name": "test", "workflow_id": "a5c4f3e2"}}`)
+		case r.Method == "GET" && r.URL.Path == "/v2/workflow/a5c4f3e2/job" && r.URL.Query().Get("page-token") == "":
+			fmt.Fprint(w, `{"items": [{"id": "0b1c2d3e", "job_number": 35}], "next_page_token": "next"}`)
+		case r.Method == "GET" && r.URL.Path == "/v2/workflow/a5c4f3e2/job" && r.URL.Query().Get("page-token") == "next":
+			fmt.Fprint(w, `{"items": [{"id": "4f5a6b7c", "job_number": 36}], "next_page_token": null}`)
+		case r.Method == "POST" && r.URL.Path == "/v2/workflow/a5c4f3e2/rerun":
+			bs, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			rerun = string(bs)
+			fmt.Fprint(w, `{"workflow_id": "d8e9f0a1"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	URL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewCircleCIClient("id", "name", "token", *URL, time.Millisecond)
+	build := cache.Build{
+		Repository: &cache.Repository{Owner: "nbedos", Name: "citop"},
+		ID:         "36",
+	}
+
+	if _, err := client.RetryJob(context.Background(), build, cache.Job{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"jobs":["4f5a6b7c"]}`; rerun != expected {
+		t.Fatalf("expected payload %q but got %q", expected, rerun)
+	}
+}
@@ -0,0 +1,548 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+	"golang.org/x/oauth2"
+)
+
+// Scope of the OAuth2 tokens used to access Cloud Build, Cloud Storage and Cloud Logging
+const CloudBuildScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// Maximum number of builds of each trigger searched for the builds of a commit. Builds are
+// listed from the most recent one.
+const cloudBuildMaxBuilds = 100
+
+// CloudBuildClient reads the builds of a project of Google Cloud Build. Builds are found
+// through the triggers of the project building the repository, so the client is also a source
+// provider. The steps of a build are shown as jobs whose log is read from the bucket of the
+// build or from Cloud Logging if the build does not store its log in a bucket.
+type CloudBuildClient struct {
+	cloudBuildURL string
+	storageURL    string
+	loggingURL    string
+	project       string
+	httpClient    *http.Client
+	rateLimiter   <-chan time.Time
+	provider      cache.Provider
+	repositoryRestriction
+}
+
+// NewCloudBuildClient returns a client for the project identified by 'project'. Requests are
+// authorized with the tokens of 'tokenSource', usually obtained from the Application Default
+// Credentials of Google Cloud, unless 'tokenSource' is nil.
+func NewCloudBuildClient(id string, name string, tokenSource oauth2.TokenSource, project string, rateLimit time.Duration, options ...ClientOption) CloudBuildClient {
+	httpClient := newHTTPClient(requestTimeout, options)
+	if tokenSource != nil {
+		httpClient.Transport = &oauth2.Transport{Source: tokenSource, Base: httpClient.Transport}
+	}
+	return CloudBuildClient{
+		cloudBuildURL: "https://cloudbuild.googleapis.com",
+		storageURL:    "https://storage.googleapis.com",
+		loggingURL:    "https://logging.googleapis.com",
+		project:       project,
+		httpClient:    httpClient,
+		rateLimiter:   time.Tick(rateLimit),
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (c CloudBuildClient) ID() string {
+	return c.provider.ID
+}
+
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c CloudBuildClient) WithRepositories(repositories RepositoryPatterns) CloudBuildClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// Send a request to 'u' and return the body of the response
+func (c CloudBuildClient) send(ctx context.Context, method string, u string, payload interface{}) ([]byte, error) {
+	body := bytes.NewReader(nil)
+	if payload != nil {
+		bs, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(bs)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: buf.String(),
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Send a GET request to the endpoint 'path' of the API of Cloud Build and decode the response
+// into 'v'
+func (c CloudBuildClient) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	u := c.cloudBuildURL + "/v1" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	body, err := c.send(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// Return the path of an endpoint of the project
+func (c CloudBuildClient) projectPath(format string, a ...interface{}) string {
+	return "/projects/" + url.PathEscape(c.project) + fmt.Sprintf(format, a...)
+}
+
+type cloudBuildTrigger struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	GitHub struct {
+		Owner string `json:"owner"`
+		Name  string `json:"name"`
+	} `json:"github"`
+	TriggerTemplate struct {
+		RepoName string `json:"repoName"`
+	} `json:"triggerTemplate"`
+}
+
+// Return true if the trigger builds the repository 'owner/repo', either directly from GitHub or
+// from its mirror in Cloud Source Repositories
+func (t cloudBuildTrigger) matches(owner string, repo string) bool {
+	if t.GitHub.Name != "" {
+		return strings.EqualFold(t.GitHub.Owner, owner) && strings.EqualFold(t.GitHub.Name, repo)
+	}
+	for _, name := range []string{repo, "github_" + owner + "_" + repo, "bitbucket_" + owner + "_" + repo} {
+		if strings.EqualFold(t.TriggerTemplate.RepoName, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the triggers of the project building the repository 'owner/repo'
+func (c CloudBuildClient) triggers(ctx context.Context, owner string, repo string) ([]cloudBuildTrigger, error) {
+	triggers := make([]cloudBuildTrigger, 0)
+	query := url.Values{}
+	for {
+		var page struct {
+			Triggers      []cloudBuildTrigger `json:"triggers"`
+			NextPageToken string              `json:"nextPageToken"`
+		}
+		if err := c.getJSON(ctx, c.projectPath("/triggers"), query, &page); err != nil {
+			return nil, err
+		}
+		for _, trigger := range page.Triggers {
+			if trigger.matches(owner, repo) {
+				triggers = append(triggers, trigger)
+			}
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+
+	return triggers, nil
+}
+
+// BuildURLs returns the URLs of the builds of commit 'sha' among the most recent builds of the
+// triggers of the project building the repository. ErrRepositoryNotFound is returned if no
+// trigger of the project builds the repository.
+func (c CloudBuildClient) BuildURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
+	triggers, err := c.triggers(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	if len(triggers) == 0 {
+		return nil, cache.ErrRepositoryNotFound
+	}
+
+	urls := make([]string, 0)
+	for _, trigger := range triggers {
+		var builds cloudBuildBuilds
+		query := url.Values{
+			"filter":   []string{fmt.Sprintf("trigger_id=%q", trigger.ID)},
+			"pageSize": []string{strconv.Itoa(cloudBuildMaxBuilds)},
+		}
+		if err := c.getJSON(ctx, c.projectPath("/builds"), query, &builds); err != nil {
+			return nil, err
+		}
+		for _, build := range builds.Builds {
+			if build.sha() == sha {
+				urls = append(urls, build.LogURL)
+			}
+		}
+	}
+
+	return urls, nil
+}
+
+// Commit is not supported since Cloud Build does not host repositories
+func (c CloudBuildClient) Commit(ctx context.Context, repo string, sha string) (utils.Commit, error) {
+	return utils.Commit{}, cache.ErrRepositoryNotFound
+}
+
+// Return the region and the identifier of the build whose page in the console of Google Cloud
+// is at 'u', e.g. https://console.cloud.google.com/cloud-build/builds;region=global/6e6f0f36?project=123456789012
+func parseCloudBuildURL(u string) (string, string, error) {
+	v, err := url.Parse(u)
+	if err != nil || v.Hostname() != "console.cloud.google.com" {
+		return "", "", cache.ErrUnknownURL
+	}
+
+	cs := strings.Split(strings.Trim(v.Path, "/"), "/")
+	if len(cs) != 3 || cs[0] != "cloud-build" || cs[2] == "" {
+		return "", "", cache.ErrUnknownURL
+	}
+	region := "global"
+	switch {
+	case cs[1] == "builds":
+	case strings.HasPrefix(cs[1], "builds;region="):
+		region = strings.TrimPrefix(cs[1], "builds;region=")
+	default:
+		return "", "", cache.ErrUnknownURL
+	}
+
+	return region, cs[2], nil
+}
+
+// Return the path of the endpoint of a build in the API of Cloud Build
+func (c CloudBuildClient) buildPath(region string, id string) string {
+	if region == "global" {
+		return c.projectPath("/builds/%s", url.PathEscape(id))
+	}
+	return c.projectPath("/locations/%s/builds/%s", url.PathEscape(region), url.PathEscape(id))
+}
+
+// BuildFromURL returns the build whose page is at 'u'. The project of the URL may be designated
+// by its number, so builds are looked up in the project of the client and ErrUnknownURL is
+// returned if they belong to another project.
+func (c CloudBuildClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	region, id, err := parseCloudBuildURL(u)
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	var build cloudBuildBuild
+	if err := c.getJSON(ctx, c.buildPath(region, id), nil, &build); err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == http.StatusNotFound {
+			return cache.Build{}, cache.ErrUnknownURL
+		}
+		return cache.Build{}, err
+	}
+
+	return build.toCacheBuild(c.provider, u, region)
+}
+
+type cloudBuildBuilds struct {
+	Builds []cloudBuildBuild `json:"builds"`
+}
+
+type cloudBuildTiming struct {
+	StartTime string `json:"startTime"`
+	EndTime   string `json:"endTime"`
+}
+
+type cloudBuildBuild struct {
+	ID            string            `json:"id"`
+	Status        string            `json:"status"`
+	CreateTime    string            `json:"createTime"`
+	StartTime     string            `json:"startTime"`
+	FinishTime    string            `json:"finishTime"`
+	LogsBucket    string            `json:"logsBucket"`
+	LogURL        string            `json:"logUrl"`
+	Substitutions map[string]string `json:"substitutions"`
+	Source        struct {
+		RepoSource struct {
+			CommitSha string `json:"commitSha"`
+		} `json:"repoSource"`
+	} `json:"source"`
+	SourceProvenance struct {
+		ResolvedRepoSource struct {
+			CommitSha string `json:"commitSha"`
+		} `json:"resolvedRepoSource"`
+	} `json:"sourceProvenance"`
+	Steps []struct {
+		ID         string           `json:"id"`
+		Name       string           `json:"name"`
+		Entrypoint string           `json:"entrypoint"`
+		Args       []string         `json:"args"`
+		Env        []string         `json:"env"`
+		Status     string           `json:"status"`
+		Timing     cloudBuildTiming `json:"timing"`
+	} `json:"steps"`
+}
+
+// Return the commit built by the build
+func (b cloudBuildBuild) sha() string {
+	for _, sha := range []string{b.SourceProvenance.ResolvedRepoSource.CommitSha, b.Source.RepoSource.CommitSha} {
+		if sha != "" {
+			return sha
+		}
+	}
+	return b.Substitutions["COMMIT_SHA"]
+}
+
+// Return the state of a build or of a step
+func fromCloudBuildStatus(status string) cache.State {
+	switch status {
+	case "QUEUED", "PENDING":
+		return cache.Pending
+	case "WORKING":
+		return cache.Running
+	case "SUCCESS":
+		return cache.Passed
+	case "FAILURE", "INTERNAL_ERROR", "TIMEOUT", "EXPIRED":
+		return cache.Failed
+	case "CANCELLED":
+		return cache.Canceled
+	}
+	return cache.Unknown
+}
+
+// Return the build as a pipeline whose jobs are the steps of the build. Job identifiers are made
+// of the region of the build, the identifier of the build and the index of the step.
+func (b cloudBuildBuild) toCacheBuild(provider cache.Provider, webURL string, region string) (cache.Build, error) {
+	repository := cache.Repository{Provider: provider}
+	if u := b.Substitutions["_HEAD_REPO_URL"]; u != "" {
+		if _, owner, name, err := utils.RepoHostOwnerAndName(u); err == nil {
+			repository.URL, repository.Owner, repository.Name = u, owner, name
+		}
+	}
+
+	build := cache.Build{
+		Repository:      &repository,
+		ID:              b.ID,
+		Commit:          cache.Commit{Sha: b.sha()},
+		RepoBuildNumber: b.ID,
+		State:           fromCloudBuildStatus(b.Status),
+		WebURL:          webURL,
+		Stages:          map[int]*cache.Stage{},
+	}
+	if i := strings.Index(b.ID, "-"); i > 0 {
+		build.RepoBuildNumber = b.ID[:i]
+	}
+	if tag := b.Substitutions["TAG_NAME"]; tag != "" {
+		build.Ref, build.IsTag = tag, true
+	} else {
+		build.Ref = b.Substitutions["BRANCH_NAME"]
+	}
+
+	var err error
+	if build.CreatedAt, err = utils.NullTimeFromString(b.CreateTime); err != nil {
+		return build, err
+	}
+	if build.StartedAt, err = utils.NullTimeFromString(b.StartTime); err != nil {
+		return build, err
+	}
+	if build.FinishedAt, err = utils.NullTimeFromString(b.FinishTime); err != nil {
+		return build, err
+	}
+	build.UpdatedAt = utils.MaxNullTime(build.CreatedAt, build.StartedAt, build.FinishedAt).Time
+	build.Duration = utils.NullSub(build.FinishedAt, build.StartedAt)
+
+	substitutions := make([]cache.Variable, 0, len(b.Substitutions))
+	for name, value := range b.Substitutions {
+		substitutions = append(substitutions, cache.Variable{Name: name, Value: value})
+	}
+	sort.Slice(substitutions, func(i, j int) bool {
+		return substitutions[i].Name < substitutions[j].Name
+	})
+
+	for i, step := range b.Steps {
+		job := cache.Job{
+			ID:     fmt.Sprintf("%s/%s/%d", region, b.ID, i),
+			State:  fromCloudBuildStatus(step.Status),
+			Name:   step.ID,
+			WebURL: webURL,
+		}
+		if job.Name == "" {
+			job.Name = step.Name
+		}
+		if job.StartedAt, err = utils.NullTimeFromString(step.Timing.StartTime); err != nil {
+			return build, err
+		}
+		if job.FinishedAt, err = utils.NullTimeFromString(step.Timing.EndTime); err != nil {
+			return build, err
+		}
+		job.CreatedAt = job.StartedAt
+		job.Duration = utils.NullSub(job.FinishedAt, job.StartedAt)
+		switch {
+		case build.State == cache.Canceled && job.State.IsActive():
+			job.State = cache.Canceled
+		case job.State == cache.Unknown && build.State.IsActive():
+			job.State = cache.Pending
+		case job.State == cache.Unknown || (job.State == cache.Pending && !build.State.IsActive()):
+			job.State = cache.Skipped
+		}
+
+		command := step.Args
+		if step.Entrypoint != "" {
+			command = append([]string{step.Entrypoint}, command...)
+		}
+		if len(command) > 0 {
+			job.Steps = []cache.Step{{Command: strings.Join(command, " ")}}
+		}
+		for _, v := range step.Env {
+			if i := strings.Index(v, "="); i > 0 {
+				job.Variables = append(job.Variables, cache.Variable{Name: v[:i], Value: v[i+1:]})
+			}
+		}
+		job.Variables = append(job.Variables, substitutions...)
+
+		build.Jobs = append(build.Jobs, &job)
+	}
+
+	return build, nil
+}
+
+// Prefix of the lines written by a step to the log of a build, e.g. 'Step #1 - "test": '
+var cloudBuildStepPrefix = regexp.MustCompile(`^Step #(\d+)(?: - "[^"]*")?: ?`)
+
+// Log returns the output of the step designated by 'jobID'
+func (c CloudBuildClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	cs := strings.Split(jobID, "/")
+	if len(cs) != 3 {
+		return "", fmt.Errorf("invalid job identifier %q", jobID)
+	}
+	region, buildID := cs[0], cs[1]
+	step, err := strconv.Atoi(cs[2])
+	if err != nil {
+		return "", fmt.Errorf("invalid job identifier %q", jobID)
+	}
+
+	var build cloudBuildBuild
+	if err := c.getJSON(ctx, c.buildPath(region, buildID), nil, &build); err != nil {
+		return "", err
+	}
+
+	var lines []string
+	if build.LogsBucket != "" {
+		lines, err = c.bucketLog(ctx, build.LogsBucket, buildID)
+	} else {
+		lines, err = c.loggingLog(ctx, buildID, step)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	b := strings.Builder{}
+	for _, line := range lines {
+		match := cloudBuildStepPrefix.FindStringSubmatch(line)
+		if match == nil || match[1] != strconv.Itoa(step) {
+			continue
+		}
+		b.WriteString(strings.TrimPrefix(line, match[0]))
+		b.WriteString("\n")
+	}
+
+	return b.String(), nil
+}
+
+// Return the lines of the log of the build stored in 'bucket', e.g. "gs://123456789012.cloudbuild-logs.googleusercontent.com"
+func (c CloudBuildClient) bucketLog(ctx context.Context, bucket string, buildID string) ([]string, error) {
+	bucket = strings.TrimPrefix(bucket, "gs://")
+	object := fmt.Sprintf("log-%s.txt", buildID)
+	if i := strings.Index(bucket, "/"); i >= 0 {
+		bucket, object = bucket[:i], strings.Trim(bucket[i+1:], "/")+"/"+object
+	}
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", c.storageURL, url.PathEscape(bucket), url.PathEscape(object))
+
+	body, err := c.send(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(body), "\n"), "\n"), nil
+}
+
+// Return the lines of the log of a step of the build stored in Cloud Logging
+func (c CloudBuildClient) loggingLog(ctx context.Context, buildID string, step int) ([]string, error) {
+	lines := make([]string, 0)
+	request := struct {
+		ResourceNames []string `json:"resourceNames"`
+		Filter        string   `json:"filter"`
+		OrderBy       string   `json:"orderBy"`
+		PageSize      int      `json:"pageSize"`
+		PageToken     string   `json:"pageToken,omitempty"`
+	}{
+		ResourceNames: []string{"projects/" + c.project},
+		Filter:        fmt.Sprintf(`resource.type="build" AND resource.labels.build_id=%q AND labels.build_step="Step #%d"`, buildID, step),
+		OrderBy:       "timestamp asc",
+		PageSize:      1000,
+	}
+	for {
+		body, err := c.send(ctx, "POST", c.loggingURL+"/v2/entries:list", request)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Entries []struct {
+				TextPayload string `json:"textPayload"`
+			} `json:"entries"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+		for _, entry := range page.Entries {
+			// Entries are not always prefixed by the step like the lines of the log of the bucket
+			line := strings.TrimSuffix(entry.TextPayload, "\n")
+			if !cloudBuildStepPrefix.MatchString(line) {
+				line = fmt.Sprintf("Step #%d: %s", step, line)
+			}
+			lines = append(lines, line)
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		request.PageToken = page.NextPageToken
+	}
+
+	return lines, nil
+}
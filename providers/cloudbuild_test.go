@@ -0,0 +1,232 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func newCloudBuildTestClient(t *testing.T) (CloudBuildClient, *httptest.Server) {
+	files := map[string]string{
+		"/v1/projects/citop-ci/triggers":                                                                                  "cloudbuild_triggers.json",
+		"/v1/projects/citop-ci/builds":                                                                                    "cloudbuild_builds.json",
+		"/v1/projects/citop-ci/builds/6e6f0f36-8b5a-4d9c-9a3e-1f2b3c4d5e6f":                                               "cloudbuild_build.json",
+		"/v1/projects/citop-ci/locations/europe-west1/builds/9c8b7a6f-5e4d-4c3b-2a1f-0e9d8c7b6a5f":                        "cloudbuild_build_logging.json",
+		"/storage/v1/b/123456789012.cloudbuild-logs.googleusercontent.com/o/log-6e6f0f36-8b5a-4d9c-9a3e-1f2b3c4d5e6f.txt": "cloudbuild_log.txt",
+		"/v2/entries:list": "cloudbuild_entries.json",
+	}
+
+	ts := newFixtureServer(t, files, func(w http.ResponseWriter, r *http.Request) bool {
+		// Logs are the only resource listed with a POST request
+		if (r.Method == "POST") != (r.URL.Path == "/v2/entries:list") {
+			w.WriteHeader(404)
+			return true
+		}
+		if r.URL.Path == "/v1/projects/citop-ci/builds" && r.URL.Query().Get("filter") != `trigger_id="0f7e3c9a-1b2d-4e5f-8a6b-7c8d9e0f1a2b"` {
+			fmt.Fprint(w, "{}")
+			return true
+		}
+		return false
+	})
+
+	client := NewCloudBuildClient("cloudbuild", "cloudbuild", nil, "citop-ci", time.Millisecond)
+	client.cloudBuildURL = ts.URL
+	client.storageURL = ts.URL
+	client.loggingURL = ts.URL
+
+	return client, ts
+}
+
+const cloudBuildTestURL = "https://console.cloud.google.com/cloud-build/builds/6e6f0f36-8b5a-4d9c-9a3e-1f2b3c4d5e6f?project=123456789012"
+
+func TestCloudBuildClient_BuildURLs(t *testing.T) {
+	client, ts := newCloudBuildTestClient(t)
+	defer ts.Close()
+	ctx := context.Background()
+
+	t.Run("Builds of a commit", func(t *testing.T) {
+		urls, err := client.BuildURLs(ctx, "nbedos", "citop", "a24840cf94b395af69da4a1001d32e3694637e20")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{cloudBuildTestURL}, urls); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Unknown repository", func(t *testing.T) {
+		_, err := client.BuildURLs(ctx, "nbedos", "unknown", "a24840cf94b395af69da4a1001d32e3694637e20")
+		if err != cache.ErrRepositoryNotFound {
+			t.Fatalf("expected %v but got %v", cache.ErrRepositoryNotFound, err)
+		}
+	})
+}
+
+func TestCloudBuildClient_BuildFromURL(t *testing.T) {
+	client, ts := newCloudBuildTestClient(t)
+	defer ts.Close()
+
+	build, err := client.BuildFromURL(context.Background(), cloudBuildTestURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(s string) utils.NullTime {
+		t, err := time.Parse(time.RFC3339, s)
+		return utils.NullTime{Time: t, Valid: err == nil}
+	}
+	substitutions := []cache.Variable{
+		{Name: "BRANCH_NAME", Value: "master"},
+		{Name: "COMMIT_SHA", Value: "a24840cf94b395af69da4a1001d32e3694637e20"},
+		{Name: "REPO_NAME", Value: "citop"},
+	}
+	expected := cache.Build{
+		Repository:      &cache.Repository{Provider: cache.Provider{ID: "cloudbuild", Name: "cloudbuild"}},
+		ID:              "6e6f0f36-8b5a-4d9c-9a3e-1f2b3c4d5e6f",
+		Commit:          cache.Commit{Sha: "a24840cf94b395af69da4a1001d32e3694637e20"},
+		Ref:             "master",
+		RepoBuildNumber: "6e6f0f36",
+		State:           cache.Failed,
+		CreatedAt:       at("2019-12-04T10:00:00.123456Z"),
+		StartedAt:       at("2019-12-04T10:00:05Z"),
+		FinishedAt:      at("2019-12-04T10:02:05Z"),
+		UpdatedAt:       at("2019-12-04T10:02:05Z").Time,
+		Duration:        utils.NullDuration{Duration: 2 * time.Minute, Valid: true},
+		WebURL:          cloudBuildTestURL,
+		Stages:          map[int]*cache.Stage{},
+		Jobs: []*cache.Job{
+			{
+				ID:         "global/6e6f0f36-8b5a-4d9c-9a3e-1f2b3c4d5e6f/0",
+				State:      cache.Passed,
+				Name:       "build",
+				CreatedAt:  at("2019-12-04T10:00:10Z"),
+				StartedAt:  at("2019-12-04T10:00:10Z"),
+				FinishedAt: at("2019-12-04T10:01:00Z"),
+				Duration:   utils.NullDuration{Duration: 50 * time.Second, Valid: true},
+				WebURL:     cloudBuildTestURL,
+				Steps:      []cache.Step{{Command: "go build ./..."}},
+				Variables:  append([]cache.Variable{{Name: "GOFLAGS", Value: "-mod=vendor"}}, substitutions...),
+			},
+			{
+				ID:         "global/6e6f0f36-8b5a-4d9c-9a3e-1f2b3c4d5e6f/1",
+				State:      cache.Failed,
+				Name:       "golang:1.13",
+				CreatedAt:  at("2019-12-04T10:01:00Z"),
+				StartedAt:  at("2019-12-04T10:01:00Z"),
+				FinishedAt: at("2019-12-04T10:02:00Z"),
+				Duration:   utils.NullDuration{Duration: time.Minute, Valid: true},
+				WebURL:     cloudBuildTestURL,
+				Steps:      []cache.Step{{Command: "bash -c go test ./..."}},
+				Variables:  substitutions,
+			},
+			{
+				ID:        "global/6e6f0f36-8b5a-4d9c-9a3e-1f2b3c4d5e6f/2",
+				State:     cache.Skipped,
+				Name:      "push",
+				WebURL:    cloudBuildTestURL,
+				Steps:     []cache.Step{{Command: "push gcr.io/citop-ci/citop"}},
+				Variables: substitutions,
+			},
+		},
+	}
+
+	if diff := cmp.Diff(expected, build); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("Build of another project", func(t *testing.T) {
+		u := strings.Replace(cloudBuildTestURL, "6e6f0f36", "00000000", 1)
+		if _, err := client.BuildFromURL(context.Background(), u); err != cache.ErrUnknownURL {
+			t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+		}
+	})
+}
+
+func TestCloudBuildClient_Log(t *testing.T) {
+	client, ts := newCloudBuildTestClient(t)
+	defer ts.Close()
+
+	testCases := []struct {
+		name     string
+		jobID    string
+		expected string
+	}{
+		{
+			name:     "Log stored in a bucket",
+			jobID:    "global/6e6f0f36-8b5a-4d9c-9a3e-1f2b3c4d5e6f/1",
+			expected: "Already have image (with digest): golang:1.13\n--- FAIL: TestCloudBuildClient (0.00s)\nFAIL\n",
+		},
+		{
+			name:     "Log stored in a bucket of a step with an identifier",
+			jobID:    "global/6e6f0f36-8b5a-4d9c-9a3e-1f2b3c4d5e6f/0",
+			expected: "Already have image (with digest): golang:1.13\n",
+		},
+		{
+			name:     "Log stored in Cloud Logging",
+			jobID:    "europe-west1/9c8b7a6f-5e4d-4c3b-2a1f-0e9d8c7b6a5f/1",
+			expected: "Already have image (with digest): golang:1.13\n--- FAIL: TestCloudBuildClient (0.00s)\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			log, err := client.Log(context.Background(), cache.Repository{}, testCase.jobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.expected, log); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestParseCloudBuildURL(t *testing.T) {
+	testCases := []struct {
+		url    string
+		region string
+		id     string
+	}{
+		{
+			url:    cloudBuildTestURL,
+			region: "global",
+			id:     "6e6f0f36-8b5a-4d9c-9a3e-1f2b3c4d5e6f",
+		},
+		{
+			url:    "https://console.cloud.google.com/cloud-build/builds;region=europe-west1/9c8b7a6f-5e4d-4c3b-2a1f-0e9d8c7b6a5f?project=citop-ci",
+			region: "europe-west1",
+			id:     "9c8b7a6f-5e4d-4c3b-2a1f-0e9d8c7b6a5f",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			region, id, err := parseCloudBuildURL(testCase.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if region != testCase.region || id != testCase.id {
+				t.Fatalf("expected (%q, %q) but got (%q, %q)", testCase.region, testCase.id, region, id)
+			}
+		})
+	}
+
+	for _, u := range []string{
+		"https://github.com/nbedos/citop",
+		"https://console.cloud.google.com/cloud-build/triggers?project=citop-ci",
+	} {
+		t.Run(u, func(t *testing.T) {
+			if _, _, err := parseCloudBuildURL(u); err != cache.ErrUnknownURL {
+				t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,484 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/codebuild"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// Maximum number of builds of each project searched for the builds of a commit. Builds are
+// listed from the most recent one.
+const codeBuildMaxBuilds = 100
+
+// CodeBuildClient reads the builds of the projects of AWS CodeBuild in a region. CodeBuild only
+// reports the results of builds to GitHub and Bitbucket when configured to do so, so the client
+// is also a source provider listing the builds of a commit. The phases of a build are shown as
+// jobs whose log is the part of the log of the build stored in CloudWatch Logs written during
+// the phase.
+type CodeBuildClient struct {
+	codeBuild   *codebuild.CodeBuild
+	logs        *cloudwatchlogs.CloudWatchLogs
+	region      string
+	rateLimiter <-chan time.Time
+	provider    cache.Provider
+	repositoryRestriction
+}
+
+// NewCodeBuildClient returns a client for the region of the session 'sess'. Credentials are
+// those of the session, usually obtained from the default credential chain of AWS.
+func NewCodeBuildClient(id string, name string, sess *session.Session, rateLimit time.Duration, options ...ClientOption) CodeBuildClient {
+	sess = sess.Copy(aws.NewConfig().WithHTTPClient(newHTTPClient(requestTimeout, options)))
+	return CodeBuildClient{
+		codeBuild:   codebuild.New(sess),
+		logs:        cloudwatchlogs.New(sess),
+		region:      aws.StringValue(sess.Config.Region),
+		rateLimiter: time.Tick(rateLimit),
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (c CodeBuildClient) ID() string {
+	return c.provider.ID
+}
+
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c CodeBuildClient) WithRepositories(repositories RepositoryPatterns) CodeBuildClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// Wait until the rate limit allows another request
+func (c CodeBuildClient) wait(ctx context.Context) error {
+	select {
+	case <-c.rateLimiter:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ErrorStatusCode returns the status of the HTTP response that caused 'err'
+func (c CodeBuildClient) ErrorStatusCode(err error) (int, bool) {
+	if err, ok := err.(awserr.RequestFailure); ok && err.StatusCode() != 0 {
+		return err.StatusCode(), true
+	}
+	return 0, false
+}
+
+// Return the projects whose source is the repository 'owner/repo'
+func (c CodeBuildClient) projects(ctx context.Context, owner string, repo string) ([]*codebuild.Project, error) {
+	names := make([]*string, 0)
+	input := codebuild.ListProjectsInput{}
+	for {
+		if err := c.wait(ctx); err != nil {
+			return nil, err
+		}
+		output, err := c.codeBuild.ListProjectsWithContext(ctx, &input)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, output.Projects...)
+		if aws.StringValue(output.NextToken) == "" {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+
+	projects := make([]*codebuild.Project, 0)
+	// BatchGetProjects accepts at most 100 names
+	for len(names) > 0 {
+		n := len(names)
+		if n > 100 {
+			n = 100
+		}
+		if err := c.wait(ctx); err != nil {
+			return nil, err
+		}
+		output, err := c.codeBuild.BatchGetProjectsWithContext(ctx, &codebuild.BatchGetProjectsInput{
+			Names: names[:n],
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range output.Projects {
+			if project.Source == nil {
+				continue
+			}
+			_, o, r, err := utils.RepoHostOwnerAndName(aws.StringValue(project.Source.Location))
+			if err == nil && strings.EqualFold(o, owner) && strings.EqualFold(r, repo) {
+				projects = append(projects, project)
+			}
+		}
+		names = names[n:]
+	}
+
+	return projects, nil
+}
+
+// Return the builds identified by 'ids'
+func (c CodeBuildClient) builds(ctx context.Context, ids []*string) ([]*codebuild.Build, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+	output, err := c.codeBuild.BatchGetBuildsWithContext(ctx, &codebuild.BatchGetBuildsInput{
+		Ids: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Builds, nil
+}
+
+// BuildURLs returns the URLs of the builds of commit 'sha' among the most recent builds of the
+// projects whose source is the repository. ErrRepositoryNotFound is returned if no project of
+// the region builds the repository.
+func (c CodeBuildClient) BuildURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
+	projects, err := c.projects(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	if len(projects) == 0 {
+		return nil, cache.ErrRepositoryNotFound
+	}
+
+	urls := make([]string, 0)
+	for _, project := range projects {
+		if err := c.wait(ctx); err != nil {
+			return nil, err
+		}
+		output, err := c.codeBuild.ListBuildsForProjectWithContext(ctx, &codebuild.ListBuildsForProjectInput{
+			ProjectName: project.Name,
+			SortOrder:   aws.String(codebuild.SortOrderTypeDescending),
+		})
+		if err != nil {
+			return nil, err
+		}
+		ids := output.Ids
+		if len(ids) > codeBuildMaxBuilds {
+			ids = ids[:codeBuildMaxBuilds]
+		}
+		builds, err := c.builds(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, build := range builds {
+			if aws.StringValue(build.ResolvedSourceVersion) == sha || aws.StringValue(build.SourceVersion) == sha {
+				urls = append(urls, codeBuildWebURL(c.region, build))
+			}
+		}
+	}
+
+	return urls, nil
+}
+
+// Commit is not supported since CodeBuild does not host repositories
+func (c CodeBuildClient) Commit(ctx context.Context, repo string, sha string) (utils.Commit, error) {
+	return utils.Commit{}, cache.ErrRepositoryNotFound
+}
+
+// Return the URL of the page of a build in the AWS console
+func codeBuildWebURL(region string, build *codebuild.Build) string {
+	u := url.URL{
+		Scheme:   "https",
+		Host:     region + ".console.aws.amazon.com",
+		Path:     fmt.Sprintf("/codesuite/codebuild/projects/%s/build/%s/", aws.StringValue(build.ProjectName), aws.StringValue(build.Id)),
+		RawQuery: url.Values{"region": []string{region}}.Encode(),
+	}
+	return u.String()
+}
+
+// Return the region and the identifier of the build whose page in the AWS console is at 'u',
+// e.g. https://us-east-1.console.aws.amazon.com/codesuite/codebuild/projects/citop/build/citop:2c2b0d3c/?region=us-east-1
+func parseCodeBuildURL(u string) (string, string, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return "", "", cache.ErrUnknownURL
+	}
+	host := v.Hostname()
+	if host != "console.aws.amazon.com" && !strings.HasSuffix(host, ".console.aws.amazon.com") {
+		return "", "", cache.ErrUnknownURL
+	}
+	region := v.Query().Get("region")
+	if region == "" && host != "console.aws.amazon.com" {
+		region = strings.TrimSuffix(host, ".console.aws.amazon.com")
+	}
+
+	// The path may include the identifier of the account before "projects"
+	cs := strings.Split(strings.Trim(v.Path, "/"), "/")
+	if len(cs) < 6 || cs[0] != "codesuite" || cs[1] != "codebuild" {
+		return "", "", cache.ErrUnknownURL
+	}
+	for i := 2; i+3 < len(cs); i++ {
+		if cs[i] == "projects" && cs[i+2] == "build" && strings.HasPrefix(cs[i+3], cs[i+1]+":") {
+			return region, cs[i+3], nil
+		}
+	}
+	return "", "", cache.ErrUnknownURL
+}
+
+func (c CodeBuildClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	region, id, err := parseCodeBuildURL(u)
+	if err != nil {
+		return cache.Build{}, err
+	}
+	if region != c.region {
+		return cache.Build{}, cache.ErrUnknownURL
+	}
+
+	builds, err := c.builds(ctx, []*string{aws.String(id)})
+	if err != nil {
+		return cache.Build{}, err
+	}
+	if len(builds) == 0 {
+		return cache.Build{}, cache.ErrUnknownURL
+	}
+
+	return fromCodeBuildBuild(c.provider, u, builds[0]), nil
+}
+
+// Phases of a build in order of execution. COMPLETED marks the end of the build and is not
+// shown as a job.
+var codeBuildPhases = []string{"SUBMITTED", "QUEUED", "PROVISIONING", "DOWNLOAD_SOURCE",
+	"INSTALL", "PRE_BUILD", "BUILD", "POST_BUILD", "UPLOAD_ARTIFACTS", "FINALIZING"}
+
+// Return the state of a build or of a phase
+func fromCodeBuildStatus(status string) cache.State {
+	switch status {
+	case codebuild.StatusTypeInProgress:
+		return cache.Running
+	case codebuild.StatusTypeSucceeded:
+		return cache.Passed
+	case codebuild.StatusTypeFailed, codebuild.StatusTypeFault, codebuild.StatusTypeTimedOut:
+		return cache.Failed
+	case codebuild.StatusTypeStopped:
+		return cache.Canceled
+	}
+	return cache.Unknown
+}
+
+func nullTimeFromAWS(t *time.Time) utils.NullTime {
+	if t == nil {
+		return utils.NullTime{}
+	}
+	return utils.NullTime{Time: *t, Valid: true}
+}
+
+// Return the commands of each phase of the buildspec of the build if it is defined inline in
+// the project. Buildspecs stored in the repository are not read.
+func codeBuildCommands(b *codebuild.Build) map[string][]string {
+	commands := make(map[string][]string)
+	if b.Source == nil || !strings.Contains(aws.StringValue(b.Source.Buildspec), "\n") {
+		return commands
+	}
+	var buildspec struct {
+		Phases map[string]struct {
+			Commands []string `yaml:"commands"`
+		} `yaml:"phases"`
+	}
+	if err := yaml.Unmarshal([]byte(aws.StringValue(b.Source.Buildspec)), &buildspec); err != nil {
+		return commands
+	}
+	for phase, p := range buildspec.Phases {
+		commands[strings.ToUpper(phase)] = p.Commands
+	}
+	return commands
+}
+
+// Return the build as a pipeline whose jobs are the phases of the build
+func fromCodeBuildBuild(provider cache.Provider, webURL string, b *codebuild.Build) cache.Build {
+	repository := cache.Repository{Provider: provider}
+	if b.Source != nil {
+		repository.URL = aws.StringValue(b.Source.Location)
+		if _, owner, name, err := utils.RepoHostOwnerAndName(repository.URL); err == nil {
+			repository.Owner, repository.Name = owner, name
+		}
+	}
+
+	build := cache.Build{
+		Repository:      &repository,
+		ID:              aws.StringValue(b.Id),
+		Commit:          cache.Commit{Sha: aws.StringValue(b.ResolvedSourceVersion)},
+		RepoBuildNumber: fmt.Sprintf("%s #%d", aws.StringValue(b.ProjectName), aws.Int64Value(b.BuildNumber)),
+		State:           fromCodeBuildStatus(aws.StringValue(b.BuildStatus)),
+		CreatedAt:       nullTimeFromAWS(b.StartTime),
+		StartedAt:       nullTimeFromAWS(b.StartTime),
+		FinishedAt:      nullTimeFromAWS(b.EndTime),
+		WebURL:          webURL,
+		Stages:          map[int]*cache.Stage{},
+	}
+	build.UpdatedAt = utils.MaxNullTime(build.StartedAt, build.FinishedAt).Time
+	build.Duration = utils.NullSub(build.FinishedAt, build.StartedAt)
+	switch ref := aws.StringValue(b.SourceVersion); {
+	case strings.HasPrefix(ref, "refs/tags/"):
+		build.Ref, build.IsTag = strings.TrimPrefix(ref, "refs/tags/"), true
+	case ref != build.Commit.Sha:
+		build.Ref = strings.TrimPrefix(ref, "refs/heads/")
+	}
+	switch aws.StringValue(b.CurrentPhase) {
+	case "SUBMITTED", "QUEUED":
+		if build.State == cache.Running {
+			build.State = cache.Pending
+		}
+	}
+
+	variables := make([]cache.Variable, 0)
+	image := ""
+	if b.Environment != nil {
+		image = aws.StringValue(b.Environment.Image)
+		for _, v := range b.Environment.EnvironmentVariables {
+			// Values of other types are references to secrets
+			if aws.StringValue(v.Type) == codebuild.EnvironmentVariableTypePlaintext {
+				variables = append(variables, cache.Variable{
+					Name:  aws.StringValue(v.Name),
+					Value: aws.StringValue(v.Value),
+				})
+			}
+		}
+	}
+	commands := codeBuildCommands(b)
+
+	newJob := func(phase string) *cache.Job {
+		job := cache.Job{
+			ID:        fmt.Sprintf("%s/%s", build.ID, phase),
+			State:     cache.Pending,
+			Name:      phase,
+			WebURL:    webURL,
+			Variables: variables,
+			OS:        image,
+		}
+		for _, command := range commands[phase] {
+			job.Steps = append(job.Steps, cache.Step{Command: command})
+		}
+		return &job
+	}
+
+	seen := make(map[string]bool)
+	for _, phase := range b.Phases {
+		name := aws.StringValue(phase.PhaseType)
+		if name == codebuild.BuildPhaseTypeCompleted {
+			continue
+		}
+		seen[name] = true
+		job := newJob(name)
+		job.CreatedAt = nullTimeFromAWS(phase.StartTime)
+		job.StartedAt = job.CreatedAt
+		job.FinishedAt = nullTimeFromAWS(phase.EndTime)
+		job.Duration = utils.NullSub(job.FinishedAt, job.StartedAt)
+		if job.State = fromCodeBuildStatus(aws.StringValue(phase.PhaseStatus)); job.State == cache.Unknown {
+			// The status of the current phase is only known once the phase is over
+			job.State = cache.Running
+			if build.State == cache.Canceled {
+				job.State = cache.Canceled
+			}
+		}
+		build.Jobs = append(build.Jobs, job)
+	}
+	// Phases that did not start yet
+	if build.State.IsActive() {
+		for _, phase := range codeBuildPhases {
+			if !seen[phase] {
+				build.Jobs = append(build.Jobs, newJob(phase))
+			}
+		}
+	}
+
+	return build
+}
+
+// Beginning of the lines written by CodeBuild to the log when a phase starts
+var codeBuildPhaseLine = regexp.MustCompile(`Entering phase ([A-Z_]+)`)
+
+// Log returns the part of the log of the build written during the phase designated by 'jobID'
+func (c CodeBuildClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	i := strings.LastIndex(jobID, "/")
+	if i < 0 {
+		return "", fmt.Errorf("invalid job identifier %q", jobID)
+	}
+	buildID, phase := jobID[:i], jobID[i+1:]
+
+	builds, err := c.builds(ctx, []*string{aws.String(buildID)})
+	if err != nil {
+		return "", err
+	}
+	if len(builds) == 0 {
+		return "", fmt.Errorf("build %q not found", buildID)
+	}
+	logs := builds[0].Logs
+	if logs == nil || logs.GroupName == nil || logs.StreamName == nil {
+		return "", fmt.Errorf("the log of build %q is not stored in CloudWatch Logs", buildID)
+	}
+
+	lines, err := c.logEvents(ctx, aws.StringValue(logs.GroupName), aws.StringValue(logs.StreamName))
+	if err != nil {
+		return "", err
+	}
+
+	return codeBuildPhaseLog(lines, phase), nil
+}
+
+// Return the messages of the log stream in chronological order
+func (c CodeBuildClient) logEvents(ctx context.Context, group string, stream string) ([]string, error) {
+	messages := make([]string, 0)
+	input := cloudwatchlogs.GetLogEventsInput{
+		LogGroupName:  aws.String(group),
+		LogStreamName: aws.String(stream),
+		StartFromHead: aws.Bool(true),
+	}
+	for {
+		if err := c.wait(ctx); err != nil {
+			return nil, err
+		}
+		output, err := c.logs.GetLogEventsWithContext(ctx, &input)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range output.Events {
+			messages = append(messages, aws.StringValue(event.Message))
+		}
+		// The end of the stream is reached once the token stops changing
+		if output.NextForwardToken == nil || aws.StringValue(output.NextForwardToken) == aws.StringValue(input.NextToken) {
+			break
+		}
+		input.NextToken = output.NextForwardToken
+	}
+	return messages, nil
+}
+
+// Return the messages of the log written during 'phase'. Messages written before the first
+// phase change belong to the download of the source.
+func codeBuildPhaseLog(messages []string, phase string) string {
+	b := strings.Builder{}
+	current := "DOWNLOAD_SOURCE"
+	for _, message := range messages {
+		if match := codeBuildPhaseLine.FindStringSubmatch(message); match != nil {
+			current = match[1]
+		}
+		if current != phase {
+			continue
+		}
+		b.WriteString(message)
+		if !strings.HasSuffix(message, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,238 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func newCodeBuildTestClient(t *testing.T) (CodeBuildClient, *httptest.Server) {
+	files := map[string]string{
+		"/CodeBuild_20161006.ListProjects":         "codebuild_projects.json",
+		"/CodeBuild_20161006.BatchGetProjects":     "codebuild_batch_projects.json",
+		"/CodeBuild_20161006.ListBuildsForProject": "codebuild_builds_for_project.json",
+		"/CodeBuild_20161006.BatchGetBuilds":       "codebuild_batch_builds.json",
+		"/Logs_20140328.GetLogEvents":              "codebuild_log_events.json",
+	}
+
+	ts := newFixtureServer(t, files, func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential=AKID/") {
+			w.WriteHeader(403)
+			return true
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(500)
+			return true
+		}
+		// The whole log stream fits in a single page
+		if strings.Contains(string(body), "nextToken") {
+			fmt.Fprint(w, `{"events": [], "nextForwardToken": "f/35130491224787916803563493316340512051232960870051135488"}`)
+			return true
+		}
+		// All requests of the AWS API are sent to the same path and told apart by their target
+		r.URL.Path, r.URL.RawPath = "/"+r.Header.Get("X-Amz-Target"), ""
+		return false
+	})
+
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithEndpoint(ts.URL).
+		WithCredentials(credentials.NewStaticCredentials("AKID", "SECRET", "")))
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+
+	return NewCodeBuildClient("codebuild", "codebuild", sess, time.Millisecond), ts
+}
+
+const codeBuildTestURL = "https://us-east-1.console.aws.amazon.com/codesuite/codebuild/projects/citop/build/citop:8f4d3c0e-5a7b-4c1d-9e2f-3b6a1d0c7e58/?region=us-east-1"
+
+func TestCodeBuildClient_BuildURLs(t *testing.T) {
+	client, ts := newCodeBuildTestClient(t)
+	defer ts.Close()
+	ctx := context.Background()
+
+	t.Run("Builds of a commit", func(t *testing.T) {
+		urls, err := client.BuildURLs(ctx, "nbedos", "citop", "a24840cf94b395af69da4a1001d32e3694637e20")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{codeBuildTestURL}, urls); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Unknown repository", func(t *testing.T) {
+		_, err := client.BuildURLs(ctx, "nbedos", "unknown", "a24840cf94b395af69da4a1001d32e3694637e20")
+		if err != cache.ErrRepositoryNotFound {
+			t.Fatalf("expected %v but got %v", cache.ErrRepositoryNotFound, err)
+		}
+	})
+}
+
+func TestCodeBuildClient_BuildFromURL(t *testing.T) {
+	client, ts := newCodeBuildTestClient(t)
+	defer ts.Close()
+
+	build, err := client.BuildFromURL(context.Background(), codeBuildTestURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(seconds int64) utils.NullTime {
+		return utils.NullTime{Time: time.Unix(seconds, 0).UTC(), Valid: true}
+	}
+	id := "citop:8f4d3c0e-5a7b-4c1d-9e2f-3b6a1d0c7e58"
+	variables := []cache.Variable{{Name: "GOFLAGS", Value: "-mod=vendor"}}
+	image := "aws/codebuild/standard:2.0"
+	phase := func(name string, state cache.State, start int64, end int64) *cache.Job {
+		return &cache.Job{
+			ID:         id + "/" + name,
+			State:      state,
+			Name:       name,
+			CreatedAt:  at(start),
+			StartedAt:  at(start),
+			FinishedAt: at(end),
+			Duration:   utils.NullDuration{Duration: time.Duration(end-start) * time.Second, Valid: true},
+			WebURL:     codeBuildTestURL,
+			Variables:  variables,
+			OS:         image,
+		}
+	}
+	buildPhase := phase("BUILD", cache.Failed, 1575453640, 1575453710)
+	buildPhase.Steps = []cache.Step{{Command: "go build ./..."}, {Command: "go test ./..."}}
+
+	expected := cache.Build{
+		Repository: &cache.Repository{
+			Provider: cache.Provider{ID: "codebuild", Name: "codebuild"},
+			URL:      "https://github.com/nbedos/citop.git",
+			Owner:    "nbedos",
+			Name:     "citop",
+		},
+		ID:              id,
+		Commit:          cache.Commit{Sha: "a24840cf94b395af69da4a1001d32e3694637e20"},
+		Ref:             "master",
+		RepoBuildNumber: "citop #12",
+		State:           cache.Failed,
+		CreatedAt:       at(1575453600),
+		StartedAt:       at(1575453600),
+		FinishedAt:      at(1575453720),
+		UpdatedAt:       time.Unix(1575453720, 0).UTC(),
+		Duration:        utils.NullDuration{Duration: 2 * time.Minute, Valid: true},
+		WebURL:          codeBuildTestURL,
+		Stages:          map[int]*cache.Stage{},
+		Jobs: []*cache.Job{
+			phase("SUBMITTED", cache.Passed, 1575453600, 1575453601),
+			phase("PROVISIONING", cache.Passed, 1575453601, 1575453630),
+			phase("DOWNLOAD_SOURCE", cache.Passed, 1575453630, 1575453640),
+			buildPhase,
+			phase("FINALIZING", cache.Passed, 1575453710, 1575453720),
+		},
+	}
+
+	if diff := cmp.Diff(expected, build); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("URL of another region", func(t *testing.T) {
+		u := strings.Replace(codeBuildTestURL, "us-east-1", "eu-west-3", -1)
+		if _, err := client.BuildFromURL(context.Background(), u); err != cache.ErrUnknownURL {
+			t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+		}
+	})
+}
+
+func TestCodeBuildClient_Log(t *testing.T) {
+	client, ts := newCodeBuildTestClient(t)
+	defer ts.Close()
+
+	testCases := []struct {
+		phase    string
+		expected string
+	}{
+		{
+			phase: "DOWNLOAD_SOURCE",
+			expected: "[Container] 2019/12/04 10:00:30 Waiting for agent ping\n" +
+				"[Container] 2019/12/04 10:00:31 Phase is DOWNLOAD_SOURCE\n",
+		},
+		{
+			phase: "BUILD",
+			expected: "[Container] 2019/12/04 10:00:40 Entering phase BUILD\n" +
+				"[Container] 2019/12/04 10:00:41 Running command go build ./...\n" +
+				"[Container] 2019/12/04 10:01:49 Command did not exit successfully go test ./... exit status 1\n",
+		},
+		{
+			phase:    "SUBMITTED",
+			expected: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.phase, func(t *testing.T) {
+			jobID := "citop:8f4d3c0e-5a7b-4c1d-9e2f-3b6a1d0c7e58/" + testCase.phase
+			log, err := client.Log(context.Background(), cache.Repository{}, jobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.expected, log); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestParseCodeBuildURL(t *testing.T) {
+	testCases := []struct {
+		url    string
+		region string
+		id     string
+	}{
+		{
+			url:    codeBuildTestURL,
+			region: "us-east-1",
+			id:     "citop:8f4d3c0e-5a7b-4c1d-9e2f-3b6a1d0c7e58",
+		},
+		{
+			url:    "https://console.aws.amazon.com/codesuite/codebuild/123456789012/projects/citop/build/citop%3A8f4d3c0e-5a7b-4c1d-9e2f-3b6a1d0c7e58/log?region=eu-west-3",
+			region: "eu-west-3",
+			id:     "citop:8f4d3c0e-5a7b-4c1d-9e2f-3b6a1d0c7e58",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			region, id, err := parseCodeBuildURL(testCase.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if region != testCase.region || id != testCase.id {
+				t.Fatalf("expected (%q, %q) but got (%q, %q)", testCase.region, testCase.id, region, id)
+			}
+		})
+	}
+
+	for _, u := range []string{
+		"https://github.com/nbedos/citop",
+		"https://console.aws.amazon.com/codesuite/codebuild/projects/citop/history?region=us-east-1",
+	} {
+		t.Run(u, func(t *testing.T) {
+			if _, _, err := parseCodeBuildURL(u); err != cache.ErrUnknownURL {
+				t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,538 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// Maximum time spent reading the events of a build. The stream of events of a running build
+// stays open until the build finishes so it is read until this delay expires.
+const concourseEventsTimeout = 2 * time.Second
+
+// ConcourseClient reads the builds of the pipelines of a team of Concourse. Concourse does not
+// necessarily report the results of builds to the host of the repository, so the client is
+// also a source provider listing the builds whose inputs include a commit of the repository.
+type ConcourseClient struct {
+	baseURL     url.URL
+	team        string
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    cache.Provider
+	repositoryRestriction
+}
+
+// NewConcourseClient returns a client for the pipelines of team 'team' of the instance whose web
+// interface is at 'baseURL'. 'token' is a bearer token such as the token stored by fly after
+// logging in.
+func NewConcourseClient(id string, name string, token string, baseURL url.URL, team string, rateLimit time.Duration, options ...ClientOption) ConcourseClient {
+	return ConcourseClient{
+		baseURL:     baseURL,
+		team:        team,
+		httpClient:  newHTTPClient(0, options),
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (c ConcourseClient) ID() string {
+	return c.provider.ID
+}
+
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c ConcourseClient) WithRepositories(repositories RepositoryPatterns) ConcourseClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// Send a GET request to the endpoint 'path' of the API and return the body of the response
+func (c ConcourseClient) get(ctx context.Context, path string, query url.Values) (io.ReadCloser, error) {
+	u := c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v1" + path
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			message = nil
+		}
+		resp.Body.Close()
+		return nil, HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: string(message),
+		}
+	}
+
+	return resp.Body, nil
+}
+
+// Send a GET request to the endpoint 'path' of the API and decode the response into 'v'
+func (c ConcourseClient) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	body, err := c.get(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return json.NewDecoder(body).Decode(v)
+}
+
+// CheckAuthentication returns an error if Concourse rejects the token
+func (c ConcourseClient) CheckAuthentication(ctx context.Context) error {
+	var pipelines []concoursePipeline
+	return c.getJSON(ctx, c.teamPath("/pipelines"), nil, &pipelines)
+}
+
+// Return the path of an endpoint of the team
+func (c ConcourseClient) teamPath(format string, a ...interface{}) string {
+	return "/teams/" + url.PathEscape(c.team) + fmt.Sprintf(format, a...)
+}
+
+type concoursePipeline struct {
+	Name string `json:"name"`
+}
+
+type concourseResource struct {
+	Name   string                 `json:"name"`
+	Type   string                 `json:"type"`
+	Source map[string]interface{} `json:"source"`
+}
+
+// Return the git resources of the pipeline designating the repository
+func (c ConcourseClient) gitResources(ctx context.Context, pipeline string, owner string, repo string) ([]concourseResource, error) {
+	var config struct {
+		Config struct {
+			Resources []concourseResource `json:"resources"`
+		} `json:"config"`
+	}
+	if err := c.getJSON(ctx, c.teamPath("/pipelines/%s/config", url.PathEscape(pipeline)), nil, &config); err != nil {
+		return nil, err
+	}
+
+	resources := make([]concourseResource, 0)
+	for _, resource := range config.Config.Resources {
+		uri, _ := resource.Source["uri"].(string)
+		if resource.Type != "git" || uri == "" {
+			continue
+		}
+		_, o, r, err := utils.RepoHostOwnerAndName(uri)
+		if err != nil || !strings.EqualFold(o, owner) || !strings.EqualFold(r, repo) {
+			continue
+		}
+		resources = append(resources, resource)
+	}
+
+	return resources, nil
+}
+
+type concourseBuild struct {
+	ID           int    `json:"id"`
+	TeamName     string `json:"team_name"`
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	JobName      string `json:"job_name"`
+	PipelineName string `json:"pipeline_name"`
+	StartTime    int64  `json:"start_time"`
+	EndTime      int64  `json:"end_time"`
+}
+
+// Return the URL of the page of a build in the web interface
+func (c ConcourseClient) webURL(b concourseBuild) string {
+	u := c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + fmt.Sprintf("/teams/%s/pipelines/%s/jobs/%s/builds/%s",
+		b.TeamName, b.PipelineName, b.JobName, b.Name)
+	return u.String()
+}
+
+// BuildURLs returns the URLs of the builds of the team whose inputs include commit 'sha' of
+// the repository. ErrRepositoryNotFound is returned if no git resource of the team designates
+// the repository.
+func (c ConcourseClient) BuildURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
+	var pipelines []concoursePipeline
+	if err := c.getJSON(ctx, c.teamPath("/pipelines"), nil, &pipelines); err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0)
+	seen := make(map[int]bool)
+	repositoryFound := false
+	for _, pipeline := range pipelines {
+		resources, err := c.gitResources(ctx, pipeline.Name, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range resources {
+			repositoryFound = true
+			resourcePath := c.teamPath("/pipelines/%s/resources/%s", url.PathEscape(pipeline.Name), url.PathEscape(resource.Name))
+			var versions []struct {
+				ID      int               `json:"id"`
+				Version map[string]string `json:"version"`
+			}
+			query := url.Values{"filter": {"ref:" + sha}}
+			if err := c.getJSON(ctx, resourcePath+"/versions", query, &versions); err != nil {
+				return nil, err
+			}
+			for _, version := range versions {
+				// Older versions of Concourse ignore the filter
+				if version.Version["ref"] != sha {
+					continue
+				}
+				var builds []concourseBuild
+				if err := c.getJSON(ctx, fmt.Sprintf("%s/versions/%d/input_to", resourcePath, version.ID), nil, &builds); err != nil {
+					return nil, err
+				}
+				for _, build := range builds {
+					if !seen[build.ID] {
+						seen[build.ID] = true
+						urls = append(urls, c.webURL(build))
+					}
+				}
+			}
+		}
+	}
+	if !repositoryFound {
+		return nil, cache.ErrRepositoryNotFound
+	}
+
+	return urls, nil
+}
+
+// Commit is not supported since Concourse does not host repositories
+func (c ConcourseClient) Commit(ctx context.Context, repo string, sha string) (utils.Commit, error) {
+	return utils.Commit{}, cache.ErrRepositoryNotFound
+}
+
+// Return the team, pipeline, job and name of the build whose page is at 'u', e.g.
+// https://ci.example.com/teams/main/pipelines/citop/jobs/unit/builds/42
+func parseConcourseURL(baseURL url.URL, u string) (string, string, string, string, error) {
+	v, err := url.Parse(u)
+	if err != nil || v.Hostname() != baseURL.Hostname() {
+		return "", "", "", "", cache.ErrUnknownURL
+	}
+	prefix := strings.TrimSuffix(baseURL.Path, "/") + "/"
+	if !strings.HasPrefix(v.Path, prefix) {
+		return "", "", "", "", cache.ErrUnknownURL
+	}
+
+	cs := strings.Split(strings.Trim(strings.TrimPrefix(v.Path, prefix), "/"), "/")
+	if len(cs) != 8 || cs[0] != "teams" || cs[2] != "pipelines" || cs[4] != "jobs" || cs[6] != "builds" {
+		return "", "", "", "", cache.ErrUnknownURL
+	}
+
+	return cs[1], cs[3], cs[5], cs[7], nil
+}
+
+func (c ConcourseClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	team, pipeline, job, name, err := parseConcourseURL(c.baseURL, u)
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	var build concourseBuild
+	path := fmt.Sprintf("/teams/%s/pipelines/%s/jobs/%s/builds/%s", url.PathEscape(team),
+		url.PathEscape(pipeline), url.PathEscape(job), url.PathEscape(name))
+	if err := c.getJSON(ctx, path, nil, &build); err != nil {
+		return cache.Build{}, err
+	}
+
+	var resources struct {
+		Inputs []struct {
+			Name    string            `json:"name"`
+			Version map[string]string `json:"version"`
+		} `json:"inputs"`
+	}
+	if err := c.getJSON(ctx, fmt.Sprintf("/builds/%d/resources", build.ID), nil, &resources); err != nil {
+		return cache.Build{}, err
+	}
+	sha := ""
+	for _, input := range resources.Inputs {
+		if ref := input.Version["ref"]; ref != "" {
+			sha = ref
+			break
+		}
+	}
+
+	var plan struct {
+		Plan interface{} `json:"plan"`
+	}
+	if err := c.getJSON(ctx, fmt.Sprintf("/builds/%d/plan", build.ID), nil, &plan); err != nil {
+		// Builds that never started, e.g. builds aborted while pending, have no plan
+		if err, ok := err.(HTTPError); !ok || err.Status != http.StatusNotFound {
+			return cache.Build{}, err
+		}
+	}
+	steps := concourseSteps(plan.Plan, nil)
+
+	events, err := c.events(ctx, build.ID)
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	return fromConcourseBuild(c.provider, u, build, sha, steps, events), nil
+}
+
+// Step of the plan of a build
+type concourseStep struct {
+	ID   string
+	Kind string
+	Name string
+}
+
+// Kinds of steps shown as jobs
+var concourseStepKinds = []string{"get", "put", "task", "set_pipeline", "load_var"}
+
+// Keys of a plan holding nested plans, in order of execution
+var concourseNestedPlans = []string{"do", "in_parallel", "aggregate", "steps", "try", "timeout",
+	"retry", "step", "on_success", "on_failure", "on_abort", "on_error", "ensure"}
+
+// Append to 'steps' the steps of 'plan' in order of execution
+func concourseSteps(plan interface{}, steps []concourseStep) []concourseStep {
+	switch p := plan.(type) {
+	case []interface{}:
+		for _, nested := range p {
+			steps = concourseSteps(nested, steps)
+		}
+	case map[string]interface{}:
+		id, _ := p["id"].(string)
+		for _, kind := range concourseStepKinds {
+			if step, ok := p[kind].(map[string]interface{}); ok && id != "" {
+				name, _ := step["name"].(string)
+				steps = append(steps, concourseStep{ID: id, Kind: kind, Name: name})
+			}
+		}
+		for _, key := range concourseNestedPlans {
+			if nested, exists := p[key]; exists {
+				steps = concourseSteps(nested, steps)
+			}
+		}
+	}
+	return steps
+}
+
+// Event of a build
+type concourseEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		Origin struct {
+			ID string `json:"id"`
+		} `json:"origin"`
+		Time       int64  `json:"time"`
+		Payload    string `json:"payload"`
+		Message    string `json:"message"`
+		ExitStatus *int   `json:"exit_status"`
+	} `json:"data"`
+}
+
+// Return the events of the build identified by 'buildID' published so far
+func (c ConcourseClient) events(ctx context.Context, buildID int) ([]concourseEvent, error) {
+	streamCtx, cancel := context.WithTimeout(ctx, concourseEventsTimeout)
+	defer cancel()
+
+	body, err := c.get(streamCtx, fmt.Sprintf("/builds/%d/events", buildID), nil)
+	if err != nil {
+		if streamCtx.Err() != nil && ctx.Err() == nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer body.Close()
+
+	return readConcourseEvents(body, func() bool {
+		return streamCtx.Err() != nil && ctx.Err() == nil
+	})
+}
+
+// Read the server-sent events of a build from 'r' until the end of the stream. 'expired'
+// tells whether a read error is caused by the expiration of the delay allowed for reading the
+// stream, in which case the events read so far are returned.
+func readConcourseEvents(r io.Reader, expired func() bool) ([]concourseEvent, error) {
+	events := make([]concourseEvent, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "event: end":
+			return events, nil
+		case strings.HasPrefix(line, "data: "):
+			var event concourseEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				// The last line may have been cut short by the expiration of the delay
+				if expired() {
+					return events, nil
+				}
+				return nil, err
+			}
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil && !expired() {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Return the state of a build
+func fromConcourseStatus(status string) cache.State {
+	switch status {
+	case "pending":
+		return cache.Pending
+	case "started":
+		return cache.Running
+	case "succeeded":
+		return cache.Passed
+	case "failed", "errored":
+		return cache.Failed
+	case "aborted":
+		return cache.Canceled
+	default:
+		return cache.Unknown
+	}
+}
+
+func fromConcourseTimestamp(t int64) utils.NullTime {
+	if t == 0 {
+		return utils.NullTime{}
+	}
+	return utils.NullTime{Time: time.Unix(t, 0).UTC(), Valid: true}
+}
+
+func fromConcourseBuild(provider cache.Provider, webURL string, b concourseBuild, sha string, steps []concourseStep, events []concourseEvent) cache.Build {
+	startedAt := fromConcourseTimestamp(b.StartTime)
+	finishedAt := fromConcourseTimestamp(b.EndTime)
+	build := cache.Build{
+		Repository:      &cache.Repository{Provider: provider},
+		ID:              strconv.Itoa(b.ID),
+		Commit:          cache.Commit{Sha: sha},
+		RepoBuildNumber: fmt.Sprintf("%s/%s #%s", b.PipelineName, b.JobName, b.Name),
+		State:           fromConcourseStatus(b.Status),
+		CreatedAt:       startedAt,
+		StartedAt:       startedAt,
+		FinishedAt:      finishedAt,
+		UpdatedAt:       utils.MaxNullTime(startedAt, finishedAt).Time,
+		Duration:        utils.NullSub(finishedAt, startedAt),
+		WebURL:          webURL,
+		Stages:          map[int]*cache.Stage{},
+		Jobs:            make([]*cache.Job, 0, len(steps)),
+	}
+
+	jobs := make(map[string]*cache.Job, len(steps))
+	for _, step := range steps {
+		job := &cache.Job{
+			ID:     fmt.Sprintf("%d/%s", b.ID, step.ID),
+			Name:   fmt.Sprintf("%s %s", step.Kind, step.Name),
+			WebURL: webURL,
+		}
+		jobs[step.ID] = job
+		build.Jobs = append(build.Jobs, job)
+	}
+
+	for _, event := range events {
+		job, exists := jobs[event.Data.Origin.ID]
+		if !exists {
+			continue
+		}
+		t := fromConcourseTimestamp(event.Data.Time)
+		switch {
+		case strings.HasPrefix(event.Event, "initialize"):
+			job.State, job.CreatedAt = cache.Pending, t
+		case strings.HasPrefix(event.Event, "start"):
+			job.State, job.StartedAt = cache.Running, t
+		case strings.HasPrefix(event.Event, "finish"):
+			job.State, job.FinishedAt = cache.Passed, t
+			if event.Data.ExitStatus != nil && *event.Data.ExitStatus != 0 {
+				job.State = cache.Failed
+			}
+		case event.Event == "error":
+			job.State = cache.Failed
+		}
+	}
+
+	for _, job := range build.Jobs {
+		switch {
+		case build.State == cache.Canceled && job.State.IsActive():
+			job.State = cache.Canceled
+		case job.State == cache.Unknown && build.State.IsActive():
+			job.State = cache.Pending
+		case job.State == cache.Unknown:
+			job.State = cache.Skipped
+		}
+		job.Duration = utils.NullSub(job.FinishedAt, job.StartedAt)
+	}
+
+	return build
+}
+
+// Log returns the output of the step whose ID is 'jobID' along with the errors it raised
+func (c ConcourseClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	i := strings.Index(jobID, "/")
+	if i < 0 {
+		return "", fmt.Errorf("invalid job identifier %q", jobID)
+	}
+	buildID, err := strconv.Atoi(jobID[:i])
+	if err != nil {
+		return "", err
+	}
+	stepID := jobID[i+1:]
+
+	events, err := c.events(ctx, buildID)
+	if err != nil {
+		return "", err
+	}
+
+	b := strings.Builder{}
+	for _, event := range events {
+		if event.Data.Origin.ID != stepID {
+			continue
+		}
+		switch event.Event {
+		case "log":
+			b.WriteString(event.Data.Payload)
+		case "error":
+			fmt.Fprintf(&b, "%s\n", event.Data.Message)
+		}
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,194 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func newConcourseTestServer(t *testing.T) *httptest.Server {
+	files := map[string]string{
+		"/api/v1/teams/main/pipelines":                                          "concourse_pipelines.json",
+		"/api/v1/teams/main/pipelines/citop/config":                             "concourse_config.json",
+		"/api/v1/teams/main/pipelines/citop/resources/repo/versions":            "concourse_versions.json",
+		"/api/v1/teams/main/pipelines/citop/resources/repo/versions/7/input_to": "concourse_input_to.json",
+		"/api/v1/teams/main/pipelines/citop/jobs/unit/builds/42":                "concourse_build.json",
+		"/api/v1/builds/1001/resources":                                         "concourse_resources.json",
+		"/api/v1/builds/1001/plan":                                              "concourse_plan.json",
+		"/api/v1/builds/1001/events":                                            "concourse_events.txt",
+	}
+
+	return newFixtureServer(t, files, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(401)
+			return true
+		}
+		return false
+	})
+}
+
+func TestConcourseClient_BuildURLs(t *testing.T) {
+	ts := newConcourseTestServer(t)
+	defer ts.Close()
+
+	baseURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewConcourseClient("concourse", "concourse", "token", *baseURL, "main", time.Millisecond)
+	ctx := context.Background()
+
+	t.Run("Builds of a commit", func(t *testing.T) {
+		urls, err := client.BuildURLs(ctx, "nbedos", "citop", "a24840cf94b395af69da4a1001d32e3694637e20")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{ts.URL + "/teams/main/pipelines/citop/jobs/unit/builds/42"}
+		if diff := cmp.Diff(expected, urls); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Unknown repository", func(t *testing.T) {
+		_, err := client.BuildURLs(ctx, "nbedos", "unknown", "a24840cf94b395af69da4a1001d32e3694637e20")
+		if err != cache.ErrRepositoryNotFound {
+			t.Fatalf("expected %v but got %v", cache.ErrRepositoryNotFound, err)
+		}
+	})
+}
+
+func TestConcourseClient_BuildFromURL(t *testing.T) {
+	ts := newConcourseTestServer(t)
+	defer ts.Close()
+
+	baseURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewConcourseClient("concourse", "concourse", "token", *baseURL, "main", time.Millisecond)
+	ctx := context.Background()
+
+	webURL := ts.URL + "/teams/main/pipelines/citop/jobs/unit/builds/42"
+	build, err := client.BuildFromURL(ctx, webURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := func(seconds int64) utils.NullTime {
+		return utils.NullTime{Time: time.Unix(1580724000+seconds, 0).UTC(), Valid: true}
+	}
+	duration := func(seconds int64) utils.NullDuration {
+		return utils.NullDuration{Duration: time.Duration(seconds) * time.Second, Valid: true}
+	}
+	expected := cache.Build{
+		Repository:      &cache.Repository{Provider: cache.Provider{ID: "concourse", Name: "concourse"}},
+		ID:              "1001",
+		Commit:          cache.Commit{Sha: "a24840cf94b395af69da4a1001d32e3694637e20"},
+		RepoBuildNumber: "citop/unit #42",
+		State:           cache.Failed,
+		CreatedAt:       date(0),
+		StartedAt:       date(0),
+		FinishedAt:      date(240),
+		UpdatedAt:       date(240).Time,
+		Duration:        duration(240),
+		WebURL:          webURL,
+		Stages:          map[int]*cache.Stage{},
+		Jobs: []*cache.Job{
+			{
+				ID:         "1001/5e1c",
+				State:      cache.Passed,
+				Name:       "get repo",
+				CreatedAt:  date(0),
+				StartedAt:  date(1),
+				FinishedAt: date(10),
+				Duration:   duration(9),
+				WebURL:     webURL,
+			},
+			{
+				ID:         "1001/5e1d",
+				State:      cache.Failed,
+				Name:       "task test",
+				CreatedAt:  date(11),
+				StartedAt:  date(12),
+				FinishedAt: date(240),
+				Duration:   duration(228),
+				WebURL:     webURL,
+			},
+			{
+				ID:     "1001/5e1f",
+				State:  cache.Skipped,
+				Name:   "put tag",
+				WebURL: webURL,
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, build); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	log, err := client.Log(ctx, *build.Repository, "1001/5e1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLog := "ok  \tgithub.com/nbedos/citop/cache\t0.021s\n--- FAIL: TestGitOriginURL (0.00s)\n"
+	if log != expectedLog {
+		t.Fatalf("expected log %q but got %q", expectedLog, log)
+	}
+}
+
+func TestParseConcourseURL(t *testing.T) {
+	baseURL := url.URL{Scheme: "https", Host: "ci.example.com", Path: "/concourse"}
+	team, pipeline, job, name, err := parseConcourseURL(baseURL, "https://ci.example.com/concourse/teams/main/pipelines/citop/jobs/unit/builds/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"main", "citop", "unit", "42"}, []string{team, pipeline, job, name}); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	for _, u := range []string{
+		"https://other.example.com/concourse/teams/main/pipelines/citop/jobs/unit/builds/42",
+		"https://ci.example.com/teams/main/pipelines/citop/jobs/unit/builds/42",
+		"https://ci.example.com/concourse/teams/main/pipelines/citop",
+	} {
+		if _, _, _, _, err := parseConcourseURL(baseURL, u); err != cache.ErrUnknownURL {
+			t.Fatalf("expected %v for %q but got %v", cache.ErrUnknownURL, u, err)
+		}
+	}
+}
+
+func TestReadConcourseEvents(t *testing.T) {
+	// The stream of a running build is cut when the delay expires
+	stream := "event: event\ndata: {\"event\":\"log\",\"data\":{\"payload\":\"a\"}}\n\nevent: event\ndata: {\"event\":"
+	events, err := readConcourseEvents(&failingReader{strings.NewReader(stream)}, func() bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Data.Payload != "a" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+
+	if _, err := readConcourseEvents(&failingReader{strings.NewReader(stream)}, func() bool { return false }); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+}
+
+// Reader failing once the underlying reader is exhausted
+type failingReader struct {
+	r *strings.Reader
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.r.Len() == 0 {
+		return 0, context.DeadlineExceeded
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,500 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// CustomAPI describes the REST API of a CI service unknown to citop
+type CustomAPI struct {
+	// URL templates of the endpoints by name ("pipelines", "pipeline", "log" and "web")
+	Endpoints map[string]string
+	// Paths of the fields of the responses by name, e.g. "data.pipelines" or "jobs.0.id"
+	Fields map[string]string
+	// States of citop by state of the service
+	States map[string]string
+}
+
+// Endpoints of a custom API and the placeholders their templates may contain
+var customEndpoints = map[string][]string{
+	// Pipelines of a commit, optional
+	"pipelines": {"sha", "owner", "repo"},
+	// Pipeline, mandatory
+	"pipeline": {"id"},
+	// Log of a job, optional
+	"log": {"id", "job"},
+	// Web page of a pipeline, the endpoint of the pipeline if not specified
+	"web": {"id"},
+}
+
+// Default paths of the fields of the responses of a custom API. The fields of pipelines are
+// relative to the response of the endpoint "pipeline" and the fields of jobs to the jobs of the
+// pipeline.
+var customFields = map[string]string{
+	// Pipelines in the response of the endpoint "pipelines", empty for the root of the response,
+	// and identifier of each of them
+	"pipelines":       "",
+	"id":              "id",
+	"sha":             "sha",
+	"ref":             "ref",
+	"repository":      "repository",
+	"number":          "number",
+	"state":           "state",
+	"created_at":      "created_at",
+	"started_at":      "started_at",
+	"finished_at":     "finished_at",
+	"jobs":            "jobs",
+	"job.id":          "id",
+	"job.name":        "name",
+	"job.stage":       "stage",
+	"job.state":       "state",
+	"job.started_at":  "started_at",
+	"job.finished_at": "finished_at",
+	"job.url":         "url",
+	// Log in the response of the endpoint "log", empty if the response is the log itself
+	"log": "",
+}
+
+// CustomClient reads the pipelines of a CI service through a REST API described entirely by
+// the configuration of the user. Responses must be JSON objects except for logs which may be
+// plain text. Timestamps are either RFC 3339 strings or numbers of seconds since the epoch.
+type CustomClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	endpoints   map[string]string
+	fields      map[string]string
+	states      map[string]cache.State
+	webPage     *regexp.Regexp
+	provider    cache.Provider
+	repositoryRestriction
+}
+
+// NewCustomClient returns a client for the API described by 'api' whose endpoints are relative
+// to 'baseURL'. Requests are authenticated by the bearer token 'token' if it is not empty.
+func NewCustomClient(id string, name string, token string, baseURL url.URL, api CustomAPI, rateLimit time.Duration, options ...ClientOption) (CustomClient, error) {
+	endpoints := make(map[string]string, len(api.Endpoints))
+	for key, template := range api.Endpoints {
+		placeholders, exists := customEndpoints[key]
+		if !exists {
+			return CustomClient{}, fmt.Errorf("unknown endpoint %q", key)
+		}
+		for _, p := range customPlaceholder.FindAllStringSubmatch(template, -1) {
+			valid := false
+			for _, placeholder := range placeholders {
+				valid = valid || p[1] == placeholder
+			}
+			if !valid {
+				return CustomClient{}, fmt.Errorf("invalid placeholder {%s} in endpoint %q (expected one of %v)", p[1], key, placeholders)
+			}
+		}
+		endpoints[key] = template
+	}
+	if endpoints["pipeline"] == "" {
+		return CustomClient{}, fmt.Errorf("missing endpoint %q", "pipeline")
+	}
+	if endpoints["web"] == "" {
+		endpoints["web"] = endpoints["pipeline"]
+	}
+	for _, key := range []string{"pipeline", "web"} {
+		if !strings.Contains(endpoints[key], "{id}") {
+			return CustomClient{}, fmt.Errorf("missing placeholder {id} in endpoint %q", key)
+		}
+	}
+
+	fields := make(map[string]string, len(customFields))
+	for key, path := range customFields {
+		fields[key] = path
+	}
+	for key, path := range api.Fields {
+		if _, exists := customFields[key]; !exists {
+			return CustomClient{}, fmt.Errorf("unknown field %q", key)
+		}
+		fields[key] = path
+	}
+
+	states := make(map[string]cache.State, len(api.States))
+	for value, state := range api.States {
+		s := cache.State(strings.ToLower(state))
+		if !customStates[s] {
+			return CustomClient{}, fmt.Errorf("invalid state %q for value %q (expected one of %v)", state, value, customStateNames())
+		}
+		states[value] = s
+	}
+
+	c := CustomClient{
+		baseURL:     baseURL,
+		httpClient:  newHTTPClient(requestTimeout, options),
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		endpoints:   endpoints,
+		fields:      fields,
+		states:      states,
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+	// Web pages are recognized by replacing the identifier of the pipeline by a pattern once
+	// the URL is escaped
+	web := c.url(endpoints["web"], map[string]string{"id": customIDSentinel})
+	pattern := regexp.QuoteMeta(strings.TrimSuffix(web.String(), "/"))
+	pattern = strings.Replace(pattern, customIDSentinel, "([^/?#&]+)", 1)
+	c.webPage = regexp.MustCompile("^" + pattern + "/?$")
+
+	return c, nil
+}
+
+// Placeholder of a URL template, e.g. "{sha}"
+var customPlaceholder = regexp.MustCompile(`{(\w+)}`)
+
+// Identifier standing for any pipeline in the web pages of pipelines
+const customIDSentinel = "citopPipelineID"
+
+// States of citop the states of a service can be mapped to
+var customStates = map[cache.State]bool{
+	cache.Pending:  true,
+	cache.Running:  true,
+	cache.Passed:   true,
+	cache.Failed:   true,
+	cache.Canceled: true,
+	cache.Manual:   true,
+	cache.Skipped:  true,
+}
+
+// Return the names of the states of citop, sorted
+func customStateNames() []string {
+	names := make([]string, 0, len(customStates))
+	for state := range customStates {
+		names = append(names, string(state))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c CustomClient) ID() string {
+	return c.provider.ID
+}
+
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c CustomClient) WithRepositories(repositories RepositoryPatterns) CustomClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// Return the URL of the template 'template' whose placeholders are replaced by the escaped
+// values of 'values'. Templates are either absolute or relative to the base URL.
+func (c CustomClient) url(template string, values map[string]string) url.URL {
+	replace := func(s string, escape func(string) string) string {
+		return customPlaceholder.ReplaceAllStringFunc(s, func(p string) string {
+			if value, exists := values[p[1:len(p)-1]]; exists {
+				return escape(value)
+			}
+			return p
+		})
+	}
+	path, query := template, ""
+	if i := strings.Index(template, "?"); i >= 0 {
+		path, query = template[:i], template[i+1:]
+	}
+	path, query = replace(path, url.PathEscape), replace(query, url.QueryEscape)
+
+	u := c.baseURL
+	prefix := strings.TrimSuffix(u.EscapedPath(), "/")
+	if v, err := url.Parse(path); err == nil && v.IsAbs() {
+		u, prefix, path = *v, "", v.EscapedPath()
+	}
+	u.RawPath = prefix + path
+	if p, err := url.PathUnescape(u.RawPath); err == nil {
+		u.Path = p
+	}
+	u.RawQuery = query
+	return u
+}
+
+// Send a GET request to 'u' and return the body of the response
+func (c CustomClient) get(ctx context.Context, u url.URL) (*bytes.Buffer, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: body.String(),
+		}
+	}
+
+	return body, nil
+}
+
+// Send a GET request to 'u' and decode the JSON response
+func (c CustomClient) getJSON(ctx context.Context, u url.URL) (interface{}, error) {
+	body, err := c.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = json.Unmarshal(body.Bytes(), &v)
+	return v, err
+}
+
+// Return the value at 'path' in 'v', e.g. "data.jobs.0.id". The boolean is false if the value
+// does not exist or is null.
+func lookupField(v interface{}, path string) (interface{}, bool) {
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			switch w := v.(type) {
+			case map[string]interface{}:
+				v = w[key]
+			case []interface{}:
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= len(w) {
+					return nil, false
+				}
+				v = w[i]
+			default:
+				return nil, false
+			}
+		}
+	}
+	return v, v != nil
+}
+
+// Return the value of field 'key' of 'v' as a string, empty if it does not exist
+func (c CustomClient) stringField(v interface{}, key string) string {
+	value, exists := lookupField(v, c.fields[key])
+	if !exists {
+		return ""
+	}
+	switch w := value.(type) {
+	case string:
+		return w
+	case float64:
+		return strconv.FormatFloat(w, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(w)
+	}
+	return ""
+}
+
+// Return the value of field 'key' of 'v' as a time
+func (c CustomClient) timeField(v interface{}, key string) utils.NullTime {
+	value, exists := lookupField(v, c.fields[key])
+	if !exists {
+		return utils.NullTime{}
+	}
+	switch w := value.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, w); err == nil {
+			return utils.NullTime{Time: t.UTC(), Valid: true}
+		}
+	case float64:
+		return utils.NullTime{Time: time.Unix(int64(w), 0).UTC(), Valid: true}
+	}
+	return utils.NullTime{}
+}
+
+// Return the value of field 'key' of 'v' as a list
+func (c CustomClient) listField(v interface{}, key string) []interface{} {
+	value, _ := lookupField(v, c.fields[key])
+	list, _ := value.([]interface{})
+	return list
+}
+
+// Return the state of citop matching state 's' of the service. States of the service missing
+// from the mapping are used as is if they are states of citop.
+func (c CustomClient) state(s string) cache.State {
+	if state, exists := c.states[s]; exists {
+		return state
+	}
+	if state := cache.State(strings.ToLower(s)); customStates[state] {
+		return state
+	}
+	return cache.Unknown
+}
+
+// Commit is not supported since the API only describes pipelines
+func (c CustomClient) Commit(ctx context.Context, repo string, sha string) (utils.Commit, error) {
+	return utils.Commit{}, cache.ErrRepositoryNotFound
+}
+
+// BuildURLs returns the web pages of the pipelines listed by the endpoint "pipelines"
+func (c CustomClient) BuildURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
+	template, exists := c.endpoints["pipelines"]
+	if !exists {
+		return nil, cache.ErrRepositoryNotFound
+	}
+	u := c.url(template, map[string]string{"sha": sha, "owner": owner, "repo": repo})
+	v, err := c.getJSON(ctx, u)
+	if err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == http.StatusNotFound {
+			return nil, cache.ErrRepositoryNotFound
+		}
+		return nil, err
+	}
+
+	urls := make([]string, 0)
+	for _, pipeline := range c.listField(v, "pipelines") {
+		if id := c.stringField(pipeline, "id"); id != "" {
+			web := c.url(c.endpoints["web"], map[string]string{"id": id})
+			urls = append(urls, web.String())
+		}
+	}
+
+	return urls, nil
+}
+
+// BuildFromURL returns the pipeline whose web page is at 'u'
+func (c CustomClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	cs := c.webPage.FindStringSubmatch(u)
+	if cs == nil {
+		return cache.Build{}, cache.ErrUnknownURL
+	}
+	id, err := url.PathUnescape(cs[1])
+	if err != nil {
+		return cache.Build{}, cache.ErrUnknownURL
+	}
+
+	v, err := c.getJSON(ctx, c.url(c.endpoints["pipeline"], map[string]string{"id": id}))
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	return c.fromPipeline(id, v), nil
+}
+
+// Log returns the log of a job given by the endpoint "log"
+func (c CustomClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	template, exists := c.endpoints["log"]
+	if !exists {
+		return "", fmt.Errorf("no endpoint %q in configuration of provider %q", "log", c.provider.Name)
+	}
+	cs := strings.SplitN(jobID, "/", 2)
+	if len(cs) != 2 {
+		return "", fmt.Errorf("invalid job identifier %q", jobID)
+	}
+	id, err := url.PathUnescape(cs[0])
+	if err != nil {
+		return "", err
+	}
+	job, err := url.PathUnescape(cs[1])
+	if err != nil {
+		return "", err
+	}
+
+	body, err := c.get(ctx, c.url(template, map[string]string{"id": id, "job": job}))
+	if err != nil {
+		return "", err
+	}
+	if c.fields["log"] == "" {
+		return body.String(), nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(body.Bytes(), &v); err != nil {
+		return "", err
+	}
+	return c.stringField(v, "log"), nil
+}
+
+func (c CustomClient) fromPipeline(id string, v interface{}) cache.Build {
+	web := c.url(c.endpoints["web"], map[string]string{"id": id})
+	repository := cache.Repository{
+		Provider: c.provider,
+		Name:     c.stringField(v, "repository"),
+	}
+	build := cache.Build{
+		Repository:      &repository,
+		ID:              id,
+		Commit:          cache.Commit{Sha: c.stringField(v, "sha")},
+		Ref:             c.stringField(v, "ref"),
+		RepoBuildNumber: c.stringField(v, "number"),
+		State:           c.state(c.stringField(v, "state")),
+		CreatedAt:       c.timeField(v, "created_at"),
+		StartedAt:       c.timeField(v, "started_at"),
+		FinishedAt:      c.timeField(v, "finished_at"),
+		WebURL:          web.String(),
+		Stages:          make(map[int]*cache.Stage),
+	}
+	if build.RepoBuildNumber == "" {
+		build.RepoBuildNumber = id
+	}
+	if !build.CreatedAt.Valid {
+		build.CreatedAt = build.StartedAt
+	}
+	build.UpdatedAt = utils.MaxNullTime(build.CreatedAt, build.StartedAt, build.FinishedAt).Time
+	build.Duration = utils.NullSub(build.FinishedAt, build.StartedAt)
+
+	stageIDs := make(map[string]int)
+	for _, j := range c.listField(v, "jobs") {
+		job := cache.Job{
+			ID:         url.PathEscape(id) + "/" + url.PathEscape(c.stringField(j, "job.id")),
+			State:      c.state(c.stringField(j, "job.state")),
+			Name:       c.stringField(j, "job.name"),
+			StartedAt:  c.timeField(j, "job.started_at"),
+			FinishedAt: c.timeField(j, "job.finished_at"),
+			WebURL:     c.stringField(j, "job.url"),
+		}
+		job.CreatedAt = job.StartedAt
+		job.Duration = utils.NullSub(job.FinishedAt, job.StartedAt)
+
+		name := c.stringField(j, "job.stage")
+		if name == "" {
+			build.Jobs = append(build.Jobs, &job)
+			continue
+		}
+		stageID, exists := stageIDs[name]
+		if !exists {
+			stageID = len(stageIDs) + 1
+			stageIDs[name] = stageID
+			build.Stages[stageID] = &cache.Stage{ID: stageID, Name: name}
+		}
+		build.Stages[stageID].Jobs = append(build.Stages[stageID].Jobs, &job)
+	}
+	for _, stage := range build.Stages {
+		statusers := make([]cache.Statuser, 0, len(stage.Jobs))
+		for _, job := range stage.Jobs {
+			statusers = append(statusers, *job)
+		}
+		stage.State = cache.AggregateStatuses(statusers)
+	}
+
+	return build
+}
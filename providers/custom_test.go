@@ -0,0 +1,281 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+var customTestAPI = CustomAPI{
+	Endpoints: map[string]string{
+		"pipelines": "/api/pipelines?commit={sha}",
+		"pipeline":  "/api/pipelines/{id}",
+		"log":       "/api/pipelines/{id}/tasks/{job}/log",
+		"web":       "/ui/pipelines/{id}",
+	},
+	Fields: map[string]string{
+		"pipelines":       "data",
+		"sha":             "data.commit",
+		"ref":             "data.branch",
+		"repository":      "data.project",
+		"state":           "data.status",
+		"created_at":      "data.queued",
+		"started_at":      "data.started",
+		"finished_at":     "data.finished",
+		"jobs":            "data.tasks",
+		"job.id":          "key",
+		"job.name":        "title",
+		"job.stage":       "phase",
+		"job.state":       "status",
+		"job.started_at":  "started",
+		"job.finished_at": "finished",
+		"log":             "output",
+	},
+	States: map[string]string{
+		"SUCCESS": "passed",
+		"FAILURE": "failed",
+		"SKIPPED": "skipped",
+	},
+}
+
+func newCustomTestClient(t *testing.T) (CustomClient, *httptest.Server) {
+	files := map[string]string{
+		"/api/pipelines":                   "custom_pipelines.json",
+		"/api/pipelines/42":                "custom_pipeline.json",
+		"/api/pipelines/42/tasks/test/log": "custom_log.json",
+	}
+
+	ts := newFixtureServer(t, files, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(401)
+			return true
+		}
+		if r.URL.Path == "/api/pipelines" && r.URL.Query().Get("commit") != "a24840cf94b395af69da4a1001d32e3694637e20" {
+			fmt.Fprint(w, `{"data": []}`)
+			return true
+		}
+		return false
+	})
+
+	baseURL, err := url.Parse(ts.URL)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+
+	client, err := NewCustomClient("custom", "custom", "token", *baseURL, customTestAPI, time.Millisecond)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+
+	return client, ts
+}
+
+func TestCustomClient_BuildURLs(t *testing.T) {
+	client, ts := newCustomTestClient(t)
+	defer ts.Close()
+
+	urls, err := client.BuildURLs(context.Background(), "nbedos", "citop", "a24840cf94b395af69da4a1001d32e3694637e20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		ts.URL + "/ui/pipelines/42",
+		ts.URL + "/ui/pipelines/43",
+	}
+	if diff := cmp.Diff(expected, urls); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("No endpoint listing pipelines", func(t *testing.T) {
+		api := CustomAPI{Endpoints: map[string]string{"pipeline": "/api/pipelines/{id}"}}
+		client, err := NewCustomClient("custom", "custom", "token", url.URL{}, api, time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.BuildURLs(context.Background(), "nbedos", "citop", "a24840cf94b395af69da4a1001d32e3694637e20"); err != cache.ErrRepositoryNotFound {
+			t.Fatalf("expected %v but got %v", cache.ErrRepositoryNotFound, err)
+		}
+	})
+}
+
+func TestCustomClient_BuildFromURL(t *testing.T) {
+	client, ts := newCustomTestClient(t)
+	defer ts.Close()
+
+	build, err := client.BuildFromURL(context.Background(), ts.URL+"/ui/pipelines/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(min int, sec int) utils.NullTime {
+		return utils.NullTime{Time: time.Date(2020, 2, 3, 10, min, sec, 0, time.UTC), Valid: true}
+	}
+	expected := cache.Build{
+		Repository: &cache.Repository{
+			Provider: cache.Provider{ID: "custom", Name: "custom"},
+			Name:     "citop",
+		},
+		ID:              "42",
+		Commit:          cache.Commit{Sha: "a24840cf94b395af69da4a1001d32e3694637e20"},
+		Ref:             "master",
+		RepoBuildNumber: "42",
+		State:           cache.Failed,
+		CreatedAt:       at(0, 2),
+		StartedAt:       at(0, 5),
+		FinishedAt:      at(1, 28),
+		UpdatedAt:       at(1, 28).Time,
+		Duration:        utils.NullDuration{Duration: 83 * time.Second, Valid: true},
+		WebURL:          ts.URL + "/ui/pipelines/42",
+		Stages: map[int]*cache.Stage{
+			1: {
+				ID:    1,
+				Name:  "check",
+				State: cache.Failed,
+				Jobs: []*cache.Job{
+					{
+						ID:         "42/lint",
+						State:      cache.Passed,
+						Name:       "go vet",
+						CreatedAt:  at(0, 5),
+						StartedAt:  at(0, 5),
+						FinishedAt: at(0, 30),
+						Duration:   utils.NullDuration{Duration: 25 * time.Second, Valid: true},
+					},
+					{
+						ID:         "42/test",
+						State:      cache.Failed,
+						Name:       "go test",
+						CreatedAt:  at(0, 5),
+						StartedAt:  at(0, 5),
+						FinishedAt: at(1, 28),
+						Duration:   utils.NullDuration{Duration: 83 * time.Second, Valid: true},
+					},
+				},
+			},
+			2: {
+				ID:    2,
+				Name:  "release",
+				State: cache.Skipped,
+				Jobs: []*cache.Job{
+					{
+						ID:    "42/deploy",
+						State: cache.Skipped,
+						Name:  "deploy",
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, build); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("Web page of another service", func(t *testing.T) {
+		for _, u := range []string{
+			"https://example.com/ui/pipelines/42",
+			ts.URL + "/ui/pipelines/42/tasks",
+			ts.URL + "/api/pipelines/42",
+		} {
+			if _, err := client.BuildFromURL(context.Background(), u); err != cache.ErrUnknownURL {
+				t.Fatalf("expected %v but got %v for %q", cache.ErrUnknownURL, err, u)
+			}
+		}
+	})
+}
+
+func TestCustomClient_Log(t *testing.T) {
+	client, ts := newCustomTestClient(t)
+	defer ts.Close()
+
+	log, err := client.Log(context.Background(), cache.Repository{}, "42/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "go test ./...\n--- FAIL: TestCustomClient (0.00s)\nFAIL\n"
+	if diff := cmp.Diff(expected, log); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestNewCustomClient(t *testing.T) {
+	for _, testCase := range []struct {
+		name string
+		api  CustomAPI
+	}{
+		{
+			name: "missing endpoint of pipelines",
+			api:  CustomAPI{Endpoints: map[string]string{"web": "/pipelines/{id}"}},
+		},
+		{
+			name: "missing identifier of pipelines",
+			api:  CustomAPI{Endpoints: map[string]string{"pipeline": "/api/pipelines"}},
+		},
+		{
+			name: "unknown endpoint",
+			api:  CustomAPI{Endpoints: map[string]string{"pipeline": "/api/pipelines/{id}", "jobs": "/api/jobs"}},
+		},
+		{
+			name: "invalid placeholder",
+			api:  CustomAPI{Endpoints: map[string]string{"pipeline": "/api/pipelines/{id}", "log": "/api/logs/{sha}"}},
+		},
+		{
+			name: "unknown field",
+			api: CustomAPI{
+				Endpoints: map[string]string{"pipeline": "/api/pipelines/{id}"},
+				Fields:    map[string]string{"color": "color"},
+			},
+		},
+		{
+			name: "invalid state",
+			api: CustomAPI{
+				Endpoints: map[string]string{"pipeline": "/api/pipelines/{id}"},
+				States:    map[string]string{"SUCCESS": "green"},
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := NewCustomClient("custom", "custom", "", url.URL{}, testCase.api, time.Millisecond); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestLookupField(t *testing.T) {
+	v := map[string]interface{}{
+		"data": []interface{}{
+			map[string]interface{}{"id": 42.0},
+		},
+	}
+	for _, testCase := range []struct {
+		path     string
+		expected interface{}
+		exists   bool
+	}{
+		{path: "", expected: v, exists: true},
+		{path: "data.0.id", expected: 42.0, exists: true},
+		{path: "data.1.id"},
+		{path: "data.id"},
+		{path: "missing"},
+	} {
+		t.Run(testCase.path, func(t *testing.T) {
+			value, exists := lookupField(v, testCase.path)
+			if exists != testCase.exists {
+				t.Fatalf("expected %v but got %v", testCase.exists, exists)
+			}
+			if diff := cmp.Diff(testCase.expected, value); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
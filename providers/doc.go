@@ -0,0 +1,103 @@
+// Package providers implements the interfaces cache.SourceProvider and cache.CIProvider for
+// the online services supported by citop.
+//
+// Each client is created by a constructor taking an identifier unique among the providers
+// given to a cache, a display name and credentials. Options such as WithHeader and WithTransport
+// configure the HTTP client of the client and come last so that new options leave the signature
+// of constructors unchanged.
+//
+// This package is part of the public Go API of citop and follows semantic versioning (see
+// package cache).
+package providers
+
+import "github.com/nbedos/citop/cache"
+
+// Clients must keep implementing the interfaces of package cache since external programs rely
+// on them
+var (
+	_ cache.SourceProvider        = GitHubClient{}
+	_ cache.SourceProvider        = GitLabClient{}
+	_ cache.SourceProvider        = BuildbotClient{}
+	_ cache.SourceProvider        = ConcourseClient{}
+	_ cache.SourceProvider        = CodeBuildClient{}
+	_ cache.SourceProvider        = CloudBuildClient{}
+	_ cache.SourceProvider        = GiteaClient{}
+	_ cache.SourceProvider        = BambooClient{}
+	_ cache.SourceProvider        = GerritClient{}
+	_ cache.SourceProvider        = WebhookReceiver{}
+	_ cache.SourceProvider        = CustomClient{}
+	_ cache.CIProvider            = GitHubClient{}
+	_ cache.CIProvider            = GitLabClient{}
+	_ cache.CIProvider            = TravisClient{}
+	_ cache.CIProvider            = AppVeyorClient{}
+	_ cache.CIProvider            = CircleCIClient{}
+	_ cache.CIProvider            = AzurePipelinesClient{}
+	_ cache.CIProvider            = ProwClient{}
+	_ cache.CIProvider            = LighthouseClient{}
+	_ cache.CIProvider            = BuildbotClient{}
+	_ cache.CIProvider            = SemaphoreClient{}
+	_ cache.CIProvider            = ConcourseClient{}
+	_ cache.CIProvider            = CodeBuildClient{}
+	_ cache.CIProvider            = CloudBuildClient{}
+	_ cache.CIProvider            = GiteaClient{}
+	_ cache.CIProvider            = BambooClient{}
+	_ cache.CIProvider            = CustomClient{}
+	_ cache.AuthenticationChecker = GitHubClient{}
+	_ cache.AuthenticationChecker = GitLabClient{}
+	_ cache.AuthenticationChecker = TravisClient{}
+	_ cache.AuthenticationChecker = AppVeyorClient{}
+	_ cache.AuthenticationChecker = CircleCIClient{}
+	_ cache.AuthenticationChecker = AzurePipelinesClient{}
+	_ cache.AuthenticationChecker = SemaphoreClient{}
+	_ cache.AuthenticationChecker = ConcourseClient{}
+	_ cache.AuthenticationChecker = GiteaClient{}
+	_ cache.AuthenticationChecker = BambooClient{}
+	_ cache.AuthenticationChecker = GerritClient{}
+	_ cache.PullRequestFinder     = GitHubClient{}
+	_ cache.PullRequestFinder     = GitLabClient{}
+	_ cache.HistoryProvider       = GitLabClient{}
+	_ cache.HistoryProvider       = TravisClient{}
+	_ cache.DeploymentManager     = GitLabClient{}
+	_ cache.PipelineTrigger       = GitLabClient{}
+	_ cache.PipelineTrigger       = TravisClient{}
+	_ cache.PipelineTrigger       = CircleCIClient{}
+	_ cache.PipelineTrigger       = GitHubClient{}
+	_ cache.PipelineTrigger       = AzurePipelinesClient{}
+	_ cache.PipelineCanceler      = GitLabClient{}
+	_ cache.PipelineCanceler      = TravisClient{}
+	_ cache.PipelineCanceler      = CircleCIClient{}
+	_ cache.PipelineCanceler      = AzurePipelinesClient{}
+	_ cache.PipelineCanceler      = AppVeyorClient{}
+	_ cache.PipelineRestarter     = GitLabClient{}
+	_ cache.PipelineRestarter     = TravisClient{}
+	_ cache.PipelineRestarter     = CircleCIClient{}
+	_ cache.PipelineRestarter     = AzurePipelinesClient{}
+	_ cache.JobRetrier            = GitLabClient{}
+	_ cache.JobRetrier            = TravisClient{}
+	_ cache.JobRetrier            = CircleCIClient{}
+	_ cache.ManualJobStarter      = GitLabClient{}
+	_ cache.ManualJobStarter      = AzurePipelinesClient{}
+	_ cache.ArtifactProvider      = GitLabClient{}
+	_ cache.ArtifactProvider      = CircleCIClient{}
+	_ cache.TestReportProvider    = GitLabClient{}
+	_ cache.TestReportProvider    = AzurePipelinesClient{}
+	_ cache.Notifier              = WebhookReceiver{}
+	_ cache.RepositoryMatcher     = GitHubClient{}
+	_ cache.RepositoryMatcher     = GitLabClient{}
+	_ cache.RepositoryMatcher     = TravisClient{}
+	_ cache.RepositoryMatcher     = AppVeyorClient{}
+	_ cache.RepositoryMatcher     = CircleCIClient{}
+	_ cache.RepositoryMatcher     = AzurePipelinesClient{}
+	_ cache.RepositoryMatcher     = ProwClient{}
+	_ cache.RepositoryMatcher     = LighthouseClient{}
+	_ cache.RepositoryMatcher     = BuildbotClient{}
+	_ cache.RepositoryMatcher     = SemaphoreClient{}
+	_ cache.RepositoryMatcher     = ConcourseClient{}
+	_ cache.RepositoryMatcher     = CodeBuildClient{}
+	_ cache.RepositoryMatcher     = CloudBuildClient{}
+	_ cache.RepositoryMatcher     = GiteaClient{}
+	_ cache.RepositoryMatcher     = BambooClient{}
+	_ cache.RepositoryMatcher     = GerritClient{}
+	_ cache.RepositoryMatcher     = WebhookReceiver{}
+	_ cache.RepositoryMatcher     = CustomClient{}
+)
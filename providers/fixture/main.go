@@ -0,0 +1,185 @@
+// Command fixture downloads a response of the API of a provider and writes it, stripped of
+// personal information and credentials, in the format of the files of providers/test_data used
+// by the tests of the providers.
+//
+// Usage:
+//
+//	go run ./providers/fixture [-H HEADER]... [-o FILE] URL
+//
+// Example:
+//
+//	go run ./providers/fixture -H "Travis-API-Version: 3" \
+//	    -H "Authorization: token $TRAVIS_TOKEN" \
+//	    -o providers/test_data/travis_build_609256446.json \
+//	    https://api.travis-ci.org/build/609256446
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const usage = `usage: go run ./providers/fixture [-H HEADER]... [-o FILE] URL
+
+Download URL and write the response to FILE, or to the standard output, after redacting
+credentials, email addresses and IP addresses. JSON responses are indented.
+
+  -H HEADER   add HEADER ("Name: value") to the request, may be repeated
+  -o FILE     write the fixture to FILE instead of the standard output`
+
+// Keys of JSON objects whose values are replaced, matched as lowercase substrings
+var sensitiveKeys = []string{"token", "secret", "password", "email", "ip_address"}
+
+const redacted = "REDACTED"
+
+var emailPattern = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+var credentialsPattern = regexp.MustCompile(`(?i)((?:access_token|circle-token|private_token|token)=)[^&"\s]+`)
+
+type headers []string
+
+func (h *headers) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headers) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("invalid header %q (expected \"Name: value\")", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// Redact email addresses and credentials passed in URLs
+func redactString(s string) string {
+	s = emailPattern.ReplaceAllString(s, "redacted@example.com")
+	return credentialsPattern.ReplaceAllString(s, "${1}"+redacted)
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range sensitiveKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return a copy of the decoded JSON value 'v' without personal information or credentials
+func redactJSON(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		redactedMap := make(map[string]interface{}, len(value))
+		for key, element := range value {
+			if s, ok := element.(string); ok && s != "" && isSensitiveKey(key) {
+				redactedMap[key] = redacted
+				continue
+			}
+			redactedMap[key] = redactJSON(element)
+		}
+		return redactedMap
+	case []interface{}:
+		redactedSlice := make([]interface{}, 0, len(value))
+		for _, element := range value {
+			redactedSlice = append(redactedSlice, redactJSON(element))
+		}
+		return redactedSlice
+	case string:
+		return redactString(value)
+	default:
+		return v
+	}
+}
+
+// Return the content of the fixture built from the body of a response. Bodies that are not
+// valid JSON, such as logs, are only stripped of email addresses and credentials.
+func fixture(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Keep numbers as is, large identifiers do not fit in a float64
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return []byte(redactString(string(body))), nil
+	}
+
+	b := bytes.Buffer{}
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(redactJSON(v)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Download 'u' with the headers 'h' and return the body of the response
+func download(client *http.Client, u string, h headers) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range h {
+		i := strings.Index(header, ":")
+		req.Header.Add(strings.TrimSpace(header[:i]), strings.TrimSpace(header[i+1:]))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("GET %s returned status %q", redactString(u), resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+func run(args []string, stdout io.Writer) error {
+	var h headers
+	var output string
+	f := flag.NewFlagSet("fixture", flag.ContinueOnError)
+	f.SetOutput(ioutil.Discard)
+	f.Var(&h, "H", "")
+	f.StringVar(&output, "o", "", "")
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if f.NArg() != 1 {
+		return errors.New("exactly one URL must be specified")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	body, err := download(client, f.Arg(0), h)
+	if err != nil {
+		return err
+	}
+	content, err := fixture(body)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = stdout.Write(content)
+		return err
+	}
+	return ioutil.WriteFile(output, content, 0644)
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+}
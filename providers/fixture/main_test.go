@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFixture(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name: "json",
+			body: `{"id": 609256446123456789, "committer_email": "nicolas@example.org", "token": "abc",` +
+				`"log_url": "https://circleci.com/api?circle-token=abc&page=2", "jobs": [{"author": "Nicolas <nicolas@example.org>"}]}`,
+			expected: `{
+  "committer_email": "REDACTED",
+  "id": 609256446123456789,
+  "jobs": [
+    {
+      "author": "Nicolas <redacted@example.com>"
+    }
+  ],
+  "log_url": "https://circleci.com/api?circle-token=REDACTED&page=2",
+  "token": "REDACTED"
+}
+`,
+		},
+		{
+			name:     "log",
+			body:     "Cloning https://gitlab.com/nbedos/citop.git?private_token=abc\nAuthor: nicolas@example.org\n",
+			expected: "Cloning https://gitlab.com/nbedos/citop.git?private_token=REDACTED\nAuthor: redacted@example.com\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			content, err := fixture([]byte(testCase.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.expected, string(content)); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"id": 1}`)
+	}))
+	defer ts.Close()
+
+	b := bytes.Buffer{}
+	if err := run([]string{"-H", "Authorization: token abc", ts.URL}, &b); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "{\n  \"id\": 1\n}\n"; b.String() != expected {
+		t.Fatalf("expected %q but got %q", expected, b.String())
+	}
+
+	if err := run([]string{ts.URL}, &b); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+	if err := run([]string{"-H", "invalid", ts.URL}, &b); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+}
@@ -0,0 +1,308 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// GerritClient is a source provider for the projects of an instance of Gerrit Code Review.
+// Besides commit SHAs, it resolves changes and their patch sets, designated by refs such as
+// "12345", "12345/3" or "refs/changes/45/12345/3". Pipelines of a commit are the builds whose
+// URLs were posted as review messages on the patch set of the commit, as CI systems integrated
+// with Gerrit usually do.
+type GerritClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	username    string
+	password    string
+	provider    cache.Provider
+	repositoryRestriction
+}
+
+// NewGerritClient returns a client for the instance whose web interface is at 'baseURL', e.g.
+// https://gerrit.example.com. Requests are anonymous unless an HTTP password is given.
+func NewGerritClient(id string, name string, username string, password string, baseURL url.URL, rateLimit time.Duration, options ...ClientOption) GerritClient {
+	return GerritClient{
+		baseURL:     baseURL,
+		httpClient:  newHTTPClient(requestTimeout, options),
+		rateLimiter: time.Tick(rateLimit),
+		username:    username,
+		password:    password,
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (c GerritClient) ID() string {
+	return c.provider.ID
+}
+
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c GerritClient) WithRepositories(repositories RepositoryPatterns) GerritClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// Prefix of the responses of the REST API of Gerrit preventing their execution as JavaScript
+const gerritXSSIPrefix = ")]}'"
+
+// Send a GET request to the endpoint 'path' of the REST API and decode the response into 'v'.
+// 'path' must be escaped since the names of projects are escaped as a single segment.
+func (c GerritClient) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	u := c.baseURL
+	u.RawPath = strings.TrimSuffix(u.EscapedPath(), "/")
+	if c.password != "" {
+		// Authenticated requests are sent to the endpoints prefixed by "/a"
+		u.RawPath += "/a"
+	}
+	u.RawPath += path
+	p, err := url.PathUnescape(u.RawPath)
+	if err != nil {
+		return err
+	}
+	u.Path = p
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", "application/json")
+	if c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: body.String(),
+		}
+	}
+
+	return json.Unmarshal(bytes.TrimPrefix(body.Bytes(), []byte(gerritXSSIPrefix)), v)
+}
+
+// CheckAuthentication returns an error if Gerrit rejects the HTTP password of the user
+func (c GerritClient) CheckAuthentication(ctx context.Context) error {
+	var account struct {
+		Username string `json:"username"`
+	}
+	return c.getJSON(ctx, "/accounts/self", nil, &account)
+}
+
+// Return the name of the project of Gerrit cloned from 'repositoryURL', e.g.
+// https://gerrit.example.com/a/nbedos/citop or ssh://user@gerrit.example.com:29418/nbedos/citop
+func (c GerritClient) project(repositoryURL string) (string, error) {
+	u, err := url.Parse(repositoryURL)
+	if err != nil || u.Hostname() != c.baseURL.Hostname() {
+		return "", cache.ErrUnknownURL
+	}
+	p := u.Path
+	if u.Scheme == "http" || u.Scheme == "https" {
+		p = strings.TrimPrefix(p, strings.TrimSuffix(c.baseURL.Path, "/"))
+		p = strings.TrimPrefix(p, "/a/")
+	}
+	p = strings.TrimSuffix(strings.Trim(p, "/"), ".git")
+	if p == "" {
+		return "", cache.ErrUnknownURL
+	}
+
+	return p, nil
+}
+
+// Ref of a patch set, e.g. "refs/changes/45/12345/3"
+var gerritChangeRef = regexp.MustCompile(`^refs/changes/\d+/(\d+)/(\d+)$`)
+
+// Number of a change optionally followed by the number of a patch set, e.g. "12345/3"
+var gerritChangeNumber = regexp.MustCompile(`^(\d+)(?:/(\d+))?$`)
+
+// Return the number of the change and the revision designated by 'ref'. The revision is
+// "current" if 'ref' designates a change without specifying a patch set. 'ok' is false if
+// 'ref' does not designate a change.
+func parseGerritRef(ref string) (change int, revision string, ok bool) {
+	cs := gerritChangeRef.FindStringSubmatch(ref)
+	if cs == nil {
+		cs = gerritChangeNumber.FindStringSubmatch(ref)
+	}
+	if cs == nil {
+		return 0, "", false
+	}
+	change, err := strconv.Atoi(cs[1])
+	if err != nil {
+		return 0, "", false
+	}
+	revision = cs[2]
+	if revision == "" {
+		revision = "current"
+	}
+	return change, revision, true
+}
+
+type gerritCommit struct {
+	Commit string `json:"commit"`
+	Author struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Date  string `json:"date"`
+	} `json:"author"`
+	Message string `json:"message"`
+}
+
+// Layout of the timestamps of Gerrit, always expressed in UTC
+const gerritTimeLayout = "2006-01-02 15:04:05.000000000"
+
+// Commit returns the commit designated by 'ref' in the project of 'repo'. 'ref' is either the
+// SHA of a commit or a ref designating a change or one of its patch sets. The commit of a
+// change is the commit of its current patch set.
+func (c GerritClient) Commit(ctx context.Context, repo string, ref string) (utils.Commit, error) {
+	project, err := c.project(repo)
+	if err != nil {
+		return utils.Commit{}, err
+	}
+
+	var commit gerritCommit
+	var files map[string]struct {
+		Status  string `json:"status"`
+		OldPath string `json:"old_path"`
+	}
+	if number, revision, ok := parseGerritRef(ref); ok {
+		p := fmt.Sprintf("/changes/%s~%d/revisions/%s", url.PathEscape(project), number, url.PathEscape(revision))
+		if err := c.getJSON(ctx, p+"/commit", nil, &commit); err != nil {
+			return utils.Commit{}, err
+		}
+		if err := c.getJSON(ctx, p+"/files", nil, &files); err != nil {
+			return utils.Commit{}, err
+		}
+	} else {
+		p := fmt.Sprintf("/projects/%s/commits/%s", url.PathEscape(project), url.PathEscape(ref))
+		if err := c.getJSON(ctx, p, nil, &commit); err != nil {
+			return utils.Commit{}, err
+		}
+	}
+
+	date, err := time.Parse(gerritTimeLayout, commit.Author.Date)
+	if err != nil {
+		return utils.Commit{}, err
+	}
+	result := utils.Commit{
+		Sha:     commit.Commit,
+		Author:  fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email),
+		Date:    date,
+		Message: commit.Message,
+	}
+	if files != nil {
+		result.Files = make([]utils.ChangedFile, 0, len(files))
+		for path, file := range files {
+			// Gerrit lists the commit message as a file
+			if strings.HasPrefix(path, "/") {
+				continue
+			}
+			f := utils.ChangedFile{Path: path, Status: "modified", PreviousPath: file.OldPath}
+			switch file.Status {
+			case "A":
+				f.Status = "added"
+			case "D":
+				f.Status = "deleted"
+			case "R":
+				f.Status = "renamed"
+			case "C":
+				f.Status = "copied"
+			}
+			result.Files = append(result.Files, f)
+		}
+		sort.Slice(result.Files, func(i, j int) bool {
+			return result.Files[i].Path < result.Files[j].Path
+		})
+	}
+
+	return result, nil
+}
+
+// URLs posted in review messages
+var gerritMessageURL = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+
+// BuildURLs returns the URLs posted in the review messages of the patch sets whose commit is
+// 'sha', which usually designate the builds of the patch set. Messages are searched in every
+// project of the instance since the commit identifies the patch set.
+func (c GerritClient) BuildURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
+	var changes []struct {
+		Revisions map[string]struct {
+			Number int `json:"_number"`
+		} `json:"revisions"`
+		Messages []struct {
+			Message        string `json:"message"`
+			RevisionNumber int    `json:"_revision_number"`
+		} `json:"messages"`
+	}
+	query := url.Values{
+		"q": []string{"commit:" + sha},
+		"o": []string{"ALL_REVISIONS", "MESSAGES"},
+	}
+	if err := c.getJSON(ctx, "/changes/", query, &changes); err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, cache.ErrRepositoryNotFound
+	}
+
+	urls := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, change := range changes {
+		revision, exists := change.Revisions[sha]
+		if !exists {
+			continue
+		}
+		for _, message := range change.Messages {
+			if message.RevisionNumber != revision.Number {
+				continue
+			}
+			for _, u := range gerritMessageURL.FindAllString(message.Message, -1) {
+				u = strings.TrimRight(u, ".,;:!?")
+				// Links to the instance itself designate changes, not builds
+				if v, err := url.Parse(u); err != nil || v.Hostname() == c.baseURL.Hostname() {
+					continue
+				}
+				if !seen[u] {
+					seen[u] = true
+					urls = append(urls, u)
+				}
+			}
+		}
+	}
+
+	return urls, nil
+}
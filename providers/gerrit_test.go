@@ -0,0 +1,219 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func newGerritTestClient(t *testing.T) (GerritClient, *httptest.Server) {
+	// Names of projects are escaped so requests are matched against the escaped path
+	files := map[string]string{
+		"/a/accounts/self": "gerrit_account.json",
+		"/a/changes/":      "gerrit_changes.json",
+		"/a/changes/nbedos%2Fcitop~12345/revisions/3/commit":                          "gerrit_change_commit.json",
+		"/a/changes/nbedos%2Fcitop~12345/revisions/3/files":                           "gerrit_change_files.json",
+		"/a/changes/nbedos%2Fcitop~12345/revisions/current/commit":                    "gerrit_change_commit.json",
+		"/a/changes/nbedos%2Fcitop~12345/revisions/current/files":                     "gerrit_change_files.json",
+		"/a/projects/nbedos%2Fcitop/commits/1efc5a1e5d4d9a8a7d4e1e0e2ea3b1b6a9b1f4c2": "gerrit_commit.json",
+	}
+
+	ts := newFixtureServer(t, files, func(w http.ResponseWriter, r *http.Request) bool {
+		if username, password, ok := r.BasicAuth(); !ok || username != "nbedos" || password != "token" {
+			w.WriteHeader(401)
+			return true
+		}
+		if r.URL.EscapedPath() == "/a/changes/" && r.URL.Query().Get("q") != "commit:a24840cf94b395af69da4a1001d32e3694637e20" {
+			fmt.Fprint(w, ")]}'\n[]")
+			return true
+		}
+		return false
+	})
+
+	baseURL, err := url.Parse(ts.URL)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+
+	return NewGerritClient("gerrit", "gerrit", "nbedos", "token", *baseURL, time.Millisecond), ts
+}
+
+func TestGerritClient_BuildURLs(t *testing.T) {
+	client, ts := newGerritTestClient(t)
+	defer ts.Close()
+	ctx := context.Background()
+
+	t.Run("URLs posted on the patch set of a commit", func(t *testing.T) {
+		urls, err := client.BuildURLs(ctx, "nbedos", "citop", "a24840cf94b395af69da4a1001d32e3694637e20")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{
+			"https://jenkins.example.com/job/citop/42/",
+			"https://buildkite.com/nbedos/citop/builds/7",
+		}
+		if diff := cmp.Diff(expected, urls); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Commit of no change", func(t *testing.T) {
+		_, err := client.BuildURLs(ctx, "nbedos", "citop", "0000000000000000000000000000000000000000")
+		if err != cache.ErrRepositoryNotFound {
+			t.Fatalf("expected %v but got %v", cache.ErrRepositoryNotFound, err)
+		}
+	})
+}
+
+func TestGerritClient_Commit(t *testing.T) {
+	client, ts := newGerritTestClient(t)
+	defer ts.Close()
+	ctx := context.Background()
+
+	patchSet := utils.Commit{
+		Sha:     "a24840cf94b395af69da4a1001d32e3694637e20",
+		Author:  "nbedos <nicolas@example.com>",
+		Date:    time.Date(2020, 2, 3, 9, 58, 12, 0, time.UTC),
+		Message: "Add Gerrit provider\n\nChange-Id: I8473b95934b5732ac55d26311a706c9c2bde9940\n",
+		Files: []utils.ChangedFile{
+			{Status: "modified", Path: "main.go"},
+			{Status: "added", Path: "providers/gerrit.go"},
+			{Status: "renamed", Path: "providers/gerrit_test.go", PreviousPath: "providers/gerrit_old_test.go"},
+		},
+	}
+
+	for _, testCase := range []struct {
+		name     string
+		repo     string
+		ref      string
+		expected utils.Commit
+	}{
+		{
+			name:     "Change",
+			repo:     ts.URL + "/nbedos/citop",
+			ref:      "12345",
+			expected: patchSet,
+		},
+		{
+			name:     "Patch set",
+			repo:     ts.URL + "/a/nbedos/citop.git",
+			ref:      "12345/3",
+			expected: patchSet,
+		},
+		{
+			name:     "Ref of a patch set",
+			repo:     ts.URL + "/nbedos/citop",
+			ref:      "refs/changes/45/12345/3",
+			expected: patchSet,
+		},
+		{
+			name: "Commit",
+			repo: ts.URL + "/nbedos/citop",
+			ref:  "1efc5a1e5d4d9a8a7d4e1e0e2ea3b1b6a9b1f4c2",
+			expected: utils.Commit{
+				Sha:     "1efc5a1e5d4d9a8a7d4e1e0e2ea3b1b6a9b1f4c2",
+				Author:  "nbedos <nicolas@example.com>",
+				Date:    time.Date(2020, 2, 1, 18, 30, 0, 0, time.UTC),
+				Message: "Add Bamboo provider\n",
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			commit, err := client.Commit(ctx, testCase.repo, testCase.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.expected, commit); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("Repository of another host", func(t *testing.T) {
+		_, err := client.Commit(ctx, "https://github.com/nbedos/citop", "12345")
+		if err != cache.ErrUnknownURL {
+			t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+		}
+	})
+}
+
+func TestGerritClient_CheckAuthentication(t *testing.T) {
+	client, ts := newGerritTestClient(t)
+	defer ts.Close()
+
+	if err := client.CheckAuthentication(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	client.password = "invalid"
+	if err := client.CheckAuthentication(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestParseGerritRef(t *testing.T) {
+	for _, testCase := range []struct {
+		ref      string
+		change   int
+		revision string
+		ok       bool
+	}{
+		{ref: "12345", change: 12345, revision: "current", ok: true},
+		{ref: "12345/3", change: 12345, revision: "3", ok: true},
+		{ref: "refs/changes/45/12345/3", change: 12345, revision: "3", ok: true},
+		{ref: "master", ok: false},
+		{ref: "refs/heads/master", ok: false},
+		{ref: "12345/3/1", ok: false},
+	} {
+		t.Run(testCase.ref, func(t *testing.T) {
+			change, revision, ok := parseGerritRef(testCase.ref)
+			if change != testCase.change || revision != testCase.revision || ok != testCase.ok {
+				t.Fatalf("expected (%d, %q, %v) but got (%d, %q, %v)", testCase.change, testCase.revision, testCase.ok, change, revision, ok)
+			}
+		})
+	}
+}
+
+func TestGerritClient_project(t *testing.T) {
+	baseURL, err := url.Parse("https://example.com/gerrit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewGerritClient("gerrit", "gerrit", "", "", *baseURL, time.Millisecond)
+
+	for _, u := range []string{
+		"https://example.com/gerrit/nbedos/citop",
+		"https://example.com/gerrit/a/nbedos/citop.git",
+		"ssh://nbedos@example.com:29418/nbedos/citop",
+	} {
+		t.Run(u, func(t *testing.T) {
+			project, err := client.project(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if project != "nbedos/citop" {
+				t.Fatalf("expected %q but got %q", "nbedos/citop", project)
+			}
+		})
+	}
+
+	for _, u := range []string{
+		"https://github.com/nbedos/citop",
+		"https://example.com/gerrit/",
+	} {
+		t.Run(u, func(t *testing.T) {
+			if _, err := client.project(u); err != cache.ErrUnknownURL {
+				t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+			}
+		})
+	}
+}
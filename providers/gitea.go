@@ -0,0 +1,391 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// Maximum number of items per page of the API of Gitea
+const giteaPageSize = 50
+
+// GiteaClient reads the workflow runs of Gitea Actions, or of Forgejo Actions, on a self-hosted
+// forge. The API of Gitea Actions follows the API of GitHub Actions so workflow runs are shown
+// as pipelines whose stages are the jobs of the workflow and whose jobs are the steps of these
+// jobs. Workflow runs of a commit are listed by the client itself so it is also a source
+// provider.
+type GiteaClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    cache.Provider
+	repositoryRestriction
+}
+
+// NewGiteaClient returns a client for the forge whose web interface is at 'baseURL', e.g.
+// https://gitea.example.com, authenticated by a personal access token
+func NewGiteaClient(id string, name string, token string, baseURL url.URL, rateLimit time.Duration, options ...ClientOption) GiteaClient {
+	return GiteaClient{
+		baseURL:     baseURL,
+		httpClient:  newHTTPClient(requestTimeout, options),
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (c GiteaClient) ID() string {
+	return c.provider.ID
+}
+
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c GiteaClient) WithRepositories(repositories RepositoryPatterns) GiteaClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// Send a GET request to the endpoint 'path' of the API and return the body of the response
+func (c GiteaClient) get(ctx context.Context, path string, query url.Values) (*bytes.Buffer, error) {
+	u := c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v1" + path
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("token %s", c.token))
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: body.String(),
+		}
+	}
+
+	return body, nil
+}
+
+// Send a GET request to the endpoint 'path' of the API and decode the response into 'v'
+func (c GiteaClient) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	body, err := c.get(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body.Bytes(), v)
+}
+
+// CheckAuthentication returns an error if Gitea rejects the personal access token
+func (c GiteaClient) CheckAuthentication(ctx context.Context) error {
+	var user struct {
+		Login string `json:"login"`
+	}
+	return c.getJSON(ctx, "/user", nil, &user)
+}
+
+// Return the URL of the web page of a repository of the forge
+func (c GiteaClient) repositoryURL(owner string, repo string) string {
+	u := c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + fmt.Sprintf("/%s/%s", owner, repo)
+	u.RawQuery = ""
+	return u.String()
+}
+
+func (c GiteaClient) Commit(ctx context.Context, repo string, sha string) (utils.Commit, error) {
+	host, owner, repo, err := utils.RepoHostOwnerAndName(repo)
+	if err != nil || host != c.baseURL.Hostname() {
+		return utils.Commit{}, cache.ErrUnknownURL
+	}
+
+	var giteaCommit struct {
+		Sha    string `json:"sha"`
+		Commit struct {
+			Message string `json:"message"`
+			Author  struct {
+				Name  string    `json:"name"`
+				Email string    `json:"email"`
+				Date  time.Time `json:"date"`
+			} `json:"author"`
+			Verification *struct {
+				Verified bool   `json:"verified"`
+				Reason   string `json:"reason"`
+			} `json:"verification"`
+		} `json:"commit"`
+		Files []struct {
+			Filename string `json:"filename"`
+			Status   string `json:"status"`
+		} `json:"files"`
+	}
+	p := fmt.Sprintf("/repos/%s/%s/git/commits/%s", url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(sha))
+	if err := c.getJSON(ctx, p, url.Values{"stat": []string{"false"}}, &giteaCommit); err != nil {
+		return utils.Commit{}, err
+	}
+
+	commit := utils.Commit{
+		Sha:     giteaCommit.Sha,
+		Author:  fmt.Sprintf("%s <%s>", giteaCommit.Commit.Author.Name, giteaCommit.Commit.Author.Email),
+		Date:    giteaCommit.Commit.Author.Date,
+		Message: giteaCommit.Commit.Message,
+		Files:   make([]utils.ChangedFile, 0, len(giteaCommit.Files)),
+	}
+	for _, file := range giteaCommit.Files {
+		status := file.Status
+		if status == "removed" {
+			status = "deleted"
+		}
+		commit.Files = append(commit.Files, utils.ChangedFile{
+			Status: status,
+			Path:   file.Filename,
+		})
+	}
+	if verification := giteaCommit.Commit.Verification; verification != nil {
+		switch {
+		case verification.Verified:
+			commit.Signature = "verified"
+		case strings.Contains(verification.Reason, "not_signed"):
+			commit.Signature = "unsigned"
+		default:
+			commit.Signature = "unverified: " + verification.Reason
+		}
+	}
+
+	return commit, nil
+}
+
+// Workflow run of Gitea Actions, which has the times of the start and of the end of the run
+// instead of the times of its creation and of its last update
+type giteaWorkflowRun struct {
+	actionsWorkflowRun
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// List the workflow runs of the repository, newest first
+func (c GiteaClient) workflowRuns(ctx context.Context, owner string, repo string, query url.Values) ([]giteaWorkflowRun, error) {
+	var runs struct {
+		WorkflowRuns []giteaWorkflowRun `json:"workflow_runs"`
+	}
+	p := fmt.Sprintf("/repos/%s/%s/actions/runs", url.PathEscape(owner), url.PathEscape(repo))
+	if err := c.getJSON(ctx, p, query, &runs); err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == http.StatusNotFound {
+			return nil, cache.ErrRepositoryNotFound
+		}
+		return nil, err
+	}
+
+	return runs.WorkflowRuns, nil
+}
+
+// BuildURLs returns the web pages of the workflow runs of commit 'sha'
+func (c GiteaClient) BuildURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
+	urls := make([]string, 0)
+	query := url.Values{
+		"head_sha": []string{sha},
+		"limit":    []string{strconv.Itoa(giteaPageSize)},
+	}
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		runs, err := c.workflowRuns(ctx, owner, repo, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range runs {
+			if run.HeadSha == sha && run.HTMLURL != "" {
+				urls = append(urls, run.HTMLURL)
+			}
+		}
+		if len(runs) < giteaPageSize {
+			break
+		}
+	}
+
+	return urls, nil
+}
+
+// Return the owner, the name of the repository and the number of a workflow run given the URL
+// of its web page, e.g. https://gitea.example.com/owner/repo/actions/runs/12
+func (c GiteaClient) parseWebURL(u string) (string, string, int, error) {
+	v, err := url.Parse(u)
+	if err != nil || v.Hostname() != c.baseURL.Hostname() {
+		return "", "", 0, cache.ErrUnknownURL
+	}
+	p := strings.TrimPrefix(v.Path, strings.TrimSuffix(c.baseURL.Path, "/"))
+	cs := strings.Split(strings.Trim(p, "/"), "/")
+	if len(cs) != 5 || cs[0] == "" || cs[1] == "" || cs[2] != "actions" || cs[3] != "runs" {
+		return "", "", 0, cache.ErrUnknownURL
+	}
+	number, err := strconv.Atoi(cs[4])
+	if err != nil {
+		return "", "", 0, cache.ErrUnknownURL
+	}
+
+	return cs[0], cs[1], number, nil
+}
+
+// Return the workflow run whose number is 'number'. Web pages designate workflow runs by
+// number whereas the API designates them by identifier, which often are the same on forges
+// hosting few repositories.
+func (c GiteaClient) workflowRun(ctx context.Context, owner string, repo string, number int) (giteaWorkflowRun, error) {
+	var run giteaWorkflowRun
+	p := fmt.Sprintf("/repos/%s/%s/actions/runs/%d", url.PathEscape(owner), url.PathEscape(repo), number)
+	err := c.getJSON(ctx, p, nil, &run)
+	switch err := err.(type) {
+	case nil:
+		if run.RunNumber == number {
+			return run, nil
+		}
+	case HTTPError:
+		if err.Status != http.StatusNotFound {
+			return giteaWorkflowRun{}, err
+		}
+	default:
+		return giteaWorkflowRun{}, err
+	}
+
+	query := url.Values{"limit": []string{strconv.Itoa(giteaPageSize)}}
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		runs, err := c.workflowRuns(ctx, owner, repo, query)
+		if err != nil {
+			return giteaWorkflowRun{}, err
+		}
+		for _, run := range runs {
+			if run.RunNumber == number {
+				return run, nil
+			}
+		}
+		// Runs are listed newest first
+		if len(runs) < giteaPageSize || runs[len(runs)-1].RunNumber < number {
+			return giteaWorkflowRun{}, cache.ErrUnknownURL
+		}
+	}
+}
+
+// BuildFromURL returns the workflow run whose web page is 'u' as a pipeline whose stages are the
+// jobs of the workflow and whose jobs are the steps of these jobs
+func (c GiteaClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	owner, repo, number, err := c.parseWebURL(u)
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	run, err := c.workflowRun(ctx, owner, repo, number)
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	jobs := make([]actionsJob, 0)
+	query := url.Values{"limit": []string{strconv.Itoa(giteaPageSize)}}
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		var resp struct {
+			Jobs []actionsJob `json:"jobs"`
+		}
+		p := fmt.Sprintf("/repos/%s/%s/actions/runs/%d/jobs", url.PathEscape(owner), url.PathEscape(repo), run.ID)
+		if err := c.getJSON(ctx, p, query, &resp); err != nil {
+			return cache.Build{}, err
+		}
+		jobs = append(jobs, resp.Jobs...)
+		if len(resp.Jobs) < giteaPageSize {
+			break
+		}
+	}
+
+	run.Status, run.Conclusion = fromGiteaActionsState(run.Status, run.Conclusion)
+	if run.CreatedAt.IsZero() && run.StartedAt != nil {
+		run.CreatedAt = *run.StartedAt
+	}
+	if run.UpdatedAt.IsZero() {
+		run.UpdatedAt = utils.MaxNullTime(nullTimeFromPointer(run.StartedAt), nullTimeFromPointer(run.CompletedAt)).Time
+	}
+	if run.Name == "" {
+		// Path of the workflow file followed by the ref, e.g. "ci.yml@refs/heads/main"
+		run.Name = path.Base(strings.SplitN(run.Path, "@", 2)[0])
+	}
+	for i := range jobs {
+		jobs[i].Status, jobs[i].Conclusion = fromGiteaActionsState(jobs[i].Status, jobs[i].Conclusion)
+		for j := range jobs[i].Steps {
+			step := &jobs[i].Steps[j]
+			step.Status, step.Conclusion = fromGiteaActionsState(step.Status, step.Conclusion)
+		}
+	}
+
+	repository := cache.Repository{
+		Provider: c.provider,
+		URL:      c.repositoryURL(owner, repo),
+		Owner:    owner,
+		Name:     repo,
+	}
+
+	return fromActionsWorkflowRun(&repository, run.actionsWorkflowRun, jobs, nil), nil
+}
+
+// Log returns the part of the log of a job of Gitea Actions written by the step 'jobID'
+func (c GiteaClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	id, number, err := parseActionsStepJobID(jobID)
+	if err != nil {
+		return "", err
+	}
+
+	var job actionsJob
+	p := fmt.Sprintf("/repos/%s/%s/actions/jobs/%d", url.PathEscape(repository.Owner), url.PathEscape(repository.Name), id)
+	if err := c.getJSON(ctx, p, nil, &job); err != nil {
+		return "", err
+	}
+	log, err := c.get(ctx, p+"/logs", nil)
+	if err != nil {
+		return "", err
+	}
+
+	return stepLog(log.String(), job.Steps, number), nil
+}
+
+// Return the status and the conclusion of a workflow run, a job or a step in the vocabulary of
+// GitHub Actions. Older versions of Gitea and Forgejo only return a status named after the
+// internal state of the run, e.g. "running" or "failure".
+func fromGiteaActionsState(status string, conclusion string) (string, string) {
+	switch strings.ToLower(status) {
+	case "waiting", "blocked", "pending":
+		return "queued", ""
+	case "running":
+		return "in_progress", ""
+	case "success", "failure", "cancelled", "skipped":
+		return "completed", strings.ToLower(status)
+	}
+	return status, conclusion
+}
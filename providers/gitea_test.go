@@ -0,0 +1,223 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func newGiteaTestClient(t *testing.T) (GiteaClient, *httptest.Server) {
+	files := map[string]string{
+		"/api/v1/user": "gitea_user.json",
+		"/api/v1/repos/nbedos/citop/git/commits/a24840cf94b395af69da4a1001d32e3694637e20": "gitea_commit.json",
+		"/api/v1/repos/nbedos/citop/actions/runs":                                         "gitea_workflow_runs.json",
+		"/api/v1/repos/nbedos/citop/actions/runs/183/jobs":                                "gitea_workflow_jobs.json",
+		"/api/v1/repos/nbedos/citop/actions/jobs/421":                                     "gitea_job.json",
+		"/api/v1/repos/nbedos/citop/actions/jobs/421/logs":                                "gitea_job_log.txt",
+	}
+
+	ts := newFixtureServer(t, files, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "token token" {
+			w.WriteHeader(401)
+			return true
+		}
+		return false
+	})
+
+	baseURL, err := url.Parse(ts.URL)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+
+	return NewGiteaClient("gitea", "gitea", "token", *baseURL, time.Millisecond), ts
+}
+
+func TestGiteaClient_BuildURLs(t *testing.T) {
+	client, ts := newGiteaTestClient(t)
+	defer ts.Close()
+	ctx := context.Background()
+
+	t.Run("Workflow runs of a commit", func(t *testing.T) {
+		urls, err := client.BuildURLs(ctx, "nbedos", "citop", "a24840cf94b395af69da4a1001d32e3694637e20")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"https://gitea.example.com/nbedos/citop/actions/runs/7"}
+		if diff := cmp.Diff(expected, urls); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Unknown repository", func(t *testing.T) {
+		_, err := client.BuildURLs(ctx, "nbedos", "unknown", "a24840cf94b395af69da4a1001d32e3694637e20")
+		if err != cache.ErrRepositoryNotFound {
+			t.Fatalf("expected %v but got %v", cache.ErrRepositoryNotFound, err)
+		}
+	})
+}
+
+func TestGiteaClient_Commit(t *testing.T) {
+	client, ts := newGiteaTestClient(t)
+	defer ts.Close()
+
+	commit, err := client.Commit(context.Background(), ts.URL+"/nbedos/citop", "a24840cf94b395af69da4a1001d32e3694637e20")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := utils.Commit{
+		Sha:     "a24840cf94b395af69da4a1001d32e3694637e20",
+		Author:  "nbedos <nicolas@example.com>",
+		Date:    time.Date(2020, 2, 3, 9, 58, 12, 0, time.UTC),
+		Message: "Add Gitea provider\n",
+		Files: []utils.ChangedFile{
+			{Status: "added", Path: "providers/gitea.go"},
+			{Status: "modified", Path: "main.go"},
+		},
+		Signature: "unsigned",
+	}
+	if diff := cmp.Diff(expected, commit); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("Repository of another host", func(t *testing.T) {
+		_, err := client.Commit(context.Background(), "https://github.com/nbedos/citop", "a24840cf94b395af69da4a1001d32e3694637e20")
+		if err != cache.ErrUnknownURL {
+			t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+		}
+	})
+}
+
+func TestGiteaClient_BuildFromURL(t *testing.T) {
+	client, ts := newGiteaTestClient(t)
+	defer ts.Close()
+
+	build, err := client.BuildFromURL(context.Background(), ts.URL+"/nbedos/citop/actions/runs/7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(min int, sec int) utils.NullTime {
+		return utils.NullTime{Time: time.Date(2020, 2, 3, 10, min, sec, 0, time.UTC), Valid: true}
+	}
+	step := func(number int, state cache.State, name string, startedAt utils.NullTime, finishedAt utils.NullTime) *cache.Job {
+		return &cache.Job{
+			ID:         fmt.Sprintf("actions-421/%d", number),
+			State:      state,
+			Name:       name,
+			CreatedAt:  startedAt,
+			StartedAt:  startedAt,
+			FinishedAt: finishedAt,
+			Duration:   utils.NullSub(finishedAt, startedAt),
+			WebURL:     "https://gitea.example.com/nbedos/citop/actions/runs/7/jobs/0",
+		}
+	}
+	expected := cache.Build{
+		Repository: &cache.Repository{
+			Provider: cache.Provider{ID: "gitea", Name: "gitea"},
+			URL:      ts.URL + "/nbedos/citop",
+			Owner:    "nbedos",
+			Name:     "citop",
+		},
+		ID:              "183",
+		Name:            "ci.yml",
+		Commit:          cache.Commit{Sha: "a24840cf94b395af69da4a1001d32e3694637e20"},
+		Ref:             "master",
+		RepoBuildNumber: "ci.yml #7",
+		State:           cache.Failed,
+		CreatedAt:       at(0, 2),
+		StartedAt:       at(0, 5),
+		FinishedAt:      at(1, 28),
+		UpdatedAt:       at(1, 30).Time,
+		Duration:        utils.NullDuration{Duration: 83 * time.Second, Valid: true},
+		WebURL:          "https://gitea.example.com/nbedos/citop/actions/runs/7",
+		Stages: map[int]*cache.Stage{
+			1: {
+				ID:    1,
+				Name:  "test",
+				State: cache.Failed,
+				Jobs: []*cache.Job{
+					step(0, cache.Passed, "Set up job", at(0, 5), at(0, 10)),
+					step(1, cache.Failed, "go test ./...", at(0, 10), at(1, 25)),
+					step(2, cache.Passed, "Complete job", at(1, 25), at(1, 28)),
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, build); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("Unknown workflow run", func(t *testing.T) {
+		_, err := client.BuildFromURL(context.Background(), ts.URL+"/nbedos/citop/actions/runs/8")
+		if err != cache.ErrUnknownURL {
+			t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+		}
+	})
+}
+
+func TestGiteaClient_Log(t *testing.T) {
+	client, ts := newGiteaTestClient(t)
+	defer ts.Close()
+
+	repository := cache.Repository{Owner: "nbedos", Name: "citop"}
+	log, err := client.Log(context.Background(), repository, "actions-421/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "go test ./...\n--- FAIL: TestGiteaClient (0.00s)\nFAIL\n"
+	if diff := cmp.Diff(expected, log); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestGiteaClient_CheckAuthentication(t *testing.T) {
+	client, ts := newGiteaTestClient(t)
+	defer ts.Close()
+
+	if err := client.CheckAuthentication(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	client.token = "invalid"
+	if err := client.CheckAuthentication(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestParseGiteaURL(t *testing.T) {
+	baseURL, err := url.Parse("https://example.com/gitea")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewGiteaClient("gitea", "gitea", "", *baseURL, time.Millisecond)
+
+	owner, repo, number, err := client.parseWebURL("https://example.com/gitea/nbedos/citop/actions/runs/7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != "nbedos" || repo != "citop" || number != 7 {
+		t.Fatalf("expected (%q, %q, %d) but got (%q, %q, %d)", "nbedos", "citop", 7, owner, repo, number)
+	}
+
+	for _, u := range []string{
+		"https://github.com/nbedos/citop/actions/runs/7",
+		"https://example.com/gitea/nbedos/citop/actions/runs/abc",
+		"https://example.com/gitea/nbedos/citop/pulls/7",
+	} {
+		t.Run(u, func(t *testing.T) {
+			if _, _, _, err := client.parseWebURL(u); err != cache.ErrUnknownURL {
+				t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+			}
+		})
+	}
+}
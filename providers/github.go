@@ -4,8 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/google/go-github/v28/github"
 	"github.com/nbedos/citop/cache"
@@ -16,15 +20,47 @@ import (
 type GitHubClient struct {
 	id     string
 	client *github.Client
+	policy StatusPolicy
+	owners OwnerPatterns
+	repositoryRestriction
 }
 
-func NewGitHubClient(ctx context.Context, id string, token *string) GitHubClient {
-	var httpClient *http.Client
+// Sources of pipelines preferred by a StatusPolicy
+const (
+	PreferChecks   = "checks"
+	PreferStatuses = "statuses"
+)
+
+// StatusPolicy selects the commit statuses and the check runs used to find the pipelines of a
+// commit. Some CI services report each pipeline both ways, which would otherwise show the same
+// pipeline twice.
+type StatusPolicy struct {
+	// Source kept when a commit status and a check run point to the same CI service:
+	// PreferChecks, PreferStatuses, or an empty string to keep both
+	Prefer string
+	// Patterns, in the syntax of path.Match, of the contexts of the commit statuses to ignore
+	HiddenContexts []string
+}
+
+// Return true if commit statuses of context 'context' must be ignored
+func (p StatusPolicy) hidden(context string) bool {
+	for _, pattern := range p.HiddenContexts {
+		if matched, err := path.Match(pattern, context); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func NewGitHubClient(ctx context.Context, id string, token *string, options ...ClientOption) GitHubClient {
+	httpClient := newHTTPClient(0, options)
 
 	if token != nil {
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: *token},
 		)
+		// The OAuth2 client sends its requests through the client stored in the context
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
 		httpClient = oauth2.NewClient(ctx, ts)
 	}
 
@@ -34,14 +70,64 @@ func NewGitHubClient(ctx context.Context, id string, token *string) GitHubClient
 	}
 }
 
+// WithStatusPolicy returns a copy of the client applying 'policy' to the commit statuses and
+// check runs of commits
+func (c GitHubClient) WithStatusPolicy(policy StatusPolicy) GitHubClient {
+	c.policy = policy
+	return c
+}
+
+// WithOwners returns a copy of the client only used for the repositories of the owners matching
+// 'owners'
+func (c GitHubClient) WithOwners(owners OwnerPatterns) GitHubClient {
+	c.owners = owners
+	return c
+}
+
+// MatchesOwner returns true if the client is used for the repositories of 'owner'
+func (c GitHubClient) MatchesOwner(owner string) bool {
+	return c.owners.Match(owner)
+}
+
 func (c GitHubClient) ID() string {
 	return c.id
 }
 
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c GitHubClient) WithRepositories(repositories RepositoryPatterns) GitHubClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// CheckAuthentication returns an error if GitHub rejects the API token
+func (c GitHubClient) CheckAuthentication(ctx context.Context) error {
+	_, _, err := c.client.Users.Get(ctx, "")
+	return err
+}
+
+// ErrorStatusCode returns the status of the HTTP response that caused 'err'. Exhausted rate
+// limits are reported by GitHub with the status 403 and returned as 429 Too Many Requests.
+func (c GitHubClient) ErrorStatusCode(err error) (int, bool) {
+	switch err := err.(type) {
+	case *github.RateLimitError, *github.AbuseRateLimitError:
+		return http.StatusTooManyRequests, true
+	case *github.ErrorResponse:
+		if err.Response != nil {
+			return err.Response.StatusCode, true
+		}
+	}
+	return 0, false
+}
+
+// Return the host of the web interface of GitHub
+func (c GitHubClient) webHost() string {
+	return strings.TrimPrefix(c.client.BaseURL.Hostname(), "api.")
+}
+
 func (c GitHubClient) Commit(ctx context.Context, repo string, sha string) (utils.Commit, error) {
 	host, owner, repo, err := utils.RepoHostOwnerAndName(repo)
-	expectedHost := strings.TrimPrefix(c.client.BaseURL.Hostname(), "api.")
-	if err != nil || !strings.Contains(host, expectedHost) {
+	if err != nil || !strings.Contains(host, c.webHost()) {
 		return utils.Commit{}, cache.ErrUnknownURL
 	}
 
@@ -57,6 +143,28 @@ func (c GitHubClient) Commit(ctx context.Context, repo string, sha string) (util
 		Date:    githubCommit.GetAuthor().GetDate(),
 		Message: githubCommit.GetMessage(),
 	}
+	commit.Files = make([]utils.ChangedFile, 0, len(repoCommit.Files))
+	for _, file := range repoCommit.Files {
+		status := file.GetStatus()
+		if status == "removed" {
+			status = "deleted"
+		}
+		commit.Files = append(commit.Files, utils.ChangedFile{
+			Status:       status,
+			Path:         file.GetFilename(),
+			PreviousPath: file.GetPreviousFilename(),
+		})
+	}
+	if verification := githubCommit.GetVerification(); verification != nil {
+		switch reason := verification.GetReason(); {
+		case verification.GetVerified():
+			commit.Signature = "verified"
+		case reason == "unsigned":
+			commit.Signature = "unsigned"
+		default:
+			commit.Signature = "unverified: " + strings.Replace(reason, "_", " ", -1)
+		}
+	}
 
 	branches, _, err := c.client.Repositories.ListBranchesHeadCommit(ctx, owner, repo, commit.Sha)
 	if err != nil {
@@ -87,11 +195,107 @@ func (c GitHubClient) Commit(ctx context.Context, repo string, sha string) (util
 	return commit, nil
 }
 
+// Pipelines reported by a commit status are matched with pipelines reported by a check run if
+// the hosts of their URLs are the same once this prefix is removed
+const hostPrefix = "www."
+
+// Return the host of a URL, or an empty string if the URL is invalid
+func urlHost(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), hostPrefix)
+}
+
+// Return 'urls' without the URLs whose host is the host of one of 'others'
+func withoutHostsOf(urls []string, others []string) []string {
+	hosts := make(map[string]bool, len(others))
+	for _, u := range others {
+		if host := urlHost(u); host != "" {
+			hosts[host] = true
+		}
+	}
+	filtered := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if !hosts[urlHost(u)] {
+			filtered = append(filtered, u)
+		}
+	}
+	return filtered
+}
+
+// PullRequest returns the pull request containing the commit 'sha' along with the outcome of its
+// reviews. Open pull requests are preferred over closed ones.
+func (c GitHubClient) PullRequest(ctx context.Context, repo string, sha string) (utils.PullRequest, error) {
+	host, owner, repo, err := utils.RepoHostOwnerAndName(repo)
+	if err != nil || !strings.Contains(host, c.webHost()) {
+		return utils.PullRequest{}, cache.ErrUnknownURL
+	}
+
+	pulls, _, err := c.client.PullRequests.ListPullRequestsWithCommit(ctx, owner, repo, sha, nil)
+	if err != nil {
+		return utils.PullRequest{}, err
+	}
+	if len(pulls) == 0 {
+		return utils.PullRequest{}, cache.ErrNoPullRequest
+	}
+	pull := pulls[0]
+	for _, p := range pulls {
+		if p.GetState() == "open" {
+			pull = p
+			break
+		}
+	}
+
+	pr := utils.PullRequest{
+		Number: pull.GetNumber(),
+		Title:  pull.GetTitle(),
+		State:  pull.GetState(),
+		WebURL: pull.GetHTMLURL(),
+	}
+	if pull.GetMerged() || pull.MergedAt != nil {
+		pr.State = "merged"
+	}
+
+	// Only the last review of each reviewer counts
+	reviewStates := make(map[int64]string)
+	opt := github.ListOptions{}
+	for {
+		reviews, resp, err := c.client.PullRequests.ListReviews(ctx, owner, repo, pr.Number, &opt)
+		if err != nil {
+			return utils.PullRequest{}, err
+		}
+		for _, review := range reviews {
+			switch state := review.GetState(); state {
+			case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+				reviewStates[review.GetUser().GetID()] = state
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	pr.Review = "review required"
+	for _, state := range reviewStates {
+		switch {
+		case state == "CHANGES_REQUESTED":
+			pr.Review = "changes requested"
+		case state == "APPROVED" && pr.Review != "changes requested":
+			pr.Review = "approved"
+		}
+	}
+
+	return pr, nil
+}
+
 func (c GitHubClient) BuildURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
 	errc := make(chan error)
 
-	previousURLs := make(map[string]struct{})
-	mux := sync.Mutex{}
+	statusURLs := make([]string, 0)
+	checkURLs := make([]string, 0)
+	deploymentURLs := make([]string, 0)
 
 	go func() {
 		opt := github.ListOptions{}
@@ -102,12 +306,10 @@ func (c GitHubClient) BuildURLs(ctx context.Context, owner string, repo string,
 				return
 			}
 			for _, status := range statuses {
-				if status.TargetURL == nil {
+				if status.TargetURL == nil || c.policy.hidden(status.GetContext()) {
 					continue
 				}
-				mux.Lock()
-				previousURLs[*status.TargetURL] = struct{}{}
-				mux.Unlock()
+				statusURLs = append(statusURLs, *status.TargetURL)
 			}
 
 			if resp.NextPage == 0 {
@@ -120,6 +322,8 @@ func (c GitHubClient) BuildURLs(ctx context.Context, owner string, repo string,
 
 	go func() {
 		opt := github.ListCheckRunsOptions{}
+		// Check runs of GitHub Actions are jobs of workflow runs, which are listed instead
+		actionsURLs := make([]string, 0)
 		for {
 			runs, resp, err := c.client.Checks.ListCheckRunsForRef(ctx, owner, repo, sha, &opt)
 			if err != nil {
@@ -131,9 +335,46 @@ func (c GitHubClient) BuildURLs(ctx context.Context, owner string, repo string,
 				if run == nil || run.DetailsURL == nil {
 					continue
 				}
-				mux.Lock()
-				previousURLs[*run.DetailsURL] = struct{}{}
-				mux.Unlock()
+				if isGitHubActionsApp(run.GetApp()) {
+					actionsURLs = append(actionsURLs, *run.DetailsURL)
+					continue
+				}
+				checkURLs = append(checkURLs, *run.DetailsURL)
+			}
+
+			if resp.NextPage == 0 {
+				break
+			}
+			opt.Page = resp.NextPage
+		}
+
+		if len(actionsURLs) > 0 {
+			urls, err := c.workflowRunURLs(ctx, owner, repo, sha)
+			if err != nil {
+				// Instances of GitHub Enterprise without the API of GitHub Actions
+				if err, ok := err.(*github.ErrorResponse); !ok || err.Response.StatusCode != 404 {
+					errc <- err
+					return
+				}
+				urls = actionsURLs
+			}
+			checkURLs = append(checkURLs, urls...)
+		}
+		errc <- nil
+	}()
+
+	go func() {
+		opt := github.DeploymentsListOptions{SHA: sha}
+		for {
+			deployments, resp, err := c.client.Repositories.ListDeployments(ctx, owner, repo, &opt)
+			if err != nil {
+				errc <- err
+				return
+			}
+			for _, deployment := range deployments {
+				if deployment.URL != nil {
+					deploymentURLs = append(deploymentURLs, *deployment.URL)
+				}
 			}
 
 			if resp.NextPage == 0 {
@@ -145,7 +386,7 @@ func (c GitHubClient) BuildURLs(ctx context.Context, owner string, repo string,
 	}()
 
 	var err error
-	for i := 0; i < 2; i++ {
+	for i := 0; i < 3; i++ {
 		if e := <-errc; err == nil {
 			switch errResp := e.(type) {
 			case *github.ErrorResponse:
@@ -162,10 +403,476 @@ func (c GitHubClient) BuildURLs(ctx context.Context, owner string, repo string,
 		}
 	}
 
-	urls := make([]string, 0, len(previousURLs))
-	for u := range previousURLs {
-		urls = append(urls, u)
+	// All goroutines are done so the slices can be read safely
+	switch c.policy.Prefer {
+	case PreferChecks:
+		statusURLs = withoutHostsOf(statusURLs, checkURLs)
+	case PreferStatuses:
+		checkURLs = withoutHostsOf(checkURLs, statusURLs)
+	}
+
+	previousURLs := make(map[string]struct{})
+	urls := make([]string, 0, len(statusURLs)+len(checkURLs)+len(deploymentURLs))
+	for _, u := range append(append(statusURLs, checkURLs...), deploymentURLs...) {
+		if _, exists := previousURLs[u]; !exists {
+			previousURLs[u] = struct{}{}
+			urls = append(urls, u)
+		}
 	}
 
 	return urls, err
 }
+
+// Media types required to get the states "in_progress" and "queued" of deployment statuses and
+// their fields "environment_url" and "log_url"
+const deploymentStatusMediaTypes = "application/vnd.github.ant-man-preview+json, application/vnd.github.flash-preview+json"
+
+// Return the owner, the name of the repository and the identifier of a deployment given its URL
+// in the GitHub API
+func (c GitHubClient) parseDeploymentURL(u string) (string, string, int64, error) {
+	prefix := c.client.BaseURL.String() + "repos/"
+	if !strings.HasPrefix(u, prefix) {
+		return "", "", 0, cache.ErrUnknownURL
+	}
+	// owner/repo/deployments/id
+	cs := strings.Split(strings.TrimPrefix(u, prefix), "/")
+	if len(cs) != 4 || cs[0] == "" || cs[1] == "" || cs[2] != "deployments" {
+		return "", "", 0, cache.ErrUnknownURL
+	}
+	id, err := strconv.ParseInt(cs[3], 10, 64)
+	if err != nil {
+		return "", "", 0, cache.ErrUnknownURL
+	}
+
+	return cs[0], cs[1], id, nil
+}
+
+// deploymentStatus is a github.DeploymentStatus with the fields only returned by the preview
+// versions of the API
+type deploymentStatus struct {
+	github.DeploymentStatus
+	EnvironmentURL *string `json:"environment_url,omitempty"`
+	LogURL         *string `json:"log_url,omitempty"`
+}
+
+// Return the statuses of a deployment, most recent first
+func (c GitHubClient) deploymentStatuses(ctx context.Context, owner string, repo string, id int64) ([]deploymentStatus, error) {
+	statuses := make([]deploymentStatus, 0)
+	opt := github.ListOptions{PerPage: 100}
+	for {
+		u := fmt.Sprintf("repos/%v/%v/deployments/%v/statuses?per_page=%d&page=%d", owner, repo, id, opt.PerPage, opt.Page)
+		req, err := c.client.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", deploymentStatusMediaTypes)
+
+		var page []deploymentStatus
+		resp, err := c.client.Do(ctx, req, &page)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].GetCreatedAt().After(statuses[j].GetCreatedAt().Time)
+	})
+
+	return statuses, nil
+}
+
+// Return the state of a deployment given the state of its latest status
+func fromGitHubDeploymentState(s string) cache.State {
+	switch strings.ToLower(s) {
+	case "", "pending", "queued":
+		return cache.Pending
+	case "in_progress":
+		return cache.Running
+	case "success":
+		return cache.Passed
+	case "failure", "error":
+		return cache.Failed
+	case "inactive":
+		return cache.Canceled
+	default:
+		return cache.Unknown
+	}
+}
+
+// BuildFromURL returns the deployment at URL 'u' in the GitHub API as a pipeline made of a
+// single job named after the environment. The web page of the pipeline is the URL of the
+// environment and the web page of the job is the URL of the deployment log.
+//
+// Workflow runs of GitHub Actions are returned as pipelines whose stages are the jobs of the
+// workflow and whose jobs are the steps of these jobs (see fromGitHubWorkflowRun). Other check
+// runs whose web page is hosted by GitHub are returned as pipelines made of a single job (see
+// fromGitHubCheckRun).
+func (c GitHubClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	if owner, repo, id, err := c.parseWorkflowRunURL(u); err == nil {
+		return c.workflowRun(ctx, owner, repo, id)
+	}
+
+	owner, repo, id, err := c.parseDeploymentURL(u)
+	if err == cache.ErrUnknownURL {
+		if owner, repo, id, err = c.parseCheckRunURL(u); err == nil {
+			run, _, err := c.client.Checks.GetCheckRun(ctx, owner, repo, id)
+			if err != nil {
+				return cache.Build{}, err
+			}
+			annotations, err := c.checkRunAnnotations(ctx, owner, repo, *run)
+			if err != nil {
+				return cache.Build{}, err
+			}
+			return fromGitHubCheckRun(c.id, owner, repo, *run, annotations), nil
+		}
+	}
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	deployment, _, err := c.client.Repositories.GetDeployment(ctx, owner, repo, id)
+	if err != nil {
+		return cache.Build{}, err
+	}
+	statuses, err := c.deploymentStatuses(ctx, owner, repo, id)
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	return fromGitHubDeployment(c.id, owner, repo, *deployment, statuses), nil
+}
+
+func fromGitHubDeployment(providerID string, owner string, repo string, deployment github.Deployment, statuses []deploymentStatus) cache.Build {
+	repository := cache.Repository{
+		Provider: cache.Provider{
+			ID:   providerID,
+			Name: "github",
+		},
+		URL:   fmt.Sprintf("https://github.com/%s/%s", owner, repo),
+		Owner: owner,
+		Name:  repo,
+	}
+
+	var latest deploymentStatus
+	if len(statuses) > 0 {
+		latest = statuses[0]
+	}
+	state := fromGitHubDeploymentState(latest.GetState())
+
+	createdAt := utils.NullTime{
+		Time:  deployment.GetCreatedAt().Time,
+		Valid: deployment.CreatedAt != nil,
+	}
+	updatedAt := deployment.GetUpdatedAt().Time
+	if latest.UpdatedAt != nil && latest.GetUpdatedAt().After(updatedAt) {
+		updatedAt = latest.GetUpdatedAt().Time
+	}
+	finishedAt := utils.NullTime{}
+	if !state.IsActive() && latest.CreatedAt != nil {
+		finishedAt = utils.NullTime{Time: latest.GetCreatedAt().Time, Valid: true}
+	}
+	duration := utils.NullDuration{}
+	if createdAt.Valid && finishedAt.Valid {
+		duration = utils.NullDuration{Duration: finishedAt.Time.Sub(createdAt.Time), Valid: true}
+	}
+
+	// Deployments without an environment URL are shown with their latest target URL
+	logURL := latest.GetTargetURL()
+	if latest.LogURL != nil {
+		logURL = *latest.LogURL
+	}
+	webURL := logURL
+	for _, status := range statuses {
+		if status.EnvironmentURL != nil && *status.EnvironmentURL != "" {
+			webURL = *status.EnvironmentURL
+			break
+		}
+	}
+
+	ID := strconv.FormatInt(deployment.GetID(), 10)
+	environment := deployment.GetEnvironment()
+	if environment == "" {
+		environment = "deployment"
+	}
+	job := cache.Job{
+		ID:         ID,
+		State:      state,
+		Name:       environment,
+		CreatedAt:  createdAt,
+		StartedAt:  createdAt,
+		FinishedAt: finishedAt,
+		Duration:   duration,
+		WebURL:     logURL,
+	}
+
+	return cache.Build{
+		Repository: &repository,
+		ID:         ID,
+		Commit: cache.Commit{
+			Sha: deployment.GetSHA(),
+		},
+		Ref:        deployment.GetRef(),
+		State:      state,
+		CreatedAt:  createdAt,
+		StartedAt:  createdAt,
+		FinishedAt: finishedAt,
+		UpdatedAt:  updatedAt,
+		Duration:   duration,
+		WebURL:     webURL,
+		Stages:     map[int]*cache.Stage{},
+		Jobs:       []*cache.Job{&job},
+	}
+}
+
+// Log returns the history of the statuses of the deployment 'jobID', oldest first, since
+// GitHub does not store the logs of deployments. The log of a check run is its output and the
+// log of a step of GitHub Actions is the part of the log of its job written by the step.
+func (c GitHubClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	if strings.HasPrefix(jobID, actionsStepJobPrefix) {
+		return c.actionsStepLog(ctx, repository, jobID)
+	}
+	if strings.HasPrefix(jobID, checkRunJobPrefix) {
+		id, err := strconv.ParseInt(strings.TrimPrefix(jobID, checkRunJobPrefix), 10, 64)
+		if err != nil {
+			return "", err
+		}
+		run, _, err := c.client.Checks.GetCheckRun(ctx, repository.Owner, repository.Name, id)
+		if err != nil {
+			return "", err
+		}
+		return checkRunLog(*run), nil
+	}
+
+	id, err := strconv.ParseInt(jobID, 10, 64)
+	if err != nil {
+		return "", err
+	}
+	statuses, err := c.deploymentStatuses(ctx, repository.Owner, repository.Name, id)
+	if err != nil {
+		return "", err
+	}
+
+	return deploymentLog(statuses), nil
+}
+
+func deploymentLog(statuses []deploymentStatus) string {
+	builder := strings.Builder{}
+	for i := len(statuses) - 1; i >= 0; i-- {
+		status := statuses[i]
+		line := fmt.Sprintf("%s %s", status.GetCreatedAt().UTC().Format(time.RFC3339), status.GetState())
+		if description := status.GetDescription(); description != "" {
+			line += ": " + description
+		}
+		if status.LogURL != nil {
+			line += " (" + *status.LogURL + ")"
+		} else if u := status.GetTargetURL(); u != "" {
+			line += " (" + u + ")"
+		}
+		builder.WriteString(line + "\n")
+	}
+	return builder.String()
+}
+
+// Prefix of the identifiers of the jobs made from check runs, which distinguishes them from the
+// jobs made from deployments
+const checkRunJobPrefix = "run-"
+
+// Return the owner, the name of the repository and the identifier of a check run given the URL
+// of its web page, e.g. https://github.com/nbedos/citop/runs/352137581
+func (c GitHubClient) parseCheckRunURL(u string) (string, string, int64, error) {
+	v, err := url.Parse(u)
+	if err != nil || v.Hostname() != c.webHost() {
+		return "", "", 0, cache.ErrUnknownURL
+	}
+	// owner/repo/runs/id
+	cs := strings.Split(strings.Trim(v.Path, "/"), "/")
+	if len(cs) != 4 || cs[0] == "" || cs[1] == "" || cs[2] != "runs" {
+		return "", "", 0, cache.ErrUnknownURL
+	}
+	id, err := strconv.ParseInt(cs[3], 10, 64)
+	if err != nil {
+		return "", "", 0, cache.ErrUnknownURL
+	}
+
+	return cs[0], cs[1], id, nil
+}
+
+// Return the annotations of a check run on the code of the repository
+func (c GitHubClient) checkRunAnnotations(ctx context.Context, owner string, repo string, run github.CheckRun) ([]cache.CodeAnnotation, error) {
+	if run.GetOutput().GetAnnotationsCount() == 0 {
+		return make([]cache.CodeAnnotation, 0), nil
+	}
+	return c.listCheckRunAnnotations(ctx, owner, repo, run.GetID(), run.GetHeadSHA())
+}
+
+// Return the annotations of the check run identified by 'id' on commit 'sha' of the repository
+func (c GitHubClient) listCheckRunAnnotations(ctx context.Context, owner string, repo string, id int64, sha string) ([]cache.CodeAnnotation, error) {
+	annotations := make([]cache.CodeAnnotation, 0)
+	opt := github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := c.client.Checks.ListCheckRunAnnotations(ctx, owner, repo, id, &opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range page {
+			annotation := cache.CodeAnnotation{
+				Level:     a.GetAnnotationLevel(),
+				Path:      a.GetPath(),
+				StartLine: a.GetStartLine(),
+				EndLine:   a.GetEndLine(),
+				Title:     a.GetTitle(),
+				Message:   a.GetMessage(),
+			}
+			if annotation.Path != "" && sha != "" {
+				annotation.WebURL = fmt.Sprintf("https://%s/%s/%s/blob/%s/%s#L%d", c.webHost(), owner, repo, sha, annotation.Path, annotation.StartLine)
+				if annotation.EndLine > annotation.StartLine {
+					annotation.WebURL += fmt.Sprintf("-L%d", annotation.EndLine)
+				}
+			}
+			annotations = append(annotations, annotation)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return annotations, nil
+}
+
+// Return the state of a check run given its status and its conclusion
+func fromGitHubCheckRunState(status string, conclusion string) cache.State {
+	switch strings.ToLower(status) {
+	case "queued":
+		return cache.Pending
+	case "in_progress":
+		return cache.Running
+	}
+
+	switch strings.ToLower(conclusion) {
+	case "success", "neutral":
+		return cache.Passed
+	case "failure", "timed_out":
+		return cache.Failed
+	case "cancelled":
+		return cache.Canceled
+	case "skipped", "stale":
+		return cache.Skipped
+	case "action_required":
+		return cache.Manual
+	default:
+		return cache.Unknown
+	}
+}
+
+// Return the style of the annotation summarizing a check run
+func checkRunStyle(state cache.State) string {
+	switch state {
+	case cache.Passed:
+		return "success"
+	case cache.Failed:
+		return "error"
+	case cache.Manual:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// Return the check run as a pipeline made of a single job. The output of the check run is
+// attached to the pipeline as an annotation and its annotations on the code are attached to the
+// job.
+func fromGitHubCheckRun(providerID string, owner string, repo string, run github.CheckRun, codeAnnotations []cache.CodeAnnotation) cache.Build {
+	repository := cache.Repository{
+		Provider: cache.Provider{
+			ID:   providerID,
+			Name: "github",
+		},
+		URL:   fmt.Sprintf("https://github.com/%s/%s", owner, repo),
+		Owner: owner,
+		Name:  repo,
+	}
+
+	state := fromGitHubCheckRunState(run.GetStatus(), run.GetConclusion())
+	startedAt := utils.NullTime{
+		Time:  run.GetStartedAt().Time,
+		Valid: run.StartedAt != nil,
+	}
+	finishedAt := utils.NullTime{
+		Time:  run.GetCompletedAt().Time,
+		Valid: run.CompletedAt != nil && !state.IsActive(),
+	}
+	duration := utils.NullSub(finishedAt, startedAt)
+
+	ID := strconv.FormatInt(run.GetID(), 10)
+	job := cache.Job{
+		ID:          checkRunJobPrefix + ID,
+		State:       state,
+		Name:        run.GetName(),
+		CreatedAt:   startedAt,
+		StartedAt:   startedAt,
+		FinishedAt:  finishedAt,
+		Duration:    duration,
+		WebURL:      run.GetHTMLURL(),
+		Annotations: codeAnnotations,
+	}
+
+	annotations := make([]cache.Annotation, 0)
+	if output := run.GetOutput(); output.GetSummary() != "" || output.GetText() != "" {
+		title := output.GetTitle()
+		if title == "" {
+			title = run.GetName()
+		}
+		body := output.GetSummary()
+		if text := output.GetText(); text != "" {
+			body = strings.TrimSpace(body + "\n\n" + text)
+		}
+		annotations = append(annotations, cache.Annotation{
+			Title: title,
+			Style: checkRunStyle(state),
+			Body:  body,
+		})
+	}
+
+	return cache.Build{
+		Repository: &repository,
+		ID:         ID,
+		Name:       run.GetName(),
+		Commit: cache.Commit{
+			Sha: run.GetHeadSHA(),
+		},
+		Ref:         run.GetCheckSuite().GetHeadBranch(),
+		State:       state,
+		CreatedAt:   startedAt,
+		StartedAt:   startedAt,
+		FinishedAt:  finishedAt,
+		UpdatedAt:   utils.MaxNullTime(startedAt, finishedAt).Time,
+		Duration:    duration,
+		WebURL:      run.GetHTMLURL(),
+		Stages:      map[int]*cache.Stage{},
+		Jobs:        []*cache.Job{&job},
+		Annotations: annotations,
+	}
+}
+
+// Return the output of a check run as a log
+func checkRunLog(run github.CheckRun) string {
+	output := run.GetOutput()
+	parts := make([]string, 0, 3)
+	for _, part := range []string{output.GetTitle(), output.GetSummary(), output.GetText()} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, "\n\n") + "\n"
+}
@@ -0,0 +1,534 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// Slug of the GitHub App reporting the jobs of GitHub Actions as check runs
+const gitHubActionsApp = "github-actions"
+
+// Return true if 'app' is the GitHub App of GitHub Actions. The slug of the app is the last
+// component of the URL of its page, e.g. https://github.com/apps/github-actions
+func isGitHubActionsApp(app *github.App) bool {
+	return path.Base(app.GetHTMLURL()) == gitHubActionsApp
+}
+
+// Prefix of the identifiers of the jobs made from the steps of the jobs of GitHub Actions
+const actionsStepJobPrefix = "actions-"
+
+type actionsWorkflowRun struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	HeadBranch string    `json:"head_branch"`
+	HeadSha    string    `json:"head_sha"`
+	RunNumber  int       `json:"run_number"`
+	Path       string    `json:"path"`
+	Status     string    `json:"status"`
+	Conclusion string    `json:"conclusion"`
+	HTMLURL    string    `json:"html_url"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type actionsJob struct {
+	ID          int64         `json:"id"`
+	Name        string        `json:"name"`
+	Status      string        `json:"status"`
+	Conclusion  string        `json:"conclusion"`
+	StartedAt   *time.Time    `json:"started_at"`
+	CompletedAt *time.Time    `json:"completed_at"`
+	HTMLURL     string        `json:"html_url"`
+	Steps       []actionsStep `json:"steps"`
+}
+
+type actionsStep struct {
+	Number      int        `json:"number"`
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Conclusion  string     `json:"conclusion"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// Send a GET request to the endpoint 'path' of the API of GitHub Actions and decode the
+// response into 'v', or write it to 'v' if it is an io.Writer
+func (c GitHubClient) getActions(ctx context.Context, path string, query url.Values, v interface{}) (*github.Response, error) {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	req, err := c.client.NewRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.Do(ctx, req, v)
+}
+
+// Send a POST request with the JSON body 'body' to the endpoint 'path' of the API of GitHub
+// Actions
+func (c GitHubClient) postActions(ctx context.Context, path string, body interface{}) (*github.Response, error) {
+	req, err := c.client.NewRequest("POST", path, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.Do(ctx, req, nil)
+}
+
+type actionsWorkflow struct {
+	ID    int64  `json:"id"`
+	Path  string `json:"path"`
+	State string `json:"state"`
+}
+
+// Return the active workflows of the repository whose definition on 'ref' lists the event
+// workflow_dispatch, that is to say the workflows that can be started by a user
+func (c GitHubClient) dispatchableWorkflows(ctx context.Context, owner string, repo string, ref string) ([]actionsWorkflow, error) {
+	workflows := make([]actionsWorkflow, 0)
+	query := url.Values{"per_page": []string{"100"}}
+	for {
+		var page struct {
+			Workflows []actionsWorkflow `json:"workflows"`
+		}
+		resp, err := c.getActions(ctx, fmt.Sprintf("repos/%s/%s/actions/workflows", owner, repo), query, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, workflow := range page.Workflows {
+			if workflow.State != "active" {
+				continue
+			}
+			opt := github.RepositoryContentGetOptions{Ref: ref}
+			file, _, _, err := c.client.Repositories.GetContents(ctx, owner, repo, workflow.Path, &opt)
+			if err != nil {
+				return nil, err
+			}
+			if file == nil {
+				continue
+			}
+			content, err := file.GetContent()
+			if err != nil {
+				return nil, err
+			}
+			if strings.Contains(content, "workflow_dispatch") {
+				workflows = append(workflows, workflow)
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		query.Set("page", strconv.Itoa(resp.NextPage))
+	}
+
+	return workflows, nil
+}
+
+// TriggerPipeline sends the event workflow_dispatch to the workflows of the repository at
+// 'repositoryURL' accepting it on 'ref'. Variables are passed as inputs of the workflows, which
+// must declare them. GitHub creates workflow runs asynchronously so the web page listing the
+// workflow runs of the repository is returned.
+func (c GitHubClient) TriggerPipeline(ctx context.Context, repositoryURL string, ref string, variables []cache.Variable) (string, error) {
+	host, owner, repo, err := utils.RepoHostOwnerAndName(repositoryURL)
+	if err != nil || !strings.Contains(host, c.webHost()) {
+		return "", cache.ErrUnknownURL
+	}
+
+	workflows, err := c.dispatchableWorkflows(ctx, owner, repo, ref)
+	if err != nil {
+		if err, ok := err.(*github.ErrorResponse); ok && err.Response.StatusCode == 404 {
+			return "", cache.ErrRepositoryNotFound
+		}
+		return "", err
+	}
+	if len(workflows) == 0 {
+		return "", cache.ErrUnsupportedAction
+	}
+
+	dispatch := struct {
+		Ref    string            `json:"ref"`
+		Inputs map[string]string `json:"inputs,omitempty"`
+	}{
+		Ref: ref,
+	}
+	if len(variables) > 0 {
+		dispatch.Inputs = make(map[string]string, len(variables))
+		for _, v := range variables {
+			dispatch.Inputs[v.Name] = v.Value
+		}
+	}
+	for _, workflow := range workflows {
+		endpoint := fmt.Sprintf("repos/%s/%s/actions/workflows/%d/dispatches", owner, repo, workflow.ID)
+		if _, err := c.postActions(ctx, endpoint, dispatch); err != nil {
+			return "", fmt.Errorf("workflow %s: %v", workflow.Path, err)
+		}
+	}
+
+	return fmt.Sprintf("https://%s/%s/%s/actions", c.webHost(), owner, repo), nil
+}
+
+// Return the web pages of the workflow runs of commit 'sha'
+func (c GitHubClient) workflowRunURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
+	urls := make([]string, 0)
+	query := url.Values{
+		"head_sha": []string{sha},
+		"per_page": []string{"100"},
+	}
+	for {
+		var runs struct {
+			WorkflowRuns []actionsWorkflowRun `json:"workflow_runs"`
+		}
+		resp, err := c.getActions(ctx, fmt.Sprintf("repos/%s/%s/actions/runs", owner, repo), query, &runs)
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range runs.WorkflowRuns {
+			// Older versions of the API ignore the filter
+			if run.HeadSha == sha && run.HTMLURL != "" {
+				urls = append(urls, run.HTMLURL)
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		query.Set("page", strconv.Itoa(resp.NextPage))
+	}
+
+	return urls, nil
+}
+
+// Return the owner, the name of the repository and the identifier of a workflow run given the
+// URL of its web page, e.g. https://github.com/nbedos/citop/actions/runs/39455623
+func (c GitHubClient) parseWorkflowRunURL(u string) (string, string, int64, error) {
+	v, err := url.Parse(u)
+	if err != nil || v.Hostname() != c.webHost() {
+		return "", "", 0, cache.ErrUnknownURL
+	}
+	// owner/repo/actions/runs/id
+	cs := strings.Split(strings.Trim(v.Path, "/"), "/")
+	if len(cs) != 5 || cs[0] == "" || cs[1] == "" || cs[2] != "actions" || cs[3] != "runs" {
+		return "", "", 0, cache.ErrUnknownURL
+	}
+	id, err := strconv.ParseInt(cs[4], 10, 64)
+	if err != nil {
+		return "", "", 0, cache.ErrUnknownURL
+	}
+
+	return cs[0], cs[1], id, nil
+}
+
+// Return the workflow run identified by 'id' as a pipeline whose stages are the jobs of the
+// workflow and whose jobs are the steps of these jobs
+func (c GitHubClient) workflowRun(ctx context.Context, owner string, repo string, id int64) (cache.Build, error) {
+	var run actionsWorkflowRun
+	if _, err := c.getActions(ctx, fmt.Sprintf("repos/%s/%s/actions/runs/%d", owner, repo, id), nil, &run); err != nil {
+		return cache.Build{}, err
+	}
+
+	jobs := make([]actionsJob, 0)
+	query := url.Values{"per_page": []string{"100"}}
+	for {
+		var page struct {
+			Jobs []actionsJob `json:"jobs"`
+		}
+		resp, err := c.getActions(ctx, fmt.Sprintf("repos/%s/%s/actions/runs/%d/jobs", owner, repo, id), query, &page)
+		if err != nil {
+			return cache.Build{}, err
+		}
+		jobs = append(jobs, page.Jobs...)
+		if resp.NextPage == 0 {
+			break
+		}
+		query.Set("page", strconv.Itoa(resp.NextPage))
+	}
+
+	// Jobs of GitHub Actions are also check runs, whose annotations usually explain failures.
+	// Jobs that passed may still have annotations such as lint or compiler warnings.
+	annotations := make(map[int64][]cache.CodeAnnotation)
+	for _, job := range jobs {
+		if state := fromGitHubCheckRunState(job.Status, job.Conclusion); state.IsActive() || state == cache.Skipped {
+			continue
+		}
+		a, err := c.listCheckRunAnnotations(ctx, owner, repo, job.ID, run.HeadSha)
+		if err != nil {
+			return cache.Build{}, err
+		}
+		annotations[job.ID] = a
+	}
+
+	build := fromGitHubWorkflowRun(c.id, owner, repo, run, jobs, annotations)
+	setActionsStepCommands(&build, c.workflowCommands(ctx, owner, repo, run))
+
+	return build, nil
+}
+
+// Return the commands run by the steps of the workflow of 'run' as defined at the commit of the
+// run, indexed as by actionsStepCommands. No command is returned if the definition of the
+// workflow cannot be read.
+func (c GitHubClient) workflowCommands(ctx context.Context, owner string, repo string, run actionsWorkflowRun) map[string]map[string]string {
+	if run.Path == "" {
+		return nil
+	}
+	opt := github.RepositoryContentGetOptions{Ref: run.HeadSha}
+	file, _, _, err := c.client.Repositories.GetContents(ctx, owner, repo, run.Path, &opt)
+	if err != nil || file == nil {
+		return nil
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil
+	}
+	commands, err := actionsStepCommands([]byte(content))
+	if err != nil {
+		return nil
+	}
+	return commands
+}
+
+// Return the commands run by the steps of the jobs of the workflow definition 'workflow' indexed
+// by the name of the job and then by the name of the step, as named by GitHub. Steps running an
+// action instead of a command are left out.
+func actionsStepCommands(workflow []byte) (map[string]map[string]string, error) {
+	var definition struct {
+		Jobs map[string]struct {
+			Name  string `yaml:"name"`
+			Steps []struct {
+				Name string `yaml:"name"`
+				Run  string `yaml:"run"`
+			} `yaml:"steps"`
+		} `yaml:"jobs"`
+	}
+	if err := yaml.Unmarshal(workflow, &definition); err != nil {
+		return nil, err
+	}
+
+	commands := make(map[string]map[string]string, len(definition.Jobs))
+	for id, job := range definition.Jobs {
+		name := job.Name
+		if name == "" {
+			name = id
+		}
+		steps := make(map[string]string, len(job.Steps))
+		for _, step := range job.Steps {
+			command := strings.TrimSpace(step.Run)
+			if command == "" {
+				continue
+			}
+			stepName := step.Name
+			if stepName == "" {
+				// GitHub names steps after the first line of their command
+				stepName = "Run " + strings.SplitN(command, "\n", 2)[0]
+			}
+			steps[stepName] = command
+		}
+		commands[name] = steps
+	}
+
+	return commands, nil
+}
+
+// Suffix appended by GitHub to the name of the jobs run for each combination of a matrix
+var actionsMatrixSuffix = regexp.MustCompile(` \(.*\)$`)
+
+// Set the commands of the jobs of 'build', made from the steps of a workflow run, given the
+// commands of the workflow returned by actionsStepCommands
+func setActionsStepCommands(build *cache.Build, commands map[string]map[string]string) {
+	for _, stage := range build.Stages {
+		steps, exists := commands[stage.Name]
+		if !exists {
+			steps = commands[actionsMatrixSuffix.ReplaceAllString(stage.Name, "")]
+		}
+		for _, job := range stage.Jobs {
+			if command, exists := steps[job.Name]; exists {
+				job.Steps = []cache.Step{{Command: command}}
+			}
+		}
+	}
+}
+
+func nullTimeFromPointer(t *time.Time) utils.NullTime {
+	if t == nil {
+		return utils.NullTime{}
+	}
+	return utils.NullTime{Time: *t, Valid: true}
+}
+
+// Return the workflow run as a pipeline. Annotations of the jobs of the workflow on the code of
+// the repository are attached to the step that failed, or to the last step of jobs that passed.
+func fromGitHubWorkflowRun(providerID string, owner string, repo string, run actionsWorkflowRun, jobs []actionsJob, annotations map[int64][]cache.CodeAnnotation) cache.Build {
+	repository := cache.Repository{
+		Provider: cache.Provider{
+			ID:   providerID,
+			Name: "github",
+		},
+		URL:   fmt.Sprintf("https://github.com/%s/%s", owner, repo),
+		Owner: owner,
+		Name:  repo,
+	}
+
+	return fromActionsWorkflowRun(&repository, run, jobs, annotations)
+}
+
+// Return a workflow run of GitHub Actions, or of an implementation of the same API such as Gitea
+// Actions, as a pipeline of 'repository' whose stages are the jobs of the workflow and whose jobs
+// are the steps of these jobs
+func fromActionsWorkflowRun(repository *cache.Repository, run actionsWorkflowRun, jobs []actionsJob, annotations map[int64][]cache.CodeAnnotation) cache.Build {
+	build := cache.Build{
+		Repository:      repository,
+		ID:              strconv.FormatInt(run.ID, 10),
+		Name:            run.Name,
+		Commit:          cache.Commit{Sha: run.HeadSha},
+		Ref:             run.HeadBranch,
+		RepoBuildNumber: fmt.Sprintf("%s #%d", run.Name, run.RunNumber),
+		State:           fromGitHubCheckRunState(run.Status, run.Conclusion),
+		CreatedAt:       utils.NullTime{Time: run.CreatedAt, Valid: !run.CreatedAt.IsZero()},
+		UpdatedAt:       run.UpdatedAt,
+		WebURL:          run.HTMLURL,
+		Stages:          make(map[int]*cache.Stage, len(jobs)),
+	}
+
+	startedAt := make([]utils.NullTime, 0, len(jobs))
+	finishedAt := make([]utils.NullTime, 0, len(jobs))
+	for i, job := range jobs {
+		stage := cache.Stage{
+			ID:    i + 1,
+			Name:  job.Name,
+			State: fromGitHubCheckRunState(job.Status, job.Conclusion),
+			Jobs:  make([]*cache.Job, 0, len(job.Steps)),
+		}
+		startedAt = append(startedAt, nullTimeFromPointer(job.StartedAt))
+		finishedAt = append(finishedAt, nullTimeFromPointer(job.CompletedAt))
+
+		var failedStep *cache.Job
+		for _, step := range job.Steps {
+			j := cache.Job{
+				ID:         fmt.Sprintf("%s%d/%d", actionsStepJobPrefix, job.ID, step.Number),
+				State:      fromGitHubCheckRunState(step.Status, step.Conclusion),
+				Name:       step.Name,
+				CreatedAt:  nullTimeFromPointer(step.StartedAt),
+				StartedAt:  nullTimeFromPointer(step.StartedAt),
+				FinishedAt: nullTimeFromPointer(step.CompletedAt),
+				WebURL:     job.HTMLURL,
+			}
+			switch {
+			case stage.State == cache.Canceled && j.State.IsActive():
+				j.State = cache.Canceled
+			case j.State == cache.Pending && !stage.State.IsActive():
+				j.State = cache.Skipped
+			}
+			j.Duration = utils.NullSub(j.FinishedAt, j.StartedAt)
+			if j.State == cache.Failed && failedStep == nil {
+				failedStep = &j
+			}
+			stage.Jobs = append(stage.Jobs, &j)
+		}
+		if len(annotations[job.ID]) > 0 && len(stage.Jobs) > 0 {
+			if failedStep == nil {
+				failedStep = stage.Jobs[len(stage.Jobs)-1]
+			}
+			failedStep.Annotations = annotations[job.ID]
+		}
+
+		build.Stages[stage.ID] = &stage
+	}
+
+	build.StartedAt = utils.MinNullTime(startedAt...)
+	if !build.StartedAt.Valid {
+		build.StartedAt = build.CreatedAt
+	}
+	if !build.State.IsActive() {
+		build.FinishedAt = utils.MaxNullTime(finishedAt...)
+		if !build.FinishedAt.Valid {
+			build.FinishedAt = utils.NullTime{Time: run.UpdatedAt, Valid: !run.UpdatedAt.IsZero()}
+		}
+	}
+	build.Duration = utils.NullSub(build.FinishedAt, build.StartedAt)
+
+	return build
+}
+
+// Return the job of GitHub Actions and the number of the step designated by the identifier of
+// a job made from a step
+func parseActionsStepJobID(jobID string) (int64, int, error) {
+	cs := strings.Split(strings.TrimPrefix(jobID, actionsStepJobPrefix), "/")
+	if len(cs) != 2 {
+		return 0, 0, fmt.Errorf("invalid job identifier %q", jobID)
+	}
+	id, err := strconv.ParseInt(cs[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid job identifier %q", jobID)
+	}
+	number, err := strconv.Atoi(cs[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid job identifier %q", jobID)
+	}
+	return id, number, nil
+}
+
+// Return the part of the log of a job of GitHub Actions written by step 'number'
+func (c GitHubClient) actionsStepLog(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	id, number, err := parseActionsStepJobID(jobID)
+	if err != nil {
+		return "", err
+	}
+
+	var job actionsJob
+	path := fmt.Sprintf("repos/%s/%s/actions/jobs/%d", repository.Owner, repository.Name, id)
+	if _, err := c.getActions(ctx, path, nil, &job); err != nil {
+		return "", err
+	}
+	// The API redirects to the location of the log
+	log := bytes.Buffer{}
+	if _, err := c.getActions(ctx, path+"/logs", nil, &log); err != nil {
+		return "", err
+	}
+
+	return stepLog(log.String(), job.Steps, number), nil
+}
+
+// Return the lines of 'log' written during the step 'number' of 'steps'. Each line of the log of
+// a job starts with a timestamp, whereas the times of steps are truncated to the second, so
+// lines are attributed to the last step started before them.
+func stepLog(log string, steps []actionsStep, number int) string {
+	steps = append([]actionsStep(nil), steps...)
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].Number < steps[j].Number
+	})
+	if len(steps) == 0 {
+		return ""
+	}
+
+	b := strings.Builder{}
+	current := steps[0].Number
+	for _, line := range strings.SplitAfter(log, "\n") {
+		if line == "" {
+			continue
+		}
+		if i := strings.Index(line, " "); i > 0 {
+			if t, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+				line = line[i+1:]
+				for _, step := range steps {
+					if step.StartedAt != nil && !step.StartedAt.After(t.Truncate(time.Second)) {
+						current = step.Number
+					}
+				}
+			}
+		}
+		if current == number {
+			b.WriteString(line)
+		}
+	}
+
+	return b.String()
+}
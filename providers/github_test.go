@@ -7,20 +7,54 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-github/v28/github"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
 )
 
-func TestClient(t *testing.T) {
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// Return a server replying to the requests of the GitHub API for the statuses, check runs,
+// deployments, pull requests and workflow runs of a commit and for a check run of GitHub Actions
+func newGitHubTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		filename := ""
 		switch r.URL.Path {
 		case "/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07/check-runs":
 			filename = "github_check_runs.json"
 		case "/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07/statuses":
 			filename = "github_statuses.json"
+		case "/repos/nbedos/termtosvg/deployments":
+			filename = "github_deployments.json"
+		case "/repos/nbedos/termtosvg/deployments/182338585":
+			filename = "github_deployment.json"
+		case "/repos/nbedos/termtosvg/deployments/182338585/statuses":
+			filename = "github_deployment_statuses.json"
+		case "/repos/nbedos/termtosvg/check-runs/352137581":
+			filename = "github_check_run.json"
+		case "/repos/nbedos/termtosvg/check-runs/352137581/annotations":
+			filename = "github_check_run_annotations.json"
+		case "/repos/nbedos/termtosvg/check-runs/352137580/annotations":
+			filename = "github_check_run_annotations_lint.json"
+		case "/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07/pulls":
+			filename = "github_commit_pulls.json"
+		case "/repos/nbedos/termtosvg/pulls/12/reviews":
+			filename = "github_pull_reviews.json"
+		case "/repos/nbedos/termtosvg/actions/runs":
+			filename = "github_workflow_runs.json"
+		case "/repos/nbedos/termtosvg/actions/runs/39455623":
+			filename = "github_workflow_run.json"
+		case "/repos/nbedos/termtosvg/actions/runs/39455623/jobs":
+			filename = "github_workflow_jobs.json"
+		case "/repos/nbedos/termtosvg/contents/.github/workflows/ci.yml":
+			filename = "github_workflow_ci.json"
+		case "/repos/nbedos/termtosvg/actions/jobs/352137581":
+			filename = "github_actions_job.json"
+		case "/repos/nbedos/termtosvg/actions/jobs/352137581/logs":
+			filename = "github_actions_job_log.txt"
 		default:
 			w.WriteHeader(404)
 			return
@@ -38,6 +72,10 @@ func TestClient(t *testing.T) {
 			return
 		}
 	}))
+}
+
+func TestClient(t *testing.T) {
+	ts := newGitHubTestServer()
 	defer ts.Close()
 
 	c, err := github.NewEnterpriseClient(ts.URL, ts.URL, ts.Client())
@@ -61,6 +99,8 @@ func TestClient(t *testing.T) {
 		"https://travis-ci.com/owner/repository/builds/123654789",
 		"https://travis-ci.org/nbedos/citop/builds/615087280",
 		"https://gitlab.com/nbedos/citop/pipelines/97604657",
+		"https://api.github.com/repos/nbedos/termtosvg/deployments/182338585",
+		"https://github.com/nbedos/termtosvg/actions/runs/39455623",
 	}
 
 	sort.Strings(urls)
@@ -69,3 +109,446 @@ func TestClient(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestGitHubClient_PullRequest(t *testing.T) {
+	ts := newGitHubTestServer()
+	defer ts.Close()
+
+	c, err := github.NewEnterpriseClient(ts.URL, ts.URL, ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := GitHubClient{
+		client: c,
+	}
+	ctx := context.Background()
+
+	t.Run("open pull request", func(t *testing.T) {
+		pr, err := client.PullRequest(ctx, ts.URL+"/nbedos/termtosvg", "d58600a58bf1738c6529ce3489a546bfa2178e07")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := utils.PullRequest{
+			Number: 12,
+			Title:  "Add the feature",
+			State:  "open",
+			Review: "approved",
+			WebURL: "https://github.com/nbedos/termtosvg/pull/12",
+		}
+		if diff := cmp.Diff(expected, pr); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("unknown repository", func(t *testing.T) {
+		_, err := client.PullRequest(ctx, "https://gitlab.com/nbedos/termtosvg", "d58600a58bf1738c6529ce3489a546bfa2178e07")
+		if err != cache.ErrUnknownURL {
+			t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+		}
+	})
+}
+
+func TestGitHubClient_WithStatusPolicy(t *testing.T) {
+	ts := newGitHubTestServer()
+	defer ts.Close()
+
+	c, err := github.NewEnterpriseClient(ts.URL, ts.URL, ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := GitHubClient{client: c}.WithStatusPolicy(StatusPolicy{
+		HiddenContexts: []string{"continuous-integration/travis-ci/*", "ci/circleci:*"},
+	})
+	urls, err := client.BuildURLs(context.Background(), "nbedos", "termtosvg", "d58600a58bf1738c6529ce3489a546bfa2178e07")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedURLs := []string{
+		"https://api.github.com/repos/nbedos/termtosvg/deployments/182338585",
+		"https://ci.appveyor.com/project/nbedos/citop/builds/29024796",
+		"https://github.com/nbedos/termtosvg/actions/runs/39455623",
+		"https://gitlab.com/nbedos/citop/pipelines/97604657",
+		"https://travis-ci.com/owner/repository/builds/123654789",
+	}
+	sort.Strings(urls)
+	if diff := cmp.Diff(expectedURLs, urls); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestGitHubClient_BuildFromURL(t *testing.T) {
+	ts := newGitHubTestServer()
+	defer ts.Close()
+
+	c, err := github.NewEnterpriseClient(ts.URL, ts.URL, ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := GitHubClient{id: "github", client: c}
+
+	t.Run("Unknown URL", func(t *testing.T) {
+		urls := []string{
+			"https://gitlab.com/nbedos/citop/pipelines/97604657",
+			ts.URL + "/repos/nbedos/termtosvg/statuses/182338585",
+			ts.URL + "/repos/nbedos/termtosvg/deployments/abc",
+			ts.URL + "/nbedos/termtosvg/runs/abc",
+			ts.URL + "/nbedos/termtosvg/actions/runs/abc",
+			"https://travis-ci.com/nbedos/termtosvg/runs/352137581",
+		}
+		for _, u := range urls {
+			if _, err := client.BuildFromURL(context.Background(), u); err != cache.ErrUnknownURL {
+				t.Fatalf("expected %v but got %v for URL %q", cache.ErrUnknownURL, err, u)
+			}
+		}
+	})
+
+	t.Run("Deployment", func(t *testing.T) {
+		build, err := client.BuildFromURL(context.Background(), ts.URL+"/repos/nbedos/termtosvg/deployments/182338585")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		createdAt := utils.NullTime{Time: time.Date(2019, 11, 21, 14, 44, 2, 0, time.UTC), Valid: true}
+		finishedAt := utils.NullTime{Time: time.Date(2019, 11, 21, 14, 45, 12, 0, time.UTC), Valid: true}
+		duration := utils.NullDuration{Duration: 70 * time.Second, Valid: true}
+		expected := cache.Build{
+			Repository: &cache.Repository{
+				Provider: cache.Provider{ID: "github", Name: "github"},
+				URL:      "https://github.com/nbedos/termtosvg",
+				Owner:    "nbedos",
+				Name:     "termtosvg",
+			},
+			ID:         "182338585",
+			Commit:     cache.Commit{Sha: "d58600a58bf1738c6529ce3489a546bfa2178e07"},
+			Ref:        "master",
+			State:      cache.Passed,
+			CreatedAt:  createdAt,
+			StartedAt:  createdAt,
+			FinishedAt: finishedAt,
+			UpdatedAt:  finishedAt.Time,
+			Duration:   duration,
+			WebURL:     "https://nbedos.github.io/termtosvg/",
+			Stages:     map[int]*cache.Stage{},
+			Jobs: []*cache.Job{
+				{
+					ID:         "182338585",
+					State:      cache.Passed,
+					Name:       "github-pages",
+					CreatedAt:  createdAt,
+					StartedAt:  createdAt,
+					FinishedAt: finishedAt,
+					Duration:   duration,
+					WebURL:     "https://github.com/nbedos/termtosvg/actions/runs/3456",
+				},
+			},
+		}
+		if diff := cmp.Diff(expected, build); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Check run", func(t *testing.T) {
+		webURL := ts.URL + "/nbedos/termtosvg/runs/352137581"
+		build, err := client.BuildFromURL(context.Background(), webURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		startedAt := utils.NullTime{Time: time.Date(2019, 12, 19, 10, 12, 31, 0, time.UTC), Valid: true}
+		finishedAt := utils.NullTime{Time: time.Date(2019, 12, 19, 10, 14, 1, 0, time.UTC), Valid: true}
+		duration := utils.NullDuration{Duration: 90 * time.Second, Valid: true}
+		expected := cache.Build{
+			Repository: &cache.Repository{
+				Provider: cache.Provider{ID: "github", Name: "github"},
+				URL:      "https://github.com/nbedos/termtosvg",
+				Owner:    "nbedos",
+				Name:     "termtosvg",
+			},
+			ID:         "352137581",
+			Name:       "build (3.8)",
+			Commit:     cache.Commit{Sha: "d58600a58bf1738c6529ce3489a546bfa2178e07"},
+			Ref:        "master",
+			State:      cache.Failed,
+			CreatedAt:  startedAt,
+			StartedAt:  startedAt,
+			FinishedAt: finishedAt,
+			UpdatedAt:  finishedAt.Time,
+			Duration:   duration,
+			WebURL:     "https://github.com/nbedos/termtosvg/runs/352137581",
+			Stages:     map[int]*cache.Stage{},
+			Jobs: []*cache.Job{
+				{
+					ID:         "run-352137581",
+					State:      cache.Failed,
+					Name:       "build (3.8)",
+					CreatedAt:  startedAt,
+					StartedAt:  startedAt,
+					FinishedAt: finishedAt,
+					Duration:   duration,
+					WebURL:     "https://github.com/nbedos/termtosvg/runs/352137581",
+					Annotations: []cache.CodeAnnotation{
+						{
+							Level:     "failure",
+							Path:      "tests/test_anim.py",
+							StartLine: 42,
+							EndLine:   42,
+							Title:     "test_render",
+							Message:   "AssertionError: assert 3 == 4\n  where 3 = len(frames)",
+							WebURL:    "https://127.0.0.1/nbedos/termtosvg/blob/d58600a58bf1738c6529ce3489a546bfa2178e07/tests/test_anim.py#L42",
+						},
+						{
+							Level:     "warning",
+							Path:      "termtosvg/term.py",
+							StartLine: 10,
+							EndLine:   12,
+							Message:   "Function 'record' is deprecated",
+							WebURL:    "https://127.0.0.1/nbedos/termtosvg/blob/d58600a58bf1738c6529ce3489a546bfa2178e07/termtosvg/term.py#L10-L12",
+						},
+					},
+				},
+			},
+			Annotations: []cache.Annotation{
+				{
+					Title: "Tests failed",
+					Style: "error",
+					Body:  "2 tests out of 120 failed\n\n- `test_anim.py::test_render`\n- `test_term.py::test_record`",
+				},
+			},
+		}
+		if diff := cmp.Diff(expected, build); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+
+		log, err := client.Log(context.Background(), *build.Repository, "run-352137581")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedLog := "Tests failed\n\n2 tests out of 120 failed\n\n- `test_anim.py::test_render`\n- `test_term.py::test_record`\n"
+		if diff := cmp.Diff(expectedLog, log); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Workflow run", func(t *testing.T) {
+		webURL := ts.URL + "/nbedos/termtosvg/actions/runs/39455623"
+		build, err := client.BuildFromURL(context.Background(), webURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		at := func(hour int, min int, sec int) utils.NullTime {
+			return utils.NullTime{Time: time.Date(2019, 12, 19, hour, min, sec, 0, time.UTC), Valid: true}
+		}
+		step := func(jobID int64, number int, state cache.State, name string, startedAt utils.NullTime, finishedAt utils.NullTime) *cache.Job {
+			return &cache.Job{
+				ID:         fmt.Sprintf("actions-%d/%d", jobID, number),
+				State:      state,
+				Name:       name,
+				CreatedAt:  startedAt,
+				StartedAt:  startedAt,
+				FinishedAt: finishedAt,
+				Duration:   utils.NullSub(finishedAt, startedAt),
+				WebURL:     fmt.Sprintf("https://github.com/nbedos/termtosvg/runs/%d", jobID),
+			}
+		}
+		failedStep := step(352137581, 2, cache.Failed, "Run tests", at(10, 12, 33), at(10, 14, 0))
+		failedStep.Steps = []cache.Step{{Command: "pip install -e .\npytest"}}
+		failedStep.Annotations = []cache.CodeAnnotation{
+			{
+				Level:     "failure",
+				Path:      "tests/test_anim.py",
+				StartLine: 42,
+				EndLine:   42,
+				Title:     "test_render",
+				Message:   "AssertionError: assert 3 == 4\n  where 3 = len(frames)",
+				WebURL:    "https://127.0.0.1/nbedos/termtosvg/blob/d58600a58bf1738c6529ce3489a546bfa2178e07/tests/test_anim.py#L42",
+			},
+			{
+				Level:     "warning",
+				Path:      "termtosvg/term.py",
+				StartLine: 10,
+				EndLine:   12,
+				Message:   "Function 'record' is deprecated",
+				WebURL:    "https://127.0.0.1/nbedos/termtosvg/blob/d58600a58bf1738c6529ce3489a546bfa2178e07/termtosvg/term.py#L10-L12",
+			},
+		}
+		// Warnings of jobs that passed are attached to their last step
+		lintStep := step(352137580, 2, cache.Passed, "Run flake8", at(10, 12, 27), at(10, 12, 55))
+		lintStep.Steps = []cache.Step{{Command: "flake8"}}
+		lintStep.Annotations = []cache.CodeAnnotation{
+			{
+				Level:     "warning",
+				Path:      "termtosvg/anim.py",
+				StartLine: 7,
+				EndLine:   7,
+				Title:     "F401",
+				Message:   "'typing.Iterator' imported but unused",
+				WebURL:    "https://127.0.0.1/nbedos/termtosvg/blob/d58600a58bf1738c6529ce3489a546bfa2178e07/termtosvg/anim.py#L7",
+			},
+		}
+
+		expected := cache.Build{
+			Repository: &cache.Repository{
+				Provider: cache.Provider{ID: "github", Name: "github"},
+				URL:      "https://github.com/nbedos/termtosvg",
+				Owner:    "nbedos",
+				Name:     "termtosvg",
+			},
+			ID:              "39455623",
+			Name:            "CI",
+			Commit:          cache.Commit{Sha: "d58600a58bf1738c6529ce3489a546bfa2178e07"},
+			Ref:             "master",
+			RepoBuildNumber: "CI #42",
+			State:           cache.Failed,
+			CreatedAt:       at(10, 12, 20),
+			StartedAt:       at(10, 12, 25),
+			FinishedAt:      at(10, 14, 1),
+			UpdatedAt:       at(10, 14, 5).Time,
+			Duration:        utils.NullDuration{Duration: 96 * time.Second, Valid: true},
+			WebURL:          "https://github.com/nbedos/termtosvg/actions/runs/39455623",
+			Stages: map[int]*cache.Stage{
+				1: {
+					ID:    1,
+					Name:  "lint",
+					State: cache.Passed,
+					Jobs: []*cache.Job{
+						step(352137580, 1, cache.Passed, "Set up job", at(10, 12, 25), at(10, 12, 27)),
+						lintStep,
+					},
+				},
+				2: {
+					ID:    2,
+					Name:  "build (3.8)",
+					State: cache.Failed,
+					Jobs: []*cache.Job{
+						step(352137581, 1, cache.Passed, "Set up job", at(10, 12, 31), at(10, 12, 33)),
+						failedStep,
+						step(352137581, 3, cache.Skipped, "Upload coverage", at(10, 14, 0), at(10, 14, 0)),
+						step(352137581, 4, cache.Passed, "Complete job", at(10, 14, 0), at(10, 14, 1)),
+					},
+				},
+			},
+		}
+		if diff := cmp.Diff(expected, build); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+
+		log, err := client.Log(context.Background(), *build.Repository, "actions-352137581/2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedLog := "##[group]Run python -m pytest\n" +
+			"python -m pytest\n" +
+			"FAILED tests/test_anim.py::test_render\n" +
+			"##[error]Process completed with exit code 1.\n"
+		if diff := cmp.Diff(expectedLog, log); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Log", func(t *testing.T) {
+		repository := cache.Repository{Owner: "nbedos", Name: "termtosvg"}
+		log, err := client.Log(context.Background(), repository, "182338585")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "2019-11-21T14:44:05Z in_progress (https://github.com/nbedos/termtosvg/actions/runs/3456)\n" +
+			"2019-11-21T14:45:12Z success: Deployment finished (https://github.com/nbedos/termtosvg/actions/runs/3456)\n"
+		if diff := cmp.Diff(expected, log); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestGitHubClient_TriggerPipeline(t *testing.T) {
+	dispatched := make([]string, 0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/repos/nbedos/termtosvg/actions/workflows"):
+			fmt.Fprint(w, `{"workflows": [
+				{"id": 1, "path": ".github/workflows/tests.yml", "state": "active"},
+				{"id": 2, "path": ".github/workflows/release.yml", "state": "active"},
+				{"id": 3, "path": ".github/workflows/old.yml", "state": "disabled_manually"}
+			]}`)
+		case strings.HasSuffix(r.URL.Path, "/repos/nbedos/termtosvg/contents/.github/workflows/tests.yml"):
+			fmt.Fprint(w, `{"type": "file", "encoding": "base64", "content": "b246IHB1c2gK"}`)
+		case strings.HasSuffix(r.URL.Path, "/repos/nbedos/termtosvg/contents/.github/workflows/release.yml"):
+			fmt.Fprint(w, `{"type": "file", "encoding": "base64", "content": "b246IHdvcmtmbG93X2Rpc3BhdGNoCg=="}`)
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/repos/nbedos/termtosvg/actions/workflows/2/dispatches"):
+			bs, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(500)
+				return
+			}
+			dispatched = append(dispatched, strings.TrimSpace(string(bs)))
+			w.WriteHeader(204)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := github.NewEnterpriseClient(ts.URL, ts.URL, ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := GitHubClient{
+		client: c,
+	}
+
+	variables := []cache.Variable{{Name: "version", Value: "1.0"}}
+	u, err := client.TriggerPipeline(context.Background(), ts.URL+"/nbedos/termtosvg", "master", variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "https://127.0.0.1/nbedos/termtosvg/actions"; u != expected {
+		t.Fatalf("expected %q but got %q", expected, u)
+	}
+	expected := []string{`{"ref":"master","inputs":{"version":"1.0"}}`}
+	if diff := cmp.Diff(expected, dispatched); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	if _, err := client.TriggerPipeline(context.Background(), ts.URL+"/nbedos/unknown", "master", nil); err != cache.ErrRepositoryNotFound {
+		t.Fatalf("expected %v but got %v", cache.ErrRepositoryNotFound, err)
+	}
+}
+
+func TestFromGitHubDeploymentState(t *testing.T) {
+	states := map[string]cache.State{
+		"":            cache.Pending,
+		"queued":      cache.Pending,
+		"pending":     cache.Pending,
+		"in_progress": cache.Running,
+		"success":     cache.Passed,
+		"failure":     cache.Failed,
+		"error":       cache.Failed,
+		"inactive":    cache.Canceled,
+		"unknown":     cache.Unknown,
+	}
+	for s, expected := range states {
+		if state := fromGitHubDeploymentState(s); state != expected {
+			t.Errorf("expected state %q for %q but got %q", expected, s, state)
+		}
+	}
+}
+
+func TestWithoutHostsOf(t *testing.T) {
+	statuses := []string{
+		"https://travis-ci.com/owner/repository/builds/1?utm_source=github_status",
+		"https://ci.appveyor.com/project/owner/repository/builds/2",
+	}
+	checks := []string{
+		"https://www.travis-ci.com/owner/repository/builds/1",
+	}
+
+	expected := []string{"https://ci.appveyor.com/project/owner/repository/builds/2"}
+	if diff := cmp.Diff(expected, withoutHostsOf(statuses, checks)); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff([]string{}, withoutHostsOf(checks, statuses)); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
@@ -1,10 +1,15 @@
 package providers
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -13,6 +18,7 @@ import (
 	"github.com/nbedos/citop/cache"
 	"github.com/nbedos/citop/utils"
 	"github.com/xanzy/go-gitlab"
+	"gopkg.in/yaml.v2"
 )
 
 type GitLabClient struct {
@@ -21,15 +27,17 @@ type GitLabClient struct {
 	rateLimiter          <-chan time.Time
 	updateTimePerBuildID map[string]time.Time
 	mux                  *sync.Mutex
+	owners               OwnerPatterns
+	repositoryRestriction
 }
 
-func NewGitLabClient(id string, name string, token string, rateLimit time.Duration) GitLabClient {
+func NewGitLabClient(id string, name string, token string, rateLimit time.Duration, options ...ClientOption) GitLabClient {
 	return GitLabClient{
 		provider: cache.Provider{
 			ID:   id,
 			Name: name,
 		},
-		remote:               gitlab.NewClient(nil, token),
+		remote:               gitlab.NewClient(newHTTPClient(0, options), token),
 		rateLimiter:          time.Tick(rateLimit),
 		updateTimePerBuildID: make(map[string]time.Time),
 		mux:                  &sync.Mutex{},
@@ -55,6 +63,23 @@ func (c GitLabClient) Commit(ctx context.Context, repo string, sha string) (util
 		Message: gitlabCommit.Message,
 	}
 
+	// The list of changed files is not essential either, errors leave it unknown
+	if files, err := c.changedFiles(ctx, slug, commit.Sha); err == nil {
+		commit.Files = files
+	}
+
+	// GitLab answers with 404 for commits without signature. Other errors are ignored, leaving
+	// the signature unknown, since the signature is not essential.
+	signature, resp, err := c.remote.Commits.GetGPGSiganature(slug, commit.Sha, gitlab.WithContext(ctx))
+	switch {
+	case err == nil && signature.VerificationStatus == "verified":
+		commit.Signature = "verified"
+	case err == nil:
+		commit.Signature = "unverified: " + strings.Replace(signature.VerificationStatus, "_", " ", -1)
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+		commit.Signature = "unsigned"
+	}
+
 	opt := gitlab.GetCommitRefsOptions{}
 	for {
 		refs, resp, err := c.remote.Commits.GetCommitRefs(slug, commit.Sha, &opt)
@@ -80,6 +105,85 @@ func (c GitLabClient) Commit(ctx context.Context, repo string, sha string) (util
 	return commit, nil
 }
 
+// PullRequest returns the merge request containing the commit 'sha' along with the state of its
+// approvals. Open merge requests are preferred over closed ones.
+func (c GitLabClient) PullRequest(ctx context.Context, repo string, sha string) (utils.PullRequest, error) {
+	host, owner, repo, err := utils.RepoHostOwnerAndName(repo)
+	if err != nil || !strings.Contains(host, c.remote.BaseURL().Hostname()) {
+		return utils.PullRequest{}, cache.ErrUnknownURL
+	}
+
+	slug := fmt.Sprintf("%s/%s", owner, repo)
+	mergeRequests, _, err := c.remote.Commits.GetMergeRequestsByCommit(slug, sha, gitlab.WithContext(ctx))
+	if err != nil {
+		return utils.PullRequest{}, err
+	}
+	if len(mergeRequests) == 0 {
+		return utils.PullRequest{}, cache.ErrNoPullRequest
+	}
+	mergeRequest := mergeRequests[0]
+	for _, m := range mergeRequests {
+		if m.State == "opened" {
+			mergeRequest = m
+			break
+		}
+	}
+
+	pr := utils.PullRequest{
+		Number: mergeRequest.IID,
+		Title:  mergeRequest.Title,
+		State:  mergeRequest.State,
+		WebURL: mergeRequest.WebURL,
+	}
+	if pr.State == "opened" || pr.State == "locked" {
+		pr.State = "open"
+	}
+
+	// Approvals are not essential, errors leave the outcome of reviews unknown
+	approvals, _, err := c.remote.MergeRequests.GetMergeRequestApprovals(slug, mergeRequest.IID, gitlab.WithContext(ctx))
+	if err == nil {
+		switch {
+		case approvals.ApprovalsLeft > 0 || (approvals.ApprovalsRequired == 0 && len(approvals.ApprovedBy) == 0):
+			pr.Review = "review required"
+		default:
+			pr.Review = "approved"
+		}
+	}
+
+	return pr, nil
+}
+
+// Return the files changed by the commit 'sha' of the project 'slug'
+func (c GitLabClient) changedFiles(ctx context.Context, slug string, sha string) ([]utils.ChangedFile, error) {
+	files := make([]utils.ChangedFile, 0)
+	opt := gitlab.GetCommitDiffOptions{}
+	for {
+		diffs, resp, err := c.remote.Commits.GetCommitDiff(slug, sha, &opt, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, diff := range diffs {
+			file := utils.ChangedFile{Status: "modified", Path: diff.NewPath}
+			switch {
+			case diff.NewFile:
+				file.Status = "added"
+			case diff.DeletedFile:
+				file.Status = "deleted"
+			case diff.RenamedFile:
+				file.Status = "renamed"
+				file.PreviousPath = diff.OldPath
+			}
+			files = append(files, file)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return files, nil
+}
+
 func (c GitLabClient) buildURLsPipelines(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
 	options := gitlab.ListProjectPipelinesOptions{
 		SHA: &sha,
@@ -172,10 +276,48 @@ func (c GitLabClient) BuildURLs(ctx context.Context, owner string, repo string,
 	return urls, nil
 }
 
+// WithOwners returns a copy of the client only used for the repositories of the owners matching
+// 'owners'. The owner of a project is its top-level group or user.
+func (c GitLabClient) WithOwners(owners OwnerPatterns) GitLabClient {
+	c.owners = owners
+	return c
+}
+
+// MatchesOwner returns true if the client is used for the repositories of 'owner'
+func (c GitLabClient) MatchesOwner(owner string) bool {
+	return c.owners.Match(owner)
+}
+
 func (c GitLabClient) ID() string {
 	return c.provider.ID
 }
 
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c GitLabClient) WithRepositories(repositories RepositoryPatterns) GitLabClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// CheckAuthentication returns an error if GitLab rejects the API token
+func (c GitLabClient) CheckAuthentication(ctx context.Context) error {
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	_, _, err := c.remote.Users.CurrentUser(gitlab.WithContext(ctx))
+	return err
+}
+
+// ErrorStatusCode returns the status of the HTTP response that caused 'err'
+func (c GitLabClient) ErrorStatusCode(err error) (int, bool) {
+	if err, ok := err.(*gitlab.ErrorResponse); ok && err.Response != nil {
+		return err.Response.StatusCode, true
+	}
+	return 0, false
+}
+
 func (c GitLabClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
 	owner, repo, id, err := parseGitlabWebURL(c.remote.BaseURL(), u)
 	if err != nil {
@@ -190,6 +332,47 @@ func (c GitLabClient) BuildFromURL(ctx context.Context, u string) (cache.Build,
 	return c.fetchBuild(ctx, &repository, id)
 }
 
+// PreviousBuilds returns at most 'limit' pipelines that passed on the ref of 'build' before it,
+// most recent first
+func (c GitLabClient) PreviousBuilds(ctx context.Context, build cache.Build, limit int) ([]cache.Build, error) {
+	id, err := strconv.Atoi(build.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	orderBy, sort := "id", "desc"
+	options := gitlab.ListProjectPipelinesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: limit + 1},
+		Status:      gitlab.BuildState(gitlab.Success),
+		Ref:         &build.Ref,
+		OrderBy:     &orderBy,
+		Sort:        &sort,
+	}
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	pipelines, _, err := c.remote.Pipelines.ListProjectPipelines(build.Repository.ID, &options, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	builds := make([]cache.Build, 0, limit)
+	for _, pipeline := range pipelines {
+		if pipeline.ID >= id || len(builds) >= limit {
+			continue
+		}
+		previous, err := c.fetchBuild(ctx, build.Repository, pipeline.ID)
+		if err != nil {
+			return nil, err
+		}
+		builds = append(builds, previous)
+	}
+
+	return builds, nil
+}
+
 // Extract owner, repository and build ID from web URL of build
 func parseGitlabWebURL(baseURL *url.URL, u string) (string, string, int, error) {
 	v, err := url.Parse(u)
@@ -373,6 +556,11 @@ func (c GitLabClient) fetchBuild(ctx context.Context, repository *cache.Reposito
 		options.Page = resp.NextPage
 	}
 
+	steps, err := c.jobSteps(ctx, repository.ID, pipeline.SHA)
+	if err != nil {
+		return build, err
+	}
+
 	stagesByName := make(map[string]*cache.Stage)
 	build.Stages = make(map[int]*cache.Stage)
 	for _, job := range jobs {
@@ -402,6 +590,7 @@ func (c GitLabClient) fetchBuild(ctx context.Context, repository *cache.Reposito
 			},
 			WebURL:       gitlabJob.WebURL,
 			AllowFailure: gitlabJob.AllowFailure,
+			Steps:        steps[gitlabJobName(gitlabJob.Name)],
 		}
 		stagesByName[gitlabJob.Stage].Jobs = append(stagesByName[gitlabJob.Stage].Jobs, &job)
 	}
@@ -425,8 +614,520 @@ func (c GitLabClient) fetchBuild(ctx context.Context, repository *cache.Reposito
 		stage.State = cache.AggregateStatuses(jobs)
 	}
 
+	deployments, environments, err := c.fetchDeployments(ctx, repository.ID, *pipeline)
+	if err != nil {
+		return build, err
+	}
+	build.Deployments = fromGitLabDeployments(deployments, environments)
+
 	c.mux.Lock()
 	c.updateTimePerBuildID[build.ID] = build.UpdatedAt
 	c.mux.Unlock()
 	return build, nil
 }
+
+// Return the commands of the jobs of a pipeline indexed by job name, as defined by the file
+// .gitlab-ci.yml of the commit 'sha'. No command is returned if the file is missing or invalid.
+func (c GitLabClient) jobSteps(ctx context.Context, repositoryID int, sha string) (map[string][]cache.Step, error) {
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	options := gitlab.GetRawFileOptions{Ref: &sha}
+	config, _, err := c.remote.RepositoryFiles.GetRawFile(repositoryID, ".gitlab-ci.yml", &options, gitlab.WithContext(ctx))
+	if err != nil {
+		// The configuration may come from another file or project
+		return nil, ctx.Err()
+	}
+	steps, err := gitlabJobSteps(config)
+	if err != nil {
+		return nil, nil
+	}
+	return steps, nil
+}
+
+// Keys of the configuration of a GitLab CI job listing commands, in the order they are executed
+var gitlabScriptKeys = []string{"before_script", "script", "after_script"}
+
+// Maximum number of levels of job templates followed through the keyword 'extends'
+const gitlabMaxExtendsDepth = 11
+
+// Return the commands of the jobs defined by the GitLab CI configuration 'config' indexed by job
+// name. Job templates are resolved through the keyword 'extends' but included files are not
+// fetched.
+func gitlabJobSteps(config []byte) (map[string][]cache.Step, error) {
+	entries := make(map[string]interface{})
+	if err := yaml.Unmarshal(config, &entries); err != nil {
+		return nil, err
+	}
+
+	// Commands run before and after each job unless the job overrides them
+	defaults := make(map[interface{}]interface{})
+	for _, key := range gitlabScriptKeys {
+		if value, exists := entries[key]; exists {
+			defaults[key] = value
+		}
+	}
+	if values, ok := entries["default"].(map[interface{}]interface{}); ok {
+		for key, value := range values {
+			defaults[key] = value
+		}
+	}
+
+	steps := make(map[string][]cache.Step)
+	for name := range entries {
+		if strings.HasPrefix(name, ".") {
+			// Hidden job used as a template
+			continue
+		}
+		job, ok := entries[name].(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		values := gitlabJobValues(entries, job, 0)
+		if _, exists := values["script"]; !exists {
+			// Global keyword or trigger job
+			continue
+		}
+		jobSteps := make([]cache.Step, 0)
+		for _, key := range gitlabScriptKeys {
+			value, exists := values[key]
+			if !exists && key != "script" {
+				value = defaults[key]
+			}
+			for _, command := range gitlabCommands(value, nil) {
+				jobSteps = append(jobSteps, cache.Step{Name: key, Command: command})
+			}
+		}
+		steps[name] = jobSteps
+	}
+
+	return steps, nil
+}
+
+// Return the keys of 'job' merged with those of the templates it extends, the keys of 'job'
+// taking precedence
+func gitlabJobValues(entries map[string]interface{}, job map[interface{}]interface{}, depth int) map[interface{}]interface{} {
+	values := make(map[interface{}]interface{})
+	if depth < gitlabMaxExtendsDepth {
+		var parents []interface{}
+		switch extends := job["extends"].(type) {
+		case string:
+			parents = []interface{}{extends}
+		case []interface{}:
+			parents = extends
+		}
+		for _, parent := range parents {
+			name, ok := parent.(string)
+			if !ok {
+				continue
+			}
+			if template, ok := entries[name].(map[interface{}]interface{}); ok {
+				for key, value := range gitlabJobValues(entries, template, depth+1) {
+					values[key] = value
+				}
+			}
+		}
+	}
+	for key, value := range job {
+		values[key] = value
+	}
+	return values
+}
+
+// Append to 'commands' the commands listed by 'value', a single command or a list of commands
+// possibly nested by YAML anchors
+func gitlabCommands(value interface{}, commands []string) []string {
+	switch value := value.(type) {
+	case string:
+		commands = append(commands, value)
+	case []interface{}:
+		for _, v := range value {
+			commands = gitlabCommands(v, commands)
+		}
+	}
+	return commands
+}
+
+// Suffixes appended by GitLab to the name of the jobs run several times by the keyword 'parallel'
+var gitlabParallelSuffix = regexp.MustCompile(`( \d+/\d+|: \[.*\])$`)
+
+// Return the name of the job of the configuration whose instance is named 'name'
+func gitlabJobName(name string) string {
+	return gitlabParallelSuffix.ReplaceAllString(name, "")
+}
+
+// Return the deployments of a pipeline along with their environments indexed by ID. Deployments
+// are listed from the most recent one until they predate the pipeline. Users not allowed to see
+// the deployments of the project get an empty list.
+func (c GitLabClient) fetchDeployments(ctx context.Context, repositoryID int, pipeline gitlab.Pipeline) ([]*gitlab.Deployment, map[int]*gitlab.Environment, error) {
+	deployments := make([]*gitlab.Deployment, 0)
+	environments := make(map[int]*gitlab.Environment)
+	options := gitlab.ListProjectDeploymentsOptions{
+		OrderBy: gitlab.String("id"),
+		Sort:    gitlab.String("desc"),
+	}
+	for done := false; !done; {
+		select {
+		case <-c.rateLimiter:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		page, resp, err := c.remote.Deployments.ListProjectDeployments(repositoryID, &options, gitlab.WithContext(ctx))
+		if err != nil {
+			if errResp, ok := err.(*gitlab.ErrorResponse); ok && errResp.Response.StatusCode == 403 {
+				return deployments, environments, nil
+			}
+			return nil, nil, err
+		}
+		for _, deployment := range page {
+			if deployment.CreatedAt != nil && pipeline.CreatedAt != nil && deployment.CreatedAt.Before(*pipeline.CreatedAt) {
+				done = true
+				break
+			}
+			if deployment.Deployable.Pipeline.ID == pipeline.ID {
+				deployments = append(deployments, deployment)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		options.Page = resp.NextPage
+	}
+
+	// The environments embedded in deployments lack their state and their last deployment
+	for _, deployment := range deployments {
+		if deployment.Environment == nil {
+			continue
+		}
+		if _, exists := environments[deployment.Environment.ID]; exists {
+			continue
+		}
+		select {
+		case <-c.rateLimiter:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		environment, _, err := c.remote.Environments.GetEnvironment(repositoryID, deployment.Environment.ID, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, nil, err
+		}
+		environments[environment.ID] = environment
+	}
+
+	return deployments, environments, nil
+}
+
+// Convert deployments listed most recent first to cache.Deployment, oldest first. 'environments'
+// describes the current state of the environments of the deployments.
+func fromGitLabDeployments(deployments []*gitlab.Deployment, environments map[int]*gitlab.Environment) []*cache.Deployment {
+	result := make([]*cache.Deployment, 0, len(deployments))
+	for i := len(deployments) - 1; i >= 0; i-- {
+		d := deployments[i]
+		deployment := cache.Deployment{
+			ID:         strconv.Itoa(d.ID),
+			State:      FromGitLabState(d.Deployable.Status),
+			CreatedAt:  utils.NullTimeFromTime(d.CreatedAt),
+			FinishedAt: utils.NullTimeFromTime(d.Deployable.FinishedAt),
+			JobID:      strconv.Itoa(d.Deployable.ID),
+		}
+		deployment.CanRetry = d.Deployable.ID != 0 && (deployment.State == cache.Passed || deployment.State == cache.Failed || deployment.State == cache.Canceled)
+		if d.Environment != nil {
+			deployment.Environment = d.Environment.Name
+			deployment.EnvironmentID = strconv.Itoa(d.Environment.ID)
+			deployment.WebURL = d.Environment.ExternalURL
+			if environment, exists := environments[d.Environment.ID]; exists {
+				if environment.ExternalURL != "" {
+					deployment.WebURL = environment.ExternalURL
+				}
+				// Stopping the environment only makes sense while it runs this deployment
+				deployment.CanStop = environment.State == "available" &&
+					environment.LastDeployment != nil && environment.LastDeployment.ID == d.ID
+			}
+		}
+		result = append(result, &deployment)
+	}
+
+	return result
+}
+
+// StopEnvironment stops the environment of 'deployment', which runs its on_stop action
+func (c GitLabClient) StopEnvironment(ctx context.Context, repository cache.Repository, deployment cache.Deployment) error {
+	id, err := strconv.Atoi(deployment.EnvironmentID)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	_, err = c.remote.Environments.StopEnvironment(repository.ID, id, gitlab.WithContext(ctx))
+	return err
+}
+
+// RetryDeployment retries the job of 'deployment', which creates a new deployment
+func (c GitLabClient) RetryDeployment(ctx context.Context, repository cache.Repository, deployment cache.Deployment) error {
+	id, err := strconv.Atoi(deployment.JobID)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	_, _, err = c.remote.Jobs.RetryJob(repository.ID, id, gitlab.WithContext(ctx))
+	return err
+}
+
+// CancelBuild cancels the pipeline along with its active jobs
+func (c GitLabClient) CancelBuild(ctx context.Context, build cache.Build) error {
+	id, err := strconv.Atoi(build.ID)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	_, _, err = c.remote.Pipelines.CancelPipelineBuild(build.Repository.ID, id, gitlab.WithContext(ctx))
+	return err
+}
+
+// CancelJob cancels a job of the pipeline
+func (c GitLabClient) CancelJob(ctx context.Context, build cache.Build, job cache.Job) error {
+	id, err := strconv.Atoi(job.ID)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	_, _, err = c.remote.Jobs.CancelJob(build.Repository.ID, id, gitlab.WithContext(ctx))
+	return err
+}
+
+// RestartBuild retries the failed and canceled jobs of the pipeline and returns its web page
+func (c GitLabClient) RestartBuild(ctx context.Context, build cache.Build) (string, error) {
+	id, err := strconv.Atoi(build.ID)
+	if err != nil {
+		return "", err
+	}
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	pipeline, _, err := c.remote.Pipelines.RetryPipelineBuild(build.Repository.ID, id, gitlab.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	return pipeline.WebURL, nil
+}
+
+// RetryJob creates a new job identical to 'job' in the pipeline and returns its identifier
+func (c GitLabClient) RetryJob(ctx context.Context, build cache.Build, job cache.Job) (string, error) {
+	id, err := strconv.Atoi(job.ID)
+	if err != nil {
+		return "", err
+	}
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	retried, _, err := c.remote.Jobs.RetryJob(build.Repository.ID, id, gitlab.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(retried.ID), nil
+}
+
+// StartManualJob plays a manual job of the pipeline
+func (c GitLabClient) StartManualJob(ctx context.Context, build cache.Build, job cache.Job) error {
+	id, err := strconv.Atoi(job.ID)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	_, _, err = c.remote.Jobs.PlayJob(build.Repository.ID, id, gitlab.WithContext(ctx))
+	return err
+}
+
+// TriggerPipeline creates a pipeline on 'ref' of the project at 'repositoryURL' and returns its
+// web page
+func (c GitLabClient) TriggerPipeline(ctx context.Context, repositoryURL string, ref string, variables []cache.Variable) (string, error) {
+	host, owner, repo, err := utils.RepoHostOwnerAndName(repositoryURL)
+	if err != nil || !strings.Contains(host, c.remote.BaseURL().Hostname()) {
+		return "", cache.ErrUnknownURL
+	}
+
+	opt := gitlab.CreatePipelineOptions{Ref: &ref}
+	for _, v := range variables {
+		opt.Variables = append(opt.Variables, &gitlab.PipelineVariable{
+			Key:          v.Name,
+			Value:        v.Value,
+			VariableType: "env_var",
+		})
+	}
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	slug := fmt.Sprintf("%s/%s", owner, repo)
+	pipeline, resp, err := c.remote.Pipelines.CreatePipeline(slug, &opt, gitlab.WithContext(ctx))
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", cache.ErrRepositoryNotFound
+		}
+		return "", err
+	}
+
+	return pipeline.WebURL, nil
+}
+
+// Artifacts lists the files of the artifacts archive of a job. GitLab only exposes the archive
+// so it is downloaded as a whole.
+func (c GitLabClient) Artifacts(ctx context.Context, build cache.Build, job cache.Job) ([]cache.Artifact, error) {
+	id, err := strconv.Atoi(job.ID)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	r, resp, err := c.remote.Jobs.GetJobArtifacts(build.Repository.ID, id, gitlab.WithContext(ctx))
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			// The job has no artifacts or they expired
+			return []cache.Artifact{}, nil
+		}
+		return nil, err
+	}
+	archive, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make([]cache.Artifact, 0, len(zr.File))
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		artifact := cache.Artifact{
+			Path: f.Name,
+			Size: int64(f.UncompressedSize64),
+		}
+		if job.WebURL != "" {
+			artifact.WebURL = fmt.Sprintf("%s/artifacts/file/%s", strings.TrimSuffix(job.WebURL, "/"), f.Name)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+
+	return artifacts, nil
+}
+
+// WriteArtifact writes a single file of the artifacts archive of a job to 'w'
+func (c GitLabClient) WriteArtifact(ctx context.Context, build cache.Build, job cache.Job, path string, w io.Writer) error {
+	id, err := strconv.Atoi(job.ID)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	r, _, err := c.remote.Jobs.DownloadSingleArtifactsFile(build.Repository.ID, id, path, gitlab.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+type gitLabTestReport struct {
+	Suites []struct {
+		Name       string `json:"name"`
+		TotalCount int    `json:"total_count"`
+		Cases      []struct {
+			Status     string `json:"status"`
+			Name       string `json:"name"`
+			Classname  string `json:"classname"`
+			Output     string `json:"system_output"`
+			StackTrace string `json:"stack_trace"`
+		} `json:"test_cases"`
+	} `json:"test_suites"`
+}
+
+// TestReport returns the results of the tests of 'job' from the test report of the pipeline,
+// where each job publishing JUnit reports gets a test suite named after it
+func (c GitLabClient) TestReport(ctx context.Context, build cache.Build, job cache.Job) (cache.TestReport, error) {
+	u := fmt.Sprintf("projects/%d/pipelines/%s/test_report", build.Repository.ID, url.PathEscape(build.ID))
+	req, err := c.remote.NewRequest("GET", u, nil, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return cache.TestReport{}, err
+	}
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return cache.TestReport{}, ctx.Err()
+	}
+	var testReport gitLabTestReport
+	resp, err := c.remote.Do(req, &testReport)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return cache.TestReport{}, cache.ErrNoTestReport
+		}
+		return cache.TestReport{}, err
+	}
+
+	report := cache.TestReport{}
+	found := false
+	for _, suite := range testReport.Suites {
+		if suite.Name != job.Name {
+			continue
+		}
+		found = true
+		for _, testCase := range suite.Cases {
+			report.Total++
+			switch testCase.Status {
+			case "failed", "error":
+				report.Failed++
+				message := testCase.Output
+				if message == "" {
+					message = testCase.StackTrace
+				}
+				report.Failures = append(report.Failures, cache.TestCase{
+					Suite:   testCase.Classname,
+					Name:    testCase.Name,
+					Message: strings.SplitN(strings.TrimSpace(message), "\n", 2)[0],
+				})
+			case "skipped":
+				report.Skipped++
+			}
+		}
+	}
+	if !found {
+		return cache.TestReport{}, cache.ErrNoTestReport
+	}
+
+	return report, nil
+}
@@ -1,8 +1,18 @@
 package providers
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+	"github.com/xanzy/go-gitlab"
 )
 
 func TestParseGitlabWebURL(t *testing.T) {
@@ -22,3 +32,161 @@ func TestParseGitlabWebURL(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestFromGitLabDeployments(t *testing.T) {
+	date := time.Date(2019, 11, 21, 14, 44, 2, 0, time.UTC)
+	newDeployment := func(id int, jobID int, status string, environment *gitlab.Environment) *gitlab.Deployment {
+		d := gitlab.Deployment{
+			ID:          id,
+			CreatedAt:   &date,
+			Environment: environment,
+		}
+		d.Deployable.ID = jobID
+		d.Deployable.Status = status
+		return &d
+	}
+	review := gitlab.Environment{ID: 1, Name: "review/feature", ExternalURL: "https://feature.example.com"}
+	production := gitlab.Environment{ID: 2, Name: "production"}
+
+	// Most recent first, as listed by GitLab
+	deployments := []*gitlab.Deployment{
+		newDeployment(12, 102, "running", &production),
+		newDeployment(11, 101, "success", &review),
+	}
+	environments := map[int]*gitlab.Environment{
+		1: {ID: 1, State: "available", ExternalURL: "https://feature.example.com", LastDeployment: &gitlab.Deployment{ID: 11}},
+		2: {ID: 2, State: "available", ExternalURL: "https://example.com", LastDeployment: &gitlab.Deployment{ID: 10}},
+	}
+
+	expected := []*cache.Deployment{
+		{
+			ID:            "11",
+			Environment:   "review/feature",
+			EnvironmentID: "1",
+			State:         cache.Passed,
+			CreatedAt:     utils.NullTime{Time: date, Valid: true},
+			WebURL:        "https://feature.example.com",
+			JobID:         "101",
+			CanStop:       true,
+			CanRetry:      true,
+		},
+		{
+			ID:            "12",
+			Environment:   "production",
+			EnvironmentID: "2",
+			State:         cache.Running,
+			CreatedAt:     utils.NullTime{Time: date, Valid: true},
+			WebURL:        "https://example.com",
+			JobID:         "102",
+		},
+	}
+	if diff := cmp.Diff(expected, fromGitLabDeployments(deployments, environments)); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestGitLabClient_TestReport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/projects/8/pipelines/42/test_report" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{
+  "total_count": 3,
+  "test_suites": [
+    {
+      "name": "rspec",
+      "total_count": 3,
+      "test_cases": [
+        {"status": "success", "name": "renders the page", "classname": "spec.page"},
+        {"status": "failed", "name": "saves the user", "classname": "spec.user", "system_output": "expected true\ngot false"},
+        {"status": "skipped", "name": "deletes the user", "classname": "spec.user"}
+      ]
+    }
+  ]
+}`)
+	}))
+	defer ts.Close()
+
+	client := NewGitLabClient("id", "name", "token", time.Millisecond)
+	if err := client.remote.SetBaseURL(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	build := cache.Build{Repository: &cache.Repository{ID: 8}, ID: "42"}
+	ctx := context.Background()
+
+	report, err := client.TestReport(ctx, build, cache.Job{ID: "1", Name: "rspec"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := cache.TestReport{
+		Total:    3,
+		Failed:   1,
+		Skipped:  1,
+		Failures: []cache.TestCase{{Suite: "spec.user", Name: "saves the user", Message: "expected true"}},
+	}
+	if diff := cmp.Diff(expected, report); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	if _, err := client.TestReport(ctx, build, cache.Job{ID: "2", Name: "lint"}); err != cache.ErrNoTestReport {
+		t.Fatalf("expected %v but got %v", cache.ErrNoTestReport, err)
+	}
+	build.ID = "43"
+	if _, err := client.TestReport(ctx, build, cache.Job{ID: "1", Name: "rspec"}); err != cache.ErrNoTestReport {
+		t.Fatalf("expected %v but got %v", cache.ErrNoTestReport, err)
+	}
+}
+
+func TestGitlabJobSteps(t *testing.T) {
+	config := `
+stages: [test, deploy]
+before_script:
+  - go version
+.go:
+  image: golang:1.12
+  script:
+    - go test ./...
+lint:
+  stage: test
+  before_script: []
+  script: make lint
+tests:
+  extends: .go
+  after_script:
+    - - rm -rf vendor
+      - make clean
+deploy:
+  stage: deploy
+  trigger: nbedos/deployments
+`
+	steps, err := gitlabJobSteps([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]cache.Step{
+		"lint": {
+			{Name: "script", Command: "make lint"},
+		},
+		"tests": {
+			{Name: "before_script", Command: "go version"},
+			{Name: "script", Command: "go test ./..."},
+			{Name: "after_script", Command: "rm -rf vendor"},
+			{Name: "after_script", Command: "make clean"},
+		},
+	}
+	if diff := cmp.Diff(expected, steps); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	for name, expected := range map[string]string{
+		"tests":              "tests",
+		"tests 2/3":          "tests",
+		"tests: [1.12, amd]": "tests",
+		"tests 2":            "tests 2",
+	} {
+		if job := gitlabJobName(name); job != expected {
+			t.Fatalf("expected %q but got %q", expected, job)
+		}
+	}
+}
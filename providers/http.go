@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"net/http"
+	"time"
+)
+
+// Timeout of the requests sent by the clients of providers implemented in this package
+const requestTimeout = 10 * time.Second
+
+// headerTransport adds headers to every request before sending it with the base transport.
+// Headers set by the transport replace headers of the same name set on the request, including
+// the User-Agent set by the client libraries of GitHub and GitLab.
+type headerTransport struct {
+	header http.Header
+	base   http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.header) == 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request it is given
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+len(t.header))
+	for key, values := range req.Header {
+		r.Header[key] = values
+	}
+	for key, values := range t.header {
+		r.Header[key] = values
+	}
+
+	return t.base.RoundTrip(r)
+}
+
+// NewHeaderTransport returns a transport adding 'header' to every request before sending it
+// with 'base'
+func NewHeaderTransport(header http.Header, base http.RoundTripper) http.RoundTripper {
+	return headerTransport{
+		header: header,
+		base:   base,
+	}
+}
+
+// ClientOption configures the HTTP client used by the client of a provider. Options are passed
+// to the constructors of the clients of this package.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	header    http.Header
+	transport http.RoundTripper
+}
+
+// WithHeader adds 'header' to every request sent to the provider, replacing the headers of the
+// same name set by the client
+func WithHeader(header http.Header) ClientOption {
+	return func(o *clientOptions) {
+		o.header = header
+	}
+}
+
+// WithTransport sends the requests to the provider with 'transport' instead of the default
+// transport of the net/http package
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(o *clientOptions) {
+		o.transport = transport
+	}
+}
+
+// Return an HTTP client configured by 'options'. A timeout of zero means no timeout.
+func newHTTPClient(timeout time.Duration, options []ClientOption) *http.Client {
+	o := clientOptions{}
+	for _, option := range options {
+		option(&o)
+	}
+	transport := o.transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if len(o.header) > 0 {
+		transport = NewHeaderTransport(o.header, transport)
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
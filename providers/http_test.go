@@ -0,0 +1,79 @@
+package providers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// Return a server answering each request with the content of the file of test_data associated
+// to the escaped path of its URL in 'files', or with a 404 error if there is none. Occurrences
+// of TEST_SERVER_URL in files are replaced by the URL of the server so that fixtures may link
+// to it. If 'handle' is not nil it is called first and returns true if it answered the request
+// itself, for example to reject invalid credentials.
+func newFixtureServer(t *testing.T, files map[string]string, handle func(w http.ResponseWriter, r *http.Request) bool) *httptest.Server {
+	contents := make(map[string][]byte, len(files))
+	for path, filename := range files {
+		bs, err := ioutil.ReadFile(filepath.Join("test_data", filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[path] = bs
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handle != nil && handle(w, r) {
+			return
+		}
+		bs, exists := contents[r.URL.EscapedPath()]
+		if !exists {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write(bytes.Replace(bs, []byte("TEST_SERVER_URL"), []byte("http://"+r.Host), -1))
+	}))
+}
+
+func TestWithHeader(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+	}))
+	defer ts.Close()
+
+	header := http.Header{}
+	header.Set("User-Agent", "citop/0.2.0")
+	header.Set("X-Proxy-Token", "secret")
+	client := newHTTPClient(requestTimeout, []ClientOption{WithHeader(header)})
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", "go-gitlab")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	received := <-headers
+	for key, expected := range map[string]string{
+		"User-Agent":    "citop/0.2.0",
+		"X-Proxy-Token": "secret",
+		"Accept":        "application/json",
+	} {
+		if value := received.Get(key); value != expected {
+			t.Fatalf("expected header %s to be %q but got %q", key, expected, value)
+		}
+	}
+
+	// The request passed to the client must not be modified
+	if value := req.Header.Get("User-Agent"); value != "go-gitlab" {
+		t.Fatalf("request modified: expected User-Agent %q but got %q", "go-gitlab", value)
+	}
+}
@@ -0,0 +1,449 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// Default namespace of the resources of Jenkins X
+const LighthouseNamespace = "jx"
+
+// LighthouseClient reads the pipelines triggered by Lighthouse, the webhook handler of Jenkins X,
+// from the Kubernetes cluster running them. Pipelines are found through the commit statuses that
+// Lighthouse reports to GitHub, whose target URLs point to the dashboard of Jenkins X. The
+// progress of each pipeline is read from its PipelineActivity and the logs of its steps from
+// the pods of the Tekton PipelineRun.
+type LighthouseClient struct {
+	dashboardURL  url.URL
+	kubernetesURL url.URL
+	namespace     string
+	token         string
+	httpClient    *http.Client
+	rateLimiter   <-chan time.Time
+	provider      cache.Provider
+	// Step of the Tekton pipeline run by each job, used to retrieve their logs
+	jobs map[string]lighthouseStep
+	mux  *sync.Mutex
+	repositoryRestriction
+}
+
+// lighthouseBuild identifies a run of a pipeline the way Lighthouse does
+type lighthouseBuild struct {
+	owner  string
+	repo   string
+	branch string
+	build  string
+}
+
+// Name of the PipelineActivity of the build, e.g. "nbedos-citop-pr-12-3"
+func (b lighthouseBuild) activityName() string {
+	name := strings.ToLower(strings.Join([]string{b.owner, b.repo, b.branch, b.build}, "-"))
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		return '-'
+	}, name)
+}
+
+// Labels set by Lighthouse on the PipelineRun of the build
+func (b lighthouseBuild) labelSelector() string {
+	return strings.Join([]string{
+		"lighthouse.jenkins-x.io/refs.org=" + b.owner,
+		"lighthouse.jenkins-x.io/refs.repo=" + b.repo,
+		"lighthouse.jenkins-x.io/branch=" + b.branch,
+		"lighthouse.jenkins-x.io/buildNum=" + b.build,
+	}, ",")
+}
+
+type lighthouseStep struct {
+	build lighthouseBuild
+	// Name of the task of the Tekton pipeline and name of the step of the task
+	task string
+	step string
+	// URL of the archived log of the pipeline, if any
+	logsURL string
+}
+
+func NewLighthouseClient(id string, name string, token string, dashboardURL url.URL, kubernetesURL url.URL, namespace string, rateLimit time.Duration, options ...ClientOption) LighthouseClient {
+	return LighthouseClient{
+		dashboardURL:  dashboardURL,
+		kubernetesURL: kubernetesURL,
+		namespace:     namespace,
+		token:         token,
+		httpClient:    newHTTPClient(requestTimeout, options),
+		rateLimiter:   time.Tick(rateLimit),
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+		jobs: make(map[string]lighthouseStep),
+		mux:  &sync.Mutex{},
+	}
+}
+
+func (c LighthouseClient) ID() string {
+	return c.provider.ID
+}
+
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c LighthouseClient) WithRepositories(repositories RepositoryPatterns) LighthouseClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// Return the build whose page on the dashboard of Jenkins X is at 'u', e.g.
+// https://dashboard-jx.example.com/nbedos/citop/PR-12/3
+func parseLighthouseURL(dashboardURL url.URL, u string) (lighthouseBuild, error) {
+	v, err := url.Parse(u)
+	if err != nil || v.Hostname() != dashboardURL.Hostname() {
+		return lighthouseBuild{}, cache.ErrUnknownURL
+	}
+
+	path := strings.TrimPrefix(v.Path, strings.TrimSuffix(dashboardURL.Path, "/"))
+	cs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(cs) != 4 {
+		return lighthouseBuild{}, cache.ErrUnknownURL
+	}
+	for _, c := range cs {
+		if c == "" {
+			return lighthouseBuild{}, cache.ErrUnknownURL
+		}
+	}
+	if _, err := strconv.ParseUint(cs[3], 10, 64); err != nil {
+		return lighthouseBuild{}, cache.ErrUnknownURL
+	}
+
+	return lighthouseBuild{
+		owner:  cs[0],
+		repo:   cs[1],
+		branch: cs[2],
+		build:  cs[3],
+	}, nil
+}
+
+// Send a GET request to the Kubernetes API and return the body of the response
+func (c LighthouseClient) get(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	u := c.kubernetesURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: "",
+		}
+	}
+
+	return body.Bytes(), nil
+}
+
+// Progress of a step, of a stage or of a whole pipeline
+type lighthouseProgress struct {
+	Name               string     `json:"name"`
+	Status             string     `json:"status"`
+	StartedTimestamp   *time.Time `json:"startedTimestamp"`
+	CompletedTimestamp *time.Time `json:"completedTimestamp"`
+}
+
+// PipelineActivity resource (jenkins.io/v1) recording the progress of a pipeline
+type lighthouseActivity struct {
+	Metadata struct {
+		Name              string    `json:"name"`
+		CreationTimestamp time.Time `json:"creationTimestamp"`
+	} `json:"metadata"`
+	Spec struct {
+		lighthouseProgress
+		Build         string `json:"build"`
+		Context       string `json:"context"`
+		GitURL        string `json:"gitUrl"`
+		GitOwner      string `json:"gitOwner"`
+		GitRepository string `json:"gitRepository"`
+		GitBranch     string `json:"gitBranch"`
+		LastCommitSHA string `json:"lastCommitSHA"`
+		BuildLogsURL  string `json:"buildLogsUrl"`
+		Steps         []struct {
+			Kind  string `json:"kind"`
+			Stage *struct {
+				lighthouseProgress
+				Steps []lighthouseProgress `json:"steps"`
+			} `json:"stage"`
+		} `json:"steps"`
+	} `json:"spec"`
+}
+
+// PipelineRun resource (tekton.dev/v1beta1) running the tasks of a pipeline
+type lighthousePipelineRun struct {
+	Status struct {
+		TaskRuns map[string]struct {
+			PipelineTaskName string `json:"pipelineTaskName"`
+			Status           struct {
+				PodName string `json:"podName"`
+				Steps   []struct {
+					Name      string `json:"name"`
+					Container string `json:"container"`
+				} `json:"steps"`
+			} `json:"status"`
+		} `json:"taskRuns"`
+	} `json:"status"`
+}
+
+func (c LighthouseClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	b, err := parseLighthouseURL(c.dashboardURL, u)
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	path := fmt.Sprintf("/apis/jenkins.io/v1/namespaces/%s/pipelineactivities/%s", c.namespace, b.activityName())
+	body, err := c.get(ctx, path, nil)
+	if err != nil {
+		if httpErr, ok := err.(HTTPError); ok && httpErr.Status == 404 {
+			// The PipelineActivity is created once the pipeline starts
+			return fromLighthouseActivity(c.provider, u, b, nil), nil
+		}
+		return cache.Build{}, err
+	}
+	var activity lighthouseActivity
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return cache.Build{}, err
+	}
+
+	build := fromLighthouseActivity(c.provider, u, b, &activity)
+	c.mux.Lock()
+	for _, stage := range build.Stages {
+		for _, job := range stage.Jobs {
+			c.jobs[job.ID] = lighthouseStep{
+				build:   b,
+				task:    stage.Name,
+				step:    job.Name,
+				logsURL: activity.Spec.BuildLogsURL,
+			}
+		}
+	}
+	c.mux.Unlock()
+
+	return build, nil
+}
+
+func fromLighthouseState(status string) cache.State {
+	switch strings.ToLower(status) {
+	case "", "pending", "waiting", "blocked":
+		return cache.Pending
+	case "running":
+		return cache.Running
+	case "succeeded":
+		return cache.Passed
+	case "failed", "error", "timedout":
+		return cache.Failed
+	case "aborted":
+		return cache.Canceled
+	case "notexecuted", "skipped":
+		return cache.Skipped
+	default:
+		return cache.Unknown
+	}
+}
+
+func (p lighthouseProgress) times() (utils.NullTime, utils.NullTime, utils.NullDuration) {
+	startedAt := utils.NullTimeFromTime(p.StartedTimestamp)
+	finishedAt := utils.NullTimeFromTime(p.CompletedTimestamp)
+	return startedAt, finishedAt, utils.NullSub(finishedAt, startedAt)
+}
+
+// Return the build recorded by 'activity', or a pending build if 'activity' is nil
+func fromLighthouseActivity(provider cache.Provider, webURL string, b lighthouseBuild, activity *lighthouseActivity) cache.Build {
+	repository := cache.Repository{
+		Provider: provider,
+		URL:      fmt.Sprintf("https://github.com/%s/%s", b.owner, b.repo),
+		Owner:    b.owner,
+		Name:     b.repo,
+	}
+	ref := b.branch
+	if n := strings.TrimPrefix(strings.ToUpper(ref), "PR-"); n != ref {
+		if _, err := strconv.Atoi(n); err == nil {
+			ref = "pull/" + n
+		}
+	}
+	build := cache.Build{
+		Repository: &repository,
+		ID:         b.activityName(),
+		Ref:        ref,
+		State:      cache.Pending,
+		WebURL:     webURL,
+		Stages:     map[int]*cache.Stage{},
+		Jobs:       []*cache.Job{},
+	}
+	if activity == nil {
+		return build
+	}
+
+	spec := activity.Spec
+	if spec.GitURL != "" {
+		repository.URL = strings.TrimSuffix(spec.GitURL, ".git")
+	}
+	if spec.GitOwner != "" && spec.GitRepository != "" {
+		repository.Owner, repository.Name = spec.GitOwner, spec.GitRepository
+	}
+	build.Commit = cache.Commit{Sha: spec.LastCommitSHA}
+	build.State = fromLighthouseState(spec.Status)
+	build.CreatedAt = utils.NullTime{Time: activity.Metadata.CreationTimestamp, Valid: !activity.Metadata.CreationTimestamp.IsZero()}
+	build.StartedAt, build.FinishedAt, build.Duration = spec.times()
+	build.CreatedAt = utils.MinNullTime(build.CreatedAt, build.StartedAt)
+	build.UpdatedAt = utils.MaxNullTime(build.CreatedAt, build.StartedAt, build.FinishedAt).Time
+
+	for _, step := range spec.Steps {
+		if step.Stage == nil {
+			continue
+		}
+		stage := cache.Stage{
+			ID:    len(build.Stages) + 1,
+			Name:  step.Stage.Name,
+			State: fromLighthouseState(step.Stage.Status),
+			Jobs:  make([]*cache.Job, 0, len(step.Stage.Steps)),
+		}
+		for _, s := range step.Stage.Steps {
+			job := cache.Job{
+				ID:     fmt.Sprintf("%s.%s.%s", build.ID, stage.Name, s.Name),
+				State:  fromLighthouseState(s.Status),
+				Name:   s.Name,
+				WebURL: webURL,
+			}
+			job.StartedAt, job.FinishedAt, job.Duration = s.times()
+			job.CreatedAt = job.StartedAt
+			stage.Jobs = append(stage.Jobs, &job)
+		}
+		build.Stages[stage.ID] = &stage
+	}
+
+	return build
+}
+
+// Log returns the log of the step run by the job. The log is read from the container of the
+// step as long as the pod of the task exists, then from the archived log of the pipeline.
+func (c LighthouseClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	c.mux.Lock()
+	step, exists := c.jobs[jobID]
+	c.mux.Unlock()
+	if !exists {
+		return "", fmt.Errorf("unknown Lighthouse job %q", jobID)
+	}
+
+	log, err := c.podLog(ctx, step)
+	if httpErr, ok := err.(HTTPError); ok && httpErr.Status == 404 && step.logsURL != "" {
+		return c.archivedLog(ctx, step.logsURL)
+	}
+	return log, err
+}
+
+// Return the log of the container running the step
+func (c LighthouseClient) podLog(ctx context.Context, step lighthouseStep) (string, error) {
+	path := fmt.Sprintf("/apis/tekton.dev/v1beta1/namespaces/%s/pipelineruns", c.namespace)
+	body, err := c.get(ctx, path, url.Values{"labelSelector": {step.build.labelSelector()}})
+	if err != nil {
+		return "", err
+	}
+	var runs struct {
+		Items []lighthousePipelineRun `json:"items"`
+	}
+	if err := json.Unmarshal(body, &runs); err != nil {
+		return "", err
+	}
+
+	for _, run := range runs.Items {
+		for _, taskRun := range run.Status.TaskRuns {
+			if taskRun.PipelineTaskName != step.task || taskRun.Status.PodName == "" {
+				continue
+			}
+			for _, s := range taskRun.Status.Steps {
+				if s.Name != step.step {
+					continue
+				}
+				path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/log", c.namespace, taskRun.Status.PodName)
+				log, err := c.get(ctx, path, url.Values{"container": {s.Container}})
+				return string(log), err
+			}
+		}
+	}
+
+	return "", HTTPError{
+		Method:  "GET",
+		URL:     path,
+		Status:  404,
+		Message: fmt.Sprintf("no pod found for step %q of task %q", step.step, step.task),
+	}
+}
+
+// Return the archived log of the pipeline. Only logs served over HTTP are supported.
+func (c LighthouseClient) archivedLog(ctx context.Context, logsURL string) (string, error) {
+	u, err := url.Parse(logsURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported location of archived log: %q", logsURL)
+	}
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: "",
+		}
+	}
+
+	return body.String(), nil
+}
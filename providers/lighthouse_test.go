@@ -0,0 +1,194 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func TestParseLighthouseURL(t *testing.T) {
+	dashboardURL := url.URL{Scheme: "https", Host: "dashboard-jx.example.com"}
+
+	t.Run("Dashboard URLs", func(t *testing.T) {
+		urls := []string{
+			"https://dashboard-jx.example.com/nbedos/citop/PR-12/3",
+			"https://dashboard-jx.example.com/nbedos/citop/PR-12/3/",
+		}
+		expected := lighthouseBuild{
+			owner:  "nbedos",
+			repo:   "citop",
+			branch: "PR-12",
+			build:  "3",
+		}
+		for _, u := range urls {
+			b, err := parseLighthouseURL(dashboardURL, u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(expected, b, cmp.AllowUnexported(lighthouseBuild{})); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+			if name := b.activityName(); name != "nbedos-citop-pr-12-3" {
+				t.Fatalf("unexpected activity name %q", name)
+			}
+		}
+	})
+
+	t.Run("Unknown URLs", func(t *testing.T) {
+		urls := []string{
+			"https://gitlab.com/nbedos/citop/pipelines/97604657",
+			"https://dashboard-jx.example.com/nbedos/citop/PR-12",
+			"https://dashboard-jx.example.com/nbedos/citop/PR-12/latest",
+			"https://dashboard-jx.example.com/nbedos/citop/PR-12/3/logs",
+		}
+		for _, u := range urls {
+			if _, err := parseLighthouseURL(dashboardURL, u); err != cache.ErrUnknownURL {
+				t.Fatalf("expected %v but got %v for URL %q", cache.ErrUnknownURL, err, u)
+			}
+		}
+	})
+}
+
+// Return a server acting as the API server of a Kubernetes cluster running a pipeline
+func newLighthouseTestServer() *httptest.Server {
+	const pod = "nbedos-citop-pr-12-r5x2k-from-build-pack-9vsb4-pod-6bw2z"
+	files := map[string]string{
+		"/apis/jenkins.io/v1/namespaces/jx/pipelineactivities/nbedos-citop-pr-12-3": "lighthouse_activity.json",
+		"/apis/tekton.dev/v1beta1/namespaces/jx/pipelineruns":                       "lighthouse_pipelineruns.json",
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(401)
+			return
+		}
+		if r.URL.Path == fmt.Sprintf("/api/v1/namespaces/jx/pods/%s/log", pod) {
+			fmt.Fprintf(w, "log of container %s\n", r.URL.Query().Get("container"))
+			return
+		}
+		filename, exists := files[r.URL.Path]
+		if !exists {
+			w.WriteHeader(404)
+			return
+		}
+		bs, err := ioutil.ReadFile(fmt.Sprintf("test_data/%s", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		w.Write(bs)
+	}))
+}
+
+func TestLighthouseClient_BuildFromURL(t *testing.T) {
+	ts := newLighthouseTestServer()
+	defer ts.Close()
+
+	kubernetesURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dashboardURL := url.URL{Scheme: "https", Host: "dashboard-jx.example.com"}
+	client := NewLighthouseClient("lighthouse", "lighthouse", "token", dashboardURL, *kubernetesURL, LighthouseNamespace, time.Millisecond)
+	ctx := context.Background()
+
+	t.Run("Finished pipeline", func(t *testing.T) {
+		webURL := "https://dashboard-jx.example.com/nbedos/citop/PR-12/3"
+		build, err := client.BuildFromURL(ctx, webURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		date := func(minutes int, seconds int) utils.NullTime {
+			return utils.NullTime{Time: time.Date(2020, 2, 3, 10, minutes, seconds, 0, time.UTC), Valid: true}
+		}
+		duration := func(d time.Duration) utils.NullDuration {
+			return utils.NullDuration{Duration: d, Valid: true}
+		}
+		expected := cache.Build{
+			Repository: &cache.Repository{
+				Provider: cache.Provider{ID: "lighthouse", Name: "lighthouse"},
+				URL:      "https://github.com/nbedos/citop",
+				Owner:    "nbedos",
+				Name:     "citop",
+			},
+			ID:         "nbedos-citop-pr-12-3",
+			Commit:     cache.Commit{Sha: "a24840cf94b395af69da4a1001d32e3694637e20"},
+			Ref:        "pull/12",
+			State:      cache.Failed,
+			CreatedAt:  date(0, 0),
+			StartedAt:  date(0, 5),
+			FinishedAt: date(4, 5),
+			UpdatedAt:  date(4, 5).Time,
+			Duration:   duration(4 * time.Minute),
+			WebURL:     webURL,
+			Stages: map[int]*cache.Stage{
+				1: {
+					ID:    1,
+					Name:  "from-build-pack",
+					State: cache.Failed,
+					Jobs: []*cache.Job{
+						{
+							ID:         "nbedos-citop-pr-12-3.from-build-pack.git-clone",
+							State:      cache.Passed,
+							Name:       "git-clone",
+							CreatedAt:  date(0, 5),
+							StartedAt:  date(0, 5),
+							FinishedAt: date(0, 15),
+							Duration:   duration(10 * time.Second),
+							WebURL:     webURL,
+						},
+						{
+							ID:         "nbedos-citop-pr-12-3.from-build-pack.build-make-test",
+							State:      cache.Failed,
+							Name:       "build-make-test",
+							CreatedAt:  date(0, 15),
+							StartedAt:  date(0, 15),
+							FinishedAt: date(4, 5),
+							Duration:   duration(3*time.Minute + 50*time.Second),
+							WebURL:     webURL,
+						},
+						{
+							ID:     "nbedos-citop-pr-12-3.from-build-pack.promote-jx-preview",
+							State:  cache.Skipped,
+							Name:   "promote-jx-preview",
+							WebURL: webURL,
+						},
+					},
+				},
+			},
+			Jobs: []*cache.Job{},
+		}
+		if diff := cmp.Diff(expected, build); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+
+		log, err := client.Log(ctx, *build.Repository, build.Stages[1].Jobs[1].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := "log of container step-build-make-test\n"; log != expected {
+			t.Fatalf("expected log %q but got %q", expected, log)
+		}
+	})
+
+	t.Run("Pipeline not started", func(t *testing.T) {
+		build, err := client.BuildFromURL(ctx, "https://dashboard-jx.example.com/nbedos/citop/master/4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if build.State != cache.Pending || build.Ref != "master" || len(build.Stages) > 0 {
+			t.Fatalf("expected a pending pipeline but got %+v", build)
+		}
+	})
+}
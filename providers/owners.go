@@ -0,0 +1,24 @@
+package providers
+
+import (
+	"path"
+	"strings"
+)
+
+// OwnerPatterns restricts an account to the repositories whose owner matches one of the patterns,
+// such as "acme" or "acme-*" (see path.Match for the syntax of patterns). Case is ignored since
+// GitHub and GitLab ignore the case of owners. An empty list matches every owner.
+type OwnerPatterns []string
+
+// Match returns true if 'owner' matches one of the patterns
+func (p OwnerPatterns) Match(owner string) bool {
+	if len(p) == 0 {
+		return true
+	}
+	for _, pattern := range p {
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(owner)); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package providers
+
+import "testing"
+
+func TestOwnerPatterns_Match(t *testing.T) {
+	testCases := []struct {
+		patterns OwnerPatterns
+		owner    string
+		expected bool
+	}{
+		{nil, "nbedos", true},
+		{OwnerPatterns{"nbedos"}, "nbedos", true},
+		{OwnerPatterns{"nbedos"}, "NBedos", true},
+		{OwnerPatterns{"nbedos"}, "acme", false},
+		{OwnerPatterns{"nbedos", "acme-*"}, "acme-labs", true},
+		{OwnerPatterns{"acme-*"}, "acme", false},
+	}
+	for _, testCase := range testCases {
+		if matched := testCase.patterns.Match(testCase.owner); matched != testCase.expected {
+			t.Fatalf("%v.Match(%q): expected %v but got %v", testCase.patterns, testCase.owner, testCase.expected, matched)
+		}
+	}
+}
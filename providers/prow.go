@@ -0,0 +1,475 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// ProwURL is the URL of Deck, the web interface of the instance of Prow used by the Kubernetes
+// project
+var ProwURL = url.URL{
+	Scheme: "https",
+	Host:   "prow.k8s.io",
+}
+
+// GCSURL is the URL of the public endpoint of Google Cloud Storage where Prow uploads the
+// artifacts of jobs
+var GCSURL = url.URL{
+	Scheme: "https",
+	Host:   "storage.googleapis.com",
+}
+
+// ProwClient reads the results of Prow jobs from the artifacts uploaded to the storage bucket of
+// the instance. Jobs are found through the commit statuses that Prow reports to GitHub, whose
+// target URLs point to Spyglass, the artifact viewer of Deck.
+type ProwClient struct {
+	deckURL     url.URL
+	storageURL  url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	provider    cache.Provider
+	// Location of the artifacts of each job by build ID, used to retrieve their logs
+	jobs map[string]prowJobLocation
+	mux  *sync.Mutex
+	repositoryRestriction
+}
+
+type prowJobLocation struct {
+	// Path of the artifacts of the job in the storage: bucket/.../job/buildID
+	artifacts string
+	job       string
+}
+
+func NewProwClient(id string, name string, deckURL url.URL, storageURL url.URL, rateLimit time.Duration, options ...ClientOption) ProwClient {
+	return ProwClient{
+		deckURL:     deckURL,
+		storageURL:  storageURL,
+		httpClient:  newHTTPClient(requestTimeout, options),
+		rateLimiter: time.Tick(rateLimit),
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+		jobs: make(map[string]prowJobLocation),
+		mux:  &sync.Mutex{},
+	}
+}
+
+func (c ProwClient) ID() string {
+	return c.provider.ID
+}
+
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c ProwClient) WithRepositories(repositories RepositoryPatterns) ProwClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// Return the location of the artifacts of the job whose Spyglass page is at 'u', e.g.
+// https://prow.k8s.io/view/gs/kubernetes-jenkins/pr-logs/pull/kubernetes_kubernetes/123/pull-kubernetes-e2e/456
+func parseProwURL(deckURL url.URL, u string) (prowJobLocation, string, error) {
+	v, err := url.Parse(u)
+	if err != nil || v.Hostname() != deckURL.Hostname() {
+		return prowJobLocation{}, "", cache.ErrUnknownURL
+	}
+
+	path := strings.TrimPrefix(v.EscapedPath(), strings.TrimSuffix(deckURL.EscapedPath(), "/"))
+	var artifacts string
+	switch {
+	case strings.HasPrefix(path, "/view/gs/"):
+		artifacts = strings.TrimPrefix(path, "/view/gs/")
+	case strings.HasPrefix(path, "/view/gcs/"):
+		artifacts = strings.TrimPrefix(path, "/view/gcs/")
+	default:
+		return prowJobLocation{}, "", cache.ErrUnknownURL
+	}
+
+	// bucket/.../job/buildID
+	cs := strings.Split(strings.Trim(artifacts, "/"), "/")
+	if len(cs) < 3 {
+		return prowJobLocation{}, "", cache.ErrUnknownURL
+	}
+	buildID := cs[len(cs)-1]
+	if _, err := strconv.ParseUint(buildID, 10, 64); err != nil {
+		return prowJobLocation{}, "", cache.ErrUnknownURL
+	}
+	location := prowJobLocation{
+		artifacts: strings.Join(cs, "/"),
+		job:       cs[len(cs)-2],
+	}
+
+	return location, buildID, nil
+}
+
+// Return the content of the artifact 'name' of a job
+func (c ProwClient) get(ctx context.Context, location prowJobLocation, name string) ([]byte, error) {
+	u := c.storageURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + location.artifacts + "/" + name
+	return c.getURL(ctx, u)
+}
+
+func (c ProwClient) getURL(ctx context.Context, u url.URL) ([]byte, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: "",
+		}
+	}
+
+	return body.Bytes(), nil
+}
+
+// Decode the JSON artifact 'name' of a job into 'v'. Return false if the artifact does not
+// exist, which is the case of most artifacts until the job finishes.
+func (c ProwClient) getJSON(ctx context.Context, location prowJobLocation, name string, v interface{}) (bool, error) {
+	body, err := c.get(ctx, location, name)
+	if err != nil {
+		if httpErr, ok := err.(HTTPError); ok && httpErr.Status == 404 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, json.Unmarshal(body, v)
+}
+
+// Content of started.json, uploaded when the job starts
+type prowStarted struct {
+	Timestamp  int64  `json:"timestamp"`
+	RepoCommit string `json:"repo-commit"`
+	// Refs of the repositories checked out by the job by repository, e.g.
+	// "kubernetes/test-infra": "master:5f4e5b1,123:c0ffee5"
+	Repos map[string]string `json:"repos"`
+}
+
+// Content of finished.json, uploaded when the job ends
+type prowFinished struct {
+	Timestamp *int64 `json:"timestamp"`
+	Passed    *bool  `json:"passed"`
+	Result    string `json:"result"`
+	Revision  string `json:"revision"`
+}
+
+type prowRefs struct {
+	Org     string `json:"org"`
+	Repo    string `json:"repo"`
+	BaseRef string `json:"base_ref"`
+	BaseSHA string `json:"base_sha"`
+	Pulls   []struct {
+		Number int    `json:"number"`
+		SHA    string `json:"sha"`
+		Ref    string `json:"ref"`
+	} `json:"pulls"`
+}
+
+// Content of prowjob.json, the definition of the job along with its status
+type prowJob struct {
+	Spec struct {
+		Job     string    `json:"job"`
+		Refs    *prowRefs `json:"refs"`
+		PodSpec *struct {
+			Containers []struct {
+				Name    string   `json:"name"`
+				Command []string `json:"command"`
+				Args    []string `json:"args"`
+				Env     []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"env"`
+			} `json:"containers"`
+		} `json:"pod_spec"`
+	} `json:"spec"`
+	Status struct {
+		StartTime      *time.Time `json:"startTime"`
+		CompletionTime *time.Time `json:"completionTime"`
+		State          string     `json:"state"`
+	} `json:"status"`
+}
+
+// Content of clone-records.json, the commands run to check out the repositories of the job
+type prowCloneRecord struct {
+	Refs     prowRefs `json:"refs"`
+	Commands []struct {
+		Command string `json:"command"`
+	} `json:"commands"`
+}
+
+// prowArtifacts gathers the artifacts describing a job. Artifacts not uploaded yet are nil, which
+// is the case of all artifacts until the pod of the job starts.
+type prowArtifacts struct {
+	job      *prowJob
+	started  *prowStarted
+	finished *prowFinished
+	clone    []prowCloneRecord
+}
+
+func (c ProwClient) fetchArtifacts(ctx context.Context, location prowJobLocation) (prowArtifacts, error) {
+	a := prowArtifacts{}
+	var job prowJob
+	if exists, err := c.getJSON(ctx, location, "prowjob.json", &job); err != nil {
+		return a, err
+	} else if exists {
+		a.job = &job
+	}
+	var started prowStarted
+	if exists, err := c.getJSON(ctx, location, "started.json", &started); err != nil {
+		return a, err
+	} else if exists {
+		a.started = &started
+	}
+	var finished prowFinished
+	if exists, err := c.getJSON(ctx, location, "finished.json", &finished); err != nil {
+		return a, err
+	} else if exists {
+		a.finished = &finished
+	}
+	if _, err := c.getJSON(ctx, location, "clone-records.json", &a.clone); err != nil {
+		return a, err
+	}
+
+	return a, nil
+}
+
+func (c ProwClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	location, buildID, err := parseProwURL(c.deckURL, u)
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	artifacts, err := c.fetchArtifacts(ctx, location)
+	if err != nil {
+		return cache.Build{}, err
+	}
+	c.mux.Lock()
+	c.jobs[buildID] = location
+	c.mux.Unlock()
+
+	return fromProwArtifacts(c.provider, u, location, buildID, artifacts), nil
+}
+
+// Return the state of a job. finished.json takes precedence over the status of prowjob.json
+// which may have been uploaded before the end of the job.
+func (a prowArtifacts) state() cache.State {
+	if f := a.finished; f != nil {
+		switch strings.ToUpper(f.Result) {
+		case "SUCCESS":
+			return cache.Passed
+		case "FAILURE", "ERROR":
+			return cache.Failed
+		case "ABORTED":
+			return cache.Canceled
+		}
+		if f.Passed != nil && *f.Passed {
+			return cache.Passed
+		}
+		return cache.Failed
+	}
+	if a.job != nil {
+		switch a.job.Status.State {
+		case "triggered":
+			return cache.Pending
+		case "pending":
+			return cache.Running
+		case "success":
+			return cache.Passed
+		case "failure", "error":
+			return cache.Failed
+		case "aborted":
+			return cache.Canceled
+		}
+	}
+	if a.started != nil {
+		return cache.Running
+	}
+	return cache.Pending
+}
+
+// Return the refs checked out by the job
+func (a prowArtifacts) refs() prowRefs {
+	if a.job != nil && a.job.Spec.Refs != nil {
+		return *a.job.Spec.Refs
+	}
+	if len(a.clone) > 0 {
+		return a.clone[0].Refs
+	}
+	return prowRefs{}
+}
+
+func fromProwArtifacts(provider cache.Provider, webURL string, location prowJobLocation, buildID string, a prowArtifacts) cache.Build {
+	refs := a.refs()
+	owner, name := refs.Org, refs.Repo
+	if owner == "" && a.started != nil {
+		// Fall back on the first repository listed by started.json
+		repos := make([]string, 0, len(a.started.Repos))
+		for repo := range a.started.Repos {
+			repos = append(repos, repo)
+		}
+		sort.Strings(repos)
+		if len(repos) > 0 {
+			if cs := strings.SplitN(repos[0], "/", 2); len(cs) == 2 {
+				owner, name = cs[0], cs[1]
+			}
+		}
+	}
+	repository := cache.Repository{
+		Provider: provider,
+		URL:      fmt.Sprintf("https://github.com/%s/%s", owner, name),
+		Owner:    owner,
+		Name:     name,
+	}
+
+	sha, ref := refs.BaseSHA, refs.BaseRef
+	if len(refs.Pulls) > 0 {
+		sha = refs.Pulls[0].SHA
+		ref = refs.Pulls[0].Ref
+		if ref == "" {
+			ref = fmt.Sprintf("pull/%d", refs.Pulls[0].Number)
+		}
+	}
+	if sha == "" && a.started != nil {
+		sha = a.started.RepoCommit
+	}
+	if sha == "" && a.finished != nil {
+		sha = a.finished.Revision
+	}
+
+	jobName := location.job
+	if a.job != nil && a.job.Spec.Job != "" {
+		jobName = a.job.Spec.Job
+	}
+
+	state := a.state()
+	var createdAt, startedAt, finishedAt utils.NullTime
+	if a.job != nil {
+		createdAt = utils.NullTimeFromTime(a.job.Status.StartTime)
+		finishedAt = utils.NullTimeFromTime(a.job.Status.CompletionTime)
+	}
+	if a.started != nil && a.started.Timestamp > 0 {
+		startedAt = utils.NullTime{Time: time.Unix(a.started.Timestamp, 0).UTC(), Valid: true}
+	}
+	if a.finished != nil && a.finished.Timestamp != nil {
+		finishedAt = utils.NullTime{Time: time.Unix(*a.finished.Timestamp, 0).UTC(), Valid: true}
+	}
+	if state.IsActive() {
+		finishedAt = utils.NullTime{}
+	}
+	createdAt = utils.MinNullTime(createdAt, startedAt)
+	duration := utils.NullSub(finishedAt, startedAt)
+
+	steps := make([]cache.Step, 0)
+	for _, record := range a.clone {
+		for _, command := range record.Commands {
+			steps = append(steps, cache.Step{
+				Name:    fmt.Sprintf("clone %s/%s", record.Refs.Org, record.Refs.Repo),
+				Command: command.Command,
+			})
+		}
+	}
+	variables := make(map[string]string)
+	if a.job != nil && a.job.Spec.PodSpec != nil {
+		for _, container := range a.job.Spec.PodSpec.Containers {
+			name := container.Name
+			if name == "" {
+				name = "test"
+			}
+			command := strings.Join(append(append([]string(nil), container.Command...), container.Args...), " ")
+			if command != "" {
+				steps = append(steps, cache.Step{Name: name, Command: command})
+			}
+			for _, env := range container.Env {
+				variables[env.Name] = env.Value
+			}
+		}
+	}
+
+	job := cache.Job{
+		ID:         buildID,
+		State:      state,
+		Name:       jobName,
+		CreatedAt:  createdAt,
+		StartedAt:  startedAt,
+		FinishedAt: finishedAt,
+		Duration:   duration,
+		WebURL:     webURL,
+		Steps:      steps,
+		Variables:  sortedVariables(variables),
+	}
+
+	return cache.Build{
+		Repository: &repository,
+		ID:         buildID,
+		Commit: cache.Commit{
+			Sha: sha,
+		},
+		Ref:        ref,
+		State:      state,
+		CreatedAt:  createdAt,
+		StartedAt:  startedAt,
+		FinishedAt: finishedAt,
+		UpdatedAt:  utils.MaxNullTime(createdAt, startedAt, finishedAt).Time,
+		Duration:   duration,
+		WebURL:     webURL,
+		Stages:     map[int]*cache.Stage{},
+		Jobs:       []*cache.Job{&job},
+	}
+}
+
+// Log returns the log of the job. The log is uploaded to the storage at the end of the job so
+// the log of a running job is read from Deck instead.
+func (c ProwClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	c.mux.Lock()
+	location, exists := c.jobs[jobID]
+	c.mux.Unlock()
+	if !exists {
+		return "", fmt.Errorf("unknown Prow job %q", jobID)
+	}
+
+	log, err := c.get(ctx, location, "build-log.txt")
+	if httpErr, ok := err.(HTTPError); ok && httpErr.Status == 404 {
+		u := c.deckURL
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/log"
+		u.RawQuery = url.Values{"job": {location.job}, "id": {jobID}}.Encode()
+		log, err = c.getURL(ctx, u)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(log), nil
+}
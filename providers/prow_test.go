@@ -0,0 +1,189 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func TestParseProwURL(t *testing.T) {
+	deckURL := url.URL{Scheme: "https", Host: "prow.k8s.io"}
+
+	t.Run("Spyglass URLs", func(t *testing.T) {
+		urls := []string{
+			"https://prow.k8s.io/view/gs/kubernetes-jenkins/pr-logs/pull/test-infra/15532/pull-test-infra-bazel/1204361226364178432",
+			"https://prow.k8s.io/view/gcs/kubernetes-jenkins/pr-logs/pull/test-infra/15532/pull-test-infra-bazel/1204361226364178432/",
+		}
+		expected := prowJobLocation{
+			artifacts: "kubernetes-jenkins/pr-logs/pull/test-infra/15532/pull-test-infra-bazel/1204361226364178432",
+			job:       "pull-test-infra-bazel",
+		}
+		for _, u := range urls {
+			location, buildID, err := parseProwURL(deckURL, u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buildID != "1204361226364178432" {
+				t.Fatalf("unexpected build ID %q", buildID)
+			}
+			if diff := cmp.Diff(expected, location, cmp.AllowUnexported(prowJobLocation{})); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		}
+	})
+
+	t.Run("Unknown URLs", func(t *testing.T) {
+		urls := []string{
+			"https://gitlab.com/nbedos/citop/pipelines/97604657",
+			"https://prow.k8s.io/pr-history/?org=kubernetes&repo=test-infra&pr=15532",
+			"https://prow.k8s.io/view/gs/kubernetes-jenkins/logs",
+			"https://prow.k8s.io/view/gs/kubernetes-jenkins/logs/ci-job/latest",
+		}
+		for _, u := range urls {
+			if _, _, err := parseProwURL(deckURL, u); err != cache.ErrUnknownURL {
+				t.Fatalf("expected %v but got %v for URL %q", cache.ErrUnknownURL, err, u)
+			}
+		}
+	})
+}
+
+// Return a server acting both as Deck and as the storage of the artifacts of two jobs, one
+// finished and one running
+func newProwTestServer() *httptest.Server {
+	const finished = "/storage/kubernetes-jenkins/pr-logs/pull/test-infra/15532/pull-test-infra-bazel/1204361226364178432/"
+	const running = "/storage/kubernetes-jenkins/pr-logs/pull/test-infra/15532/pull-test-infra-bazel/1204361226364178433/"
+	files := map[string]string{
+		finished + "prowjob.json":       "prow_prowjob.json",
+		finished + "started.json":       "prow_started.json",
+		finished + "finished.json":      "prow_finished.json",
+		finished + "clone-records.json": "prow_clone_records.json",
+		finished + "build-log.txt":      "prow_build_log.txt",
+		running + "prowjob.json":        "prow_prowjob.json",
+		running + "started.json":        "prow_started.json",
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/log" {
+			fmt.Fprintf(w, "live log of %s #%s\n", r.URL.Query().Get("job"), r.URL.Query().Get("id"))
+			return
+		}
+		filename, exists := files[r.URL.Path]
+		if !exists {
+			w.WriteHeader(404)
+			return
+		}
+		bs, err := ioutil.ReadFile(fmt.Sprintf("test_data/%s", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		w.Write(bs)
+	}))
+}
+
+func TestProwClient_BuildFromURL(t *testing.T) {
+	ts := newProwTestServer()
+	defer ts.Close()
+
+	deckURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageURL := *deckURL
+	storageURL.Path = "/storage"
+	client := NewProwClient("prow", "prow", *deckURL, storageURL, time.Millisecond)
+	ctx := context.Background()
+	jobURL := ts.URL + "/view/gs/kubernetes-jenkins/pr-logs/pull/test-infra/15532/pull-test-infra-bazel/"
+
+	t.Run("Finished job", func(t *testing.T) {
+		build, err := client.BuildFromURL(ctx, jobURL+"1204361226364178432")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		createdAt := utils.NullTime{Time: time.Date(2019, 12, 10, 10, 0, 0, 0, time.UTC), Valid: true}
+		startedAt := utils.NullTime{Time: time.Date(2019, 12, 10, 10, 0, 5, 0, time.UTC), Valid: true}
+		finishedAt := utils.NullTime{Time: time.Date(2019, 12, 10, 10, 10, 5, 0, time.UTC), Valid: true}
+		duration := utils.NullDuration{Duration: 10 * time.Minute, Valid: true}
+		expected := cache.Build{
+			Repository: &cache.Repository{
+				Provider: cache.Provider{ID: "prow", Name: "prow"},
+				URL:      "https://github.com/kubernetes/test-infra",
+				Owner:    "kubernetes",
+				Name:     "test-infra",
+			},
+			ID:         "1204361226364178432",
+			Commit:     cache.Commit{Sha: "c0ffee5d3c4a5e6f7a8b9c0d1e2f3a4b5c6d7e8f"},
+			Ref:        "pull/15532",
+			State:      cache.Failed,
+			CreatedAt:  createdAt,
+			StartedAt:  startedAt,
+			FinishedAt: finishedAt,
+			UpdatedAt:  finishedAt.Time,
+			Duration:   duration,
+			WebURL:     jobURL + "1204361226364178432",
+			Stages:     map[int]*cache.Stage{},
+			Jobs: []*cache.Job{
+				{
+					ID:         "1204361226364178432",
+					State:      cache.Failed,
+					Name:       "pull-test-infra-bazel",
+					CreatedAt:  createdAt,
+					StartedAt:  startedAt,
+					FinishedAt: finishedAt,
+					Duration:   duration,
+					WebURL:     jobURL + "1204361226364178432",
+					Steps: []cache.Step{
+						{Name: "clone kubernetes/test-infra", Command: "/usr/bin/git init"},
+						{Name: "clone kubernetes/test-infra", Command: "/usr/bin/git fetch https://github.com/kubernetes/test-infra.git master"},
+						{Name: "test", Command: "hack/bazel.sh test //..."},
+					},
+					Variables: []cache.Variable{
+						{Name: "BAZEL_VERSION", Value: "0.29.1"},
+					},
+				},
+			},
+		}
+		if diff := cmp.Diff(expected, build); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+
+		log, err := client.Log(ctx, *build.Repository, build.Jobs[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(log, "FAIL: //prow/cmd/deck:go_default_test") {
+			t.Fatalf("unexpected log %q", log)
+		}
+	})
+
+	t.Run("Running job", func(t *testing.T) {
+		build, err := client.BuildFromURL(ctx, jobURL+"1204361226364178433")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if build.State != cache.Running || build.FinishedAt.Valid {
+			t.Fatalf("expected a running job but got state %q", build.State)
+		}
+
+		// The log of a running job is read from Deck
+		log, err := client.Log(ctx, *build.Repository, build.Jobs[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := "live log of pull-test-infra-bazel #1204361226364178433\n"; log != expected {
+			t.Fatalf("expected log %q but got %q", expected, log)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package providers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Query parameters carrying credentials. Their values are never written to disk.
+var secretParameters = []string{"access_token", "circle-token", "private_token", "token"}
+
+// recordedResponse is the content of the file storing a response received from a provider
+type recordedResponse struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// Return the URL of the request without credentials
+func redactedURL(u url.URL) string {
+	parameters := u.Query()
+	for _, name := range secretParameters {
+		if _, exists := parameters[name]; exists {
+			parameters.Set(name, "REDACTED")
+		}
+	}
+	u.RawQuery = parameters.Encode()
+	u.User = nil
+	return u.String()
+}
+
+// Return the prefix of the name of the files storing the responses to requests sent with
+// 'method' to 'u'
+func recordingKey(method string, u url.URL) string {
+	sum := sha256.Sum256([]byte(method + " " + redactedURL(u)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Return the path of the file storing the response number 'n' to the requests designated by 'key'
+func recordingPath(dir string, key string, n int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s-%04d.json", key, n))
+}
+
+// RecordingTransport sends requests with a base transport and saves every response in a
+// directory. Responses to successive requests for the same URL are saved in distinct files so
+// that replaying them reproduces the progression of pipelines.
+type RecordingTransport struct {
+	dir    string
+	base   http.RoundTripper
+	mutex  *sync.Mutex
+	counts map[string]int
+}
+
+func NewRecordingTransport(dir string, base http.RoundTripper) RecordingTransport {
+	return RecordingTransport{
+		dir:    dir,
+		base:   base,
+		mutex:  &sync.Mutex{},
+		counts: make(map[string]int),
+	}
+}
+
+func (t RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	header := make(http.Header, len(resp.Header))
+	for key, values := range resp.Header {
+		if key != "Set-Cookie" {
+			header[key] = values
+		}
+	}
+	recorded := recordedResponse{
+		Method:     req.Method,
+		URL:        redactedURL(*req.URL),
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		Body:       body,
+	}
+	bs, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	key := recordingKey(req.Method, *req.URL)
+	t.mutex.Lock()
+	n := t.counts[key]
+	t.counts[key]++
+	t.mutex.Unlock()
+	if err := ioutil.WriteFile(recordingPath(t.dir, key, n), bs, 0600); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// ReplayTransport answers requests with the responses saved by a RecordingTransport without
+// sending anything over the network. Successive requests for the same URL receive the
+// responses in the order they were recorded, and the last response once all of them have been
+// served.
+type ReplayTransport struct {
+	dir    string
+	mutex  *sync.Mutex
+	counts map[string]int
+}
+
+func NewReplayTransport(dir string) ReplayTransport {
+	return ReplayTransport{
+		dir:    dir,
+		mutex:  &sync.Mutex{},
+		counts: make(map[string]int),
+	}
+}
+
+func (t ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	key := recordingKey(req.Method, *req.URL)
+	t.mutex.Lock()
+	n := t.counts[key]
+	bs, err := ioutil.ReadFile(recordingPath(t.dir, key, n))
+	if err == nil {
+		t.counts[key]++
+	} else if os.IsNotExist(err) && n > 0 {
+		bs, err = ioutil.ReadFile(recordingPath(t.dir, key, n-1))
+	}
+	t.mutex.Unlock()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no recorded response for %s %s", req.Method, redactedURL(*req.URL))
+		}
+		return nil, err
+	}
+
+	var recorded recordedResponse
+	if err := json.Unmarshal(bs, &recorded); err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
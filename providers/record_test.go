@@ -0,0 +1,85 @@
+package providers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.Header().Set("Set-Cookie", "session=secret")
+		fmt.Fprintf(w, "response %d", n)
+	}))
+	defer ts.Close()
+
+	get := func(client *http.Client, u string) (string, error) {
+		resp, err := client.Get(u)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		bs, err := ioutil.ReadAll(resp.Body)
+		return string(bs), err
+	}
+
+	u := ts.URL + "/builds?circle-token=secret"
+	recorder := newHTTPClient(requestTimeout, []ClientOption{WithTransport(NewRecordingTransport(dir, http.DefaultTransport))})
+	for i := 1; i <= 2; i++ {
+		body, err := get(recorder, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprintf("response %d", i); body != expected {
+			t.Fatalf("expected %q but got %q", expected, body)
+		}
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 files in %s but got %d", dir, len(entries))
+	}
+	for _, entry := range entries {
+		bs, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(bs), "secret") {
+			t.Fatalf("credentials found in %s:\n%s", entry.Name(), string(bs))
+		}
+	}
+
+	// The server must not be reached by the replay
+	ts.Close()
+	replayer := newHTTPClient(requestTimeout, []ClientOption{WithTransport(NewReplayTransport(dir))})
+	for _, expected := range []string{"response 1", "response 2", "response 2"} {
+		// The token used during the replay does not have to match the one recorded
+		body, err := get(replayer, ts.URL+"/builds?circle-token=other")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != expected {
+			t.Fatalf("expected %q but got %q", expected, body)
+		}
+	}
+
+	if _, err := get(replayer, ts.URL+"/unknown"); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+}
@@ -0,0 +1,44 @@
+package providers
+
+import (
+	"path"
+	"strings"
+)
+
+// RepositoryPatterns restricts a provider to some repositories designated by patterns matching
+// "owner/name", such as "nbedos/citop" or "acme/*" (see path.Match for the syntax of patterns).
+// Repositories matching one of the patterns of Except are excluded. Other repositories are
+// included if Only is empty or if they match one of its patterns. Case is ignored.
+type RepositoryPatterns struct {
+	Only   []string
+	Except []string
+}
+
+// Return true if the repository "owner/name" matches one of 'patterns'
+func matchRepository(patterns []string, owner string, name string) bool {
+	repository := strings.ToLower(owner + "/" + name)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), repository); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns true if the repository 'name' of 'owner' is included
+func (p RepositoryPatterns) Match(owner string, name string) bool {
+	if matchRepository(p.Except, owner, name) {
+		return false
+	}
+	return len(p.Only) == 0 || matchRepository(p.Only, owner, name)
+}
+
+// repositoryRestriction is embedded by clients to implement cache.RepositoryMatcher
+type repositoryRestriction struct {
+	repositoryPatterns RepositoryPatterns
+}
+
+// MatchesRepository returns true if the client is used for the repository 'name' of 'owner'
+func (r repositoryRestriction) MatchesRepository(owner string, name string) bool {
+	return r.repositoryPatterns.Match(owner, name)
+}
@@ -0,0 +1,27 @@
+package providers
+
+import "testing"
+
+func TestRepositoryPatterns_Match(t *testing.T) {
+	testCases := []struct {
+		patterns RepositoryPatterns
+		owner    string
+		name     string
+		expected bool
+	}{
+		{RepositoryPatterns{}, "nbedos", "citop", true},
+		{RepositoryPatterns{Only: []string{"nbedos/citop"}}, "nbedos", "citop", true},
+		{RepositoryPatterns{Only: []string{"nbedos/citop"}}, "NBedos", "Citop", true},
+		{RepositoryPatterns{Only: []string{"nbedos/citop"}}, "nbedos", "other", false},
+		{RepositoryPatterns{Only: []string{"acme/*"}}, "acme", "web", true},
+		{RepositoryPatterns{Only: []string{"acme/*"}}, "acme-labs", "web", false},
+		{RepositoryPatterns{Except: []string{"acme/legacy-*"}}, "acme", "legacy-api", false},
+		{RepositoryPatterns{Except: []string{"acme/legacy-*"}}, "acme", "api", true},
+		{RepositoryPatterns{Only: []string{"acme/*"}, Except: []string{"acme/web"}}, "acme", "web", false},
+	}
+	for _, testCase := range testCases {
+		if matched := testCase.patterns.Match(testCase.owner, testCase.name); matched != testCase.expected {
+			t.Fatalf("%v.Match(%q, %q): expected %v but got %v", testCase.patterns, testCase.owner, testCase.name, testCase.expected, matched)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// SemaphoreClient reads the workflows of an organization of Semaphore 2.0. Workflows are found
+// through the commit statuses reported by Semaphore to GitHub or Bitbucket. The initial pipeline
+// of a workflow, or the pipeline designated by the web URL, is shown as a pipeline whose blocks
+// are stages.
+type SemaphoreClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    cache.Provider
+	// Repositories of the projects of the organization by project identifier
+	repositories map[string]cache.Repository
+	mux          *sync.Mutex
+	repositoryRestriction
+}
+
+// NewSemaphoreClient returns a client for the organization whose web interface is at 'baseURL',
+// e.g. https://example.semaphoreci.com
+func NewSemaphoreClient(id string, name string, token string, baseURL url.URL, rateLimit time.Duration, options ...ClientOption) SemaphoreClient {
+	return SemaphoreClient{
+		baseURL:     baseURL,
+		httpClient:  newHTTPClient(requestTimeout, options),
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+		repositories: make(map[string]cache.Repository),
+		mux:          &sync.Mutex{},
+	}
+}
+
+func (c SemaphoreClient) ID() string {
+	return c.provider.ID
+}
+
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c SemaphoreClient) WithRepositories(repositories RepositoryPatterns) SemaphoreClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// Send a GET request to the endpoint 'path' of the API and decode the response into 'v'
+func (c SemaphoreClient) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	u := c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v1alpha" + path
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Token %s", c.token))
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: body.String(),
+		}
+	}
+
+	return json.Unmarshal(body.Bytes(), v)
+}
+
+// CheckAuthentication returns an error if Semaphore rejects the API token
+func (c SemaphoreClient) CheckAuthentication(ctx context.Context) error {
+	var projects []semaphoreProject
+	return c.getJSON(ctx, "/projects", nil, &projects)
+}
+
+// Extract the identifiers of the workflow and of the pipeline from the web URL of a workflow.
+// The pipeline identifier is empty if the URL designates the initial pipeline.
+func (c SemaphoreClient) parseWebURL(u string) (string, string, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return "", "", err
+	}
+	if v.Hostname() != c.baseURL.Hostname() {
+		return "", "", cache.ErrUnknownURL
+	}
+
+	// URL format: https://example.semaphoreci.com/workflows/<wf_id>?pipeline_id=<ppl_id>
+	cs := strings.Split(strings.Trim(v.EscapedPath(), "/"), "/")
+	if len(cs) != 2 || cs[0] != "workflows" || cs[1] == "" {
+		return "", "", cache.ErrUnknownURL
+	}
+
+	return cs[1], v.Query().Get("pipeline_id"), nil
+}
+
+func (c SemaphoreClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	workflowID, pipelineID, err := c.parseWebURL(u)
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	if pipelineID == "" {
+		var workflow struct {
+			Workflow struct {
+				InitialPipelineID string `json:"initial_ppl_id"`
+			} `json:"workflow"`
+		}
+		if err := c.getJSON(ctx, "/plumber-workflows/"+url.PathEscape(workflowID), nil, &workflow); err != nil {
+			return cache.Build{}, err
+		}
+		pipelineID = workflow.Workflow.InitialPipelineID
+	}
+
+	var pipeline semaphorePipeline
+	query := url.Values{"detailed": []string{"true"}}
+	if err := c.getJSON(ctx, "/pipelines/"+url.PathEscape(pipelineID), query, &pipeline); err != nil {
+		return cache.Build{}, err
+	}
+
+	repository, err := c.repository(ctx, pipeline.Pipeline.ProjectID)
+	if err != nil {
+		return cache.Build{}, err
+	}
+
+	jobs := make(map[string]semaphoreJob)
+	for _, block := range pipeline.Blocks {
+		for _, job := range block.Jobs {
+			var j semaphoreJob
+			if err := c.getJSON(ctx, "/jobs/"+url.PathEscape(job.ID), nil, &j); err != nil {
+				return cache.Build{}, err
+			}
+			jobs[job.ID] = j
+		}
+	}
+
+	return pipeline.toCacheBuild(&repository, c.baseURL, jobs), nil
+}
+
+// Return the repository of the project identified by 'projectID'
+func (c SemaphoreClient) repository(ctx context.Context, projectID string) (cache.Repository, error) {
+	c.mux.Lock()
+	repository, exists := c.repositories[projectID]
+	c.mux.Unlock()
+	if exists {
+		return repository, nil
+	}
+
+	var projects []semaphoreProject
+	if err := c.getJSON(ctx, "/projects", nil, &projects); err != nil {
+		return cache.Repository{}, err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for _, p := range projects {
+		repository := cache.Repository{
+			Provider: c.provider,
+			URL:      p.Spec.Repository.URL,
+			Name:     p.Metadata.Name,
+		}
+		if _, owner, name, err := utils.RepoHostOwnerAndName(p.Spec.Repository.URL); err == nil {
+			repository.Owner, repository.Name = owner, name
+		}
+		c.repositories[p.Metadata.ID] = repository
+	}
+
+	repository, exists = c.repositories[projectID]
+	if !exists {
+		return repository, cache.ErrRepositoryNotFound
+	}
+	return repository, nil
+}
+
+// Log returns the output of the commands run by the job
+func (c SemaphoreClient) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	var logs struct {
+		Events []struct {
+			Event     string `json:"event"`
+			Directive string `json:"directive"`
+			Output    string `json:"output"`
+			ExitCode  int    `json:"exit_code"`
+		} `json:"events"`
+	}
+	if err := c.getJSON(ctx, "/logs/"+url.PathEscape(jobID), nil, &logs); err != nil {
+		return "", err
+	}
+
+	b := strings.Builder{}
+	for _, event := range logs.Events {
+		switch event.Event {
+		case "cmd_started":
+			fmt.Fprintf(&b, "$ %s\n", event.Directive)
+		case "cmd_output":
+			b.WriteString(event.Output)
+		case "cmd_finished":
+			if event.ExitCode != 0 {
+				fmt.Fprintf(&b, "exit code %d\n", event.ExitCode)
+			}
+		}
+	}
+
+	return b.String(), nil
+}
+
+// Return the state of a pipeline, a block or a job
+func fromSemaphoreState(state string, result string) cache.State {
+	switch strings.ToUpper(state) {
+	case "INITIALIZING", "PENDING", "QUEUING", "QUEUED", "WAITING", "ENQUEUED", "SCHEDULED":
+		return cache.Pending
+	case "RUNNING", "STOPPING":
+		return cache.Running
+	case "DONE", "FINISHED":
+		switch strings.ToUpper(result) {
+		case "PASSED":
+			return cache.Passed
+		case "FAILED":
+			return cache.Failed
+		case "STOPPED", "CANCELED":
+			return cache.Canceled
+		}
+	}
+	return cache.Unknown
+}
+
+type semaphoreProject struct {
+	Metadata struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Repository struct {
+			URL string `json:"url"`
+		} `json:"repository"`
+	} `json:"spec"`
+}
+
+// Timestamp of the API of pipelines
+type semaphoreTimestamp struct {
+	Seconds int64 `json:"seconds"`
+	Nanos   int64 `json:"nanos"`
+}
+
+func (t semaphoreTimestamp) NullTime() utils.NullTime {
+	if t.Seconds == 0 && t.Nanos == 0 {
+		return utils.NullTime{}
+	}
+	return utils.NullTime{Time: time.Unix(t.Seconds, t.Nanos).UTC(), Valid: true}
+}
+
+type semaphorePipeline struct {
+	Pipeline struct {
+		ID         string             `json:"ppl_id"`
+		WorkflowID string             `json:"wf_id"`
+		ProjectID  string             `json:"project_id"`
+		Name       string             `json:"name"`
+		BranchName string             `json:"branch_name"`
+		CommitSha  string             `json:"commit_sha"`
+		State      string             `json:"state"`
+		Result     string             `json:"result"`
+		CreatedAt  semaphoreTimestamp `json:"created_at"`
+		RunningAt  semaphoreTimestamp `json:"running_at"`
+		StoppingAt semaphoreTimestamp `json:"stopping_at"`
+		DoneAt     semaphoreTimestamp `json:"done_at"`
+	} `json:"pipeline"`
+	Blocks []struct {
+		ID     string `json:"block_id"`
+		Name   string `json:"name"`
+		State  string `json:"state"`
+		Result string `json:"result"`
+		Jobs   []struct {
+			ID     string `json:"job_id"`
+			Name   string `json:"name"`
+			Index  int    `json:"index"`
+			Status string `json:"status"`
+			Result string `json:"result"`
+		} `json:"jobs"`
+	} `json:"blocks"`
+}
+
+// Details of a job returned by the API of jobs. Times are Unix timestamps.
+type semaphoreJob struct {
+	Metadata struct {
+		CreateTime string `json:"create_time"`
+		StartTime  string `json:"start_time"`
+		FinishTime string `json:"finish_time"`
+	} `json:"metadata"`
+	Spec struct {
+		Commands []string `json:"commands"`
+		EnvVars  []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"env_vars"`
+		Agent struct {
+			Machine struct {
+				Type    string `json:"type"`
+				OSImage string `json:"os_image"`
+			} `json:"machine"`
+		} `json:"agent"`
+	} `json:"spec"`
+}
+
+func semaphoreTime(s string) utils.NullTime {
+	seconds, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || seconds == 0 {
+		return utils.NullTime{}
+	}
+	return utils.NullTime{Time: time.Unix(seconds, 0).UTC(), Valid: true}
+}
+
+// Return the pipeline as a build whose stages are the blocks of the pipeline. 'jobs' holds the
+// details of the jobs of the pipeline by job identifier.
+func (p semaphorePipeline) toCacheBuild(repository *cache.Repository, baseURL url.URL, jobs map[string]semaphoreJob) cache.Build {
+	webURL := baseURL
+	webURL.Path = strings.TrimSuffix(webURL.Path, "/") + "/workflows/" + p.Pipeline.WorkflowID
+	webURL.RawQuery = url.Values{"pipeline_id": []string{p.Pipeline.ID}}.Encode()
+
+	build := cache.Build{
+		Repository:      repository,
+		ID:              p.Pipeline.ID,
+		Commit:          cache.Commit{Sha: p.Pipeline.CommitSha},
+		Ref:             p.Pipeline.BranchName,
+		RepoBuildNumber: p.Pipeline.Name,
+		State:           fromSemaphoreState(p.Pipeline.State, p.Pipeline.Result),
+		CreatedAt:       p.Pipeline.CreatedAt.NullTime(),
+		StartedAt:       p.Pipeline.RunningAt.NullTime(),
+		FinishedAt:      p.Pipeline.DoneAt.NullTime(),
+		WebURL:          webURL.String(),
+		Stages:          make(map[int]*cache.Stage),
+	}
+	if strings.HasPrefix(build.Ref, "refs/tags/") {
+		build.Ref, build.IsTag = strings.TrimPrefix(build.Ref, "refs/tags/"), true
+	}
+	build.UpdatedAt = utils.MaxNullTime(build.CreatedAt, build.StartedAt,
+		p.Pipeline.StoppingAt.NullTime(), build.FinishedAt).Time
+	build.Duration = utils.NullSub(build.FinishedAt, build.StartedAt)
+
+	for i, block := range p.Blocks {
+		stage := cache.Stage{
+			ID:    i + 1,
+			Name:  block.Name,
+			State: fromSemaphoreState(block.State, block.Result),
+		}
+		for _, j := range block.Jobs {
+			details := jobs[j.ID]
+			jobURL := baseURL
+			jobURL.Path = strings.TrimSuffix(jobURL.Path, "/") + "/jobs/" + j.ID
+			job := cache.Job{
+				ID:         j.ID,
+				State:      fromSemaphoreState(j.Status, j.Result),
+				Name:       j.Name,
+				CreatedAt:  semaphoreTime(details.Metadata.CreateTime),
+				StartedAt:  semaphoreTime(details.Metadata.StartTime),
+				FinishedAt: semaphoreTime(details.Metadata.FinishTime),
+				WebURL:     jobURL.String(),
+				OS:         details.Spec.Agent.Machine.OSImage,
+			}
+			job.Duration = utils.NullSub(job.FinishedAt, job.StartedAt)
+			for _, command := range details.Spec.Commands {
+				job.Steps = append(job.Steps, cache.Step{Command: command})
+			}
+			for _, v := range details.Spec.EnvVars {
+				job.Variables = append(job.Variables, cache.Variable{Name: v.Name, Value: v.Value})
+			}
+			stage.Jobs = append(stage.Jobs, &job)
+		}
+		build.Stages[stage.ID] = &stage
+	}
+
+	return build
+}
@@ -0,0 +1,147 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func newSemaphoreTestServer(t *testing.T) *httptest.Server {
+	files := map[string]string{
+		"/api/v1alpha/projects": "semaphore_projects.json",
+		"/api/v1alpha/plumber-workflows/a6b7c8d9-e0f1-4a2b-8c3d-4e5f6a7b8c9d": "semaphore_workflow.json",
+		"/api/v1alpha/pipelines/1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d":         "semaphore_pipeline.json",
+		"/api/v1alpha/jobs/2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e":              "semaphore_job.json",
+		"/api/v1alpha/logs/2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e":              "semaphore_logs.json",
+	}
+
+	return newFixtureServer(t, files, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Token token" {
+			w.WriteHeader(401)
+			return true
+		}
+		return false
+	})
+}
+
+func TestSemaphoreClient_BuildFromURL(t *testing.T) {
+	ts := newSemaphoreTestServer(t)
+	defer ts.Close()
+
+	baseURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewSemaphoreClient("semaphore", "semaphore", "token", *baseURL, time.Millisecond)
+	ctx := context.Background()
+
+	date := func(seconds int64) utils.NullTime {
+		return utils.NullTime{Time: time.Unix(1580724000+seconds, 0).UTC(), Valid: true}
+	}
+	duration := func(seconds int64) utils.NullDuration {
+		return utils.NullDuration{Duration: time.Duration(seconds) * time.Second, Valid: true}
+	}
+	webURL := ts.URL + "/workflows/a6b7c8d9-e0f1-4a2b-8c3d-4e5f6a7b8c9d?pipeline_id=1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d"
+	expected := cache.Build{
+		Repository: &cache.Repository{
+			Provider: cache.Provider{ID: "semaphore", Name: "semaphore"},
+			URL:      "git@github.com:nbedos/citop.git",
+			Owner:    "nbedos",
+			Name:     "citop",
+		},
+		ID:              "1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d",
+		Commit:          cache.Commit{Sha: "a24840cf94b395af69da4a1001d32e3694637e20"},
+		Ref:             "master",
+		RepoBuildNumber: "Pipeline",
+		State:           cache.Failed,
+		CreatedAt:       date(0),
+		StartedAt:       date(2),
+		FinishedAt:      date(240),
+		UpdatedAt:       date(240).Time,
+		Duration:        duration(238),
+		WebURL:          webURL,
+		Stages: map[int]*cache.Stage{
+			1: {
+				ID:    1,
+				Name:  "Tests",
+				State: cache.Failed,
+				Jobs: []*cache.Job{
+					{
+						ID:         "2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e",
+						State:      cache.Failed,
+						Name:       "go test",
+						CreatedAt:  date(3),
+						StartedAt:  date(10),
+						FinishedAt: date(240),
+						Duration:   duration(230),
+						WebURL:     ts.URL + "/jobs/2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e",
+						Steps:      []cache.Step{{Command: "checkout"}, {Command: "go test ./..."}},
+						Variables:  []cache.Variable{{Name: "GO111MODULE", Value: "on"}},
+						OS:         "ubuntu1804",
+					},
+				},
+			},
+		},
+	}
+
+	// The status reported to GitHub designates the workflow, the initial pipeline is shown
+	for _, u := range []string{ts.URL + "/workflows/a6b7c8d9-e0f1-4a2b-8c3d-4e5f6a7b8c9d", webURL} {
+		t.Run(u, func(t *testing.T) {
+			build, err := client.BuildFromURL(ctx, u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(expected, build); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("unknown URL", func(t *testing.T) {
+		for _, u := range []string{
+			"https://other.semaphoreci.com/workflows/a6b7c8d9-e0f1-4a2b-8c3d-4e5f6a7b8c9d",
+			ts.URL + "/projects/citop",
+		} {
+			if _, err := client.BuildFromURL(ctx, u); err != cache.ErrUnknownURL {
+				t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+			}
+		}
+	})
+
+	t.Run("log", func(t *testing.T) {
+		log, err := client.Log(ctx, *expected.Repository, "2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedLog := "$ go test ./...\nok  \tgithub.com/nbedos/citop/cache\t0.021s\n--- FAIL: TestGitOriginURL (0.00s)\nexit code 1\n"
+		if log != expectedLog {
+			t.Fatalf("expected log %q but got %q", expectedLog, log)
+		}
+	})
+}
+
+func TestSemaphoreClient_CheckAuthentication(t *testing.T) {
+	ts := newSemaphoreTestServer(t)
+	defer ts.Close()
+
+	baseURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{"token", "invalid"} {
+		t.Run(token, func(t *testing.T) {
+			client := NewSemaphoreClient("id", "name", token, *baseURL, time.Millisecond)
+			err := client.CheckAuthentication(context.Background())
+			if valid := token == "token"; valid != (err == nil) {
+				t.Fatalf("unexpected result for %s token: %v", token, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// JSON endpoints of the status pages of online services. GitHub, Travis CI and CircleCI use
+// Atlassian Statuspage and GitLab uses status.io.
+const (
+	GitHubStatusURL   = "https://www.githubstatus.com/api/v2/status.json"
+	GitLabStatusURL   = "https://api.status.io/1.0/status/5b36dc6502d06804c08349f7"
+	TravisStatusURL   = "https://www.traviscistatus.com/api/v2/status.json"
+	CircleCIStatusURL = "https://status.circleci.com/api/v2/status.json"
+)
+
+var ErrUnknownStatusFormat = errors.New("unknown format of status page")
+
+// StatusPage is the public page reporting incidents affecting the online service of a provider
+type StatusPage struct {
+	name       string
+	url        string
+	httpClient *http.Client
+}
+
+// NewStatusPage returns the status page of the service 'name' whose JSON endpoint is 'u'
+func NewStatusPage(name string, u string, transport http.RoundTripper) StatusPage {
+	return StatusPage{
+		name:       name,
+		url:        u,
+		httpClient: newHTTPClient(requestTimeout, []ClientOption{WithTransport(transport)}),
+	}
+}
+
+func (p StatusPage) Name() string {
+	return p.name
+}
+
+// Response of the status endpoint of Atlassian Statuspage (/api/v2/status.json)
+type statuspageStatus struct {
+	Status *struct {
+		Indicator   string `json:"indicator"`
+		Description string `json:"description"`
+	} `json:"status"`
+}
+
+// Response of the status endpoint of status.io (/1.0/status/{page})
+type statusioStatus struct {
+	Result *struct {
+		StatusOverall struct {
+			Status     string `json:"status"`
+			StatusCode int    `json:"status_code"`
+		} `json:"status_overall"`
+	} `json:"result"`
+}
+
+// Status code of status.io meaning that all systems are operational
+const statusioOperational = 100
+
+// Return the description of the incident reported by the body of the response of a status
+// endpoint, or an empty string if the service is operational
+func parseStatus(body []byte) (string, error) {
+	var statuspage statuspageStatus
+	if err := json.Unmarshal(body, &statuspage); err != nil {
+		return "", err
+	}
+	if s := statuspage.Status; s != nil {
+		if s.Indicator == "none" || s.Indicator == "" {
+			return "", nil
+		}
+		return s.Description, nil
+	}
+
+	var statusio statusioStatus
+	if err := json.Unmarshal(body, &statusio); err != nil {
+		return "", err
+	}
+	if r := statusio.Result; r != nil {
+		if r.StatusOverall.StatusCode == statusioOperational {
+			return "", nil
+		}
+		return r.StatusOverall.Status, nil
+	}
+
+	return "", ErrUnknownStatusFormat
+}
+
+// Incident returns the description of the incident reported by the status page, or an empty
+// string if the service is operational
+func (p StatusPage) Incident(ctx context.Context) (string, error) {
+	req, err := http.NewRequest("GET", p.url, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: body.String(),
+		}
+	}
+
+	return parseStatus(body.Bytes())
+}
@@ -0,0 +1,82 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "statuspage operational",
+			body:     `{"page":{"id":"kctbh9vrtdwd"},"status":{"indicator":"none","description":"All Systems Operational"}}`,
+			expected: "",
+		},
+		{
+			name:     "statuspage incident",
+			body:     `{"page":{"id":"kctbh9vrtdwd"},"status":{"indicator":"minor","description":"Partial System Outage"}}`,
+			expected: "Partial System Outage",
+		},
+		{
+			name:     "status.io operational",
+			body:     `{"result":{"status_overall":{"updated":"2020-01-15T10:00:00.000Z","status":"Operational","status_code":100}}}`,
+			expected: "",
+		},
+		{
+			name:     "status.io incident",
+			body:     `{"result":{"status_overall":{"updated":"2020-01-15T10:00:00.000Z","status":"Degraded Performance","status_code":300}}}`,
+			expected: "Degraded Performance",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			description, err := parseStatus([]byte(testCase.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if description != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, description)
+			}
+		})
+	}
+
+	t.Run("unknown format", func(t *testing.T) {
+		if _, err := parseStatus([]byte(`{"health":"healthy"}`)); err != ErrUnknownStatusFormat {
+			t.Fatalf("expected %v but got %v", ErrUnknownStatusFormat, err)
+		}
+	})
+}
+
+func TestStatusPage_Incident(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/status.json":
+			fmt.Fprint(w, `{"status":{"indicator":"major","description":"Major Service Outage"}}`)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	page := NewStatusPage("github", ts.URL+"/api/v2/status.json", nil)
+	description, err := page.Incident(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description != "Major Service Outage" {
+		t.Fatalf("expected %q but got %q", "Major Service Outage", description)
+	}
+
+	page = NewStatusPage("github", ts.URL+"/missing", nil)
+	if _, err := page.Incident(context.Background()); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -183,9 +184,8 @@ type travisJob struct {
 
 type travisJobConfig map[string]interface{}
 
-func (c travisJobConfig) String() string {
-	var os, dist, language, compiler string
-
+// Language returns the language of the job followed by its version, if any
+func (c travisJobConfig) Language() string {
 	language, ok := c["language"].(string)
 	if language != "" && ok {
 		var s string
@@ -201,7 +201,13 @@ func (c travisJobConfig) String() string {
 			language = fmt.Sprintf("%s %s", language, s)
 		}
 	}
+	return language
+}
+
+func (c travisJobConfig) String() string {
+	var os, dist, compiler string
 
+	language := c.Language()
 	compiler, _ = c["compiler"].(string)
 	os, _ = c["os"].(string)
 	dist, _ = c["dist"].(string)
@@ -217,6 +223,49 @@ func (c travisJobConfig) String() string {
 	return strings.Join(nonEmptyValues, ", ")
 }
 
+// Phases of a Travis CI job running commands, in the order they are executed
+var travisPhases = []string{"before_install", "install", "before_script", "script", "after_success", "after_failure", "after_script"}
+
+// Steps returns the commands of each phase of the job
+func (c travisJobConfig) Steps() []cache.Step {
+	var steps []cache.Step
+	for _, phase := range travisPhases {
+		var commands []interface{}
+		switch value := c[phase].(type) {
+		case string:
+			commands = []interface{}{value}
+		case []interface{}:
+			commands = value
+		}
+		for _, command := range commands {
+			if command, ok := command.(string); ok {
+				steps = append(steps, cache.Step{Name: phase, Command: command})
+			}
+		}
+	}
+	return steps
+}
+
+// Variables returns the environment variables of the job. Encrypted variables are omitted.
+func (c travisJobConfig) Variables() []cache.Variable {
+	var assignments []interface{}
+	switch value := c["env"].(type) {
+	case string:
+		assignments = []interface{}{value}
+	case []interface{}:
+		assignments = value
+	}
+
+	var variables []cache.Variable
+	for _, assignment := range assignments {
+		// Encrypted variables are objects with a single key "secure"
+		if s, ok := assignment.(string); ok {
+			variables = append(variables, parseAssignments(s)...)
+		}
+	}
+	return variables
+}
+
 func (j travisJob) toCacheJob(build *cache.Build, stage *cache.Stage, webURL string) (cache.Job, error) {
 	var err error
 
@@ -232,7 +281,12 @@ func (j travisJob) toCacheJob(build *cache.Build, stage *cache.Stage, webURL str
 		Log:          utils.NullString{String: j.Log, Valid: j.Log != ""},
 		WebURL:       fmt.Sprintf("%s/jobs/%d", webURL, j.ID),
 		AllowFailure: j.AllowFailure,
+		Steps:        j.Config.Steps(),
+		Variables:    j.Config.Variables(),
+		Language:     j.Config.Language(),
 	}
+	job.OS, _ = j.Config["os"].(string)
+	job.Arch, _ = j.Config["arch"].(string)
 
 	ats := map[string]*utils.NullTime{
 		j.CreatedAt:  &job.CreatedAt,
@@ -275,15 +329,16 @@ type TravisClient struct {
 	buildsPageSize     int
 	token              string
 	provider           cache.Provider
+	repositoryRestriction
 }
 
 var TravisOrgURL = url.URL{Scheme: "https", Host: "api.travis-ci.org"}
 var TravisComURL = url.URL{Scheme: "https", Host: "api.travis-ci.com"}
 
-func NewTravisClient(id string, name string, token string, URL url.URL, rateLimit time.Duration) TravisClient {
+func NewTravisClient(id string, name string, token string, URL url.URL, rateLimit time.Duration, options ...ClientOption) TravisClient {
 	return TravisClient{
 		baseURL:            URL,
-		httpClient:         &http.Client{Timeout: 10 * time.Second},
+		httpClient:         newHTTPClient(requestTimeout, options),
 		rateLimiter:        time.Tick(rateLimit),
 		logBackoffInterval: 10 * time.Second,
 		token:              token,
@@ -299,6 +354,22 @@ func (c TravisClient) ID() string {
 	return c.provider.ID
 }
 
+// WithRepositories returns a copy of the client only used for the repositories matching
+// 'repositories'
+func (c TravisClient) WithRepositories(repositories RepositoryPatterns) TravisClient {
+	c.repositoryPatterns = repositories
+	return c
+}
+
+// CheckAuthentication returns an error if Travis CI rejects the API token
+func (c TravisClient) CheckAuthentication(ctx context.Context) error {
+	endpoint := c.baseURL
+	endpoint.Path += "/user"
+
+	_, err := c.get(ctx, "GET", endpoint)
+	return err
+}
+
 func (c TravisClient) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
 	owner, repo, id, err := parseTravisWebURL(&c.baseURL, u)
 	if err != nil {
@@ -313,6 +384,137 @@ func (c TravisClient) BuildFromURL(ctx context.Context, u string) (cache.Build,
 	return c.fetchBuild(ctx, &repository, id)
 }
 
+// PreviousBuilds returns at most 'limit' builds that passed on the branch of 'build' before it,
+// most recent first
+func (c TravisClient) PreviousBuilds(ctx context.Context, build cache.Build, limit int) ([]cache.Build, error) {
+	id, err := strconv.Atoi(build.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	buildsURL := c.baseURL
+	buildsPathFormat := "/repo/%s/builds"
+	buildsURL.Path += fmt.Sprintf(buildsPathFormat, build.Repository.Slug())
+	buildsURL.RawPath += fmt.Sprintf(buildsPathFormat, url.PathEscape(build.Repository.Slug()))
+	parameters := buildsURL.Query()
+	parameters.Add("branch.name", build.Ref)
+	parameters.Add("state", "passed")
+	parameters.Add("sort_by", "id:desc")
+	parameters.Add("limit", strconv.Itoa(limit+1))
+	buildsURL.RawQuery = parameters.Encode()
+
+	body, err := c.get(ctx, "GET", buildsURL)
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		Builds []travisBuild
+	}
+	if err := json.Unmarshal(body.Bytes(), &response); err != nil {
+		return nil, err
+	}
+
+	builds := make([]cache.Build, 0, limit)
+	for _, b := range response.Builds {
+		if b.ID >= id || len(builds) >= limit {
+			continue
+		}
+		previous, err := c.fetchBuild(ctx, build.Repository, strconv.Itoa(b.ID))
+		if err != nil {
+			return nil, err
+		}
+		builds = append(builds, previous)
+	}
+
+	return builds, nil
+}
+
+// CancelBuild cancels the build along with its active jobs
+func (c TravisClient) CancelBuild(ctx context.Context, build cache.Build) error {
+	cancelURL := c.baseURL
+	cancelURL.Path += fmt.Sprintf("/build/%s/cancel", build.ID)
+	_, err := c.send(ctx, "POST", cancelURL, nil)
+	return err
+}
+
+// RestartBuild restarts all the jobs of the build and returns its web page
+func (c TravisClient) RestartBuild(ctx context.Context, build cache.Build) (string, error) {
+	restartURL := c.baseURL
+	restartURL.Path += fmt.Sprintf("/build/%s/restart", build.ID)
+	if _, err := c.send(ctx, "POST", restartURL, nil); err != nil {
+		return "", err
+	}
+	return build.WebURL, nil
+}
+
+// RetryJob restarts a job of the build. Travis CI restarts jobs in place so the identifier of
+// the job is returned.
+func (c TravisClient) RetryJob(ctx context.Context, build cache.Build, job cache.Job) (string, error) {
+	restartURL := c.baseURL
+	restartURL.Path += fmt.Sprintf("/job/%s/restart", job.ID)
+	if _, err := c.send(ctx, "POST", restartURL, nil); err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// CancelJob cancels a job of the build
+func (c TravisClient) CancelJob(ctx context.Context, build cache.Build, job cache.Job) error {
+	cancelURL := c.baseURL
+	cancelURL.Path += fmt.Sprintf("/job/%s/cancel", job.ID)
+	_, err := c.send(ctx, "POST", cancelURL, nil)
+	return err
+}
+
+// TriggerPipeline asks Travis CI to build 'ref' of the GitHub repository at 'repositoryURL'. The
+// build is created asynchronously so the web page of the repository is returned.
+func (c TravisClient) TriggerPipeline(ctx context.Context, repositoryURL string, ref string, variables []cache.Variable) (string, error) {
+	host, owner, repo, err := utils.RepoHostOwnerAndName(repositoryURL)
+	if err != nil || host != "github.com" {
+		return "", cache.ErrUnknownURL
+	}
+	slug := fmt.Sprintf("%s/%s", owner, repo)
+
+	var request struct {
+		Request struct {
+			Branch string                 `json:"branch"`
+			Config map[string]interface{} `json:"config,omitempty"`
+		} `json:"request"`
+	}
+	request.Request.Branch = ref
+	if len(variables) > 0 {
+		env := make([]string, 0, len(variables))
+		for _, v := range variables {
+			env = append(env, fmt.Sprintf("%s=%s", v.Name, v.Value))
+		}
+		request.Request.Config = map[string]interface{}{
+			"merge_mode": "deep_merge",
+			"env":        map[string]interface{}{"global": env},
+		}
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	requestsURL := c.baseURL
+	requestsPathFormat := "/repo/%s/requests"
+	requestsURL.Path += fmt.Sprintf(requestsPathFormat, slug)
+	requestsURL.RawPath += fmt.Sprintf(requestsPathFormat, url.PathEscape(slug))
+	if _, err := c.send(ctx, "POST", requestsURL, payload); err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == 404 {
+			return "", cache.ErrRepositoryNotFound
+		}
+		return "", err
+	}
+
+	webURL, err := c.webURL(cache.Repository{Owner: owner, Name: repo})
+	if err != nil {
+		return "", err
+	}
+	return webURL.String(), nil
+}
+
 // Extract owner, repository and build ID from web URL of build
 func parseTravisWebURL(baseURL *url.URL, u string) (string, string, string, error) {
 	v, err := url.Parse(u)
@@ -367,10 +569,22 @@ func (c TravisClient) webURL(repository cache.Repository) (url.URL, error) {
 
 // Rate-limited HTTP GET request with custom headers
 func (c TravisClient) get(ctx context.Context, method string, resourceURL url.URL) (*bytes.Buffer, error) {
-	req, err := http.NewRequest(method, resourceURL.String(), nil)
+	return c.send(ctx, method, resourceURL, nil)
+}
+
+// Rate-limited HTTP request with custom headers and a JSON payload unless 'payload' is nil
+func (c TravisClient) send(ctx context.Context, method string, resourceURL url.URL, payload []byte) (*bytes.Buffer, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, resourceURL.String(), reqBody)
 	if err != nil {
 		return nil, err
 	}
+	if payload != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
 	req.Header.Add("Travis-API-Version", "3")
 	req.Header.Add("Authorization", fmt.Sprintf("token %s", c.token))
 	req = req.WithContext(ctx)
@@ -415,6 +629,11 @@ func (err HTTPError) Error() string {
 		err.Method, err.URL, err.Status, err.Message)
 }
 
+// StatusCode returns the status of the HTTP response
+func (err HTTPError) StatusCode() int {
+	return err.Status
+}
+
 func (c TravisClient) fetchBuild(ctx context.Context, repository *cache.Repository, buildID string) (cache.Build, error) {
 	buildURL := c.baseURL
 	buildURL.Path += fmt.Sprintf("/build/%s", buildID)
@@ -130,6 +130,9 @@ func TestTravisClientfetchBuild(t *testing.T) {
 			Log:          utils.NullString{},
 			WebURL:       fmt.Sprintf("%s/nbedos/citop/jobs/609256447", ts.URL),
 			AllowFailure: false,
+			Steps:        []cache.Step{{Name: "script", Command: "make tests"}},
+			OS:           "linux",
+			Language:     "go 1.13.x",
 		},
 		{
 			ID:    "609256448",
@@ -154,6 +157,9 @@ func TestTravisClientfetchBuild(t *testing.T) {
 			Log:          utils.NullString{},
 			WebURL:       fmt.Sprintf("%s/nbedos/citop/jobs/609256448", ts.URL),
 			AllowFailure: false,
+			Steps:        []cache.Step{{Name: "script", Command: "make tests"}},
+			OS:           "linux",
+			Language:     "go 1.12.x",
 		},
 		{
 			ID:    "609256449",
@@ -178,6 +184,9 @@ func TestTravisClientfetchBuild(t *testing.T) {
 			Log:          utils.NullString{},
 			WebURL:       fmt.Sprintf("%s/nbedos/citop/jobs/609256449", ts.URL),
 			AllowFailure: false,
+			Steps:        []cache.Step{{Name: "script", Command: "make tests"}},
+			OS:           "osx",
+			Language:     "go 1.12.x",
 		},
 		{
 			ID:    "609256450",
@@ -202,6 +211,9 @@ func TestTravisClientfetchBuild(t *testing.T) {
 			Log:          utils.NullString{},
 			WebURL:       fmt.Sprintf("%s/nbedos/citop/jobs/609256450", ts.URL),
 			AllowFailure: false,
+			Steps:        []cache.Step{{Name: "script", Command: "make tests"}},
+			OS:           "osx",
+			Language:     "go 1.12.x",
 		},
 	}
 
@@ -287,3 +299,216 @@ func TestParseTravisWebURL(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestTravisClient_CheckAuthentication(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/user" && r.Header.Get("Authorization") == "token valid" {
+			fmt.Fprint(w, `{"login": "nbedos"}`)
+			return
+		}
+		w.WriteHeader(403)
+	}))
+	defer ts.Close()
+
+	URL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []string{"valid", "invalid"} {
+		t.Run(token, func(t *testing.T) {
+			client := NewTravisClient("id", "name", token, *URL, time.Millisecond)
+			err := client.CheckAuthentication(context.Background())
+			if valid := token == "valid"; valid != (err == nil) {
+				t.Fatalf("unexpected result for %s token: %v", token, err)
+			}
+		})
+	}
+}
+
+func TestTravisClient_PreviousBuilds(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/repo/nbedos/citop/builds":
+			query := r.URL.Query()
+			if query.Get("branch.name") != "master" || query.Get("state") != "passed" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"builds": [{"id": 609256500}, {"id": 609256446}]}`)
+		case r.Method == "GET" && r.URL.Path == "/build/609256446":
+			bs, err := ioutil.ReadFile("test_data/travis_build_609256446.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fmt.Fprint(w, string(bs)); err != nil {
+				t.Fatal(err)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	URL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewTravisClient("id", "name", "token", *URL, time.Millisecond)
+
+	build := cache.Build{
+		Repository: &cache.Repository{
+			Provider: cache.Provider{ID: "id", Name: "name"},
+			Owner:    "nbedos",
+			Name:     "citop",
+		},
+		ID:  "609256500",
+		Ref: "master",
+	}
+	builds, err := client.PreviousBuilds(context.Background(), build, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(builds))
+	for _, b := range builds {
+		ids = append(ids, b.ID)
+	}
+	if diff := cmp.Diff([]string{"609256446"}, ids); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestTravisJobConfig_Steps(t *testing.T) {
+	config := travisJobConfig{
+		"install":        true,
+		"before_install": "sudo apt-get install -y shellcheck",
+		"script":         []interface{}{"make lint", "make tests"},
+		"after_script":   []interface{}{"make clean"},
+	}
+	expected := []cache.Step{
+		{Name: "before_install", Command: "sudo apt-get install -y shellcheck"},
+		{Name: "script", Command: "make lint"},
+		{Name: "script", Command: "make tests"},
+		{Name: "after_script", Command: "make clean"},
+	}
+	if diff := cmp.Diff(expected, config.Steps()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestTravisClient_TriggerPipeline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/repo/nbedos/citop/requests" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		bs, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `{"request":{"branch":"master","config":{"env":{"global":["DEPLOY=true"]},"merge_mode":"deep_merge"}}}`
+		if string(bs) != expected {
+			t.Errorf("expected payload %s but got %s", expected, string(bs))
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"@type": "pending"}`)
+	}))
+	defer ts.Close()
+
+	URL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewTravisClient("id", "name", "token", *URL, time.Millisecond)
+	variables := []cache.Variable{{Name: "DEPLOY", Value: "true"}}
+
+	webURL, err := client.TriggerPipeline(context.Background(), "github.com/nbedos/citop", "master", variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "http://" + URL.Host + "/nbedos/citop"; webURL != expected {
+		t.Fatalf("expected %q but got %q", expected, webURL)
+	}
+
+	if _, err := client.TriggerPipeline(context.Background(), "github.com/nbedos/unknown", "master", nil); err != cache.ErrRepositoryNotFound {
+		t.Fatalf("expected %v but got %v", cache.ErrRepositoryNotFound, err)
+	}
+	if _, err := client.TriggerPipeline(context.Background(), "gitlab.com/nbedos/citop", "master", nil); err != cache.ErrUnknownURL {
+		t.Fatalf("expected %v but got %v", cache.ErrUnknownURL, err)
+	}
+}
+
+func TestTravisClient_Restart(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && (r.URL.Path == "/build/609256446/restart" || r.URL.Path == "/job/609256447/restart") {
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"@type": "pending"}`)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	URL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewTravisClient("id", "name", "token", *URL, time.Millisecond)
+	build := cache.Build{ID: "609256446", WebURL: "https://travis-ci.org/nbedos/citop/builds/609256446"}
+
+	u, err := client.RestartBuild(context.Background(), build)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != build.WebURL {
+		t.Fatalf("expected %q but got %q", build.WebURL, u)
+	}
+	if _, err := client.RestartBuild(context.Background(), cache.Build{ID: "1"}); err == nil {
+		t.Fatal("expected an error for an unknown build")
+	}
+
+	id, err := client.RetryJob(context.Background(), build, cache.Job{ID: "609256447"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "609256447" {
+		t.Fatalf("expected %q but got %q", "609256447", id)
+	}
+}
+
+func TestTravisClient_Cancel(t *testing.T) {
+	canceled := make([]string, 0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && (r.URL.Path == "/build/609256446/cancel" || r.URL.Path == "/job/609256447/cancel"):
+			canceled = append(canceled, r.URL.Path)
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"@type": "pending"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	URL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewTravisClient("id", "name", "token", *URL, time.Millisecond)
+	build := cache.Build{ID: "609256446"}
+
+	if err := client.CancelBuild(context.Background(), build); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CancelJob(context.Background(), build, cache.Job{ID: "609256447"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"/build/609256446/cancel", "/job/609256447/cancel"}
+	if diff := cmp.Diff(expected, canceled); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	if err := client.CancelBuild(context.Background(), cache.Build{ID: "1"}); err == nil {
+		t.Fatal("expected an error for an unknown build")
+	}
+}
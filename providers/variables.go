@@ -0,0 +1,72 @@
+package providers
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/nbedos/citop/cache"
+)
+
+// Variables whose names contain one of these substrings (case insensitive) are never shown
+var secretNames = []string{"token", "secret", "password", "passwd", "key", "credential", "auth"}
+
+func isSecretVariable(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range secretNames {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the variables assigned by 's', a list of assignments "NAME=value" separated by spaces
+// as found in the configuration of Travis CI. Values may be surrounded by single or double
+// quotes. Secret variables and malformed assignments are omitted.
+func parseAssignments(s string) []cache.Variable {
+	variables := make([]cache.Variable, 0)
+	assignment := strings.Builder{}
+	var quote rune
+	flush := func() {
+		defer assignment.Reset()
+		i := strings.Index(assignment.String(), "=")
+		if i <= 0 {
+			return
+		}
+		name, value := assignment.String()[:i], assignment.String()[i+1:]
+		if !isSecretVariable(name) {
+			variables = append(variables, cache.Variable{Name: name, Value: value})
+		}
+	}
+
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && unicode.IsSpace(r):
+			flush()
+		default:
+			assignment.WriteRune(r)
+		}
+	}
+	flush()
+
+	return variables
+}
+
+// Return the variables of 'm' sorted by name, secrets excluded
+func sortedVariables(m map[string]string) []cache.Variable {
+	variables := make([]cache.Variable, 0, len(m))
+	for name, value := range m {
+		if !isSecretVariable(name) {
+			variables = append(variables, cache.Variable{Name: name, Value: value})
+		}
+	}
+	sort.Slice(variables, func(i, j int) bool {
+		return variables[i].Name < variables[j].Name
+	})
+	return variables
+}
@@ -0,0 +1,74 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+func TestParseAssignments(t *testing.T) {
+	testCases := []struct {
+		s         string
+		variables []cache.Variable
+	}{
+		{
+			s:         "",
+			variables: []cache.Variable{},
+		},
+		{
+			s: "GO111MODULE=on  GOFLAGS='-mod=vendor -v' EMPTY=",
+			variables: []cache.Variable{
+				{Name: "GO111MODULE", Value: "on"},
+				{Name: "GOFLAGS", Value: "-mod=vendor -v"},
+				{Name: "EMPTY", Value: ""},
+			},
+		},
+		{
+			s: `MESSAGE="hello world" GITHUB_TOKEN=abc AWS_SECRET_ACCESS_KEY=def invalid =x`,
+			variables: []cache.Variable{
+				{Name: "MESSAGE", Value: "hello world"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.s, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.variables, parseAssignments(testCase.s)); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestTravisJobConfig_Variables(t *testing.T) {
+	config := travisJobConfig{
+		"env": []interface{}{
+			"GO111MODULE=on",
+			map[string]interface{}{"secure": "encrypted"},
+			"DB=postgres TOKEN=abc",
+		},
+	}
+	expected := []cache.Variable{
+		{Name: "GO111MODULE", Value: "on"},
+		{Name: "DB", Value: "postgres"},
+	}
+	if diff := cmp.Diff(expected, config.Variables()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestAzureBuild_variables(t *testing.T) {
+	b := azureBuild{Parameters: `{"system.debug": "true", "config": "release", "deployPassword": "p"}`}
+	variables, err := b.variables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []cache.Variable{
+		{Name: "config", Value: "release"},
+		{Name: "system.debug", Value: "true"},
+	}
+	if diff := cmp.Diff(expected, variables); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,307 @@
+package providers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// Maximum size of the body of a webhook
+const maxWebhookSize = 10 << 20
+
+// Number of notifications buffered for each subscriber
+const notificationBufferSize = 64
+
+// Maximum number of commits whose pipelines are remembered by a receiver. The commits notified
+// first are forgotten beyond this number.
+const maxWebhookCommits = 1000
+
+// Maximum number of pipelines remembered for a commit
+const maxWebhookURLs = 100
+
+var ErrMissingWebhookSecret = errors.New("webhook receivers require a secret")
+
+// WebhookReceiver is a source provider listening on a local address for the webhooks sent by
+// online services every time a pipeline changes. It understands the webhooks of GitHub (events
+// "workflow_run", "check_run" and "status"), of GitLab (pipeline and job events) and generic JSON
+// objects such as {"sha": "<commit>", "url": "<web page of the pipeline>"}.
+//
+// Pipelines notified are fetched by CI providers on every webhook instead of being polled.
+type WebhookReceiver struct {
+	address  string
+	secret   string
+	state    *webhookState
+	provider cache.Provider
+	repositoryRestriction
+}
+
+// Shared by the copies of a receiver
+type webhookState struct {
+	mutex       *sync.Mutex
+	started     bool
+	err         error
+	subscribers map[chan cache.Notification]struct{}
+	// Web pages of the pipelines notified, by commit
+	urls map[string][]string
+	// Commits of 'urls' in the order they were first notified
+	shas []string
+}
+
+// NewWebhookReceiver returns a receiver listening on 'address', e.g. "localhost:8080", once
+// notifications are requested. Webhooks must be authenticated with 'secret', without which the
+// receiver refuses to listen.
+func NewWebhookReceiver(id string, name string, address string, secret string) WebhookReceiver {
+	return WebhookReceiver{
+		address: address,
+		secret:  secret,
+		state: &webhookState{
+			mutex:       &sync.Mutex{},
+			subscribers: make(map[chan cache.Notification]struct{}),
+			urls:        make(map[string][]string),
+		},
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (r WebhookReceiver) ID() string {
+	return r.provider.ID
+}
+
+// WithRepositories returns a copy of the receiver only used for the repositories matching
+// 'repositories'
+func (r WebhookReceiver) WithRepositories(repositories RepositoryPatterns) WebhookReceiver {
+	r.repositoryPatterns = repositories
+	return r
+}
+
+// Notifications starts listening for webhooks if the receiver is not listening yet and returns
+// a channel receiving a notification for every pipeline updated. Notifications are dropped if
+// the channel is not drained. ErrMissingWebhookSecret is returned if the receiver has no secret
+// since anyone able to reach its address could then make citop fetch any URL.
+func (r WebhookReceiver) Notifications(ctx context.Context) (<-chan cache.Notification, error) {
+	if r.secret == "" {
+		return nil, ErrMissingWebhookSecret
+	}
+
+	r.state.mutex.Lock()
+	defer r.state.mutex.Unlock()
+
+	if !r.state.started {
+		r.state.started = true
+		var l net.Listener
+		if l, r.state.err = net.Listen("tcp", r.address); r.state.err == nil {
+			// The receiver listens until citop exits
+			go http.Serve(l, r)
+		}
+	}
+	if r.state.err != nil {
+		return nil, r.state.err
+	}
+
+	notifications := make(chan cache.Notification, notificationBufferSize)
+	r.state.subscribers[notifications] = struct{}{}
+	go func() {
+		<-ctx.Done()
+		r.state.mutex.Lock()
+		defer r.state.mutex.Unlock()
+		delete(r.state.subscribers, notifications)
+		close(notifications)
+	}()
+
+	return notifications, nil
+}
+
+// Commit is not supported since webhooks do not describe commits
+func (r WebhookReceiver) Commit(ctx context.Context, repo string, sha string) (utils.Commit, error) {
+	return utils.Commit{}, cache.ErrRepositoryNotFound
+}
+
+// BuildURLs returns the web pages of the pipelines of commit 'sha' notified so far
+func (r WebhookReceiver) BuildURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
+	r.state.mutex.Lock()
+	defer r.state.mutex.Unlock()
+
+	urls, exists := r.state.urls[sha]
+	if !exists {
+		return nil, cache.ErrRepositoryNotFound
+	}
+	return append([]string(nil), urls...), nil
+}
+
+// ServeHTTP handles a webhook and notifies subscribers of the pipelines it describes
+func (r WebhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxWebhookSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !r.authenticate(req.Header, body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	notifications, err := parseWebhook(req.Header, body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+
+	r.state.mutex.Lock()
+	defer r.state.mutex.Unlock()
+	for _, notification := range notifications {
+		r.remember(notification)
+		for subscriber := range r.state.subscribers {
+			select {
+			case subscriber <- notification:
+			default:
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Record the pipeline of 'notification' among those of its commit. The caller must hold the
+// mutex of the state.
+func (r WebhookReceiver) remember(notification cache.Notification) {
+	urls, exists := r.state.urls[notification.Sha]
+	if !exists {
+		if len(r.state.shas) >= maxWebhookCommits {
+			delete(r.state.urls, r.state.shas[0])
+			r.state.shas = r.state.shas[1:]
+		}
+		r.state.shas = append(r.state.shas, notification.Sha)
+	}
+	for _, u := range urls {
+		if u == notification.URL {
+			return
+		}
+	}
+	if len(urls) < maxWebhookURLs {
+		r.state.urls[notification.Sha] = append(urls, notification.URL)
+	}
+}
+
+// Tell whether the webhook was sent by a service knowing the secret of the receiver. GitHub signs
+// the body of webhooks with the secret while GitLab and other services send the secret itself.
+// Webhooks are never authenticated without a secret.
+func (r WebhookReceiver) authenticate(header http.Header, body []byte) bool {
+	if r.secret == "" {
+		return false
+	}
+	if signature := header.Get("X-Hub-Signature-256"); signature != "" {
+		mac := hmac.New(sha256.New, []byte(r.secret))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(signature), []byte(expected))
+	}
+	token := header.Get("X-Gitlab-Token")
+	if token == "" {
+		token = strings.TrimPrefix(header.Get("Authorization"), "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(r.secret)) == 1
+}
+
+// Return the pipelines described by a webhook. Events unrelated to pipelines are ignored.
+func parseWebhook(header http.Header, body []byte) ([]cache.Notification, error) {
+	notifications := make([]cache.Notification, 0, 1)
+	add := func(sha string, u string) {
+		if sha != "" && u != "" {
+			notifications = append(notifications, cache.Notification{Sha: sha, URL: u})
+		}
+	}
+
+	switch {
+	case header.Get("X-GitHub-Event") != "":
+		var event struct {
+			WorkflowRun *struct {
+				HeadSha string `json:"head_sha"`
+				HTMLURL string `json:"html_url"`
+			} `json:"workflow_run"`
+			CheckRun *struct {
+				HeadSha    string `json:"head_sha"`
+				DetailsURL string `json:"details_url"`
+			} `json:"check_run"`
+			Sha       string `json:"sha"`
+			TargetURL string `json:"target_url"`
+		}
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, err
+		}
+		switch header.Get("X-GitHub-Event") {
+		case "workflow_run":
+			if event.WorkflowRun != nil {
+				add(event.WorkflowRun.HeadSha, event.WorkflowRun.HTMLURL)
+			}
+		case "check_run":
+			if event.CheckRun != nil {
+				add(event.CheckRun.HeadSha, event.CheckRun.DetailsURL)
+			}
+		case "status":
+			add(event.Sha, event.TargetURL)
+		}
+
+	case header.Get("X-Gitlab-Event") != "":
+		var event struct {
+			ObjectAttributes struct {
+				ID  int    `json:"id"`
+				Sha string `json:"sha"`
+			} `json:"object_attributes"`
+			Project struct {
+				WebURL string `json:"web_url"`
+			} `json:"project"`
+			Sha        string `json:"sha"`
+			PipelineID int    `json:"pipeline_id"`
+			Repository struct {
+				Homepage string `json:"homepage"`
+			} `json:"repository"`
+		}
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, err
+		}
+		switch header.Get("X-Gitlab-Event") {
+		case "Pipeline Hook":
+			if event.Project.WebURL != "" {
+				add(event.ObjectAttributes.Sha, fmt.Sprintf("%s/pipelines/%d", event.Project.WebURL, event.ObjectAttributes.ID))
+			}
+		case "Job Hook":
+			if event.Repository.Homepage != "" {
+				add(event.Sha, fmt.Sprintf("%s/pipelines/%d", event.Repository.Homepage, event.PipelineID))
+			}
+		}
+
+	default:
+		var event struct {
+			Sha string `json:"sha"`
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, err
+		}
+		if event.Sha == "" || event.URL == "" {
+			return nil, fmt.Errorf("missing key 'sha' or 'url' in webhook")
+		}
+		add(event.Sha, event.URL)
+	}
+
+	return notifications, nil
+}
@@ -0,0 +1,245 @@
+package providers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+func TestWebhookReceiver_ServeHTTP(t *testing.T) {
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	sha := "a24840cf94b395af69da4a1001d32e3694637e20"
+
+	testCases := []struct {
+		name     string
+		header   map[string]string
+		body     string
+		status   int
+		expected []string
+	}{
+		{
+			name: "GitHub workflow run",
+			header: map[string]string{
+				"X-GitHub-Event":      "workflow_run",
+				"X-Hub-Signature-256": sign(`{"workflow_run": {"head_sha": "` + sha + `", "html_url": "https://github.com/nbedos/citop/actions/runs/7"}}`),
+			},
+			body:     `{"workflow_run": {"head_sha": "` + sha + `", "html_url": "https://github.com/nbedos/citop/actions/runs/7"}}`,
+			status:   http.StatusNoContent,
+			expected: []string{"https://github.com/nbedos/citop/actions/runs/7"},
+		},
+		{
+			name: "GitHub commit status",
+			header: map[string]string{
+				"X-GitHub-Event":      "status",
+				"X-Hub-Signature-256": sign(`{"sha": "` + sha + `", "target_url": "https://travis-ci.org/nbedos/citop/builds/615087998"}`),
+			},
+			body:     `{"sha": "` + sha + `", "target_url": "https://travis-ci.org/nbedos/citop/builds/615087998"}`,
+			status:   http.StatusNoContent,
+			expected: []string{"https://travis-ci.org/nbedos/citop/builds/615087998"},
+		},
+		{
+			name: "GitHub event unrelated to pipelines",
+			header: map[string]string{
+				"X-GitHub-Event":      "ping",
+				"X-Hub-Signature-256": sign(`{"zen": "Keep it logically awesome."}`),
+			},
+			body:   `{"zen": "Keep it logically awesome."}`,
+			status: http.StatusNoContent,
+		},
+		{
+			name: "GitHub webhook with invalid signature",
+			header: map[string]string{
+				"X-GitHub-Event":      "status",
+				"X-Hub-Signature-256": sign("{}"),
+			},
+			body:   `{"sha": "` + sha + `", "target_url": "https://travis-ci.org/nbedos/citop/builds/615087998"}`,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "GitLab pipeline",
+			header: map[string]string{
+				"X-Gitlab-Event": "Pipeline Hook",
+				"X-Gitlab-Token": "secret",
+			},
+			body:     `{"object_attributes": {"id": 97604657, "sha": "` + sha + `"}, "project": {"web_url": "https://gitlab.com/nbedos/citop"}}`,
+			status:   http.StatusNoContent,
+			expected: []string{"https://gitlab.com/nbedos/citop/pipelines/97604657"},
+		},
+		{
+			name: "GitLab job",
+			header: map[string]string{
+				"X-Gitlab-Event": "Job Hook",
+				"X-Gitlab-Token": "secret",
+			},
+			body:     `{"sha": "` + sha + `", "pipeline_id": 97604657, "repository": {"homepage": "https://gitlab.com/nbedos/citop"}}`,
+			status:   http.StatusNoContent,
+			expected: []string{"https://gitlab.com/nbedos/citop/pipelines/97604657"},
+		},
+		{
+			name: "GitLab webhook with invalid token",
+			header: map[string]string{
+				"X-Gitlab-Event": "Pipeline Hook",
+				"X-Gitlab-Token": "invalid",
+			},
+			body:   `{"object_attributes": {"id": 97604657, "sha": "` + sha + `"}, "project": {"web_url": "https://gitlab.com/nbedos/citop"}}`,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "Generic webhook",
+			header: map[string]string{
+				"Authorization": "Bearer secret",
+			},
+			body:     `{"sha": "` + sha + `", "url": "https://buildkite.com/nbedos/citop/builds/7"}`,
+			status:   http.StatusNoContent,
+			expected: []string{"https://buildkite.com/nbedos/citop/builds/7"},
+		},
+		{
+			name: "Generic webhook without URL",
+			header: map[string]string{
+				"Authorization": "Bearer secret",
+			},
+			body:   `{"sha": "` + sha + `"}`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			receiver := NewWebhookReceiver("webhook", "webhook", "", "secret")
+			req := httptest.NewRequest("POST", "/", strings.NewReader(testCase.body))
+			for key, value := range testCase.header {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			receiver.ServeHTTP(w, req)
+			if w.Code != testCase.status {
+				t.Fatalf("expected status %d but got %d", testCase.status, w.Code)
+			}
+
+			urls, err := receiver.BuildURLs(context.Background(), "nbedos", "citop", sha)
+			if len(testCase.expected) == 0 {
+				if err != cache.ErrRepositoryNotFound {
+					t.Fatalf("expected %v but got %v", cache.ErrRepositoryNotFound, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.expected, urls); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("Receiver without secret", func(t *testing.T) {
+		receiver := NewWebhookReceiver("webhook", "webhook", "", "")
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"sha": "`+sha+`", "url": "https://buildkite.com/nbedos/citop/builds/7"}`))
+		w := httptest.NewRecorder()
+		receiver.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status %d but got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+
+	t.Run("Commits notified first are forgotten", func(t *testing.T) {
+		receiver := NewWebhookReceiver("webhook", "webhook", "", "secret")
+		for i := 0; i <= maxWebhookCommits; i++ {
+			body := fmt.Sprintf(`{"sha": "%d", "url": "https://buildkite.com/nbedos/citop/builds/%d"}`, i, i)
+			req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer secret")
+			receiver.ServeHTTP(httptest.NewRecorder(), req)
+		}
+		if _, err := receiver.BuildURLs(context.Background(), "nbedos", "citop", "0"); err != cache.ErrRepositoryNotFound {
+			t.Fatalf("expected %v but got %v", cache.ErrRepositoryNotFound, err)
+		}
+		if _, err := receiver.BuildURLs(context.Background(), "nbedos", "citop", "1"); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(receiver.state.urls); n != maxWebhookCommits {
+			t.Fatalf("expected %d commits but got %d", maxWebhookCommits, n)
+		}
+	})
+
+	t.Run("Method other than POST", func(t *testing.T) {
+		receiver := NewWebhookReceiver("webhook", "webhook", "", "")
+		w := httptest.NewRecorder()
+		receiver.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("expected status %d but got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
+
+func TestWebhookReceiver_Notifications(t *testing.T) {
+	receiver := NewWebhookReceiver("webhook", "webhook", "127.0.0.1:0", "secret")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notifications, err := receiver.Notifications(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"sha": "a24840cf94b395af69da4a1001d32e3694637e20", "url": "https://buildkite.com/nbedos/citop/builds/7"}`
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	receiver.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case notification := <-notifications:
+		expected := cache.Notification{
+			Sha: "a24840cf94b395af69da4a1001d32e3694637e20",
+			URL: "https://buildkite.com/nbedos/citop/builds/7",
+		}
+		if diff := cmp.Diff(expected, notification); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no notification received")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-notifications:
+		if ok {
+			t.Fatal("expected closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancellation")
+	}
+
+	t.Run("Address already in use", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		receiver := NewWebhookReceiver("webhook", "webhook", l.Addr().String(), "secret")
+		if _, err := receiver.Notifications(context.Background()); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("Missing secret", func(t *testing.T) {
+		receiver := NewWebhookReceiver("webhook", "webhook", "127.0.0.1:0", "")
+		if _, err := receiver.Notifications(context.Background()); err != ErrMissingWebhookSecret {
+			t.Fatalf("expected %v but got %v", ErrMissingWebhookSecret, err)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/nbedos/citop/tui"
+	"github.com/nbedos/citop/utils"
+)
+
+type statusArguments struct {
+	repository string
+	format     string
+	interval   time.Duration
+	commit     string
+}
+
+func parseStatusArguments(args []string, defaultRepository string, defaultCommit string) (statusArguments, error) {
+	a := statusArguments{commit: defaultCommit}
+	f := flag.NewFlagSet("citop status", flag.ContinueOnError)
+	f.SetOutput(bytes.NewBuffer(nil))
+	f.StringVar(&a.repository, "repository", defaultRepository, "")
+	f.StringVar(&a.repository, "r", defaultRepository, "")
+	f.StringVar(&a.format, "format", string(tui.StatusPlain), "")
+	f.DurationVar(&a.interval, "interval", 0, "")
+	if err := f.Parse(args); err != nil {
+		return a, err
+	}
+
+	if commits := f.Args(); len(commits) == 1 {
+		a.commit = commits[0]
+	} else if len(commits) > 1 {
+		return a, errors.New("at most one commit can be specified")
+	}
+
+	if _, err := tui.ParseStatusFormat(a.format); err != nil {
+		return a, err
+	}
+	if a.interval < 0 {
+		return a, errors.New("interval must not be negative")
+	}
+
+	return a, nil
+}
+
+// Print a one-line summary of the state of the pipelines of a commit and return the exit
+// status of citop. If an interval is specified, a new line is printed at every interval until
+// citop is killed and errors do not stop the loop.
+func runStatus(args []string) int {
+	defaultRepository, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	a, err := parseStatusArguments(args, defaultRepository, "HEAD")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		fmt.Fprintln(os.Stderr, usage())
+		return exitError
+	}
+	format, err := tui.ParseStatusFormat(a.format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+
+	paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
+	config, err := loadConfiguration(paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	icons, err := config.Style.StateIcons()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+	if icons == nil {
+		// A summary without icons would be a list of numbers
+		icons = tui.ASCIIStateIcons
+		if utf8Locale() {
+			icons = tui.UnicodeStateIcons
+		}
+	}
+
+	ctx := context.Background()
+	sourceProviders, ciProviders, err := config.Providers.Providers(ctx, http.DefaultTransport)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return exitError
+	}
+
+	cacheDir := path.Join(utils.XDGCacheHome(), ConfDir)
+	for {
+		err = tui.RunStatus(ctx, os.Stdout, a.repository, a.commit, ciProviders, sourceProviders, format, icons, cacheDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+			if a.interval == 0 {
+				return exitError
+			}
+		}
+		if a.interval == 0 {
+			return 0
+		}
+		time.Sleep(a.interval)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseStatusArguments(t *testing.T) {
+	testCases := []struct {
+		args      []string
+		arguments statusArguments
+	}{
+		{
+			args:      nil,
+			arguments: statusArguments{repository: "repo", format: "plain", commit: "HEAD"},
+		},
+		{
+			args:      []string{"-r", "github.com/nbedos/citop", "--format", "tmux", "master"},
+			arguments: statusArguments{repository: "github.com/nbedos/citop", format: "tmux", commit: "master"},
+		},
+		{
+			args:      []string{"--format", "waybar", "--interval", "30s"},
+			arguments: statusArguments{repository: "repo", format: "waybar", interval: 30 * time.Second, commit: "HEAD"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(strings.Join(testCase.args, " "), func(t *testing.T) {
+			a, err := parseStatusArguments(testCase.args, "repo", "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.arguments, a, cmp.AllowUnexported(statusArguments{})); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	for _, args := range [][]string{
+		{"--format", "xml"},
+		{"HEAD", "HEAD~1"},
+		{"--interval", "-1s"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			if _, err := parseStatusArguments(args, "repo", "HEAD"); err == nil {
+				t.Fatal("expected error but got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package text
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell"
+)
+
+// ANSIStyle holds the colors and attributes selected by the SGR escape sequences of a terminal,
+// such as "\x1b[1;31m", found in logs. It is applied to the style of a string before its
+// classes so that the row at the cursor stays highlighted.
+type ANSIStyle struct {
+	Foreground tcell.Color
+	Background tcell.Color
+	Attributes tcell.AttrMask
+}
+
+// DefaultANSIStyle leaves the style of the terminal unchanged
+var DefaultANSIStyle = ANSIStyle{
+	Foreground: tcell.ColorDefault,
+	Background: tcell.ColorDefault,
+}
+
+// Attribute set and unset by the parameters of SGR sequences
+var ansiAttributes = map[int]struct {
+	mask tcell.AttrMask
+	on   bool
+}{
+	1:  {tcell.AttrBold, true},
+	2:  {tcell.AttrDim, true},
+	4:  {tcell.AttrUnderline, true},
+	5:  {tcell.AttrBlink, true},
+	7:  {tcell.AttrReverse, true},
+	22: {tcell.AttrBold | tcell.AttrDim, false},
+	24: {tcell.AttrUnderline, false},
+	25: {tcell.AttrBlink, false},
+	27: {tcell.AttrReverse, false},
+}
+
+// Return the color designated by the parameters following 38 or 48 in a SGR sequence, either
+// "5;n" for a color of the 256-color palette or "2;r;g;b" for a 24-bit color, along with the
+// number of parameters read
+func extendedColor(params []int) (tcell.Color, int, bool) {
+	switch {
+	case len(params) >= 2 && params[0] == 5:
+		return tcell.Color(params[1] & 0xff), 2, true
+	case len(params) >= 4 && params[0] == 2:
+		return tcell.NewRGBColor(int32(params[1]&0xff), int32(params[2]&0xff), int32(params[3]&0xff)), 4, true
+	}
+	return tcell.ColorDefault, len(params), false
+}
+
+// Update returns the style resulting from the parameters of a SGR sequence, e.g. "1;31" for
+// "\x1b[1;31m". Unknown parameters are ignored.
+func (a ANSIStyle) Update(parameters string) ANSIStyle {
+	params := make([]int, 0)
+	for _, s := range strings.Split(parameters, ";") {
+		// An empty parameter stands for 0
+		n, err := strconv.Atoi(s)
+		if err != nil && s != "" {
+			return a
+		}
+		params = append(params, n)
+	}
+
+	for i := 0; i < len(params); i++ {
+		switch p := params[i]; {
+		case p == 0:
+			a = DefaultANSIStyle
+		case p >= 30 && p <= 37:
+			a.Foreground = tcell.Color(p - 30)
+		case p >= 90 && p <= 97:
+			a.Foreground = tcell.Color(p - 90 + 8)
+		case p == 39:
+			a.Foreground = tcell.ColorDefault
+		case p >= 40 && p <= 47:
+			a.Background = tcell.Color(p - 40)
+		case p >= 100 && p <= 107:
+			a.Background = tcell.Color(p - 100 + 8)
+		case p == 49:
+			a.Background = tcell.ColorDefault
+		case p == 38 || p == 48:
+			color, n, ok := extendedColor(params[i+1:])
+			i += n
+			if !ok {
+				break
+			}
+			if p == 38 {
+				a.Foreground = color
+			} else {
+				a.Background = color
+			}
+		default:
+			if attr, exists := ansiAttributes[p]; exists {
+				if attr.on {
+					a.Attributes |= attr.mask
+				} else {
+					a.Attributes &^= attr.mask
+				}
+			}
+		}
+	}
+
+	return a
+}
+
+// Return 's' with the colors and attributes of 'a'
+func (a ANSIStyle) apply(s tcell.Style) tcell.Style {
+	if a.Foreground != tcell.ColorDefault {
+		s = s.Foreground(a.Foreground)
+	}
+	if a.Background != tcell.ColorDefault {
+		s = s.Background(a.Background)
+	}
+	if a.Attributes&tcell.AttrBold != 0 {
+		s = s.Bold(true)
+	}
+	if a.Attributes&tcell.AttrDim != 0 {
+		s = s.Dim(true)
+	}
+	if a.Attributes&tcell.AttrUnderline != 0 {
+		s = s.Underline(true)
+	}
+	if a.Attributes&tcell.AttrBlink != 0 {
+		s = s.Blink(true)
+	}
+	if a.Attributes&tcell.AttrReverse != 0 {
+		s = s.Reverse(true)
+	}
+	return s
+}
+
+// AppendANSI appends 'content' shown with the style selected by SGR sequences and the classes
+// 'classes'
+func (s *StyledString) AppendANSI(content string, style ANSIStyle, classes ...Class) {
+	e := elementaryString{
+		Content: content,
+		Classes: classes,
+	}
+	if style != DefaultANSIStyle {
+		e.ANSI = &style
+	}
+	s.components = append(s.components, e)
+}
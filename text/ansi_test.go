@@ -0,0 +1,115 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestANSIStyle_Update(t *testing.T) {
+	testCases := []struct {
+		name       string
+		style      ANSIStyle
+		parameters string
+		expected   ANSIStyle
+	}{
+		{
+			name:       "basic colors",
+			style:      DefaultANSIStyle,
+			parameters: "31;42",
+			expected:   ANSIStyle{Foreground: tcell.ColorMaroon, Background: tcell.ColorGreen},
+		},
+		{
+			name:       "bright colors",
+			style:      DefaultANSIStyle,
+			parameters: "91;102",
+			expected:   ANSIStyle{Foreground: tcell.ColorRed, Background: tcell.ColorLime},
+		},
+		{
+			name:       "attributes",
+			style:      DefaultANSIStyle,
+			parameters: "1;4",
+			expected: ANSIStyle{
+				Foreground: tcell.ColorDefault,
+				Background: tcell.ColorDefault,
+				Attributes: tcell.AttrBold | tcell.AttrUnderline,
+			},
+		},
+		{
+			name:       "attributes are unset independently of colors",
+			style:      ANSIStyle{Foreground: tcell.ColorRed, Background: tcell.ColorDefault, Attributes: tcell.AttrBold | tcell.AttrUnderline},
+			parameters: "22",
+			expected:   ANSIStyle{Foreground: tcell.ColorRed, Background: tcell.ColorDefault, Attributes: tcell.AttrUnderline},
+		},
+		{
+			name:       "256-color palette",
+			style:      DefaultANSIStyle,
+			parameters: "38;5;208",
+			expected:   ANSIStyle{Foreground: tcell.Color(208), Background: tcell.ColorDefault},
+		},
+		{
+			name:       "24-bit colors",
+			style:      DefaultANSIStyle,
+			parameters: "48;2;10;20;30;1",
+			expected: ANSIStyle{
+				Foreground: tcell.ColorDefault,
+				Background: tcell.NewRGBColor(10, 20, 30),
+				Attributes: tcell.AttrBold,
+			},
+		},
+		{
+			name:       "default colors",
+			style:      ANSIStyle{Foreground: tcell.ColorRed, Background: tcell.ColorBlue},
+			parameters: "39;49",
+			expected:   DefaultANSIStyle,
+		},
+		{
+			name:       "empty parameter resets the style",
+			style:      ANSIStyle{Foreground: tcell.ColorRed, Background: tcell.ColorBlue, Attributes: tcell.AttrBold},
+			parameters: "",
+			expected:   DefaultANSIStyle,
+		},
+		{
+			name:       "invalid parameters are ignored",
+			style:      DefaultANSIStyle,
+			parameters: "31;x",
+			expected:   DefaultANSIStyle,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if style := testCase.style.Update(testCase.parameters); style != testCase.expected {
+				t.Fatalf("expected %+v but got %+v", testCase.expected, style)
+			}
+		})
+	}
+}
+
+func TestLocalizedStyledString_Draw(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	screen.SetSize(10, 1)
+
+	s := NewStyledString("")
+	s.AppendANSI("a", DefaultANSIStyle.Update("1;31"))
+	s.AppendANSI("b", DefaultANSIStyle.Update("32"), ActiveRow)
+	styleSheet := StyleSheet{
+		ActiveRow: func(s tcell.Style) tcell.Style { return s.Background(tcell.ColorBlue) },
+	}
+	LocalizedStyledString{X: 0, Y: 0, S: s}.Draw(screen, tcell.StyleDefault, styleSheet)
+
+	expected := []tcell.Style{
+		tcell.StyleDefault.Foreground(tcell.ColorMaroon).Bold(true),
+		// Classes are applied after escape sequences
+		tcell.StyleDefault.Foreground(tcell.ColorGreen).Background(tcell.ColorBlue),
+	}
+	for x, style := range expected {
+		if _, _, s, _ := screen.GetContent(x, 0); s != style {
+			t.Fatalf("unexpected style at column %d", x)
+		}
+	}
+}
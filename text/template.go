@@ -0,0 +1,143 @@
+package text
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Names of the classes that may be used with the "style" function of templates
+var ClassNames = map[string]Class{
+	"header":     TableHeader,
+	"active_row": ActiveRow,
+	"provider":   Provider,
+	"sha":        GitSha,
+	"ref":        GitRef,
+	"branch":     GitBranch,
+	"tag":        GitTag,
+	"head":       GitHead,
+	"passed":     StatusPassed,
+	"running":    StatusRunning,
+	"failed":     StatusFailed,
+	"skipped":    StatusSkipped,
+	"pending":    StatusPending,
+	"canceled":   StatusCanceled,
+	"manual":     StatusManual,
+}
+
+// Control characters delimiting styled sections in the output of a template. These characters
+// are not expected in the data passed to templates.
+const (
+	styleStart     = '\x02'
+	styleClassName = '\x03'
+	styleEnd       = '\x04'
+)
+
+func stripMarkers(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case styleStart, styleClassName, styleEnd:
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// Style wraps 's' in markers so that the output of the template is rendered with the style of
+// class 'name'. Styled sections may be nested.
+func Style(name string, s string) (string, error) {
+	if _, exists := ClassNames[name]; !exists {
+		return "", fmt.Errorf("unknown style %q", name)
+	}
+	return string(styleStart) + name + string(styleClassName) + s + string(styleEnd), nil
+}
+
+// Styled returns the version of 's' that can be included as is in the output of a template
+// while preserving its classes.
+func Styled(s StyledString) string {
+	var b strings.Builder
+	for _, c := range s.components {
+		content := stripMarkers(c.Content)
+		for i := len(c.Classes) - 1; i >= 0; i-- {
+			for name, class := range ClassNames {
+				if class == c.Classes[i] {
+					content, _ = Style(name, content)
+					break
+				}
+			}
+		}
+		b.WriteString(content)
+	}
+	return b.String()
+}
+
+// NewTemplate parses a text/template whose output is turned into styled lines by
+// ExecuteTemplate. In addition to 'funcs', templates have access to the function
+// 'style NAME TEXT' which renders TEXT with the style of the element NAME.
+func NewTemplate(name string, s string, funcs template.FuncMap) (*template.Template, error) {
+	return template.New(name).
+		Funcs(template.FuncMap{"style": Style}).
+		Funcs(funcs).
+		Option("missingkey=error").
+		Parse(s)
+}
+
+// ExecuteTemplate applies 't' to 'data' and returns the lines of the output. A trailing newline
+// does not produce an empty line.
+func ExecuteTemplate(t *template.Template, data interface{}) ([]StyledString, error) {
+	buf := bytes.Buffer{}
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	return parseStyledLines(strings.TrimSuffix(buf.String(), "\n"))
+}
+
+func parseStyledLines(s string) ([]StyledString, error) {
+	lines := make([]StyledString, 0)
+	line := StyledString{}
+	classes := make([]Class, 0)
+	content := strings.Builder{}
+
+	flush := func() {
+		if content.Len() > 0 {
+			line.Append(content.String(), append([]Class(nil), classes...)...)
+			content.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\n':
+			flush()
+			lines = append(lines, line)
+			line = StyledString{}
+		case styleStart:
+			flush()
+			j := strings.IndexByte(s[i:], styleClassName)
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated style name at offset %d", i)
+			}
+			name := s[i+1 : i+j]
+			class, exists := ClassNames[name]
+			if !exists {
+				return nil, fmt.Errorf("unknown style %q", name)
+			}
+			classes = append(classes, class)
+			i += j
+		case styleEnd:
+			flush()
+			if len(classes) == 0 {
+				return nil, fmt.Errorf("unbalanced end of style at offset %d", i)
+			}
+			classes = classes[:len(classes)-1]
+		default:
+			content.WriteByte(s[i])
+		}
+	}
+	flush()
+	lines = append(lines, line)
+
+	return lines, nil
+}
@@ -0,0 +1,69 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExecuteTemplate(t *testing.T) {
+	t.Run("styled sections must be turned into classes", func(t *testing.T) {
+		tmpl, err := NewTemplate("test", `{{ style "sha" "abc" }} {{ style "branch" (printf "%s %s" "x" (style "head" "y")) }}
+second line
+`, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines, err := ExecuteTemplate(tmpl, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []StyledString{{}, NewStyledString("second line")}
+		expected[0].Append("abc", GitSha)
+		expected[0].Append(" ")
+		expected[0].Append("x ", GitBranch)
+		expected[0].Append("y", GitBranch, GitHead)
+
+		if len(lines) != len(expected) {
+			t.Fatalf("expected %d lines but got %d", len(expected), len(lines))
+		}
+		for i := range lines {
+			if diff := cmp.Diff(expected[i], lines[i], cmp.AllowUnexported(StyledString{}, elementaryString{})); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		}
+	})
+
+	t.Run("Styled must preserve classes", func(t *testing.T) {
+		var s StyledString
+		s.Append("HEAD -> ", GitHead)
+		s.Append("master", GitBranch)
+
+		tmpl, err := NewTemplate("test", "{{ . }}", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines, err := ExecuteTemplate(tmpl, Styled(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) != 1 {
+			t.Fatalf("expected 1 line but got %d", len(lines))
+		}
+		if diff := cmp.Diff(s, lines[0], cmp.AllowUnexported(StyledString{}, elementaryString{})); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("unknown style must cause an error", func(t *testing.T) {
+		tmpl, err := NewTemplate("test", `{{ style "blink" "abc" }}`, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ExecuteTemplate(tmpl, nil); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
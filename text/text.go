@@ -23,12 +23,19 @@ const (
 	StatusRunning
 	StatusFailed
 	StatusSkipped
+	StatusPending
+	StatusCanceled
+	StatusManual
 	Provider
+	SearchMatch
 )
 
 type elementaryString struct {
 	Content string
 	Classes []Class
+	// Style selected by escape sequences, applied before the classes. nil for strings
+	// without escape sequences.
+	ANSI *ANSIStyle
 }
 
 type StyledString struct {
@@ -96,6 +103,44 @@ func (s *StyledString) Align(alignment Alignment, width int) {
 	}
 }
 
+// Wrap splits 's' into lines at most 'width' screen columns wide, keeping the classes of each
+// part of the string. 's' is returned as is if 'width' is not positive.
+func (s StyledString) Wrap(width int) []StyledString {
+	if width <= 0 {
+		return []StyledString{s}
+	}
+
+	lines := []StyledString{{}}
+	lineWidth := 0
+	appendChunk := func(content string, c elementaryString) {
+		line := &lines[len(lines)-1]
+		line.components = append(line.components, elementaryString{
+			Content: content,
+			Classes: append([]Class(nil), c.Classes...),
+			ANSI:    c.ANSI,
+		})
+	}
+	for _, c := range s.components {
+		start := 0
+		for i, r := range c.Content {
+			w := runewidth.RuneWidth(r)
+			if lineWidth > 0 && lineWidth+w > width {
+				if i > start {
+					appendChunk(c.Content[start:i], c)
+				}
+				lines = append(lines, StyledString{})
+				start, lineWidth = i, 0
+			}
+			lineWidth += w
+		}
+		if start < len(c.Content) {
+			appendChunk(c.Content[start:], c)
+		}
+	}
+
+	return lines
+}
+
 func (s StyledString) Contains(value string) bool {
 	b := bytes.NewBufferString("")
 	for _, c := range s.components {
@@ -104,6 +149,59 @@ func (s StyledString) Contains(value string) bool {
 	return strings.Contains(b.String(), value)
 }
 
+// Highlight returns a copy of 's' where the occurrences of 'pattern' have the class 'class' in
+// addition to their own classes. Occurrences may span several parts of the string.
+func (s StyledString) Highlight(pattern string, class Class) StyledString {
+	// Byte offsets of the beginning and end of each occurrence
+	var bounds []int
+	content := s.String()
+	if pattern != "" {
+		for offset := 0; ; {
+			i := strings.Index(content[offset:], pattern)
+			if i < 0 {
+				break
+			}
+			offset += i
+			bounds = append(bounds, offset, offset+len(pattern))
+			offset += len(pattern)
+		}
+	}
+	if len(bounds) == 0 {
+		return s
+	}
+
+	highlighted := StyledString{}
+	offset := 0
+	for _, c := range s.components {
+		start, end := offset, offset+len(c.Content)
+		for start < end {
+			// Look for the first bound past 'start' to know whether the text at 'start' is part
+			// of an occurrence and where the next bound is
+			i := 0
+			for i < len(bounds) && bounds[i] <= start {
+				i++
+			}
+			next, matching := end, i%2 == 1
+			if i < len(bounds) && bounds[i] < end {
+				next = bounds[i]
+			}
+			e := elementaryString{
+				Content: c.Content[start-offset : next-offset],
+				Classes: append([]Class(nil), c.Classes...),
+				ANSI:    c.ANSI,
+			}
+			if matching {
+				e.Classes = append(e.Classes, class)
+			}
+			highlighted.components = append(highlighted.components, e)
+			start = next
+		}
+		offset = end
+	}
+
+	return highlighted
+}
+
 func NewStyledString(content string, classes ...Class) StyledString {
 	return StyledString{
 		components: []elementaryString{
@@ -133,6 +231,9 @@ func (t LocalizedStyledString) Draw(screen tcell.Screen, style tcell.Style, styl
 	x, y := t.X, t.Y
 	for _, component := range t.S.components {
 		s := style
+		if component.ANSI != nil {
+			s = component.ANSI.apply(s)
+		}
 		for _, c := range component.Classes {
 			f, exists := styleSheet[c]
 			if exists && f != nil {
@@ -0,0 +1,72 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStyledString_Wrap(t *testing.T) {
+	s := NewStyledString("abc", GitSha)
+	s.Append("defgh", GitBranch)
+
+	t.Run("lines keep the classes of each part", func(t *testing.T) {
+		expected := []StyledString{
+			{components: []elementaryString{{Content: "abc", Classes: []Class{GitSha}}, {Content: "d", Classes: []Class{GitBranch}}}},
+			{components: []elementaryString{{Content: "efgh", Classes: []Class{GitBranch}}}},
+		}
+		if diff := cmp.Diff(expected, s.Wrap(4), cmp.AllowUnexported(StyledString{})); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("wide characters are not split", func(t *testing.T) {
+		lines := NewStyledString("a日本").Wrap(2)
+		texts := make([]string, 0, len(lines))
+		for _, line := range lines {
+			texts = append(texts, line.String())
+		}
+		if diff := cmp.Diff([]string{"a", "日", "本"}, texts); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("non-positive width", func(t *testing.T) {
+		if lines := s.Wrap(0); len(lines) != 1 || lines[0].String() != "abcdefgh" {
+			t.Fatalf("expected string to be returned as is but got %v", lines)
+		}
+	})
+}
+
+func TestStyledString_Highlight(t *testing.T) {
+	s := NewStyledString("error: ", StatusFailed)
+	s.Append("errors found")
+
+	t.Run("occurrences spanning parts of the string", func(t *testing.T) {
+		expected := StyledString{components: []elementaryString{
+			{Content: "error", Classes: []Class{StatusFailed, SearchMatch}},
+			{Content: ": ", Classes: []Class{StatusFailed}},
+			{Content: "error", Classes: []Class{SearchMatch}},
+			{Content: "s found", Classes: nil},
+		}}
+		if diff := cmp.Diff(expected, s.Highlight("error", SearchMatch), cmp.AllowUnexported(StyledString{})); diff != "" {
+			t.Fatal(diff)
+		}
+
+		expected = StyledString{components: []elementaryString{
+			{Content: "error", Classes: []Class{StatusFailed}},
+			{Content: ": ", Classes: []Class{StatusFailed, SearchMatch}},
+			{Content: "e", Classes: []Class{SearchMatch}},
+			{Content: "rrors found", Classes: nil},
+		}}
+		if diff := cmp.Diff(expected, s.Highlight(": e", SearchMatch), cmp.AllowUnexported(StyledString{})); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("no occurrence", func(t *testing.T) {
+		if diff := cmp.Diff(s, s.Highlight("warning", SearchMatch), cmp.AllowUnexported(StyledString{})); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
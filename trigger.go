@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// variableFlags collects the variables passed with repeated --var options
+type variableFlags []cache.Variable
+
+func (vs *variableFlags) String() string {
+	s := make([]string, 0, len(*vs))
+	for _, v := range *vs {
+		s = append(s, fmt.Sprintf("%s=%s", v.Name, v.Value))
+	}
+	return strings.Join(s, " ")
+}
+
+func (vs *variableFlags) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("invalid variable %q (expected NAME=VALUE)", s)
+	}
+	*vs = append(*vs, cache.Variable{Name: s[:i], Value: s[i+1:]})
+	return nil
+}
+
+type triggerArguments struct {
+	repository string
+	providers  string
+	variables  variableFlags
+	ref        string
+}
+
+func parseTriggerArguments(args []string, defaultRepository string, defaultRef string) (triggerArguments, error) {
+	a := triggerArguments{ref: defaultRef}
+	f := flag.NewFlagSet("citop trigger", flag.ContinueOnError)
+	f.SetOutput(bytes.NewBuffer(nil))
+	f.StringVar(&a.repository, "repository", defaultRepository, "")
+	f.StringVar(&a.repository, "r", defaultRepository, "")
+	f.StringVar(&a.providers, "provider", "", "")
+	f.Var(&a.variables, "var", "")
+	if err := f.Parse(args); err != nil {
+		return a, err
+	}
+
+	if refs := f.Args(); len(refs) == 1 {
+		a.ref = refs[0]
+	} else if len(refs) > 1 {
+		return a, errors.New("at most one ref can be specified")
+	}
+
+	return a, nil
+}
+
+// Return the identifiers of the providers selected with --provider, nil if all providers are
+// selected
+func (a triggerArguments) providerIDs() []string {
+	if a.providers == "" {
+		return nil
+	}
+	ids := make([]string, 0)
+	for _, id := range strings.Split(a.providers, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Return the URL of the repository 'repo' and the branch or tag designated by 'ref'. HEAD
+// stands for the branch checked out in the local repository.
+func triggerTarget(repo string, ref string) (string, string, error) {
+	repositoryURL, commit, err := utils.GitOriginURL(repo, ref)
+	if err != nil {
+		// 'repo' is not a local repository so it must be the URL of an online repository
+		if ref == "HEAD" {
+			return "", "", errors.New("a branch or a tag must be specified for an online repository")
+		}
+		return repo, ref, nil
+	}
+	if ref == "HEAD" {
+		if commit.Head == "" {
+			return "", "", errors.New("HEAD is detached, specify the branch or the tag to run pipelines on")
+		}
+		ref = commit.Head
+	}
+	return repositoryURL, ref, nil
+}
+
+var ErrNoPipelineTriggered = errors.New("no provider started a pipeline")
+
+// runTrigger starts a pipeline on 'ref' of the repository at 'repositoryURL' with every provider
+// of 'ciProviders' able to do so (see cache.PipelineTrigger), or only with the providers whose
+// identifier is listed in 'ids' unless 'ids' is empty. The web page of each pipeline is written
+// to 'w'. Providers that do not host the repository are skipped.
+func runTrigger(ctx context.Context, w io.Writer, repositoryURL string, ref string, variables []cache.Variable, ciProviders []cache.CIProvider, ids []string) error {
+	known := make(map[string]bool, len(ciProviders))
+	for _, p := range ciProviders {
+		known[p.ID()] = true
+	}
+	selected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if !known[id] {
+			return fmt.Errorf("no provider with identifier %q in the configuration file", id)
+		}
+		selected[id] = true
+	}
+	_, owner, repo, err := utils.RepoHostOwnerAndName(repositoryURL)
+	if err != nil {
+		return err
+	}
+
+	triggered := 0
+	for _, p := range ciProviders {
+		explicit := selected[p.ID()]
+		if len(ids) > 0 && !explicit {
+			continue
+		}
+		trigger, ok := p.(cache.PipelineTrigger)
+		if !ok || !cache.UsedFor(p, owner, repo) {
+			if explicit && !ok {
+				return fmt.Errorf("provider %q cannot start pipelines", p.ID())
+			}
+			if explicit {
+				return fmt.Errorf("provider %q is not used for the repository %s/%s", p.ID(), owner, repo)
+			}
+			continue
+		}
+
+		webURL, err := trigger.TriggerPipeline(ctx, repositoryURL, ref, variables)
+		switch err {
+		case nil:
+			fmt.Fprintf(w, "%s: pipeline started on %s %s\n", p.ID(), ref, webURL)
+			triggered++
+		case cache.ErrUnknownURL, cache.ErrRepositoryNotFound:
+			if explicit {
+				return fmt.Errorf("provider %q does not know the repository %s", p.ID(), repositoryURL)
+			}
+		case cache.ErrUnsupportedAction:
+			// Such as a GitHub repository without any workflow accepting workflow_dispatch
+			if explicit {
+				return fmt.Errorf("provider %q cannot start pipelines of the repository %s", p.ID(), repositoryURL)
+			}
+		default:
+			return fmt.Errorf("%s: %v", p.ID(), err)
+		}
+	}
+
+	if triggered == 0 {
+		return ErrNoPipelineTriggered
+	}
+	return nil
+}
+
+// Start pipelines as requested by the arguments of 'citop trigger' and return the arguments of
+// citop monitoring them along with the exit status of citop
+func runTriggerCommand(args []string) ([]string, int) {
+	defaultRepository, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return nil, exitError
+	}
+	a, err := parseTriggerArguments(args, defaultRepository, "HEAD")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		fmt.Fprintln(os.Stderr, usage())
+		return nil, exitError
+	}
+	repositoryURL, ref, err := triggerTarget(a.repository, a.ref)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return nil, exitError
+	}
+
+	paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
+	config, err := loadConfiguration(paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return nil, exitError
+	}
+	ctx := context.Background()
+	_, ciProviders, err := config.Providers.Providers(ctx, http.DefaultTransport)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return nil, exitError
+	}
+
+	if err := runTrigger(ctx, os.Stdout, repositoryURL, ref, a.variables, ciProviders, a.providerIDs()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return nil, exitError
+	}
+
+	return []string{"--repository", a.repository, ref}, 0
+}
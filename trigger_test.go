@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+func TestParseTriggerArguments(t *testing.T) {
+	testCases := []struct {
+		args      []string
+		arguments triggerArguments
+	}{
+		{
+			args:      nil,
+			arguments: triggerArguments{repository: "repo", ref: "HEAD"},
+		},
+		{
+			args: []string{"-r", "gitlab.com/nbedos/citop", "--provider", "gitlab-0", "--var", "DEPLOY=true", "--var", "EMPTY=", "master"},
+			arguments: triggerArguments{
+				repository: "gitlab.com/nbedos/citop",
+				providers:  "gitlab-0",
+				variables:  variableFlags{{Name: "DEPLOY", Value: "true"}, {Name: "EMPTY", Value: ""}},
+				ref:        "master",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(strings.Join(testCase.args, " "), func(t *testing.T) {
+			a, err := parseTriggerArguments(testCase.args, "repo", "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.arguments, a, cmp.AllowUnexported(triggerArguments{})); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	for _, args := range [][]string{
+		{"--var", "DEPLOY"},
+		{"--var", "=true"},
+		{"master", "feature"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			if _, err := parseTriggerArguments(args, "repo", "HEAD"); err == nil {
+				t.Fatal("expected error but got nil")
+			}
+		})
+	}
+}
+
+type mockTrigger struct {
+	id        string
+	host      string
+	triggered []string
+}
+
+func (p *mockTrigger) ID() string { return p.id }
+func (p *mockTrigger) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	return "", nil
+}
+func (p *mockTrigger) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	return cache.Build{}, cache.ErrUnknownURL
+}
+func (p *mockTrigger) TriggerPipeline(ctx context.Context, repositoryURL string, ref string, variables []cache.Variable) (string, error) {
+	if !strings.HasPrefix(repositoryURL, p.host+"/") {
+		return "", cache.ErrUnknownURL
+	}
+	p.triggered = append(p.triggered, ref)
+	return "https://" + p.host + "/pipelines/1", nil
+}
+
+// Provider unable to start pipelines
+type mockCIProvider struct {
+	id string
+}
+
+func (p mockCIProvider) ID() string { return p.id }
+func (p mockCIProvider) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	return "", nil
+}
+func (p mockCIProvider) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	return cache.Build{}, cache.ErrUnknownURL
+}
+
+func TestRunTrigger(t *testing.T) {
+	newProviders := func() (*mockTrigger, *mockTrigger, []cache.CIProvider) {
+		gitlab := &mockTrigger{id: "gitlab-0", host: "gitlab.com"}
+		travis := &mockTrigger{id: "travis-0", host: "github.com"}
+		return gitlab, travis, []cache.CIProvider{gitlab, travis, mockCIProvider{id: "appveyor-0"}}
+	}
+
+	t.Run("every provider knowing the repository", func(t *testing.T) {
+		gitlab, travis, ps := newProviders()
+		w := bytes.Buffer{}
+		if err := runTrigger(context.Background(), &w, "gitlab.com/nbedos/citop", "master", nil, ps, nil); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"master"}, gitlab.triggered); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+		if len(travis.triggered) > 0 {
+			t.Fatal("no pipeline must be started by a provider not hosting the repository")
+		}
+		if expected := "gitlab-0: pipeline started on master https://gitlab.com/pipelines/1\n"; w.String() != expected {
+			t.Fatalf("expected %q but got %q", expected, w.String())
+		}
+	})
+
+	t.Run("no provider knowing the repository", func(t *testing.T) {
+		_, _, ps := newProviders()
+		err := runTrigger(context.Background(), &bytes.Buffer{}, "bitbucket.org/nbedos/citop", "master", nil, ps, nil)
+		if err != ErrNoPipelineTriggered {
+			t.Fatalf("expected %v but got %v", ErrNoPipelineTriggered, err)
+		}
+	})
+
+	for _, ids := range [][]string{{"travis-0"}, {"appveyor-0"}, {"unknown"}} {
+		t.Run(strings.Join(ids, ","), func(t *testing.T) {
+			gitlab, _, ps := newProviders()
+			if err := runTrigger(context.Background(), &bytes.Buffer{}, "gitlab.com/nbedos/citop", "master", nil, ps, ids); err == nil {
+				t.Fatal("expected error but got nil")
+			}
+			if len(gitlab.triggered) > 0 {
+				t.Fatal("no pipeline must be started by a provider not selected")
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+)
+
+// RunAccessible monitors the same pipelines as RunApplication but without taking control of the
+// terminal. It writes a flat, line-oriented description of the pipelines to 'w' and then a new
+// line every time the state of a pipeline, stage or job changes. There are no box-drawing
+// characters or colors in the output so that it can be followed with a screen reader. A
+// PolicyError is returned if the final state of the pipelines violates 'policy' and ErrTimeout
+// if pipelines are still running when the deadline of 'ctx' expires.
+func RunAccessible(ctx context.Context, w io.Writer, repo string, sha string, CIProviders []cache.CIProvider, SourceProviders []cache.SourceProvider, filter Filter, loc *time.Location, policy FailurePolicy) error {
+	if len(CIProviders) == 0 || len(SourceProviders) == 0 {
+		return ErrNoProvider
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	repositoryURL, commit, err := resolveCommit(ctx, repo, sha, SourceProviders)
+	if err != nil {
+		return err
+	}
+	for _, line := range commit.Strings() {
+		if _, err := fmt.Fprintln(w, line.String()); err != nil {
+			return err
+		}
+	}
+
+	engine := cache.NewEngine(CIProviders, SourceProviders)
+	filter.apply(engine.Cache())
+	source := NewBuildsByCommit(engine.Cache())
+
+	updates := engine.Subscribe()
+	if err := engine.Start(ctx, repositoryURL, commit.Sha); err != nil {
+		return err
+	}
+
+	a := newAnnouncer(w, loc)
+	for {
+		select {
+		case _, ok := <-updates:
+			if !ok {
+				return policy.outcome(ctx, engine.Wait(), engine.Cache().Builds())
+			}
+			if err := a.Announce(source.Rows()); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return policy.outcome(ctx, ctx.Err(), engine.Cache().Builds())
+		}
+	}
+}
+
+// announcer describes rows as plain text and only reports rows that are new or whose state
+// changed since the last call to Announce
+type announcer struct {
+	w      io.Writer
+	loc    *time.Location
+	states map[interface{}]string
+}
+
+func newAnnouncer(w io.Writer, loc *time.Location) announcer {
+	return announcer{
+		w:      w,
+		loc:    loc,
+		states: make(map[interface{}]string),
+	}
+}
+
+func (a *announcer) Announce(rows []HierarchicalTabularSourceRow) error {
+	for _, row := range rows {
+		if err := a.announce(row, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *announcer) announce(row HierarchicalTabularSourceRow, path []string) error {
+	values := row.Tabular(a.loc)
+	name := strings.TrimSpace(values["NAME"].String())
+	switch values["TYPE"].String() {
+	case "P":
+		name = fmt.Sprintf("%s pipeline %s", name, values["PIPELINE"].String())
+	case "S":
+		name = "stage " + name
+	case "J":
+		name = "job " + name
+	case "D":
+		name = "deployment to " + name
+	case "A":
+		name = "annotation " + name
+	}
+	path = append(path, name)
+
+	state := values["STATE"].String()
+	previousState, exists := a.states[row.Key()]
+	var line string
+	switch {
+	case !exists:
+		line = fmt.Sprintf("%s: %s", strings.Join(path, ", "), state)
+	case previousState != state:
+		line = fmt.Sprintf("%s: %s -> %s", strings.Join(path, ", "), previousState, state)
+	}
+	a.states[row.Key()] = state
+
+	if line != "" {
+		if _, err := fmt.Fprintln(a.w, line); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range row.Children() {
+		if err := a.announce(child.(HierarchicalTabularSourceRow), path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package tui
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+func TestAnnouncer_Announce(t *testing.T) {
+	repository := cache.Repository{
+		Provider: cache.Provider{
+			ID:   "gitlab-0",
+			Name: "gitlab",
+		},
+	}
+	build := cache.Build{
+		Repository: &repository,
+		ID:         "42",
+		State:      cache.Running,
+		UpdatedAt:  time.Date(2019, 11, 24, 14, 52, 0, 0, time.UTC),
+		Stages: map[int]*cache.Stage{
+			1: {
+				ID:    1,
+				Name:  "tests",
+				State: cache.Running,
+				Jobs: []*cache.Job{
+					{ID: "1", Name: "unit", State: cache.Running},
+				},
+			},
+		},
+	}
+
+	c := cache.NewCache(nil, nil)
+	source := NewBuildsByCommit(&c)
+	buf := bytes.Buffer{}
+	a := newAnnouncer(&buf, time.UTC)
+
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Announce(source.Rows()); err != nil {
+		t.Fatal(err)
+	}
+
+	build.State = cache.Passed
+	build.UpdatedAt = build.UpdatedAt.Add(time.Minute)
+	build.Stages[1].State = cache.Passed
+	build.Stages[1].Jobs[0].State = cache.Passed
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Announce(source.Rows()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing changed so nothing must be announced
+	if err := a.Announce(source.Rows()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `gitlab pipeline #42: running
+gitlab pipeline #42, stage tests: running
+gitlab pipeline #42, stage tests, job unit: running
+gitlab pipeline #42: running -> passed
+gitlab pipeline #42, stage tests: running -> passed
+gitlab pipeline #42, stage tests, job unit: running -> passed
+`
+	if diff := cmp.Diff(expected, buf.String()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,221 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/text"
+	"github.com/nbedos/citop/utils"
+)
+
+// Largest artifact shown in the pager, larger artifacts are left to the web browser
+const maxViewableArtifactSize = 1 << 20
+
+var ErrArtifactNotViewable = errors.New("artifact too large or not a text file")
+
+type artifactRowKey struct {
+	path string
+}
+
+type artifactRow struct {
+	key    artifactRowKey
+	size   int64
+	url    string
+	prefix string
+}
+
+func (r artifactRow) Traversable() bool {
+	return false
+}
+
+func (r artifactRow) Children() []utils.TreeNode {
+	return nil
+}
+
+func (r *artifactRow) SetTraversable(traversable bool, recursive bool) {}
+
+func (r *artifactRow) SetPrefix(s string) {
+	r.prefix = s
+}
+
+func (r artifactRow) Key() interface{} {
+	return r.key
+}
+
+func (r artifactRow) URL() string {
+	return r.url
+}
+
+func (r artifactRow) Tabular(loc *time.Location) map[string]text.StyledString {
+	return map[string]text.StyledString{
+		"SIZE": text.NewStyledString(formatSize(r.size)),
+		"NAME": text.NewStyledString(r.prefix + r.key.path),
+	}
+}
+
+// Return a size in bytes in a human readable form such as "12.3 KiB", or "-" if the size is
+// negative, i.e. unknown
+func formatSize(size int64) string {
+	if size < 0 {
+		return "-"
+	}
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / 1024
+	for _, unit := range []string{"KiB", "MiB", "GiB"} {
+		if value < 1024 || unit == "GiB" {
+			return fmt.Sprintf("%.1f %s", value, unit)
+		}
+		value /= 1024
+	}
+	return ""
+}
+
+// JobArtifacts lists the artifacts of a job. Small text artifacts are written to disk to be
+// viewed in the pager.
+type JobArtifacts struct {
+	cache     cache.Cache
+	job       buildRowKey
+	artifacts []cache.Artifact
+}
+
+// Artifacts returns a data source listing the artifacts of the job designated by 'key' along
+// with a description of the job
+func (s BuildsByCommit) Artifacts(ctx context.Context, key interface{}) (HierarchicalTabularDataSource, string, error) {
+	job, err := s.job(key)
+	if err != nil {
+		return nil, "", err
+	}
+	buildKey := key.(buildRowKey)
+	artifacts, err := s.cache.Artifacts(ctx, buildKey.accountID, buildKey.buildID, buildKey.stageID, buildKey.jobID)
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].Path < artifacts[j].Path
+	})
+
+	return JobArtifacts{
+		cache:     s.cache,
+		job:       buildKey,
+		artifacts: artifacts,
+	}, fmt.Sprintf("job %q", job.Name), nil
+}
+
+func (s JobArtifacts) Headers() []string {
+	return []string{"SIZE", "NAME"}
+}
+
+func (s JobArtifacts) Alignment() map[string]text.Alignment {
+	return map[string]text.Alignment{
+		"SIZE": text.Right,
+		"NAME": text.Left,
+	}
+}
+
+func (s JobArtifacts) Rows() []HierarchicalTabularSourceRow {
+	rows := make([]HierarchicalTabularSourceRow, 0, len(s.artifacts))
+	for _, artifact := range s.artifacts {
+		rows = append(rows, &artifactRow{
+			key:  artifactRowKey{path: artifact.Path},
+			size: artifact.Size,
+			url:  artifact.WebURL,
+		})
+	}
+	return rows
+}
+
+// Return the artifact of the row designated by 'key'
+func (s JobArtifacts) artifact(key interface{}) (cache.Artifact, error) {
+	artifactKey, ok := key.(artifactRowKey)
+	if !ok {
+		return cache.Artifact{}, fmt.Errorf("key conversion to artifactRowKey failed: '%v'", key)
+	}
+	for _, artifact := range s.artifacts {
+		if artifact.Path == artifactKey.path {
+			return artifact, nil
+		}
+	}
+	return cache.Artifact{}, fmt.Errorf("no artifact found at %q", artifactKey.path)
+}
+
+// Buffer failing once more than 'limit' bytes are written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, ErrArtifactNotViewable
+	}
+	return b.Buffer.Write(p)
+}
+
+// Return true if 'content' looks like text that can be shown in a terminal
+func isText(content []byte) bool {
+	return utf8.Valid(content) && bytes.IndexByte(content, 0) < 0
+}
+
+// WriteToDisk downloads the artifact designated by 'key' to 'dir' and returns the path of the
+// file. ErrArtifactNotViewable is returned for artifacts larger than 1 MiB and binary files.
+func (s JobArtifacts) WriteToDisk(ctx context.Context, key interface{}, dir string) (string, error) {
+	artifact, err := s.artifact(key)
+	if err != nil {
+		return "", err
+	}
+	if artifact.Size > maxViewableArtifactSize {
+		return "", ErrArtifactNotViewable
+	}
+
+	buf := limitedBuffer{limit: maxViewableArtifactSize}
+	err = s.cache.WriteArtifact(ctx, s.job.accountID, s.job.buildID, s.job.stageID, s.job.jobID, artifact.Path, &buf)
+	if err != nil {
+		return "", err
+	}
+	if !isText(buf.Bytes()) {
+		return "", ErrArtifactNotViewable
+	}
+
+	file, err := ioutil.TempFile(dir, "artifact_*_"+strings.Replace(path.Base(artifact.Path), "*", "", -1))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, filepath.Base(file.Name())), nil
+}
+
+// Details describes the artifact designated by 'key'
+func (s JobArtifacts) Details(key interface{}) (string, error) {
+	artifact, err := s.artifact(key)
+	if err != nil {
+		return "", ErrNoDetailsHere
+	}
+
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "Artifact: %s\n", artifact.Path)
+	size := formatSize(artifact.Size)
+	if artifact.Size >= 1024 {
+		size = fmt.Sprintf("%s (%d bytes)", size, artifact.Size)
+	}
+	fmt.Fprintf(&b, "Size:     %s\n", size)
+	if artifact.WebURL != "" {
+		fmt.Fprintf(&b, "URL:      %s\n", artifact.WebURL)
+	}
+	return b.String(), nil
+}
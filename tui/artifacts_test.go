@@ -0,0 +1,190 @@
+package tui
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/text"
+)
+
+type mockArtifactProvider struct {
+	mockProvider
+	files map[string]string
+}
+
+func (p mockArtifactProvider) Artifacts(ctx context.Context, build cache.Build, job cache.Job) ([]cache.Artifact, error) {
+	artifacts := make([]cache.Artifact, 0, len(p.files))
+	for path, content := range p.files {
+		artifacts = append(artifacts, cache.Artifact{
+			Path:   path,
+			Size:   int64(len(content)),
+			WebURL: "https://example.com/" + path,
+		})
+	}
+	return artifacts, nil
+}
+
+func (p mockArtifactProvider) WriteArtifact(ctx context.Context, build cache.Build, job cache.Job, path string, w io.Writer) error {
+	_, err := io.WriteString(w, p.files[path])
+	return err
+}
+
+func TestFormatSize(t *testing.T) {
+	testCases := []struct {
+		size     int64
+		expected string
+	}{
+		{size: -1, expected: "-"},
+		{size: 0, expected: "0 B"},
+		{size: 1023, expected: "1023 B"},
+		{size: 1536, expected: "1.5 KiB"},
+		{size: 5 << 20, expected: "5.0 MiB"},
+		{size: 3 << 40, expected: "3072.0 GiB"},
+	}
+	for _, testCase := range testCases {
+		if s := formatSize(testCase.size); s != testCase.expected {
+			t.Fatalf("expected %q for %d bytes but got %q", testCase.expected, testCase.size, s)
+		}
+	}
+}
+
+func TestBuildsByCommit_Artifacts(t *testing.T) {
+	c := cache.NewCache([]cache.CIProvider{mockArtifactProvider{
+		mockProvider: mockProvider{id: "id"},
+		files: map[string]string{
+			"report.txt":  "coverage: 87%\n",
+			"binary":      "\x7fELF\x00\x01",
+			"huge.log":    strings.Repeat("a", maxViewableArtifactSize+1),
+			"dist/app.js": "console.log('ok')\n",
+		},
+	}}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+	ctx := context.Background()
+
+	if _, _, err := source.Artifacts(ctx, buildAsRow.key); err != ErrNoJobHere {
+		t.Fatalf("expected %v but got %v", ErrNoJobHere, err)
+	}
+	artifacts, name, err := source.Artifacts(ctx, jobAsRow.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != `job "golang 1.12"` {
+		t.Fatalf("expected %q but got %q", `job "golang 1.12"`, name)
+	}
+
+	paths := make([]string, 0)
+	for _, row := range artifacts.Rows() {
+		paths = append(paths, row.Key().(artifactRowKey).path)
+	}
+	if strings.Join(paths, " ") != "binary dist/app.js huge.log report.txt" {
+		t.Fatalf("unexpected artifacts %v", paths)
+	}
+
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filePath, err := artifacts.WriteToDisk(ctx, artifactRowKey{path: "report.txt"}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "coverage: 87%\n" {
+		t.Fatalf("unexpected content %q", string(content))
+	}
+	for _, p := range []string{"binary", "huge.log"} {
+		if _, err := artifacts.WriteToDisk(ctx, artifactRowKey{path: p}, dir); err != ErrArtifactNotViewable {
+			t.Fatalf("expected %v for %q but got %v", ErrArtifactNotViewable, p, err)
+		}
+	}
+
+	details, err := artifacts.Details(artifactRowKey{path: "report.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(details, "Size:     14 B") || !strings.Contains(details, "https://example.com/report.txt") {
+		t.Fatalf("unexpected details %q", details)
+	}
+}
+
+func TestController_toggleArtifacts(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, text.StyleSheet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+	c := cache.NewCache([]cache.CIProvider{mockArtifactProvider{
+		mockProvider: mockProvider{id: "id"},
+		files:        map[string]string{"report.txt": "ok\n"},
+	}}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+	controller, err := NewController(&tui, &source, time.UTC, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.resize(80, 20)
+	controller.refresh()
+	ctx := context.Background()
+
+	if !controller.table.Jump(jobAsRow.key) {
+		t.Fatal("job row not found")
+	}
+	if err := controller.toggleArtifacts(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Artifacts are listed in the background
+	if err := applyOutcome(t, &controller); err != nil {
+		t.Fatal(err)
+	}
+	if controller.artifacts == nil {
+		t.Fatal("expected the artifacts of the job to be shown")
+	}
+	if key, _ := controller.table.ActiveKey(); key != (artifactRowKey{path: "report.txt"}) {
+		t.Fatalf("unexpected row at the cursor: %v", key)
+	}
+
+	if err := controller.toggleArtifacts(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if controller.artifacts != nil {
+		t.Fatal("expected the pipelines to be shown again")
+	}
+	if key, _ := controller.table.ActiveKey(); key != jobAsRow.key {
+		t.Fatalf("expected the cursor on %v but got %v", jobAsRow.key, key)
+	}
+
+	// Pipelines have no artifacts
+	controller.table.Top()
+	if err := controller.toggleArtifacts(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyOutcome(t, &controller); err != nil {
+		t.Fatal(err)
+	}
+	if controller.artifacts != nil {
+		t.Fatal("artifacts must only be listed for jobs")
+	}
+}
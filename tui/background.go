@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Background designates the brightness of the background color of the terminal
+type Background int
+
+const (
+	UnknownBackground Background = iota
+	DarkBackground
+	LightBackground
+)
+
+var errBackgroundQueryUnsupported = errors.New("querying the background color of the terminal is not supported on this platform")
+
+// DetectBackground tries to find out if the terminal has a dark or light background. The
+// COLORFGBG environment variable is used if set, otherwise the terminal is asked for its
+// background color with the OSC 11 control sequence. UnknownBackground is returned if both
+// methods fail.
+func DetectBackground() Background {
+	if bg := backgroundFromCOLORFGBG(os.Getenv("COLORFGBG")); bg != UnknownBackground {
+		return bg
+	}
+
+	response, err := queryTerminal("\x1b]11;?\x1b\\", 200*time.Millisecond)
+	if err != nil {
+		return UnknownBackground
+	}
+	r, g, b, err := parseOSC11Response(response)
+	if err != nil {
+		return UnknownBackground
+	}
+
+	return backgroundFromRGB(r, g, b)
+}
+
+// COLORFGBG is set by some terminal emulators (rxvt, konsole...) to "fg;bg" or "fg;default;bg"
+// where fg and bg are indices in the 16-color ANSI palette
+func backgroundFromCOLORFGBG(s string) Background {
+	if s == "" {
+		return UnknownBackground
+	}
+	cs := strings.Split(s, ";")
+	bg, err := strconv.Atoi(cs[len(cs)-1])
+	if err != nil {
+		return UnknownBackground
+	}
+
+	switch {
+	case bg == 7 || (bg >= 9 && bg <= 15):
+		return LightBackground
+	case bg >= 0 && bg <= 15:
+		return DarkBackground
+	default:
+		return UnknownBackground
+	}
+}
+
+// Parse the response of the terminal to the query "OSC 11 ; ? ST", e.g.
+// "\x1b]11;rgb:ffff/ffff/ffff\x1b\\". Each component is scaled to the range [0, 1].
+func parseOSC11Response(s string) (float64, float64, float64, error) {
+	i := strings.Index(s, "rgb:")
+	if i < 0 {
+		return 0, 0, 0, fmt.Errorf("invalid OSC 11 response: %q", s)
+	}
+	s = strings.TrimRight(s[i+len("rgb:"):], "\x1b\\\a")
+
+	cs := strings.Split(s, "/")
+	if len(cs) != 3 {
+		return 0, 0, 0, fmt.Errorf("invalid OSC 11 response: %q", s)
+	}
+	values := make([]float64, 0, len(cs))
+	for _, c := range cs {
+		if len(c) == 0 || len(c) > 4 {
+			return 0, 0, 0, fmt.Errorf("invalid OSC 11 response: %q", s)
+		}
+		v, err := strconv.ParseUint(c, 16, 16)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		// Components are made of 1 to 4 hexadecimal digits
+		max := uint64(1)<<(4*uint(len(c))) - 1
+		values = append(values, float64(v)/float64(max))
+	}
+
+	return values[0], values[1], values[2], nil
+}
+
+func backgroundFromRGB(r, g, b float64) Background {
+	// Relative luminance as defined by ITU-R BT.709
+	if 0.2126*r+0.7152*g+0.0722*b > 0.5 {
+		return LightBackground
+	}
+	return DarkBackground
+}
@@ -0,0 +1,68 @@
+package tui
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBackgroundFromCOLORFGBG(t *testing.T) {
+	testCases := map[string]Background{
+		"":             UnknownBackground,
+		"15;0":         DarkBackground,
+		"0;15":         LightBackground,
+		"0;7":          LightBackground,
+		"7;default":    UnknownBackground,
+		"15;default;0": DarkBackground,
+		"0;default;15": LightBackground,
+	}
+
+	for value, expected := range testCases {
+		t.Run(value, func(t *testing.T) {
+			if bg := backgroundFromCOLORFGBG(value); bg != expected {
+				t.Fatalf("expected %v but got %v", expected, bg)
+			}
+		})
+	}
+}
+
+func TestParseOSC11Response(t *testing.T) {
+	t.Run("valid responses", func(t *testing.T) {
+		testCases := []struct {
+			response string
+			r, g, b  float64
+		}{
+			{"\x1b]11;rgb:ffff/ffff/ffff\x1b\\", 1, 1, 1},
+			{"\x1b]11;rgb:0000/0000/0000\a", 0, 0, 0},
+			{"\x1b]11;rgb:ff/80/00\x1b\\", 1, 128.0 / 255, 0},
+		}
+
+		for _, testCase := range testCases {
+			r, g, b, err := parseOSC11Response(testCase.response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, pair := range [][2]float64{{r, testCase.r}, {g, testCase.g}, {b, testCase.b}} {
+				if math.Abs(pair[0]-pair[1]) > 1e-6 {
+					t.Fatalf("expected (%v, %v, %v) but got (%v, %v, %v)", testCase.r, testCase.g, testCase.b, r, g, b)
+				}
+			}
+		}
+	})
+
+	t.Run("invalid responses", func(t *testing.T) {
+		for _, response := range []string{"", "\x1b]11;rgb:ffff/ffff\x1b\\", "\x1b]11;rgb:gggg/0/0\a"} {
+			if _, _, _, err := parseOSC11Response(response); err == nil {
+				t.Fatalf("expected error for response %q", response)
+			}
+		}
+	})
+}
+
+func TestBackgroundFromRGB(t *testing.T) {
+	if bg := backgroundFromRGB(1, 1, 1); bg != LightBackground {
+		t.Fatalf("expected %v but got %v", LightBackground, bg)
+	}
+	if bg := backgroundFromRGB(0.1, 0.1, 0.2); bg != DarkBackground {
+		t.Fatalf("expected %v but got %v", DarkBackground, bg)
+	}
+}
@@ -0,0 +1,173 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+var ErrNoCommit = errors.New("no commit in range")
+
+// Return the state of the job named 'job' on a commit given the pipelines of the commit. 'job'
+// is either the name of the job or the name of its stage and the name of the job separated by a
+// slash. If 'job' is empty, the state of the commit is derived from the state of all pipelines.
+// Unknown is returned if the state cannot be used to bisect, e.g. if the job did not run or is
+// still running.
+func bisectState(builds []cache.Build, job string) cache.State {
+	states := make([]cache.State, 0)
+	if job == "" {
+		for _, build := range builds {
+			states = append(states, build.State)
+		}
+	} else {
+		match := func(stage string, j cache.Job) {
+			if j.Name == job || cache.DurationKey(stage, j.Name) == job {
+				states = append(states, j.State)
+			}
+		}
+		for _, build := range builds {
+			for _, j := range build.Jobs {
+				match("", *j)
+			}
+			for _, stage := range build.Stages {
+				for _, j := range stage.Jobs {
+					match(stage.Name, *j)
+				}
+			}
+		}
+	}
+
+	state := cache.Unknown
+	for _, s := range states {
+		switch {
+		case s == cache.Failed:
+			return cache.Failed
+		case s.IsActive():
+			state = cache.Running
+		case s == cache.Passed && state == cache.Unknown:
+			state = cache.Passed
+		}
+	}
+	if state != cache.Passed {
+		return cache.Unknown
+	}
+	return state
+}
+
+// bisect returns the range of indices of 'shas' containing the first commit whose state is
+// Failed, the state of the commit preceding the first element of 'shas' being Passed and the
+// state of the last one being Failed. The range is reduced to a single commit unless commits
+// without a usable state prevent it, in which case the first failure is one of the commits of
+// the range. 'state' is called at most once per commit.
+func bisect(shas []string, state func(sha string) (cache.State, error)) (int, int, error) {
+	if len(shas) == 0 {
+		return 0, 0, ErrNoCommit
+	}
+
+	states := make(map[int]cache.State)
+	stateOf := func(i int) (cache.State, error) {
+		if s, exists := states[i]; exists {
+			return s, nil
+		}
+		s, err := state(shas[i])
+		if err != nil {
+			return s, err
+		}
+		states[i] = s
+		return s, nil
+	}
+
+	last := len(shas) - 1
+	s, err := stateOf(last)
+	if err != nil {
+		return 0, 0, err
+	}
+	if s != cache.Failed {
+		return 0, 0, fmt.Errorf("commit %s is not failing (state: %q)", shortSha(shas[last]), s)
+	}
+
+	// Invariant: the commit at index 'good' passed (-1 stands for the commit preceding the range)
+	// and the commit at index 'bad' failed
+	good, bad := -1, last
+	for {
+		// Look for the commit with a usable state that is the closest to the middle of the range
+		mid := (good + bad) / 2
+		found := -1
+		for offset := 0; found < 0; offset++ {
+			below, above := mid-offset, mid+offset+1
+			if below <= good && above >= bad {
+				break
+			}
+			for _, i := range []int{below, above} {
+				if i <= good || i >= bad || found >= 0 {
+					continue
+				}
+				s, err := stateOf(i)
+				if err != nil {
+					return 0, 0, err
+				}
+				if s != cache.Unknown {
+					found = i
+				}
+			}
+		}
+		if found < 0 {
+			return good + 1, bad, nil
+		}
+
+		if states[found] == cache.Failed {
+			bad = found
+		} else {
+			good = found
+		}
+	}
+}
+
+// RunBisect looks for the first commit of the range 'first..last' of the local repository
+// 'repo' on which the job 'job', or any pipeline if 'job' is empty, failed. It only relies on
+// the pipelines recorded by the providers and reports each commit inspected to 'w'.
+func RunBisect(ctx context.Context, w io.Writer, repo string, first string, last string, job string, CIProviders []cache.CIProvider, SourceProviders []cache.SourceProvider) error {
+	if len(CIProviders) == 0 || len(SourceProviders) == 0 {
+		return ErrNoProvider
+	}
+
+	repositoryURL, _, err := utils.GitOriginURL(repo, last)
+	if err != nil {
+		return err
+	}
+	shas, err := utils.GitCommitRange(repo, first, last)
+	if err != nil {
+		return err
+	}
+
+	c := cache.NewCache(CIProviders, SourceProviders)
+	state := func(sha string) (cache.State, error) {
+		builds, err := c.Pipelines(ctx, repositoryURL, sha)
+		if err != nil {
+			return cache.Unknown, err
+		}
+		s := bisectState(builds, job)
+		description := string(s)
+		if s == cache.Unknown {
+			description = "no result, skipped"
+		}
+		_, err = fmt.Fprintf(w, "commit %s: %s\n", shortSha(sha), description)
+		return s, err
+	}
+
+	i, j, err := bisect(shas, state)
+	if err != nil {
+		return err
+	}
+	if i == j {
+		_, err = fmt.Fprintf(w, "first failing commit: %s\n", shas[i])
+		return err
+	}
+	_, err = fmt.Fprintf(w, "first failing commit is one of: %s\n", strings.Join(shas[i:j+1], " "))
+	return err
+}
@@ -0,0 +1,117 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/nbedos/citop/cache"
+)
+
+func TestBisectState(t *testing.T) {
+	builds := []cache.Build{
+		{
+			State: cache.Failed,
+			Jobs:  []*cache.Job{{Name: "lint", State: cache.Passed}},
+			Stages: map[int]*cache.Stage{
+				1: {ID: 1, Name: "test", Jobs: []*cache.Job{
+					{Name: "unit", State: cache.Failed},
+					{Name: "e2e", State: cache.Running},
+				}},
+			},
+		},
+		{
+			State: cache.Passed,
+			Jobs:  []*cache.Job{{Name: "lint", State: cache.Passed}},
+		},
+	}
+
+	testCases := []struct {
+		job      string
+		expected cache.State
+	}{
+		{job: "", expected: cache.Failed},
+		{job: "lint", expected: cache.Passed},
+		{job: "unit", expected: cache.Failed},
+		{job: "test/unit", expected: cache.Failed},
+		{job: "e2e", expected: cache.Unknown},
+		{job: "unknown", expected: cache.Unknown},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.job, func(t *testing.T) {
+			if s := bisectState(builds, testCase.job); s != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, s)
+			}
+		})
+	}
+}
+
+func TestBisect(t *testing.T) {
+	shas := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+	testCases := []struct {
+		name string
+		// Index of the first failing commit
+		failing int
+		// Commits without a usable state
+		skipped map[string]bool
+		first   int
+		last    int
+	}{
+		{
+			name:    "last commit",
+			failing: 9,
+			first:   9,
+			last:    9,
+		},
+		{
+			name:    "first commit",
+			failing: 0,
+			first:   0,
+			last:    0,
+		},
+		{
+			name:    "middle commit",
+			failing: 3,
+			first:   3,
+			last:    3,
+		},
+		{
+			name:    "commits without result",
+			failing: 4,
+			skipped: map[string]bool{"2": true, "3": true, "4": true, "5": true},
+			first:   2,
+			last:    6,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			calls := make(map[string]int)
+			state := func(sha string) (cache.State, error) {
+				calls[sha]++
+				if calls[sha] > 1 {
+					t.Fatalf("state of commit %s requested twice", sha)
+				}
+				if testCase.skipped[sha] {
+					return cache.Unknown, nil
+				}
+				if sha >= shas[testCase.failing] {
+					return cache.Failed, nil
+				}
+				return cache.Passed, nil
+			}
+			first, last, err := bisect(shas, state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first != testCase.first || last != testCase.last {
+				t.Fatalf("expected [%d, %d] but got [%d, %d]", testCase.first, testCase.last, first, last)
+			}
+		})
+	}
+
+	t.Run("last commit not failing", func(t *testing.T) {
+		state := func(sha string) (cache.State, error) { return cache.Passed, nil }
+		if _, _, err := bisect(shas, state); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/utils"
+)
+
+// Line of a trailer such as "Signed-off-by: Name <email>"
+var trailerLine = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*: \S`)
+
+// commitTrailers returns the trailers of a commit message, that is to say the lines of its last
+// paragraph if all of them are trailers. The title of the message never holds trailers.
+func commitTrailers(message string) []string {
+	paragraphs := strings.Split(strings.Trim(strings.Replace(message, "\r\n", "\n", -1), "\n"), "\n\n")
+	if len(paragraphs) < 2 {
+		return nil
+	}
+	lines := strings.Split(strings.Trim(paragraphs[len(paragraphs)-1], "\n"), "\n")
+	for _, line := range lines {
+		if !trailerLine.MatchString(line) {
+			return nil
+		}
+	}
+	return lines
+}
+
+// commitDescription returns the complete description of a commit: its references, author, date,
+// signature status, full message, trailers and the files it changes
+func commitDescription(commit utils.Commit) string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "commit %s\n", commit.Sha)
+	if len(commit.Tags) > 0 {
+		fmt.Fprintf(&b, "Tags:      %s\n", strings.Join(commit.Tags, ", "))
+	}
+	if len(commit.Branches) > 0 {
+		fmt.Fprintf(&b, "Branches:  %s\n", strings.Join(commit.Branches, ", "))
+	}
+	fmt.Fprintf(&b, "Author:    %s\n", commit.Author)
+	fmt.Fprintf(&b, "Date:      %s\n", commit.Date.Truncate(time.Second).String())
+	signature := commit.Signature
+	if signature == "" {
+		signature = "unknown"
+	}
+	fmt.Fprintf(&b, "Signature: %s\n", signature)
+	if pr := commit.PullRequest; pr != nil {
+		fmt.Fprintf(&b, "Pull request: %s %s\n", pr.String(), pr.WebURL)
+	}
+
+	b.WriteString("\n")
+	for _, line := range strings.Split(strings.TrimRight(commit.Message, "\n"), "\n") {
+		if line = strings.TrimRight(line, "\r"); line == "" {
+			b.WriteString("\n")
+		} else {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+	}
+
+	if trailers := commitTrailers(commit.Message); len(trailers) > 0 {
+		b.WriteString("\nTrailers:\n")
+		for _, trailer := range trailers {
+			fmt.Fprintf(&b, "  %s\n", trailer)
+		}
+	}
+
+	if commit.Files != nil {
+		fmt.Fprintf(&b, "\nChanged files (%d):\n", len(commit.Files))
+		for _, file := range commit.Files {
+			fmt.Fprintf(&b, "  %-9s %s\n", file.Status, file.String())
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/utils"
+)
+
+func TestCommitTrailers(t *testing.T) {
+	testCases := []struct {
+		name     string
+		message  string
+		trailers []string
+	}{
+		{
+			name:     "title only",
+			message:  "Fix: typo\n",
+			trailers: nil,
+		},
+		{
+			name:     "body without trailers",
+			message:  "Title\n\nLong description\nof the change\n",
+			trailers: nil,
+		},
+		{
+			name:     "trailers",
+			message:  "Title\n\nBody\n\nSigned-off-by: Alice <alice@example.com>\nFixes: #12\n",
+			trailers: []string{"Signed-off-by: Alice <alice@example.com>", "Fixes: #12"},
+		},
+		{
+			name:     "last paragraph mixing trailers and text",
+			message:  "Title\n\nSigned-off-by: Alice <alice@example.com>\nand some text\n",
+			trailers: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.trailers, commitTrailers(testCase.message)); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestCommitDescription(t *testing.T) {
+	commit := utils.Commit{
+		Sha:       "a24840cf94b395af69da4a1001d32e3694637e20",
+		Author:    "Alice <alice@example.com>",
+		Date:      time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Message:   "Title\n\nBody\n\nCo-authored-by: Bob <bob@example.com>\n",
+		Branches:  []string{"master"},
+		Signature: "verified",
+		Files: []utils.ChangedFile{
+			{Status: "modified", Path: "main.go"},
+			{Status: "renamed", Path: "tui/b.go", PreviousPath: "tui/a.go"},
+		},
+	}
+
+	expected := `commit a24840cf94b395af69da4a1001d32e3694637e20
+Branches:  master
+Author:    Alice <alice@example.com>
+Date:      2020-01-02 03:04:05 +0000 UTC
+Signature: verified
+
+    Title
+
+    Body
+
+    Co-authored-by: Bob <bob@example.com>
+
+Trailers:
+  Co-authored-by: Bob <bob@example.com>
+
+Changed files (2):
+  modified  main.go
+  renamed   tui/a.go -> tui/b.go
+`
+	if diff := cmp.Diff(expected, commitDescription(commit)); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
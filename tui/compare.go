@@ -0,0 +1,234 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// comparisonRow associates a pipeline or a job of a commit to its counterpart on another commit.
+// The state is Unknown if there is no counterpart.
+type comparisonRow struct {
+	provider string
+	name     string
+	before   cache.State
+	after    cache.State
+}
+
+func (r comparisonRow) changed() bool {
+	return r.before != r.after
+}
+
+// Return the jobs of a build along with their names prefixed by the name of their stage
+func namedJobs(b cache.Build) ([]string, map[string]cache.Job) {
+	names := make([]string, 0)
+	jobs := make(map[string]cache.Job)
+	add := func(prefix string, job cache.Job) {
+		name := job.Name
+		if name == "" {
+			name = job.ID
+		}
+		name = prefix + name
+		if _, exists := jobs[name]; !exists {
+			names = append(names, name)
+		}
+		jobs[name] = job
+	}
+
+	for _, job := range b.Jobs {
+		add("", *job)
+	}
+	stageIDs := make([]int, 0, len(b.Stages))
+	for id := range b.Stages {
+		stageIDs = append(stageIDs, id)
+	}
+	sort.Ints(stageIDs)
+	for _, id := range stageIDs {
+		stage := b.Stages[id]
+		for _, job := range stage.Jobs {
+			add(stage.Name+" / ", *job)
+		}
+	}
+
+	return names, jobs
+}
+
+// Return the builds of each provider ordered by creation date
+func buildsByProvider(builds []cache.Build) ([]string, map[string][]cache.Build) {
+	providers := make([]string, 0)
+	byProvider := make(map[string][]cache.Build)
+	for _, b := range builds {
+		name := b.Repository.Provider.Name
+		if _, exists := byProvider[name]; !exists {
+			providers = append(providers, name)
+		}
+		byProvider[name] = append(byProvider[name], b)
+	}
+	sort.Strings(providers)
+	for _, bs := range byProvider {
+		sort.SliceStable(bs, func(i, j int) bool {
+			ti := utils.MinNullTime(bs[i].CreatedAt, bs[i].StartedAt)
+			tj := utils.MinNullTime(bs[j].CreatedAt, bs[j].StartedAt)
+			if !ti.Time.Equal(tj.Time) {
+				return ti.Time.Before(tj.Time)
+			}
+			return bs[i].ID < bs[j].ID
+		})
+	}
+	return providers, byProvider
+}
+
+func pipelineName(b *cache.Build) string {
+	if b == nil {
+		return "-"
+	}
+	return "#" + b.ID
+}
+
+// compareBuilds matches the pipelines of two commits and the jobs of these pipelines. Pipelines
+// are matched by provider and order of creation, and jobs by stage and name.
+func compareBuilds(before []cache.Build, after []cache.Build) []comparisonRow {
+	providersBefore, buildsBefore := buildsByProvider(before)
+	providersAfter, buildsAfter := buildsByProvider(after)
+	providers := append([]string(nil), providersBefore...)
+	for _, p := range providersAfter {
+		if _, exists := buildsBefore[p]; !exists {
+			providers = append(providers, p)
+		}
+	}
+	sort.Strings(providers)
+
+	rows := make([]comparisonRow, 0)
+	for _, provider := range providers {
+		bs, as := buildsBefore[provider], buildsAfter[provider]
+		for i := 0; i < utils.MaxInt(len(bs), len(as)); i++ {
+			var b, a *cache.Build
+			namesBefore, namesAfter := []string(nil), []string(nil)
+			jobsBefore, jobsAfter := map[string]cache.Job{}, map[string]cache.Job{}
+			row := comparisonRow{provider: provider}
+			if i < len(bs) {
+				b = &bs[i]
+				row.before = b.State
+				namesBefore, jobsBefore = namedJobs(*b)
+			}
+			if i < len(as) {
+				a = &as[i]
+				row.after = a.State
+				namesAfter, jobsAfter = namedJobs(*a)
+			}
+			row.name = fmt.Sprintf("pipeline %s / %s", pipelineName(b), pipelineName(a))
+			rows = append(rows, row)
+
+			names := namesBefore
+			for _, name := range namesAfter {
+				if _, exists := jobsBefore[name]; !exists {
+					names = append(names, name)
+				}
+			}
+			for _, name := range names {
+				rows = append(rows, comparisonRow{
+					provider: provider,
+					name:     "  " + name,
+					before:   jobsBefore[name].State,
+					after:    jobsAfter[name].State,
+				})
+			}
+		}
+	}
+
+	return rows
+}
+
+// ANSI escape sequences used to highlight the state of a row that changed between two commits
+var stateColors = map[cache.State]string{
+	cache.Failed:   "\x1b[31m",
+	cache.Canceled: "\x1b[31m",
+	cache.Passed:   "\x1b[32m",
+	cache.Running:  "\x1b[33m",
+	cache.Pending:  "\x1b[33m",
+}
+
+const colorReset = "\x1b[0m"
+
+// writeComparison writes one line per row with the state on each commit. Rows whose state
+// differs are marked with an exclamation mark and, if 'colors' is true, the new state is colored.
+func writeComparison(w io.Writer, rows []comparisonRow, labelBefore string, labelAfter string, colors bool) error {
+	stateString := func(s cache.State) string {
+		if s == cache.Unknown {
+			return "-"
+		}
+		return string(s)
+	}
+
+	headers := [4]string{"PROVIDER", "PIPELINE / JOB", labelBefore, labelAfter}
+	widths := [4]int{}
+	for i, header := range headers {
+		widths[i] = len(header)
+	}
+	for _, row := range rows {
+		widths[0] = utils.MaxInt(widths[0], len(row.provider))
+		widths[1] = utils.MaxInt(widths[1], len(row.name))
+		widths[2] = utils.MaxInt(widths[2], len(stateString(row.before)))
+		widths[3] = utils.MaxInt(widths[3], len(stateString(row.after)))
+	}
+	pad := func(s string, width int) string {
+		return s + strings.Repeat(" ", width-len(s))
+	}
+
+	line := fmt.Sprintf("  %s  %s  %s  %s", pad(headers[0], widths[0]), pad(headers[1], widths[1]), pad(headers[2], widths[2]), headers[3])
+	if _, err := fmt.Fprintln(w, line); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		marker := " "
+		after := stateString(row.after)
+		if row.changed() {
+			marker = "!"
+			if color, exists := stateColors[row.after]; colors && exists {
+				after = color + after + colorReset
+			}
+		}
+		line := fmt.Sprintf("%s %s  %s  %s  %s", marker, pad(row.provider, widths[0]), pad(row.name, widths[1]), pad(stateString(row.before), widths[2]), after)
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunCompare writes to 'w' the pipelines and jobs of the commit 'shaBefore' next to their
+// counterparts on the commit 'shaAfter' so that the jobs whose state changed between both
+// commits stand out
+func RunCompare(ctx context.Context, w io.Writer, repo string, shaBefore string, shaAfter string, CIProviders []cache.CIProvider, SourceProviders []cache.SourceProvider, colors bool) error {
+	if len(CIProviders) == 0 || len(SourceProviders) == 0 {
+		return ErrNoProvider
+	}
+
+	repositoryURL, before, err := resolveCommit(ctx, repo, shaBefore, SourceProviders)
+	if err != nil {
+		return err
+	}
+	_, after, err := resolveCommit(ctx, repo, shaAfter, SourceProviders)
+	if err != nil {
+		return err
+	}
+
+	c := cache.NewCache(CIProviders, SourceProviders)
+	buildsBefore, err := c.Pipelines(ctx, repositoryURL, before.Sha)
+	if err != nil {
+		return err
+	}
+	buildsAfter, err := c.Pipelines(ctx, repositoryURL, after.Sha)
+	if err != nil {
+		return err
+	}
+
+	rows := compareBuilds(buildsBefore, buildsAfter)
+	return writeComparison(w, rows, shortSha(before.Sha), shortSha(after.Sha), colors)
+}
@@ -0,0 +1,99 @@
+package tui
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+func TestCompareBuilds(t *testing.T) {
+	gitlab := &cache.Repository{Provider: cache.Provider{ID: "gitlab-0", Name: "gitlab"}}
+	travis := &cache.Repository{Provider: cache.Provider{ID: "travis-0", Name: "travis"}}
+
+	before := []cache.Build{
+		{
+			Repository: gitlab,
+			ID:         "1",
+			State:      cache.Passed,
+			Stages: map[int]*cache.Stage{
+				1: {ID: 1, Name: "build", Jobs: []*cache.Job{{ID: "11", Name: "compile", State: cache.Passed}}},
+				2: {ID: 2, Name: "test", Jobs: []*cache.Job{
+					{ID: "12", Name: "unit", State: cache.Passed},
+					{ID: "13", Name: "lint", State: cache.Passed},
+				}},
+			},
+		},
+		{
+			Repository: travis,
+			ID:         "5",
+			State:      cache.Passed,
+			Jobs:       []*cache.Job{{ID: "51", State: cache.Passed}},
+		},
+	}
+	after := []cache.Build{
+		{
+			Repository: gitlab,
+			ID:         "2",
+			State:      cache.Failed,
+			Stages: map[int]*cache.Stage{
+				1: {ID: 1, Name: "build", Jobs: []*cache.Job{{ID: "21", Name: "compile", State: cache.Passed}}},
+				2: {ID: 2, Name: "test", Jobs: []*cache.Job{
+					{ID: "22", Name: "unit", State: cache.Failed},
+					{ID: "23", Name: "e2e", State: cache.Passed},
+				}},
+			},
+		},
+	}
+
+	expected := []comparisonRow{
+		{provider: "gitlab", name: "pipeline #1 / #2", before: cache.Passed, after: cache.Failed},
+		{provider: "gitlab", name: "  build / compile", before: cache.Passed, after: cache.Passed},
+		{provider: "gitlab", name: "  test / unit", before: cache.Passed, after: cache.Failed},
+		{provider: "gitlab", name: "  test / lint", before: cache.Passed, after: cache.Unknown},
+		{provider: "gitlab", name: "  test / e2e", before: cache.Unknown, after: cache.Passed},
+		{provider: "travis", name: "pipeline #5 / -", before: cache.Passed, after: cache.Unknown},
+		{provider: "travis", name: "  51", before: cache.Passed, after: cache.Unknown},
+	}
+	rows := compareBuilds(before, after)
+	if diff := cmp.Diff(expected, rows, cmp.AllowUnexported(comparisonRow{})); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestWriteComparison(t *testing.T) {
+	rows := []comparisonRow{
+		{provider: "gitlab", name: "pipeline #1 / #2", before: cache.Passed, after: cache.Failed},
+		{provider: "gitlab", name: "  build / compile", before: cache.Passed, after: cache.Passed},
+		{provider: "gitlab", name: "  test / e2e", before: cache.Unknown, after: cache.Passed},
+	}
+
+	t.Run("without colors", func(t *testing.T) {
+		w := bytes.Buffer{}
+		if err := writeComparison(&w, rows, "c2bb562", "a1b2c3d", false); err != nil {
+			t.Fatal(err)
+		}
+		expected := "" +
+			"  PROVIDER  PIPELINE / JOB     c2bb562  a1b2c3d\n" +
+			"! gitlab    pipeline #1 / #2   passed   failed\n" +
+			"  gitlab      build / compile  passed   passed\n" +
+			"! gitlab      test / e2e       -        passed\n"
+		if diff := cmp.Diff(expected, w.String()); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("with colors", func(t *testing.T) {
+		w := bytes.Buffer{}
+		if err := writeComparison(&w, rows[:1], "c2bb562", "a1b2c3d", true); err != nil {
+			t.Fatal(err)
+		}
+		expected := "" +
+			"  PROVIDER  PIPELINE / JOB    c2bb562  a1b2c3d\n" +
+			"! gitlab    pipeline #1 / #2  passed   \x1b[31mfailed\x1b[0m\n"
+		if diff := cmp.Diff(expected, w.String()); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"regexp"
+	"text/template"
 	"time"
 
 	"github.com/gdamore/tcell"
@@ -19,16 +21,123 @@ type Controller struct {
 	tui           *TUI
 	header        *TextArea
 	table         *Table
+	footer        *TextArea
 	status        *StatusBar
 	tempDir       string
 	inputMode     bool
 	defaultStatus string
 	help          string
+	// Commit offered to the user by the last call to offer, nil if there is none
+	offered  *utils.Commit
+	accepted chan utils.Commit
+	// Outcomes of the operations run in the background, applied on the event loop by Run
+	outcomes chan func() error
+	// Path of the log marked by the user for comparison with another log, empty if there is none
+	markedLog string
+	// Whether the stages of pipelines are hidden by the user
+	flat bool
+	// Whether the jobs of all pipelines are listed in a flat table
+	jobsOnly bool
+	// Presentation of the timestamps prefixing the lines of logs
+	timestamps TimestampMode
+	// Whether lines of logs are prefixed with the time elapsed since the start of their job
+	gutter bool
+	// Whether the pager drops the colors of logs instead of showing them
+	stripColors bool
+	// Template of the path of the files logs are saved to
+	logPathTemplate *template.Template
+	// Column used to sort rows, empty for the default order
+	sortColumn string
+	reverse    bool
+	// Commit whose pipelines are shown and URL of its repository
+	commit        utils.Commit
+	repositoryURL string
+	// Description of the commit and incidents reported by the status pages of providers, both
+	// shown in the header
+	commitHeader []text.StyledString
+	incidents    []Incident
+	// Notices shown above the incidents, e.g. to describe the data unavailable in anonymous mode
+	notices []string
+	// Action waiting for the user to confirm it, nil if there is none
+	pending *pendingAction
+	// Number typed by the user to repeat the next movement, 0 if there is none
+	count int
+	// Key of the rows marked by the user, by letter. The mark "'" designates the row where the
+	// cursor was before the last jump.
+	marks map[rune]interface{}
+	// Command waiting for a letter to designate a mark (m to set a mark, a single quote to jump
+	// to a mark), 0 if there is none
+	markCommand rune
+	// Whether the prompt asks for a pattern to look for in the logs of a pipeline instead of a
+	// pattern to look for among rows. In that case the pattern of the last search among rows
+	// is kept aside.
+	searchingLogs bool
+	rowPattern    string
+	// Session whose folds and cursor position are being restored, nil once the user presses a
+	// key
+	session *Session
+	// Icons shown next to the states counted in the footer
+	icons StateIcons
+	// Time at which a pipeline of the commit was last updated, zero if no update was received
+	lastUpdate time.Time
+	// Providers whose rejection of the token of the user was already reported
+	rejectedTokens map[string]bool
+	// Table of pipelines replaced by the list of the artifacts of a job, nil if the artifacts
+	// of a job are not shown
+	artifacts *artifactsPanel
+	// Table replaced by the pager, nil if the pager is closed
+	pager *pagerPanel
+}
+
+// artifactsPanel keeps the source, the folds and the cursor of the table of pipelines while the
+// artifacts of a job are shown instead
+type artifactsPanel struct {
+	source HierarchicalTabularDataSource
+	// Session paths of the rows whose fold is open and of the row at the cursor (see
+	// Table.SessionState)
+	open   []string
+	cursor string
+	// Key of the row of the job
+	key interface{}
+}
+
+// pagerPanel keeps the source, the folds and the cursor of the table while a text such as the
+// log of a job is shown in the pager instead
+type pagerPanel struct {
+	source HierarchicalTabularDataSource
+	// Session paths of the rows whose fold is open and of the row at the cursor (see
+	// Table.SessionState)
+	open   []string
+	cursor string
+	// Key of the row at the cursor
+	key interface{}
+	// Stops following the log of a running job, nil if there is nothing to stop
+	stopFollowing context.CancelFunc
+	// Receives the outcome of the following of the log, nil once it is received
+	followed <-chan error
+}
+
+// pendingAction is an action confirmed by pressing its key again on the same row
+type pendingAction struct {
+	name string
+	key  interface{}
 }
 
 var ErrExit = errors.New("exit")
 
-func NewController(tui *TUI, source cache.HierarchicalTabularDataSource, loc *time.Location, tempDir string, defaultStatus string, help string) (Controller, error) {
+// Maximum number of times a movement can be repeated
+const maxCount = 9999
+
+// Number of screen columns the table is panned by at once
+const horizontalScrollStep = 8
+
+// Interval between two refreshes of the table in the absence of updates
+const refreshInterval = time.Second
+
+// Interval between two fetches of the log of a running job shown in the pager
+const logFollowInterval = 3 * time.Second
+
+func NewController(tui *TUI, source HierarchicalTabularDataSource, loc *time.Location, tempDir string, defaultStatus string, help string) (Controller, error) {
 	// Arbitrary values, the correct size will be set when the first RESIZE event is received
 	width, height := 10, 10
 	header, err := NewTextArea(width, height)
@@ -41,6 +150,11 @@ func NewController(tui *TUI, source cache.HierarchicalTabularDataSource, loc *ti
 		return Controller{}, err
 	}
 
+	footer, err := NewTextArea(width, 1)
+	if err != nil {
+		return Controller{}, err
+	}
+
 	status, err := NewStatusBar(width, height)
 	if err != nil {
 		return Controller{}, err
@@ -48,25 +162,76 @@ func NewController(tui *TUI, source cache.HierarchicalTabularDataSource, loc *ti
 	status.Write(defaultStatus)
 
 	return Controller{
-		tui:           tui,
-		header:        &header,
-		table:         &table,
-		status:        &status,
-		tempDir:       tempDir,
-		defaultStatus: defaultStatus,
-		help:          help,
+		tui:             tui,
+		header:          &header,
+		table:           &table,
+		footer:          &footer,
+		status:          &status,
+		tempDir:         tempDir,
+		defaultStatus:   defaultStatus,
+		help:            help,
+		accepted:        make(chan utils.Commit, 1),
+		outcomes:        make(chan func() error),
+		timestamps:      TimestampsKeep,
+		logPathTemplate: template.Must(NewLogPathTemplate(DefaultLogPathTemplate)),
+		marks:           make(map[rune]interface{}),
+		rejectedTokens:  make(map[string]bool),
 	}, nil
 }
 
-func (c *Controller) Run(ctx context.Context, updates <-chan time.Time) error {
+// Target designates the commit whose pipelines are shown by the controller
+type Target struct {
+	Commit utils.Commit
+	// Description of the commit shown at the top of the screen
+	Header []text.StyledString
+	Source HierarchicalTabularDataSource
+	// Channel notified every time a pipeline of the commit is updated
+	Updates <-chan cache.Event
+	// Message shown in the status bar once the target replaces the previous one
+	Status string
+}
+
+// Run processes terminal events and refreshes the table every time a value is received on
+// 'updates'. A value received on 'targets' replaces the commit being shown. A commit received
+// on 'offers' is suggested to the user and sent on the channel returned by Accepted if the user
+// agrees to monitor it. Incidents received on 'incidents' replace the incidents shown in the
+// header.
+func (c *Controller) Run(ctx context.Context, updates <-chan cache.Event, targets <-chan Target, offers <-chan utils.Commit, incidents <-chan []Incident) error {
+	// Refresh the table periodically so that values depending on the current time stay up to date
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
 	var err error
 	for err == nil {
 		select {
 		case <-ctx.Done():
 			err = ctx.Err()
-		case <-updates:
+		case <-ticker.C:
 			c.refresh()
 			c.draw()
+		case _, ok := <-updates:
+			if !ok {
+				// Pipelines are no longer monitored but the user may still browse them
+				updates = nil
+				continue
+			}
+			c.lastUpdate = time.Now()
+			c.refresh()
+			c.draw()
+		case target := <-targets:
+			updates = target.Updates
+			c.setTarget(target)
+			c.draw()
+		case commit := <-offers:
+			c.offer(commit)
+			c.draw()
+		case list := <-incidents:
+			c.incidents = list
+			c.writeHeader()
+			c.draw()
+		case apply := <-c.outcomes:
+			err = apply()
+			c.draw()
 		case event := <-c.tui.eventc:
 			err = c.process(ctx, event)
 		}
@@ -79,7 +244,97 @@ func (c *Controller) Run(ctx context.Context, updates <-chan time.Time) error {
 }
 
 func (c *Controller) SetHeader(lines []text.StyledString) {
+	c.commitHeader = lines
+	c.writeHeader()
+}
+
+// Write notices, incidents and the description of the commit to the header
+func (c *Controller) writeHeader() {
+	lines := make([]text.StyledString, 0, len(c.notices)+len(c.incidents)+len(c.commitHeader))
+	for _, notice := range c.notices {
+		lines = append(lines, text.NewStyledString("[i] "+notice, text.StatusPending))
+	}
+	lines = append(lines, incidentLines(c.incidents)...)
+	lines = append(lines, c.commitHeader...)
 	c.header.Write(lines...)
+
+	// The height of the header depends on its number of lines
+	width, height := c.table.Size()
+	for _, widget := range []Widget{c.header, c.footer, c.status} {
+		_, h := widget.Size()
+		height += h
+	}
+	c.resize(width, height)
+}
+
+// SetCommit tells the controller about the commit whose pipelines are shown initially and its
+// repository
+func (c *Controller) SetCommit(repositoryURL string, commit utils.Commit) {
+	c.repositoryURL = repositoryURL
+	c.commit = commit
+}
+
+// Show the pipelines of another commit
+func (c *Controller) setTarget(target Target) {
+	c.commit = target.Commit
+	c.lastUpdate = time.Time{}
+	// Marks designate rows of the previous commit
+	c.marks = make(map[rune]interface{})
+	c.SetHeader(target.Header)
+	if c.pager != nil && c.pager.stopFollowing != nil {
+		c.pager.stopFollowing()
+	}
+	c.pager = nil
+	c.artifacts = nil
+	c.table.SetSource(target.Source)
+	c.applyPresentation()
+
+	if target.Status != "" {
+		c.setStatus(target.Status)
+	}
+}
+
+// Apply the presentation chosen by the user to the source of the table. Sources not supporting
+// it are shown as is.
+func (c *Controller) applyPresentation() {
+	if c.flat {
+		c.table.SetGrouped(false)
+	}
+	if c.jobsOnly {
+		c.table.SetJobsOnly(true)
+	}
+	if c.sortColumn != "" {
+		c.table.SetSort(c.sortColumn, c.reverse)
+	}
+	c.table.SetTimestampMode(c.timestamps)
+	c.table.SetTimingGutter(c.gutter)
+}
+
+// Accepted returns the channel receiving the commits offered to the user that the user chose to
+// monitor
+func (c *Controller) Accepted() <-chan utils.Commit {
+	return c.accepted
+}
+
+// Suggest to the user to monitor 'commit' instead of the commit being shown
+func (c *Controller) offer(commit utils.Commit) {
+	c.offered = &commit
+	c.setStatus(fmt.Sprintf("New commit %s on the remote branch, press u to monitor it", shortSha(commit.Sha)))
+}
+
+// Accept the commit offered to the user, if any
+func (c *Controller) acceptOffer() {
+	if c.offered == nil {
+		c.setStatus("No new commit to monitor")
+		return
+	}
+	select {
+	case c.accepted <- *c.offered:
+		c.setStatus(fmt.Sprintf("Switching to commit %s...", shortSha(c.offered.Sha)))
+	default:
+		// A previous commit has been accepted but is not processed yet
+	}
+	c.offered = nil
 }
 
 func (c *Controller) setStatus(s string) {
@@ -91,14 +346,73 @@ func (c *Controller) clearStatus() {
 }
 
 func (c *Controller) refresh() {
+	// The pager keeps showing the end of the log being followed unless the user moved up
+	following := c.pager != nil && c.pager.followed != nil
+	atBottom := following && c.table.AtBottom()
+	if following {
+		select {
+		case err := <-c.pager.followed:
+			c.pager.followed = nil
+			switch err {
+			case nil:
+				c.setStatus("The job is finished, its log is complete")
+			case context.Canceled:
+			default:
+				c.setStatus(fmt.Sprintf("Stopped following the log: %v", err))
+			}
+		default:
+		}
+	}
 	c.table.Refresh()
+	if atBottom {
+		c.table.Bottom()
+	}
+	c.resumeSession()
+	c.writeFooter()
+}
+
+// Tell the user how to replace the token of providers that started rejecting it. Each provider
+// is only reported once.
+func (c *Controller) reportRejectedTokens(health []cache.ProviderHealth) {
+	for _, h := range health {
+		if h.Status == cache.ProviderAuthFailed && !c.rejectedTokens[h.ID] {
+			c.rejectedTokens[h.ID] = true
+			c.setStatus(fmt.Sprintf("Provider %s rejected the token, enter a new one with 'citop login %s' and restart citop", h.ID, h.ID))
+		}
+	}
+}
+
+// SetStateIcons selects the icons shown next to the states counted in the footer. A nil value
+// disables icons.
+func (c *Controller) SetStateIcons(icons StateIcons) {
+	c.icons = icons
+	c.writeFooter()
+}
+
+// Write the number of pipelines in each state, the age of the last update and the connectivity
+// of each provider to the footer. Parts not supported by the source of the table are left out.
+func (c *Controller) writeFooter() {
+	now := time.Now()
+	parts := make([]text.StyledString, 0, 2)
+	if summary, err := c.table.Summary(); err == nil {
+		parts = append(parts, summary.Footer(c.icons, c.lastUpdate, now))
+	}
+	if health, err := c.table.Health(); err == nil && len(health) > 0 {
+		parts = append(parts, providerIndicators(health, now))
+		c.reportRejectedTokens(health)
+	}
+	if len(parts) == 0 {
+		c.footer.Write()
+		return
+	}
+	c.footer.Write(text.Join(parts, text.NewStyledString("  |  ")))
 }
 
 func (c Controller) text() []text.LocalizedStyledString {
 	texts := make([]text.LocalizedStyledString, 0)
 	yOffset := 0
 
-	for _, child := range []Widget{c.header, c.table, c.status} {
+	for _, child := range []Widget{c.header, c.table, c.footer, c.status} {
 		for _, line := range child.Text() {
 			line.Y += yOffset
 			texts = append(texts, line)
@@ -114,11 +428,13 @@ func (c *Controller) resize(width int, height int) {
 	width = utils.MaxInt(width, 0)
 	height = utils.MaxInt(height, 0)
 	headerHeight := utils.MinInt(utils.MinInt(len(c.header.content)+2, 9), height)
-	tableHeight := utils.MaxInt(0, height-headerHeight-1)
-	statusHeight := height - headerHeight - tableHeight
+	tableHeight := utils.MaxInt(0, height-headerHeight-2)
+	footerHeight := utils.MinInt(1, height-headerHeight-tableHeight)
+	statusHeight := height - headerHeight - tableHeight - footerHeight
 
 	c.header.Resize(width, headerHeight)
 	c.table.Resize(width, tableHeight)
+	c.footer.Resize(width, footerHeight)
 	c.status.Resize(width, statusHeight)
 }
 
@@ -133,126 +449,1129 @@ func (c *Controller) process(ctx context.Context, event tcell.Event) error {
 		sx, sy := ev.Size()
 		c.resize(sx, sy)
 	case *tcell.EventKey:
-		switch ev.Key() {
-		case tcell.KeyDown:
-			c.table.Scroll(+1)
-		case tcell.KeyUp:
-			c.table.Scroll(-1)
-		case tcell.KeyPgDn:
-			c.table.Scroll(c.table.NbrRows())
-		case tcell.KeyPgUp:
-			c.table.Scroll(-c.table.NbrRows())
-		case tcell.KeyHome:
-			c.table.Top()
-		case tcell.KeyEnd:
-			c.table.Bottom()
-		case tcell.KeyEsc:
-			if c.inputMode {
-				c.inputMode = false
-				c.status.ShowInput = false
+		// The user takes over from the session being restored
+		c.session = nil
+		key := Key{Key: ev.Key()}
+		if key.Key == tcell.KeyRune {
+			key.Rune = ev.Rune()
+		}
+
+		// The key following 'm' or '\'' designates a mark
+		if command := c.markCommand; command != 0 {
+			c.markCommand = 0
+			if key.Key == tcell.KeyRune {
+				c.useMark(command, key.Rune)
 			}
-		case tcell.KeyEnter:
-			if c.inputMode {
-				c.inputMode = false
-				c.status.ShowInput = false
+			break
+		}
+
+		// Digits typed outside of the prompt make up the number of times the next movement
+		// is repeated, as in vim. A leading zero is not a count.
+		if !c.inputMode && key.Key == tcell.KeyRune && key.Rune >= '0' && key.Rune <= '9' && (key.Rune != '0' || c.count > 0) {
+			if c.count < maxCount {
+				c.count = c.count*10 + int(key.Rune-'0')
 			}
-			if c.status.InputBuffer != "" {
-				found := c.table.NextMatch(c.status.InputBuffer, true)
-				if !found {
-					c.setStatus(fmt.Sprintf("No match found for %#v", c.status.InputBuffer))
-				}
+			c.setStatus(fmt.Sprintf("%d", c.count))
+			break
+		}
+		count := c.count
+		c.count = 0
+
+		bindings := KeyBindings
+		if c.inputMode {
+			if binding, exists := findKeyBinding(PromptKeyBindings, key); exists {
+				bindings = []KeyBinding{binding}
+			} else if key.Key == tcell.KeyRune {
+				c.status.InputBuffer += string(key.Rune)
+				break
 			}
-		case tcell.KeyCtrlU:
-			if c.inputMode {
-				c.status.InputBuffer = ""
+		}
+
+		if binding, exists := findKeyBinding(bindings, key); exists {
+			pending := c.pending
+			if !binding.Repeatable || count < 1 {
+				count = 1
 			}
-		case tcell.KeyBackspace, tcell.KeyBackspace2:
-			if c.inputMode {
-				runes := []rune(c.status.InputBuffer)
-				if len(runes) > 0 {
-					c.status.InputBuffer = string(runes[:len(runes)-1])
+			for i := 0; i < count; i++ {
+				if err := binding.action(c, ctx); err != nil {
+					return err
 				}
 			}
-		case tcell.KeyRune:
-			if c.inputMode {
-				c.status.InputBuffer += string(ev.Rune())
-				break
+			// Any other action cancels the action waiting for confirmation
+			if c.pending == pending {
+				c.pending = nil
 			}
-			switch keyRune := ev.Rune(); keyRune {
-			case 'b':
-				browser := os.Getenv("BROWSER")
-				if browser == "" {
-					return errors.New("BROWSER environment variable not set")
-				}
-				if err := c.table.OpenInBrowser(browser); err != nil {
-					return err
-				}
-			case 'j':
-				c.table.Scroll(+1)
-			case 'k':
-				c.table.Scroll(-1)
-			case 'c':
-				c.table.SetTraversable(false, false)
-			case 'C', '-':
-				c.table.SetTraversable(false, true)
-			case 'o':
-				c.table.SetTraversable(true, false)
-			case 'O', '+':
-				c.table.SetTraversable(true, true)
-			case 'n', 'N':
-				if c.status.InputBuffer != "" {
-					_ = c.table.NextMatch(c.status.InputBuffer, ev.Rune() == 'n')
-				}
-			case 'q':
-				return ErrExit
-			case '/':
-				c.inputMode = true
-				c.status.ShowInput = true
-				c.status.InputBuffer = ""
-			case '?':
-				file, err := ioutil.TempFile(c.tempDir, "citop_")
-				if err != nil {
-					return err
-				}
-				_, err = file.Write([]byte(c.help))
-				if err != nil {
-					return err
-				}
+		}
+	}
 
-				cmd := ExecCmd{
-					name: "man",
-					args: []string{"-l", path.Join(c.tempDir, path.Base(file.Name()))},
-				}
-				if err := c.tui.Exec(ctx, cmd); err != nil {
-					return err
-				}
+	c.draw()
+	return nil
+}
 
-			case 'v':
-				c.setStatus("Fetching logs...")
-				c.draw()
-				defer func() {
-					c.clearStatus()
-					c.draw()
-				}()
-
-				logPath, err := c.table.WriteToDisk(ctx, c.tempDir)
-				if err != nil {
-					if err == cache.ErrNoLogHere {
-						return nil
-					}
-					return err
-				}
+func (c *Controller) openPrompt() {
+	c.inputMode = true
+	c.status.ShowInput = true
+	c.status.InputBuffer = ""
+	c.status.inputPrefix = "/"
+}
+
+func (c *Controller) closePrompt() {
+	c.inputMode = false
+	c.status.ShowInput = false
+	if c.searchingLogs {
+		c.searchingLogs = false
+		c.status.InputBuffer = c.rowPattern
+	}
+}
+
+// Wait for the letter designating the mark used by 'command': m to mark the row at the cursor,
+// a single quote to jump to a marked row
+func (c *Controller) waitForMark(command rune) {
+	c.markCommand = command
+	if command == 'm' {
+		c.setStatus("Mark row at the cursor with letter...")
+	} else {
+		c.setStatus("Jump to the row marked with letter...")
+	}
+}
+
+func isMarkLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// Set the mark 'letter' on the row at the cursor if 'command' is m, or move the cursor to the
+// row marked with 'letter' if 'command' is a single quote
+func (c *Controller) useMark(command rune, letter rune) {
+	if !isMarkLetter(letter) && !(command == '\'' && letter == '\'') {
+		c.setStatus(fmt.Sprintf("Invalid mark %q (expected a letter)", letter))
+		return
+	}
+
+	active, ok := c.table.ActiveKey()
+	if !ok {
+		return
+	}
+	if command == 'm' {
+		c.marks[letter] = active
+		c.setStatus(fmt.Sprintf("Row marked with %c", letter))
+		return
+	}
+
+	key, exists := c.marks[letter]
+	if !exists {
+		c.setStatus(fmt.Sprintf("Mark %c not set", letter))
+		return
+	}
+	if !c.table.Jump(key) {
+		c.setStatus(fmt.Sprintf("The row marked with %c no longer exists", letter))
+		return
+	}
+	c.marks['\''] = active
+}
+
+// Ask for a pattern to look for in the logs of the pipeline at the cursor
+func (c *Controller) openLogSearchPrompt() {
+	pattern := c.status.InputBuffer
+	c.openPrompt()
+	c.status.inputPrefix = "grep: "
+	c.searchingLogs = true
+	c.rowPattern = pattern
+}
 
-				cmd := ExecCmd{
-					name: "less",
-					args: []string{"-R", logPath},
+// Look for the pattern of the prompt in the logs of all the jobs of the pipeline at the cursor
+// and show the matching lines of each job
+func (c *Controller) searchLogs(ctx context.Context) error {
+	s := c.status.InputBuffer
+	c.closePrompt()
+	if s == "" {
+		return nil
+	}
+	pattern, err := regexp.Compile(s)
+	if err != nil {
+		c.setStatus(fmt.Sprintf("Invalid pattern: %v", err))
+		return nil
+	}
+
+	c.setStatus("Fetching logs...")
+	log := c.table.ActiveLog()
+	c.inBackground(ctx, func() func() error {
+		search, err := log.SearchLogs(ctx, pattern)
+		filePath := ""
+		if err == nil && len(search.Results) > 0 {
+			filePath, err = c.writeTempFile("search_*.txt", search.String())
+		}
+		return func() error {
+			switch err {
+			case nil:
+			case ErrUnsupportedView:
+				c.setStatus("Logs cannot be searched in this view")
+				return nil
+			case cache.ErrNoLogHere:
+				c.setStatus("No pipeline at the cursor")
+				return nil
+			default:
+				c.setStatus(fmt.Sprintf("Failed to search logs: %v", err))
+				return nil
+			}
+			if len(search.Results) == 0 {
+				c.setStatus(fmt.Sprintf("No match found for %#v in the logs of %d jobs", s, search.Jobs))
+				return nil
+			}
+
+			c.openPager("MATCHES", filePath)
+			c.setStatus(fmt.Sprintf("Lines matching %#v in the logs of %d jobs, press q to close the pager", s, search.Jobs))
+			return nil
+		}
+	})
+	return nil
+}
+
+func (c *Controller) nextMatch(forward bool) {
+	// Matches are highlighted in the text of the pager
+	if c.pager != nil {
+		c.table.SetHighlight(c.status.InputBuffer)
+	}
+	if c.status.InputBuffer != "" {
+		if found := c.table.NextMatch(c.status.InputBuffer, forward); !found {
+			c.setStatus(fmt.Sprintf("No match found for %#v", c.status.InputBuffer))
+		}
+	}
+}
+
+func (c *Controller) openInBrowser() error {
+	browser := os.Getenv("BROWSER")
+	if browser == "" {
+		return errors.New("BROWSER environment variable not set")
+	}
+	return c.table.OpenInBrowser(browser)
+}
+
+// Open the pull request containing the commit with the default web browser
+func (c *Controller) openPullRequest() error {
+	if c.commit.PullRequest == nil || c.commit.PullRequest.WebURL == "" {
+		c.setStatus("No pull request found for this commit")
+		return nil
+	}
+	browser := os.Getenv("BROWSER")
+	if browser == "" {
+		return errors.New("BROWSER environment variable not set")
+	}
+	return startBrowser(browser, c.commit.PullRequest.WebURL)
+}
+
+func (c *Controller) viewManual(ctx context.Context) error {
+	file, err := ioutil.TempFile(c.tempDir, "citop_")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write([]byte(c.help))
+	if err != nil {
+		return err
+	}
+
+	cmd := ExecCmd{
+		name: "man",
+		args: []string{"-l", path.Join(c.tempDir, path.Base(file.Name()))},
+	}
+	return c.tui.Exec(ctx, cmd)
+}
+
+func (c *Controller) viewLog(ctx context.Context) error {
+	title := "LOG"
+	if c.artifacts != nil {
+		title = "ARTIFACT"
+		c.setStatus("Fetching artifact...")
+	} else {
+		c.setStatus("Fetching logs...")
+	}
+	c.draw()
+	artifact := c.artifacts != nil
+
+	log := c.table.ActiveLog()
+	c.inBackground(ctx, func() func() error {
+		logPath, err := log.WriteToDisk(ctx, c.tempDir)
+		title := title
+		if err == cache.ErrNoLogHere && !artifact {
+			// Pipelines and stages show the logs of all their jobs
+			title = "LOGS"
+			logPath, err = log.WriteLogsToDisk(ctx, c.tempDir)
+		}
+		return func() error {
+			if err != nil {
+				c.clearStatus()
+				switch {
+				case err == cache.ErrNoLogHere:
+					return nil
+				case err == ErrArtifactNotViewable:
+					c.setStatus("Only text artifacts up to 1 MiB can be viewed, press b to open the artifact with the web browser")
+					return nil
+				case artifact:
+					c.setStatus(fmt.Sprintf("Failed to fetch the artifact: %v", err))
+					return nil
 				}
+				return err
+			}
+			c.showLog(ctx, title, logPath, log)
+			return nil
+		}
+	})
+	return nil
+}
 
-				return c.tui.Exec(ctx, cmd)
+// Show the file at 'logPath' where 'log' was written in the pager and follow it if its job is
+// still running
+func (c *Controller) showLog(ctx context.Context, title string, logPath string, log RowLog) {
+	line := log.LogLine()
+	followCtx, stopFollowing := context.WithCancel(ctx)
+	var followed <-chan error
+	if line == 0 && log.LogRunning() {
+		followed = log.FollowLog(followCtx, logPath, logFollowInterval)
+	}
+
+	c.openPager(title, logPath)
+	c.pager.stopFollowing = stopFollowing
+	switch {
+	case line > 0:
+		c.table.Jump(pagerRowKey{line: line})
+		c.setStatus("Press q to close the pager")
+	case followed != nil:
+		c.pager.followed = followed
+		c.table.Bottom()
+		c.setStatus("Following the log of the running job, press q to close the pager")
+	default:
+		c.setStatus("Press q to close the pager")
+	}
+}
+
+// Write 'content' to a new file of the temporary directory whose name follows 'pattern' and
+// return its path
+func (c *Controller) writeTempFile(pattern string, content string) (string, error) {
+	file, err := ioutil.TempFile(c.tempDir, pattern)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		return "", err
+	}
+	return path.Join(c.tempDir, path.Base(file.Name())), nil
+}
+
+// Show the text file at 'filePath' in the pager under the header 'title' instead of the table.
+// The pager replaces the text shown if it is already open.
+func (c *Controller) openPager(title string, filePath string) {
+	if c.pager != nil {
+		if c.pager.stopFollowing != nil {
+			c.pager.stopFollowing()
+		}
+		c.pager.stopFollowing, c.pager.followed = nil, nil
+	} else {
+		key, _ := c.table.ActiveKey()
+		open, cursor := c.table.SessionState()
+		c.pager = &pagerPanel{
+			source: c.table.source,
+			open:   open,
+			cursor: cursor,
+			key:    key,
+		}
+	}
+	c.table.SetSource(NewPager(title, filePath, c.stripColors))
+}
+
+// Close the pager and go back to the table with the folds and the cursor it had before
+func (c *Controller) closePager() {
+	panel := c.pager
+	c.pager = nil
+	if panel.stopFollowing != nil {
+		panel.stopFollowing()
+	}
+	c.table.SetSource(panel.source)
+	c.applyPresentation()
+	c.table.RestoreSession(panel.open, "")
+	c.table.Jump(panel.key)
+}
+
+// Close the pager if it is open, otherwise quit
+func (c *Controller) quit(ctx context.Context) error {
+	if c.pager != nil {
+		c.closePager()
+		return nil
+	}
+	return ErrExit
+}
+
+// SetStripColors selects whether the pager drops the colors set by escape sequences in logs
+// instead of showing them
+func (c *Controller) SetStripColors(strip bool) {
+	c.stripColors = strip
+}
+
+// SetLogPathTemplate sets the template of the path of the files logs are saved to
+func (c *Controller) SetLogPathTemplate(tmpl *template.Template) {
+	c.logPathTemplate = tmpl
+}
+
+// SetTimestampMode selects how the timestamps prefixing the lines of logs are shown
+func (c *Controller) SetTimestampMode(mode TimestampMode) {
+	c.timestamps = mode
+	c.table.SetTimestampMode(mode)
+}
+
+// Switch to the next presentation of the timestamps prefixing the lines of logs
+func (c *Controller) cycleTimestampMode(ctx context.Context) error {
+	mode := c.timestamps.next()
+	if err := c.table.SetTimestampMode(mode); err != nil {
+		if err == ErrUnsupportedView {
+			c.setStatus("Timestamps cannot be changed in this view")
+			return nil
+		}
+		return err
+	}
+	c.timestamps = mode
+	switch mode {
+	case TimestampsHide:
+		c.setStatus("Timestamps hidden from logs")
+	case TimestampsRelative:
+		c.setStatus("Timestamps of logs relative to the start of the job")
+	default:
+		c.setStatus("Timestamps of logs shown as is")
+	}
+	return nil
+}
+
+// Show or hide the time elapsed since the start of the job in front of each line of logs
+func (c *Controller) toggleTimingGutter(ctx context.Context) error {
+	if err := c.table.SetTimingGutter(!c.gutter); err != nil {
+		if err == ErrUnsupportedView {
+			c.setStatus("Elapsed times cannot be shown in this view")
+			return nil
+		}
+		return err
+	}
+	c.gutter = !c.gutter
+	if c.gutter {
+		c.setStatus("Lines of logs prefixed with the time elapsed since the start of the job")
+	} else {
+		c.setStatus("Elapsed times hidden from logs")
+	}
+	return nil
+}
+
+// Fetch the log of the job at the cursor and list the problems found in it under the job
+func (c *Controller) findProblems(ctx context.Context) error {
+	c.setStatus("Fetching logs...")
+	c.draw()
+
+	log := c.table.ActiveLog()
+	c.inBackground(ctx, func() func() error {
+		_, err := log.WriteToDisk(ctx, c.tempDir)
+		return func() error {
+			return c.showProblems(log, err)
+		}
+	})
+	return nil
+}
+
+// Show the problems found in 'log' once written to disk, 'err' being the outcome of the write
+func (c *Controller) showProblems(log RowLog, err error) error {
+	if err != nil {
+		if err == cache.ErrNoLogHere {
+			c.setStatus("Problems are only found in the logs of jobs")
+			return nil
+		}
+		c.setStatus(fmt.Sprintf("Failed to fetch the log: %v", err))
+		return nil
+	}
+	problems, err := log.Problems()
+	if err != nil {
+		if err == ErrUnsupportedView {
+			c.setStatus("Problems cannot be listed in this view")
+			return nil
+		}
+		return err
+	}
+	// Show the problems right away under the job, unless the cursor left it in the meantime
+	c.table.Refresh()
+	key, _ := log.Key()
+	if active, exists := c.table.ActiveKey(); len(problems) > 0 && exists && active == key {
+		c.table.SetTraversable(true, false)
+	}
+	switch len(problems) {
+	case 0:
+		c.setStatus("No problem found in the log")
+	case 1:
+		c.setStatus("1 problem found in the log")
+	default:
+		c.setStatus(fmt.Sprintf("%d problems found in the log", len(problems)))
+	}
+	return nil
+}
+
+// Fetch the test report of the job at the cursor, list it under the job and move the cursor to
+// the first test that failed
+func (c *Controller) showTestReport(ctx context.Context) error {
+	c.setStatus("Fetching test report...")
+	row := c.table.ActiveRow()
+	c.inBackground(ctx, func() func() error {
+		key, report, err := row.TestReport(ctx)
+		return func() error {
+			switch err {
+			case nil:
+			case ErrUnsupportedView, ErrNoJobHere:
+				c.setStatus("Test reports are only available for jobs")
+				return nil
+			case cache.ErrNoTestReport:
+				c.setStatus("No test report found for this job")
+				return nil
+			case cache.ErrUnsupportedAction:
+				c.setStatus("The provider of this job does not expose test results")
+				return nil
+			default:
+				c.setStatus(fmt.Sprintf("Failed to fetch the test report: %v", err))
+				return nil
 			}
+
+			c.table.Refresh()
+			if report.Failed > 0 && len(report.Failures) > 0 {
+				first := key.(buildRowKey)
+				first.test = 1
+				key = first
+			}
+			c.table.Jump(key)
+			c.setStatus(fmt.Sprintf("%d tests: %s", report.Total, testCounts(report)))
+			return nil
 		}
+	})
+	return nil
+}
+
+// Show the details of the job at the cursor, including the commands it executes
+// Switch between jobs grouped by stage and jobs listed directly under their pipeline
+func (c *Controller) toggleStages(ctx context.Context) error {
+	if err := c.table.SetGrouped(c.flat); err != nil {
+		if err == ErrUnsupportedView {
+			c.setStatus("Stages cannot be hidden in this view")
+			return nil
+		}
+		return err
+	}
+	c.flat = !c.flat
+	if c.flat {
+		c.setStatus("Stages hidden, jobs are listed under their pipeline")
+	} else {
+		c.setStatus("Jobs grouped by stage")
 	}
+	return nil
+}
+
+// Switch between the tree of pipelines and the flat table of the jobs of all pipelines
+func (c *Controller) toggleJobsOnly(ctx context.Context) error {
+	if err := c.table.SetJobsOnly(!c.jobsOnly); err != nil {
+		if err == ErrUnsupportedView {
+			c.setStatus("Jobs cannot be listed separately in this view")
+			return nil
+		}
+		return err
+	}
+	c.jobsOnly = !c.jobsOnly
+	if c.jobsOnly {
+		c.setStatus("Jobs of all pipelines listed in a flat table")
+	} else {
+		c.setStatus("Jobs listed under their pipeline")
+	}
+	return nil
+}
+
+// SetColumnWidths selects the sizing mode of the columns of the table
+func (c *Controller) SetColumnWidths(widths map[string]ColumnWidth) {
+	c.table.SetColumnWidths(widths)
+}
+
+// SetColumnPriorities overrides the default priorities of the columns hidden on narrow screens
+func (c *Controller) SetColumnPriorities(priorities map[string]int) {
+	c.table.SetColumnPriorities(priorities)
+}
+
+// SetSort sorts rows by 'column', in descending order if 'reverse' is true. An empty column
+// selects the default order.
+func (c *Controller) SetSort(column string, reverse bool) {
+	if c.table.SetSort(column, reverse) == nil {
+		c.sortColumn, c.reverse = column, reverse
+	}
+}
+
+// SetLineNumbers selects the numbering of rows
+func (c *Controller) SetLineNumbers(numbers LineNumbers) {
+	c.table.SetLineNumbers(numbers)
+}
+
+// Switch to the next numbering of rows
+func (c *Controller) cycleLineNumbers(ctx context.Context) error {
+	numbers := c.table.numbers.next()
+	c.table.SetLineNumbers(numbers)
+	switch numbers {
+	case LineNumbersAbsolute:
+		c.setStatus("Rows numbered from the top of the table")
+	case LineNumbersRelative:
+		c.setStatus("Rows numbered by their distance to the cursor")
+	default:
+		c.setStatus("Row numbers hidden")
+	}
+	return nil
+}
+
+// SetNotices sets the notices shown at the top of the header
+func (c *Controller) SetNotices(notices []string) {
+	c.notices = notices
+	c.writeHeader()
+}
+
+// SetWrap enables or disables the wrapping of the NAME column of the row at the cursor
+func (c *Controller) SetWrap(wrap bool) {
+	c.table.SetWrap(wrap)
+}
+
+// Switch between wrapping the NAME column of the row at the cursor and cutting it at the edge of
+// the screen
+func (c *Controller) toggleWrap(ctx context.Context) error {
+	c.table.SetWrap(!c.table.wrap)
+	if c.table.wrap {
+		c.setStatus("Name of the row at the cursor wrapped")
+	} else {
+		c.setStatus("Names cut at the edge of the screen")
+	}
+	return nil
+}
+
+// Sort rows by the column 'step' columns away from the current sort column. The default order
+// comes after the last column and before the first one.
+func (c *Controller) cycleSortColumn(step int) error {
+	columns := append([]string{""}, c.table.Headers()...)
+	i := 0
+	for j, column := range columns {
+		if column == c.sortColumn {
+			i = j
+		}
+	}
+	column := columns[utils.Modulo(i+step, len(columns))]
+	return c.sort(column, c.reverse)
+}
+
+func (c *Controller) sort(column string, reverse bool) error {
+	if err := c.table.SetSort(column, reverse); err != nil {
+		if err == ErrUnsupportedView {
+			c.setStatus("Rows cannot be sorted in this view")
+			return nil
+		}
+		return err
+	}
+	c.sortColumn, c.reverse = column, reverse
+	switch {
+	case c.sortColumn == "" && c.reverse:
+		c.setStatus("Rows in reverse default order")
+	case c.sortColumn == "":
+		c.setStatus("Rows in default order")
+	case c.reverse:
+		c.setStatus(fmt.Sprintf("Rows sorted by %s (descending)", c.sortColumn))
+	default:
+		c.setStatus(fmt.Sprintf("Rows sorted by %s (ascending)", c.sortColumn))
+	}
+	return nil
+}
 
+func (c *Controller) viewDetails(ctx context.Context) error {
+	details, err := c.table.Details()
+	if err != nil {
+		if err == ErrNoDetailsHere {
+			c.setStatus("Details are only available for pipelines, jobs, deployments and annotations")
+			return nil
+		}
+		return err
+	}
+
+	filePath, err := c.writeTempFile("details_*.txt", details)
+	if err != nil {
+		return err
+	}
+	c.openPager("DETAILS", filePath)
+	c.setStatus("Press q to close the pager")
+	return nil
+}
+
+// View the complete description of the commit, whose message is truncated in the header
+func (c *Controller) viewCommit(ctx context.Context) error {
+	if c.commit.Sha == "" {
+		c.setStatus("No commit to describe")
+		return nil
+	}
+
+	filePath, err := c.writeTempFile("commit_*.txt", commitDescription(c.commit))
+	if err != nil {
+		return err
+	}
+	c.openPager("COMMIT", filePath)
+	c.setStatus("Press q to close the pager")
+	return nil
+}
+
+// Mark the log of the job at the cursor, or show the differences between the log marked
+// previously and the log of the job at the cursor
+func (c *Controller) diffLog(ctx context.Context) error {
+	c.setStatus("Fetching logs...")
 	c.draw()
+
+	log := c.table.ActiveLog()
+	c.inBackground(ctx, func() func() error {
+		logPath, err := log.WriteToDisk(ctx, c.tempDir)
+		return func() error {
+			if err != nil {
+				c.clearStatus()
+				switch err {
+				case cache.ErrNoLogHere:
+					return nil
+				case ErrArtifactNotViewable:
+					c.setStatus("Only text artifacts up to 1 MiB can be compared")
+					return nil
+				}
+				return err
+			}
+
+			if c.markedLog == "" {
+				c.markedLog = logPath
+				c.setStatus("Log marked, press d on another job to compare")
+				return nil
+			}
+			markedLog := c.markedLog
+			c.markedLog = ""
+			c.compareLogs(ctx, markedLog, logPath)
+			return nil
+		}
+	})
+	return nil
+}
+
+// Show the differences between the logs at 'before' and 'after' in the pager
+func (c *Controller) compareLogs(ctx context.Context, before string, after string) {
+	// Comparing large logs takes a while so do it off the event loop
+	c.setStatus("Comparing logs...")
+	c.inBackground(ctx, func() func() error {
+		filePath, err := c.writeDiff(before, after)
+		return func() error {
+			switch {
+			case err != nil:
+				return err
+			case filePath == "":
+				c.setStatus("Logs are identical")
+			default:
+				c.openPager("DIFF", filePath)
+				c.setStatus("Press q to close the pager")
+			}
+			return nil
+		}
+	})
+}
+
+// Write the differences between the logs at 'before' and 'after' to a file of the temporary
+// directory and return its path, or an empty string if the logs are identical
+func (c *Controller) writeDiff(before string, after string) (string, error) {
+	a, err := ioutil.ReadFile(before)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(after)
+	if err != nil {
+		return "", err
+	}
+	diff := unifiedDiff(path.Base(before), string(a), path.Base(after), string(b))
+	if diff == "" {
+		return "", nil
+	}
+	return c.writeTempFile("diff_*.log", diff)
+}
+
+// Save the raw log of the job at the cursor, or of the job whose log is shown in the pager, to
+// the file given by the template of the path of saved logs
+func (c *Controller) saveLog(ctx context.Context) error {
+	c.setStatus("Fetching logs...")
+	log := c.table.ActiveLog()
+	if c.pager != nil {
+		log = RowLog{source: c.pager.source, key: c.pager.key, exists: true}
+	}
+	pathTemplate := c.logPathTemplate
+	c.inBackground(ctx, func() func() error {
+		filePath, err := log.SaveLog(ctx, pathTemplate)
+		return func() error {
+			switch err {
+			case nil:
+				c.setStatus(fmt.Sprintf("Log saved to %s", filePath))
+			case ErrUnsupportedView, ErrNoJobHere:
+				c.setStatus("Only the logs of jobs can be saved")
+			default:
+				c.setStatus(fmt.Sprintf("Failed to save the log: %v", err))
+			}
+			return nil
+		}
+	})
 	return nil
 }
+
+// Run 'work' in the background so that the user interface stays responsive while it runs.
+// 'work' must not access the mutable state of the controller: it returns a function applying
+// its outcome, which Run calls on the event loop. The outcome is dropped once 'ctx' is done.
+func (c *Controller) inBackground(ctx context.Context, work func() func() error) {
+	go func() {
+		apply := work()
+		select {
+		case c.outcomes <- apply:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// Return true if the user confirmed the action 'name' on the row at the cursor by requesting it
+// twice in a row. Otherwise ask for confirmation with the message 'question'.
+func (c *Controller) confirm(name string, question string) bool {
+	key, exists := c.table.ActiveKey()
+	if !exists {
+		return false
+	}
+	return c.confirmOn(name, key, question)
+}
+
+// Same as confirm for an action applying to 'key' instead of the row at the cursor
+func (c *Controller) confirmOn(name string, key interface{}, question string) bool {
+	action := pendingAction{name: name, key: key}
+	if c.pending != nil && *c.pending == action {
+		c.pending = nil
+		return true
+	}
+	c.pending = &action
+	c.setStatus(question)
+	return false
+}
+
+// Return the deployment at the cursor, or false after telling the user if there is none
+func (c *Controller) activeDeployment() (cache.Deployment, bool, error) {
+	deployment, err := c.table.Deployment()
+	switch err {
+	case nil:
+		return deployment, true, nil
+	case ErrUnsupportedView, ErrNoDeploymentHere:
+		c.setStatus("No deployment at the cursor")
+		return deployment, false, nil
+	default:
+		return deployment, false, err
+	}
+}
+
+// Stop the environment of the deployment at the cursor once the user confirms it
+func (c *Controller) stopEnvironment(ctx context.Context) error {
+	deployment, exists, err := c.activeDeployment()
+	if !exists || err != nil {
+		return err
+	}
+	if !deployment.CanStop {
+		c.setStatus(fmt.Sprintf("Environment %q cannot be stopped from this deployment", deployment.Environment))
+		return nil
+	}
+	if !c.confirm("stop", fmt.Sprintf("Press x again to stop environment %q", deployment.Environment)) {
+		return nil
+	}
+
+	c.setStatus(fmt.Sprintf("Stopping environment %q...", deployment.Environment))
+	row := c.table.ActiveRow()
+	c.inBackground(ctx, func() func() error {
+		err := row.StopEnvironment(ctx)
+		return func() error {
+			if err != nil {
+				c.setStatus(fmt.Sprintf("Failed to stop environment %q: %v", deployment.Environment, err))
+				return nil
+			}
+			c.setStatus(fmt.Sprintf("Environment %q stopped", deployment.Environment))
+			return nil
+		}
+	})
+	return nil
+}
+
+// Stop the environment of the deployment at the cursor, or cancel the pipeline or the job at
+// the cursor, once the user confirms it
+func (c *Controller) stopOrCancel(ctx context.Context) error {
+	if _, err := c.table.Deployment(); err == nil {
+		return c.stopEnvironment(ctx)
+	}
+
+	name, err := c.table.Cancelable()
+	switch err {
+	case nil:
+	case ErrUnsupportedView, ErrNothingToCancelHere:
+		c.setStatus("No deployment, active pipeline or active job at the cursor")
+		return nil
+	default:
+		return err
+	}
+	if !c.confirm("cancel", fmt.Sprintf("Press x again to cancel %s", name)) {
+		return nil
+	}
+
+	c.setStatus(fmt.Sprintf("Canceling %s...", name))
+	row := c.table.ActiveRow()
+	c.inBackground(ctx, func() func() error {
+		err := row.Cancel(ctx)
+		return func() error {
+			if err != nil {
+				c.setStatus(fmt.Sprintf("Failed to cancel %s: %v", name, err))
+				return nil
+			}
+			c.setStatus(fmt.Sprintf("Cancellation of %s requested, its state will change at the next update", name))
+			return nil
+		}
+	})
+	return nil
+}
+
+// Run again the pipeline at the cursor once the user confirms it and move the cursor to the
+// pipeline started
+func (c *Controller) restartPipeline(ctx context.Context) error {
+	name, err := c.table.Restartable()
+	switch err {
+	case nil:
+	case ErrUnsupportedView, ErrNoPipelineHere:
+		c.setStatus("No pipeline at the cursor")
+		return nil
+	case cache.ErrActionNotPermitted:
+		c.setStatus(fmt.Sprintf("Cannot restart %s while it is active, cancel it or wait for it to finish", name))
+		return nil
+	default:
+		return err
+	}
+	if !c.confirm("restart", fmt.Sprintf("Press R again to restart %s", name)) {
+		return nil
+	}
+
+	c.setStatus(fmt.Sprintf("Restarting %s...", name))
+	row := c.table.ActiveRow()
+	c.inBackground(ctx, func() func() error {
+		key, err := row.Restart(ctx)
+		return func() error {
+			if err != nil {
+				c.setStatus(fmt.Sprintf("Failed to restart %s: %v", name, err))
+				return nil
+			}
+			c.refresh()
+			if key == nil || !c.table.Jump(key) {
+				c.setStatus(fmt.Sprintf("Restarted %s, the new run will be shown at the next update", name))
+				return nil
+			}
+			c.setStatus(fmt.Sprintf("Restarted %s, the cursor is on the new run", name))
+			return nil
+		}
+	})
+	return nil
+}
+
+// Run again the job of the deployment at the cursor, or the job at the cursor, once the user
+// confirms it
+func (c *Controller) retry(ctx context.Context) error {
+	if _, err := c.table.Deployment(); err == nil {
+		return c.retryDeployment(ctx)
+	}
+
+	name, err := c.table.Retryable()
+	switch err {
+	case nil:
+	case ErrUnsupportedView, ErrNoJobHere:
+		c.setStatus("No deployment or job at the cursor")
+		return nil
+	case cache.ErrActionNotPermitted:
+		c.setStatus(fmt.Sprintf("Cannot retry %s while it is active, cancel it or wait for it to finish", name))
+		return nil
+	default:
+		return err
+	}
+	if !c.confirm("retry", fmt.Sprintf("Press r again to retry %s", name)) {
+		return nil
+	}
+
+	c.setStatus(fmt.Sprintf("Retrying %s...", name))
+	row := c.table.ActiveRow()
+	c.inBackground(ctx, func() func() error {
+		key, err := row.Retry(ctx)
+		return func() error {
+			if err != nil {
+				c.setStatus(fmt.Sprintf("Failed to retry %s: %v", name, err))
+				return nil
+			}
+			c.refresh()
+			if !c.table.Jump(key) {
+				c.setStatus(fmt.Sprintf("Retried %s, the new run will be shown at the next update", name))
+				return nil
+			}
+			c.setStatus(fmt.Sprintf("Retried %s", name))
+			return nil
+		}
+	})
+	return nil
+}
+
+// Start the manual job, or approve the approval, at the cursor once the user confirms it
+func (c *Controller) startManualJob(ctx context.Context) error {
+	name, err := c.table.ManualJob()
+	switch err {
+	case nil:
+	case ErrUnsupportedView, ErrNoJobHere:
+		c.setStatus("No job at the cursor")
+		return nil
+	case cache.ErrActionNotPermitted:
+		c.setStatus(fmt.Sprintf("The %s does not wait for a user to start it", name))
+		return nil
+	default:
+		return err
+	}
+	if !c.confirm("play", fmt.Sprintf("Press p again to start %s", name)) {
+		return nil
+	}
+
+	c.setStatus(fmt.Sprintf("Starting %s...", name))
+	row := c.table.ActiveRow()
+	c.inBackground(ctx, func() func() error {
+		err := row.StartManualJob(ctx)
+		return func() error {
+			if err != nil {
+				c.setStatus(fmt.Sprintf("Failed to start %s: %v", name, err))
+				return nil
+			}
+			c.refresh()
+			c.setStatus(fmt.Sprintf("Started %s", name))
+			return nil
+		}
+	})
+	return nil
+}
+
+// Start a new pipeline on the branch or tag of the commit once the user confirms it
+func (c *Controller) triggerPipelines(ctx context.Context) error {
+	ref, ok := triggerRef(c.commit)
+	if !ok || c.repositoryURL == "" {
+		c.setStatus("No single branch or tag points to the commit, use 'citop trigger REF' instead")
+		return nil
+	}
+	if !c.confirmOn("trigger", ref, fmt.Sprintf("Press Ctrl-R again to start a new pipeline on %s", ref)) {
+		return nil
+	}
+
+	c.setStatus(fmt.Sprintf("Starting a new pipeline on %s...", ref))
+	row, repositoryURL := c.table.ActiveRow(), c.repositoryURL
+	c.inBackground(ctx, func() func() error {
+		urls, err := row.TriggerPipelines(ctx, repositoryURL, ref)
+		return func() error {
+			if err != nil {
+				c.setStatus(fmt.Sprintf("Failed to start a pipeline on %s: %v", ref, err))
+				return nil
+			}
+			c.refresh()
+			c.setStatus(fmt.Sprintf("Started %d pipeline(s) on %s, they will be shown once the commit is known to their provider", len(urls), ref))
+			return nil
+		}
+	})
+	return nil
+}
+
+// Run again the job of the deployment at the cursor once the user confirms it
+func (c *Controller) retryDeployment(ctx context.Context) error {
+	deployment, exists, err := c.activeDeployment()
+	if !exists || err != nil {
+		return err
+	}
+	if !deployment.CanRetry {
+		c.setStatus(fmt.Sprintf("Deployment to %q cannot be retried", deployment.Environment))
+		return nil
+	}
+	if !c.confirm("retry", fmt.Sprintf("Press r again to retry the deployment to %q", deployment.Environment)) {
+		return nil
+	}
+
+	c.setStatus(fmt.Sprintf("Retrying the deployment to %q...", deployment.Environment))
+	row := c.table.ActiveRow()
+	c.inBackground(ctx, func() func() error {
+		err := row.RetryDeployment(ctx)
+		return func() error {
+			if err != nil {
+				c.setStatus(fmt.Sprintf("Failed to retry the deployment to %q: %v", deployment.Environment, err))
+				return nil
+			}
+			c.setStatus(fmt.Sprintf("Deployment to %q restarted, the new deployment will be shown at the next update", deployment.Environment))
+			return nil
+		}
+	})
+	return nil
+}
+
+// Replace the table of pipelines by the list of the artifacts of the job at the cursor, or go
+// back to the table of pipelines if the artifacts of a job are shown
+func (c *Controller) toggleArtifacts(ctx context.Context) error {
+	if c.pager != nil {
+		c.setStatus("Press q to close the pager first")
+		return nil
+	}
+	if c.artifacts != nil {
+		c.closeArtifacts()
+		return nil
+	}
+
+	c.setStatus("Fetching artifacts...")
+	row := c.table.ActiveRow()
+	c.inBackground(ctx, func() func() error {
+		source, name, err := row.Artifacts(ctx)
+		return func() error {
+			switch err {
+			case nil:
+			case ErrUnsupportedView, ErrNoJobHere:
+				c.setStatus("No job at the cursor")
+				return nil
+			case cache.ErrUnsupportedAction:
+				c.setStatus("The provider of this job does not expose artifacts")
+				return nil
+			default:
+				c.setStatus(fmt.Sprintf("Failed to list artifacts: %v", err))
+				return nil
+			}
+			// The user opened another view while the artifacts were listed
+			if c.pager != nil || c.artifacts != nil {
+				return nil
+			}
+			n := len(source.Rows())
+			if n == 0 {
+				c.setStatus(fmt.Sprintf("No artifact found for %s", name))
+				return nil
+			}
+
+			open, cursor := c.table.SessionState()
+			c.artifacts = &artifactsPanel{
+				source: c.table.source,
+				open:   open,
+				cursor: cursor,
+				key:    row.key,
+			}
+			c.table.SetSource(source)
+			if n == 1 {
+				c.setStatus(fmt.Sprintf("1 artifact of %s, press v to view it and a to go back", name))
+			} else {
+				c.setStatus(fmt.Sprintf("%d artifacts of %s, press v to view one and a to go back", n, name))
+			}
+			return nil
+		}
+	})
+	return nil
+}
+
+// Go back to the table of pipelines with the folds and the cursor it had before the artifacts
+// of a job were shown
+func (c *Controller) closeArtifacts() {
+	panel := c.artifacts
+	c.artifacts = nil
+	c.table.SetSource(panel.source)
+	c.applyPresentation()
+	c.table.RestoreSession(panel.open, "")
+	c.table.Jump(panel.key)
+}
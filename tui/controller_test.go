@@ -1,12 +1,17 @@
 package tui
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/gdamore/tcell"
 	"github.com/nbedos/citop/cache"
 	"github.com/nbedos/citop/text"
+	"github.com/nbedos/citop/utils"
 )
 
 func TestController_resize(t *testing.T) {
@@ -22,7 +27,7 @@ func TestController_resize(t *testing.T) {
 			tui.Finish()
 		}()
 		c := cache.NewCache(nil, nil)
-		controller, err := NewController(&tui, (&c).BuildsByCommit(), time.UTC, "", "", "")
+		controller, err := NewController(&tui, NewBuildsByCommit(&c), time.UTC, "", "", "")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -33,3 +38,386 @@ func TestController_resize(t *testing.T) {
 		controller.draw()
 	})
 }
+
+func TestController_acceptOffer(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, text.StyleSheet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+	c := cache.NewCache(nil, nil)
+	controller, err := NewController(&tui, NewBuildsByCommit(&c), time.UTC, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing to accept
+	controller.acceptOffer()
+	select {
+	case commit := <-controller.Accepted():
+		t.Fatalf("unexpected commit %q", commit.Sha)
+	default:
+	}
+
+	controller.offer(utils.Commit{Sha: "c2bb562"})
+	controller.acceptOffer()
+	select {
+	case commit := <-controller.Accepted():
+		if commit.Sha != "c2bb562" {
+			t.Fatalf("expected commit %q but got %q", "c2bb562", commit.Sha)
+		}
+	default:
+		t.Fatal("expected the offered commit to be accepted")
+	}
+}
+
+func TestController_cycleSortColumn(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, text.StyleSheet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+	c := cache.NewCache(nil, nil)
+	source := NewBuildsByCommit(&c)
+	controller, err := NewController(&tui, &source, time.UTC, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers := controller.table.Headers()
+	steps := []struct {
+		step   int
+		column string
+	}{
+		{step: +1, column: headers[0]},
+		{step: +1, column: headers[1]},
+		{step: -1, column: headers[0]},
+		{step: -1, column: ""},
+		{step: -1, column: headers[len(headers)-1]},
+	}
+	for _, s := range steps {
+		if err := controller.cycleSortColumn(s.step); err != nil {
+			t.Fatal(err)
+		}
+		if controller.sortColumn != s.column || source.sortColumn != s.column {
+			t.Fatalf("expected sort column %q but got %q (source: %q)", s.column, controller.sortColumn, source.sortColumn)
+		}
+	}
+}
+
+func TestController_confirm(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, text.StyleSheet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+	c := cache.NewCache(nil, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	controller, err := NewController(&tui, NewBuildsByCommit(&c), time.UTC, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.resize(80, 20)
+	controller.refresh()
+
+	if controller.confirm("stop", "confirm?") {
+		t.Fatal("action must not be confirmed by the first request")
+	}
+	if controller.confirm("retry", "confirm?") {
+		t.Fatal("another action must not confirm the pending action")
+	}
+	if !controller.confirm("retry", "confirm?") {
+		t.Fatal("action must be confirmed by the second request")
+	}
+	if controller.pending != nil {
+		t.Fatal("confirmed action must not be pending anymore")
+	}
+}
+
+func TestController_count(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, text.StyleSheet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+	c := cache.NewCache(nil, nil)
+	for i := 1; i <= 20; i++ {
+		build := cache.Build{
+			Repository: &cache.Repository{Provider: cache.Provider{ID: "provider", Name: "provider"}},
+			ID:         fmt.Sprintf("%d", i),
+			Stages:     map[int]*cache.Stage{},
+		}
+		if err := c.Save(build); err != nil {
+			t.Fatal(err)
+		}
+	}
+	controller, err := NewController(&tui, NewBuildsByCommit(&c), time.UTC, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.resize(80, 40)
+	controller.refresh()
+
+	ctx := context.Background()
+	press := func(r rune) {
+		if err := controller.process(ctx, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		keys string
+		line int
+	}{
+		{"j", 1},
+		{"5j", 6},
+		{"12j", 18},
+		{"3k", 15},
+		// A leading zero is not a count
+		{"0k", 14},
+		{"10k", 4},
+		// Movements stop at the first line
+		{"100k", 0},
+	}
+	for _, step := range steps {
+		for _, r := range step.keys {
+			press(r)
+		}
+		if controller.table.activeLine != step.line {
+			t.Fatalf("expected line %d after %q but got %d", step.line, step.keys, controller.table.activeLine)
+		}
+		if controller.count != 0 {
+			t.Fatalf("expected count to be reset after %q but got %d", step.keys, controller.count)
+		}
+	}
+
+	// Counts do not repeat other commands
+	press('3')
+	if controller.count != 3 {
+		t.Fatalf("expected count 3 but got %d", controller.count)
+	}
+	press('s')
+	if controller.count != 0 {
+		t.Fatalf("expected count to be reset but got %d", controller.count)
+	}
+}
+
+func TestController_marks(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, text.StyleSheet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+	c := cache.NewCache(nil, nil)
+	for i := 1; i <= 5; i++ {
+		build := cache.Build{
+			Repository: &cache.Repository{Provider: cache.Provider{ID: "provider", Name: "provider"}},
+			ID:         fmt.Sprintf("%d", i),
+			Stages:     map[int]*cache.Stage{},
+			Jobs:       []*cache.Job{{ID: "1", Name: "tests"}},
+		}
+		if err := c.Save(build); err != nil {
+			t.Fatal(err)
+		}
+	}
+	controller, err := NewController(&tui, NewBuildsByCommit(&c), time.UTC, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.resize(80, 40)
+	controller.refresh()
+
+	ctx := context.Background()
+	press := func(keys string) {
+		for _, r := range keys {
+			if err := controller.process(ctx, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	activeKey := func() interface{} {
+		key, ok := controller.table.ActiveKey()
+		if !ok {
+			t.Fatal("no active row")
+		}
+		return key
+	}
+
+	// Mark the job of the second pipeline then close its fold
+	press("jojma")
+	job := activeKey()
+	press("kc")
+	if key := activeKey(); key == job {
+		t.Fatal("expected the cursor to move away from the job")
+	}
+	pipeline := activeKey()
+	press("mbjjj")
+
+	press("'a")
+	if key := activeKey(); key != job {
+		t.Fatalf("expected cursor on the marked job %v but got %v", job, key)
+	}
+	press("'b")
+	if key := activeKey(); key != pipeline {
+		t.Fatalf("expected cursor on pipeline %v but got %v", pipeline, key)
+	}
+	press("''")
+	if key := activeKey(); key != job {
+		t.Fatalf("expected cursor back on %v but got %v", job, key)
+	}
+
+	// Unknown marks leave the cursor in place
+	press("'z")
+	if key := activeKey(); key != job {
+		t.Fatalf("expected cursor on %v but got %v", job, key)
+	}
+}
+
+// Wait for the outcome of the next operation run in the background and apply it
+func applyOutcome(t *testing.T, controller *Controller) error {
+	select {
+	case apply := <-controller.outcomes:
+		return apply()
+	case <-time.After(5 * time.Second):
+		t.Fatal("no outcome received")
+		return nil
+	}
+}
+
+func TestController_diffLog(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, text.StyleSheet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+	c := cache.NewCache([]cache.CIProvider{mockProvider{id: "id"}}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	controller, err := NewController(&tui, NewBuildsByCommit(&c), time.UTC, dir, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.resize(80, 20)
+	controller.refresh()
+	if !controller.table.Jump(jobAsRow.key) {
+		t.Fatal("job row not found")
+	}
+	ctx := context.Background()
+
+	if err := controller.diffLog(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyOutcome(t, &controller); err != nil {
+		t.Fatal(err)
+	}
+	if controller.markedLog == "" {
+		t.Fatal("expected the log of the job to be marked")
+	}
+
+	// Fetching the log and comparing it both run in the background
+	if err := controller.diffLog(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := applyOutcome(t, &controller); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buffer := controller.status.outputBuffer
+	if status := buffer[len(buffer)-1]; status != "Logs are identical" {
+		t.Fatalf("unexpected status %q", status)
+	}
+	if controller.pager != nil {
+		t.Fatal("the pager must stay closed for identical logs")
+	}
+}
+
+func TestController_restartPipeline(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, text.StyleSheet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+	restarted := build
+	restarted.ID = "43"
+	restarted.State = cache.Pending
+	restarted.WebURL = "example.com/pipeline/43"
+	c := cache.NewCache([]cache.CIProvider{
+		mockRestarter{mockProvider: mockProvider{id: "id"}, restarted: restarted},
+	}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	controller, err := NewController(&tui, NewBuildsByCommit(&c), time.UTC, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.resize(80, 20)
+	controller.refresh()
+	if !controller.table.Jump(buildAsRow.key) {
+		t.Fatal("pipeline row not found")
+	}
+
+	// The first call asks for a confirmation, the second one restarts the pipeline in the
+	// background
+	for i := 0; i < 2; i++ {
+		if err := controller.restartPipeline(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := applyOutcome(t, &controller); err != nil {
+		t.Fatal(err)
+	}
+	expected := buildAsRow.key
+	expected.buildID = "43"
+	if key, _ := controller.table.ActiveKey(); key != expected {
+		t.Fatalf("expected the cursor on %v but got %v", expected, key)
+	}
+	buffer := controller.status.outputBuffer
+	if status := buffer[len(buffer)-1]; status != "Restarted pipeline #42, the cursor is on the new run" {
+		t.Fatalf("unexpected status %q", status)
+	}
+}
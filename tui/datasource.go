@@ -0,0 +1,220 @@
+package tui
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/text"
+	"github.com/nbedos/citop/utils"
+)
+
+type HierarchicalTabularSourceRow interface {
+	Tabular(*time.Location) map[string]text.StyledString
+	Key() interface{}
+	URL() string
+	SetPrefix(s string)
+	utils.TreeNode
+}
+
+type HierarchicalTabularDataSource interface {
+	Rows() []HierarchicalTabularSourceRow
+	Headers() []string
+	Alignment() map[string]text.Alignment
+	WriteToDisk(ctx context.Context, key interface{}, tmpDir string) (string, error)
+	// Details returns a plain text description of the row designated by 'key'
+	Details(key interface{}) (string, error)
+}
+
+// GroupedDataSource is implemented by data sources able to hide the intermediate level of the
+// hierarchy of their rows
+type GroupedDataSource interface {
+	SetGrouped(grouped bool)
+}
+
+// JobsDataSource is implemented by data sources able to list the leaves of the hierarchy of their
+// rows in a flat table
+type JobsDataSource interface {
+	SetJobsOnly(jobsOnly bool)
+}
+
+// SortableDataSource is implemented by data sources whose rows can be sorted by column
+type SortableDataSource interface {
+	SetSort(column string, reverse bool)
+}
+
+// DeploymentDataSource is implemented by data sources whose rows include deployments the user
+// can act on
+type DeploymentDataSource interface {
+	// Deployment returns the deployment designated by 'key'
+	Deployment(key interface{}) (cache.Deployment, error)
+	StopEnvironment(ctx context.Context, key interface{}) error
+	RetryDeployment(ctx context.Context, key interface{}) error
+}
+
+// CancelDataSource is implemented by data sources whose rows include pipelines and jobs the user
+// can cancel
+type CancelDataSource interface {
+	// Cancelable returns a description of the active pipeline or job designated by 'key' such
+	// as `pipeline #42` or `job "tests"`
+	Cancelable(key interface{}) (string, error)
+	Cancel(ctx context.Context, key interface{}) error
+}
+
+// RestartDataSource is implemented by data sources whose rows include pipelines the user can run
+// again
+type RestartDataSource interface {
+	// Restartable returns a description of the finished pipeline designated by 'key'
+	Restartable(key interface{}) (string, error)
+	// Restart runs the pipeline again and returns the key of the row of the pipeline started,
+	// nil if the row does not exist yet
+	Restart(ctx context.Context, key interface{}) (interface{}, error)
+}
+
+// RetryDataSource is implemented by data sources whose rows include jobs the user can run again
+type RetryDataSource interface {
+	// Retryable returns a description of the finished job designated by 'key'
+	Retryable(key interface{}) (string, error)
+	// Retry runs the job again and returns the key of the row of the job started
+	Retry(ctx context.Context, key interface{}) (interface{}, error)
+}
+
+// ManualJobDataSource is implemented by data sources whose rows include jobs waiting for the
+// action of a user, such as manual jobs and approvals
+type ManualJobDataSource interface {
+	// ManualJob returns a description of the job designated by 'key' if it waits for a user
+	ManualJob(key interface{}) (string, error)
+	StartManualJob(ctx context.Context, key interface{}) error
+}
+
+// TriggerDataSource is implemented by data sources able to start new pipelines
+type TriggerDataSource interface {
+	// TriggerPipelines starts a pipeline on 'ref' of the repository at 'repositoryURL' with
+	// every provider able to do so and returns the web pages of the pipelines started
+	TriggerPipelines(ctx context.Context, repositoryURL string, ref string) ([]string, error)
+}
+
+// ArtifactDataSource is implemented by data sources whose rows include jobs whose artifacts can
+// be listed
+type ArtifactDataSource interface {
+	// Artifacts returns a data source listing the artifacts of the job designated by 'key'
+	// along with a description of the job
+	Artifacts(ctx context.Context, key interface{}) (HierarchicalTabularDataSource, string, error)
+}
+
+// TestReportDataSource is implemented by data sources able to list the results of the tests of
+// jobs under their rows
+type TestReportDataSource interface {
+	// TestReport fetches the results of the tests of the job designated by 'key' and returns
+	// the key of the row summarizing them
+	TestReport(ctx context.Context, key interface{}) (interface{}, cache.TestReport, error)
+}
+
+// LogFollowDataSource is implemented by data sources able to append the lines added to the log of
+// a running job to the file written by WriteToDisk
+type LogFollowDataSource interface {
+	// LogRunning returns true if the log of the row designated by 'key' is not complete yet
+	LogRunning(key interface{}) bool
+	// FollowLog appends the lines added to the log of the row designated by 'key' to the file
+	// at 'logPath' every 'interval' until the job is finished or 'ctx' is done
+	FollowLog(ctx context.Context, key interface{}, logPath string, interval time.Duration) error
+}
+
+// PipelineLogDataSource is implemented by data sources able to show the logs of all the jobs of
+// a pipeline or of a stage at once
+type PipelineLogDataSource interface {
+	// WriteLogsToDisk writes the logs of the jobs of the pipeline or stage designated by 'key'
+	// to a file of 'dir' and returns the path of the file
+	WriteLogsToDisk(ctx context.Context, key interface{}, dir string) (string, error)
+}
+
+// LogSaveDataSource is implemented by data sources able to save the raw logs of their jobs
+type LogSaveDataSource interface {
+	// SaveLog writes the raw log of the job designated by 'key' to the file whose path is given
+	// by 'pathTemplate' and returns the path of the file
+	SaveLog(ctx context.Context, key interface{}, pathTemplate *template.Template) (string, error)
+}
+
+// TimestampDataSource is implemented by data sources able to change the timestamps prefixing the
+// lines of the logs they write to disk
+type TimestampDataSource interface {
+	SetTimestampMode(mode TimestampMode)
+	SetTimingGutter(show bool)
+}
+
+// SummaryDataSource is implemented by data sources able to count their pipelines by state
+type SummaryDataSource interface {
+	Summary() Summary
+}
+
+// HealthDataSource is implemented by data sources able to tell whether the providers of their
+// pipelines can be reached
+type HealthDataSource interface {
+	Health() []cache.ProviderHealth
+}
+
+// LogSearchDataSource is implemented by data sources able to search the logs of all the jobs of
+// a pipeline
+type LogSearchDataSource interface {
+	SearchLogs(ctx context.Context, key interface{}, pattern *regexp.Regexp) (LogSearch, error)
+}
+
+// ProblemDataSource is implemented by data sources looking for problems in the logs they write
+// to disk
+type ProblemDataSource interface {
+	// Problems returns the problems found in the log of the job designated by 'key' when the log
+	// was last written to disk
+	Problems(key interface{}) []Problem
+	// LogLine returns the line of the log of the row designated by 'key' to show first, starting
+	// at 1, or 0 to show the beginning of the log
+	LogLine(key interface{}) int
+}
+
+func Prefix(row HierarchicalTabularSourceRow, indent string, last bool) {
+	var prefix string
+	// Special behavior for the root node which is prefixed by "+" if its children are hidden
+	if indent == "" {
+		switch {
+		case len(row.Children()) == 0:
+			prefix = " "
+		case row.Traversable():
+			prefix = "-"
+		default:
+			prefix = "+"
+		}
+	} else {
+		if last {
+			prefix = "└─"
+		} else {
+			prefix = "├─"
+		}
+
+		if len(row.Children()) == 0 || row.Traversable() {
+			prefix += "─ "
+		} else {
+			prefix += "+ "
+		}
+	}
+
+	row.SetPrefix(indent + prefix)
+
+	if row.Traversable() {
+		for i, child := range row.Children() {
+			child := child.(HierarchicalTabularSourceRow)
+			var childIndent string
+			if last {
+				childIndent = " "
+			} else {
+				childIndent = "│"
+			}
+
+			paddingLength := runewidth.StringWidth(prefix) - runewidth.StringWidth(childIndent)
+			childIndent += strings.Repeat(" ", paddingLength)
+			Prefix(child, indent+childIndent, i == len(row.Children())-1)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Number of unchanged lines shown around each change of a unified diff
+const diffContext = 3
+
+// Maximum number of edits explored from each end of the ranges compared by the middle snake
+// search. Ranges that differ more than that, such as the logs of unrelated jobs, are reported as
+// entirely changed so that the time spent comparing them stays reasonable.
+const maxDiffSteps = 1000
+
+type diffOperation byte
+
+const (
+	diffEqual  diffOperation = ' '
+	diffDelete diffOperation = '-'
+	diffInsert diffOperation = '+'
+)
+
+type diffLine struct {
+	op   diffOperation
+	text string
+	// Indices of the line in the old and new versions before the operation is applied
+	a, b int
+}
+
+// Return the shortest edit script turning 'a' into 'b'. Memory use is linear in the number of
+// lines so that large logs can be compared.
+func diffLines(a []string, b []string) []diffLine {
+	d := differ{
+		a:     a,
+		b:     b,
+		lines: make([]diffLine, 0, len(a)+len(b)),
+	}
+	d.compare(0, len(a), 0, len(b))
+	return d.lines
+}
+
+// differ computes the edit script turning 'a' into 'b' with the linear space variant of Myers'
+// O(ND) algorithm: the middle snake of a shortest edit script splits the problem into two
+// smaller ones solved recursively
+type differ struct {
+	a     []string
+	b     []string
+	lines []diffLine
+}
+
+func (d *differ) equal(x int, y int) {
+	d.lines = append(d.lines, diffLine{op: diffEqual, text: d.a[x], a: x, b: y})
+}
+
+func (d *differ) delete(x int, y int) {
+	d.lines = append(d.lines, diffLine{op: diffDelete, text: d.a[x], a: x, b: y})
+}
+
+func (d *differ) insert(x int, y int) {
+	d.lines = append(d.lines, diffLine{op: diffInsert, text: d.b[y], a: x, b: y})
+}
+
+// Append the edit script turning a[a0:a1] into b[b0:b1]. Common prefixes and suffixes are
+// skipped beforehand since logs of two runs of the same job usually share most of their lines.
+func (d *differ) compare(a0 int, a1 int, b0 int, b1 int) {
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.equal(a0, b0)
+		a0++
+		b0++
+	}
+	suffix := 0
+	for a1-suffix > a0 && b1-suffix > b0 && d.a[a1-suffix-1] == d.b[b1-suffix-1] {
+		suffix++
+	}
+	a1, b1 = a1-suffix, b1-suffix
+
+	switch {
+	case a0 == a1:
+		for y := b0; y < b1; y++ {
+			d.insert(a0, y)
+		}
+	case b0 == b1:
+		for x := a0; x < a1; x++ {
+			d.delete(x, b0)
+		}
+	default:
+		if x, y, ok := d.middleSnake(a0, a1, b0, b1); ok {
+			d.compare(a0, x, b0, y)
+			d.compare(x, a1, y, b1)
+		} else {
+			for x := a0; x < a1; x++ {
+				d.delete(x, b0)
+			}
+			for y := b0; y < b1; y++ {
+				d.insert(a1, y)
+			}
+		}
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.equal(a1+i, b1+i)
+	}
+}
+
+// Return the point where the paths explored from both ends of the edit graph of a[a0:a1] and
+// b[b0:b1] meet, or false if the ranges have no line in common or differ by more than
+// 2*maxDiffSteps edits. Both ranges must be non-empty and must not share their first or their
+// last line.
+func (d *differ) middleSnake(a0 int, a1 int, b0 int, b1 int) (int, int, bool) {
+	n, m := a1-a0, b1-b0
+	maxD := (n + m + 1) / 2
+	if maxD > maxDiffSteps {
+		maxD = maxDiffSteps
+	}
+	offset := maxD + 1
+	// forward[offset+k] is the furthest x reached on diagonal k from the beginning, backward
+	// the same from the end, -1 if the diagonal has not been reached yet
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+	delta := n - m
+	// Paths overlap during the forward pass if delta is odd and during the backward pass if
+	// delta is even
+	odd := delta%2 != 0
+	// Diagonals on which paths left the edit graph are not explored any further
+	kStartF, kEndF, kStartB, kEndB := 0, 0, 0, 0
+
+	for step := 0; step < maxD; step++ {
+		for k := -step + kStartF; k <= step-kEndF; k += 2 {
+			var x int
+			if k == -step || (k != step && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[a0+x] == d.b[b0+y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			switch {
+			case x > n:
+				kEndF += 2
+			case y > m:
+				kStartF += 2
+			case odd:
+				if i := offset + delta - k; i >= 0 && i < len(backward) && backward[i] != -1 && x >= n-backward[i] {
+					return a0 + x, b0 + y, true
+				}
+			}
+		}
+
+		for k := -step + kStartB; k <= step-kEndB; k += 2 {
+			var x int
+			if k == -step || (k != step && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[a1-x-1] == d.b[b1-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			switch {
+			case x > n:
+				kEndB += 2
+			case y > m:
+				kStartB += 2
+			case !odd:
+				if i := offset + delta - k; i >= 0 && i < len(forward) && forward[i] != -1 {
+					forwardX := forward[i]
+					forwardY := forwardX - (i - offset)
+					if forwardX >= n-x {
+						return a0 + forwardX, b0 + forwardY, true
+					}
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// Return the range of a hunk header in the format used by diff -u
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// unifiedDiff returns the differences between 'a' and 'b' in the unified format of diff -u,
+// or an empty string if both texts are identical
+func unifiedDiff(nameA string, a string, nameB string, b string) string {
+	split := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	}
+	lines := diffLines(split(a), split(b))
+
+	// Group changes separated by less than 2*diffContext unchanged lines into the same hunk
+	hunks := make([][2]int, 0)
+	for i, line := range lines {
+		if line.op == diffEqual {
+			continue
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i + diffContext + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+		if len(hunks) > 0 && hunks[len(hunks)-1][1] >= start {
+			hunks[len(hunks)-1][1] = end
+		} else {
+			hunks = append(hunks, [2]int{start, end})
+		}
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "--- %s\n+++ %s\n", nameA, nameB)
+	for _, hunk := range hunks {
+		countA, countB := 0, 0
+		for _, line := range lines[hunk[0]:hunk[1]] {
+			if line.op != diffInsert {
+				countA++
+			}
+			if line.op != diffDelete {
+				countB++
+			}
+		}
+		first := lines[hunk[0]]
+		fmt.Fprintf(&builder, "@@ -%s +%s @@\n", hunkRange(first.a, countA), hunkRange(first.b, countB))
+		for _, line := range lines[hunk[0]:hunk[1]] {
+			fmt.Fprintf(&builder, "%c%s\n", line.op, line.text)
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,132 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a        string
+		b        string
+		expected string
+	}{
+		{
+			name:     "identical",
+			a:        "a\nb\nc\n",
+			b:        "a\nb\nc\n",
+			expected: "",
+		},
+		{
+			name:     "empty logs",
+			a:        "",
+			b:        "",
+			expected: "",
+		},
+		{
+			name: "from empty log",
+			a:    "",
+			b:    "a\nb\n",
+			expected: "--- before\n+++ after\n" +
+				"@@ -0,0 +1,2 @@\n" +
+				"+a\n" +
+				"+b\n",
+		},
+		{
+			name: "single change",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			expected: "--- before\n+++ after\n" +
+				"@@ -2,7 +2,7 @@\n" +
+				" 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "distant changes",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			expected: "--- before\n+++ after\n" +
+				"@@ -1,3 +1,4 @@\n" +
+				"+0\n 1\n 2\n 3\n" +
+				"@@ -9,4 +10,3 @@\n" +
+				" 9\n 10\n 11\n-12\n",
+		},
+		{
+			name: "interleaved changes",
+			a:    "a\nb\nc\na\nb\nb\na\n",
+			b:    "c\nb\na\nb\na\nc\n",
+			expected: "--- before\n+++ after\n" +
+				"@@ -1,7 +1,6 @@\n" +
+				"-a\n+c\n b\n-c\n a\n b\n-b\n a\n+c\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			diff := unifiedDiff("before", testCase.a, "after", testCase.b)
+			if diff := cmp.Diff(testCase.expected, diff); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	// Large logs differing on most of their lines
+	var large [2][]string
+	for i := 0; i < 20000; i++ {
+		large[0] = append(large[0], fmt.Sprintf("line %d", i))
+		large[1] = append(large[1], fmt.Sprintf("line %d", i*7%20000))
+	}
+	var unrelated [2][]string
+	for i := 0; i < 20000; i++ {
+		unrelated[0] = append(unrelated[0], fmt.Sprintf("a %d", i))
+		unrelated[1] = append(unrelated[1], fmt.Sprintf("b %d", i))
+	}
+
+	testCases := []struct {
+		name string
+		a    []string
+		b    []string
+	}{
+		{
+			name: "small",
+			a:    strings.Split("x\ny\nz\nx\ny\nz\nfoo\nbar", "\n"),
+			b:    strings.Split("y\nz\nbaz\nx\nfoo\nz\nbar\nqux", "\n"),
+		},
+		{
+			name: "large",
+			a:    large[0],
+			b:    large[1],
+		},
+		{
+			name: "unrelated",
+			a:    unrelated[0],
+			b:    unrelated[1],
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Applying the edit script to the old version must produce the new version
+			old, new := make([]string, 0), make([]string, 0)
+			for _, line := range diffLines(testCase.a, testCase.b) {
+				if line.op != diffInsert {
+					old = append(old, line.text)
+				}
+				if line.op != diffDelete {
+					new = append(new, line.text)
+				}
+			}
+			if diff := cmp.Diff(testCase.a, old); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(testCase.b, new); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
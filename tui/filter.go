@@ -0,0 +1,120 @@
+package tui
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/nbedos/citop/cache"
+)
+
+// Filter restricts the pipelines and jobs fetched by the cache. Nil fields keep everything.
+type Filter struct {
+	Jobs      cache.JobFilter
+	Pipelines cache.PipelineFilter
+}
+
+// Set the filters of 'c'
+func (f Filter) apply(c *cache.Cache) {
+	c.SetJobFilter(f.Jobs)
+	c.SetPipelineFilter(f.Pipelines)
+}
+
+// Return a function telling whether one of the strings passed as argument matches one of
+// 'patterns'. A pattern written between slashes, such as "/^deploy-(staging|production)$/", is a
+// regular expression. Any other pattern is a glob, such as "deploy-*".
+func compilePatterns(patterns []string) (func(...string) bool, error) {
+	matchers := make([]func(string) bool, 0, len(patterns))
+	for _, pattern := range patterns {
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			r, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+			matchers = append(matchers, r.MatchString)
+			continue
+		}
+		// Report malformed globs now rather than silently rejecting everything
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		glob := pattern
+		matchers = append(matchers, func(s string) bool {
+			matched, _ := path.Match(glob, s)
+			return matched
+		})
+	}
+
+	return func(values ...string) bool {
+		for _, match := range matchers {
+			for _, value := range values {
+				if match(value) {
+					return true
+				}
+			}
+		}
+		return false
+	}, nil
+}
+
+// Return the strings that the patterns designating a job are matched against
+func jobNames(stage string, job string) []string {
+	if stage == "" {
+		return []string{job}
+	}
+	return []string{job, stage + "/" + job}
+}
+
+// NewJobFilter returns a filter keeping the jobs matching at least one of 'patterns' and none of
+// 'ignored'. All jobs match if 'patterns' is empty. Patterns are matched against the name of the
+// job and against "stage/job" for jobs belonging to a stage (see compilePatterns for the syntax
+// of patterns). A nil filter is returned if there are no patterns at all.
+func NewJobFilter(patterns []string, ignored []string) (cache.JobFilter, error) {
+	if len(patterns) == 0 && len(ignored) == 0 {
+		return nil, nil
+	}
+
+	include, err := compilePatterns(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job pattern: %v", err)
+	}
+	exclude, err := compilePatterns(ignored)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job pattern: %v", err)
+	}
+
+	return func(stage string, job string) bool {
+		names := jobNames(stage, job)
+		return (len(patterns) == 0 || include(names...)) && !exclude(names...)
+	}, nil
+}
+
+// NewPipelineFilter returns a filter rejecting the pipelines matching one of 'ignored'. Patterns
+// are matched against the identifier, the name and the ref of the pipeline, and against
+// "provider/value" for each of them where provider is the name of the CI provider. A nil filter is
+// returned if there are no patterns.
+func NewPipelineFilter(ignored []string) (cache.PipelineFilter, error) {
+	if len(ignored) == 0 {
+		return nil, nil
+	}
+
+	exclude, err := compilePatterns(ignored)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pipeline pattern: %v", err)
+	}
+
+	return func(build cache.Build) bool {
+		names := make([]string, 0, 6)
+		for _, value := range []string{build.ID, build.Name, build.Ref} {
+			if value == "" {
+				continue
+			}
+			names = append(names, value)
+			if build.Repository != nil {
+				names = append(names, build.Repository.Provider.Name+"/"+value)
+			}
+		}
+		return !exclude(names...)
+	}, nil
+}
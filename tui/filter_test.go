@@ -0,0 +1,138 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/nbedos/citop/cache"
+)
+
+func TestNewJobFilter(t *testing.T) {
+	t.Run("no pattern", func(t *testing.T) {
+		filter, err := NewJobFilter(nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filter != nil {
+			t.Fatal("expected nil filter")
+		}
+	})
+
+	t.Run("globs and regular expressions", func(t *testing.T) {
+		filter, err := NewJobFilter([]string{"deploy-*", "/^(unit|integration)$/", "docs/*"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testCases := []struct {
+			stage string
+			job   string
+			kept  bool
+		}{
+			{"", "deploy-staging", true},
+			{"deploy", "deploy-production", true},
+			{"", "predeploy-staging", false},
+			{"tests", "unit", true},
+			{"tests", "unit-go1.13", false},
+			{"docs", "pages", true},
+			{"", "pages", false},
+			{"", "lint", false},
+		}
+		for _, testCase := range testCases {
+			if kept := filter(testCase.stage, testCase.job); kept != testCase.kept {
+				t.Errorf("expected %v for job %q of stage %q but got %v", testCase.kept, testCase.job, testCase.stage, kept)
+			}
+		}
+	})
+
+	t.Run("invalid patterns", func(t *testing.T) {
+		for _, pattern := range []string{"/(/", "deploy-["} {
+			if _, err := NewJobFilter([]string{pattern}, nil); err == nil {
+				t.Errorf("expected error for pattern %q but got nil", pattern)
+			}
+			if _, err := NewJobFilter(nil, []string{pattern}); err == nil {
+				t.Errorf("expected error for ignored pattern %q but got nil", pattern)
+			}
+		}
+	})
+
+	t.Run("ignored jobs", func(t *testing.T) {
+		filter, err := NewJobFilter(nil, []string{"canary-*", "bots/*"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		testCases := []struct {
+			stage string
+			job   string
+			kept  bool
+		}{
+			{"", "canary-nightly", false},
+			{"deploy", "canary-nightly", false},
+			{"bots", "dependabot", false},
+			{"tests", "unit", true},
+			{"", "dependabot", true},
+		}
+		for _, testCase := range testCases {
+			if kept := filter(testCase.stage, testCase.job); kept != testCase.kept {
+				t.Errorf("expected %v for job %q of stage %q but got %v", testCase.kept, testCase.job, testCase.stage, kept)
+			}
+		}
+	})
+
+	t.Run("ignored jobs take precedence", func(t *testing.T) {
+		filter, err := NewJobFilter([]string{"deploy-*"}, []string{"*-canary"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !filter("", "deploy-staging") {
+			t.Error("expected deploy-staging to be kept")
+		}
+		if filter("", "deploy-canary") {
+			t.Error("expected deploy-canary to be rejected")
+		}
+	})
+}
+
+func TestNewPipelineFilter(t *testing.T) {
+	filter, err := NewPipelineFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter != nil {
+		t.Fatal("expected nil filter")
+	}
+
+	filter, err = NewPipelineFilter([]string{"/^Scheduled /", "github/Dependabot*", "gh-pages"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		provider string
+		id       string
+		name     string
+		ref      string
+		kept     bool
+	}{
+		// GitHub identifies workflow runs and check runs by a number, patterns match their name
+		{"github", "39455623", "Dependabot Updates", "dependabot/go_modules/x", false},
+		{"github", "352137581", "Dependabot", "master", false},
+		{"github", "39455624", "CI", "master", true},
+		{"gitlab", "12", "Dependabot", "master", true},
+		{"gitlab", "Scheduled canary #12", "", "master", false},
+		{"gitlab", "13", "", "gh-pages", false},
+		{"gitlab", "14", "", "master", true},
+	}
+	for _, testCase := range testCases {
+		build := cache.Build{
+			Repository: &cache.Repository{Provider: cache.Provider{Name: testCase.provider}},
+			ID:         testCase.id,
+			Name:       testCase.name,
+			Ref:        testCase.ref,
+		}
+		if kept := filter(build); kept != testCase.kept {
+			t.Errorf("expected %v for pipeline %q (%q) of %s but got %v", testCase.kept, testCase.id, testCase.name, testCase.provider, kept)
+		}
+	}
+
+	if _, err := NewPipelineFilter([]string{"/(/"}); err == nil {
+		t.Error("expected error but got nil")
+	}
+}
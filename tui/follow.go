@@ -0,0 +1,233 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// Interval between two checks of the commit referenced by HEAD
+const headPollInterval = 2 * time.Second
+
+// Interval between two checks of the commit referenced by HEAD when the git directory is
+// watched for changes. Polling only catches the changes missed by the watcher.
+const headWatchedPollInterval = 30 * time.Second
+
+// Delay between a change of the git directory and the check of HEAD. Git updates a reference
+// by writing then renaming a lock file so a single commit causes several events.
+const headChangeDelay = 100 * time.Millisecond
+
+// Interval between two requests for the commit at the tip of a remote branch. Requests count
+// against the rate limit of source providers so this is much longer than headPollInterval.
+const branchPollInterval = time.Minute
+
+// FollowMode tells what the user interface does when the remote branch being monitored
+// advances to a new commit
+type FollowMode string
+
+const (
+	// Tell the user about the new commit and switch to it on request
+	FollowAsk FollowMode = "ask"
+	// Switch to the new commit without asking
+	FollowAuto FollowMode = "auto"
+	// Do not look for new commits
+	FollowOff FollowMode = "off"
+)
+
+// ParseFollowMode returns the mode named 's'. An empty string selects FollowAsk.
+func ParseFollowMode(s string) (FollowMode, error) {
+	switch m := FollowMode(strings.ToLower(s)); m {
+	case "":
+		return FollowAsk, nil
+	case FollowAsk, FollowAuto, FollowOff:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid follow mode %q (expected \"ask\", \"auto\" or \"off\")", s)
+	}
+}
+
+// Return the first 7 characters of a SHA identifier
+func shortSha(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// Return the name of the remote branch designated by 'ref' if 'ref' is the name of a branch
+// pointing to 'commit', either local or remote-tracking
+func remoteBranch(ref string, commit utils.Commit) (string, bool) {
+	for _, branch := range commit.Branches {
+		if branch == ref {
+			return strings.TrimPrefix(ref, "origin/"), true
+		}
+	}
+	return "", false
+}
+
+// Return the branch or the tag to start pipelines on to test 'commit', that is to say the branch
+// checked out or the only branch or tag pointing to the commit. False is returned if there is no
+// such reference.
+func triggerRef(commit utils.Commit) (string, bool) {
+	if commit.Head != "" {
+		return commit.Head, true
+	}
+	branches := make(map[string]struct{})
+	for _, branch := range commit.Branches {
+		branches[strings.TrimPrefix(branch, "origin/")] = struct{}{}
+	}
+	switch {
+	case len(branches) == 1:
+		for branch := range branches {
+			return branch, true
+		}
+	case len(branches) == 0 && len(commit.Tags) == 1:
+		return commit.Tags[0], true
+	}
+	return "", false
+}
+
+// Send on 'commits' the commit referenced by HEAD in the local git repository 'repo' every time
+// it changes until 'ctx' is canceled. 'sha' is the commit referenced by HEAD when the function
+// is called. Changes of the git directory trigger an immediate check of HEAD and HEAD is also
+// checked every 'interval', or every headWatchedPollInterval if the git directory is watched.
+// Errors are ignored since HEAD may be temporarily unreadable, for example while a rebase is
+// in progress.
+func watchHead(ctx context.Context, repo string, sha string, interval time.Duration, commits chan<- utils.Commit) {
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	var watcher *fsnotify.Watcher
+	if gitDir, err := utils.GitDir(repo); err == nil {
+		if watcher, err = newRefWatcher(gitDir); err == nil {
+			defer watcher.Close()
+			events, watchErrors = watcher.Events, watcher.Errors
+			if interval < headWatchedPollInterval {
+				interval = headWatchedPollInterval
+			}
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	delay := time.NewTimer(headChangeDelay)
+	delay.Stop()
+
+	check := func() bool {
+		_, commit, err := utils.GitOriginURL(repo, "HEAD")
+		if err != nil || commit.Sha == sha {
+			return true
+		}
+		select {
+		case commits <- commit:
+			sha = commit.Sha
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// HEAD may have moved before the watcher was set up
+	if !check() {
+		return
+	}
+
+	for {
+		select {
+		case event := <-events:
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() && event.Op&fsnotify.Create != 0 {
+				// New directory of branches such as "refs/heads/feature"
+				_ = watcher.Add(event.Name)
+			}
+			if isRefEvent(event) {
+				delay.Reset(headChangeDelay)
+			}
+		case <-watchErrors:
+		case <-delay.C:
+			if !check() {
+				return
+			}
+		case <-ticker.C:
+			if !check() {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Return a watcher of the files of the git directory 'gitDir' modified when HEAD moves: HEAD
+// itself, packed-refs and the references of local branches
+func newRefWatcher(gitDir string) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{gitDir}
+	err = filepath.Walk(filepath.Join(gitDir, "refs", "heads"), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err == nil {
+		for _, dir := range dirs {
+			if err = watcher.Add(dir); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	return watcher, nil
+}
+
+// Return true if 'event' may be caused by HEAD moving. Changes of other files of the git
+// directory, like the index, are ignored.
+func isRefEvent(event fsnotify.Event) bool {
+	switch filepath.Base(event.Name) {
+	case "HEAD", "packed-refs":
+		return true
+	}
+	return strings.Contains(filepath.ToSlash(event.Name), "/refs/heads/")
+}
+
+// Send on 'commits' the commit at the tip of 'branch' in the online repository at
+// 'repositoryURL' every time it changes until 'ctx' is canceled. 'sha' is the commit monitored
+// when the function is called. Errors are ignored since the branch may not have been pushed yet.
+func watchBranch(ctx context.Context, repositoryURL string, branch string, sha string, interval time.Duration, sourceProviders []cache.SourceProvider, commits chan<- utils.Commit) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			commit, err := remoteCommit(ctx, repositoryURL, branch, sourceProviders)
+			if err != nil || commit.Sha == sha {
+				continue
+			}
+			select {
+			case commits <- commit:
+				sha = commit.Sha
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
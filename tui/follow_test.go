@@ -0,0 +1,212 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func TestWatchHead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=citop", "-c", "user.email=citop@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v (%s)", args, err, output)
+		}
+	}
+	git("init")
+	git("remote", "add", "origin", "https://github.com/nbedos/citop.git")
+	git("commit", "--allow-empty", "-m", "first commit")
+
+	_, first, err := utils.GitOriginURL(dir, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	commits := make(chan utils.Commit)
+	go watchHead(ctx, dir, first.Sha, 10*time.Millisecond, commits)
+
+	git("commit", "--allow-empty", "-m", "second commit")
+	select {
+	case commit := <-commits:
+		if commit.Sha == first.Sha {
+			t.Fatalf("expected a new commit but got %s", commit.Sha)
+		}
+		if commit.Message != "second commit\n" {
+			t.Fatalf("unexpected commit message %q", commit.Message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the new commit")
+	}
+}
+
+type branchProvider struct {
+	mutex *sync.Mutex
+	tips  map[string]string
+}
+
+func (p branchProvider) ID() string { return "branches" }
+func (p branchProvider) BuildURLs(ctx context.Context, owner string, repo string, sha string) ([]string, error) {
+	return nil, nil
+}
+func (p branchProvider) Commit(ctx context.Context, repo string, sha string) (utils.Commit, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	tip, exists := p.tips[sha]
+	if !exists {
+		return utils.Commit{}, errors.New("unknown branch")
+	}
+	return utils.Commit{Sha: tip, Branches: []string{sha}}, nil
+}
+
+func TestWatchBranch(t *testing.T) {
+	p := branchProvider{
+		mutex: &sync.Mutex{},
+		tips:  map[string]string{"master": "a"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	commits := make(chan utils.Commit)
+	go watchBranch(ctx, "github.com/nbedos/citop", "master", "a", 10*time.Millisecond, []cache.SourceProvider{p}, commits)
+
+	p.mutex.Lock()
+	p.tips["master"] = "b"
+	p.mutex.Unlock()
+	select {
+	case commit := <-commits:
+		if commit.Sha != "b" {
+			t.Fatalf("expected commit %q but got %q", "b", commit.Sha)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the new commit")
+	}
+}
+
+func TestRemoteBranch(t *testing.T) {
+	commit := utils.Commit{
+		Sha:      "a",
+		Branches: []string{"master", "origin/master"},
+		Tags:     []string{"0.9.0"},
+	}
+	testCases := []struct {
+		ref    string
+		branch string
+		ok     bool
+	}{
+		{"master", "master", true},
+		{"origin/master", "master", true},
+		{"0.9.0", "", false},
+		{"a", "", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.ref, func(t *testing.T) {
+			branch, ok := remoteBranch(testCase.ref, commit)
+			if branch != testCase.branch || ok != testCase.ok {
+				t.Fatalf("expected (%q, %v) but got (%q, %v)", testCase.branch, testCase.ok, branch, ok)
+			}
+		})
+	}
+}
+
+func TestTriggerRef(t *testing.T) {
+	testCases := []struct {
+		name   string
+		commit utils.Commit
+		ref    string
+		ok     bool
+	}{
+		{
+			name:   "branch checked out",
+			commit: utils.Commit{Head: "feature", Branches: []string{"feature", "master"}},
+			ref:    "feature",
+			ok:     true,
+		},
+		{
+			name:   "single branch",
+			commit: utils.Commit{Branches: []string{"master", "origin/master"}, Tags: []string{"0.9.0"}},
+			ref:    "master",
+			ok:     true,
+		},
+		{
+			name:   "single tag",
+			commit: utils.Commit{Tags: []string{"0.9.0"}},
+			ref:    "0.9.0",
+			ok:     true,
+		},
+		{
+			name:   "several branches",
+			commit: utils.Commit{Branches: []string{"master", "feature"}},
+		},
+		{
+			name: "no reference",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ref, ok := triggerRef(testCase.commit)
+			if ref != testCase.ref || ok != testCase.ok {
+				t.Fatalf("expected (%q, %v) but got (%q, %v)", testCase.ref, testCase.ok, ref, ok)
+			}
+		})
+	}
+}
+
+func TestParseFollowMode(t *testing.T) {
+	for s, expected := range map[string]FollowMode{"": FollowAsk, "Auto": FollowAuto, "off": FollowOff} {
+		mode, err := ParseFollowMode(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode != expected {
+			t.Fatalf("expected %q but got %q", expected, mode)
+		}
+	}
+
+	if _, err := ParseFollowMode("always"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestIsRefEvent(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected bool
+	}{
+		{"/repo/.git/HEAD", true},
+		{"/repo/.git/packed-refs", true},
+		{"/repo/.git/refs/heads/feature/doc", true},
+		{"/repo/.git/refs/heads/master.lock", true},
+		{"/repo/.git/index", false},
+		{"/repo/.git/HEAD.lock", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			event := fsnotify.Event{Name: testCase.name, Op: fsnotify.Write}
+			if isRefEvent(event) != testCase.expected {
+				t.Fatalf("expected %v but got %v", testCase.expected, !testCase.expected)
+			}
+		})
+	}
+}
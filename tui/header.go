@@ -0,0 +1,48 @@
+package tui
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/nbedos/citop/text"
+	"github.com/nbedos/citop/utils"
+)
+
+var headerFuncs = template.FuncMap{
+	// List of the references pointing to the commit, as shown by 'git log --decorate'
+	"refs": func(c utils.Commit) string {
+		return text.Styled(c.Decoration())
+	},
+	// First line of a commit message
+	"title": func(message string) string {
+		return strings.SplitN(message, "\n", 2)[0]
+	},
+	// Commit message without its title
+	"body": func(message string) string {
+		parts := strings.SplitN(message, "\n", 2)
+		if len(parts) < 2 {
+			return ""
+		}
+		return strings.Trim(parts[1], "\n")
+	},
+	// Prefix each line of 's' with 'n' spaces
+	"indent": func(n int, s string) string {
+		prefix := strings.Repeat(" ", n)
+		return prefix + strings.Replace(s, "\n", "\n"+prefix, -1)
+	},
+}
+
+// NewHeaderTemplate parses the template used to describe the commit at the top of the screen.
+// The template is applied to a utils.Commit.
+func NewHeaderTemplate(s string) (*template.Template, error) {
+	return text.NewTemplate("header", s, headerFuncs)
+}
+
+// Return the lines describing 'commit' at the top of the screen. A nil template selects the
+// default description of the commit.
+func headerLines(tmpl *template.Template, commit utils.Commit) ([]text.StyledString, error) {
+	if tmpl == nil {
+		return commit.Strings(), nil
+	}
+	return text.ExecuteTemplate(tmpl, commit)
+}
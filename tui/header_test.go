@@ -0,0 +1,82 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbedos/citop/utils"
+)
+
+func TestHeaderLines(t *testing.T) {
+	commit := utils.Commit{
+		Sha:      "c2bb562365d40caec0b37138f73a87b6339a8b7a",
+		Author:   "Nicolas Bedos",
+		Date:     time.Date(2019, 11, 13, 13, 12, 11, 0, time.UTC),
+		Message:  "commit title\n\nline #1\nline #2\n",
+		Branches: []string{"master", "feature"},
+		Tags:     []string{"0.1.0"},
+		Head:     "master",
+	}
+
+	t.Run("default header", func(t *testing.T) {
+		lines, err := headerLines(nil, commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "commit c2bb562365d40caec0b37138f73a87b6339a8b7a (HEAD -> master, tag: 0.1.0, feature)"
+		if s := lines[0].String(); s != expected {
+			t.Fatalf("expected %q but got %q", expected, s)
+		}
+	})
+
+	t.Run("default header with pull request", func(t *testing.T) {
+		c := commit
+		c.PullRequest = &utils.PullRequest{Number: 12, Title: "Add the feature", State: "open", Review: "approved"}
+		lines, err := headerLines(nil, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "Pull request: #12 Add the feature (open, approved)"
+		if s := lines[3].String(); s != expected {
+			t.Fatalf("expected %q but got %q", expected, s)
+		}
+	})
+
+	t.Run("custom header", func(t *testing.T) {
+		tmpl, err := NewHeaderTemplate(`{{ style "sha" .Sha }} {{ refs . }}
+{{ title .Message }}
+{{ body .Message | indent 2 }}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines, err := headerLines(tmpl, commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{
+			"c2bb562365d40caec0b37138f73a87b6339a8b7a (HEAD -> master, tag: 0.1.0, feature)",
+			"commit title",
+			"  line #1",
+			"  line #2",
+		}
+		if len(lines) != len(expected) {
+			t.Fatalf("expected %d lines but got %d", len(expected), len(lines))
+		}
+		for i, line := range lines {
+			if s := line.String(); s != expected[i] {
+				t.Fatalf("expected %q but got %q", expected[i], s)
+			}
+		}
+	})
+
+	t.Run("invalid field", func(t *testing.T) {
+		tmpl, err := NewHeaderTemplate(`{{ .Reviewers }}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := headerLines(tmpl, commit); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/text"
+)
+
+// Interval between two checks of the status pages of providers
+const statusPageInterval = 5 * time.Minute
+
+// StatusPage reports the incidents affecting the online service of a provider
+type StatusPage interface {
+	// Name of the service shown to the user
+	Name() string
+	// Incident returns the description of the incident affecting the service, or an empty
+	// string if the service is operational
+	Incident(ctx context.Context) (string, error)
+}
+
+// Incident is an outage or a degradation of the service of a provider reported by its status
+// page
+type Incident struct {
+	Service     string
+	Description string
+}
+
+// Return the incidents reported by 'pages' sorted by service. Status pages that cannot be
+// reached are ignored: a warning would be misleading since the service itself may be
+// operational.
+func checkStatusPages(ctx context.Context, pages []StatusPage) []Incident {
+	type result struct {
+		name        string
+		description string
+		err         error
+	}
+	results := make(chan result, len(pages))
+	for _, page := range pages {
+		go func(page StatusPage) {
+			description, err := page.Incident(ctx)
+			results <- result{name: page.Name(), description: description, err: err}
+		}(page)
+	}
+
+	incidents := make([]Incident, 0)
+	for range pages {
+		r := <-results
+		if r.err == nil && r.description != "" {
+			incidents = append(incidents, Incident{Service: r.name, Description: r.description})
+		}
+	}
+	sort.Slice(incidents, func(i, j int) bool {
+		return incidents[i].Service < incidents[j].Service
+	})
+
+	return incidents
+}
+
+// Send on 'incidents' the incidents reported by 'pages' every time they change until 'ctx' is
+// canceled. Status pages are checked immediately then every 'interval'.
+func watchStatusPages(ctx context.Context, pages []StatusPage, interval time.Duration, incidents chan<- []Incident) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var previous []Incident
+	for {
+		current := checkStatusPages(ctx, pages)
+		if !equalIncidents(previous, current) {
+			select {
+			case incidents <- current:
+				previous = current
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func equalIncidents(a []Incident, b []Incident) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the lines warning the user about incidents, shown above the description of the commit
+func incidentLines(incidents []Incident) []text.StyledString {
+	lines := make([]text.StyledString, 0, len(incidents))
+	for _, incident := range incidents {
+		s := fmt.Sprintf("[!] %s reports an incident: %s", incident.Service, incident.Description)
+		lines = append(lines, text.NewStyledString(s, text.StatusFailed))
+	}
+	return lines
+}
+
+// Classes of the statuses of providers
+var providerStatusClasses = map[cache.ProviderStatus]text.Class{
+	cache.ProviderUnknown:     text.StatusPending,
+	cache.ProviderOK:          text.StatusPassed,
+	cache.ProviderRateLimited: text.StatusRunning,
+	cache.ProviderAuthFailed:  text.StatusFailed,
+	cache.ProviderUnreachable: text.StatusFailed,
+}
+
+// Return a compact description of the connectivity of each provider at time 'now' such as
+// "github ok 3s ago  gitlab rate-limited, last ok 2m10s ago"
+func providerIndicators(health []cache.ProviderHealth, now time.Time) text.StyledString {
+	indicators := make([]text.StyledString, 0, len(health))
+	for _, h := range health {
+		var s string
+		switch {
+		case h.Status == cache.ProviderUnknown:
+			s = fmt.Sprintf("%s connecting", h.ID)
+		case h.Status == cache.ProviderOK:
+			s = fmt.Sprintf("%s ok %s ago", h.ID, age(h.LastSuccess, now))
+		case h.LastSuccess.IsZero():
+			s = fmt.Sprintf("%s %s, never ok", h.ID, h.Status)
+		default:
+			s = fmt.Sprintf("%s %s, last ok %s ago", h.ID, h.Status, age(h.LastSuccess, now))
+		}
+		indicators = append(indicators, text.NewStyledString(s, providerStatusClasses[h.Status]))
+	}
+	return text.Join(indicators, text.NewStyledString("  "))
+}
@@ -0,0 +1,83 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+type testStatusPage struct {
+	name        string
+	description string
+	err         error
+}
+
+func (p testStatusPage) Name() string {
+	return p.name
+}
+
+func (p testStatusPage) Incident(ctx context.Context) (string, error) {
+	return p.description, p.err
+}
+
+func TestCheckStatusPages(t *testing.T) {
+	pages := []StatusPage{
+		testStatusPage{name: "travis", description: "Delays in build processing"},
+		testStatusPage{name: "github"},
+		testStatusPage{name: "gitlab", err: errors.New("unreachable")},
+		testStatusPage{name: "circleci", description: "Major Service Outage"},
+	}
+
+	expected := []Incident{
+		{Service: "circleci", Description: "Major Service Outage"},
+		{Service: "travis", Description: "Delays in build processing"},
+	}
+	if diff := cmp.Diff(expected, checkStatusPages(context.Background(), pages)); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestWatchStatusPages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pages := []StatusPage{
+		testStatusPage{name: "github", description: "Partial System Outage"},
+	}
+	incidents := make(chan []Incident)
+	go watchStatusPages(ctx, pages, time.Millisecond, incidents)
+
+	expected := []Incident{{Service: "github", Description: "Partial System Outage"}}
+	select {
+	case list := <-incidents:
+		if diff := cmp.Diff(expected, list); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no incident received")
+	}
+
+	// Incidents are only sent again if they change
+	select {
+	case list := <-incidents:
+		t.Fatalf("unexpected incidents %v", list)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestProviderIndicators(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	health := []cache.ProviderHealth{
+		{ID: "appveyor"},
+		{ID: "github", Status: cache.ProviderOK, LastSuccess: now.Add(-3 * time.Second)},
+		{ID: "gitlab", Status: cache.ProviderRateLimited, LastSuccess: now.Add(-130 * time.Second)},
+		{ID: "travis", Status: cache.ProviderAuthFailed},
+	}
+	expected := "appveyor connecting  github ok 3s ago  gitlab rate-limited, last ok 2m10s ago  travis auth failed, never ok"
+	if s := providerIndicators(health, now).String(); s != expected {
+		t.Fatalf("expected %q but got %q", expected, s)
+	}
+}
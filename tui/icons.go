@@ -0,0 +1,39 @@
+package tui
+
+import "github.com/nbedos/citop/cache"
+
+// StateIcons maps each state to a glyph displayed before the name of the state
+type StateIcons map[cache.State]string
+
+// UnicodeStateIcons only relies on symbols available in most fonts
+var UnicodeStateIcons = StateIcons{
+	cache.Pending:  "◷",
+	cache.Running:  "●",
+	cache.Passed:   "✓",
+	cache.Failed:   "✗",
+	cache.Canceled: "⊘",
+	cache.Skipped:  "↷",
+	cache.Manual:   "▸",
+}
+
+// NerdFontStateIcons requires a font patched with Nerd Fonts (https://www.nerdfonts.com/)
+var NerdFontStateIcons = StateIcons{
+	cache.Pending:  "", // nf-fa-clock_o
+	cache.Running:  "", // nf-fa-spinner
+	cache.Passed:   "", // nf-fa-check
+	cache.Failed:   "", // nf-fa-times
+	cache.Canceled: "", // nf-fa-ban
+	cache.Skipped:  "", // nf-fa-forward
+	cache.Manual:   "", // nf-fa-play
+}
+
+// ASCIIStateIcons is the fallback for terminals unable to display non-ASCII characters
+var ASCIIStateIcons = StateIcons{
+	cache.Pending:  ".",
+	cache.Running:  "*",
+	cache.Passed:   "+",
+	cache.Failed:   "x",
+	cache.Canceled: "/",
+	cache.Skipped:  "-",
+	cache.Manual:   ">",
+}
@@ -0,0 +1,361 @@
+package tui
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gdamore/tcell"
+)
+
+// Key is either a special key of the keyboard or a rune
+type Key struct {
+	Key  tcell.Key
+	Rune rune
+}
+
+func keyRune(r rune) Key {
+	return Key{Key: tcell.KeyRune, Rune: r}
+}
+
+var keyNames = map[tcell.Key]string{
+	tcell.KeyUp:         "Up",
+	tcell.KeyDown:       "Down",
+	tcell.KeyLeft:       "Left",
+	tcell.KeyRight:      "Right",
+	tcell.KeyPgUp:       "Page Up",
+	tcell.KeyPgDn:       "Page Down",
+	tcell.KeyHome:       "Home",
+	tcell.KeyEnd:        "End",
+	tcell.KeyEnter:      "Enter",
+	tcell.KeyEsc:        "Escape",
+	tcell.KeyCtrlR:      "Ctrl-R",
+	tcell.KeyCtrlU:      "Ctrl-U",
+	tcell.KeyBackspace:  "Backspace",
+	tcell.KeyBackspace2: "Backspace",
+}
+
+func (k Key) String() string {
+	if k.Key == tcell.KeyRune {
+		return string(k.Rune)
+	}
+	if name, exists := keyNames[k.Key]; exists {
+		return name
+	}
+	return tcell.KeyNames[k.Key]
+}
+
+// KeyBinding associates keys to an action of the controller
+type KeyBinding struct {
+	Keys        []Key
+	Description string
+	// Whether the action is repeated N times when the key is preceded by the number N
+	Repeatable bool
+	action     func(c *Controller, ctx context.Context) error
+}
+
+// KeysString returns the comma-separated list of the names of the keys of the binding
+func (b KeyBinding) KeysString() string {
+	names := make([]string, 0, len(b.Keys))
+	seen := make(map[string]bool)
+	for _, key := range b.Keys {
+		if name := key.String(); !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// KeyBindings lists the commands available to move around the table of pipelines and act on it.
+// The manual page is generated from this list.
+var KeyBindings = []KeyBinding{
+	{
+		Keys:        []Key{{Key: tcell.KeyUp}, keyRune('k')},
+		Description: "Move cursor up by one line",
+		Repeatable:  true,
+		action:      func(c *Controller, ctx context.Context) error { c.table.Scroll(-1); return nil },
+	},
+	{
+		Keys:        []Key{{Key: tcell.KeyDown}, keyRune('j')},
+		Description: "Move cursor down by one line",
+		Repeatable:  true,
+		action:      func(c *Controller, ctx context.Context) error { c.table.Scroll(+1); return nil },
+	},
+	{
+		Keys:        []Key{{Key: tcell.KeyPgUp}},
+		Description: "Move cursor up by one screen",
+		Repeatable:  true,
+		action:      func(c *Controller, ctx context.Context) error { c.table.Scroll(-c.table.NbrRows()); return nil },
+	},
+	{
+		Keys:        []Key{{Key: tcell.KeyPgDn}},
+		Description: "Move cursor down by one screen",
+		Repeatable:  true,
+		action:      func(c *Controller, ctx context.Context) error { c.table.Scroll(c.table.NbrRows()); return nil },
+	},
+	{
+		Keys:        []Key{{Key: tcell.KeyLeft}, keyRune('h')},
+		Description: "Scroll the table left",
+		Repeatable:  true,
+		action: func(c *Controller, ctx context.Context) error {
+			c.table.ScrollHorizontally(-horizontalScrollStep)
+			return nil
+		},
+	},
+	{
+		Keys:        []Key{{Key: tcell.KeyRight}, keyRune('l')},
+		Description: "Scroll the table right to reveal the end of lines wider than the screen",
+		Repeatable:  true,
+		action: func(c *Controller, ctx context.Context) error {
+			c.table.ScrollHorizontally(horizontalScrollStep)
+			return nil
+		},
+	},
+	{
+		Keys:        []Key{{Key: tcell.KeyHome}},
+		Description: "Move cursor to the first line",
+		action:      func(c *Controller, ctx context.Context) error { c.table.Top(); return nil },
+	},
+	{
+		Keys:        []Key{{Key: tcell.KeyEnd}},
+		Description: "Move cursor to the last line",
+		action:      func(c *Controller, ctx context.Context) error { c.table.Bottom(); return nil },
+	},
+	{
+		Keys:        []Key{keyRune('o')},
+		Description: "Open the fold at the cursor",
+		action:      func(c *Controller, ctx context.Context) error { c.table.SetTraversable(true, false); return nil },
+	},
+	{
+		Keys:        []Key{keyRune('O'), keyRune('+')},
+		Description: "Open the fold at the cursor and all sub-folds",
+		action:      func(c *Controller, ctx context.Context) error { c.table.SetTraversable(true, true); return nil },
+	},
+	{
+		Keys:        []Key{keyRune('c')},
+		Description: "Close the fold at the cursor",
+		action:      func(c *Controller, ctx context.Context) error { c.table.SetTraversable(false, false); return nil },
+	},
+	{
+		Keys:        []Key{keyRune('C'), keyRune('-')},
+		Description: "Close the fold at the cursor and all sub-folds",
+		action:      func(c *Controller, ctx context.Context) error { c.table.SetTraversable(false, true); return nil },
+	},
+	{
+		Keys:        []Key{keyRune('s')},
+		Description: "Toggle the grouping of jobs by stage. When stages are hidden, jobs are listed directly under their pipeline",
+		action:      (*Controller).toggleStages,
+	},
+	{
+		Keys:        []Key{keyRune('f')},
+		Description: "Toggle the flat table listing the jobs of all pipelines along with their provider, pipeline and stage",
+		action:      (*Controller).toggleJobsOnly,
+	},
+	{
+		Keys:        []Key{keyRune('#')},
+		Description: "Cycle through the numbering of rows: hidden, numbered from the top of the table, numbered by their distance to the cursor. Relative numbers are the counts to type before j or k to reach a row",
+		action:      (*Controller).cycleLineNumbers,
+	},
+	{
+		Keys:        []Key{keyRune('w')},
+		Description: "Toggle the wrapping of the name of the row at the cursor. When wrapping is enabled, the part of the name past the right edge of the screen is shown on the following lines instead of being cut",
+		action:      (*Controller).toggleWrap,
+	},
+	{
+		Keys:        []Key{keyRune('>')},
+		Description: "Sort rows by the next column. The header of the sort column ends with an arrow pointing up in ascending order and down in descending order",
+		action:      func(c *Controller, ctx context.Context) error { return c.cycleSortColumn(+1) },
+	},
+	{
+		Keys:        []Key{keyRune('<')},
+		Description: "Sort rows by the previous column",
+		action:      func(c *Controller, ctx context.Context) error { return c.cycleSortColumn(-1) },
+	},
+	{
+		Keys:        []Key{keyRune('!')},
+		Description: "Reverse the sort order",
+		action:      func(c *Controller, ctx context.Context) error { return c.sort(c.sortColumn, !c.reverse) },
+	},
+	{
+		Keys:        []Key{keyRune('m')},
+		Description: "Mark the row at the cursor with the letter typed next",
+		action:      func(c *Controller, ctx context.Context) error { c.waitForMark('m'); return nil },
+	},
+	{
+		Keys:        []Key{keyRune('\'')},
+		Description: "Move cursor to the row marked with the letter typed next, opening the folds hiding it. Typing a single quote instead of a letter moves the cursor back to the row it was on before the last jump",
+		action:      func(c *Controller, ctx context.Context) error { c.waitForMark('\''); return nil },
+	},
+	{
+		Keys:        []Key{keyRune('/')},
+		Description: "Open search prompt. Matches are highlighted in the pager",
+		action:      func(c *Controller, ctx context.Context) error { c.openPrompt(); return nil },
+	},
+	{
+		Keys:        []Key{keyRune('g')},
+		Description: "Open search prompt to look for a regular expression in the logs of all the jobs of the pipeline at the cursor. The matching lines of each job are shown once all logs are fetched",
+		action:      func(c *Controller, ctx context.Context) error { c.openLogSearchPrompt(); return nil },
+	},
+	{
+		Keys:        []Key{{Key: tcell.KeyEnter}, keyRune('n')},
+		Description: "Move to the next match",
+		Repeatable:  true,
+		action:      func(c *Controller, ctx context.Context) error { c.nextMatch(true); return nil },
+	},
+	{
+		Keys:        []Key{keyRune('N')},
+		Description: "Move to the previous match",
+		Repeatable:  true,
+		action:      func(c *Controller, ctx context.Context) error { c.nextMatch(false); return nil },
+	},
+	{
+		Keys:        []Key{keyRune('v')},
+		Description: "View the log of the job at the cursor in the pager, or the logs of all the jobs of the pipeline or stage at the cursor one after the other. The log of a running job is followed until the job finishes. The log of a problem opens at the line reporting the problem",
+		action:      (*Controller).viewLog,
+	},
+	{
+		Keys:        []Key{keyRune('a')},
+		Description: "List the artifacts of the job at the cursor along with their size, or go back to the pipelines. Press v on an artifact to view it if it is a text file of up to 1 MiB, b to open it with the web browser and i to view its details. Artifacts are listed for GitLab and CircleCI, where jobs share the artifacts of their build",
+		action:      (*Controller).toggleArtifacts,
+	},
+	{
+		Keys:        []Key{keyRune('t')},
+		Description: "Cycle through the presentations of the timestamps prefixing the lines of logs: shown as is, hidden, or replaced by the time elapsed since the start of the job",
+		action:      (*Controller).cycleTimestampMode,
+	},
+	{
+		Keys:        []Key{keyRune('T')},
+		Description: "Show or hide the time elapsed since the start of the job in front of each line of logs. Times come from the timestamps of lines and from the markers written by GitLab, Buildkite and Travis CI, lines without a time of their own inherit the time of the previous line",
+		action:      (*Controller).toggleTimingGutter,
+	},
+	{
+		Keys:        []Key{keyRune('e')},
+		Description: "Look for problems in the log of the job at the cursor and list them under the job. Problems are also listed every time the log of a job is viewed",
+		action:      (*Controller).findProblems,
+	},
+	{
+		Keys:        []Key{keyRune('E')},
+		Description: "Fetch the test report of the job at the cursor and list it under the job. The summary of the report counts the tests that passed, failed and were skipped and the tests that failed are listed under the summary along with their message. Test results come from the test reports of GitLab, the test runs of Azure Pipelines and the JUnit XML reports found among the artifacts of jobs",
+		action:      (*Controller).showTestReport,
+	},
+	{
+		Keys:        []Key{keyRune('i')},
+		Description: "View the details of the pipeline, job, deployment, annotation or problem at the cursor. Details of pipelines include their elapsed time and the number of jobs in each state, details of jobs include their variables and the commands they execute if the CI provider exposes them",
+		action:      (*Controller).viewDetails,
+	},
+	{
+		Keys:        []Key{keyRune('I')},
+		Description: "View the full message of the commit along with its trailers, the status of its signature and the files it changes",
+		action:      (*Controller).viewCommit,
+	},
+	{
+		Keys:        []Key{keyRune('d')},
+		Description: "Mark the log of the job at the cursor, or compare the marked log with the log of the job at the cursor",
+		action:      (*Controller).diffLog,
+	},
+	{
+		Keys:        []Key{keyRune('S')},
+		Description: "Save the raw log of the job at the cursor, or of the job whose log is shown in the pager, to the file given by the save_path setting of the configuration file",
+		action:      (*Controller).saveLog,
+	},
+	{
+		Keys:        []Key{keyRune('x')},
+		Description: "Stop the environment of the deployment at the cursor, if the environment still runs this deployment, or cancel the pipeline or job at the cursor if it is still pending or running. Jobs of CircleCI, Azure Pipelines and AppVeyor can only be canceled along with their pipeline. Press x twice to confirm",
+		action:      (*Controller).stopOrCancel,
+	},
+	{
+		Keys:        []Key{keyRune('r')},
+		Description: "Retry the deployment at the cursor, if its job finished, or the job at the cursor if it finished. Jobs can be retried on GitLab, Travis CI and CircleCI. Press r twice to confirm",
+		action:      (*Controller).retry,
+	},
+	{
+		Keys:        []Key{keyRune('p')},
+		Description: "Start the manual job of GitLab, or approve the approval of Azure Pipelines, at the cursor. Press p twice to confirm",
+		action:      (*Controller).startManualJob,
+	},
+	{
+		Keys:        []Key{{Key: tcell.KeyCtrlR}},
+		Description: "Start a new pipeline on the branch or tag of the commit with every provider able to do so. Press Ctrl-R twice to confirm",
+		action:      (*Controller).triggerPipelines,
+	},
+	{
+		Keys:        []Key{keyRune('R')},
+		Description: "Restart the pipeline at the cursor, if it finished, and move the cursor to the new run. Press R twice to confirm",
+		action:      (*Controller).restartPipeline,
+	},
+	{
+		Keys:        []Key{keyRune('b')},
+		Description: "Open with default web browser",
+		action:      func(c *Controller, ctx context.Context) error { return c.openInBrowser() },
+	},
+	{
+		Keys:        []Key{keyRune('P')},
+		Description: "Open the pull request, or merge request, containing the commit with default web browser",
+		action:      func(c *Controller, ctx context.Context) error { return c.openPullRequest() },
+	},
+	{
+		Keys:        []Key{keyRune('u')},
+		Description: "Monitor the new commit at the tip of the remote branch being monitored",
+		action:      func(c *Controller, ctx context.Context) error { c.acceptOffer(); return nil },
+	},
+	{
+		Keys:        []Key{keyRune('q')},
+		Description: "Close the pager, or quit if the pager is closed",
+		action:      (*Controller).quit,
+	},
+	{
+		Keys:        []Key{keyRune('?')},
+		Description: "View manual page",
+		action:      (*Controller).viewManual,
+	},
+}
+
+// PromptKeyBindings lists the commands available while the search prompt is open. Other runes
+// are appended to the search pattern.
+var PromptKeyBindings = []KeyBinding{
+	{
+		Keys:        []Key{{Key: tcell.KeyEsc}},
+		Description: "Close search prompt",
+		action:      func(c *Controller, ctx context.Context) error { c.closePrompt(); return nil },
+	},
+	{
+		Keys:        []Key{{Key: tcell.KeyEnter}},
+		Description: "Close search prompt and move to the next match, or search the logs of the pipeline if the prompt was opened with g",
+		action: func(c *Controller, ctx context.Context) error {
+			if c.searchingLogs {
+				return c.searchLogs(ctx)
+			}
+			c.closePrompt()
+			c.nextMatch(true)
+			return nil
+		},
+	},
+	{
+		Keys:        []Key{{Key: tcell.KeyCtrlU}},
+		Description: "Clear search prompt",
+		action:      func(c *Controller, ctx context.Context) error { c.status.InputBuffer = ""; return nil },
+	},
+	{
+		Keys:        []Key{{Key: tcell.KeyBackspace}, {Key: tcell.KeyBackspace2}},
+		Description: "Delete the last character of the search prompt",
+		action: func(c *Controller, ctx context.Context) error {
+			runes := []rune(c.status.InputBuffer)
+			if len(runes) > 0 {
+				c.status.InputBuffer = string(runes[:len(runes)-1])
+			}
+			return nil
+		},
+	},
+}
+
+// Return the binding associated to 'key' if there is one
+func findKeyBinding(bindings []KeyBinding, key Key) (KeyBinding, bool) {
+	for _, binding := range bindings {
+		for _, k := range binding.Keys {
+			if k == key {
+				return binding, true
+			}
+		}
+	}
+	return KeyBinding{}, false
+}
@@ -0,0 +1,33 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestKeyBindings(t *testing.T) {
+	for _, bindings := range [][]KeyBinding{KeyBindings, PromptKeyBindings} {
+		seen := make(map[Key]bool)
+		for _, binding := range bindings {
+			if len(binding.Keys) == 0 || binding.Description == "" || binding.action == nil {
+				t.Fatalf("incomplete key binding: %+v", binding)
+			}
+			for _, key := range binding.Keys {
+				if seen[key] {
+					t.Fatalf("key %q is bound to more than one action", key)
+				}
+				seen[key] = true
+			}
+		}
+	}
+}
+
+func TestKeyBinding_KeysString(t *testing.T) {
+	binding := KeyBinding{
+		Keys: []Key{{Key: tcell.KeyBackspace}, {Key: tcell.KeyBackspace2}, keyRune('x')},
+	}
+	if s, expected := binding.KeysString(), "Backspace, x"; s != expected {
+		t.Fatalf("expected %q but got %q", expected, s)
+	}
+}
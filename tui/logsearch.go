@@ -0,0 +1,231 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// Maximum number of logs fetched at the same time by a search
+const maxConcurrentLogFetches = 8
+
+// LogSearch is the outcome of a search in the logs of the jobs of a pipeline
+type LogSearch struct {
+	Pattern string
+	// Number of jobs of the pipeline
+	Jobs int
+	// Jobs with at least one line matching the pattern or whose log could not be fetched, in
+	// the order of the table
+	Results []LogSearchResult
+}
+
+// LogSearchResult lists the lines of the log of a job matching the pattern of a search
+type LogSearchResult struct {
+	// Name of the stage of the job, empty if the job does not belong to a stage
+	Stage string
+	Job   string
+	// Lines of the log matching the pattern
+	Matches []LogMatch
+	// Error preventing the log from being searched, nil if the log was searched
+	Err error
+}
+
+// LogMatch is a line of a log matching the pattern of a search
+type LogMatch struct {
+	// Position of the line in the log, starting at 1
+	Line int
+	Text string
+}
+
+// A job of a pipeline along with the identifier of its stage
+type stageJob struct {
+	stageID   int
+	stageName string
+	job       cache.Job
+}
+
+// Return the jobs of a pipeline in the order of the table, only keeping the latest run of each
+// job of a stage
+func stageJobs(b cache.Build) []stageJob {
+	jobs := make([]stageJob, 0)
+	for _, job := range b.Jobs {
+		jobs = append(jobs, stageJob{job: *job})
+	}
+	stageIDs := make([]int, 0, len(b.Stages))
+	for id := range b.Stages {
+		stageIDs = append(stageIDs, id)
+	}
+	sort.Ints(stageIDs)
+	for _, id := range stageIDs {
+		stage := b.Stages[id]
+		for _, job := range latestJobs(stage.Jobs) {
+			jobs = append(jobs, stageJob{stageID: id, stageName: stage.Name, job: *job})
+		}
+	}
+	return jobs
+}
+
+// SearchLogs fetches the logs of all the jobs of the pipeline of the row designated by 'key' and
+// looks for the lines matching 'pattern'. Logs are fetched concurrently and the logs of finished
+// jobs are kept in cache for subsequent searches.
+func (s BuildsByCommit) SearchLogs(ctx context.Context, key interface{}, pattern *regexp.Regexp) (LogSearch, error) {
+	buildKey, ok := key.(buildRowKey)
+	if !ok {
+		return LogSearch{}, fmt.Errorf("key conversion to buildRowKey failed: '%v'", key)
+	}
+	build, exists := s.cache.Build(buildKey.accountID, buildKey.buildID)
+	if !exists {
+		return LogSearch{}, cache.ErrNoLogHere
+	}
+
+	jobs := stageJobs(build)
+	results := make([]LogSearchResult, len(jobs))
+	semaphore := make(chan struct{}, maxConcurrentLogFetches)
+	wg := sync.WaitGroup{}
+	for i, j := range jobs {
+		results[i].Stage = j.stageName
+		results[i].Job = j.job.Name
+		if results[i].Job == "" {
+			results[i].Job = j.job.ID
+		}
+
+		wg.Add(1)
+		go func(i int, j stageJob) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+			log, err := s.cache.Log(ctx, buildKey.accountID, buildKey.buildID, j.stageID, j.job.ID)
+			if err != nil {
+				results[i].Err = err
+				return
+			}
+			log = formatTimestamps(utils.PostProcess(log), s.timestamps, j.job.StartedAt)
+			for n, line := range strings.Split(log, "\n") {
+				line = colorSequence.ReplaceAllString(strings.TrimRight(line, "\r"), "")
+				if pattern.MatchString(line) {
+					results[i].Matches = append(results[i].Matches, LogMatch{Line: n + 1, Text: line})
+				}
+			}
+		}(i, j)
+	}
+	wg.Wait()
+
+	search := LogSearch{
+		Pattern: pattern.String(),
+		Jobs:    len(jobs),
+		Results: make([]LogSearchResult, 0),
+	}
+	for _, result := range results {
+		// Jobs without log, such as jobs that have not started yet, are left out
+		if (result.Err != nil && result.Err != cache.ErrNoLogHere) || len(result.Matches) > 0 {
+			search.Results = append(search.Results, result)
+		}
+	}
+	return search, nil
+}
+
+// MatchingJobs returns the number of jobs whose log has at least one line matching the pattern
+func (l LogSearch) MatchingJobs() int {
+	n := 0
+	for _, result := range l.Results {
+		if len(result.Matches) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// String lists the matching lines of each job
+func (l LogSearch) String() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "Pattern %q found in the logs of %d out of %d jobs\n", l.Pattern, l.MatchingJobs(), l.Jobs)
+	for _, result := range l.Results {
+		if result.Stage != "" {
+			fmt.Fprintf(&b, "\n%s: %s\n", result.Stage, result.Job)
+		} else {
+			fmt.Fprintf(&b, "\n%s\n", result.Job)
+		}
+		if result.Err != nil {
+			fmt.Fprintf(&b, "    log unavailable: %v\n", result.Err)
+			continue
+		}
+		for _, match := range result.Matches {
+			fmt.Fprintf(&b, "%8d: %s\n", match.Line, match.Text)
+		}
+	}
+
+	return b.String()
+}
+
+// RunGrep looks for 'pattern' in the logs of all the jobs of the pipelines of a commit. Matching
+// lines are written to 'w' prefixed with the provider, the pipeline and the job they come from,
+// followed by the line number, in the fashion of grep. Logs that cannot be fetched are reported
+// on 'errW'. The number of matching lines is returned.
+func RunGrep(ctx context.Context, w io.Writer, errW io.Writer, repo string, sha string, pattern *regexp.Regexp, CIProviders []cache.CIProvider, SourceProviders []cache.SourceProvider) (int, error) {
+	if len(CIProviders) == 0 || len(SourceProviders) == 0 {
+		return 0, ErrNoProvider
+	}
+
+	repositoryURL, commit, err := resolveCommit(ctx, repo, sha, SourceProviders)
+	if err != nil {
+		return 0, err
+	}
+	c := cache.NewCache(CIProviders, SourceProviders)
+	builds, err := c.Pipelines(ctx, repositoryURL, commit.Sha)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		pi, pj := builds[i].Repository.Provider, builds[j].Repository.Provider
+		if pi.Name != pj.Name {
+			return pi.Name < pj.Name
+		}
+		return builds[i].CreatedAt.Time.Before(builds[j].CreatedAt.Time)
+	})
+
+	source := NewBuildsByCommit(&c)
+	matches := 0
+	for _, build := range builds {
+		key := buildRowKey{
+			accountID: build.Repository.Provider.ID,
+			buildID:   build.ID,
+		}
+		search, err := source.SearchLogs(ctx, key, pattern)
+		if err != nil {
+			return matches, err
+		}
+		for _, result := range search.Results {
+			location := fmt.Sprintf("%s/%s/", build.Repository.Provider.Name, build.ID)
+			if result.Stage != "" {
+				location += result.Stage + "/"
+			}
+			location += result.Job
+			if result.Err != nil {
+				if _, err := fmt.Fprintf(errW, "%s: %v\n", location, result.Err); err != nil {
+					return matches, err
+				}
+				continue
+			}
+			for _, match := range result.Matches {
+				if _, err := fmt.Fprintf(w, "%s:%d:%s\n", location, match.Line, match.Text); err != nil {
+					return matches, err
+				}
+				matches++
+			}
+		}
+	}
+
+	return matches, nil
+}
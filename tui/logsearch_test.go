@@ -0,0 +1,133 @@
+package tui
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func TestBuildsByCommit_SearchLogs(t *testing.T) {
+	b := build
+	b.Jobs = []*cache.Job{
+		{
+			ID:   "1",
+			Name: "lint",
+			Log:  utils.NullString{String: "golint ./...\nok\n", Valid: true},
+		},
+		{
+			ID:   "2",
+			Name: "test",
+			Log:  utils.NullString{String: "go test ./...\nconnection refused\nretrying\n\x1b[31mconnection refused\x1b[0m\n", Valid: true},
+		},
+	}
+	b.Stages = map[int]*cache.Stage{
+		1: {
+			ID:   1,
+			Name: "deploy",
+			Jobs: []*cache.Job{
+				// The log of this job cannot be fetched since the cache knows no provider
+				{ID: "3", Name: "staging"},
+			},
+		},
+	}
+	c := cache.NewCache(nil, nil)
+	if err := c.Save(b); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+
+	key := buildRowKey{
+		ref:       b.Ref,
+		sha:       b.Commit.Sha,
+		accountID: b.Repository.Provider.ID,
+		buildID:   b.ID,
+	}
+	search, err := source.SearchLogs(context.Background(), key, regexp.MustCompile("refused$"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if search.Jobs != 3 || search.MatchingJobs() != 1 || len(search.Results) != 2 {
+		t.Fatalf("unexpected search %+v", search)
+	}
+	if search.Results[1].Job != "staging" || search.Results[1].Err == nil {
+		t.Fatalf("expected an error for the log of the last job but got %+v", search.Results[1])
+	}
+	search.Results[1].Err = nil
+
+	expected := []LogSearchResult{
+		{
+			Job: "test",
+			Matches: []LogMatch{
+				{Line: 2, Text: "connection refused"},
+				{Line: 4, Text: "connection refused"},
+			},
+		},
+		{
+			Stage: "deploy",
+			Job:   "staging",
+		},
+	}
+	if diff := cmp.Diff(expected, search.Results); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestController_searchLogs(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, DefaultStyleSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+	c := cache.NewCache([]cache.CIProvider{mockProvider{id: "id"}}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := NewBuildsByCommit(&c)
+	controller, err := NewController(&tui, &source, time.UTC, dir, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.resize(80, 20)
+	controller.refresh()
+	ctx := context.Background()
+
+	if !controller.table.Jump(buildAsRow.key) {
+		t.Fatal("pipeline row not found")
+	}
+	controller.openLogSearchPrompt()
+	controller.status.InputBuffer = "^log$"
+	if err := controller.searchLogs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The logs are searched in the background and the pager opens once the outcome is applied
+	if controller.pager != nil {
+		t.Fatal("the pager must not open before the search is over")
+	}
+	if err := applyOutcome(t, &controller); err != nil {
+		t.Fatal(err)
+	}
+	if controller.pager == nil {
+		t.Fatal("expected the matching lines to be shown in the pager")
+	}
+	if headers := controller.table.Headers(); len(headers) != 1 || headers[0] != "MATCHES" {
+		t.Fatalf("unexpected headers %v", headers)
+	}
+}
@@ -0,0 +1,106 @@
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang"
+	"github.com/nbedos/citop/cache"
+)
+
+// Maximum number of state changes waiting to be published. Changes are dropped once the queue
+// is full so that a slow broker never delays the monitoring of pipelines.
+const publicationQueueSize = 64
+
+// StatePublisher publishes the state changes of pipelines
+type StatePublisher interface {
+	Publish(ctx context.Context, change StateChange) error
+}
+
+var errMQTTTimeout = errors.New("timeout expired before the MQTT broker acknowledged the request")
+
+// MQTTPublisher publishes state changes encoded in JSON to a topic of an MQTT broker. The
+// connection to the broker is established on the first publication.
+type MQTTPublisher struct {
+	client mqtt.Client
+	topic  string
+}
+
+func NewMQTTPublisher(broker string, topic string, clientID string, username string, password string) MQTTPublisher {
+	options := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(clientID).
+		SetUsername(username).
+		SetPassword(password).
+		SetConnectTimeout(notificationTimeout).
+		SetAutoReconnect(true)
+
+	return MQTTPublisher{
+		client: mqtt.NewClient(options),
+		topic:  topic,
+	}
+}
+
+// Wait for the completion of the request associated to 'token' until the deadline of 'ctx'
+func waitToken(ctx context.Context, token mqtt.Token) error {
+	timeout := notificationTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if !token.WaitTimeout(timeout) {
+		return errMQTTTimeout
+	}
+	return token.Error()
+}
+
+func (p MQTTPublisher) Publish(ctx context.Context, change StateChange) error {
+	if !p.client.IsConnected() {
+		if err := waitToken(ctx, p.client.Connect()); err != nil {
+			return err
+		}
+	}
+
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	// QoS 1: the message is delivered at least once
+	return waitToken(ctx, p.client.Publish(p.topic, 1, false, payload))
+}
+
+// publish passes the state changes of the pipelines of 'events' to the publishers, in order.
+// Errors are ignored since a state change that cannot be published must not interrupt
+// monitoring. publish returns once 'events' is closed and all queued changes have been
+// published.
+func publish(ctx context.Context, events <-chan cache.Event, publishers []StatePublisher) {
+	changes := make(chan StateChange, publicationQueueSize)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for change := range changes {
+			for _, p := range publishers {
+				ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+				p.Publish(ctx, change)
+				cancel()
+			}
+		}
+	}()
+
+	tracker := newStateTracker()
+	for event := range events {
+		for _, change := range tracker.Changes(event.Build, event.Time) {
+			if change.Type != "pipeline" {
+				continue
+			}
+			select {
+			case changes <- change:
+			default:
+				// The queue is full, drop the change
+			}
+		}
+	}
+	close(changes)
+	<-done
+}
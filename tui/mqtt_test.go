@@ -0,0 +1,165 @@
+package tui
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+type recordingPublisher struct {
+	mutex   *sync.Mutex
+	changes []StateChange
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, change StateChange) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.changes = append(p.changes, change)
+	return nil
+}
+
+func TestPublish(t *testing.T) {
+	build := cache.Build{
+		Repository: &cache.Repository{
+			Provider: cache.Provider{ID: "gitlab-0", Name: "gitlab"},
+		},
+		ID:    "42",
+		State: cache.Running,
+		Jobs: []*cache.Job{
+			{ID: "1", State: cache.Running},
+		},
+	}
+
+	events := make(chan cache.Event, 2)
+	events <- cache.Event{Build: build}
+	build.State = cache.Passed
+	build.Jobs = []*cache.Job{{ID: "1", State: cache.Passed}}
+	events <- cache.Event{Build: build}
+	close(events)
+
+	p := &recordingPublisher{mutex: &sync.Mutex{}}
+	publish(context.Background(), events, []StatePublisher{p})
+
+	states := make([][2]cache.State, 0)
+	for _, change := range p.changes {
+		if change.Type != "pipeline" {
+			t.Fatalf("unexpected change of type %q", change.Type)
+		}
+		states = append(states, [2]cache.State{change.PreviousState, change.State})
+	}
+	expected := [][2]cache.State{
+		{cache.Unknown, cache.Running},
+		{cache.Running, cache.Passed},
+	}
+	if diff := cmp.Diff(expected, states); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+type mqttMessage struct {
+	topic   string
+	payload []byte
+}
+
+// Read an MQTT control packet and return its fixed header and its variable part
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&127) * multiplier
+		multiplier *= 128
+		if b&128 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+// Run a minimal MQTT broker accepting a single client and send the messages it publishes to
+// 'messages'
+func serveMQTT(l net.Listener, messages chan<- mqttMessage) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case 1: // CONNECT
+			conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+		case 3: // PUBLISH with QoS 1
+			n := int(body[0])<<8 | int(body[1])
+			topic := string(body[2 : 2+n])
+			packetID := body[2+n : 4+n]
+			messages <- mqttMessage{topic: topic, payload: body[4+n:]}
+			conn.Write([]byte{0x40, 0x02, packetID[0], packetID[1]})
+		case 12: // PINGREQ
+			conn.Write([]byte{0xd0, 0x00})
+		case 14: // DISCONNECT
+			return
+		}
+	}
+}
+
+func TestMQTTPublisher_Publish(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	messages := make(chan mqttMessage, 1)
+	go serveMQTT(l, messages)
+
+	p := NewMQTTPublisher("tcp://"+l.Addr().String(), "ci/citop", "citop-test", "", "")
+	change := StateChange{
+		Time:          time.Date(2019, 11, 13, 13, 12, 0, 0, time.UTC),
+		Type:          "pipeline",
+		Provider:      "gitlab",
+		Pipeline:      "42",
+		Name:          "gitlab",
+		State:         cache.Failed,
+		PreviousState: cache.Running,
+		Ref:           "master",
+		Sha:           "c2bb562",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Publish(ctx, change); err != nil {
+		t.Fatal(err)
+	}
+
+	message := <-messages
+	if message.topic != "ci/citop" {
+		t.Fatalf("expected topic %q but got %q", "ci/citop", message.topic)
+	}
+	var published StateChange
+	if err := json.Unmarshal(message.payload, &published); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(change, published); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
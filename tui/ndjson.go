@@ -0,0 +1,195 @@
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+)
+
+// StateChange is the JSON object written by RunNDJSON for a pipeline, a stage or a job that
+// appeared or changed state
+type StateChange struct {
+	Time time.Time `json:"time"`
+	// "pipeline", "stage" or "job"
+	Type     string `json:"type"`
+	Provider string `json:"provider"`
+	Pipeline string `json:"pipeline"`
+	// Name of the stage, for stages and for jobs belonging to a stage
+	Stage string `json:"stage,omitempty"`
+	// Identifier of the job
+	Job           string      `json:"job,omitempty"`
+	Name          string      `json:"name"`
+	State         cache.State `json:"state"`
+	PreviousState cache.State `json:"previous_state,omitempty"`
+	Ref           string      `json:"ref"`
+	Tag           bool        `json:"tag"`
+	Sha           string      `json:"sha"`
+	URL           string      `json:"url,omitempty"`
+}
+
+type stateChangeKey struct {
+	accountID string
+	buildID   string
+	stageID   int
+	jobID     string
+}
+
+// stateTracker turns builds into state changes by remembering the last state of every pipeline,
+// stage and job
+type stateTracker struct {
+	states map[stateChangeKey]cache.State
+}
+
+func newStateTracker() stateTracker {
+	return stateTracker{
+		states: make(map[stateChangeKey]cache.State),
+	}
+}
+
+func (t *stateTracker) track(changes []StateChange, key stateChangeKey, change StateChange) []StateChange {
+	previous, exists := t.states[key]
+	if exists && previous == change.State {
+		return changes
+	}
+	t.states[key] = change.State
+	change.PreviousState = previous
+	return append(changes, change)
+}
+
+// Return the state changes of the pipeline, its stages and its jobs since the last call
+func (t *stateTracker) Changes(build cache.Build, at time.Time) []StateChange {
+	changes := make([]StateChange, 0)
+	base := StateChange{
+		Time:     at,
+		Provider: build.Repository.Provider.Name,
+		Pipeline: build.ID,
+		Ref:      build.Ref,
+		Tag:      build.IsTag,
+		Sha:      build.Commit.Sha,
+	}
+	key := stateChangeKey{
+		accountID: build.Repository.Provider.ID,
+		buildID:   build.ID,
+	}
+
+	pipeline := base
+	pipeline.Type = "pipeline"
+	pipeline.Name = build.Repository.Provider.Name
+	pipeline.State = build.State
+	pipeline.URL = build.WebURL
+	changes = t.track(changes, key, pipeline)
+
+	jobChange := func(stageKey stateChangeKey, stageName string, job cache.Job) {
+		change := base
+		change.Type = "job"
+		change.Stage = stageName
+		change.Job = job.ID
+		change.Name = job.Name
+		if change.Name == "" {
+			change.Name = job.ID
+		}
+		change.State = job.State
+		change.URL = job.WebURL
+		jobKey := stageKey
+		jobKey.jobID = job.ID
+		changes = t.track(changes, jobKey, change)
+	}
+
+	for _, job := range build.Jobs {
+		jobChange(key, "", *job)
+	}
+
+	stageIDs := make([]int, 0, len(build.Stages))
+	for stageID := range build.Stages {
+		stageIDs = append(stageIDs, stageID)
+	}
+	sort.Ints(stageIDs)
+	for _, stageID := range stageIDs {
+		stage := build.Stages[stageID]
+		change := base
+		change.Type = "stage"
+		change.Stage = stage.Name
+		change.Name = stage.Name
+		change.State = stage.State
+		stageKey := key
+		stageKey.stageID = stageID
+		changes = t.track(changes, stageKey, change)
+
+		for _, job := range stage.Jobs {
+			jobChange(stageKey, stage.Name, *job)
+		}
+	}
+
+	return changes
+}
+
+// RunNDJSON writes to 'w' a JSON object per line for each pipeline, stage and job associated
+// to the commit. If 'follow' is true, pipelines are monitored and a new object is written every
+// time one of them changes state until monitoring stops or 'ctx' is canceled. A PolicyError is
+// returned if the final state of the pipelines violates 'policy' and ErrTimeout if pipelines are
+// still running when the deadline of 'ctx' expires.
+func RunNDJSON(ctx context.Context, w io.Writer, repo string, sha string, CIProviders []cache.CIProvider, SourceProviders []cache.SourceProvider, filter Filter, follow bool, policy FailurePolicy) error {
+	if len(CIProviders) == 0 || len(SourceProviders) == 0 {
+		return ErrNoProvider
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	repositoryURL, commit, err := resolveCommit(ctx, repo, sha, SourceProviders)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	tracker := newStateTracker()
+	write := func(build cache.Build, at time.Time) error {
+		for _, change := range tracker.Changes(build, at) {
+			if err := encoder.Encode(change); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !follow {
+		c := cache.NewCache(CIProviders, SourceProviders)
+		filter.apply(&c)
+		builds, err := c.Pipelines(ctx, repositoryURL, commit.Sha)
+		if err != nil {
+			return policy.outcome(ctx, err, builds)
+		}
+		sort.Slice(builds, func(i, j int) bool {
+			bi, bj := builds[i], builds[j]
+			if bi.Repository.Provider.ID != bj.Repository.Provider.ID {
+				return bi.Repository.Provider.ID < bj.Repository.Provider.ID
+			}
+			return bi.ID < bj.ID
+		})
+		now := time.Now()
+		for _, build := range builds {
+			if err := write(build, now); err != nil {
+				return err
+			}
+		}
+		return policy.outcome(ctx, nil, builds)
+	}
+
+	engine := cache.NewEngine(CIProviders, SourceProviders)
+	filter.apply(engine.Cache())
+	events := engine.Subscribe()
+	if err := engine.Start(ctx, repositoryURL, commit.Sha); err != nil {
+		return err
+	}
+	for event := range events {
+		if err := write(event.Build, event.Time); err != nil {
+			return err
+		}
+	}
+
+	return policy.outcome(ctx, engine.Wait(), engine.Cache().Builds())
+}
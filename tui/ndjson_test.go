@@ -0,0 +1,76 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+func TestStateTracker_Changes(t *testing.T) {
+	at := time.Date(2019, 11, 13, 13, 12, 0, 0, time.UTC)
+	newBuild := func(pipeline cache.State, stage cache.State, job cache.State) cache.Build {
+		return cache.Build{
+			Repository: &cache.Repository{
+				Provider: cache.Provider{
+					ID:   "gitlab-0",
+					Name: "gitlab",
+				},
+			},
+			ID:     "42",
+			Commit: cache.Commit{Sha: "c2bb562"},
+			Ref:    "master",
+			State:  pipeline,
+			WebURL: "https://example.com/42",
+			Stages: map[int]*cache.Stage{
+				1: {
+					ID:    1,
+					Name:  "tests",
+					State: stage,
+					Jobs: []*cache.Job{
+						{
+							ID:    "7",
+							Name:  "go1.13",
+							State: job,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	tracker := newStateTracker()
+	changes := tracker.Changes(newBuild(cache.Running, cache.Running, cache.Running), at)
+	base := StateChange{
+		Time:     at,
+		Provider: "gitlab",
+		Pipeline: "42",
+		Ref:      "master",
+		Sha:      "c2bb562",
+	}
+	pipeline, stage, job := base, base, base
+	pipeline.Type, pipeline.Name, pipeline.State, pipeline.URL = "pipeline", "gitlab", cache.Running, "https://example.com/42"
+	stage.Type, stage.Stage, stage.Name, stage.State = "stage", "tests", "tests", cache.Running
+	job.Type, job.Stage, job.Job, job.Name, job.State = "job", "tests", "7", "go1.13", cache.Running
+	if diff := cmp.Diff([]StateChange{pipeline, stage, job}, changes); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("unchanged states must not be reported", func(t *testing.T) {
+		changes := tracker.Changes(newBuild(cache.Running, cache.Running, cache.Running), at)
+		if len(changes) != 0 {
+			t.Fatalf("expected no change but got %d", len(changes))
+		}
+	})
+
+	t.Run("state changes must include the previous state", func(t *testing.T) {
+		changes := tracker.Changes(newBuild(cache.Running, cache.Running, cache.Passed), at)
+		job := job
+		job.State = cache.Passed
+		job.PreviousState = cache.Running
+		if diff := cmp.Diff([]StateChange{job}, changes); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
@@ -0,0 +1,282 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+)
+
+// Maximum duration of the delivery of a notification
+const notificationTimeout = 10 * time.Second
+
+// Notifier posts messages to a chat service or a mailbox
+type Notifier interface {
+	Post(ctx context.Context, message string) error
+}
+
+// Notification associates a notifier to the final states of pipelines it must be told about
+type Notification struct {
+	Notifier Notifier
+	States   map[cache.State]bool
+}
+
+// Send 'payload' encoded in JSON to 'u' and check that the request succeeded
+func sendJSON(ctx context.Context, client *http.Client, method string, u string, header http.Header, payload interface{}) error {
+	bs, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned status %q", method, u, resp.Status)
+	}
+	return nil
+}
+
+// SlackNotifier posts messages to an incoming webhook of Slack
+type SlackNotifier struct {
+	client     *http.Client
+	webhookURL string
+}
+
+func NewSlackNotifier(client *http.Client, webhookURL string) SlackNotifier {
+	return SlackNotifier{
+		client:     client,
+		webhookURL: webhookURL,
+	}
+}
+
+func (n SlackNotifier) Post(ctx context.Context, message string) error {
+	payload := struct {
+		Text string `json:"text"`
+	}{
+		Text: message,
+	}
+	return sendJSON(ctx, n.client, "POST", n.webhookURL, nil, payload)
+}
+
+// DiscordNotifier posts messages to a webhook of a Discord channel
+type DiscordNotifier struct {
+	client     *http.Client
+	webhookURL string
+}
+
+func NewDiscordNotifier(client *http.Client, webhookURL string) DiscordNotifier {
+	return DiscordNotifier{
+		client:     client,
+		webhookURL: webhookURL,
+	}
+}
+
+func (n DiscordNotifier) Post(ctx context.Context, message string) error {
+	payload := struct {
+		Content string `json:"content"`
+	}{
+		Content: message,
+	}
+	return sendJSON(ctx, n.client, "POST", n.webhookURL, nil, payload)
+}
+
+// MatrixNotifier sends messages to a Matrix room using the client-server API of the homeserver.
+// The account owning the access token must have joined the room.
+type MatrixNotifier struct {
+	client        *http.Client
+	homeserverURL string
+	room          string
+	token         string
+}
+
+func NewMatrixNotifier(client *http.Client, homeserverURL string, room string, token string) MatrixNotifier {
+	return MatrixNotifier{
+		client:        client,
+		homeserverURL: strings.TrimSuffix(homeserverURL, "/"),
+		room:          room,
+		token:         token,
+	}
+}
+
+func (n MatrixNotifier) Post(ctx context.Context, message string) error {
+	// Matrix expects a transaction identifier unique to each message sent with the token
+	txnID := fmt.Sprintf("citop-%d", time.Now().UnixNano())
+	u := fmt.Sprintf("%s/_matrix/client/r0/rooms/%s/send/m.room.message/%s", n.homeserverURL,
+		url.PathEscape(n.room), txnID)
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+n.token)
+	payload := struct {
+		MsgType string `json:"msgtype"`
+		Body    string `json:"body"`
+	}{
+		MsgType: "m.text",
+		Body:    message,
+	}
+	return sendJSON(ctx, n.client, "PUT", u, header, payload)
+}
+
+// notificationMessage returns the description of the pipeline that changed state, such as
+// "gitlab pipeline #42 (branch master, commit c2bb562): failed https://example.com/42"
+func notificationMessage(change StateChange) string {
+	pipeline := change.Pipeline
+	if _, err := strconv.Atoi(pipeline); err == nil {
+		pipeline = "#" + pipeline
+	}
+	ref := "branch"
+	if change.Tag {
+		ref = "tag"
+	}
+	s := fmt.Sprintf("%s pipeline %s (%s %s, commit %s): %s", change.Provider, pipeline, ref,
+		change.Ref, shortSha(change.Sha), change.State)
+	if change.URL != "" {
+		s += " " + change.URL
+	}
+	return s
+}
+
+// notify posts a message every time a pipeline of 'events' that was seen running or pending
+// reaches one of the states of a notification. Pipelines already complete when monitoring
+// starts are not reported. Errors are ignored since a notification that cannot be delivered
+// must not interrupt monitoring. notify returns once 'events' is closed and all messages have
+// been posted.
+func notify(ctx context.Context, events <-chan cache.Event, notifications []Notification) {
+	wg := sync.WaitGroup{}
+	tracker := newStateTracker()
+	for event := range events {
+		for _, change := range tracker.Changes(event.Build, event.Time) {
+			if change.Type != "pipeline" || !change.PreviousState.IsActive() {
+				continue
+			}
+			message := notificationMessage(change)
+			for _, n := range notifications {
+				if !n.States[change.State] {
+					continue
+				}
+				wg.Add(1)
+				go func(notifier Notifier) {
+					defer wg.Done()
+					ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+					defer cancel()
+					notifier.Post(ctx, message)
+				}(n.Notifier)
+			}
+		}
+	}
+	wg.Wait()
+}
+
+// EmailNotifier sends messages by email through an SMTP server. The connection is upgraded
+// with STARTTLS whenever the server supports it and credentials are only sent over encrypted
+// connections, or to a server running on the local machine.
+type EmailNotifier struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func NewEmailNotifier(host string, port int, username string, password string, from string, to []string) EmailNotifier {
+	return EmailNotifier{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+		to:       to,
+	}
+}
+
+// Return an email whose subject and body are both 'message'
+func emailMessage(from string, to []string, message string, date time.Time) []byte {
+	b := bytes.Buffer{}
+	headers := [][2]string{
+		{"From", from},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", "[citop] "+message)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&b, "%s: %s\r\n", header[0], header[1])
+	}
+	fmt.Fprintf(&b, "\r\n%s\r\n", message)
+	return b.Bytes()
+}
+
+func (n EmailNotifier) Post(ctx context.Context, message string) error {
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(n.host, strconv.Itoa(n.port)))
+	if err != nil {
+		return err
+	}
+	// net/smtp ignores contexts so the deadline is enforced by the connection itself
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return err
+		}
+	}
+	if n.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.username, n.password, n.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(emailMessage(n.from, n.to, message, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,261 @@
+package tui
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+func TestNotifiers(t *testing.T) {
+	type request struct {
+		method        string
+		path          string
+		authorization string
+		payload       map[string]string
+	}
+
+	requests := make(chan request, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := make(map[string]string)
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests <- request{
+			method:        r.Method,
+			path:          r.URL.EscapedPath(),
+			authorization: r.Header.Get("Authorization"),
+			payload:       payload,
+		}
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name     string
+		notifier Notifier
+		expected request
+	}{
+		{
+			name:     "slack",
+			notifier: NewSlackNotifier(ts.Client(), ts.URL+"/services/T0/B0/X"),
+			expected: request{
+				method:  "POST",
+				path:    "/services/T0/B0/X",
+				payload: map[string]string{"text": "message"},
+			},
+		},
+		{
+			name:     "discord",
+			notifier: NewDiscordNotifier(ts.Client(), ts.URL+"/api/webhooks/0/X"),
+			expected: request{
+				method:  "POST",
+				path:    "/api/webhooks/0/X",
+				payload: map[string]string{"content": "message"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.notifier.Post(context.Background(), "message"); err != nil {
+				t.Fatal(err)
+			}
+			r := <-requests
+			if diff := cmp.Diff(testCase.expected, r, cmp.AllowUnexported(request{})); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("matrix", func(t *testing.T) {
+		n := NewMatrixNotifier(ts.Client(), ts.URL+"/", "!room:matrix.org", "token")
+		if err := n.Post(context.Background(), "message"); err != nil {
+			t.Fatal(err)
+		}
+		r := <-requests
+		prefix := "/_matrix/client/r0/rooms/%21room:matrix.org/send/m.room.message/citop-"
+		if r.method != "PUT" || len(r.path) <= len(prefix) || r.path[:len(prefix)] != prefix {
+			t.Fatalf("unexpected request %s %s", r.method, r.path)
+		}
+		if r.authorization != "Bearer token" {
+			t.Fatalf("unexpected authorization header %q", r.authorization)
+		}
+		expected := map[string]string{"msgtype": "m.text", "body": "message"}
+		if diff := cmp.Diff(expected, r.payload); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer ts.Close()
+		if err := NewSlackNotifier(ts.Client(), ts.URL).Post(context.Background(), "message"); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
+
+type recordingNotifier struct {
+	mutex    *sync.Mutex
+	messages []string
+}
+
+func (n *recordingNotifier) Post(ctx context.Context, message string) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+func TestNotify(t *testing.T) {
+	build := func(id string, state cache.State) cache.Build {
+		return cache.Build{
+			Repository: &cache.Repository{
+				Provider: cache.Provider{ID: "gitlab-0", Name: "gitlab"},
+			},
+			ID:     id,
+			Ref:    "master",
+			Commit: cache.Commit{Sha: "c2bb562e3ac6e1f8d5b7b1c4e2b6f6d1f0f2b3a4"},
+			State:  state,
+			WebURL: "https://example.com/" + id,
+		}
+	}
+
+	events := make(chan cache.Event, 16)
+	for _, b := range []cache.Build{
+		// Already complete when monitoring starts
+		build("1", cache.Failed),
+		build("2", cache.Running),
+		build("3", cache.Pending),
+		build("2", cache.Failed),
+		build("3", cache.Running),
+		build("3", cache.Passed),
+	} {
+		events <- cache.Event{Time: time.Now(), Build: b}
+	}
+	close(events)
+
+	failures := &recordingNotifier{mutex: &sync.Mutex{}}
+	all := &recordingNotifier{mutex: &sync.Mutex{}}
+	notify(context.Background(), events, []Notification{
+		{Notifier: failures, States: map[cache.State]bool{cache.Failed: true}},
+		{Notifier: all, States: map[cache.State]bool{cache.Failed: true, cache.Passed: true}},
+	})
+
+	expected := []string{
+		"gitlab pipeline #2 (branch master, commit c2bb562): failed https://example.com/2",
+	}
+	if diff := cmp.Diff(expected, failures.messages); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	expected = append(expected, "gitlab pipeline #3 (branch master, commit c2bb562): passed https://example.com/3")
+	sort.Strings(all.messages)
+	if diff := cmp.Diff(expected, all.messages); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	date := time.Date(2019, 11, 13, 13, 12, 0, 0, time.UTC)
+	message := emailMessage("citop@example.com", []string{"alice@example.com", "bob@example.com"}, "gitlab pipeline #2: failed", date)
+	expected := "From: citop@example.com\r\n" +
+		"To: alice@example.com, bob@example.com\r\n" +
+		"Subject: [citop] gitlab pipeline #2: failed\r\n" +
+		"Date: Wed, 13 Nov 2019 13:12:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"gitlab pipeline #2: failed\r\n"
+	if diff := cmp.Diff(expected, string(message)); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+// Run a minimal SMTP server accepting a single message and send the commands it received to
+// 'commands'
+func serveSMTP(t *testing.T, l net.Listener, commands chan<- []string) {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Error(err)
+		close(commands)
+		return
+	}
+	defer conn.Close()
+
+	received := make([]string, 0)
+	defer func() { commands <- received }()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+	data := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if data {
+			if line == "." {
+				data = false
+				fmt.Fprint(conn, "250 OK\r\n")
+			}
+			continue
+		}
+		received = append(received, line)
+		switch {
+		case strings.HasPrefix(line, "EHLO"):
+			fmt.Fprint(conn, "250 localhost\r\n")
+		case line == "DATA":
+			data = true
+			fmt.Fprint(conn, "354 Go ahead\r\n")
+		case line == "QUIT":
+			fmt.Fprint(conn, "221 Bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "250 OK\r\n")
+		}
+	}
+}
+
+func TestEmailNotifier_Post(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	commands := make(chan []string, 1)
+	go serveSMTP(t, l, commands)
+
+	port := l.Addr().(*net.TCPAddr).Port
+	n := NewEmailNotifier("127.0.0.1", port, "", "", "citop@example.com", []string{"alice@example.com"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Post(ctx, "message"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"EHLO localhost",
+		"MAIL FROM:<citop@example.com>",
+		"RCPT TO:<alice@example.com>",
+		"DATA",
+		"QUIT",
+	}
+	if diff := cmp.Diff(expected, <-commands); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
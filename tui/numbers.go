@@ -0,0 +1,65 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LineNumbers tells how the rows of the table are numbered
+type LineNumbers string
+
+const (
+	// Rows are not numbered
+	LineNumbersOff LineNumbers = "off"
+	// Rows are numbered from the top of the table starting at 1
+	LineNumbersAbsolute LineNumbers = "absolute"
+	// Rows are numbered by their distance to the cursor, the row at the cursor showing its
+	// absolute number, so that the number of a row is the count to type before j or k to
+	// reach it
+	LineNumbersRelative LineNumbers = "relative"
+)
+
+// ParseLineNumbers returns the numbering named 's'. An empty string selects LineNumbersOff.
+func ParseLineNumbers(s string) (LineNumbers, error) {
+	switch n := LineNumbers(strings.ToLower(s)); n {
+	case "":
+		return LineNumbersOff, nil
+	case LineNumbersOff, LineNumbersAbsolute, LineNumbersRelative:
+		return n, nil
+	default:
+		return "", fmt.Errorf("invalid line numbers %q (expected \"off\", \"absolute\" or \"relative\")", s)
+	}
+}
+
+// Return the numbering following 'n' when the user cycles through numberings
+func (n LineNumbers) next() LineNumbers {
+	switch n {
+	case LineNumbersAbsolute:
+		return LineNumbersRelative
+	case LineNumbersRelative:
+		return LineNumbersOff
+	default:
+		return LineNumbersAbsolute
+	}
+}
+
+// Return the number shown in front of the row at position 'i' of the table, starting at 0, when
+// the cursor is on the row at position 'active'
+func (n LineNumbers) number(i int, active int) int {
+	if n == LineNumbersRelative && i != active {
+		if i < active {
+			return active - i
+		}
+		return i - active
+	}
+	return i + 1
+}
+
+// Return the width of the gutter holding the numbers of 'rows' rows, separator included
+func (n LineNumbers) gutterWidth(rows int) int {
+	if n != LineNumbersAbsolute && n != LineNumbersRelative {
+		return 0
+	}
+	return len(strconv.Itoa(rows)) + 1
+}
@@ -0,0 +1,84 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseLineNumbers(t *testing.T) {
+	testCases := []struct {
+		s        string
+		expected LineNumbers
+	}{
+		{"", LineNumbersOff},
+		{"off", LineNumbersOff},
+		{"Absolute", LineNumbersAbsolute},
+		{"relative", LineNumbersRelative},
+	}
+	for _, testCase := range testCases {
+		n, err := ParseLineNumbers(testCase.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != testCase.expected {
+			t.Fatalf("expected %q but got %q", testCase.expected, n)
+		}
+	}
+
+	if _, err := ParseLineNumbers("hybrid"); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+}
+
+func TestTable_SetLineNumbers(t *testing.T) {
+	table, err := NewTable(longSource, 10, 5, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Scroll(2)
+
+	// Return the gutter of each line of the table
+	gutters := func() []string {
+		values := make([]string, 0)
+		for _, text := range table.Text() {
+			if text.X == 0 {
+				values = append(values, text.S.String())
+			}
+		}
+		return values
+	}
+
+	testCases := []struct {
+		numbers  LineNumbers
+		expected []string
+	}{
+		{LineNumbersAbsolute, []string{"  ", "1 ", "2 ", "3 ", "4 "}},
+		{LineNumbersRelative, []string{"  ", "2 ", "1 ", "3 ", "1 "}},
+	}
+	for _, testCase := range testCases {
+		t.Run(string(testCase.numbers), func(t *testing.T) {
+			table.SetLineNumbers(testCase.numbers)
+			if diff := cmp.Diff(testCase.expected, gutters()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("no gutter without numbers", func(t *testing.T) {
+		table.SetLineNumbers(LineNumbersOff)
+		if texts := table.Text(); len(texts) != 5 {
+			t.Fatalf("expected 5 lines but got %d", len(texts))
+		}
+	})
+
+	t.Run("the gutter narrows the columns", func(t *testing.T) {
+		table.SetLineNumbers(LineNumbersAbsolute)
+		for _, text := range table.Text() {
+			if text.X == 2 && text.S.Length() != 8 {
+				t.Fatalf("expected line 8 columns wide but got %q", text.S.String())
+			}
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package tui
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/text"
+	"github.com/nbedos/citop/utils"
+)
+
+// Number of screen columns between two tab stops
+const tabWidth = 8
+
+// pagerRowKey designates a line of the text shown by the pager, starting at 1
+type pagerRowKey struct {
+	line int
+}
+
+type pagerRow struct {
+	key    pagerRowKey
+	header string
+	line   text.StyledString
+}
+
+func (r pagerRow) Traversable() bool {
+	return false
+}
+
+func (r pagerRow) Children() []utils.TreeNode {
+	return nil
+}
+
+func (r *pagerRow) SetTraversable(traversable bool, recursive bool) {}
+
+// Lines of text are shown as is, without the prefix of tree nodes
+func (r *pagerRow) SetPrefix(s string) {}
+
+func (r pagerRow) Key() interface{} {
+	return r.key
+}
+
+func (r pagerRow) URL() string {
+	return ""
+}
+
+func (r pagerRow) Tabular(loc *time.Location) map[string]text.StyledString {
+	return map[string]text.StyledString{
+		r.header: r.line,
+	}
+}
+
+// Control sequences of terminals. Those other than SGR sequences, e.g. "\x1b[1;31m", only make
+// sense on a terminal and are dropped.
+var controlSequence = regexp.MustCompile("\x1b\\[[0-9;?]*[A-Za-z]")
+
+// Return 'line' as shown by the pager: tabs are expanded to the next tab stop and parts of the
+// line are shown with the colors and attributes set by SGR sequences, unless 'stripColors' is
+// true. 'style' is the style set by the previous lines, the style at the end of 'line' is
+// returned along with it as terminals carry it over to the next line.
+func pagerLine(line string, style text.ANSIStyle, stripColors bool) (text.StyledString, text.ANSIStyle) {
+	s := text.NewStyledString("")
+	column := 0
+	appendText := func(t string) {
+		b := strings.Builder{}
+		for _, r := range t {
+			if r == '\t' {
+				n := tabWidth - column%tabWidth
+				b.WriteString(strings.Repeat(" ", n))
+				column += n
+				continue
+			}
+			b.WriteRune(r)
+			column += runewidth.RuneWidth(r)
+		}
+		if b.Len() > 0 {
+			s.AppendANSI(b.String(), style)
+		}
+	}
+
+	last := 0
+	for _, indices := range controlSequence.FindAllStringIndex(line, -1) {
+		appendText(line[last:indices[0]])
+		last = indices[1]
+		if sequence := line[indices[0]:indices[1]]; !stripColors && strings.HasSuffix(sequence, "m") {
+			// Parameters of "\x1b[...m"
+			style = style.Update(sequence[2 : len(sequence)-1])
+		}
+	}
+	appendText(line[last:])
+
+	return s, style
+}
+
+// Pager shows the lines of a text file, such as the log of a job, in the table. The file is
+// read again every time its size changes so that lines appended to the log of a running job are
+// shown as they arrive.
+type Pager struct {
+	title       string
+	path        string
+	stripColors bool
+	content     *pagerContent
+}
+
+// Rows of the file as of its last reading
+type pagerContent struct {
+	size int64
+	rows []HierarchicalTabularSourceRow
+}
+
+// NewPager returns a pager showing the file at 'filePath' under the header 'title'. Colors set
+// by escape sequences are dropped if 'stripColors' is true.
+func NewPager(title string, filePath string, stripColors bool) Pager {
+	return Pager{
+		title:       title,
+		path:        filePath,
+		stripColors: stripColors,
+		content: &pagerContent{
+			size: -1,
+		},
+	}
+}
+
+func (p Pager) Headers() []string {
+	return []string{p.title}
+}
+
+func (p Pager) Alignment() map[string]text.Alignment {
+	return map[string]text.Alignment{
+		p.title: text.Left,
+	}
+}
+
+// Rows returns the lines of the file. The rows of the last reading are returned if the file
+// cannot be read.
+func (p Pager) Rows() []HierarchicalTabularSourceRow {
+	info, err := os.Stat(p.path)
+	if err != nil || info.Size() == p.content.size {
+		return p.content.rows
+	}
+	bs, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return p.content.rows
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(bs), "\n"), "\n")
+	if len(bs) == 0 {
+		lines = nil
+	}
+	rows := make([]HierarchicalTabularSourceRow, 0, len(lines))
+	style := text.DefaultANSIStyle
+	for i, line := range lines {
+		row := pagerRow{
+			key:    pagerRowKey{line: i + 1},
+			header: p.title,
+		}
+		row.line, style = pagerLine(strings.TrimSuffix(line, "\r"), style, p.stripColors)
+		rows = append(rows, &row)
+	}
+	p.content.size = int64(len(bs))
+	p.content.rows = rows
+
+	return rows
+}
+
+func (p Pager) WriteToDisk(ctx context.Context, key interface{}, dir string) (string, error) {
+	return "", cache.ErrNoLogHere
+}
+
+func (p Pager) Details(key interface{}) (string, error) {
+	return "", ErrNoDetailsHere
+}
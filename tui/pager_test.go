@@ -0,0 +1,232 @@
+package tui
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/text"
+)
+
+func TestPagerLine(t *testing.T) {
+	red := text.DefaultANSIStyle.Update("1;31")
+	green := text.DefaultANSIStyle.Update("32")
+	styled := func(parts ...interface{}) text.StyledString {
+		s := text.NewStyledString("")
+		for i := 0; i < len(parts); i += 2 {
+			s.AppendANSI(parts[i].(string), parts[i+1].(text.ANSIStyle))
+		}
+		return s
+	}
+
+	testCases := []struct {
+		name          string
+		line          string
+		style         text.ANSIStyle
+		stripColors   bool
+		expected      text.StyledString
+		expectedStyle text.ANSIStyle
+	}{
+		{
+			name:          "plain text",
+			line:          "go test ./...",
+			style:         text.DefaultANSIStyle,
+			expected:      styled("go test ./...", text.DefaultANSIStyle),
+			expectedStyle: text.DefaultANSIStyle,
+		},
+		{
+			name:          "tabs are expanded to the next tab stop",
+			line:          "ok\tcitop\t0.1s",
+			style:         text.DefaultANSIStyle,
+			expected:      styled("ok      citop   0.1s", text.DefaultANSIStyle),
+			expectedStyle: text.DefaultANSIStyle,
+		},
+		{
+			name:          "colors are shown",
+			line:          "\x1b[1;31mFAIL\x1b[0m tests \x1b[32mok",
+			style:         text.DefaultANSIStyle,
+			expected:      styled("FAIL", red, " tests ", text.DefaultANSIStyle, "ok", green),
+			expectedStyle: green,
+		},
+		{
+			name:          "style of the previous lines",
+			line:          "Traceback\x1b[m",
+			style:         red,
+			expected:      styled("Traceback", red),
+			expectedStyle: text.DefaultANSIStyle,
+		},
+		{
+			name:          "colors are stripped",
+			line:          "\x1b[1;31mFAIL\x1b[0m tests",
+			style:         text.DefaultANSIStyle,
+			stripColors:   true,
+			expected:      styled("FAIL", text.DefaultANSIStyle, " tests", text.DefaultANSIStyle),
+			expectedStyle: text.DefaultANSIStyle,
+		},
+		{
+			name:          "other control sequences are dropped",
+			line:          "\x1b[?25lDownloading\x1b[2K",
+			style:         text.DefaultANSIStyle,
+			expected:      styled("Downloading", text.DefaultANSIStyle),
+			expectedStyle: text.DefaultANSIStyle,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, style := pagerLine(testCase.line, testCase.style, testCase.stripColors)
+			if !reflect.DeepEqual(s, testCase.expected) {
+				t.Fatalf("expected %#v but got %#v", testCase.expected, s)
+			}
+			if style != testCase.expectedStyle {
+				t.Fatalf("expected style %+v but got %+v", testCase.expectedStyle, style)
+			}
+		})
+	}
+}
+
+func TestPager_Rows(t *testing.T) {
+	file, err := ioutil.TempFile("", "citop_pager_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	pager := NewPager("LOG", file.Name(), false)
+	if rows := pager.Rows(); len(rows) != 0 {
+		t.Fatalf("expected no row for an empty file but got %d", len(rows))
+	}
+
+	// Lines appended to the file are shown once written
+	for _, line := range []string{"line 1\n", "line 2\r\n"} {
+		if _, err := file.WriteString(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows := pager.Rows()
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows but got %d", len(rows))
+	}
+	for i, row := range rows {
+		if key := row.Key(); key != (pagerRowKey{line: i + 1}) {
+			t.Fatalf("unexpected key %v", key)
+		}
+	}
+	if s := rows[1].Tabular(time.UTC)["LOG"].String(); s != "line 2" {
+		t.Fatalf("expected %q but got %q", "line 2", s)
+	}
+	if _, err := pager.WriteToDisk(context.Background(), rows[0].Key(), ""); err != cache.ErrNoLogHere {
+		t.Fatalf("expected %v but got %v", cache.ErrNoLogHere, err)
+	}
+}
+
+func TestController_pager(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, DefaultStyleSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+	c := cache.NewCache([]cache.CIProvider{mockProvider{id: "id"}}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := NewBuildsByCommit(&c)
+	controller, err := NewController(&tui, &source, time.UTC, dir, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.resize(80, 20)
+	controller.refresh()
+	ctx := context.Background()
+	press := func(r rune) error {
+		return controller.process(ctx, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+
+	if !controller.table.Jump(jobAsRow.key) {
+		t.Fatal("job row not found")
+	}
+	if err := press('v'); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyOutcome(t, &controller); err != nil {
+		t.Fatal(err)
+	}
+	if controller.pager == nil {
+		t.Fatal("expected the log of the job to be shown in the pager")
+	}
+	if headers := controller.table.Headers(); len(headers) != 1 || headers[0] != "LOG" {
+		t.Fatalf("unexpected headers %v", headers)
+	}
+	if key, _ := controller.table.ActiveKey(); key != (pagerRowKey{line: 1}) {
+		t.Fatalf("unexpected row at the cursor: %v", key)
+	}
+	if controller.Session().Cursor == "" {
+		t.Fatal("the session must designate the row of the table set aside by the pager")
+	}
+
+	// Matches of the search pattern are highlighted on the line at the cursor
+	for _, r := range "/og" {
+		if err := press(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := controller.process(ctx, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone)); err != nil {
+		t.Fatal(err)
+	}
+	active := DefaultStyleSheet[text.ActiveRow](tcell.StyleDefault)
+	highlighted := DefaultStyleSheet[text.SearchMatch](active)
+	found := false
+	_, height := tui.screen.Size()
+	for y := 0; y < height; y++ {
+		content := ""
+		for x := 0; x < 3; x++ {
+			r, _, _, _ := tui.screen.GetContent(x, y)
+			content += string(r)
+		}
+		if content != "log" {
+			continue
+		}
+		found = true
+		for x, expected := range []tcell.Style{active, highlighted, highlighted} {
+			if _, _, style, _ := tui.screen.GetContent(x, y); style != expected {
+				t.Fatalf("unexpected style at column %d", x)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("line of the log not found on screen")
+	}
+
+	// q closes the pager before quitting
+	if err := press('q'); err != nil {
+		t.Fatal(err)
+	}
+	if controller.pager != nil {
+		t.Fatal("expected the pager to be closed")
+	}
+	if controller.table.highlight != "" {
+		t.Fatal("matches must not be highlighted once the pager is closed")
+	}
+	if key, _ := controller.table.ActiveKey(); key != jobAsRow.key {
+		t.Fatalf("expected the cursor on %v but got %v", jobAsRow.key, key)
+	}
+	if err := press('q'); err != ErrExit {
+		t.Fatalf("expected %v but got %v", ErrExit, err)
+	}
+}
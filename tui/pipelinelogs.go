@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nbedos/citop/cache"
+)
+
+// Return the header preceding the log of 'j' among the logs of its pipeline
+func pipelineLogHeader(j stageJob) string {
+	name := j.job.Name
+	if name == "" {
+		name = j.job.ID
+	}
+	if j.stageName != "" {
+		name = j.stageName + ": " + name
+	}
+	return fmt.Sprintf("==> %s (%s) <==", name, j.job.State)
+}
+
+// WriteLogsToDisk writes the logs of all the jobs of the pipeline or stage designated by 'key'
+// to a file of 'dir', one after the other and each preceded by a header naming its job, and
+// returns the path of the file. Logs are fetched concurrently and shown as by WriteToDisk.
+func (s BuildsByCommit) WriteLogsToDisk(ctx context.Context, key interface{}, dir string) (string, error) {
+	buildKey, ok := key.(buildRowKey)
+	if !ok {
+		return "", fmt.Errorf("key conversion to buildRowKey failed: '%v'", key)
+	}
+	if buildKey.jobID != "" || buildKey.deploymentID != "" {
+		return "", cache.ErrNoLogHere
+	}
+	build, exists := s.cache.Build(buildKey.accountID, buildKey.buildID)
+	if !exists {
+		return "", cache.ErrNoLogHere
+	}
+
+	jobs := make([]stageJob, 0)
+	for _, j := range stageJobs(build) {
+		if buildKey.stageID == 0 || j.stageID == buildKey.stageID {
+			jobs = append(jobs, j)
+		}
+	}
+	if len(jobs) == 0 {
+		return "", cache.ErrNoLogHere
+	}
+
+	logs := make([]string, len(jobs))
+	errs := make([]error, len(jobs))
+	semaphore := make(chan struct{}, maxConcurrentLogFetches)
+	wg := sync.WaitGroup{}
+	for i, j := range jobs {
+		wg.Add(1)
+		go func(i int, j stageJob) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			jobKey := buildRowKey{
+				ref:       buildKey.ref,
+				sha:       buildKey.sha,
+				accountID: buildKey.accountID,
+				buildID:   buildKey.buildID,
+				stageID:   j.stageID,
+				jobID:     j.job.ID,
+			}
+			logs[i], errs[i] = s.renderLog(ctx, jobKey)
+		}(i, j)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	b := strings.Builder{}
+	for i, j := range jobs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(pipelineLogHeader(j) + "\n")
+		if errs[i] != nil {
+			fmt.Fprintf(&b, "    log unavailable: %v\n", errs[i])
+			continue
+		}
+		b.WriteString(logs[i])
+	}
+
+	file, err := ioutil.TempFile(dir, fmt.Sprintf("pipeline_%s_*.log", pathComponent(buildKey.buildID)))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(b.String()); err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, filepath.Base(file.Name())), nil
+}
@@ -0,0 +1,67 @@
+package tui
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/nbedos/citop/cache"
+)
+
+func TestBuildsByCommit_WriteLogsToDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Job outside of any stage in addition to the job of the stage "test"
+	b := build
+	b.Jobs = []*cache.Job{{ID: "55", State: cache.Failed, Name: "lint"}}
+	c := cache.NewCache([]cache.CIProvider{mockProvider{id: "id"}}, nil)
+	if err := c.Save(b); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+	ctx := context.Background()
+	pipelineKey := stageAsRow.key
+	pipelineKey.stageID = 0
+
+	testCases := []struct {
+		name     string
+		key      buildRowKey
+		expected string
+	}{
+		{
+			name:     "pipeline",
+			key:      pipelineKey,
+			expected: "==> lint (failed) <==\nlog\n\n==> test: golang 1.12 (passed) <==\nlog\n",
+		},
+		{
+			name:     "stage",
+			key:      stageAsRow.key,
+			expected: "==> test: golang 1.12 (passed) <==\nlog\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			logPath, err := source.WriteLogsToDisk(ctx, testCase.key, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := ioutil.ReadFile(logPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, string(content))
+			}
+		})
+	}
+
+	if _, err := source.WriteLogsToDisk(ctx, jobAsRow.key, dir); err != cache.ErrNoLogHere {
+		t.Fatalf("expected %v but got %v", cache.ErrNoLogHere, err)
+	}
+}
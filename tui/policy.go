@@ -0,0 +1,225 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nbedos/citop/cache"
+)
+
+// FailurePolicy decides which final states of the pipelines of a commit cause headless modes
+// to report a failure
+type FailurePolicy struct {
+	FailOn map[cache.State]bool
+	// Do not report jobs allowed to fail
+	IgnoreAllowedFailures bool
+}
+
+// DefaultFailurePolicy reports failed and canceled jobs, including jobs allowed to fail
+var DefaultFailurePolicy = FailurePolicy{
+	FailOn: map[cache.State]bool{
+		cache.Failed:   true,
+		cache.Canceled: true,
+	},
+}
+
+var policyStates = []cache.State{
+	cache.Pending,
+	cache.Running,
+	cache.Passed,
+	cache.Failed,
+	cache.Canceled,
+	cache.Manual,
+	cache.Skipped,
+}
+
+// Split a comma-separated list ignoring empty values
+func splitList(s string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// ParseStates returns the set of states listed by 's', a comma-separated list of states
+func ParseStates(s string) (map[cache.State]bool, error) {
+	states := make(map[cache.State]bool)
+	for _, value := range splitList(s) {
+		valid := false
+		for _, state := range policyStates {
+			if value == string(state) {
+				states[state] = true
+				valid = true
+			}
+		}
+		if !valid {
+			names := make([]string, 0, len(policyStates))
+			for _, state := range policyStates {
+				names = append(names, string(state))
+			}
+			return nil, fmt.Errorf("invalid state %q (expected one of %s)", value, strings.Join(names, ", "))
+		}
+	}
+	return states, nil
+}
+
+// ParseFailurePolicy builds a policy from 'failOn', a comma-separated list of states, and
+// 'ignore', a comma-separated list of exceptions. The only exception supported is
+// "allow_failure".
+func ParseFailurePolicy(failOn string, ignore string) (FailurePolicy, error) {
+	var p FailurePolicy
+	var err error
+	if p.FailOn, err = ParseStates(failOn); err != nil {
+		return p, err
+	}
+
+	for _, value := range splitList(ignore) {
+		switch value {
+		case "allow_failure":
+			p.IgnoreAllowedFailures = true
+		default:
+			return p, fmt.Errorf("invalid exception %q (expected \"allow_failure\")", value)
+		}
+	}
+
+	return p, nil
+}
+
+// Failure designates a pipeline, or a job of a pipeline, whose state violates a policy
+type Failure struct {
+	Build cache.Build
+	// Name of the stage of the job, if any
+	Stage string
+	// Nil if the failure concerns the pipeline itself
+	Job *cache.Job
+}
+
+// State returns the state of the job or the pipeline that failed
+func (f Failure) State() cache.State {
+	if f.Job != nil {
+		return f.Job.State
+	}
+	return f.Build.State
+}
+
+// Failures returns the jobs whose state violates the policy, as well as the pipelines without
+// any job whose state violates the policy. Pipelines are sorted by provider and identifier.
+func (p FailurePolicy) Failures(builds []cache.Build) []Failure {
+	builds = append([]cache.Build(nil), builds...)
+	sort.Slice(builds, func(i, j int) bool {
+		bi, bj := builds[i], builds[j]
+		if bi.Repository.Provider.ID != bj.Repository.Provider.ID {
+			return bi.Repository.Provider.ID < bj.Repository.Provider.ID
+		}
+		return bi.ID < bj.ID
+	})
+
+	failures := make([]Failure, 0)
+	for _, build := range builds {
+		nbrJobs := len(build.Jobs)
+		check := func(stage string, job *cache.Job) {
+			if p.IgnoreAllowedFailures && job.AllowFailure {
+				return
+			}
+			if p.FailOn[job.State] {
+				failures = append(failures, Failure{
+					Build: build,
+					Stage: stage,
+					Job:   job,
+				})
+			}
+		}
+
+		for _, job := range build.Jobs {
+			check("", job)
+		}
+
+		stageIDs := make([]int, 0, len(build.Stages))
+		for stageID := range build.Stages {
+			stageIDs = append(stageIDs, stageID)
+		}
+		sort.Ints(stageIDs)
+		for _, stageID := range stageIDs {
+			stage := build.Stages[stageID]
+			nbrJobs += len(stage.Jobs)
+			for _, job := range latestJobs(stage.Jobs) {
+				check(stage.Name, job)
+			}
+		}
+
+		if nbrJobs == 0 && p.FailOn[build.State] {
+			failures = append(failures, Failure{Build: build})
+		}
+	}
+
+	return failures
+}
+
+// Only keep the most recent job among jobs sharing the same name. GitLab keeps previous runs
+// of a job after it is restarted and they must not cause a failure.
+func latestJobs(jobs []*cache.Job) []*cache.Job {
+	latest := make(map[string]*cache.Job, len(jobs))
+	for _, job := range jobs {
+		other, exists := latest[job.Name]
+		if !exists || job.CreatedAt.Valid && job.CreatedAt.Time.After(other.CreatedAt.Time) {
+			latest[job.Name] = job
+		}
+	}
+
+	filtered := make([]*cache.Job, 0, len(latest))
+	for _, job := range jobs {
+		if latest[job.Name] == job {
+			filtered = append(filtered, job)
+		}
+	}
+	return filtered
+}
+
+// PolicyError is returned by headless modes when the final state of pipelines violates the
+// failure policy
+type PolicyError struct {
+	Failures []Failure
+}
+
+func (e PolicyError) Error() string {
+	if len(e.Failures) == 1 {
+		return "1 pipeline or job did not succeed"
+	}
+	return fmt.Sprintf("%d pipelines or jobs did not succeed", len(e.Failures))
+}
+
+// ErrTimeout is returned by headless modes if pipelines are still active when the deadline of
+// the context expires
+var ErrTimeout = errors.New("timeout expired before the end of all pipelines")
+
+// Return the outcome of a headless mode given the error that stopped monitoring and the last
+// known state of 'builds'. Monitoring stopped by the expiration of the deadline of 'ctx' is not
+// an error if all pipelines are complete. Otherwise ErrTimeout is returned. A PolicyError is
+// returned if the state of 'builds' violates the policy.
+func (p FailurePolicy) outcome(ctx context.Context, err error, builds []cache.Build) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		if len(builds) == 0 {
+			return ErrTimeout
+		}
+		for _, build := range builds {
+			if build.State.IsActive() {
+				return ErrTimeout
+			}
+		}
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if failures := p.Failures(builds); len(failures) > 0 {
+		return PolicyError{Failures: failures}
+	}
+	return nil
+}
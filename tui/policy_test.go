@@ -0,0 +1,187 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func TestParseFailurePolicy(t *testing.T) {
+	p, err := ParseFailurePolicy("failed, manual,", "allow_failure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.FailOn) != 2 || !p.FailOn[cache.Failed] || !p.FailOn[cache.Manual] {
+		t.Fatalf("unexpected states %v", p.FailOn)
+	}
+	if !p.IgnoreAllowedFailures {
+		t.Fatal("expected allowed failures to be ignored")
+	}
+
+	for _, args := range [][2]string{{"broken", ""}, {"failed", "manual"}} {
+		if _, err := ParseFailurePolicy(args[0], args[1]); err == nil {
+			t.Fatalf("expected error for %v but got nil", args)
+		}
+	}
+}
+
+func TestFailurePolicy_Failures(t *testing.T) {
+	date := func(minutes int) utils.NullTime {
+		return utils.NullTime{
+			Time:  time.Date(2019, 11, 13, 13, minutes, 0, 0, time.UTC),
+			Valid: true,
+		}
+	}
+	repository := cache.Repository{
+		Provider: cache.Provider{
+			ID:   "gitlab-0",
+			Name: "gitlab",
+		},
+	}
+	builds := []cache.Build{
+		{
+			Repository: &repository,
+			ID:         "2",
+			State:      cache.Failed,
+			Jobs: []*cache.Job{
+				{ID: "1", Name: "lint", State: cache.Failed, AllowFailure: true},
+			},
+			Stages: map[int]*cache.Stage{
+				1: {
+					ID:   1,
+					Name: "tests",
+					Jobs: []*cache.Job{
+						// Restarted job
+						{ID: "2", Name: "unit", State: cache.Failed, CreatedAt: date(0)},
+						{ID: "3", Name: "unit", State: cache.Passed, CreatedAt: date(5)},
+						{ID: "4", Name: "e2e", State: cache.Canceled},
+					},
+				},
+			},
+		},
+		{
+			Repository: &repository,
+			ID:         "1",
+			State:      cache.Canceled,
+		},
+	}
+
+	testCases := []struct {
+		name   string
+		policy FailurePolicy
+		jobIDs []string
+	}{
+		{
+			name:   "default policy",
+			policy: DefaultFailurePolicy,
+			jobIDs: []string{"", "1", "4"},
+		},
+		{
+			name: "allowed failures are ignored",
+			policy: FailurePolicy{
+				FailOn:                DefaultFailurePolicy.FailOn,
+				IgnoreAllowedFailures: true,
+			},
+			jobIDs: []string{"", "4"},
+		},
+		{
+			name: "only failed jobs",
+			policy: FailurePolicy{
+				FailOn: map[cache.State]bool{cache.Failed: true},
+			},
+			jobIDs: []string{"1"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			failures := testCase.policy.Failures(builds)
+			jobIDs := make([]string, 0, len(failures))
+			for _, failure := range failures {
+				if failure.Job == nil {
+					jobIDs = append(jobIDs, "")
+				} else {
+					jobIDs = append(jobIDs, failure.Job.ID)
+				}
+			}
+			if len(jobIDs) != len(testCase.jobIDs) {
+				t.Fatalf("expected failures %v but got %v", testCase.jobIDs, jobIDs)
+			}
+			for i := range jobIDs {
+				if jobIDs[i] != testCase.jobIDs[i] {
+					t.Fatalf("expected failures %v but got %v", testCase.jobIDs, jobIDs)
+				}
+			}
+		})
+	}
+}
+
+func TestFailurePolicy_outcome(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	running := []cache.Build{{ID: "1", State: cache.Running}}
+	failed := []cache.Build{{ID: "1", State: cache.Failed}}
+	passed := []cache.Build{{ID: "1", State: cache.Passed}}
+	errMonitoring := errors.New("monitoring error")
+
+	testCases := []struct {
+		name   string
+		ctx    context.Context
+		err    error
+		builds []cache.Build
+		check  func(err error) bool
+	}{
+		{
+			name:   "pipelines still running at deadline",
+			ctx:    expired,
+			err:    context.DeadlineExceeded,
+			builds: running,
+			check:  func(err error) bool { return err == ErrTimeout },
+		},
+		{
+			name:   "no pipeline found before deadline",
+			ctx:    expired,
+			err:    context.DeadlineExceeded,
+			builds: nil,
+			check:  func(err error) bool { return err == ErrTimeout },
+		},
+		{
+			name:   "pipelines complete at deadline",
+			ctx:    expired,
+			err:    context.DeadlineExceeded,
+			builds: passed,
+			check:  func(err error) bool { return err == nil },
+		},
+		{
+			name:   "failed pipelines at deadline",
+			ctx:    expired,
+			err:    context.DeadlineExceeded,
+			builds: failed,
+			check: func(err error) bool {
+				_, ok := err.(PolicyError)
+				return ok
+			},
+		},
+		{
+			name:   "monitoring error",
+			ctx:    context.Background(),
+			err:    errMonitoring,
+			builds: passed,
+			check:  func(err error) bool { return err == errMonitoring },
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := DefaultFailurePolicy.outcome(testCase.ctx, testCase.err, testCase.builds)
+			if !testCase.check(err) {
+				t.Fatalf("unexpected outcome: %v", err)
+			}
+		})
+	}
+}
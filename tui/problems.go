@@ -0,0 +1,144 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ProblemMatcher recognizes the problems reported in the lines of a log, such as compiler errors
+// and test failures
+type ProblemMatcher struct {
+	Name    string
+	pattern *regexp.Regexp
+}
+
+// NewProblemMatcher compiles a problem matcher. Each line of a log matching 'pattern' is a
+// problem. The named groups "file" and "line" of the pattern designate the location of the
+// problem and are optional. The named group "message" describes the problem, the whole line is
+// used if the pattern has no such group.
+func NewProblemMatcher(name string, pattern string) (ProblemMatcher, error) {
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return ProblemMatcher{}, fmt.Errorf("invalid pattern for problem matcher %q: %v", name, err)
+	}
+	for _, group := range r.SubexpNames() {
+		switch group {
+		case "", "file", "line", "message":
+		default:
+			return ProblemMatcher{}, fmt.Errorf("invalid group %q in the pattern of problem matcher %q (expected \"file\", \"line\" or \"message\")", group, name)
+		}
+	}
+
+	return ProblemMatcher{Name: name, pattern: r}, nil
+}
+
+func mustNewProblemMatcher(name string, pattern string) ProblemMatcher {
+	m, err := NewProblemMatcher(name, pattern)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// DefaultProblemMatchers recognize the errors of the Go compiler, the failures of Go tests and
+// the failures reported by pytest
+var DefaultProblemMatchers = []ProblemMatcher{
+	mustNewProblemMatcher("go", `^\s*(?P<file>[^\s:]+\.go):(?P<line>\d+)(?::\d+)?: (?P<message>.+)$`),
+	mustNewProblemMatcher("go test", `^\s*--- FAIL: (?P<message>.+)$`),
+	mustNewProblemMatcher("pytest", `^(?:FAILED|ERROR) (?P<file>[^\s:]+\.py)::(?P<message>.+)$`),
+	mustNewProblemMatcher("python", `^(?P<file>[^\s:]+\.py):(?P<line>\d+): (?P<message>\w+(?:Error|Exception).*)$`),
+}
+
+// Problem is a line of a log recognized by a problem matcher
+type Problem struct {
+	Matcher string
+	// Location of the problem in the source code, if the matcher knows it. Line is 0 if unknown.
+	File string
+	Line int
+	// Line of the log reporting the problem, starting at 1
+	LogLine int
+	Message string
+}
+
+func (p Problem) String() string {
+	switch {
+	case p.File != "" && p.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	case p.File != "":
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	default:
+		return p.Message
+	}
+}
+
+// Escape sequences setting the colors of the text of a log
+var colorSequence = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// FindProblems returns the problems reported in 'log' in order of appearance. Each line matches
+// at most one problem matcher, the first one listed by 'matchers'. Colors are ignored.
+func FindProblems(log string, matchers []ProblemMatcher) []Problem {
+	var problems []Problem
+	for i, line := range strings.Split(log, "\n") {
+		line = colorSequence.ReplaceAllString(strings.TrimRight(line, "\r"), "")
+		for _, m := range matchers {
+			groups := m.pattern.FindStringSubmatch(line)
+			if groups == nil {
+				continue
+			}
+			p := Problem{
+				Matcher: m.Name,
+				LogLine: i + 1,
+				Message: strings.TrimSpace(line),
+			}
+			for j, name := range m.pattern.SubexpNames() {
+				switch name {
+				case "file":
+					p.File = groups[j]
+				case "line":
+					p.Line, _ = strconv.Atoi(groups[j])
+				case "message":
+					p.Message = strings.TrimSpace(groups[j])
+				}
+			}
+			problems = append(problems, p)
+			break
+		}
+	}
+
+	return problems
+}
+
+// problemsByJob holds the problems found in the last log fetched for each job
+type problemsByJob struct {
+	mux      sync.Mutex
+	problems map[buildRowKey][]Problem
+}
+
+func newProblemsByJob() *problemsByJob {
+	return &problemsByJob{
+		problems: make(map[buildRowKey][]Problem),
+	}
+}
+
+// Return the key designating the job of the row whose key is 'key'
+func jobKeyOf(key buildRowKey) buildRowKey {
+	key.annotation = 0
+	key.problem = 0
+	key.testReport, key.test = false, 0
+	return key
+}
+
+func (p *problemsByJob) set(key buildRowKey, problems []Problem) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.problems[jobKeyOf(key)] = problems
+}
+
+func (p *problemsByJob) get(key buildRowKey) []Problem {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.problems[jobKeyOf(key)]
+}
@@ -0,0 +1,61 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewProblemMatcher(t *testing.T) {
+	t.Run("Valid pattern", func(t *testing.T) {
+		if _, err := NewProblemMatcher("eslint", `^(?P<file>\S+): line (?P<line>\d+), (?P<message>.+)$`); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Invalid patterns", func(t *testing.T) {
+		patterns := []string{
+			`^(?P<file>\S+`,
+			`^(?P<column>\d+): (?P<message>.+)$`,
+		}
+		for _, pattern := range patterns {
+			if _, err := NewProblemMatcher("invalid", pattern); err == nil {
+				t.Fatalf("expected an error for pattern %q", pattern)
+			}
+		}
+	})
+}
+
+func TestFindProblems(t *testing.T) {
+	log := "" +
+		"$ go vet ./...\n" +
+		"# github.com/nbedos/citop/tui\n" +
+		"tui/table.go:42:9: undefined: rows\n" +
+		"--- FAIL: TestTable (0.01s)\n" +
+		"    table_test.go:17: expected 3 rows\n" +
+		"\x1b[31mFAILED tests/test_anim.py::test_frames - AssertionError\x1b[0m\n" +
+		"tests/test_anim.py:42: AssertionError\n" +
+		"ok  \tgithub.com/nbedos/citop/cache\t0.021s\n"
+
+	expected := []Problem{
+		{Matcher: "go", File: "tui/table.go", Line: 42, LogLine: 3, Message: "undefined: rows"},
+		{Matcher: "go test", LogLine: 4, Message: "TestTable (0.01s)"},
+		{Matcher: "go", File: "table_test.go", Line: 17, LogLine: 5, Message: "expected 3 rows"},
+		{Matcher: "pytest", File: "tests/test_anim.py", LogLine: 6, Message: "test_frames - AssertionError"},
+		{Matcher: "python", File: "tests/test_anim.py", Line: 42, LogLine: 7, Message: "AssertionError"},
+	}
+	if diff := cmp.Diff(expected, FindProblems(log, DefaultProblemMatchers)); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("Pattern without message", func(t *testing.T) {
+		m, err := NewProblemMatcher("todo", `TODO`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Problem{{Matcher: "todo", LogLine: 2, Message: "// TODO fix this"}}
+		if diff := cmp.Diff(expected, FindProblems("ok\n  // TODO fix this\n", []ProblemMatcher{m})); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
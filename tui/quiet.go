@@ -0,0 +1,84 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/nbedos/citop/cache"
+)
+
+// RunQuiet monitors the pipelines of the commit without writing anything until monitoring stops.
+// It then writes to 'w' a line for each pipeline or job whose final state violates 'policy' and
+// returns a PolicyError. ErrTimeout is returned if pipelines are still running when the deadline
+// of 'ctx' expires.
+func RunQuiet(ctx context.Context, w io.Writer, repo string, sha string, CIProviders []cache.CIProvider, SourceProviders []cache.SourceProvider, filter Filter, policy FailurePolicy) error {
+	if len(CIProviders) == 0 || len(SourceProviders) == 0 {
+		return ErrNoProvider
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	repositoryURL, commit, err := resolveCommit(ctx, repo, sha, SourceProviders)
+	if err != nil {
+		return err
+	}
+
+	engine := cache.NewEngine(CIProviders, SourceProviders)
+	filter.apply(engine.Cache())
+	events := engine.Subscribe()
+	if err := engine.Start(ctx, repositoryURL, commit.Sha); err != nil {
+		return err
+	}
+	for range events {
+	}
+
+	err = policy.outcome(ctx, engine.Wait(), engine.Cache().Builds())
+	if policyErr, ok := err.(PolicyError); ok {
+		if err := writeSummary(w, policyErr.Failures); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// Write a line per failure to 'w'
+func writeSummary(w io.Writer, failures []Failure) error {
+	for _, failure := range failures {
+		if _, err := fmt.Fprintln(w, failure.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns a one-line description of the failure such as
+// "gitlab pipeline #42, stage tests, job go1.13: failed https://example.com/42/7"
+func (f Failure) String() string {
+	pipeline := f.Build.ID
+	if _, err := strconv.Atoi(pipeline); err == nil {
+		pipeline = "#" + pipeline
+	}
+	path := []string{fmt.Sprintf("%s pipeline %s", f.Build.Repository.Provider.Name, pipeline)}
+	webURL := f.Build.WebURL
+	if f.Stage != "" {
+		path = append(path, "stage "+f.Stage)
+	}
+	if f.Job != nil {
+		name := f.Job.Name
+		if name == "" {
+			name = f.Job.ID
+		}
+		path = append(path, "job "+name)
+		webURL = f.Job.WebURL
+	}
+
+	s := fmt.Sprintf("%s: %s", strings.Join(path, ", "), f.State())
+	if webURL != "" {
+		s += " " + webURL
+	}
+	return s
+}
@@ -0,0 +1,55 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nbedos/citop/cache"
+)
+
+func TestWriteSummary(t *testing.T) {
+	build := cache.Build{
+		Repository: &cache.Repository{
+			Provider: cache.Provider{
+				ID:   "gitlab-0",
+				Name: "gitlab",
+			},
+		},
+		ID:     "42",
+		State:  cache.Failed,
+		WebURL: "https://example.com/42",
+	}
+	failures := []Failure{
+		{
+			Build: build,
+			Stage: "tests",
+			Job: &cache.Job{
+				ID:     "7",
+				Name:   "go1.13",
+				State:  cache.Failed,
+				WebURL: "https://example.com/42/7",
+			},
+		},
+		{
+			Build: build,
+			Job: &cache.Job{
+				ID:    "8",
+				State: cache.Canceled,
+			},
+		},
+		{
+			Build: build,
+		},
+	}
+
+	b := strings.Builder{}
+	if err := writeSummary(&b, failures); err != nil {
+		t.Fatal(err)
+	}
+	expected := "gitlab pipeline #42, stage tests, job go1.13: failed https://example.com/42/7\n" +
+		"gitlab pipeline #42, job 8: canceled\n" +
+		"gitlab pipeline #42: failed https://example.com/42\n"
+	if b.String() != expected {
+		t.Fatalf("expected %q but got %q", expected, b.String())
+	}
+}
@@ -0,0 +1,1519 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/text"
+	"github.com/nbedos/citop/utils"
+)
+
+type buildRowKey struct {
+	ref       string
+	sha       string
+	accountID string
+	buildID   string
+	stageID   int
+	jobID     string
+	// Identifier of the deployment of a deployment row
+	deploymentID string
+	// Position of the annotation of an annotation row among the annotations of its job,
+	// starting at 1
+	annotation int
+	// Position of the problem of a problem row among the problems found in the log of its job,
+	// starting at 1
+	problem int
+	// Whether the row belongs to the test report of its job, and position of the failed test of
+	// a test row among the failures of the report, starting at 1. The summary of the report has
+	// no position.
+	testReport bool
+	test       int
+}
+
+type buildRow struct {
+	key        buildRowKey
+	type_      string
+	state      cache.State
+	name       string
+	provider   string
+	prefix     string
+	createdAt  utils.NullTime
+	startedAt  utils.NullTime
+	finishedAt utils.NullTime
+	updatedAt  utils.NullTime
+	duration   utils.NullDuration
+	// Average duration of the row over the previous pipelines of the same ref
+	average utils.NullDuration
+	// Estimated time remaining before the end of an active pipeline
+	eta utils.NullDuration
+	// Number of jobs of a pipeline or stage that finished, and total number of jobs
+	finished    int
+	total       int
+	children    []*buildRow
+	traversable bool
+	url         string
+	stage       string
+	os          string
+	arch        string
+	language    string
+	icon        string
+	template    *template.Template
+}
+
+func (b buildRow) Diff(other buildRow) string {
+	options := cmp.AllowUnexported(buildRowKey{}, buildRow{})
+	return cmp.Diff(b, other, options)
+}
+
+func (b buildRow) Traversable() bool {
+	return b.traversable
+}
+
+func (b buildRow) Children() []utils.TreeNode {
+	children := make([]utils.TreeNode, len(b.children))
+	for i := range b.children {
+		children[i] = b.children[i]
+	}
+	return children
+}
+
+func (b buildRow) Tabular(loc *time.Location) map[string]text.StyledString {
+	const nullPlaceholder = "-"
+
+	nullStringToString := func(s string) text.StyledString {
+		if s == "" {
+			s = nullPlaceholder
+		}
+		return text.NewStyledString(s)
+	}
+
+	nullTimeToString := func(t utils.NullTime) text.StyledString {
+		s := nullPlaceholder
+		if t.Valid {
+			s = t.Time.In(loc).Truncate(time.Second).Format("Jan 2 15:04")
+		}
+		return text.NewStyledString(s)
+	}
+
+	stateText := string(b.state)
+	if b.icon != "" {
+		stateText = b.icon + " " + stateText
+	}
+	state := text.NewStyledString(stateText)
+	if class, exists := stateClasses[b.state]; exists {
+		state.Add(class)
+	}
+
+	pipeline := b.key.buildID
+	if _, err := strconv.Atoi(b.key.buildID); err == nil {
+		pipeline = "#" + pipeline
+	}
+
+	name := text.NewStyledString(b.prefix)
+	if cell, err := b.templatedName(pipeline); err == nil {
+		name = text.Join([]text.StyledString{name, cell}, text.StyledString{})
+	} else if b.type_ == "P" {
+		name.Append(b.provider, text.Provider)
+	} else {
+		name.Append(b.name)
+	}
+
+	refClass := text.GitBranch
+	if strings.HasPrefix(b.key.ref, "tag:") {
+		refClass = text.GitTag
+	}
+
+	return map[string]text.StyledString{
+		"REF":      text.NewStyledString(b.key.ref, refClass),
+		"PROVIDER": text.NewStyledString(b.provider, text.Provider),
+		"PIPELINE": text.NewStyledString(pipeline),
+		"STAGE":    nullStringToString(b.stage),
+		"PROGRESS": b.progress(),
+		"TYPE":     text.NewStyledString(b.type_),
+		"STATE":    state,
+		"NAME":     name,
+		"CREATED":  nullTimeToString(b.createdAt),
+		"STARTED":  nullTimeToString(b.startedAt),
+		"FINISHED": nullTimeToString(b.finishedAt),
+		"UPDATED":  nullTimeToString(b.updatedAt),
+		"DURATION": text.NewStyledString(b.duration.String()),
+		"TREND":    b.trend(),
+		"ETA":      text.NewStyledString(b.eta.String()),
+		"OS":       nullStringToString(b.os),
+		"ARCH":     nullStringToString(b.arch),
+		"LANGUAGE": nullStringToString(b.language),
+	}
+}
+
+// Relative difference, in percent, between the duration of a row and its average beyond which
+// the trend is highlighted
+const trendThreshold = 25
+
+// Return the difference between the duration of the row and its recent average, e.g. "+40%"
+func (b buildRow) trend() text.StyledString {
+	if !b.average.Valid || b.average.Duration <= 0 || !b.duration.Valid || b.state.IsActive() {
+		return text.NewStyledString("-")
+	}
+
+	ratio := float64(b.duration.Duration) / float64(b.average.Duration)
+	percent := int(math.Round(100 * (ratio - 1)))
+	trend := text.NewStyledString(fmt.Sprintf("%+d%%", percent))
+	switch {
+	case percent >= trendThreshold:
+		trend.Add(text.StatusFailed)
+	case percent <= -trendThreshold:
+		trend.Add(text.StatusPassed)
+	}
+
+	return trend
+}
+
+// Return the number of finished jobs of a pipeline or stage out of its total number of jobs
+func (b buildRow) progress() text.StyledString {
+	if b.type_ == "J" || b.total == 0 {
+		return text.NewStyledString("-")
+	}
+	return text.NewStyledString(fmt.Sprintf("%d/%d", b.finished, b.total))
+}
+
+func (b buildRow) Key() interface{} {
+	return b.key
+}
+
+// SessionName identifies the row among its siblings whatever the commit. Pipelines are
+// recognized by their provider since their identifier changes with every commit.
+func (b buildRow) SessionName() string {
+	if b.type_ == "P" {
+		return b.type_ + ":" + b.provider
+	}
+	return b.type_ + ":" + b.name
+}
+
+func (b buildRow) URL() string {
+	return b.url
+}
+
+func (b *buildRow) SetTraversable(traversable bool, recursive bool) {
+	b.traversable = traversable
+	if recursive {
+		for _, child := range b.children {
+			child.SetTraversable(traversable, recursive)
+		}
+	}
+}
+
+func (b *buildRow) SetPrefix(s string) {
+	b.prefix = s
+}
+
+func (b *buildRow) setIcons(icons StateIcons) {
+	b.icon = icons[b.state]
+	for _, child := range b.children {
+		child.setIcons(icons)
+	}
+}
+
+// Set the average duration of pipelines and jobs from the history stored in 'c'
+func (b *buildRow) setAverages(c cache.Cache) {
+	var average time.Duration
+	var exists bool
+	switch b.type_ {
+	case "P":
+		average, exists = c.AverageDuration(b.key.accountID, b.key.buildID, "", "")
+	case "J":
+		average, exists = c.AverageDuration(b.key.accountID, b.key.buildID, b.stage, b.name)
+	}
+	b.average = utils.NullDuration{Duration: average, Valid: exists}
+	for _, child := range b.children {
+		child.setAverages(c)
+	}
+}
+
+// Replace the stages of a pipeline by their jobs
+func (b *buildRow) flattenStages() {
+	children := make([]*buildRow, 0, len(b.children))
+	for _, child := range b.children {
+		if child.type_ == "S" {
+			children = append(children, child.children...)
+		} else {
+			children = append(children, child)
+		}
+	}
+	b.children = children
+}
+
+// Estimate the time remaining before the end of an active pipeline from the average durations
+// of the pipeline and of its unfinished jobs. Averages must be set beforehand. The estimate is
+// the longest of the remaining times of the pipeline and of its jobs, or zero if they all run
+// longer than usual.
+func (b *buildRow) setETA(now time.Time) {
+	b.eta = utils.NullDuration{}
+	if b.type_ != "P" || !b.state.IsActive() {
+		return
+	}
+
+	remaining := func(average utils.NullDuration, start utils.NullTime) {
+		if !average.Valid {
+			return
+		}
+		d := average.Duration
+		if start.Valid {
+			d -= now.Sub(start.Time)
+		}
+		if d < 0 {
+			d = 0
+		}
+		if !b.eta.Valid || d > b.eta.Duration {
+			b.eta = utils.NullDuration{Duration: d, Valid: true}
+		}
+	}
+
+	remaining(b.average, utils.MinNullTime(b.startedAt, b.createdAt))
+	for _, node := range utils.DepthFirstTraversal(b, true) {
+		job := node.(*buildRow)
+		switch {
+		case job.type_ != "J":
+		case job.state == cache.Running:
+			remaining(job.average, job.startedAt)
+		case job.state == cache.Pending:
+			remaining(job.average, utils.NullTime{})
+		}
+	}
+}
+
+func (b *buildRow) setTemplates(templates RowTemplates) {
+	switch b.type_ {
+	case "P":
+		b.template = templates.Pipeline
+	case "S":
+		b.template = templates.Stage
+	case "J":
+		b.template = templates.Job
+	}
+	for _, child := range b.children {
+		child.setTemplates(templates)
+	}
+}
+
+var errNoTemplate = errors.New("no template is associated to this row")
+
+// Return the content of the NAME column computed by the template of the row
+func (b buildRow) templatedName(pipeline string) (text.StyledString, error) {
+	if b.template == nil {
+		return text.StyledString{}, errNoTemplate
+	}
+
+	rowType := map[string]string{
+		"P": "pipeline",
+		"S": "stage",
+		"J": "job",
+	}[b.type_]
+	data := RowData{
+		Type:     rowType,
+		Provider: b.provider,
+		Pipeline: pipeline,
+		Stage:    b.stage,
+		Name:     b.name,
+		State:    string(b.state),
+		Ref:      b.key.ref,
+		Sha:      b.key.sha,
+	}
+	lines, err := text.ExecuteTemplate(b.template, data)
+	if err != nil {
+		return text.StyledString{}, err
+	}
+
+	return text.Join(lines, text.NewStyledString(" ")), nil
+}
+
+func ref(ref string, tag bool) string {
+	if tag {
+		return fmt.Sprintf("tag: %s", ref)
+	}
+	return ref
+}
+
+// Return the jobs of a pipeline, only keeping the latest run of each job of a stage
+func pipelineJobs(b cache.Build) []*cache.Job {
+	jobs := append([]*cache.Job(nil), b.Jobs...)
+	for _, stage := range b.Stages {
+		jobs = append(jobs, latestJobs(stage.Jobs)...)
+	}
+	return jobs
+}
+
+// isFinished returns true if a job in state 's' will not run anymore without user intervention
+// other than a manual action
+func isFinished(s cache.State) bool {
+	return s == cache.Passed || s == cache.Failed || s == cache.Canceled || s == cache.Skipped
+}
+
+// Count the jobs that finished among 'jobs'
+func countFinished(jobs []*cache.Job) int {
+	finished := 0
+	for _, job := range jobs {
+		if isFinished(job.State) {
+			finished++
+		}
+	}
+	return finished
+}
+
+func buildRowFromBuild(b cache.Build) buildRow {
+	ref := ref(b.Ref, b.IsTag)
+	row := buildRow{
+		key: buildRowKey{
+			ref:       ref,
+			sha:       b.Commit.Sha,
+			accountID: b.Repository.Provider.ID,
+			buildID:   b.ID,
+		},
+		type_:      "P",
+		state:      b.State,
+		createdAt:  b.CreatedAt,
+		startedAt:  b.StartedAt,
+		finishedAt: b.FinishedAt,
+		updatedAt:  utils.NullTime{Time: b.UpdatedAt, Valid: true},
+		url:        b.WebURL,
+		duration:   b.Duration,
+		provider:   b.Repository.Provider.Name,
+	}
+	jobs := pipelineJobs(b)
+	row.finished, row.total = countFinished(jobs), len(jobs)
+
+	// Prefix only numeric IDs with hash
+	if _, err := strconv.Atoi(b.ID); err == nil {
+		row.name = fmt.Sprintf("#%s", b.ID)
+	} else {
+		row.name = b.ID
+	}
+
+	for _, job := range b.Jobs {
+		child := buildRowFromJob(b.Repository.Provider, b.Commit.Sha, ref, b.ID, 0, "", *job)
+		row.children = append(row.children, &child)
+	}
+
+	stageIDs := make([]int, 0, len(b.Stages))
+	for stageID := range b.Stages {
+		stageIDs = append(stageIDs, stageID)
+	}
+	sort.Ints(stageIDs)
+	for _, stageID := range stageIDs {
+		child := buildRowFromStage(b.Repository.Provider, b.Commit.Sha, ref, b.ID, b.WebURL, *b.Stages[stageID])
+		row.children = append(row.children, &child)
+	}
+
+	for _, deployment := range b.Deployments {
+		child := buildRowFromDeployment(b.Repository.Provider, b.Commit.Sha, ref, b.ID, *deployment)
+		row.children = append(row.children, &child)
+	}
+
+	return row
+}
+
+func buildRowFromStage(provider cache.Provider, sha string, ref string, buildID string, webURL string, s cache.Stage) buildRow {
+	row := buildRow{
+		key: buildRowKey{
+			ref:       ref,
+			sha:       sha,
+			accountID: provider.ID,
+			buildID:   buildID,
+			stageID:   s.ID,
+		},
+		type_:    "S",
+		state:    s.State,
+		name:     s.Name,
+		url:      webURL,
+		provider: provider.Name,
+	}
+
+	// Only keep the most recent run of each job to weed out previous runs of the job
+	jobs := latestJobs(s.Jobs)
+	row.finished, row.total = countFinished(jobs), len(jobs)
+	for _, job := range jobs {
+		row.createdAt = utils.MinNullTime(row.createdAt, job.CreatedAt)
+		row.startedAt = utils.MinNullTime(row.startedAt, job.StartedAt)
+		row.finishedAt = utils.MaxNullTime(row.finishedAt, job.FinishedAt)
+		row.updatedAt = utils.MaxNullTime(row.updatedAt, job.FinishedAt, job.StartedAt, job.CreatedAt)
+	}
+
+	row.duration = utils.NullSub(row.finishedAt, row.startedAt)
+
+	for _, job := range s.Jobs {
+		child := buildRowFromJob(provider, sha, ref, buildID, s.ID, s.Name, *job)
+		row.children = append(row.children, &child)
+	}
+
+	return row
+}
+
+func buildRowFromJob(provider cache.Provider, sha string, ref string, buildID string, stageID int, stageName string, j cache.Job) buildRow {
+	name := j.Name
+	if name == "" {
+		name = j.ID
+	}
+	key := buildRowKey{
+		ref:       ref,
+		sha:       sha,
+		accountID: provider.ID,
+		buildID:   buildID,
+		stageID:   stageID,
+		jobID:     j.ID,
+	}
+	var children []*buildRow
+	for i, annotation := range j.Annotations {
+		child := buildRowFromAnnotation(provider, key, i+1, annotation)
+		children = append(children, &child)
+	}
+	return buildRow{
+		key:        key,
+		children:   children,
+		type_:      "J",
+		state:      j.State,
+		name:       name,
+		stage:      stageName,
+		createdAt:  j.CreatedAt,
+		startedAt:  j.StartedAt,
+		finishedAt: j.FinishedAt,
+		updatedAt:  utils.MaxNullTime(j.FinishedAt, j.StartedAt, j.CreatedAt),
+		url:        j.WebURL,
+		duration:   j.Duration,
+		provider:   provider.Name,
+		os:         j.OS,
+		arch:       j.Arch,
+		language:   j.Language,
+	}
+}
+
+func buildRowFromDeployment(provider cache.Provider, sha string, ref string, buildID string, d cache.Deployment) buildRow {
+	name := d.Environment
+	if name == "" {
+		name = d.ID
+	}
+	return buildRow{
+		key: buildRowKey{
+			ref:          ref,
+			sha:          sha,
+			accountID:    provider.ID,
+			buildID:      buildID,
+			deploymentID: d.ID,
+		},
+		type_:      "D",
+		state:      d.State,
+		name:       name,
+		createdAt:  d.CreatedAt,
+		finishedAt: d.FinishedAt,
+		updatedAt:  utils.MaxNullTime(d.FinishedAt, d.CreatedAt),
+		url:        d.WebURL,
+		duration:   utils.NullSub(d.FinishedAt, d.CreatedAt),
+		provider:   provider.Name,
+	}
+}
+
+// Return the row of the annotation at position 'i' among the annotations of the job whose key is
+// 'jobKey'. Annotations are named after their location in the code, and only failures have a
+// state.
+func buildRowFromAnnotation(provider cache.Provider, jobKey buildRowKey, i int, a cache.CodeAnnotation) buildRow {
+	key := jobKey
+	key.annotation = i
+	name := fmt.Sprintf("%s:%d: %s", a.Path, a.StartLine, firstLine(a.Message))
+	state := cache.Failed
+	if a.Level != "failure" {
+		state = cache.Unknown
+		name = fmt.Sprintf("%s: %s", a.Level, name)
+	}
+	return buildRow{
+		key:      key,
+		type_:    "A",
+		state:    state,
+		name:     name,
+		url:      a.WebURL,
+		provider: provider.Name,
+	}
+}
+
+// Return the row of the problem at position 'i' among the problems found in the log of the job
+// whose row is 'job'
+func buildRowFromProblem(job buildRow, i int, p Problem) buildRow {
+	key := job.key
+	key.problem = i
+	return buildRow{
+		key:      key,
+		type_:    "E",
+		state:    cache.Failed,
+		name:     p.String(),
+		url:      job.url,
+		provider: job.provider,
+	}
+}
+
+// Return the first line of 's'
+func firstLine(s string) string {
+	if i := strings.Index(s, "\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// OptionalColumns lists the columns hidden unless requested by the user. They describe the build
+// matrix of jobs.
+var OptionalColumns = []string{"OS", "ARCH", "LANGUAGE"}
+
+// Columns lists the columns of the table of pipelines, optional columns included
+var Columns = []string{"REF", "PROVIDER", "PIPELINE", "STAGE", "TYPE", "STATE", "PROGRESS", "CREATED", "DURATION", "ETA", "TREND", "OS", "ARCH", "LANGUAGE", "NAME"}
+
+// BuildsByCommit presents the builds stored in a cache as a table of pipelines, stages and jobs
+type BuildsByCommit struct {
+	cache     cache.Cache
+	icons     StateIcons
+	templates RowTemplates
+	columns   []string
+	// Show jobs as direct children of their pipeline instead of grouping them by stage
+	flat bool
+	// List the jobs of all pipelines in a flat table instead of a tree
+	jobsOnly bool
+	// Column used to sort rows, empty to keep the default order
+	sortColumn string
+	reverse    bool
+	// Problem matchers applied to the logs written to disk and the problems they found
+	matchers []ProblemMatcher
+	problems *problemsByJob
+	// Test reports fetched for jobs
+	testReports *testReportsByJob
+	// Presentation of the timestamps prefixing the lines of the logs written to disk
+	timestamps TimestampMode
+	// Prefix each line of the logs written to disk with the time elapsed since the start of the
+	// job
+	gutter bool
+}
+
+func NewBuildsByCommit(c *cache.Cache) BuildsByCommit {
+	return BuildsByCommit{
+		cache:       *c,
+		matchers:    DefaultProblemMatchers,
+		problems:    newProblemsByJob(),
+		testReports: newTestReportsByJob(),
+		timestamps:  TimestampsKeep,
+	}
+}
+
+// SetStateIcons selects the glyphs shown next to the state of each row. A nil value disables
+// icons.
+func (s *BuildsByCommit) SetStateIcons(icons StateIcons) {
+	s.icons = icons
+}
+
+// SetRowTemplates selects the templates used to compute the NAME column of each type of row
+func (s *BuildsByCommit) SetRowTemplates(templates RowTemplates) {
+	s.templates = templates
+}
+
+// SetProblemMatchers selects the problem matchers applied to the logs of jobs. The problems
+// found in the log of a job are listed under the job once the log has been fetched.
+func (s *BuildsByCommit) SetProblemMatchers(matchers []ProblemMatcher) {
+	s.matchers = matchers
+}
+
+// SetTimestampMode selects how the timestamps prefixing the lines of logs are written to disk
+func (s *BuildsByCommit) SetTimestampMode(mode TimestampMode) {
+	s.timestamps = mode
+}
+
+// SetTimingGutter selects whether each line of the logs written to disk is prefixed with the time
+// elapsed since the start of the job, as far as the timestamps and the markers of the log tell
+func (s *BuildsByCommit) SetTimingGutter(show bool) {
+	s.gutter = show
+}
+
+// SetColumns selects the optional columns shown before the NAME column. Each column must be
+// listed by OptionalColumns.
+func (s *BuildsByCommit) SetColumns(columns []string) {
+	s.columns = columns
+}
+
+// SetGrouped selects whether jobs are grouped by stage or listed directly under their pipeline
+func (s *BuildsByCommit) SetGrouped(grouped bool) {
+	s.flat = !grouped
+}
+
+// SetJobsOnly selects whether the table lists the jobs of all pipelines without their pipelines
+// and stages
+func (s *BuildsByCommit) SetJobsOnly(jobsOnly bool) {
+	s.jobsOnly = jobsOnly
+}
+
+// SetSort selects the column used to sort rows. Each row is sorted among its siblings. An empty
+// column restores the default order where pipelines are sorted by creation date, 'reverse'
+// then only applies to the top-level rows.
+func (s *BuildsByCommit) SetSort(column string, reverse bool) {
+	s.sortColumn = column
+	s.reverse = reverse
+}
+
+func (s BuildsByCommit) Headers() []string {
+	if s.jobsOnly {
+		headers := []string{"PROVIDER", "PIPELINE", "STAGE", "STATE", "CREATED", "DURATION", "TREND"}
+		headers = append(headers, s.columns...)
+		return append(headers, "NAME")
+	}
+	headers := []string{"REF", "PIPELINE", "TYPE", "STATE", "PROGRESS", "CREATED", "DURATION", "ETA", "TREND"}
+	headers = append(headers, s.columns...)
+	return append(headers, "NAME")
+}
+
+func (s BuildsByCommit) Alignment() map[string]text.Alignment {
+	return map[string]text.Alignment{
+		"REF":      text.Left,
+		"PROVIDER": text.Left,
+		"PIPELINE": text.Right,
+		"STAGE":    text.Left,
+		"TYPE":     text.Right,
+		"STATE":    text.Left,
+		"PROGRESS": text.Right,
+		"CREATED":  text.Left,
+		"STARTED":  text.Left,
+		"UPDATED":  text.Left,
+		"DURATION": text.Right,
+		"ETA":      text.Right,
+		"TREND":    text.Right,
+		"OS":       text.Left,
+		"ARCH":     text.Left,
+		"LANGUAGE": text.Left,
+		"NAME":     text.Left,
+	}
+}
+
+// Summary counts the pipelines of the cache by state
+func (s BuildsByCommit) Summary() Summary {
+	return NewSummary("", "", s.cache.Builds(), time.Now())
+}
+
+// Health returns the outcome of the last request made to each provider
+func (s BuildsByCommit) Health() []cache.ProviderHealth {
+	return s.cache.Health()
+}
+
+func (s BuildsByCommit) Rows() []HierarchicalTabularSourceRow {
+	now := time.Now()
+	buildRows := make([]*buildRow, 0)
+	for _, build := range s.cache.Builds() {
+		row := buildRowFromBuild(build)
+		s.addProblems(&row)
+		s.addTestReports(&row)
+		if s.flat {
+			row.flattenStages()
+		}
+		row.setIcons(s.icons)
+		row.setTemplates(s.templates)
+		row.setAverages(s.cache)
+		row.setETA(now)
+		buildRows = append(buildRows, &row)
+	}
+
+	sort.Slice(buildRows, func(i, j int) bool {
+		ri, rj := buildRows[i], buildRows[j]
+		ti := utils.MinNullTime(
+			ri.createdAt,
+			ri.startedAt,
+			ri.updatedAt,
+			ri.finishedAt)
+
+		tj := utils.MinNullTime(
+			rj.createdAt,
+			rj.startedAt,
+			rj.updatedAt,
+			rj.finishedAt)
+
+		return ti.Time.Before(tj.Time)
+	})
+
+	if s.jobsOnly {
+		jobRows := make([]*buildRow, 0)
+		for _, row := range buildRows {
+			for _, node := range utils.DepthFirstTraversal(row, true) {
+				if job := node.(*buildRow); job.type_ == "J" {
+					job.children = nil
+					jobRows = append(jobRows, job)
+				}
+			}
+		}
+		buildRows = jobRows
+	}
+
+	if s.sortColumn != "" {
+		sortRows(buildRows, s.sortColumn, s.reverse)
+	} else if s.reverse {
+		for i, j := 0, len(buildRows)-1; i < j; i, j = i+1, j-1 {
+			buildRows[i], buildRows[j] = buildRows[j], buildRows[i]
+		}
+	}
+
+	rows := make([]HierarchicalTabularSourceRow, 0, len(buildRows))
+	for _, row := range buildRows {
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// Order in which states are sorted so that failures come first
+var stateOrder = map[cache.State]int{
+	cache.Failed:   0,
+	cache.Canceled: 1,
+	cache.Running:  2,
+	cache.Pending:  3,
+	cache.Manual:   4,
+	cache.Skipped:  5,
+	cache.Passed:   6,
+	cache.Unknown:  7,
+}
+
+// Compare the values of two rows in the column 'column'. The result is negative if 'a' comes
+// first, positive if 'b' comes first and zero otherwise. Null values come last.
+func compareRows(a *buildRow, b *buildRow, column string) int {
+	compareStrings := func(x string, y string) int {
+		return strings.Compare(strings.ToLower(x), strings.ToLower(y))
+	}
+	compareNull := func(validX bool, validY bool) (int, bool) {
+		switch {
+		case validX && validY:
+			return 0, false
+		case validX:
+			return -1, true
+		case validY:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	switch column {
+	case "REF":
+		return compareStrings(a.key.ref, b.key.ref)
+	case "PROVIDER":
+		return compareStrings(a.provider, b.provider)
+	case "PIPELINE":
+		x, errX := strconv.Atoi(a.key.buildID)
+		y, errY := strconv.Atoi(b.key.buildID)
+		if errX == nil && errY == nil {
+			return x - y
+		}
+		return compareStrings(a.key.buildID, b.key.buildID)
+	case "TYPE":
+		return compareStrings(a.type_, b.type_)
+	case "STAGE":
+		return compareStrings(a.stage, b.stage)
+	case "STATE":
+		return stateOrder[a.state] - stateOrder[b.state]
+	case "PROGRESS":
+		// Least advanced first
+		return a.finished*utils.MaxInt(b.total, 1) - b.finished*utils.MaxInt(a.total, 1)
+	case "CREATED":
+		if c, null := compareNull(a.createdAt.Valid, b.createdAt.Valid); null {
+			return c
+		}
+		switch {
+		case a.createdAt.Time.Before(b.createdAt.Time):
+			return -1
+		case b.createdAt.Time.Before(a.createdAt.Time):
+			return 1
+		}
+		return 0
+	case "DURATION":
+		if c, null := compareNull(a.duration.Valid, b.duration.Valid); null {
+			return c
+		}
+		switch {
+		case a.duration.Duration < b.duration.Duration:
+			return -1
+		case a.duration.Duration > b.duration.Duration:
+			return 1
+		}
+		return 0
+	case "ETA":
+		if c, null := compareNull(a.eta.Valid, b.eta.Valid); null {
+			return c
+		}
+		switch {
+		case a.eta.Duration < b.eta.Duration:
+			return -1
+		case a.eta.Duration > b.eta.Duration:
+			return 1
+		}
+		return 0
+	case "TREND":
+		ratio := func(r *buildRow) (float64, bool) {
+			if !r.average.Valid || r.average.Duration <= 0 || !r.duration.Valid || r.state.IsActive() {
+				return 0, false
+			}
+			return float64(r.duration.Duration) / float64(r.average.Duration), true
+		}
+		x, validX := ratio(a)
+		y, validY := ratio(b)
+		if c, null := compareNull(validX, validY); null {
+			return c
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case "OS":
+		return compareStrings(a.os, b.os)
+	case "ARCH":
+		return compareStrings(a.arch, b.arch)
+	case "LANGUAGE":
+		return compareStrings(a.language, b.language)
+	case "NAME":
+		return compareStrings(a.name, b.name)
+	}
+
+	return 0
+}
+
+// Sort rows and their descendants among their siblings by the values of the column 'column'.
+// Rows with equal values keep their relative order.
+func sortRows(rows []*buildRow, column string, reverse bool) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		c := compareRows(rows[i], rows[j], column)
+		if reverse {
+			return c > 0
+		}
+		return c < 0
+	})
+	for _, row := range rows {
+		sortRows(row.children, column, reverse)
+	}
+}
+
+func (s BuildsByCommit) WriteToDisk(ctx context.Context, key interface{}, dir string) (string, error) {
+	// TODO Allow filtering for errored jobs
+	buildKey, ok := key.(buildRowKey)
+	if !ok {
+		return "", fmt.Errorf("key conversion to buildRowKey failed: '%v'", key)
+	}
+
+	if buildKey.jobID == "" {
+		return "", cache.ErrNoLogHere
+	}
+
+	pattern := fmt.Sprintf("job_%s_*.log", buildKey.jobID)
+	file, err := ioutil.TempFile(dir, pattern)
+	w := utils.NewANSIStripper(file)
+	defer w.Close()
+	if err != nil {
+		return "", err
+	}
+	logPath := path.Join(dir, filepath.Base(file.Name()))
+
+	shown, err := s.renderLog(ctx, buildKey)
+	if err != nil {
+		return logPath, err
+	}
+	if _, err = w.Write([]byte(shown)); err != nil {
+		return logPath, err
+	}
+
+	return logPath, nil
+}
+
+// Return the log of the job of 'key' as shown to the user and record the problems found in it.
+// The last line of the log of an active job is left out until it is complete.
+func (s BuildsByCommit) renderLog(ctx context.Context, key buildRowKey) (string, error) {
+	raw, err := s.cache.Log(ctx, key.accountID, key.buildID, key.stageID, key.jobID)
+	if err != nil {
+		return "", err
+	}
+	job, exists := s.cache.Job(key.accountID, key.buildID, key.stageID, key.jobID)
+	switch {
+	case exists && job.State.IsActive():
+		raw = raw[:strings.LastIndex(raw, "\n")+1]
+	case !strings.HasSuffix(raw, "\n"):
+		raw = raw + "\n"
+	}
+	log := utils.PostProcess(raw)
+
+	shown := formatTimestamps(log, s.timestamps, job.StartedAt)
+	if s.gutter {
+		// Times are read from the raw log since post-processing removes the markers of
+		// sections, lines are preserved
+		shown = addTimingGutter(shown, lineTimes(raw), job.StartedAt)
+	}
+	// Timestamps would prevent problem matchers from matching the beginning of lines
+	s.problems.set(key, FindProblems(formatTimestamps(log, TimestampsHide, job.StartedAt), s.matchers))
+
+	return shown, nil
+}
+
+// LogRunning returns true if the log of the row designated by 'key' belongs to a job that is
+// still active
+func (s BuildsByCommit) LogRunning(key interface{}) bool {
+	buildKey, ok := key.(buildRowKey)
+	if !ok || buildKey.jobID == "" {
+		return false
+	}
+	job, exists := s.cache.Job(buildKey.accountID, buildKey.buildID, buildKey.stageID, buildKey.jobID)
+	return exists && job.State.IsActive()
+}
+
+// FollowLog appends to the file at 'logPath', written by WriteToDisk, the lines added to the log
+// of the job designated by 'key'. The job and its log are fetched every 'interval' until the job
+// is finished or 'ctx' is done.
+func (s BuildsByCommit) FollowLog(ctx context.Context, key interface{}, logPath string, interval time.Duration) error {
+	buildKey, ok := key.(buildRowKey)
+	if !ok {
+		return fmt.Errorf("key conversion to buildRowKey failed: '%v'", key)
+	}
+	if buildKey.jobID == "" {
+		return cache.ErrNoLogHere
+	}
+
+	content, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		return err
+	}
+	written := strings.Count(string(content), "\n")
+	file, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	w := utils.NewANSIStripper(file)
+	defer w.Close()
+
+	for {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		job, err := s.cache.RefreshJob(ctx, buildKey.accountID, buildKey.buildID, buildKey.stageID, buildKey.jobID)
+		if err != nil {
+			return err
+		}
+		shown, err := s.renderLog(ctx, buildKey)
+		if err != nil {
+			return err
+		}
+		// Lines already written are left untouched, the last element is the empty string
+		// following the last newline
+		lines := strings.SplitAfter(shown, "\n")
+		if n := len(lines) - 1; n > written {
+			if _, err := w.Write([]byte(strings.Join(lines[written:n], ""))); err != nil {
+				return err
+			}
+			written = n
+		}
+
+		if !job.State.IsActive() {
+			return nil
+		}
+	}
+}
+
+// List the problems found in the log of each job of 'row' under the job
+func (s BuildsByCommit) addProblems(row *buildRow) {
+	for _, node := range utils.DepthFirstTraversal(row, true) {
+		job := node.(*buildRow)
+		if job.type_ != "J" {
+			continue
+		}
+		for i, problem := range s.problems.get(job.key) {
+			child := buildRowFromProblem(*job, i+1, problem)
+			job.children = append(job.children, &child)
+		}
+	}
+}
+
+// Problems returns the problems found in the log of the job of the row designated by 'key'
+// when it was last written to disk
+func (s BuildsByCommit) Problems(key interface{}) []Problem {
+	buildKey, ok := key.(buildRowKey)
+	if !ok || buildKey.jobID == "" {
+		return nil
+	}
+	return s.problems.get(buildKey)
+}
+
+// LogLine returns the line of the log of the problem designated by 'key', starting at 1, or 0 if
+// 'key' does not designate a problem
+func (s BuildsByCommit) LogLine(key interface{}) int {
+	buildKey, ok := key.(buildRowKey)
+	if !ok || buildKey.problem == 0 {
+		return 0
+	}
+	problems := s.problems.get(buildKey)
+	if buildKey.problem > len(problems) {
+		return 0
+	}
+	return problems[buildKey.problem-1].LogLine
+}
+
+var ErrNoDetailsHere = errors.New("no details are associated to this row")
+
+// Details describes the pipeline, job, deployment, annotation, problem or test designated by
+// 'key'. Jobs are described along with their variables and the commands they execute, if their
+// provider exposes them.
+func (s BuildsByCommit) Details(key interface{}) (string, error) {
+	buildKey, ok := key.(buildRowKey)
+	if !ok {
+		return "", fmt.Errorf("key conversion to buildRowKey failed: '%v'", key)
+	}
+	if buildKey.deploymentID != "" {
+		deployment, exists := s.cache.Deployment(buildKey.accountID, buildKey.buildID, buildKey.deploymentID)
+		if !exists {
+			return "", ErrNoDetailsHere
+		}
+		return deploymentDetails(deployment), nil
+	}
+	if buildKey.jobID == "" {
+		if buildKey.stageID != 0 {
+			return "", ErrNoDetailsHere
+		}
+		build, exists := s.cache.Build(buildKey.accountID, buildKey.buildID)
+		if !exists {
+			return "", ErrNoDetailsHere
+		}
+		return pipelineDetails(build, time.Now()), nil
+	}
+	job, exists := s.cache.Job(buildKey.accountID, buildKey.buildID, buildKey.stageID, buildKey.jobID)
+	if !exists {
+		return "", ErrNoDetailsHere
+	}
+	if i := buildKey.annotation; i > 0 {
+		if i > len(job.Annotations) {
+			return "", ErrNoDetailsHere
+		}
+		return annotationDetails(job.Annotations[i-1]), nil
+	}
+	if i := buildKey.problem; i > 0 {
+		problems := s.problems.get(buildKey)
+		if i > len(problems) {
+			return "", ErrNoDetailsHere
+		}
+		return problemDetails(problems[i-1]), nil
+	}
+	if buildKey.testReport {
+		report, exists := s.testReports.get(buildKey)
+		switch {
+		case !exists || buildKey.test > len(report.Failures):
+			return "", ErrNoDetailsHere
+		case buildKey.test > 0:
+			return testCaseDetails(report.Failures[buildKey.test-1]), nil
+		default:
+			return testReportDetails(job, report), nil
+		}
+	}
+
+	return jobDetails(job), nil
+}
+
+// Return the description of an annotation along with its full message
+func annotationDetails(a cache.CodeAnnotation) string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "Annotation: %s\n", a.Level)
+	location := fmt.Sprintf("%s:%d", a.Path, a.StartLine)
+	if a.EndLine > a.StartLine {
+		location += fmt.Sprintf("-%d", a.EndLine)
+	}
+	fmt.Fprintf(&b, "File:       %s\n", location)
+	if a.Title != "" {
+		fmt.Fprintf(&b, "Title:      %s\n", a.Title)
+	}
+	if a.WebURL != "" {
+		fmt.Fprintf(&b, "URL:        %s\n", a.WebURL)
+	}
+	fmt.Fprintf(&b, "\n%s\n", strings.TrimRight(a.Message, "\n"))
+
+	return b.String()
+}
+
+// Return the description of a problem found in a log
+func problemDetails(p Problem) string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "Problem:  %s\n", p.Matcher)
+	if p.File != "" {
+		location := p.File
+		if p.Line > 0 {
+			location += fmt.Sprintf(":%d", p.Line)
+		}
+		fmt.Fprintf(&b, "File:     %s\n", location)
+	}
+	fmt.Fprintf(&b, "Log line: %d\n", p.LogLine)
+	fmt.Fprintf(&b, "\n%s\n", p.Message)
+
+	return b.String()
+}
+
+var ErrNoDeploymentHere = errors.New("no deployment is associated to this row")
+
+// Deployment returns the deployment designated by 'key'
+func (s BuildsByCommit) Deployment(key interface{}) (cache.Deployment, error) {
+	buildKey, ok := key.(buildRowKey)
+	if !ok {
+		return cache.Deployment{}, fmt.Errorf("key conversion to buildRowKey failed: '%v'", key)
+	}
+	if buildKey.deploymentID == "" {
+		return cache.Deployment{}, ErrNoDeploymentHere
+	}
+	deployment, exists := s.cache.Deployment(buildKey.accountID, buildKey.buildID, buildKey.deploymentID)
+	if !exists {
+		return cache.Deployment{}, ErrNoDeploymentHere
+	}
+	return deployment, nil
+}
+
+// StopEnvironment stops the environment of the deployment designated by 'key'
+func (s BuildsByCommit) StopEnvironment(ctx context.Context, key interface{}) error {
+	if _, err := s.Deployment(key); err != nil {
+		return err
+	}
+	buildKey := key.(buildRowKey)
+	return s.cache.StopEnvironment(ctx, buildKey.accountID, buildKey.buildID, buildKey.deploymentID)
+}
+
+// RetryDeployment runs again the job of the deployment designated by 'key'
+func (s BuildsByCommit) RetryDeployment(ctx context.Context, key interface{}) error {
+	if _, err := s.Deployment(key); err != nil {
+		return err
+	}
+	buildKey := key.(buildRowKey)
+	return s.cache.RetryDeployment(ctx, buildKey.accountID, buildKey.buildID, buildKey.deploymentID)
+}
+
+// Return the name of the pipeline used in messages, the same as the name of its row
+func pipelineDescription(build cache.Build) string {
+	if _, err := strconv.Atoi(build.ID); err == nil {
+		return fmt.Sprintf("pipeline #%s", build.ID)
+	}
+	return fmt.Sprintf("pipeline %s", build.ID)
+}
+
+var ErrNoPipelineHere = errors.New("no pipeline is associated to this row")
+
+// Restartable returns a description of the pipeline that the row designated by 'key' belongs
+// to. The description is returned along with cache.ErrActionNotPermitted if the pipeline is
+// still active.
+func (s BuildsByCommit) Restartable(key interface{}) (string, error) {
+	buildKey, ok := key.(buildRowKey)
+	if !ok {
+		return "", fmt.Errorf("key conversion to buildRowKey failed: '%v'", key)
+	}
+	build, exists := s.cache.Build(buildKey.accountID, buildKey.buildID)
+	if !exists {
+		return "", ErrNoPipelineHere
+	}
+	if build.State.IsActive() {
+		return pipelineDescription(build), cache.ErrActionNotPermitted
+	}
+	return pipelineDescription(build), nil
+}
+
+// Restart runs again the pipeline that the row designated by 'key' belongs to and returns the
+// key of the row of the pipeline started, nil if it is not known yet
+func (s BuildsByCommit) Restart(ctx context.Context, key interface{}) (interface{}, error) {
+	if _, err := s.Restartable(key); err != nil {
+		return nil, err
+	}
+	buildKey := key.(buildRowKey)
+	u, err := s.cache.RestartBuild(ctx, buildKey.accountID, buildKey.buildID)
+	if err != nil {
+		return nil, err
+	}
+	for _, build := range s.cache.Builds() {
+		if build.WebURL == u && build.Repository != nil {
+			return buildRowFromBuild(build).key, nil
+		}
+	}
+	return nil, nil
+}
+
+var ErrNoJobHere = errors.New("no job is associated to this row")
+
+// Return the job of the job row designated by 'key'
+func (s BuildsByCommit) job(key interface{}) (cache.Job, error) {
+	buildKey, ok := key.(buildRowKey)
+	if !ok {
+		return cache.Job{}, fmt.Errorf("key conversion to buildRowKey failed: '%v'", key)
+	}
+	if buildKey.jobID == "" || buildKey.deploymentID != "" || buildKey.annotation > 0 || buildKey.problem > 0 || buildKey.testReport {
+		return cache.Job{}, ErrNoJobHere
+	}
+	job, exists := s.cache.Job(buildKey.accountID, buildKey.buildID, buildKey.stageID, buildKey.jobID)
+	if !exists {
+		return cache.Job{}, ErrNoJobHere
+	}
+	return job, nil
+}
+
+// Retryable returns a description of the job designated by 'key'. The description is returned
+// along with cache.ErrActionNotPermitted if the job is still active.
+func (s BuildsByCommit) Retryable(key interface{}) (string, error) {
+	job, err := s.job(key)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("job %q", job.Name)
+	if job.State.IsActive() {
+		return name, cache.ErrActionNotPermitted
+	}
+	return name, nil
+}
+
+// Retry runs again the job designated by 'key' and returns the key of the row of the job
+// started
+func (s BuildsByCommit) Retry(ctx context.Context, key interface{}) (interface{}, error) {
+	if _, err := s.Retryable(key); err != nil {
+		return nil, err
+	}
+	buildKey := key.(buildRowKey)
+	id, err := s.cache.RetryJob(ctx, buildKey.accountID, buildKey.buildID, buildKey.stageID, buildKey.jobID)
+	if err != nil {
+		return nil, err
+	}
+	buildKey.jobID = id
+	return buildKey, nil
+}
+
+// ManualJob returns a description of the job designated by 'key'. The description is returned
+// along with cache.ErrActionNotPermitted if the job does not wait for the action of a user.
+func (s BuildsByCommit) ManualJob(key interface{}) (string, error) {
+	job, err := s.job(key)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("job %q", job.Name)
+	if job.State != cache.Manual {
+		return name, cache.ErrActionNotPermitted
+	}
+	return name, nil
+}
+
+// StartManualJob starts the job designated by 'key', which waits for the action of a user
+func (s BuildsByCommit) StartManualJob(ctx context.Context, key interface{}) error {
+	if _, err := s.ManualJob(key); err != nil {
+		return err
+	}
+	buildKey := key.(buildRowKey)
+	return s.cache.StartManualJob(ctx, buildKey.accountID, buildKey.buildID, buildKey.stageID, buildKey.jobID)
+}
+
+// TriggerPipelines starts a pipeline on 'ref' of the repository at 'repositoryURL' with every
+// provider able to do so. The pipelines are shown once the cache receives them.
+func (s BuildsByCommit) TriggerPipelines(ctx context.Context, repositoryURL string, ref string) ([]string, error) {
+	return s.cache.TriggerPipelines(ctx, repositoryURL, ref, nil)
+}
+
+var ErrNothingToCancelHere = errors.New("no active pipeline or job is associated to this row")
+
+// Cancelable returns a description of the pipeline or the job designated by 'key' if it is still
+// active. Stages cannot be canceled on their own.
+func (s BuildsByCommit) Cancelable(key interface{}) (string, error) {
+	buildKey, ok := key.(buildRowKey)
+	if !ok {
+		return "", fmt.Errorf("key conversion to buildRowKey failed: '%v'", key)
+	}
+	if buildKey.deploymentID != "" || buildKey.annotation > 0 || buildKey.problem > 0 || buildKey.testReport {
+		return "", ErrNothingToCancelHere
+	}
+	build, exists := s.cache.Build(buildKey.accountID, buildKey.buildID)
+	if !exists {
+		return "", ErrNothingToCancelHere
+	}
+
+	switch {
+	case buildKey.jobID != "":
+		job, exists := build.Get(buildKey.stageID, buildKey.jobID)
+		if !exists || !job.State.IsActive() {
+			return "", ErrNothingToCancelHere
+		}
+		return fmt.Sprintf("job %q", job.Name), nil
+	case buildKey.stageID == 0:
+		if !build.State.IsActive() {
+			return "", ErrNothingToCancelHere
+		}
+		return pipelineDescription(build), nil
+	default:
+		return "", ErrNothingToCancelHere
+	}
+}
+
+// Cancel cancels the pipeline or the job designated by 'key'
+func (s BuildsByCommit) Cancel(ctx context.Context, key interface{}) error {
+	if _, err := s.Cancelable(key); err != nil {
+		return err
+	}
+	buildKey := key.(buildRowKey)
+	if buildKey.jobID != "" {
+		return s.cache.CancelJob(ctx, buildKey.accountID, buildKey.buildID, buildKey.stageID, buildKey.jobID)
+	}
+	return s.cache.CancelBuild(ctx, buildKey.accountID, buildKey.buildID)
+}
+
+// Return the description of a deployment along with the actions it permits
+func deploymentDetails(d cache.Deployment) string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "Deployment: %s\n", d.ID)
+	fmt.Fprintf(&b, "Environment: %s\n", d.Environment)
+	fmt.Fprintf(&b, "State:      %s\n", d.State)
+	if d.JobID != "" {
+		fmt.Fprintf(&b, "Job:        %s\n", d.JobID)
+	}
+	if d.WebURL != "" {
+		fmt.Fprintf(&b, "URL:        %s\n", d.WebURL)
+	}
+	actions := make([]string, 0, 2)
+	if d.CanStop {
+		actions = append(actions, "stop environment")
+	}
+	if d.CanRetry {
+		actions = append(actions, "retry")
+	}
+	if len(actions) == 0 {
+		actions = append(actions, "none")
+	}
+	fmt.Fprintf(&b, "Actions:    %s\n", strings.Join(actions, ", "))
+
+	return b.String()
+}
+
+// Return the description of a pipeline along with the aggregated state of its jobs. The elapsed
+// time of active pipelines is computed up to 'now'.
+func pipelineDetails(build cache.Build, now time.Time) string {
+	jobs := pipelineJobs(build)
+	start := utils.MinNullTime(build.StartedAt, build.CreatedAt)
+	end := build.FinishedAt
+	if build.State.IsActive() || !end.Valid {
+		end = utils.NullTime{Time: now, Valid: true}
+	}
+	jobTime := utils.NullDuration{Valid: true}
+	counts := make(map[cache.State]int)
+	for _, job := range jobs {
+		counts[job.State]++
+		if job.Duration.Valid {
+			jobTime.Duration += job.Duration.Duration
+		}
+	}
+
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "Pipeline: %s\n", build.ID)
+	fmt.Fprintf(&b, "Provider: %s\n", build.Repository.Provider.Name)
+	fmt.Fprintf(&b, "State:    %s\n", build.State)
+	fmt.Fprintf(&b, "Duration: %s\n", build.Duration.String())
+	fmt.Fprintf(&b, "Elapsed:  %s\n", utils.NullSub(end, start).String())
+	fmt.Fprintf(&b, "Job time: %s (sum of the durations of all jobs)\n", jobTime.String())
+	fmt.Fprintf(&b, "Progress: %d/%d jobs finished\n", countFinished(jobs), len(jobs))
+	if build.WebURL != "" {
+		fmt.Fprintf(&b, "URL:      %s\n", build.WebURL)
+	}
+
+	if len(counts) > 0 {
+		states := make([]cache.State, 0, len(counts))
+		for state := range counts {
+			states = append(states, state)
+		}
+		sort.Slice(states, func(i, j int) bool {
+			return stateOrder[states[i]] < stateOrder[states[j]]
+		})
+		b.WriteString("\nJobs by state:\n")
+		for _, state := range states {
+			name := string(state)
+			if state == cache.Unknown {
+				name = "unknown"
+			}
+			fmt.Fprintf(&b, "  %-9s %d\n", name, counts[state])
+		}
+	}
+
+	for _, annotation := range build.Annotations {
+		fmt.Fprintf(&b, "\n[%s] %s\n", annotation.Style, annotation.Title)
+		for _, line := range strings.Split(annotationText(annotation.Body), "\n") {
+			if line == "" {
+				b.WriteString("\n")
+			} else {
+				fmt.Fprintf(&b, "  %s\n", line)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+var htmlLineBreak = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|pre|h[1-6])>`)
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// Return the body of an annotation as plain text. Markdown is readable as is but HTML tags are
+// removed, keeping the line breaks they imply.
+func annotationText(body string) string {
+	body = strings.Replace(body, "\r\n", "\n", -1)
+	body = htmlLineBreak.ReplaceAllString(body, "\n")
+	body = html.UnescapeString(htmlTag.ReplaceAllString(body, ""))
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	body = blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.Trim(body, "\n")
+}
+
+func jobDetails(job cache.Job) string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "Job:      %s\n", job.Name)
+	fmt.Fprintf(&b, "ID:       %s\n", job.ID)
+	fmt.Fprintf(&b, "State:    %s\n", job.State)
+	fmt.Fprintf(&b, "Duration: %s\n", job.Duration.String())
+	if job.WebURL != "" {
+		fmt.Fprintf(&b, "URL:      %s\n", job.WebURL)
+	}
+
+	if len(job.Variables) > 0 {
+		b.WriteString("\nVariables:\n")
+		for _, v := range job.Variables {
+			fmt.Fprintf(&b, "  %s=%s\n", v.Name, v.Value)
+		}
+	}
+
+	b.WriteString("\n")
+	if len(job.Steps) == 0 {
+		b.WriteString("The commands executed by this job are not provided by the CI provider.\n")
+		return b.String()
+	}
+	b.WriteString("Commands:\n")
+	name := ""
+	for _, step := range job.Steps {
+		if step.Name != name {
+			name = step.Name
+			if name != "" {
+				fmt.Fprintf(&b, "\n  %s:\n", name)
+			}
+		}
+		lines := strings.Split(strings.TrimRight(step.Command, "\n"), "\n")
+		for j, line := range lines {
+			prefix := "    $ "
+			if j > 0 {
+				prefix = "      "
+			}
+			b.WriteString(prefix + line + "\n")
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,1340 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+var build = cache.Build{
+	Repository: &cache.Repository{
+		Provider: cache.Provider{
+			ID:   "id",
+			Name: "name",
+		},
+		ID:    42,
+		URL:   "github.com/owner/project",
+		Owner: "owner",
+		Name:  "project",
+	},
+	ID: "42",
+	Commit: cache.Commit{
+		Sha:     "c2bb562365d40caec0b37138f73a87b6339a8b7a",
+		Message: "commit title\nline #1\nline #2\nline #3\n",
+		Date: utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2019, 11, 13, 13, 12, 11, 0, time.UTC),
+		},
+	},
+	Ref:             "master",
+	IsTag:           false,
+	RepoBuildNumber: "43",
+	State:           "passed",
+	CreatedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 11, 0, time.UTC),
+	},
+	StartedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 12, 0, time.UTC),
+	},
+	FinishedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 13, 0, time.UTC),
+	},
+	UpdatedAt: time.Date(2019, 11, 13, 13, 12, 13, 0, time.UTC),
+	Duration: utils.NullDuration{
+		Valid:    true,
+		Duration: 3 * time.Second,
+	},
+	WebURL: "example.com/pipeline/42",
+	Stages: map[int]*cache.Stage{
+		stage.ID: &stage,
+	},
+	Jobs: nil,
+}
+
+var buildAsRow = buildRow{
+	key: buildRowKey{
+		ref:       "master",
+		sha:       "c2bb562365d40caec0b37138f73a87b6339a8b7a",
+		accountID: "id",
+		buildID:   "42",
+	},
+	type_:    "P",
+	state:    "passed",
+	name:     "#42",
+	provider: "name",
+	prefix:   "",
+	createdAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 11, 0, time.UTC),
+	},
+	startedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 12, 0, time.UTC),
+	},
+	finishedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 13, 0, time.UTC),
+	},
+	updatedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 13, 0, time.UTC),
+	},
+	duration: utils.NullDuration{
+		Valid:    true,
+		Duration: 3 * time.Second,
+	},
+	url:      "example.com/pipeline/42",
+	finished: 1,
+	total:    1,
+	children: []*buildRow{
+		&stageAsRow,
+	},
+}
+
+var stage = cache.Stage{
+	ID:    1,
+	Name:  "test",
+	State: "passed",
+	Jobs:  []*cache.Job{&job},
+}
+
+var stageAsRow = buildRow{
+	key: buildRowKey{
+		ref:       "master",
+		sha:       "c2bb562365d40caec0b37138f73a87b6339a8b7a",
+		accountID: "id",
+		buildID:   "42",
+		stageID:   1,
+	},
+	type_:    "S",
+	state:    "passed",
+	name:     "test",
+	provider: "name",
+	createdAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 11, 0, time.UTC),
+	},
+	startedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 12, 0, time.UTC),
+	},
+	finishedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 13, 0, time.UTC),
+	},
+	updatedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 13, 0, time.UTC),
+	},
+	duration: utils.NullDuration{
+		Valid:    true,
+		Duration: time.Second,
+	},
+	url:      "example.com/pipeline/42",
+	finished: 1,
+	total:    1,
+	children: []*buildRow{
+		&jobAsRow,
+	},
+}
+
+var job = cache.Job{
+	ID:    "54",
+	State: "passed",
+	Name:  "golang 1.12",
+	CreatedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 11, 0, time.UTC),
+	},
+	StartedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 12, 0, time.UTC),
+	},
+	FinishedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 13, 0, time.UTC),
+	},
+	Duration: utils.NullDuration{
+		Valid:    true,
+		Duration: 3 * time.Second,
+	},
+	Log:          utils.NullString{},
+	WebURL:       "",
+	AllowFailure: false,
+}
+
+var jobAsRow = buildRow{
+	key: buildRowKey{
+		ref:       "master",
+		sha:       "c2bb562365d40caec0b37138f73a87b6339a8b7a",
+		accountID: "id",
+		buildID:   "42",
+		stageID:   1,
+		jobID:     "54",
+	},
+	type_:    "J",
+	state:    "passed",
+	name:     "golang 1.12",
+	stage:    "test",
+	provider: "name",
+	createdAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 11, 0, time.UTC),
+	},
+	startedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 12, 0, time.UTC),
+	},
+	finishedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 13, 0, time.UTC),
+	},
+	updatedAt: utils.NullTime{
+		Valid: true,
+		Time:  time.Date(2019, 11, 13, 13, 12, 13, 0, time.UTC),
+	},
+	duration: utils.NullDuration{
+		Valid:    true,
+		Duration: 3 * time.Second,
+	},
+	url: "",
+}
+
+func Test_buildRowFromJob(t *testing.T) {
+	p := cache.Provider{
+		ID:   "id",
+		Name: "name",
+	}
+	row := buildRowFromJob(p, build.Commit.Sha, build.Ref, build.ID, 1, "test", job)
+	if diff := row.Diff(jobAsRow); diff != "" {
+		t.Log(diff)
+		t.Fail()
+	}
+}
+
+func Test_buildRowFromStage(t *testing.T) {
+	p := cache.Provider{
+		ID:   "id",
+		Name: "name",
+	}
+	row := buildRowFromStage(p, build.Commit.Sha, build.Ref, build.ID, build.WebURL, stage)
+	if diff := row.Diff(stageAsRow); diff != "" {
+		t.Log(diff)
+		t.Fail()
+	}
+}
+
+func Test_buildRowFromBuild(t *testing.T) {
+	row := buildRowFromBuild(build)
+	if diff := row.Diff(buildAsRow); diff != "" {
+		t.Log(diff)
+		t.Fail()
+	}
+}
+
+func TestBuildRow_TreeNode(t *testing.T) {
+	nodes := utils.DepthFirstTraversal(&buildAsRow, true)
+	keys := []interface{}{
+		buildAsRow.Key(),
+		stageAsRow.Key(),
+		jobAsRow.Key(),
+	}
+
+	if len(nodes) != len(keys) {
+		t.Fatalf("expected %d nodes but got %d", len(keys), len(nodes))
+	}
+
+	for i, key := range keys {
+		if nodeKey := nodes[i].(*buildRow).Key(); nodeKey != key {
+			t.Fatalf("expected key %+v but got %+v", key, nodeKey)
+		}
+	}
+}
+
+func Delay(b cache.Build, d time.Duration) cache.Build {
+	b.CreatedAt.Time.Add(d)
+	b.StartedAt.Time.Add(d)
+	b.FinishedAt.Time.Add(d)
+
+	for _, job := range build.Jobs {
+		job.CreatedAt.Time.Add(d)
+		job.StartedAt.Time.Add(d)
+		job.FinishedAt.Time.Add(d)
+	}
+
+	for _, stage := range b.Stages {
+		for _, job := range stage.Jobs {
+			job.CreatedAt.Time.Add(d)
+			job.StartedAt.Time.Add(d)
+			job.FinishedAt.Time.Add(d)
+		}
+	}
+
+	return b
+}
+
+func TestBuildRow_Tabular(t *testing.T) {
+	t.Run("null dates should be replaced by placeholder", func(t *testing.T) {
+		text := buildRow{}.Tabular(time.UTC)
+		for _, column := range []string{"CREATED", "STARTED", "FINISHED", "UPDATED"} {
+			if text[column].String() != "-" {
+				t.Fatalf("expected %q but got %q", "-", text[column])
+			}
+		}
+	})
+
+	t.Run("tabular version of cache.Build", func(t *testing.T) {
+		expected := map[string]string{
+			"COMMIT":   "c2bb562",
+			"PIPELINE": "#42",
+			"CREATED":  "Nov 13 13:12",
+			"DURATION": "3s",
+			"FINISHED": "Nov 13 13:12",
+			"NAME":     "name",
+			"REF":      "master",
+			"STARTED":  "Nov 13 13:12",
+			"STATE":    "passed",
+			"TYPE":     "P",
+			"TREND":    "-",
+			"UPDATED":  "Nov 13 13:12",
+			"OS":       "-",
+			"ARCH":     "-",
+			"LANGUAGE": "-",
+			"PROVIDER": buildAsRow.provider,
+			"STAGE":    "-",
+			"PROGRESS": "1/1",
+			"ETA":      "-",
+		}
+		for column, text := range buildAsRow.Tabular(time.UTC) {
+			if s := text.String(); s != expected[column] {
+				t.Fatalf("expected %q but got %q", expected[column], s)
+			}
+		}
+	})
+}
+
+func TestBuildsByCommit_SetStateIcons(t *testing.T) {
+	c := cache.NewCache(nil, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+
+	source := NewBuildsByCommit(&c)
+	source.SetStateIcons(UnicodeStateIcons)
+	rows := source.Rows()
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row but got %d", len(rows))
+	}
+
+	nodes := utils.DepthFirstTraversal(rows[0].(*buildRow), true)
+	for _, node := range nodes {
+		expected := "✓ passed"
+		if state := node.(*buildRow).Tabular(time.UTC)["STATE"].String(); state != expected {
+			t.Fatalf("expected %q but got %q", expected, state)
+		}
+	}
+}
+
+func TestBuildsByCommit_SetRowTemplates(t *testing.T) {
+	c := cache.NewCache(nil, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+
+	templates := RowTemplates{}
+	var err error
+	if templates.Pipeline, err = NewRowTemplate("pipeline", `{{ style "provider" .Provider }} {{ .Pipeline }}`); err != nil {
+		t.Fatal(err)
+	}
+	if templates.Job, err = NewRowTemplate("job", `{{ .Stage }}: {{ .Name }}`); err != nil {
+		t.Fatal(err)
+	}
+
+	source := NewBuildsByCommit(&c)
+	source.SetRowTemplates(templates)
+	rows := source.Rows()
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row but got %d", len(rows))
+	}
+
+	nodes := utils.DepthFirstTraversal(rows[0].(*buildRow), true)
+	expected := []string{"name #42", "test", "test: golang 1.12"}
+	if len(nodes) != len(expected) {
+		t.Fatalf("expected %d nodes but got %d", len(expected), len(nodes))
+	}
+	for i, node := range nodes {
+		if name := node.(*buildRow).Tabular(time.UTC)["NAME"].String(); name != expected[i] {
+			t.Fatalf("expected %q but got %q", expected[i], name)
+		}
+	}
+}
+
+func TestNewRowTemplate(t *testing.T) {
+	for _, s := range []string{`{{ .Name `, `{{ .Number }}`, `{{ style "blink" .Name }}`} {
+		t.Run(s, func(t *testing.T) {
+			if _, err := NewRowTemplate("test", s); err == nil {
+				t.Fatal("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestBuildsByCommit_Rows(t *testing.T) {
+	c := cache.NewCache(nil, nil)
+	shas := []string{"aaaaaa", "bbbbbb", "cccccc"}
+	ids := []int{1, 2, 4, 8, 16, 32}
+	for i, sha := range shas {
+		for _, n := range ids {
+			build := Delay(build, time.Duration(n)*time.Hour)
+			build.ID = strconv.Itoa(1000*i + n)
+			build.Commit.Sha = sha
+			if err := c.Save(build); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	rows := NewBuildsByCommit(&c).Rows()
+	if len(rows) != len(shas)*len(ids) {
+		t.Fatalf("expected %d row but got %d", len(shas), len(rows))
+	}
+}
+
+func TestBuildsByCommit_WriteToDisk(t *testing.T) {
+	builds := []cache.Build{build}
+	c := cache.NewCache([]cache.CIProvider{
+		mockProvider{
+			id:     "id",
+			builds: builds,
+		},
+	}, nil)
+	for _, build := range builds {
+		if err := c.Save(build); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := NewBuildsByCommit(&c)
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("no log is associated to builds", func(t *testing.T) {
+		_, err := source.WriteToDisk(context.Background(), buildAsRow.Key(), dir)
+		if err != cache.ErrNoLogHere {
+			t.Fatalf("expected %v but got %v", cache.ErrNoLogHere, err)
+		}
+	})
+
+	t.Run("no log is associated to stages", func(t *testing.T) {
+		_, err := source.WriteToDisk(context.Background(), stageAsRow.Key(), dir)
+		if err != cache.ErrNoLogHere {
+			t.Fatalf("expected %v but got %v", cache.ErrNoLogHere, err)
+		}
+	})
+
+	t.Run("log of job must be written to disk", func(t *testing.T) {
+		path, err := source.WriteToDisk(context.Background(), jobAsRow.Key(), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if log := "log\n"; string(p) != log {
+			t.Fatalf("expected %q but got %q", log, string(p))
+		}
+	})
+}
+
+// Provider returning the next log of 'logs' and the next pipeline of 'builds' every time they
+// are fetched
+type mockLogFollower struct {
+	mockProvider
+	logs       []string
+	builds     []cache.Build
+	logCalls   *int
+	buildCalls *int
+}
+
+func (p mockLogFollower) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	log := p.logs[*p.logCalls]
+	*p.logCalls++
+	return log, nil
+}
+
+func (p mockLogFollower) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	build := p.builds[*p.buildCalls]
+	*p.buildCalls++
+	return build, nil
+}
+
+func TestBuildsByCommit_FollowLog(t *testing.T) {
+	// Fixtures are copied since the cache saves the logs of finished jobs in place
+	withState := func(state cache.State) cache.Build {
+		j := job
+		j.State = state
+		j.Log = utils.NullString{}
+		st := stage
+		st.State = state
+		st.Jobs = []*cache.Job{&j}
+		b := build
+		b.State = state
+		b.Stages = map[int]*cache.Stage{st.ID: &st}
+		return b
+	}
+	running := withState(cache.Running)
+	running.UpdatedAt = build.UpdatedAt.Add(-time.Minute)
+	finished := withState(cache.Passed)
+
+	logCalls, buildCalls := 0, 0
+	c := cache.NewCache([]cache.CIProvider{mockLogFollower{
+		mockProvider: mockProvider{id: "id"},
+		logs: []string{
+			"line 1\nline 2 is not",
+			"line 1\nline 2 is now complete\nline 3\n",
+			"line 1\nline 2 is now complete\nline 3\nline 4",
+		},
+		builds:     []cache.Build{running, finished},
+		logCalls:   &logCalls,
+		buildCalls: &buildCalls,
+	}}, nil)
+	if err := c.Save(running); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	if source.LogRunning(buildAsRow.key) {
+		t.Fatal("pipelines have no log to follow")
+	}
+	if !source.LogRunning(jobAsRow.key) {
+		t.Fatal("the log of a running job must be followed")
+	}
+
+	// The incomplete line of the log is left out until the next fetch
+	logPath, err := source.WriteToDisk(ctx, jobAsRow.key, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.FollowLog(ctx, jobAsRow.key, logPath, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "line 1\nline 2 is now complete\nline 3\nline 4\n"
+	if string(content) != expected {
+		t.Fatalf("expected %q but got %q", expected, string(content))
+	}
+	if source.LogRunning(jobAsRow.key) {
+		t.Fatal("the job must be finished once its log has been followed")
+	}
+}
+
+func TestBuildRow_trend(t *testing.T) {
+	duration := func(d time.Duration) utils.NullDuration {
+		return utils.NullDuration{Duration: d, Valid: true}
+	}
+	testCases := []struct {
+		name     string
+		row      buildRow
+		expected string
+	}{
+		{
+			name:     "no history",
+			row:      buildRow{state: cache.Passed, duration: duration(time.Minute)},
+			expected: "-",
+		},
+		{
+			name:     "slower",
+			row:      buildRow{state: cache.Passed, duration: duration(70 * time.Second), average: duration(50 * time.Second)},
+			expected: "+40%",
+		},
+		{
+			name:     "faster",
+			row:      buildRow{state: cache.Failed, duration: duration(45 * time.Second), average: duration(time.Minute)},
+			expected: "-25%",
+		},
+		{
+			name:     "still running",
+			row:      buildRow{state: cache.Running, duration: duration(time.Minute), average: duration(time.Minute)},
+			expected: "-",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if trend := testCase.row.trend().String(); trend != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, trend)
+			}
+		})
+	}
+}
+
+func TestBuildsByCommit_Details(t *testing.T) {
+	c := cache.NewCache(nil, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+
+	if _, err := source.Details(stageAsRow.Key()); err != ErrNoDetailsHere {
+		t.Fatalf("expected %v but got %v", ErrNoDetailsHere, err)
+	}
+	details, err := source.Details(buildAsRow.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(details, "Progress: 1/1 jobs finished") {
+		t.Fatalf("unexpected details %q", details)
+	}
+	details, err = source.Details(jobAsRow.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(details, "are not provided by the CI provider") {
+		t.Fatalf("unexpected details %q", details)
+	}
+}
+
+func TestJobDetails(t *testing.T) {
+	job := cache.Job{
+		ID:     "609256447",
+		Name:   "GoLang 1.13 on Ubuntu Bionic",
+		State:  cache.Failed,
+		WebURL: "https://travis-ci.org/nbedos/citop/jobs/609256447",
+		Duration: utils.NullDuration{
+			Valid:    true,
+			Duration: 83 * time.Second,
+		},
+		Variables: []cache.Variable{
+			{Name: "GO111MODULE", Value: "on"},
+			{Name: "GOFLAGS", Value: "-mod=vendor -v"},
+		},
+		Steps: []cache.Step{
+			{Name: "install", Command: "go mod download"},
+			{Name: "script", Command: "make lint"},
+			{Name: "script", Command: "for f in *.go; do\n  gofmt -l $f\ndone"},
+		},
+	}
+	expected := "" +
+		"Job:      GoLang 1.13 on Ubuntu Bionic\n" +
+		"ID:       609256447\n" +
+		"State:    failed\n" +
+		"Duration: 1m23s\n" +
+		"URL:      https://travis-ci.org/nbedos/citop/jobs/609256447\n" +
+		"\n" +
+		"Variables:\n" +
+		"  GO111MODULE=on\n" +
+		"  GOFLAGS=-mod=vendor -v\n" +
+		"\n" +
+		"Commands:\n" +
+		"\n" +
+		"  install:\n" +
+		"    $ go mod download\n" +
+		"\n" +
+		"  script:\n" +
+		"    $ make lint\n" +
+		"    $ for f in *.go; do\n" +
+		"        gofmt -l $f\n" +
+		"      done\n"
+	if diff := cmp.Diff(expected, jobDetails(job)); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestBuildsByCommit_SetColumns(t *testing.T) {
+	c := cache.NewCache(nil, nil)
+	source := NewBuildsByCommit(&c)
+	source.SetColumns([]string{"OS", "LANGUAGE"})
+
+	expected := []string{"REF", "PIPELINE", "TYPE", "STATE", "PROGRESS", "CREATED", "DURATION", "ETA", "TREND", "OS", "LANGUAGE", "NAME"}
+	if diff := cmp.Diff(expected, source.Headers()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	for _, column := range OptionalColumns {
+		if _, exists := source.Alignment()[column]; !exists {
+			t.Fatalf("no alignment defined for column %q", column)
+		}
+	}
+
+	job := buildRowFromJob(cache.Provider{ID: "travis-0"}, "c2bb562", "master", "42", 0, "", cache.Job{
+		ID:       "1",
+		OS:       "osx",
+		Language: "go 1.13.x",
+	})
+	values := job.Tabular(time.UTC)
+	for column, value := range map[string]string{"OS": "osx", "ARCH": "-", "LANGUAGE": "go 1.13.x"} {
+		if s := values[column].String(); s != value {
+			t.Fatalf("expected %q but got %q", value, s)
+		}
+	}
+}
+
+func TestBuildsByCommit_SetGrouped(t *testing.T) {
+	c := cache.NewCache(nil, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+
+	keys := func() []interface{} {
+		keys := make([]interface{}, 0)
+		for _, row := range source.Rows() {
+			for _, node := range utils.DepthFirstTraversal(row, true) {
+				keys = append(keys, node.(*buildRow).Key())
+			}
+		}
+		return keys
+	}
+
+	testCases := []struct {
+		grouped bool
+		keys    []interface{}
+	}{
+		{
+			grouped: false,
+			keys:    []interface{}{buildAsRow.Key(), jobAsRow.Key()},
+		},
+		{
+			grouped: true,
+			keys:    []interface{}{buildAsRow.Key(), stageAsRow.Key(), jobAsRow.Key()},
+		},
+	}
+
+	for _, testCase := range testCases {
+		source.SetGrouped(testCase.grouped)
+		if diff := cmp.Diff(testCase.keys, keys(), cmp.AllowUnexported(buildRowKey{})); len(diff) > 0 {
+			t.Fatalf("grouped=%v: %s", testCase.grouped, diff)
+		}
+	}
+}
+
+func TestBuildsByCommit_SetJobsOnly(t *testing.T) {
+	c := cache.NewCache(nil, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+	source.SetJobsOnly(true)
+
+	expected := []string{"PROVIDER", "PIPELINE", "STAGE", "STATE", "CREATED", "DURATION", "TREND", "NAME"}
+	if diff := cmp.Diff(expected, source.Headers()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	rows := source.Rows()
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row but got %d", len(rows))
+	}
+	if key := rows[0].Key(); key != jobAsRow.Key() {
+		t.Fatalf("expected key %+v but got %+v", jobAsRow.Key(), key)
+	}
+	if n := len(rows[0].Children()); n != 0 {
+		t.Fatalf("expected no children but got %d", n)
+	}
+	if stage := rows[0].Tabular(time.UTC)["STAGE"].String(); stage != stageAsRow.name {
+		t.Fatalf("expected stage %q but got %q", stageAsRow.name, stage)
+	}
+}
+
+func TestSortRows(t *testing.T) {
+	newRow := func(id string, state cache.State, duration utils.NullDuration) *buildRow {
+		return &buildRow{
+			key:      buildRowKey{buildID: id},
+			name:     "job " + id,
+			state:    state,
+			duration: duration,
+		}
+	}
+	rows := func() []*buildRow {
+		return []*buildRow{
+			newRow("10", cache.Passed, utils.NullDuration{Duration: time.Minute, Valid: true}),
+			newRow("9", cache.Failed, utils.NullDuration{}),
+			newRow("100", cache.Running, utils.NullDuration{Duration: time.Second, Valid: true}),
+		}
+	}
+
+	testCases := []struct {
+		column  string
+		reverse bool
+		ids     []string
+	}{
+		{
+			column: "PIPELINE",
+			ids:    []string{"9", "10", "100"},
+		},
+		{
+			column:  "PIPELINE",
+			reverse: true,
+			ids:     []string{"100", "10", "9"},
+		},
+		{
+			column: "STATE",
+			ids:    []string{"9", "100", "10"},
+		},
+		{
+			column: "DURATION",
+			ids:    []string{"100", "10", "9"},
+		},
+		{
+			column: "NAME",
+			ids:    []string{"10", "100", "9"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(fmt.Sprintf("%s reverse=%v", testCase.column, testCase.reverse), func(t *testing.T) {
+			rs := rows()
+			sortRows(rs, testCase.column, testCase.reverse)
+			ids := make([]string, 0, len(rs))
+			for _, row := range rs {
+				ids = append(ids, row.key.buildID)
+			}
+			if diff := cmp.Diff(testCase.ids, ids); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestPipelineDetails(t *testing.T) {
+	running := cache.Job{ID: "55", Name: "golang 1.13", State: cache.Running}
+	failed := cache.Job{ID: "56", Name: "lint", State: cache.Failed, Duration: utils.NullDuration{Duration: time.Minute, Valid: true}}
+	b := build
+	b.State = cache.Running
+	b.FinishedAt = utils.NullTime{}
+	b.Duration = utils.NullDuration{}
+	b.Jobs = []*cache.Job{&running, &failed}
+
+	now := b.StartedAt.Time.Add(90 * time.Second)
+	expected := "" +
+		"Pipeline: 42\n" +
+		"Provider: name\n" +
+		"State:    running\n" +
+		"Duration: -\n" +
+		"Elapsed:  1m31s\n" +
+		"Job time: 1m03s (sum of the durations of all jobs)\n" +
+		"Progress: 2/3 jobs finished\n" +
+		"URL:      example.com/pipeline/42\n" +
+		"\n" +
+		"Jobs by state:\n" +
+		"  failed    1\n" +
+		"  running   1\n" +
+		"  passed    1\n"
+	if diff := cmp.Diff(expected, pipelineDetails(b, now)); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("Annotations", func(t *testing.T) {
+		b.Annotations = []cache.Annotation{
+			{
+				Title: "Build Failed",
+				Style: "error",
+				Body:  "<a href='https://example.com'><img src='icon.png'> The build</a> **failed**.<br>\n\n\n\n## Jobs\nlint &amp; test",
+			},
+		}
+		suffix := "" +
+			"  passed    1\n" +
+			"\n" +
+			"[error] Build Failed\n" +
+			"   The build **failed**.\n" +
+			"\n" +
+			"  ## Jobs\n" +
+			"  lint & test\n"
+		if details := pipelineDetails(b, now); !strings.HasSuffix(details, suffix) {
+			t.Fatalf("expected details ending with %q but got %q", suffix, details)
+		}
+	})
+}
+
+func TestBuildRow_setETA(t *testing.T) {
+	start := time.Date(2019, 11, 13, 13, 0, 0, 0, time.UTC)
+	now := start.Add(4 * time.Minute)
+	average := func(d time.Duration) utils.NullDuration {
+		return utils.NullDuration{Duration: d, Valid: true}
+	}
+	newPipeline := func(state cache.State, pipelineAverage utils.NullDuration, jobs ...*buildRow) buildRow {
+		return buildRow{
+			type_:     "P",
+			state:     state,
+			startedAt: utils.NullTime{Time: start, Valid: true},
+			average:   pipelineAverage,
+			children:  jobs,
+		}
+	}
+	newJob := func(state cache.State, startedAt time.Time, jobAverage utils.NullDuration) *buildRow {
+		return &buildRow{
+			type_:     "J",
+			state:     state,
+			startedAt: utils.NullTime{Time: startedAt, Valid: !startedAt.IsZero()},
+			average:   jobAverage,
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		row      buildRow
+		expected utils.NullDuration
+	}{
+		{
+			name:     "finished pipeline",
+			row:      newPipeline(cache.Passed, average(10*time.Minute)),
+			expected: utils.NullDuration{},
+		},
+		{
+			name:     "no history",
+			row:      newPipeline(cache.Running, utils.NullDuration{}, newJob(cache.Running, start, utils.NullDuration{})),
+			expected: utils.NullDuration{},
+		},
+		{
+			name:     "average of the pipeline",
+			row:      newPipeline(cache.Running, average(10*time.Minute)),
+			expected: average(6 * time.Minute),
+		},
+		{
+			name: "running job longer than the pipeline",
+			row: newPipeline(cache.Running, average(5*time.Minute),
+				newJob(cache.Running, start.Add(time.Minute), average(8*time.Minute)),
+				newJob(cache.Passed, start, average(30*time.Minute))),
+			expected: average(5 * time.Minute),
+		},
+		{
+			name:     "pending job",
+			row:      newPipeline(cache.Pending, utils.NullDuration{}, newJob(cache.Pending, time.Time{}, average(2*time.Minute))),
+			expected: average(2 * time.Minute),
+		},
+		{
+			name:     "pipeline longer than usual",
+			row:      newPipeline(cache.Running, average(time.Minute)),
+			expected: average(0),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.row.setETA(now)
+			if diff := cmp.Diff(testCase.expected, testCase.row.eta); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestBuildsByCommit_Deployment(t *testing.T) {
+	deployment := cache.Deployment{
+		ID:            "7",
+		Environment:   "staging",
+		EnvironmentID: "3",
+		State:         cache.Passed,
+		WebURL:        "https://staging.example.com",
+		JobID:         "1",
+		CanStop:       true,
+	}
+	b := build
+	b.Deployments = []*cache.Deployment{&deployment}
+	c := cache.NewCache(nil, nil)
+	if err := c.Save(b); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+
+	var row *buildRow
+	for _, r := range source.Rows() {
+		for _, node := range utils.DepthFirstTraversal(r.(*buildRow), true) {
+			if n := node.(*buildRow); n.type_ == "D" {
+				row = n
+			}
+		}
+	}
+	if row == nil {
+		t.Fatal("deployment row not found")
+	}
+	if row.name != "staging" || row.URL() != "https://staging.example.com" {
+		t.Fatalf("unexpected deployment row %+v", row)
+	}
+
+	d, err := source.Deployment(row.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(deployment, d); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if _, err := source.Deployment(buildAsRow.Key()); err != ErrNoDeploymentHere {
+		t.Fatalf("expected %v but got %v", ErrNoDeploymentHere, err)
+	}
+
+	details, err := source.Details(row.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(details, "Actions:    stop environment\n") {
+		t.Fatalf("unexpected details %q", details)
+	}
+}
+
+func TestBuildsByCommit_Cancelable(t *testing.T) {
+	runningJob := job
+	runningJob.State = cache.Running
+	runningStage := stage
+	runningStage.Jobs = []*cache.Job{&runningJob}
+	b := build
+	b.State = cache.Running
+	b.Stages = map[int]*cache.Stage{runningStage.ID: &runningStage}
+
+	c := cache.NewCache(nil, nil)
+	if err := c.Save(b); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+
+	testCases := []struct {
+		name string
+		key  buildRowKey
+		want string
+		err  error
+	}{
+		{
+			name: "pipeline",
+			key:  buildAsRow.key,
+			want: "pipeline #42",
+		},
+		{
+			name: "job",
+			key:  jobAsRow.key,
+			want: `job "golang 1.12"`,
+		},
+		{
+			name: "stage",
+			key:  stageAsRow.key,
+			err:  ErrNothingToCancelHere,
+		},
+		{
+			name: "unknown pipeline",
+			key:  buildRowKey{accountID: "id", buildID: "43"},
+			err:  ErrNothingToCancelHere,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			name, err := source.Cancelable(testCase.key)
+			if err != testCase.err {
+				t.Fatalf("expected %v but got %v", testCase.err, err)
+			}
+			if name != testCase.want {
+				t.Fatalf("expected %q but got %q", testCase.want, name)
+			}
+		})
+	}
+
+	t.Run("finished pipeline", func(t *testing.T) {
+		c := cache.NewCache(nil, nil)
+		if err := c.Save(build); err != nil {
+			t.Fatal(err)
+		}
+		source := NewBuildsByCommit(&c)
+		if _, err := source.Cancelable(buildAsRow.key); err != ErrNothingToCancelHere {
+			t.Fatalf("expected %v but got %v", ErrNothingToCancelHere, err)
+		}
+	})
+}
+
+type mockRestarter struct {
+	mockProvider
+	restarted cache.Build
+}
+
+func (p mockRestarter) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
+	return p.restarted, nil
+}
+
+func (p mockRestarter) RestartBuild(ctx context.Context, build cache.Build) (string, error) {
+	return p.restarted.WebURL, nil
+}
+
+func TestBuildsByCommit_Restart(t *testing.T) {
+	restarted := build
+	restarted.ID = "43"
+	restarted.State = cache.Pending
+	restarted.WebURL = "example.com/pipeline/43"
+	c := cache.NewCache([]cache.CIProvider{
+		mockRestarter{mockProvider: mockProvider{id: "id"}, restarted: restarted},
+	}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+
+	name, err := source.Restartable(jobAsRow.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "pipeline #42" {
+		t.Fatalf("expected %q but got %q", "pipeline #42", name)
+	}
+
+	key, err := source.Restart(context.Background(), buildAsRow.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := buildAsRow.key
+	expected.buildID = "43"
+	if key != expected {
+		t.Fatalf("expected %v but got %v", expected, key)
+	}
+
+	if _, err := source.Restartable(key); err != cache.ErrActionNotPermitted {
+		t.Fatalf("expected %v but got %v", cache.ErrActionNotPermitted, err)
+	}
+	if _, err := source.Restartable(buildRowKey{accountID: "id", buildID: "44"}); err != ErrNoPipelineHere {
+		t.Fatalf("expected %v but got %v", ErrNoPipelineHere, err)
+	}
+}
+
+type mockJobRetrier struct {
+	mockProvider
+}
+
+func (p mockJobRetrier) RetryJob(ctx context.Context, build cache.Build, job cache.Job) (string, error) {
+	return job.ID, nil
+}
+
+func TestBuildsByCommit_Retry(t *testing.T) {
+	c := cache.NewCache([]cache.CIProvider{mockJobRetrier{mockProvider{id: "id"}}}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+
+	name, err := source.Retryable(jobAsRow.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != `job "golang 1.12"` {
+		t.Fatalf("expected %q but got %q", `job "golang 1.12"`, name)
+	}
+	key, err := source.Retry(context.Background(), jobAsRow.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != jobAsRow.key {
+		t.Fatalf("expected %v but got %v", jobAsRow.key, key)
+	}
+
+	for _, key := range []buildRowKey{buildAsRow.key, stageAsRow.key} {
+		if _, err := source.Retryable(key); err != ErrNoJobHere {
+			t.Fatalf("expected %v but got %v", ErrNoJobHere, err)
+		}
+	}
+}
+
+func TestBuildsByCommit_ManualJob(t *testing.T) {
+	manualJob := job
+	manualJob.State = cache.Manual
+	manualStage := stage
+	manualStage.Jobs = []*cache.Job{&manualJob}
+	b := build
+	b.Stages = map[int]*cache.Stage{manualStage.ID: &manualStage}
+
+	c := cache.NewCache(nil, nil)
+	if err := c.Save(b); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+
+	name, err := source.ManualJob(jobAsRow.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != `job "golang 1.12"` {
+		t.Fatalf("expected %q but got %q", `job "golang 1.12"`, name)
+	}
+	if err := source.StartManualJob(context.Background(), jobAsRow.key); err == nil {
+		t.Fatal("expected an error since the provider is unknown")
+	}
+	if _, err := source.ManualJob(buildAsRow.key); err != ErrNoJobHere {
+		t.Fatalf("expected %v but got %v", ErrNoJobHere, err)
+	}
+
+	c = cache.NewCache(nil, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source = NewBuildsByCommit(&c)
+	if _, err := source.ManualJob(jobAsRow.key); err != cache.ErrActionNotPermitted {
+		t.Fatalf("expected %v but got %v", cache.ErrActionNotPermitted, err)
+	}
+}
+
+func TestBuildsByCommit_Annotations(t *testing.T) {
+	job := cache.Job{
+		ID:    "3",
+		Name:  "pytest",
+		State: cache.Failed,
+		Annotations: []cache.CodeAnnotation{
+			{
+				Level:     "failure",
+				Path:      "tests/test_anim.py",
+				StartLine: 42,
+				EndLine:   42,
+				Message:   "AssertionError: assert 3 == 4\n  where 3 = len(frames)",
+				WebURL:    "https://github.com/owner/project/blob/c2bb562/tests/test_anim.py#L42",
+			},
+			{
+				Level:     "warning",
+				Path:      "termtosvg/term.py",
+				StartLine: 10,
+				EndLine:   12,
+				Title:     "Deprecation",
+				Message:   "Function 'record' is deprecated",
+			},
+		},
+	}
+	b := build
+	b.Stages = map[int]*cache.Stage{}
+	b.Jobs = []*cache.Job{&job}
+	c := cache.NewCache(nil, nil)
+	if err := c.Save(b); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+
+	rows := make([]*buildRow, 0)
+	for _, r := range source.Rows() {
+		for _, node := range utils.DepthFirstTraversal(r.(*buildRow), true) {
+			if n := node.(*buildRow); n.type_ == "A" {
+				rows = append(rows, n)
+			}
+		}
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 annotation rows but got %d", len(rows))
+	}
+	if rows[0].name != "tests/test_anim.py:42: AssertionError: assert 3 == 4" || rows[0].state != cache.Failed {
+		t.Fatalf("unexpected annotation row %+v", rows[0])
+	}
+	if rows[0].URL() != job.Annotations[0].WebURL {
+		t.Fatalf("expected URL %q but got %q", job.Annotations[0].WebURL, rows[0].URL())
+	}
+	if rows[1].name != "warning: termtosvg/term.py:10: Function 'record' is deprecated" || rows[1].state != cache.Unknown {
+		t.Fatalf("unexpected annotation row %+v", rows[1])
+	}
+
+	details, err := source.Details(rows[1].Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"Annotation: warning\n" +
+		"File:       termtosvg/term.py:10-12\n" +
+		"Title:      Deprecation\n" +
+		"\n" +
+		"Function 'record' is deprecated\n"
+	if diff := cmp.Diff(expected, details); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestBuildsByCommit_Problems(t *testing.T) {
+	job := cache.Job{
+		ID:    "3",
+		Name:  "go test",
+		State: cache.Failed,
+		Log: utils.NullString{
+			String: "go test ./...\n--- FAIL: TestParse (0.00s)\n    parse_test.go:12: unexpected token\nFAIL\n",
+			Valid:  true,
+		},
+	}
+	b := build
+	b.Stages = map[int]*cache.Stage{}
+	b.Jobs = []*cache.Job{&job}
+	c := cache.NewCache(nil, nil)
+	if err := c.Save(b); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+
+	problemRows := func() []*buildRow {
+		rows := make([]*buildRow, 0)
+		for _, r := range source.Rows() {
+			for _, node := range utils.DepthFirstTraversal(r.(*buildRow), true) {
+				if n := node.(*buildRow); n.type_ == "E" {
+					rows = append(rows, n)
+				}
+			}
+		}
+		return rows
+	}
+	if rows := problemRows(); len(rows) != 0 {
+		t.Fatalf("expected no problem row before the log is fetched but got %d", len(rows))
+	}
+
+	dir, err := ioutil.TempDir("", "citop_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jobKey := buildRowKey{
+		ref:       b.Ref,
+		sha:       b.Commit.Sha,
+		accountID: b.Repository.Provider.ID,
+		buildID:   b.ID,
+		jobID:     job.ID,
+	}
+	if _, err := source.WriteToDisk(context.Background(), jobKey, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := problemRows()
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 problem rows but got %d", len(rows))
+	}
+	if rows[0].name != "TestParse (0.00s)" || rows[1].name != "parse_test.go:12: unexpected token" {
+		t.Fatalf("unexpected problem rows %q and %q", rows[0].name, rows[1].name)
+	}
+	if line := source.LogLine(rows[1].Key()); line != 3 {
+		t.Fatalf("expected log line 3 but got %d", line)
+	}
+	if line := source.LogLine(jobKey); line != 0 {
+		t.Fatalf("expected log line 0 for a job but got %d", line)
+	}
+
+	details, err := source.Details(rows[1].Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"Problem:  go\n" +
+		"File:     parse_test.go:12\n" +
+		"Log line: 3\n" +
+		"\n" +
+		"unexpected token\n"
+	if diff := cmp.Diff(expected, details); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
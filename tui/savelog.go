@@ -0,0 +1,119 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// DefaultLogPathTemplate is the template of the path of saved logs used unless the user
+// provides one. Relative paths are relative to the working directory.
+const DefaultLogPathTemplate = "citop_{{.Pipeline}}_{{.JobID}}.log"
+
+// LogFileData is the data available to the template of the path of saved logs. Path separators
+// are replaced by underscores in every field so that each field stays within a single path
+// component.
+type LogFileData struct {
+	Provider string
+	// Owner and name of the repository, e.g. "nbedos_citop"
+	Repository string
+	// Identifier of the pipeline of the job
+	Pipeline string
+	Ref      string
+	Sha      string
+	Job      string
+	JobID    string
+}
+
+// NewLogPathTemplate parses the template of the path of saved logs. An empty string selects
+// DefaultLogPathTemplate.
+func NewLogPathTemplate(s string) (*template.Template, error) {
+	if s == "" {
+		s = DefaultLogPathTemplate
+	}
+	tmpl, err := template.New("save_path").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check for invalid fields right away instead of when the first log is saved
+	if _, err := logPath(tmpl, LogFileData{}); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// Return the path obtained by applying 'tmpl' to 'data'
+func logPath(tmpl *template.Template, data LogFileData) (string, error) {
+	buf := bytes.Buffer{}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Replace path separators of 's' by underscores
+func pathComponent(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// SaveLog writes the raw log of the job designated by 'key', as sent by the provider, to the
+// file whose path is given by 'pathTemplate' and returns the absolute path of the file. Missing
+// directories are created and an existing file is overwritten.
+func (s BuildsByCommit) SaveLog(ctx context.Context, key interface{}, pathTemplate *template.Template) (string, error) {
+	job, err := s.job(key)
+	if err != nil {
+		return "", err
+	}
+	buildKey := key.(buildRowKey)
+	build, exists := s.cache.Build(buildKey.accountID, buildKey.buildID)
+	if !exists {
+		return "", ErrNoJobHere
+	}
+
+	data := LogFileData{
+		Pipeline: pathComponent(build.ID),
+		Ref:      pathComponent(build.Ref),
+		Sha:      pathComponent(build.Commit.Sha),
+		Job:      pathComponent(job.Name),
+		JobID:    pathComponent(job.ID),
+	}
+	if build.Repository != nil {
+		data.Provider = pathComponent(build.Repository.Provider.Name)
+		data.Repository = pathComponent(build.Repository.Slug())
+	}
+	p, err := logPath(pathTemplate, data)
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", errors.New("the path of saved logs is empty")
+	}
+	if p, err = filepath.Abs(p); err != nil {
+		return "", err
+	}
+
+	log, err := s.cache.Log(ctx, buildKey.accountID, buildKey.buildID, buildKey.stageID, buildKey.jobID)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(p, []byte(log), 0644); err != nil {
+		return "", err
+	}
+
+	return p, nil
+}
@@ -0,0 +1,119 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/citop/cache"
+)
+
+func TestNewLogPathTemplate(t *testing.T) {
+	if _, err := NewLogPathTemplate("{{.Unknown}}.log"); err == nil {
+		t.Fatal("expected an error for an unknown field")
+	}
+	if _, err := NewLogPathTemplate("{{.Job"); err == nil {
+		t.Fatal("expected an error for an invalid template")
+	}
+	if _, err := NewLogPathTemplate(""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildsByCommit_SaveLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := cache.NewCache([]cache.CIProvider{mockProvider{id: "id"}}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+	ctx := context.Background()
+	tmpl, err := NewLogPathTemplate(filepath.Join(dir, "{{.Repository}}", "{{.Pipeline}}_{{.Job}}.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := source.SaveLog(ctx, stageAsRow.key, tmpl); err != ErrNoJobHere {
+		t.Fatalf("expected %v but got %v", ErrNoJobHere, err)
+	}
+
+	// Path separators of the fields are replaced and missing directories are created
+	p, err := source.SaveLog(ctx, jobAsRow.key, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(dir, "owner_project", "42_golang 1.12.log"); p != expected {
+		t.Fatalf("expected %q but got %q", expected, p)
+	}
+	content, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "log\n" {
+		t.Fatalf("unexpected content %q", string(content))
+	}
+}
+
+func TestController_saveLog(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, DefaultStyleSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := cache.NewCache([]cache.CIProvider{mockProvider{id: "id"}}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+	controller, err := NewController(&tui, &source, time.UTC, dir, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := NewLogPathTemplate(filepath.Join(dir, "{{.Pipeline}}_{{.Job}}.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.SetLogPathTemplate(tmpl)
+	controller.resize(80, 20)
+	controller.refresh()
+
+	if !controller.table.Jump(jobAsRow.key) {
+		t.Fatal("job row not found")
+	}
+	if err := controller.saveLog(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The log is fetched and written in the background
+	if err := applyOutcome(t, &controller); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(dir, "42_golang 1.12.log")
+	buffer := controller.status.outputBuffer
+	if expected := fmt.Sprintf("Log saved to %s", p); buffer[len(buffer)-1] != expected {
+		t.Fatalf("expected status %q but got %q", expected, buffer[len(buffer)-1])
+	}
+	if _, err := os.Stat(p); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,143 @@
+package tui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/nbedos/citop/utils"
+)
+
+// Session is the working context of the user on a ref of a repository: the rows whose fold is
+// open, the row at the cursor and the presentation of the table. It is saved on exit and
+// restored the next time the same ref is monitored.
+type Session struct {
+	RepositoryURL string `json:"repository_url"`
+	Ref           string `json:"ref"`
+	// Session paths of the rows whose fold is open and of the row at the cursor (see
+	// Table.SessionState)
+	Open   []string `json:"open"`
+	Cursor string   `json:"cursor"`
+	// Presentation of the table
+	Flat       bool   `json:"flat"`
+	JobsOnly   bool   `json:"jobs_only"`
+	SortColumn string `json:"sort_column"`
+	Reverse    bool   `json:"reverse"`
+	// Pattern of the last search among rows
+	Search string `json:"search"`
+}
+
+// sessionRow is implemented by rows that can be recognized from one run to the next whatever the
+// commit shown
+type sessionRow interface {
+	// SessionName identifies the row among its siblings
+	SessionName() string
+}
+
+// Return the ref whose session is used when monitoring the commit designated by 'ref'. HEAD
+// stands for the branch checked out, if any, so that each branch gets its own session.
+func sessionRef(ref string, commit utils.Commit) string {
+	if ref == "HEAD" && commit.Head != "" {
+		return commit.Head
+	}
+	return ref
+}
+
+func sessionPath(dir string, repositoryURL string, ref string) string {
+	h := sha256.Sum256([]byte(repositoryURL + "\n" + ref))
+	return filepath.Join(dir, "sessions", hex.EncodeToString(h[:8])+".json")
+}
+
+// Return the session of 'ref' stored in 'dir' if there is one
+func loadSession(dir string, repositoryURL string, ref string) (Session, bool) {
+	var s Session
+	bs, err := ioutil.ReadFile(sessionPath(dir, repositoryURL, ref))
+	if err != nil {
+		return s, false
+	}
+	if err := json.Unmarshal(bs, &s); err != nil {
+		return s, false
+	}
+	if s.RepositoryURL != repositoryURL || s.Ref != ref {
+		return s, false
+	}
+	return s, true
+}
+
+// Store the session in 'dir', replacing the previous session of the same ref
+func saveSession(dir string, s Session) error {
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomically(sessionPath(dir, s.RepositoryURL, s.Ref), bs)
+}
+
+// Session returns the working context of the user. RepositoryURL and Ref are left empty.
+func (c Controller) Session() Session {
+	open, cursor := c.table.SessionState()
+	// The table of pipelines is set aside by the list of artifacts first, then by the pager
+	switch {
+	case c.artifacts != nil:
+		open, cursor = c.artifacts.open, c.artifacts.cursor
+	case c.pager != nil:
+		open, cursor = c.pager.open, c.pager.cursor
+	}
+	search := c.status.InputBuffer
+	switch {
+	case c.searchingLogs:
+		search = c.rowPattern
+	case c.inputMode:
+		// The pattern being typed has not been searched yet
+		search = ""
+	}
+
+	return Session{
+		Open:       open,
+		Cursor:     cursor,
+		Flat:       c.flat,
+		JobsOnly:   c.jobsOnly,
+		SortColumn: c.sortColumn,
+		Reverse:    c.reverse,
+		Search:     search,
+	}
+}
+
+// RestoreSession restores the presentation of the table and the last search pattern of 's'.
+// Folds and cursor position are restored as rows show up in the table until the user presses
+// a key.
+func (c *Controller) RestoreSession(s Session) {
+	if s.Flat && c.table.SetGrouped(false) == nil {
+		c.flat = true
+	}
+	if s.JobsOnly && c.table.SetJobsOnly(true) == nil {
+		c.jobsOnly = true
+	}
+	if s.SortColumn != "" || s.Reverse {
+		// Ignore columns that are no longer shown
+		valid := s.SortColumn == ""
+		for _, header := range c.table.Headers() {
+			valid = valid || header == s.SortColumn
+		}
+		if valid && c.table.SetSort(s.SortColumn, s.Reverse) == nil {
+			c.sortColumn, c.reverse = s.SortColumn, s.Reverse
+		}
+	}
+	c.status.InputBuffer = s.Search
+
+	c.session = &s
+	c.resumeSession()
+}
+
+// Open the folds and move the cursor as requested by the session being restored. The cursor is
+// only moved once.
+func (c *Controller) resumeSession() {
+	if c.session == nil {
+		return
+	}
+	if c.table.RestoreSession(c.session.Open, c.session.Cursor) {
+		c.session.Cursor = ""
+	}
+}
@@ -0,0 +1,152 @@
+package tui
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/text"
+	"github.com/nbedos/citop/utils"
+)
+
+func TestSessionCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := Session{
+		RepositoryURL: "github.com/nbedos/citop",
+		Ref:           "master",
+		Open:          []string{"P:gitlab", "P:gitlab/S:tests"},
+		Cursor:        "P:github/S:tests/J:go",
+		SortColumn:    "DURATION",
+		Search:        "deploy",
+	}
+	if err := saveSession(dir, s); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("same ref", func(t *testing.T) {
+		loaded, exists := loadSession(dir, s.RepositoryURL, s.Ref)
+		if !exists {
+			t.Fatal("expected session to exist")
+		}
+		if diff := cmp.Diff(s, loaded); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("other ref", func(t *testing.T) {
+		if _, exists := loadSession(dir, s.RepositoryURL, "feature"); exists {
+			t.Fatal("expected no session")
+		}
+	})
+}
+
+func TestSessionRef(t *testing.T) {
+	testCases := []struct {
+		ref      string
+		commit   utils.Commit
+		expected string
+	}{
+		{"HEAD", utils.Commit{Head: "feature"}, "feature"},
+		{"HEAD", utils.Commit{}, "HEAD"},
+		{"master", utils.Commit{Head: "feature"}, "master"},
+	}
+	for _, testCase := range testCases {
+		if ref := sessionRef(testCase.ref, testCase.commit); ref != testCase.expected {
+			t.Fatalf("expected %q but got %q", testCase.expected, ref)
+		}
+	}
+}
+
+func TestController_RestoreSession(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, text.StyleSheet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+
+	// Pipelines of a commit have an identifier of their own but the same stages and jobs as the
+	// pipelines of the previous commit
+	saveBuilds := func(c *cache.Cache, id string) {
+		for _, provider := range []string{"github", "gitlab"} {
+			build := cache.Build{
+				Repository: &cache.Repository{Provider: cache.Provider{ID: provider, Name: provider}},
+				ID:         provider + id,
+				Stages: map[int]*cache.Stage{
+					1: {ID: 1, Name: "tests", Jobs: []*cache.Job{{ID: "1", Name: "go"}, {ID: "2", Name: "python"}}},
+				},
+			}
+			if err := c.Save(build); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	c := cache.NewCache(nil, nil)
+	saveBuilds(&c, "1")
+	source := NewBuildsByCommit(&c)
+	controller, err := NewController(&tui, &source, time.UTC, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.resize(80, 40)
+	controller.refresh()
+	if err := controller.sort("PIPELINE", true); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, r := range "ojojj" {
+		if err := controller.process(ctx, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session := controller.Session()
+	expected := Session{
+		Open:       []string{"P:gitlab", "P:gitlab/S:tests"},
+		Cursor:     "P:gitlab/S:tests/J:python",
+		SortColumn: "PIPELINE",
+		Reverse:    true,
+	}
+	if diff := cmp.Diff(expected, session); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// Restore the session before the pipelines of the next commit are fetched
+	next := cache.NewCache(nil, nil)
+	nextSource := NewBuildsByCommit(&next)
+	restored, err := NewController(&tui, &nextSource, time.UTC, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored.resize(80, 40)
+	restored.RestoreSession(session)
+	saveBuilds(&next, "2")
+	restored.refresh()
+	if diff := cmp.Diff(session, restored.Session()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	t.Run("the user takes over", func(t *testing.T) {
+		if err := restored.process(ctx, tcell.NewEventKey(tcell.KeyRune, 'k', tcell.ModNone)); err != nil {
+			t.Fatal(err)
+		}
+		restored.refresh()
+		if _, cursor := restored.table.SessionState(); cursor != "P:gitlab/S:tests/J:go" {
+			t.Fatalf("expected cursor to stay on the previous row but got %q", cursor)
+		}
+	})
+}
@@ -0,0 +1,284 @@
+package tui
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/text"
+	"github.com/nbedos/citop/utils"
+)
+
+// StatusFormat designates the output format of RunStatus
+type StatusFormat string
+
+const (
+	// Icons followed by the number of pipelines in each state
+	StatusPlain StatusFormat = "plain"
+	// Same as StatusPlain with colors specified using the syntax of the status line of tmux
+	StatusTmux StatusFormat = "tmux"
+	// JSON object expected by the custom modules of Waybar
+	StatusWaybar StatusFormat = "waybar"
+)
+
+// ParseStatusFormat returns the format named 's'
+func ParseStatusFormat(s string) (StatusFormat, error) {
+	switch f := StatusFormat(s); f {
+	case StatusPlain, StatusTmux, StatusWaybar:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid status format %q (expected \"plain\", \"tmux\" or \"waybar\")", s)
+	}
+}
+
+// Maximum age of a summary read from disk if some of its pipelines are still active. Summaries
+// of complete pipelines never expire.
+const statusCacheTTL = 30 * time.Second
+
+// Order in which states appear in a summary
+var summaryStates = []cache.State{
+	cache.Failed,
+	cache.Canceled,
+	cache.Running,
+	cache.Pending,
+	cache.Manual,
+	cache.Passed,
+	cache.Skipped,
+}
+
+// Colors of states in the status line of tmux
+var tmuxColors = map[cache.State]string{
+	cache.Failed:   "red",
+	cache.Canceled: "brightblack",
+	cache.Running:  "yellow",
+	cache.Pending:  "blue",
+	cache.Manual:   "brightblack",
+	cache.Passed:   "green",
+	cache.Skipped:  "brightblack",
+}
+
+// Summary counts the pipelines of a commit by state
+type Summary struct {
+	RepositoryURL string              `json:"repository_url"`
+	Sha           string              `json:"sha"`
+	Time          time.Time           `json:"time"`
+	States        map[cache.State]int `json:"states"`
+}
+
+// NewSummary counts 'builds' by state
+func NewSummary(repositoryURL string, sha string, builds []cache.Build, at time.Time) Summary {
+	s := Summary{
+		RepositoryURL: repositoryURL,
+		Sha:           sha,
+		Time:          at,
+		States:        make(map[cache.State]int),
+	}
+	for _, build := range builds {
+		s.States[build.State]++
+	}
+	return s
+}
+
+// State returns the most significant state among the states of the pipelines, or
+// cache.Unknown if there are no pipelines. A single failed pipeline makes the whole commit
+// failed.
+func (s Summary) State() cache.State {
+	for _, state := range summaryStates {
+		if s.States[state] > 0 {
+			return state
+		}
+	}
+	return cache.Unknown
+}
+
+// IsActive returns true if at least one pipeline is pending or running
+func (s Summary) IsActive() bool {
+	return s.States[cache.Pending] > 0 || s.States[cache.Running] > 0
+}
+
+// waybarStatus is the output of the custom modules of Waybar when "return-type" is "json"
+type waybarStatus struct {
+	Text    string `json:"text"`
+	Alt     string `json:"alt"`
+	Tooltip string `json:"tooltip"`
+	Class   string `json:"class"`
+}
+
+// Format returns the summary as a single line in the format 'f'. For formats other than
+// StatusWaybar the line is empty if there are no pipelines.
+func (s Summary) Format(f StatusFormat, icons StateIcons) string {
+	parts := make([]string, 0)
+	descriptions := make([]string, 0)
+	for _, state := range summaryStates {
+		n := s.States[state]
+		if n == 0 {
+			continue
+		}
+		part := fmt.Sprintf("%s%d", icons[state], n)
+		if f == StatusTmux {
+			part = fmt.Sprintf("#[fg=%s]%s#[fg=default]", tmuxColors[state], part)
+		}
+		parts = append(parts, part)
+		descriptions = append(descriptions, fmt.Sprintf("%d %s", n, state))
+	}
+
+	if f != StatusWaybar {
+		return strings.Join(parts, " ")
+	}
+
+	state := string(s.State())
+	if state == "" {
+		state = "none"
+	}
+	status := waybarStatus{
+		Text:    strings.Join(parts, " "),
+		Alt:     state,
+		Tooltip: fmt.Sprintf("commit %s: %s", shortSha(s.Sha), strings.Join(descriptions, ", ")),
+		Class:   state,
+	}
+	if len(descriptions) == 0 {
+		status.Tooltip = fmt.Sprintf("commit %s: no pipeline", shortSha(s.Sha))
+	}
+	bs, err := json.Marshal(status)
+	if err != nil {
+		// Marshaling a structure made of strings cannot fail
+		panic(err)
+	}
+	return string(bs)
+}
+
+// Classes of the states in the table and its footer
+var stateClasses = map[cache.State]text.Class{
+	cache.Failed:   text.StatusFailed,
+	cache.Canceled: text.StatusCanceled,
+	cache.Running:  text.StatusRunning,
+	cache.Pending:  text.StatusPending,
+	cache.Manual:   text.StatusManual,
+	cache.Passed:   text.StatusPassed,
+	cache.Skipped:  text.StatusSkipped,
+}
+
+// Footer returns the summary as a sentence such as "5 pipelines: 3 passed ✓, 1 running ●, 1
+// failed ✗" followed by the time elapsed since 'lastUpdate' at time 'now'. The time is left out
+// if 'lastUpdate' is zero.
+func (s Summary) Footer(icons StateIcons, lastUpdate time.Time, now time.Time) text.StyledString {
+	total := 0
+	for _, n := range s.States {
+		total += n
+	}
+	footer := text.NewStyledString("No pipelines")
+	if total > 0 {
+		noun := "pipelines"
+		if total == 1 {
+			noun = "pipeline"
+		}
+		counts := make([]text.StyledString, 0)
+		for _, state := range summaryStates {
+			if n := s.States[state]; n > 0 {
+				count := fmt.Sprintf("%d %s", n, state)
+				if icons[state] != "" {
+					count += " " + icons[state]
+				}
+				counts = append(counts, text.NewStyledString(count, stateClasses[state]))
+			}
+		}
+		footer = text.Join([]text.StyledString{
+			text.NewStyledString(fmt.Sprintf("%d %s: ", total, noun)),
+			text.Join(counts, text.NewStyledString(", ")),
+		}, text.StyledString{})
+	}
+
+	if !lastUpdate.IsZero() {
+		footer.Append(fmt.Sprintf(" — last update %s ago", age(lastUpdate, now)))
+	}
+
+	return footer
+}
+
+// Return the time elapsed between 't' and 'now' such as "1m05s"
+func age(t time.Time, now time.Time) string {
+	d := utils.NullDuration{Valid: true, Duration: now.Sub(t)}
+	if d.Duration < 0 {
+		d.Duration = 0
+	}
+	return d.String()
+}
+
+// Return the path of the file storing the summary of the pipelines of commit 'sha'
+func summaryPath(dir string, repositoryURL string, sha string) string {
+	h := sha256.Sum256([]byte(repositoryURL + "\n" + sha))
+	return filepath.Join(dir, "status", hex.EncodeToString(h[:8])+".json")
+}
+
+// Return the summary stored in 'dir' if there is one that is still valid at time 'now'
+func loadSummary(dir string, repositoryURL string, sha string, now time.Time) (Summary, bool) {
+	var s Summary
+	bs, err := ioutil.ReadFile(summaryPath(dir, repositoryURL, sha))
+	if err != nil {
+		return s, false
+	}
+	if err := json.Unmarshal(bs, &s); err != nil {
+		return s, false
+	}
+	if s.RepositoryURL != repositoryURL || s.Sha != sha {
+		return s, false
+	}
+	if s.IsActive() && now.Sub(s.Time) > statusCacheTTL {
+		return s, false
+	}
+	return s, true
+}
+
+// Store the summary in 'dir'. The file is replaced atomically since several instances of citop
+// may run concurrently, one per tmux client for example.
+func saveSummary(dir string, s Summary) error {
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomically(summaryPath(dir, s.RepositoryURL, s.Sha), bs)
+}
+
+// RunStatus writes to 'w' a single line summarizing the state of the pipelines of the commit.
+// The summary is stored in 'cacheDir' and reused by later calls as long as it is valid so that
+// RunStatus can be called every few seconds by a status line without exhausting the rate limit
+// of providers. An empty 'cacheDir' disables the cache.
+func RunStatus(ctx context.Context, w io.Writer, repo string, sha string, CIProviders []cache.CIProvider, SourceProviders []cache.SourceProvider, format StatusFormat, icons StateIcons, cacheDir string) error {
+	if len(CIProviders) == 0 || len(SourceProviders) == 0 {
+		return ErrNoProvider
+	}
+
+	repositoryURL, commit, err := resolveCommit(ctx, repo, sha, SourceProviders)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	summary, valid := Summary{}, false
+	if cacheDir != "" {
+		summary, valid = loadSummary(cacheDir, repositoryURL, commit.Sha, now)
+	}
+	if !valid {
+		c := cache.NewCache(CIProviders, SourceProviders)
+		builds, err := c.Pipelines(ctx, repositoryURL, commit.Sha)
+		if err != nil {
+			return err
+		}
+		summary = NewSummary(repositoryURL, commit.Sha, builds, now)
+		if cacheDir != "" {
+			// Failing to cache the summary only makes the next call more expensive
+			_ = saveSummary(cacheDir, summary)
+		}
+	}
+
+	_, err = fmt.Fprintln(w, summary.Format(format, icons))
+	return err
+}
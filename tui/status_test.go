@@ -0,0 +1,140 @@
+package tui
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+func TestSummary_Format(t *testing.T) {
+	builds := []cache.Build{
+		{ID: "1", State: cache.Passed},
+		{ID: "2", State: cache.Failed},
+		{ID: "3", State: cache.Passed},
+		{ID: "4", State: cache.Running},
+	}
+	s := NewSummary("github.com/nbedos/citop", "c2bb562", builds, time.Now())
+
+	testCases := []struct {
+		format   StatusFormat
+		expected string
+	}{
+		{StatusPlain, "x1 *1 +2"},
+		{StatusTmux, "#[fg=red]x1#[fg=default] #[fg=yellow]*1#[fg=default] #[fg=green]+2#[fg=default]"},
+		{StatusWaybar, `{"text":"x1 *1 +2","alt":"failed","tooltip":"commit c2bb562: 1 failed, 1 running, 2 passed","class":"failed"}`},
+	}
+	for _, testCase := range testCases {
+		t.Run(string(testCase.format), func(t *testing.T) {
+			if line := s.Format(testCase.format, ASCIIStateIcons); line != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, line)
+			}
+		})
+	}
+
+	t.Run("no pipeline", func(t *testing.T) {
+		s := NewSummary("github.com/nbedos/citop", "c2bb562", nil, time.Now())
+		if line := s.Format(StatusTmux, ASCIIStateIcons); line != "" {
+			t.Fatalf("expected empty line but got %q", line)
+		}
+
+		expected := `{"text":"","alt":"none","tooltip":"commit c2bb562: no pipeline","class":"none"}`
+		if line := s.Format(StatusWaybar, ASCIIStateIcons); line != expected {
+			t.Fatalf("expected %q but got %q", expected, line)
+		}
+	})
+}
+
+func TestSummary_Footer(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	builds := []cache.Build{
+		{ID: "1", State: cache.Passed},
+		{ID: "2", State: cache.Failed},
+		{ID: "3", State: cache.Passed},
+		{ID: "4", State: cache.Running},
+		{ID: "5", State: cache.Passed},
+	}
+
+	testCases := []struct {
+		name       string
+		builds     []cache.Build
+		icons      StateIcons
+		lastUpdate time.Time
+		expected   string
+	}{
+		{
+			name:       "pipelines",
+			builds:     builds,
+			icons:      UnicodeStateIcons,
+			lastUpdate: now.Add(-12 * time.Second),
+			expected:   "5 pipelines: 1 failed ✗, 1 running ●, 3 passed ✓ — last update 12s ago",
+		},
+		{
+			name:     "no icons",
+			builds:   builds[:1],
+			expected: "1 pipeline: 1 passed",
+		},
+		{
+			name:       "no pipeline",
+			lastUpdate: now.Add(-90 * time.Second),
+			expected:   "No pipelines — last update 1m30s ago",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			s := NewSummary("github.com/nbedos/citop", "c2bb562", testCase.builds, now)
+			if footer := s.Footer(testCase.icons, testCase.lastUpdate, now).String(); footer != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, footer)
+			}
+		})
+	}
+}
+
+func TestSummaryCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	at := time.Date(2019, 11, 13, 13, 12, 0, 0, time.UTC)
+	active := NewSummary("github.com/nbedos/citop", "a", []cache.Build{{State: cache.Running}}, at)
+	complete := NewSummary("github.com/nbedos/citop", "b", []cache.Build{{State: cache.Passed}}, at)
+	for _, s := range []Summary{active, complete} {
+		if err := saveSummary(dir, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		sha      string
+		now      time.Time
+		expected bool
+	}{
+		{"active and recent", "a", at.Add(statusCacheTTL / 2), true},
+		{"active and expired", "a", at.Add(2 * statusCacheTTL), false},
+		{"complete", "b", at.Add(time.Hour), true},
+		{"unknown commit", "c", at, false},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, valid := loadSummary(dir, "github.com/nbedos/citop", testCase.sha, testCase.now)
+			if valid != testCase.expected {
+				t.Fatalf("expected %v but got %v", testCase.expected, valid)
+			}
+			if valid {
+				expected := active
+				if testCase.sha == "b" {
+					expected = complete
+				}
+				if diff := cmp.Diff(expected, s); len(diff) > 0 {
+					t.Fatal(diff)
+				}
+			}
+		})
+	}
+}
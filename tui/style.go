@@ -0,0 +1,118 @@
+package tui
+
+import (
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/citop/text"
+)
+
+// DefaultStyleSheet is the color palette used on terminals with a dark background
+var DefaultStyleSheet = text.StyleSheet{
+	text.TableHeader: func(s tcell.Style) tcell.Style {
+		return s.Bold(true).Reverse(true)
+	},
+	text.ActiveRow: func(s tcell.Style) tcell.Style {
+		return s.Background(tcell.ColorSilver).Foreground(tcell.ColorBlack).Bold(false).Underline(false).Blink(false)
+	},
+	text.SearchMatch: func(s tcell.Style) tcell.Style {
+		return s.Background(tcell.ColorOlive).Foreground(tcell.ColorBlack)
+	},
+	text.Provider: func(s tcell.Style) tcell.Style {
+		return s.Bold(true)
+	},
+	text.StatusFailed: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorMaroon).Bold(false)
+	},
+	text.StatusCanceled: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorMaroon).Bold(false)
+	},
+	text.StatusPassed: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorGreen).Bold(false)
+	},
+	text.StatusRunning: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorOlive).Bold(false)
+	},
+	text.StatusPending: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorGray).Bold(false)
+	},
+	text.StatusSkipped: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorGray).Bold(false)
+	},
+	text.StatusManual: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorGray).Bold(false)
+	},
+	text.GitSha: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorOlive)
+	},
+	text.GitBranch: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorTeal).Bold(false)
+	},
+	text.GitTag: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorYellow).Bold(false)
+	},
+	text.GitHead: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorAqua)
+	},
+}
+
+// MonochromeStyleSheet only relies on text attributes and leaves foreground and background
+// colors to the defaults of the terminal. It is used when the user asks for no colors.
+var MonochromeStyleSheet = text.StyleSheet{
+	text.TableHeader: func(s tcell.Style) tcell.Style {
+		return s.Bold(true).Reverse(true)
+	},
+	text.ActiveRow: func(s tcell.Style) tcell.Style {
+		return s.Reverse(true).Bold(false).Underline(false).Blink(false)
+	},
+	text.SearchMatch: func(s tcell.Style) tcell.Style {
+		return s.Bold(true).Underline(true)
+	},
+	text.Provider: func(s tcell.Style) tcell.Style {
+		return s.Bold(true)
+	},
+	text.StatusFailed: func(s tcell.Style) tcell.Style {
+		return s.Bold(true)
+	},
+	text.StatusCanceled: func(s tcell.Style) tcell.Style {
+		return s.Bold(true)
+	},
+	text.GitHead: func(s tcell.Style) tcell.Style {
+		return s.Bold(true)
+	},
+}
+
+// LightStyleSheet is a variant of DefaultStyleSheet for terminals with a light background.
+// Colors that are hard to read on a white background are replaced by darker ones.
+var LightStyleSheet = override(DefaultStyleSheet, text.StyleSheet{
+	text.StatusRunning: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorOlive).Bold(true)
+	},
+	text.GitSha: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorMaroon)
+	},
+	text.GitTag: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorPurple).Bold(false)
+	},
+	text.GitHead: func(s tcell.Style) tcell.Style {
+		return s.Foreground(tcell.ColorNavy)
+	},
+})
+
+// StyleSheetForBackground returns the color palette adapted to the background of the terminal
+func StyleSheetForBackground(bg Background) text.StyleSheet {
+	if bg == LightBackground {
+		return LightStyleSheet
+	}
+	return DefaultStyleSheet
+}
+
+// Return a copy of 'base' where the style of each class defined in 'overrides' is replaced
+func override(base text.StyleSheet, overrides text.StyleSheet) text.StyleSheet {
+	styleSheet := make(text.StyleSheet, len(base))
+	for class, f := range base {
+		styleSheet[class] = f
+	}
+	for class, f := range overrides {
+		styleSheet[class] = f
+	}
+	return styleSheet
+}
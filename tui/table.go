@@ -3,8 +3,13 @@ package tui
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/mattn/go-runewidth"
@@ -14,19 +19,37 @@ import (
 )
 
 type Table struct {
-	source     cache.HierarchicalTabularDataSource
-	nodes      []cache.HierarchicalTabularSourceRow
-	rows       []cache.HierarchicalTabularSourceRow
+	source     HierarchicalTabularDataSource
+	nodes      []HierarchicalTabularSourceRow
+	rows       []HierarchicalTabularSourceRow
 	topLine    int
 	activeLine int
-	height     int
-	width      int
-	sep        string
-	maxWidths  map[string]int
+	// Number of screen columns hidden on the left of the table by horizontal scrolling
+	leftColumn int
+	// Whether the last column of the row at the cursor is wrapped across several lines instead
+	// of being cut at the right edge of the screen
+	wrap      bool
+	height    int
+	width     int
+	sep       string
+	maxWidths map[string]int
+	// Sizing mode of the columns of the table by header. Columns not listed fit their widest
+	// value.
+	widths map[string]ColumnWidth
+	// Priority of columns by header, columns of low priority are hidden first when the screen
+	// is too narrow
+	priorities map[string]int
+	// Numbering of rows shown in a gutter on the left of the table
+	numbers LineNumbers
+	// Column the rows of the source are sorted by, empty for the default order
+	sortColumn string
+	reverse    bool
 	location   *time.Location
+	// Text highlighted wherever it occurs in the rows of the table
+	highlight string
 }
 
-func NewTable(source cache.HierarchicalTabularDataSource, width int, height int, loc *time.Location) (Table, error) {
+func NewTable(source HierarchicalTabularDataSource, width int, height int, loc *time.Location) (Table, error) {
 	if width < 0 || height < 0 {
 		return Table{}, errors.New("table width and height must be >= 0")
 	}
@@ -45,13 +68,146 @@ func NewTable(source cache.HierarchicalTabularDataSource, width int, height int,
 	return table, nil
 }
 
+// SetSource replaces the data source of the table and moves the cursor back to the first line
+func (t *Table) SetSource(source HierarchicalTabularDataSource) {
+	t.source = source
+	t.nodes = nil
+	t.rows = nil
+	t.topLine, t.activeLine = 0, 0
+	t.leftColumn = 0
+	t.sortColumn, t.reverse = "", false
+	t.maxWidths = make(map[string]int)
+	t.highlight = ""
+	t.Refresh()
+}
+
+// SetHighlight highlights the occurrences of 'pattern' in the rows of the table, until the
+// source of the table changes. An empty pattern highlights nothing.
+func (t *Table) SetHighlight(pattern string) {
+	t.highlight = pattern
+}
+
 func (t Table) NbrRows() int {
 	return utils.MaxInt(0, t.height-1)
 }
 
+// Return the width of the screen left to the columns of the table by the gutter holding the
+// numbers of rows
+func (t Table) contentWidth() int {
+	return utils.MaxInt(0, t.width-t.numbers.gutterWidth(len(t.rows)))
+}
+
+// SetLineNumbers selects the numbering of rows
+func (t *Table) SetLineNumbers(numbers LineNumbers) {
+	t.numbers = numbers
+	t.ScrollHorizontally(0)
+}
+
+// Return the width of the lines of the table, which may exceed the width of the screen
+func (t Table) lineWidth() int {
+	width := 0
+	for i, header := range t.visibleHeaders() {
+		if i > 0 {
+			width += runewidth.StringWidth(t.sep)
+		}
+		width += t.columnWidth(header)
+	}
+	return width
+}
+
+// Return the width of the column 'header' according to its sizing mode
+func (t Table) columnWidth(header string) int {
+	if w, exists := t.widths[header]; exists {
+		return w.width(t.contentWidth(), t.maxWidths[header])
+	}
+	return t.maxWidths[header]
+}
+
+// SetColumnWidths selects the sizing mode of the columns of the table. Columns missing from
+// 'widths' fit their widest value.
+func (t *Table) SetColumnWidths(widths map[string]ColumnWidth) {
+	t.widths = widths
+	t.ScrollHorizontally(0)
+}
+
+// SetColumnPriorities overrides the priorities of DefaultColumnPriorities
+func (t *Table) SetColumnPriorities(priorities map[string]int) {
+	t.priorities = priorities
+	t.ScrollHorizontally(0)
+}
+
+// Return the width the column 'header' needs on screen. The last column needs its minimum width
+// or minLastColumnWidth, the rest of it can be revealed by scrolling or wrapping.
+func (t Table) neededWidth(header string, last bool) int {
+	width := t.columnWidth(header)
+	if !last {
+		return width
+	}
+	min := t.widths[header].Min
+	if min == 0 {
+		min = minLastColumnWidth
+	}
+	return utils.MinInt(width, utils.MaxInt(min, runewidth.StringWidth(t.headerLabel(header))))
+}
+
+// Return the priority of the column 'header'
+func (t Table) priority(header string) int {
+	if p, exists := t.priorities[header]; exists {
+		return p
+	}
+	return DefaultColumnPriorities[header]
+}
+
+// Return the headers of the columns shown on screen. Columns are hidden by increasing priority
+// until the columns left fit the width of the screen. Among columns of equal priority, the
+// rightmost one is hidden first. The STATE column and the last column are never hidden.
+func (t Table) visibleHeaders() []string {
+	headers := append([]string(nil), t.source.Headers()...)
+	sepWidth := runewidth.StringWidth(t.sep)
+	for {
+		width := 0
+		hidden := -1
+		for i, header := range headers {
+			last := i == len(headers)-1
+			width += t.neededWidth(header, last)
+			if i > 0 {
+				width += sepWidth
+			}
+			if !last && header != "STATE" && (hidden < 0 || t.priority(header) <= t.priority(headers[hidden])) {
+				hidden = i
+			}
+		}
+		if width <= t.contentWidth() || hidden < 0 {
+			return headers
+		}
+		headers = append(headers[:hidden], headers[hidden+1:]...)
+	}
+}
+
+// Return 's' cut to 'width' screen columns, an ellipsis marking the cut
+func truncate(s text.StyledString, width int) text.StyledString {
+	switch {
+	case s.Length() <= width:
+		return s
+	case width <= 0:
+		return text.NewStyledString("")
+	case width == 1:
+		return s.Wrap(1)[0]
+	}
+	s = s.Wrap(width - 1)[0]
+	s.Append("…")
+	return s
+}
+
+// ScrollHorizontally pans the table by 'amount' screen columns, to the right if 'amount' is
+// positive. Lines wider than the screen are otherwise cut at its right edge.
+func (t *Table) ScrollHorizontally(amount int) {
+	t.leftColumn = utils.Bounded(t.leftColumn+amount, 0, utils.MaxInt(0, t.lineWidth()-t.contentWidth()))
+}
+
 func (t *Table) computeMaxWidths() {
 	for _, header := range t.source.Headers() {
-		t.maxWidths[header] = utils.MaxInt(t.maxWidths[header], runewidth.StringWidth(header))
+		t.maxWidths[header] = utils.MaxInt(t.maxWidths[header], runewidth.StringWidth(t.headerLabel(header)))
 	}
 	for _, row := range t.rows {
 		for header, value := range row.Tabular(t.location) {
@@ -66,7 +222,7 @@ func (t *Table) Refresh() {
 	for i := range t.nodes {
 		rowTraversal := utils.DepthFirstTraversal(t.nodes[i], true)
 		for j := range rowTraversal {
-			if row := rowTraversal[j].(cache.HierarchicalTabularSourceRow); row.Traversable() {
+			if row := rowTraversal[j].(HierarchicalTabularSourceRow); row.Traversable() {
 				traversables[row.Key()] = struct{}{}
 			}
 		}
@@ -74,10 +230,10 @@ func (t *Table) Refresh() {
 
 	// Fetch all nodes from DataSource and restore traversable state
 	nodes := t.source.Rows()
-	t.nodes = make([]cache.HierarchicalTabularSourceRow, 0, len(nodes))
+	t.nodes = make([]HierarchicalTabularSourceRow, 0, len(nodes))
 	for _, node := range nodes {
 		for _, childRow := range utils.DepthFirstTraversal(node, true) {
-			childRow := childRow.(cache.HierarchicalTabularSourceRow)
+			childRow := childRow.(HierarchicalTabularSourceRow)
 			_, exists := traversables[childRow.Key()]
 			childRow.SetTraversable(exists, false)
 		}
@@ -89,11 +245,11 @@ func (t *Table) Refresh() {
 	if t.activeLine >= 0 && t.activeLine < len(t.rows) {
 		activeKey = t.rows[t.activeLine].Key()
 	}
-	t.rows = make([]cache.HierarchicalTabularSourceRow, 0, len(t.nodes))
+	t.rows = make([]HierarchicalTabularSourceRow, 0, len(t.nodes))
 	for _, node := range t.nodes {
-		cache.Prefix(node, "", true)
+		Prefix(node, "", true)
 		for _, childRow := range utils.DepthFirstTraversal(node, false) {
-			t.rows = append(t.rows, childRow.(cache.HierarchicalTabularSourceRow))
+			t.rows = append(t.rows, childRow.(HierarchicalTabularSourceRow))
 			// change t.activeline so that the same row stays active, except if t.activeLine == 0
 			if t.activeLine != 0 && activeKey != nil && t.rows[len(t.rows)-1].Key() == activeKey {
 				t.activeLine = len(t.rows) - 1
@@ -138,6 +294,103 @@ func (t *Table) Scroll(amount int) {
 	}
 }
 
+// Return the rows leading from 'node' to the row whose key is 'key', both included, or nil if
+// there is no such row
+func pathToKey(node HierarchicalTabularSourceRow, key interface{}) []HierarchicalTabularSourceRow {
+	if node.Key() == key {
+		return []HierarchicalTabularSourceRow{node}
+	}
+	for _, child := range node.Children() {
+		if path := pathToKey(child.(HierarchicalTabularSourceRow), key); path != nil {
+			return append([]HierarchicalTabularSourceRow{node}, path...)
+		}
+	}
+	return nil
+}
+
+// Jump moves the cursor to the row whose key is 'key', opening the folds hiding it. False is
+// returned if the table has no such row.
+func (t *Table) Jump(key interface{}) bool {
+	for _, node := range t.nodes {
+		path := pathToKey(node, key)
+		if path == nil {
+			continue
+		}
+		for _, row := range path[:len(path)-1] {
+			row.SetTraversable(true, false)
+		}
+		t.Refresh()
+		for i, row := range t.rows {
+			if row.Key() == key {
+				t.Scroll(i - t.activeLine)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Call 'f' for the rows of the tree rooted at 'node' that can be recognized across runs along
+// with their session path, that is to say the session names of the row and of its ancestors
+// joined by slashes
+func walkSessionPaths(node HierarchicalTabularSourceRow, parent string, f func(string, HierarchicalTabularSourceRow)) {
+	row, ok := node.(sessionRow)
+	if !ok {
+		return
+	}
+	p := row.SessionName()
+	if parent != "" {
+		p = parent + "/" + p
+	}
+	f(p, node)
+	for _, child := range node.Children() {
+		walkSessionPaths(child.(HierarchicalTabularSourceRow), p, f)
+	}
+}
+
+// SessionState returns the session paths of the rows whose fold is open and the session path of
+// the row at the cursor, empty if there is none
+func (t Table) SessionState() ([]string, string) {
+	active, hasActive := t.ActiveKey()
+	open := make([]string, 0)
+	cursor := ""
+	for _, node := range t.nodes {
+		walkSessionPaths(node, "", func(p string, row HierarchicalTabularSourceRow) {
+			if row.Traversable() {
+				open = append(open, p)
+			}
+			if hasActive && cursor == "" && row.Key() == active {
+				cursor = p
+			}
+		})
+	}
+	return open, cursor
+}
+
+// RestoreSession opens the folds of the rows whose session path is listed in 'open' and moves the
+// cursor to the first row whose session path is 'cursor'. False is returned if there is no such
+// row.
+func (t *Table) RestoreSession(open []string, cursor string) bool {
+	opened := make(map[string]struct{}, len(open))
+	for _, p := range open {
+		opened[p] = struct{}{}
+	}
+	var key interface{}
+	for _, node := range t.nodes {
+		walkSessionPaths(node, "", func(p string, row HierarchicalTabularSourceRow) {
+			if _, exists := opened[p]; exists && !row.Traversable() {
+				row.SetTraversable(true, false)
+			}
+			if key == nil && cursor != "" && p == cursor {
+				key = row.Key()
+			}
+		})
+	}
+	t.Refresh()
+
+	return key != nil && t.Jump(key)
+}
+
 func (t *Table) Top() {
 	t.Scroll(-len(t.rows))
 }
@@ -146,6 +399,11 @@ func (t *Table) Bottom() {
 	t.Scroll(len(t.rows))
 }
 
+// AtBottom returns true if the cursor is on the last line of the table
+func (t Table) AtBottom() bool {
+	return t.activeLine >= len(t.rows)-1
+}
+
 func (t *Table) NextMatch(s string, ascending bool) bool {
 	if len(t.rows) == 0 {
 		return false
@@ -173,18 +431,20 @@ func (t *Table) NextMatch(s string, ascending bool) bool {
 }
 
 func (t Table) stringFromColumns(values map[string]text.StyledString, header bool) text.StyledString {
-	paddedColumns := make([]text.StyledString, len(t.source.Headers()))
-	for j, name := range t.source.Headers() {
+	headers := t.visibleHeaders()
+	paddedColumns := make([]text.StyledString, len(headers))
+	for j, name := range headers {
 		alignment := text.Left
 		if !header {
 			alignment = t.source.Alignment()[name]
 		}
-		paddedColumns[j] = values[name]
-		paddedColumns[j].Align(alignment, t.maxWidths[name])
+		width := t.columnWidth(name)
+		paddedColumns[j] = truncate(values[name], width)
+		paddedColumns[j].Align(alignment, width)
 	}
 
 	line := text.Join(paddedColumns, text.NewStyledString(t.sep))
-	line.Align(text.Left, t.width)
+	line.Align(text.Left, t.leftColumn+t.contentWidth())
 
 	return line
 }
@@ -203,6 +463,7 @@ func (t *Table) Resize(width int, height int) {
 		t.activeLine = utils.Bounded(t.activeLine, t.topLine, t.topLine+height-1)
 	}
 	t.width, t.height = width, height
+	t.ScrollHorizontally(0)
 }
 
 func (t *Table) Text() []text.LocalizedStyledString {
@@ -210,59 +471,685 @@ func (t *Table) Text() []text.LocalizedStyledString {
 
 	if t.height > 0 {
 		headers := make(map[string]text.StyledString)
-		for _, header := range t.source.Headers() {
-			headers[header] = text.NewStyledString(header)
+		for _, header := range t.visibleHeaders() {
+			headers[header] = text.NewStyledString(t.headerLabel(header))
 		}
 
 		s := t.stringFromColumns(headers, true)
 		s.Add(text.TableHeader)
+		texts = t.appendLine(texts, 0, s, "")
+	}
+
+	// Skip rows at the top of the table if needed to show every line of the row at the cursor
+	topLine := t.topLine
+	var activeLines []text.StyledString
+	if t.activeLine >= 0 && t.activeLine < len(t.rows) {
+		activeLines = t.rowLines(t.rows[t.activeLine])
+		if overflow := t.activeLine - topLine + len(activeLines) - t.NbrRows(); overflow > 0 {
+			topLine = utils.MinInt(topLine+overflow, t.activeLine)
+		}
+	}
+
+	y := 1
+	for i := topLine; y <= t.NbrRows() && i < len(t.rows); i++ {
+		lines := activeLines
+		if i != t.activeLine {
+			lines = []text.StyledString{t.stringFromColumns(t.rows[i].Tabular(t.location), false)}
+		}
+		for j, line := range lines {
+			if y > t.NbrRows() {
+				break
+			}
+			if i == t.activeLine {
+				line.Add(text.ActiveRow)
+			}
+			// Matches are highlighted on top of the style of the row at the cursor
+			line = line.Highlight(t.highlight, text.SearchMatch)
+			number := ""
+			if j == 0 {
+				number = strconv.Itoa(t.numbers.number(i, t.activeLine))
+			}
+			texts = t.appendLine(texts, y, line, number)
+			y++
+		}
+	}
+
+	return texts
+}
+
+// Append to 'texts' the line 'line' of the table at height 'y', preceded by 'number' in the
+// gutter if rows are numbered. The gutter stays in place when the table is scrolled
+// horizontally, so it is drawn over the part of the line hidden on the left.
+func (t Table) appendLine(texts []text.LocalizedStyledString, y int, line text.StyledString, number string) []text.LocalizedStyledString {
+	gutter := t.numbers.gutterWidth(len(t.rows))
+	texts = append(texts, text.LocalizedStyledString{
+		X: gutter - t.leftColumn,
+		Y: y,
+		S: line,
+	})
+	if gutter > 0 {
+		s := text.NewStyledString(fmt.Sprintf("%*s ", gutter-1, number))
+		if y == 0 {
+			s.Add(text.TableHeader)
+		}
 		texts = append(texts, text.LocalizedStyledString{
 			X: 0,
-			Y: 0,
+			Y: y,
 			S: s,
 		})
 	}
+	return texts
+}
 
-	for i := 0; i < t.NbrRows() && t.topLine+i < len(t.rows); i++ {
-		row := t.rows[t.topLine+i]
-		s := text.LocalizedStyledString{
-			X: 0,
-			Y: i + 1,
-			S: t.stringFromColumns(row.Tabular(t.location), false),
-		}
+// Return the lines showing 'row'. Rows take a single line except the row at the cursor when
+// wrapping is enabled: the part of its last column hidden past the right edge of the screen is
+// then written on the following lines.
+func (t Table) rowLines(row HierarchicalTabularSourceRow) []text.StyledString {
+	values := row.Tabular(t.location)
+	headers := t.visibleHeaders()
+	if !t.wrap || len(headers) == 0 {
+		return []text.StyledString{t.stringFromColumns(values, false)}
+	}
 
-		if t.topLine+i == t.activeLine {
-			s.S.Add(text.ActiveRow)
-		}
+	last := headers[len(headers)-1]
+	offset := t.lineWidth() - t.columnWidth(last)
+	width := utils.MinInt(t.leftColumn+t.contentWidth()-offset, t.columnWidth(last))
+	value := values[last]
+	if width <= 0 || value.Length() <= width {
+		return []text.StyledString{t.stringFromColumns(values, false)}
+	}
 
-		texts = append(texts, s)
+	chunks := value.Wrap(width)
+	values[last] = chunks[0]
+	lines := []text.StyledString{t.stringFromColumns(values, false)}
+	for _, chunk := range chunks[1:] {
+		line := text.Join([]text.StyledString{text.NewStyledString(strings.Repeat(" ", offset)), chunk}, text.StyledString{})
+		line.Align(text.Left, t.leftColumn+t.contentWidth())
+		lines = append(lines, line)
 	}
 
-	return texts
+	return lines
+}
+
+// SetWrap enables or disables the wrapping of the last column of the row at the cursor
+func (t *Table) SetWrap(wrap bool) {
+	t.wrap = wrap
 }
 
 func (t Table) OpenInBrowser(browser string) error {
 	if t.activeLine >= 0 && t.activeLine < len(t.rows) {
 		if url := t.rows[t.activeLine].URL(); url != "" {
-			argv := []string{path.Base(browser), url}
-			process, err := os.StartProcess(browser, argv, &os.ProcAttr{})
-			if err != nil {
-				return err
-			}
-
-			if err := process.Release(); err != nil {
-				return err
-			}
+			return startBrowser(browser, url)
 		}
 	}
 
 	return nil
 }
 
+// Open 'url' with the web browser at path 'browser' without waiting for the browser to exit
+func startBrowser(browser string, url string) error {
+	argv := []string{path.Base(browser), url}
+	process, err := os.StartProcess(browser, argv, &os.ProcAttr{})
+	if err != nil {
+		return err
+	}
+
+	return process.Release()
+}
+
+var ErrUnsupportedView = errors.New("this presentation of rows is not supported by the data source")
+
+// SetGrouped shows or hides the intermediate level of the hierarchy of rows if the source of
+// the table supports it
+func (t *Table) SetGrouped(grouped bool) error {
+	source, ok := t.source.(GroupedDataSource)
+	if !ok {
+		return ErrUnsupportedView
+	}
+	source.SetGrouped(grouped)
+	t.Refresh()
+	return nil
+}
+
+// SetJobsOnly lists the leaves of the hierarchy of rows in a flat table if the source of the
+// table supports it
+func (t *Table) SetJobsOnly(jobsOnly bool) error {
+	source, ok := t.source.(JobsDataSource)
+	if !ok {
+		return ErrUnsupportedView
+	}
+	source.SetJobsOnly(jobsOnly)
+	t.Refresh()
+	return nil
+}
+
+// SetSort sorts rows by the values of 'column' if the source of the table supports it
+func (t *Table) SetSort(column string, reverse bool) error {
+	source, ok := t.source.(SortableDataSource)
+	if !ok {
+		return ErrUnsupportedView
+	}
+	source.SetSort(column, reverse)
+	t.sortColumn, t.reverse = column, reverse
+	t.Refresh()
+	return nil
+}
+
+// Return the text of the header of the column 'header'. The column used to sort rows is marked
+// by an arrow pointing up in ascending order and down in descending order.
+func (t Table) headerLabel(header string) string {
+	switch {
+	case header != t.sortColumn:
+		return header
+	case t.reverse:
+		return header + " ▼"
+	default:
+		return header + " ▲"
+	}
+}
+
+// SetTimestampMode selects how timestamps are shown in the logs written to disk if the source of
+// the table supports it
+func (t *Table) SetTimestampMode(mode TimestampMode) error {
+	source, ok := t.source.(TimestampDataSource)
+	if !ok {
+		return ErrUnsupportedView
+	}
+	source.SetTimestampMode(mode)
+	return nil
+}
+
+// SetTimingGutter shows or hides the elapsed time in front of each line of the logs written to
+// disk if the source of the table supports it
+func (t *Table) SetTimingGutter(show bool) error {
+	source, ok := t.source.(TimestampDataSource)
+	if !ok {
+		return ErrUnsupportedView
+	}
+	source.SetTimingGutter(show)
+	return nil
+}
+
+// Summary counts the pipelines of the table by state if the source of the table supports it
+func (t Table) Summary() (Summary, error) {
+	source, ok := t.source.(SummaryDataSource)
+	if !ok {
+		return Summary{}, ErrUnsupportedView
+	}
+	return source.Summary(), nil
+}
+
+// Health returns the outcome of the last request made to each provider if the source of the
+// table supports it
+func (t Table) Health() ([]cache.ProviderHealth, error) {
+	source, ok := t.source.(HealthDataSource)
+	if !ok {
+		return nil, ErrUnsupportedView
+	}
+	return source.Health(), nil
+}
+
+// Headers returns the names of the columns of the table
+func (t Table) Headers() []string {
+	return t.source.Headers()
+}
+
+// ActiveKey returns the key of the row at the cursor, if there is one
+func (t Table) ActiveKey() (interface{}, bool) {
+	if t.activeLine < 0 || t.activeLine >= len(t.rows) {
+		return nil, false
+	}
+	return t.rows[t.activeLine].Key(), true
+}
+
+// Return the source of the table if it supports deployments, along with the key of the row at
+// the cursor
+func (t Table) deploymentSource() (DeploymentDataSource, interface{}, error) {
+	source, ok := t.source.(DeploymentDataSource)
+	if !ok {
+		return nil, nil, ErrUnsupportedView
+	}
+	key, exists := t.ActiveKey()
+	if !exists {
+		return nil, nil, ErrNoDeploymentHere
+	}
+	return source, key, nil
+}
+
+// Deployment returns the deployment at the cursor
+func (t Table) Deployment() (cache.Deployment, error) {
+	source, key, err := t.deploymentSource()
+	if err != nil {
+		return cache.Deployment{}, err
+	}
+	return source.Deployment(key)
+}
+
+// StopEnvironment stops the environment of the deployment at the cursor
+func (t Table) StopEnvironment(ctx context.Context) error {
+	return t.ActiveRow().StopEnvironment(ctx)
+}
+
+// RetryDeployment runs again the job of the deployment at the cursor
+func (t Table) RetryDeployment(ctx context.Context) error {
+	return t.ActiveRow().RetryDeployment(ctx)
+}
+
+func (t Table) cancelSource() (CancelDataSource, interface{}, error) {
+	source, ok := t.source.(CancelDataSource)
+	if !ok {
+		return nil, nil, ErrUnsupportedView
+	}
+	key, exists := t.ActiveKey()
+	if !exists {
+		return nil, nil, ErrNothingToCancelHere
+	}
+	return source, key, nil
+}
+
+// Cancelable returns a description of the pipeline or job at the cursor if it can be canceled
+func (t Table) Cancelable() (string, error) {
+	source, key, err := t.cancelSource()
+	if err != nil {
+		return "", err
+	}
+	return source.Cancelable(key)
+}
+
+// Cancel cancels the pipeline or job at the cursor
+func (t Table) Cancel(ctx context.Context) error {
+	return t.ActiveRow().Cancel(ctx)
+}
+
+func (t Table) restartSource() (RestartDataSource, interface{}, error) {
+	source, ok := t.source.(RestartDataSource)
+	if !ok {
+		return nil, nil, ErrUnsupportedView
+	}
+	key, exists := t.ActiveKey()
+	if !exists {
+		return nil, nil, ErrNoPipelineHere
+	}
+	return source, key, nil
+}
+
+// Restartable returns a description of the pipeline at the cursor if it can be run again
+func (t Table) Restartable() (string, error) {
+	source, key, err := t.restartSource()
+	if err != nil {
+		return "", err
+	}
+	return source.Restartable(key)
+}
+
+// Restart runs again the pipeline at the cursor and returns the key of the row of the pipeline
+// started, nil if the row does not exist yet
+func (t Table) Restart(ctx context.Context) (interface{}, error) {
+	return t.ActiveRow().Restart(ctx)
+}
+
+func (t Table) retrySource() (RetryDataSource, interface{}, error) {
+	source, ok := t.source.(RetryDataSource)
+	if !ok {
+		return nil, nil, ErrUnsupportedView
+	}
+	key, exists := t.ActiveKey()
+	if !exists {
+		return nil, nil, ErrNoJobHere
+	}
+	return source, key, nil
+}
+
+// Retryable returns a description of the job at the cursor if it can be run again
+func (t Table) Retryable() (string, error) {
+	source, key, err := t.retrySource()
+	if err != nil {
+		return "", err
+	}
+	return source.Retryable(key)
+}
+
+// Retry runs again the job at the cursor and returns the key of the row of the job started
+func (t Table) Retry(ctx context.Context) (interface{}, error) {
+	return t.ActiveRow().Retry(ctx)
+}
+
+func (t Table) manualJobSource() (ManualJobDataSource, interface{}, error) {
+	source, ok := t.source.(ManualJobDataSource)
+	if !ok {
+		return nil, nil, ErrUnsupportedView
+	}
+	key, exists := t.ActiveKey()
+	if !exists {
+		return nil, nil, ErrNoJobHere
+	}
+	return source, key, nil
+}
+
+// ManualJob returns a description of the job at the cursor if it waits for the action of a user
+func (t Table) ManualJob() (string, error) {
+	source, key, err := t.manualJobSource()
+	if err != nil {
+		return "", err
+	}
+	return source.ManualJob(key)
+}
+
+// StartManualJob starts the job at the cursor, which waits for the action of a user
+func (t Table) StartManualJob(ctx context.Context) error {
+	return t.ActiveRow().StartManualJob(ctx)
+}
+
+// TriggerPipelines starts a pipeline on 'ref' of the repository at 'repositoryURL' and returns
+// the web pages of the pipelines started
+func (t Table) TriggerPipelines(ctx context.Context, repositoryURL string, ref string) ([]string, error) {
+	return t.ActiveRow().TriggerPipelines(ctx, repositoryURL, ref)
+}
+
+// Artifacts returns a data source listing the artifacts of the job at the cursor along with a
+// description of the job
+func (t Table) Artifacts(ctx context.Context) (HierarchicalTabularDataSource, string, error) {
+	return t.ActiveRow().Artifacts(ctx)
+}
+
+// TestReport fetches the results of the tests of the job at the cursor and returns the key of
+// the row summarizing them
+func (t Table) TestReport(ctx context.Context) (interface{}, cache.TestReport, error) {
+	return t.ActiveRow().TestReport(ctx)
+}
+
+// LogRunning returns true if the log of the row at the cursor belongs to a job that is still
+// running and whose log can be followed
+func (t Table) LogRunning() bool {
+	return t.ActiveLog().LogRunning()
+}
+
+// FollowLog starts appending the lines added to the log of the job at the cursor to the file at
+// 'logPath' in the background until the job is finished or 'ctx' is done. The outcome is sent on
+// the channel returned.
+func (t Table) FollowLog(ctx context.Context, logPath string, interval time.Duration) <-chan error {
+	return t.ActiveLog().FollowLog(ctx, logPath, interval)
+}
+
+// WriteLogsToDisk writes the logs of all the jobs of the pipeline or stage at the cursor to a
+// file of 'dir' and returns the path of the file
+func (t Table) WriteLogsToDisk(ctx context.Context, dir string) (string, error) {
+	return t.ActiveLog().WriteLogsToDisk(ctx, dir)
+}
+
+// SaveLog writes the raw log of the job at the cursor to the file whose path is given by
+// 'pathTemplate' and returns the path of the file
+func (t Table) SaveLog(ctx context.Context, pathTemplate *template.Template) (string, error) {
+	return t.ActiveLog().SaveLog(ctx, pathTemplate)
+}
+
+// SearchLogs looks for 'pattern' in the logs of the jobs of the pipeline at the cursor if the
+// source of the table supports it
+func (t Table) SearchLogs(ctx context.Context, pattern *regexp.Regexp) (LogSearch, error) {
+	return t.ActiveLog().SearchLogs(ctx, pattern)
+}
+
+// Problems returns the problems found in the log of the job at the cursor
+func (t Table) Problems() ([]Problem, error) {
+	return t.ActiveLog().Problems()
+}
+
+// LogLine returns the line of the log of the row at the cursor to show first, or 0 to show the
+// beginning of the log
+func (t Table) LogLine() int {
+	return t.ActiveLog().LogLine()
+}
+
+// Details returns the description of the row at the cursor
+func (t *Table) Details() (string, error) {
+	if t.activeLine < 0 || t.activeLine >= len(t.rows) {
+		return "", ErrNoDetailsHere
+	}
+	return t.source.Details(t.rows[t.activeLine].Key())
+}
+
 func (t *Table) WriteToDisk(ctx context.Context, dir string) (string, error) {
-	if t.activeLine >= 0 && t.activeLine < len(t.rows) {
+	return t.ActiveLog().WriteToDisk(ctx, dir)
+}
+
+// RowLog designates the log of a row of a table. Unlike the table, it does not change when the
+// cursor moves or the table is refreshed so the log can be fetched in the background.
+type RowLog struct {
+	source HierarchicalTabularDataSource
+	key    interface{}
+	exists bool
+}
+
+// ActiveLog returns the log of the row at the cursor
+func (t Table) ActiveLog() RowLog {
+	key, exists := t.ActiveKey()
+	return RowLog{
+		source: t.source,
+		key:    key,
+		exists: exists,
+	}
+}
+
+// Key returns the key of the row of the log and false if there was no row at the cursor
+func (l RowLog) Key() (interface{}, bool) {
+	return l.key, l.exists
+}
 
+// WriteToDisk writes the log of the row to a file of 'dir' and returns the path of the file
+func (l RowLog) WriteToDisk(ctx context.Context, dir string) (string, error) {
+	if !l.exists {
+		return "", cache.ErrNoLogHere
+	}
+	return l.source.WriteToDisk(ctx, l.key, dir)
+}
+
+// WriteLogsToDisk writes the logs of all the jobs of the pipeline or stage of the row to a file
+// of 'dir' and returns the path of the file
+func (l RowLog) WriteLogsToDisk(ctx context.Context, dir string) (string, error) {
+	source, ok := l.source.(PipelineLogDataSource)
+	if !ok || !l.exists {
+		return "", cache.ErrNoLogHere
+	}
+	return source.WriteLogsToDisk(ctx, l.key, dir)
+}
+
+// SaveLog writes the raw log of the job of the row to the file whose path is given by
+// 'pathTemplate' and returns the path of the file
+func (l RowLog) SaveLog(ctx context.Context, pathTemplate *template.Template) (string, error) {
+	if !l.exists {
+		return "", ErrNoJobHere
+	}
+	source, ok := l.source.(LogSaveDataSource)
+	if !ok {
+		return "", ErrUnsupportedView
+	}
+	return source.SaveLog(ctx, l.key, pathTemplate)
+}
+
+// SearchLogs looks for 'pattern' in the logs of the jobs of the pipeline of the row if the source
+// of the table supports it
+func (l RowLog) SearchLogs(ctx context.Context, pattern *regexp.Regexp) (LogSearch, error) {
+	source, ok := l.source.(LogSearchDataSource)
+	if !ok {
+		return LogSearch{}, ErrUnsupportedView
+	}
+	if !l.exists {
+		return LogSearch{}, cache.ErrNoLogHere
+	}
+	return source.SearchLogs(ctx, l.key, pattern)
+}
+
+// LogLine returns the line of the log to show first, or 0 to show the beginning of the log
+func (l RowLog) LogLine() int {
+	source, ok := l.source.(ProblemDataSource)
+	if !ok || !l.exists {
+		return 0
+	}
+	return source.LogLine(l.key)
+}
+
+// Problems returns the problems found in the log the last time it was written to disk
+func (l RowLog) Problems() ([]Problem, error) {
+	source, ok := l.source.(ProblemDataSource)
+	if !ok {
+		return nil, ErrUnsupportedView
+	}
+	if !l.exists {
+		return nil, nil
+	}
+	return source.Problems(l.key), nil
+}
+
+// LogRunning returns true if the log belongs to a job that is still running and whose log can
+// be followed
+func (l RowLog) LogRunning() bool {
+	source, ok := l.source.(LogFollowDataSource)
+	return ok && l.exists && source.LogRunning(l.key)
+}
+
+// FollowLog starts appending the lines added to the log to the file at 'logPath' in the
+// background until the job is finished or 'ctx' is done. The outcome is sent on the channel
+// returned.
+func (l RowLog) FollowLog(ctx context.Context, logPath string, interval time.Duration) <-chan error {
+	errc := make(chan error, 1)
+	source, ok := l.source.(LogFollowDataSource)
+	switch {
+	case !ok:
+		errc <- ErrUnsupportedView
+	case !l.exists:
+		errc <- cache.ErrNoLogHere
+	default:
+		go func() {
+			errc <- source.FollowLog(ctx, l.key, logPath, interval)
+		}()
+	}
+	return errc
+}
+
+// RowRef designates a row of a table. Like RowLog, it does not change when the cursor moves or
+// the table is refreshed so that actions on the row can run in the background.
+type RowRef struct {
+	source HierarchicalTabularDataSource
+	key    interface{}
+	exists bool
+}
+
+// ActiveRow returns the row at the cursor
+func (t Table) ActiveRow() RowRef {
+	key, exists := t.ActiveKey()
+	return RowRef{
+		source: t.source,
+		key:    key,
+		exists: exists,
+	}
+}
+
+// Cancel cancels the pipeline or job of the row
+func (r RowRef) Cancel(ctx context.Context) error {
+	source, ok := r.source.(CancelDataSource)
+	if !ok {
+		return ErrUnsupportedView
+	}
+	if !r.exists {
+		return ErrNothingToCancelHere
+	}
+	return source.Cancel(ctx, r.key)
+}
+
+// Artifacts returns a data source listing the artifacts of the job of the row along with a
+// description of the job
+func (r RowRef) Artifacts(ctx context.Context) (HierarchicalTabularDataSource, string, error) {
+	source, ok := r.source.(ArtifactDataSource)
+	if !ok {
+		return nil, "", ErrUnsupportedView
+	}
+	if !r.exists {
+		return nil, "", ErrNoJobHere
+	}
+	return source.Artifacts(ctx, r.key)
+}
+
+// TestReport fetches the results of the tests of the job of the row and returns the key of the
+// row summarizing them
+func (r RowRef) TestReport(ctx context.Context) (interface{}, cache.TestReport, error) {
+	source, ok := r.source.(TestReportDataSource)
+	if !ok {
+		return nil, cache.TestReport{}, ErrUnsupportedView
+	}
+	if !r.exists {
+		return nil, cache.TestReport{}, ErrNoJobHere
+	}
+	return source.TestReport(ctx, r.key)
+}
+
+// Restart runs again the pipeline of the row and returns the key of the row of the pipeline
+// started, nil if the row does not exist yet
+func (r RowRef) Restart(ctx context.Context) (interface{}, error) {
+	source, ok := r.source.(RestartDataSource)
+	if !ok {
+		return nil, ErrUnsupportedView
+	}
+	if !r.exists {
+		return nil, ErrNoPipelineHere
+	}
+	return source.Restart(ctx, r.key)
+}
+
+// Retry runs again the job of the row and returns the key of the row of the job started
+func (r RowRef) Retry(ctx context.Context) (interface{}, error) {
+	source, ok := r.source.(RetryDataSource)
+	if !ok {
+		return nil, ErrUnsupportedView
+	}
+	if !r.exists {
+		return nil, ErrNoJobHere
+	}
+	return source.Retry(ctx, r.key)
+}
+
+// StartManualJob starts the job of the row, which waits for the action of a user
+func (r RowRef) StartManualJob(ctx context.Context) error {
+	source, ok := r.source.(ManualJobDataSource)
+	if !ok {
+		return ErrUnsupportedView
+	}
+	if !r.exists {
+		return ErrNoJobHere
+	}
+	return source.StartManualJob(ctx, r.key)
+}
+
+// TriggerPipelines starts a pipeline on 'ref' of the repository at 'repositoryURL' with the
+// source of the row and returns the web pages of the pipelines started. The row itself does not
+// matter.
+func (r RowRef) TriggerPipelines(ctx context.Context, repositoryURL string, ref string) ([]string, error) {
+	source, ok := r.source.(TriggerDataSource)
+	if !ok {
+		return nil, ErrUnsupportedView
+	}
+	return source.TriggerPipelines(ctx, repositoryURL, ref)
+}
+
+// StopEnvironment stops the environment of the deployment of the row
+func (r RowRef) StopEnvironment(ctx context.Context) error {
+	source, ok := r.source.(DeploymentDataSource)
+	if !ok {
+		return ErrUnsupportedView
+	}
+	if !r.exists {
+		return ErrNoDeploymentHere
+	}
+	return source.StopEnvironment(ctx, r.key)
+}
+
+// RetryDeployment runs again the job of the deployment of the row
+func (r RowRef) RetryDeployment(ctx context.Context) error {
+	source, ok := r.source.(DeploymentDataSource)
+	if !ok {
+		return ErrUnsupportedView
+	}
+	if !r.exists {
+		return ErrNoDeploymentHere
 	}
-	key := t.rows[t.activeLine].Key()
-	return t.source.WriteToDisk(ctx, key, dir)
+	return source.RetryDeployment(ctx, r.key)
 }
@@ -2,9 +2,11 @@ package tui
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/mattn/go-runewidth"
 	"github.com/nbedos/citop/cache"
 	"github.com/nbedos/citop/text"
@@ -65,8 +67,8 @@ type testSource struct {
 	rows []testRow
 }
 
-func (s testSource) Rows() []cache.HierarchicalTabularSourceRow {
-	rows := make([]cache.HierarchicalTabularSourceRow, 0, len(s.rows))
+func (s testSource) Rows() []HierarchicalTabularSourceRow {
+	rows := make([]HierarchicalTabularSourceRow, 0, len(s.rows))
 	for _, row := range s.rows {
 		row := row
 		rows = append(rows, &row)
@@ -83,6 +85,10 @@ func (r testSource) WriteToDisk(ctx context.Context, key interface{}, dir string
 	return "", nil
 }
 
+func (r testSource) Details(key interface{}) (string, error) {
+	return "", nil
+}
+
 var source = testSource{
 	rows: []testRow{
 		{value: "a"},
@@ -424,3 +430,131 @@ func TestTable_NextMatch(t *testing.T) {
 	})
 
 }
+
+func TestTable_SetGrouped(t *testing.T) {
+	table, err := NewTable(source, 10, 10, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := table.SetGrouped(false); err != ErrUnsupportedView {
+		t.Fatalf("expected %v but got %v", ErrUnsupportedView, err)
+	}
+}
+
+func TestTable_ScrollHorizontally(t *testing.T) {
+	table, err := NewTable(source, 3, 10, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxOffset := table.lineWidth() - 3
+	if maxOffset <= 0 {
+		t.Fatalf("expected lines wider than the table but got width %d", table.lineWidth())
+	}
+
+	testCases := []struct {
+		amount   int
+		expected int
+	}{
+		{amount: -1, expected: 0},
+		{amount: 1, expected: 1},
+		{amount: 999, expected: maxOffset},
+		{amount: -1, expected: maxOffset - 1},
+		{amount: -999, expected: 0},
+	}
+	for _, testCase := range testCases {
+		table.ScrollHorizontally(testCase.amount)
+		if table.leftColumn != testCase.expected {
+			t.Fatalf("expected leftColumn %d but got %d", testCase.expected, table.leftColumn)
+		}
+	}
+
+	t.Run("text is shifted to the left", func(t *testing.T) {
+		table.ScrollHorizontally(2)
+		for _, line := range table.Text() {
+			if line.X != -2 {
+				t.Fatalf("expected line at X=-2 but got X=%d", line.X)
+			}
+			if width := line.S.Length(); width < 2+3 {
+				t.Fatalf("expected line filling the table but got width %d", width)
+			}
+		}
+	})
+
+	t.Run("widening the table reduces the offset", func(t *testing.T) {
+		table.ScrollHorizontally(999)
+		table.Resize(table.lineWidth()-1, 10)
+		if table.leftColumn != 1 {
+			t.Fatalf("expected leftColumn 1 but got %d", table.leftColumn)
+		}
+	})
+}
+
+func TestTable_SetWrap(t *testing.T) {
+	wideSource := testSource{
+		rows: []testRow{
+			{value: "a"},
+			{value: "0123456789"},
+			{value: "b"},
+		},
+	}
+	table, err := NewTable(wideSource, 4, 4, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Scroll(1)
+	lines := func() []string {
+		texts := table.Text()
+		values := make([]string, 0, len(texts))
+		for _, text := range texts[1:] {
+			values = append(values, text.S.String())
+		}
+		return values
+	}
+
+	if diff := cmp.Diff([]string{"a         ", "0123456789", "b         "}, lines()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	table.SetWrap(true)
+	t.Run("the last column of the row at the cursor is wrapped", func(t *testing.T) {
+		if diff := cmp.Diff([]string{"0123      ", "4567", "89  "}, lines()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("wrapping follows horizontal scrolling", func(t *testing.T) {
+		table.ScrollHorizontally(2)
+		if diff := cmp.Diff([]string{"a         ", "012345    ", "6789  "}, lines()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestTable_headerLabel(t *testing.T) {
+	c := cache.NewCache(nil, nil)
+	source := NewBuildsByCommit(&c)
+	table, err := NewTable(&source, 200, 10, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := func() string {
+		return table.Text()[0].S.String()
+	}
+
+	if strings.ContainsAny(header(), "▲▼") {
+		t.Fatalf("expected no sort indicator in %q", header())
+	}
+	if err := table.SetSort("DURATION", false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(header(), "DURATION ▲") {
+		t.Fatalf("expected ascending indicator in %q", header())
+	}
+	if err := table.SetSort("DURATION", true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(header(), "DURATION ▼") {
+		t.Fatalf("expected descending indicator in %q", header())
+	}
+}
@@ -0,0 +1,47 @@
+package tui
+
+import (
+	"text/template"
+
+	"github.com/nbedos/citop/text"
+)
+
+// RowData is the data available to the templates of the NAME column
+type RowData struct {
+	// "pipeline", "stage" or "job"
+	Type     string
+	Provider string
+	// Identifier of the pipeline the row belongs to
+	Pipeline string
+	// Name of the stage of a job. Empty for other rows and for jobs outside of a stage.
+	Stage string
+	Name  string
+	State string
+	Ref   string
+	Sha   string
+}
+
+// RowTemplates defines how the NAME column is composed for each type of row. A nil template
+// selects the default content of the column.
+type RowTemplates struct {
+	Pipeline *template.Template
+	Stage    *template.Template
+	Job      *template.Template
+}
+
+// NewRowTemplate parses a template of the NAME column. The template is applied to a RowData
+// and has access to the "style" function.
+func NewRowTemplate(name string, s string) (*template.Template, error) {
+	tmpl, err := text.NewTemplate(name, s, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Templates are executed while drawing the table, at which point errors can no longer be
+	// reported, so check for invalid fields and styles right away
+	if _, err := text.ExecuteTemplate(tmpl, RowData{}); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package tui
+
+import "time"
+
+func queryTerminal(query string, timeout time.Duration) (string, error) {
+	return "", errBackgroundQueryUnsupported
+}
@@ -0,0 +1,64 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package tui
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Write 'query' to the controlling terminal and return its response. The terminal is put in
+// non-canonical mode for the duration of the call so that the response can be read without
+// waiting for a newline and without being echoed.
+func queryTerminal(query string, timeout time.Duration) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+	fd := int(tty.Fd())
+
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return "", err
+	}
+	raw := *termios
+	raw.Lflag &^= unix.ECHO | unix.ICANON
+	// Reads return as soon as a byte is available or after VTIME tenths of second
+	raw.Cc[unix.VMIN] = 0
+	raw.Cc[unix.VTIME] = 1
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return "", err
+	}
+	defer unix.IoctlSetTermios(fd, ioctlSetTermios, termios)
+
+	if _, err := tty.WriteString(query); err != nil {
+		return "", err
+	}
+
+	response := strings.Builder{}
+	buf := make([]byte, 64)
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); {
+		n, err := tty.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		if n == 0 {
+			if response.Len() > 0 {
+				break
+			}
+			continue
+		}
+		response.Write(buf[:n])
+		// Responses are terminated either by BEL or ST
+		if s := response.String(); strings.HasSuffix(s, "\a") || strings.HasSuffix(s, "\x1b\\") {
+			break
+		}
+	}
+
+	return response.String(), nil
+}
@@ -0,0 +1,154 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// testReportsByJob holds the last test report fetched for each job
+type testReportsByJob struct {
+	mux     sync.Mutex
+	reports map[buildRowKey]cache.TestReport
+}
+
+func newTestReportsByJob() *testReportsByJob {
+	return &testReportsByJob{
+		reports: make(map[buildRowKey]cache.TestReport),
+	}
+}
+
+func (r *testReportsByJob) set(key buildRowKey, report cache.TestReport) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.reports[jobKeyOf(key)] = report
+}
+
+func (r *testReportsByJob) get(key buildRowKey) (cache.TestReport, bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	report, exists := r.reports[jobKeyOf(key)]
+	return report, exists
+}
+
+// Return the number of tests in each state, e.g. "12 passed, 2 failed, 1 skipped"
+func testCounts(report cache.TestReport) string {
+	counts := []string{fmt.Sprintf("%d passed", report.Total-report.Failed-report.Skipped)}
+	if report.Failed > 0 {
+		counts = append(counts, fmt.Sprintf("%d failed", report.Failed))
+	}
+	if report.Skipped > 0 {
+		counts = append(counts, fmt.Sprintf("%d skipped", report.Skipped))
+	}
+	return strings.Join(counts, ", ")
+}
+
+// Return the full name of a test, prefixed by its suite if any
+func testName(c cache.TestCase) string {
+	if c.Suite == "" {
+		return c.Name
+	}
+	return c.Suite + "." + c.Name
+}
+
+// Return the row summarizing the test report of the job whose row is 'job'. The tests that
+// failed are listed under the summary.
+func buildRowFromTestReport(job buildRow, report cache.TestReport) buildRow {
+	key := job.key
+	key.testReport = true
+	row := buildRow{
+		key:      key,
+		type_:    "T",
+		state:    cache.Passed,
+		name:     "Tests: " + testCounts(report),
+		url:      job.url,
+		provider: job.provider,
+	}
+	if report.Failed > 0 {
+		row.state = cache.Failed
+	}
+
+	for i, failure := range report.Failures {
+		childKey := key
+		childKey.test = i + 1
+		name := testName(failure)
+		if failure.Message != "" {
+			name += ": " + failure.Message
+		}
+		row.children = append(row.children, &buildRow{
+			key:      childKey,
+			type_:    "T",
+			state:    cache.Failed,
+			name:     name,
+			url:      job.url,
+			provider: job.provider,
+		})
+	}
+
+	return row
+}
+
+// List the test report fetched for each job of 'row' under the job
+func (s BuildsByCommit) addTestReports(row *buildRow) {
+	for _, node := range utils.DepthFirstTraversal(row, true) {
+		job := node.(*buildRow)
+		if job.type_ != "J" {
+			continue
+		}
+		if report, exists := s.testReports.get(job.key); exists {
+			child := buildRowFromTestReport(*job, report)
+			job.children = append(job.children, &child)
+		}
+	}
+}
+
+// TestReport fetches the results of the tests of the job designated by 'key' and returns the
+// key of the row summarizing them. The summary is listed under the job along with the tests
+// that failed.
+func (s BuildsByCommit) TestReport(ctx context.Context, key interface{}) (interface{}, cache.TestReport, error) {
+	if _, err := s.job(key); err != nil {
+		return nil, cache.TestReport{}, err
+	}
+	buildKey := key.(buildRowKey)
+	report, err := s.cache.TestReport(ctx, buildKey.accountID, buildKey.buildID, buildKey.stageID, buildKey.jobID)
+	if err != nil {
+		return nil, cache.TestReport{}, err
+	}
+	s.testReports.set(buildKey, report)
+
+	buildKey.testReport = true
+	return buildKey, report, nil
+}
+
+// Return the description of the test report of 'job'
+func testReportDetails(job cache.Job, report cache.TestReport) string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "Test report: %s\n", job.Name)
+	fmt.Fprintf(&b, "Tests:       %d (%s)\n", report.Total, testCounts(report))
+	if len(report.Failures) > 0 {
+		b.WriteString("\nFailed tests:\n")
+		for _, failure := range report.Failures {
+			fmt.Fprintf(&b, "    %s\n", testName(failure))
+		}
+	}
+
+	return b.String()
+}
+
+// Return the description of a failed test along with its message
+func testCaseDetails(c cache.TestCase) string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "Test:  %s\n", c.Name)
+	if c.Suite != "" {
+		fmt.Fprintf(&b, "Suite: %s\n", c.Suite)
+	}
+	if c.Message != "" {
+		fmt.Fprintf(&b, "\n%s\n", c.Message)
+	}
+
+	return b.String()
+}
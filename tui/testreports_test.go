@@ -0,0 +1,136 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+type mockTestReportProvider struct {
+	mockProvider
+	report cache.TestReport
+}
+
+func (p mockTestReportProvider) TestReport(ctx context.Context, build cache.Build, job cache.Job) (cache.TestReport, error) {
+	return p.report, nil
+}
+
+func TestBuildsByCommit_TestReport(t *testing.T) {
+	report := cache.TestReport{
+		Total:   4,
+		Failed:  1,
+		Skipped: 1,
+		Failures: []cache.TestCase{
+			{Suite: "tests.test_term", Name: "test_record", Message: "OSError: no such device"},
+		},
+	}
+	c := cache.NewCache([]cache.CIProvider{mockTestReportProvider{mockProvider: mockProvider{id: "id"}, report: report}}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+	ctx := context.Background()
+
+	if _, _, err := source.TestReport(ctx, stageAsRow.key); err != ErrNoJobHere {
+		t.Fatalf("expected %v but got %v", ErrNoJobHere, err)
+	}
+	key, r, err := source.TestReport(ctx, jobAsRow.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Failed != 1 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+
+	// The summary of the report and the failed test are listed under the job
+	var summary *buildRow
+	for _, row := range source.Rows() {
+		for _, node := range utils.DepthFirstTraversal(row.(*buildRow), true) {
+			if node.(*buildRow).key == key {
+				summary = node.(*buildRow)
+			}
+		}
+	}
+	if summary == nil {
+		t.Fatal("summary of the test report not found")
+	}
+	if summary.name != "Tests: 2 passed, 1 failed, 1 skipped" || summary.state != cache.Failed {
+		t.Fatalf("unexpected summary %q (%s)", summary.name, summary.state)
+	}
+	if len(summary.children) != 1 || summary.children[0].name != "tests.test_term.test_record: OSError: no such device" {
+		t.Fatalf("unexpected failed tests %+v", summary.children)
+	}
+
+	details, err := source.Details(summary.children[0].key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(details, "Suite: tests.test_term") || !strings.Contains(details, "OSError") {
+		t.Fatalf("unexpected details %q", details)
+	}
+	details, err = source.Details(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(details, "Tests:       4 (2 passed, 1 failed, 1 skipped)") {
+		t.Fatalf("unexpected details %q", details)
+	}
+
+	// Rows of the test report are not jobs
+	if _, err := source.Retryable(key); err != ErrNoJobHere {
+		t.Fatalf("expected %v but got %v", ErrNoJobHere, err)
+	}
+}
+
+func TestController_showTestReport(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, DefaultStyleSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tui.Finish()
+	}()
+	report := cache.TestReport{
+		Total:  2,
+		Failed: 1,
+		Failures: []cache.TestCase{
+			{Suite: "tests.test_term", Name: "test_record"},
+		},
+	}
+	c := cache.NewCache([]cache.CIProvider{mockTestReportProvider{mockProvider: mockProvider{id: "id"}, report: report}}, nil)
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+	controller, err := NewController(&tui, &source, time.UTC, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.resize(80, 20)
+	controller.refresh()
+
+	if !controller.table.Jump(jobAsRow.key) {
+		t.Fatal("job row not found")
+	}
+	if err := controller.showTestReport(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The report is fetched in the background and listed once the outcome is applied
+	if err := applyOutcome(t, &controller); err != nil {
+		t.Fatal(err)
+	}
+	expected := jobAsRow.key
+	expected.testReport = true
+	expected.test = 1
+	if key, _ := controller.table.ActiveKey(); key != expected {
+		t.Fatalf("expected the cursor on %v but got %v", expected, key)
+	}
+}
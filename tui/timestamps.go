@@ -0,0 +1,221 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/utils"
+)
+
+// TimestampMode tells how the timestamps prefixing the lines of logs are shown
+type TimestampMode string
+
+const (
+	// Show timestamps as written by the provider
+	TimestampsKeep TimestampMode = "keep"
+	// Remove timestamps
+	TimestampsHide TimestampMode = "hide"
+	// Replace timestamps by the time elapsed since the start of the job
+	TimestampsRelative TimestampMode = "relative"
+)
+
+// ParseTimestampMode returns the mode named 's'. An empty string selects TimestampsKeep.
+func ParseTimestampMode(s string) (TimestampMode, error) {
+	switch m := TimestampMode(strings.ToLower(s)); m {
+	case "":
+		return TimestampsKeep, nil
+	case TimestampsKeep, TimestampsHide, TimestampsRelative:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid timestamp mode %q (expected \"keep\", \"hide\" or \"relative\")", s)
+	}
+}
+
+// Return the mode following 'm' when the user cycles through modes
+func (m TimestampMode) next() TimestampMode {
+	switch m {
+	case TimestampsKeep:
+		return TimestampsHide
+	case TimestampsHide:
+		return TimestampsRelative
+	default:
+		return TimestampsKeep
+	}
+}
+
+// RFC 3339 timestamp, possibly between brackets, with a space instead of the "T" separator or
+// without time zone, followed by at most one space
+var timestampPrefix = regexp.MustCompile(`^(\[)?(\d{4}-\d{2}-\d{2})[T ](\d{2}:\d{2}:\d{2}(?:[.,]\d+)?)(Z|[+-]\d{2}:?\d{2})?(\])? ?`)
+
+// Timestamp written by the Buildkite agent before each chunk of output, in milliseconds since the
+// epoch, e.g. "\x1b_bk;t=1580724005123\x07". Terminals do not show these markers.
+var buildkiteTimestamp = regexp.MustCompile("\x1b_bk;t=(\\d+)\x07")
+
+// Return the time designated by the groups of a match of buildkiteTimestamp
+func buildkiteTime(groups []string) (time.Time, bool) {
+	milliseconds, err := strconv.ParseInt(groups[1], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, milliseconds*int64(time.Millisecond)).UTC(), true
+}
+
+// Return the time written at the beginning of 'line' and the length of the prefix holding it
+func parseTimestampPrefix(line string) (time.Time, int, bool) {
+	if strings.HasPrefix(line, "\x1b_bk;") {
+		if groups := buildkiteTimestamp.FindStringSubmatch(line); groups != nil && strings.HasPrefix(line, groups[0]) {
+			t, ok := buildkiteTime(groups)
+			return t, len(groups[0]), ok
+		}
+	}
+
+	groups := timestampPrefix.FindStringSubmatch(line)
+	// Brackets must be balanced
+	if groups == nil || (groups[1] == "") != (groups[5] == "") {
+		return time.Time{}, 0, false
+	}
+	zone := strings.Replace(groups[4], ":", "", 1)
+	if zone == "" || zone == "Z" {
+		zone = "+0000"
+	}
+	s := fmt.Sprintf("%sT%s%s", groups[2], strings.Replace(groups[3], ",", ".", 1), zone)
+	t, err := time.Parse("2006-01-02T15:04:05-0700", s)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return t, len(groups[0]), true
+}
+
+// Return the duration 'd' formatted as "+hh:mm:ss.sss"
+func relativeTimestamp(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	d = d.Round(time.Millisecond)
+	hours := d / time.Hour
+	minutes := (d - hours*time.Hour) / time.Minute
+	seconds := (d - hours*time.Hour - minutes*time.Minute) / time.Millisecond
+	return fmt.Sprintf("%s%02d:%02d:%06.3f", sign, hours, minutes, float64(seconds)/1000)
+}
+
+// formatTimestamps returns 'log' with the timestamps prefixing its lines shown as requested by
+// 'mode'. Relative timestamps are computed from 'start', or from the first timestamp of the log
+// if 'start' is not valid. Buildkite timestamps, which are invisible, are shown as RFC 3339
+// timestamps in TimestampsKeep mode and removed from the middle of lines in all modes. Lines are
+// neither added nor removed.
+func formatTimestamps(log string, mode TimestampMode, start utils.NullTime) string {
+	buildkite := strings.Contains(log, "\x1b_bk;t=")
+	if mode != TimestampsHide && mode != TimestampsRelative && !buildkite {
+		return log
+	}
+
+	lines := strings.Split(log, "\n")
+	for i, line := range lines {
+		t, n, ok := parseTimestampPrefix(line)
+		rest := line[n:]
+		if buildkite {
+			rest = buildkiteTimestamp.ReplaceAllString(rest, "")
+		}
+		switch {
+		case !ok:
+			lines[i] = rest
+		case mode == TimestampsHide:
+			lines[i] = rest
+		case mode == TimestampsRelative:
+			if !start.Valid {
+				start = utils.NullTime{Time: t, Valid: true}
+			}
+			lines[i] = relativeTimestamp(t.Sub(start.Time)) + " " + rest
+		case strings.HasPrefix(line, "\x1b_bk;"):
+			lines[i] = t.Format("2006-01-02T15:04:05.000Z07:00") + " " + rest
+		default:
+			lines[i] = line[:n] + rest
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Markers written by GitLab at the beginning and at the end of each section of a log, and by
+// Travis CI at the end of each command
+var gitLabSectionMarker = regexp.MustCompile(`section_(?:start|end):(\d+):`)
+var travisTimeMarker = regexp.MustCompile(`travis_time:end:[^:]*:start=\d+,finish=(\d+)`)
+
+// Return the time of the last event of 'line' known from a timestamp prefixing the line or from
+// the markers of GitLab, Buildkite and Travis CI
+func lineTime(line string) (time.Time, bool) {
+	line = colorSequence.ReplaceAllString(line, "")
+	var t time.Time
+	found := false
+	if prefix, _, ok := parseTimestampPrefix(line); ok {
+		t, found = prefix, true
+	}
+	for _, groups := range gitLabSectionMarker.FindAllStringSubmatch(line, -1) {
+		if seconds, err := strconv.ParseInt(groups[1], 10, 64); err == nil {
+			t, found = time.Unix(seconds, 0), true
+		}
+	}
+	for _, groups := range buildkiteTimestamp.FindAllStringSubmatch(line, -1) {
+		if marker, ok := buildkiteTime(groups); ok {
+			t, found = marker, true
+		}
+	}
+	for _, groups := range travisTimeMarker.FindAllStringSubmatch(line, -1) {
+		if nanoseconds, err := strconv.ParseInt(groups[1], 10, 64); err == nil {
+			t, found = time.Unix(0, nanoseconds), true
+		}
+	}
+
+	return t, found
+}
+
+// lineTimes returns the time at which each line of 'log' was written as far as the log tells.
+// Lines without a time of their own inherit the time of the previous line so that logs made of
+// timestamped chunks get a time for every line once the first chunk begins.
+func lineTimes(log string) []utils.NullTime {
+	lines := strings.Split(log, "\n")
+	times := make([]utils.NullTime, len(lines))
+	var last utils.NullTime
+	for i, line := range lines {
+		if t, ok := lineTime(line); ok {
+			last = utils.NullTime{Time: t, Valid: true}
+		}
+		times[i] = last
+	}
+
+	return times
+}
+
+// addTimingGutter prefixes each line of 'log' with the time elapsed between 'start' and the time
+// of the line given by 'times'. The first valid time is used if 'start' is not valid. Lines
+// without a time get a blank gutter.
+func addTimingGutter(log string, times []utils.NullTime, start utils.NullTime) string {
+	if !start.Valid {
+		for _, t := range times {
+			if t.Valid {
+				start = t
+				break
+			}
+		}
+	}
+
+	blank := strings.Repeat(" ", len(relativeTimestamp(0)))
+	lines := strings.Split(log, "\n")
+	for i, line := range lines {
+		// Leave the empty string following the last newline as is
+		if i == len(lines)-1 && line == "" {
+			break
+		}
+		gutter := blank
+		if i < len(times) && times[i].Valid && start.Valid {
+			gutter = relativeTimestamp(times[i].Time.Sub(start.Time))
+		}
+		lines[i] = gutter + " " + line
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,165 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/utils"
+)
+
+func TestParseTimestampMode(t *testing.T) {
+	for s, expected := range map[string]TimestampMode{
+		"":         TimestampsKeep,
+		"keep":     TimestampsKeep,
+		"Hide":     TimestampsHide,
+		"relative": TimestampsRelative,
+	} {
+		mode, err := ParseTimestampMode(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode != expected {
+			t.Fatalf("expected %q but got %q", expected, mode)
+		}
+	}
+
+	if _, err := ParseTimestampMode("absolute"); err == nil {
+		t.Fatal("expected an error but got nil")
+	}
+}
+
+func TestFormatTimestamps(t *testing.T) {
+	log := "" +
+		"2020-02-03T10:00:05.1234567Z ##[section]Starting: build\n" +
+		"[2020-02-03 11:01:06+01:00] go build ./...\n" +
+		"no timestamp on this line\n" +
+		"[2020-02-03T10:01:07Z error: unbalanced brackets\n" +
+		"2020-02-03T10:02:08,5Z done\n"
+
+	t.Run("Keep", func(t *testing.T) {
+		if s := formatTimestamps(log, TimestampsKeep, utils.NullTime{}); s != log {
+			t.Fatalf("expected log to be left untouched but got %q", s)
+		}
+	})
+
+	t.Run("Hide", func(t *testing.T) {
+		expected := "" +
+			"##[section]Starting: build\n" +
+			"go build ./...\n" +
+			"no timestamp on this line\n" +
+			"[2020-02-03T10:01:07Z error: unbalanced brackets\n" +
+			"done\n"
+		if diff := cmp.Diff(expected, formatTimestamps(log, TimestampsHide, utils.NullTime{})); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Relative to the start of the job", func(t *testing.T) {
+		start := utils.NullTime{Time: time.Date(2020, 2, 3, 10, 0, 0, 0, time.UTC), Valid: true}
+		expected := "" +
+			"+00:00:05.123 ##[section]Starting: build\n" +
+			"+00:01:06.000 go build ./...\n" +
+			"no timestamp on this line\n" +
+			"[2020-02-03T10:01:07Z error: unbalanced brackets\n" +
+			"+00:02:08.500 done\n"
+		if diff := cmp.Diff(expected, formatTimestamps(log, TimestampsRelative, start)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Relative to the first timestamp", func(t *testing.T) {
+		expected := "" +
+			"+00:00:00.000 ##[section]Starting: build\n" +
+			"+00:01:00.877 go build ./...\n" +
+			"no timestamp on this line\n" +
+			"[2020-02-03T10:01:07Z error: unbalanced brackets\n" +
+			"+00:02:03.377 done\n"
+		if diff := cmp.Diff(expected, formatTimestamps(log, TimestampsRelative, utils.NullTime{})); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestFormatTimestamps_Buildkite(t *testing.T) {
+	log := "" +
+		"\x1b_bk;t=1580724005123\x07~~~ Running commands\n" +
+		"\x1b_bk;t=1580724066000\x07$ make\x1b_bk;t=1580724067000\x07 test\n" +
+		"no timestamp on this line\n"
+
+	testCases := []struct {
+		mode     TimestampMode
+		expected string
+	}{
+		{
+			mode: TimestampsKeep,
+			expected: "" +
+				"2020-02-03T10:00:05.123Z ~~~ Running commands\n" +
+				"2020-02-03T10:01:06.000Z $ make test\n" +
+				"no timestamp on this line\n",
+		},
+		{
+			mode: TimestampsHide,
+			expected: "" +
+				"~~~ Running commands\n" +
+				"$ make test\n" +
+				"no timestamp on this line\n",
+		},
+		{
+			mode: TimestampsRelative,
+			expected: "" +
+				"+00:00:00.000 ~~~ Running commands\n" +
+				"+00:01:00.877 $ make test\n" +
+				"no timestamp on this line\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.mode), func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, formatTimestamps(log, testCase.mode, utils.NullTime{})); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	// The time of a line is the time of its last marker
+	times := lineTimes(log)
+	if !times[1].Valid || !times[1].Time.Equal(time.Unix(1580724067, 0)) {
+		t.Fatalf("unexpected time %v", times[1])
+	}
+}
+
+func TestAddTimingGutter(t *testing.T) {
+	raw := "" +
+		"Running with gitlab-runner 12.7.0\n" +
+		"section_start:1580724000:prepare_script\r\x1b[0KPreparing environment\n" +
+		"Running on runner-abc\n" +
+		"section_end:1580724005:prepare_script\r\x1b[0Ksection_start:1580724005:build_script\r\x1b[0K$ make\n" +
+		"travis_time:end:0a1b:start=1580724005000000000,finish=1580724070500000000,duration=65500000000\r\x1b[0Kok\n"
+	log := utils.PostProcess(raw)
+
+	t.Run("Relative to the start of the job", func(t *testing.T) {
+		start := utils.NullTime{Time: time.Unix(1580723990, 0), Valid: true}
+		expected := "" +
+			"              Running with gitlab-runner 12.7.0\n" +
+			"+00:00:10.000 Preparing environment\n" +
+			"+00:00:10.000 Running on runner-abc\n" +
+			"+00:00:15.000 $ make\n" +
+			"+00:01:20.500 ok\n"
+		if diff := cmp.Diff(expected, addTimingGutter(log, lineTimes(raw), start)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Relative to the first time", func(t *testing.T) {
+		expected := "" +
+			"              Running with gitlab-runner 12.7.0\n" +
+			"+00:00:00.000 Preparing environment\n" +
+			"+00:00:00.000 Running on runner-abc\n" +
+			"+00:00:05.000 $ make\n" +
+			"+00:01:10.500 ok\n"
+		if diff := cmp.Diff(expected, addTimingGutter(log, lineTimes(raw), utils.NullTime{})); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
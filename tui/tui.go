@@ -3,10 +3,12 @@ package tui
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"text/template"
 	"time"
 
 	"github.com/gdamore/tcell"
@@ -23,8 +25,50 @@ type ExecCmd struct {
 
 var ErrNoProvider = errors.New("list of providers must not be empty")
 
-func RunApplication(ctx context.Context, newScreen func() (tcell.Screen, error), repo string, sha string, CIProviders []cache.CIProvider, SourceProviders []cache.SourceProvider, loc *time.Location, help string) (err error) {
-	if len(CIProviders) == 0 || len(SourceProviders) == 0 {
+// Options of RunApplication
+type Options struct {
+	// Create the screen of the application
+	NewScreen func() (tcell.Screen, error)
+	// Path of a local repository or URL of an online repository
+	Repository string
+	// Commit, branch or tag whose pipelines are monitored
+	Sha             string
+	CIProviders     []cache.CIProvider
+	SourceProviders []cache.SourceProvider
+	// Pipelines shown in the table
+	Filter     Filter
+	StyleSheet text.StyleSheet
+	Icons      StateIcons
+	// Template of the lines shown above the table
+	Header       *template.Template
+	RowTemplates RowTemplates
+	Columns      []string
+	Widths       map[string]ColumnWidth
+	Priorities   map[string]int
+	Wrap         bool
+	LineNumbers  LineNumbers
+	// Column sorting the table, none if empty
+	SortColumn      string
+	Reverse         bool
+	Matchers        []ProblemMatcher
+	Timestamps      TimestampMode
+	StripColors     bool
+	LogPathTemplate *template.Template
+	// Time zone of the dates shown by the application
+	Location *time.Location
+	// Manual page shown by the key '?'
+	Help          string
+	FollowBranch  FollowMode
+	Notifications []Notification
+	Publishers    []StatePublisher
+	StatusPages   []StatusPage
+	Notices       []string
+	// Directory where the session is saved on exit, sessions are not saved if empty
+	StateDir string
+}
+
+func RunApplication(ctx context.Context, options Options) (err error) {
+	if len(options.CIProviders) == 0 || len(options.SourceProviders) == 0 {
 		return ErrNoProvider
 	}
 	// FIXME Discard log until the status bar is implemented in order to hide the "Unsolicited response received on
@@ -39,63 +83,59 @@ func RunApplication(ctx context.Context, newScreen func() (tcell.Screen, error),
 	defer os.RemoveAll(tmpDir)
 
 	defaultStyle := tcell.StyleDefault
-	styleSheet := text.StyleSheet{
-		text.TableHeader: func(s tcell.Style) tcell.Style {
-			return s.Bold(true).Reverse(true)
-		},
-		text.ActiveRow: func(s tcell.Style) tcell.Style {
-			return s.Background(tcell.ColorSilver).Foreground(tcell.ColorBlack).Bold(false).Underline(false).Blink(false)
-		},
-		text.Provider: func(s tcell.Style) tcell.Style {
-			return s.Bold(true)
-		},
-		text.StatusFailed: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorMaroon).Bold(false)
-		},
-		text.StatusPassed: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorGreen).Bold(false)
-		},
-		text.StatusRunning: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorOlive).Bold(false)
-		},
-		text.StatusSkipped: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorGray).Bold(false)
-		},
-		text.GitSha: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorOlive)
-		},
-		text.GitBranch: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorTeal).Bold(false)
-		},
-		text.GitTag: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorYellow).Bold(false)
-		},
-		text.GitHead: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorAqua)
-		},
-	}
-	defaultStatus := "j:Down  k:Up  oO:Open  cC:Close  /:Search  v:Logs  b:Browser  ?:Help  q:Quit"
+	defaultStatus := "j:Down  k:Up  oO:Open  cC:Close  /:Search  <>:Sort  v:Logs  b:Browser  ?:Help  q:Quit"
 
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	// FIXME
-	repositoryURL, commit, err := utils.GitOriginURL(repo, sha)
+	repositoryURL, commit, err := resolveCommit(ctx, options.Repository, options.Sha, options.SourceProviders)
 	if err != nil {
-		for i, p := range SourceProviders {
-			commit, err = p.Commit(ctx, repositoryURL, sha)
-			if err == nil {
-				break
-			}
-			if i >= len(SourceProviders)-1 {
-				return err
-			}
-		}
+		return err
 	}
 
-	cacheDB := cache.NewCache(CIProviders, SourceProviders)
-	source := cacheDB.BuildsByCommit()
+	// Start monitoring the pipelines of 'commit' with a new engine. The engine stops when the
+	// function returned is called.
+	monitor := func(commit utils.Commit) (*cache.Engine, Target, context.CancelFunc, error) {
+		engineCtx, cancel := context.WithCancel(ctx)
+		engine := cache.NewEngine(options.CIProviders, options.SourceProviders)
+		options.Filter.apply(engine.Cache())
+		source := NewBuildsByCommit(engine.Cache())
+		source.SetStateIcons(options.Icons)
+		source.SetRowTemplates(options.RowTemplates)
+		source.SetColumns(options.Columns)
+		source.SetProblemMatchers(options.Matchers)
+		source.SetTimestampMode(options.Timestamps)
 
-	ui, err := NewTUI(newScreen, defaultStyle, styleSheet)
+		commit.PullRequest = findPullRequest(ctx, repositoryURL, commit.Sha, options.SourceProviders)
+		lines, err := headerLines(options.Header, commit)
+		if err != nil {
+			return nil, Target{}, cancel, err
+		}
+		target := Target{
+			Commit:  commit,
+			Header:  lines,
+			Source:  &source,
+			Updates: engine.Subscribe(),
+		}
+		// Messages are posted within the context of the application so that switching to
+		// another commit does not interrupt their delivery
+		if len(options.Notifications) > 0 {
+			go notify(ctx, engine.Subscribe(), options.Notifications)
+		}
+		if len(options.Publishers) > 0 {
+			go publish(ctx, engine.Subscribe(), options.Publishers)
+		}
+		return engine, target, cancel, engine.Start(engineCtx, repositoryURL, commit.Sha)
+	}
+	wait := func(engine *cache.Engine) <-chan error {
+		errc := make(chan error, 1)
+		go func() {
+			errc <- engine.Wait()
+		}()
+		return errc
+	}
+
+	ui, err := NewTUI(options.NewScreen, defaultStyle, options.StyleSheet)
 	if err != nil {
 		return err
 	}
@@ -103,43 +143,193 @@ func RunApplication(ctx context.Context, newScreen func() (tcell.Screen, error),
 		ui.Finish()
 	}()
 
-	controller, err := NewController(&ui, &source, loc, tmpDir, defaultStatus, help)
+	engine, target, stopEngine, err := monitor(commit)
+	defer func() {
+		stopEngine()
+	}()
 	if err != nil {
 		return err
 	}
-	controller.SetHeader(commit.Strings())
+	errEngine := wait(engine)
 
-	errCache := make(chan error)
-	updates := make(chan time.Time)
-	go func() {
-		errCache <- cacheDB.GetPipelines(ctx, repositoryURL, commit, updates)
-	}()
+	controller, err := NewController(&ui, target.Source, options.Location, tmpDir, defaultStatus, options.Help)
+	if err != nil {
+		return err
+	}
+	controller.SetCommit(repositoryURL, target.Commit)
+	controller.SetHeader(target.Header)
+	controller.SetStateIcons(options.Icons)
+	controller.SetTimestampMode(options.Timestamps)
+	controller.SetStripColors(options.StripColors)
+	controller.SetLogPathTemplate(options.LogPathTemplate)
+	controller.SetColumnWidths(options.Widths)
+	controller.SetColumnPriorities(options.Priorities)
+	controller.SetWrap(options.Wrap)
+	controller.SetNotices(options.Notices)
+	controller.SetLineNumbers(options.LineNumbers)
+	if options.SortColumn != "" || options.Reverse {
+		controller.SetSort(options.SortColumn, options.Reverse)
+	}
+	ref := sessionRef(options.Sha, commit)
+	if options.StateDir != "" {
+		if session, exists := loadSession(options.StateDir, repositoryURL, ref); exists {
+			controller.RestoreSession(session)
+		}
+	}
 
+	// Follow HEAD of the local repository if the user did not ask for a specific commit, and
+	// follow the remote branch if the user asked for a branch
+	commits := make(chan utils.Commit)
+	tips := make(chan utils.Commit)
+	if options.Sha == "HEAD" {
+		if _, _, err := utils.GitOriginURL(options.Repository, options.Sha); err == nil {
+			go watchHead(ctx, options.Repository, commit.Sha, headPollInterval, commits)
+		}
+	} else if branch, ok := remoteBranch(options.Sha, commit); ok && options.FollowBranch != FollowOff {
+		go watchBranch(ctx, repositoryURL, branch, commit.Sha, branchPollInterval, options.SourceProviders, tips)
+	}
+
+	// Warn the user about incidents affecting providers
+	incidents := make(chan []Incident)
+	if len(options.StatusPages) > 0 {
+		go watchStatusPages(ctx, options.StatusPages, statusPageInterval, incidents)
+	}
+
+	targets := make(chan Target)
+	offers := make(chan utils.Commit)
 	errController := make(chan error)
 	go func() {
-		errController <- controller.Run(ctx, updates)
+		errController <- controller.Run(ctx, target.Updates, targets, offers, incidents)
 	}()
 
-	var e error
-	errSet := false
-	for i := 0; i < 2; i++ {
+	for {
+		var next utils.Commit
+		var status string
 		select {
-		case e = <-errCache:
-			if e != nil && !errSet {
+		case err := <-errEngine:
+			errEngine = nil
+			if err != nil {
 				cancel()
-				err = e
-				errSet = true
+				<-errController
+				return err
 			}
-		case e = <-errController:
-			if !errSet {
-				cancel()
-				err = e
-				errSet = true
+			continue
+		case err := <-errController:
+			cancel()
+			if errEngine != nil {
+				<-errEngine
 			}
+			if err == nil && options.StateDir != "" {
+				session := controller.Session()
+				session.RepositoryURL, session.Ref = repositoryURL, ref
+				// Failing to save the session must not turn a normal exit into an error
+				_ = saveSession(options.StateDir, session)
+			}
+			return err
+		case next = <-commits:
+			status = fmt.Sprintf("HEAD moved to %s, now monitoring its pipelines", shortSha(next.Sha))
+		case next = <-tips:
+			if options.FollowBranch != FollowAuto {
+				select {
+				case offers <- next:
+				case err := <-errController:
+					cancel()
+					return err
+				}
+				continue
+			}
+			status = fmt.Sprintf("Branch %s advanced to %s, now monitoring its pipelines", options.Sha, shortSha(next.Sha))
+		case next = <-controller.Accepted():
+			status = fmt.Sprintf("Now monitoring the pipelines of commit %s", shortSha(next.Sha))
+		}
+
+		stopEngine()
+		engine, target, stopEngine, err = monitor(next)
+		if err != nil {
+			cancel()
+			<-errController
+			return err
+		}
+		target.Status = status
+		errEngine = wait(engine)
+		select {
+		case targets <- target:
+		case err := <-errController:
+			cancel()
+			return err
 		}
 	}
+}
 
-	return err
+// Return the URL of the repository and the commit designated by 'sha'. The local git
+// repository is used if there is one, otherwise source providers are queried. Source providers
+// are also queried for the repository of the remote 'origin' if 'sha' is unknown to the local
+// repository, such as a change of Gerrit that was not fetched.
+func resolveCommit(ctx context.Context, repo string, sha string, sourceProviders []cache.SourceProvider) (string, utils.Commit, error) {
+	repositoryURL, commit, err := utils.GitOriginURL(repo, sha)
+	if err != nil {
+		// 'repo' is either the URL of an online repository or a local repository that does not
+		// know 'sha'
+		repositoryURL = repo
+		if origin, err := utils.GitRemoteURL(repo); err == nil {
+			repositoryURL = origin
+		}
+		if commit, err = remoteCommit(ctx, repositoryURL, sha, sourceProviders); err != nil {
+			return "", commit, err
+		}
+	}
+
+	return repositoryURL, commit, nil
+}
+
+// Return the source providers used for the repository at 'repositoryURL' (see
+// cache.OwnerMatcher and cache.RepositoryMatcher)
+func repositoryProviders(repositoryURL string, sourceProviders []cache.SourceProvider) []cache.SourceProvider {
+	_, owner, repo, err := utils.RepoHostOwnerAndName(repositoryURL)
+	if err != nil {
+		return sourceProviders
+	}
+	ps := make([]cache.SourceProvider, 0, len(sourceProviders))
+	for _, p := range sourceProviders {
+		if cache.UsedFor(p, owner, repo) {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// Return the commit designated by 'sha' in the online repository at 'repositoryURL'. The error
+// of the last source provider is returned if no provider knows the commit.
+func remoteCommit(ctx context.Context, repositoryURL string, sha string, sourceProviders []cache.SourceProvider) (utils.Commit, error) {
+	commit := utils.Commit{}
+	err := cache.ErrRepositoryNotFound
+	for _, p := range repositoryProviders(repositoryURL, sourceProviders) {
+		if commit, err = p.Commit(ctx, repositoryURL, sha); err == nil {
+			break
+		}
+	}
+	return commit, err
+}
+
+// Maximum time spent looking for the pull request containing a commit
+const pullRequestTimeout = 5 * time.Second
+
+// Return the pull request containing the commit 'sha' of the repository at 'repositoryURL' or
+// nil if no source provider knows one. Errors are ignored since the pull request is only
+// informative.
+func findPullRequest(ctx context.Context, repositoryURL string, sha string, sourceProviders []cache.SourceProvider) *utils.PullRequest {
+	ctx, cancel := context.WithTimeout(ctx, pullRequestTimeout)
+	defer cancel()
+	for _, p := range repositoryProviders(repositoryURL, sourceProviders) {
+		finder, ok := p.(cache.PullRequestFinder)
+		if !ok {
+			continue
+		}
+		if pr, err := finder.PullRequest(ctx, repositoryURL, sha); err == nil {
+			return &pr
+		}
+	}
+	return nil
 }
 
 type TUI struct {
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/gdamore/tcell"
@@ -132,12 +133,13 @@ func TestTUI_Exec(t *testing.T) {
 }
 
 type mockProvider struct {
-	id string
+	id     string
+	builds []cache.Build
 }
 
-func (p mockProvider) AccountID() string { return p.id }
-func (p mockProvider) Log(ctx context.Context, repository cache.Repository, jobID int) (string, bool, error) {
-	return "", false, nil
+func (p mockProvider) ID() string { return p.id }
+func (p mockProvider) Log(ctx context.Context, repository cache.Repository, jobID string) (string, error) {
+	return "log\n", nil
 }
 func (p mockProvider) BuildFromURL(ctx context.Context, u string) (cache.Build, error) {
 	return cache.Build{}, nil
@@ -150,7 +152,17 @@ func TestRunApplication(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		err = RunApplication(ctx, newScreen, pwd, "HEAD", nil, nil, time.UTC, "")
+		err = RunApplication(ctx, Options{
+			NewScreen:       newScreen,
+			Repository:      pwd,
+			Sha:             "HEAD",
+			StyleSheet:      DefaultStyleSheet,
+			Matchers:        DefaultProblemMatchers,
+			Timestamps:      TimestampsKeep,
+			LogPathTemplate: template.Must(NewLogPathTemplate("")),
+			Location:        time.UTC,
+			FollowBranch:    FollowAsk,
+		})
 		if err != ErrNoProvider {
 			t.Fatalf("expected %v but got %v", ErrNoProvider, err)
 		}
@@ -0,0 +1,76 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nbedos/citop/utils"
+)
+
+// ColumnWidth tells how the width of a column of the table is computed
+type ColumnWidth struct {
+	// Number of screen columns, or percentage of the width of the terminal if Percent is set.
+	// Zero stands for the width of the widest value of the column.
+	Value   int
+	Percent bool
+	// Minimum number of screen columns, 0 if there is none
+	Min int
+}
+
+// Width needed on screen by the last column of the table, usually NAME, unless its minimum width
+// says otherwise. Columns are hidden to make room for it on narrow terminals.
+const minLastColumnWidth = 20
+
+// DefaultColumnPriorities ranks the columns of the table of pipelines. Columns of low priority
+// are the first to be hidden when the terminal is too narrow to show every column. The STATE and
+// NAME columns are never hidden.
+var DefaultColumnPriorities = map[string]int{
+	"PIPELINE": 9,
+	"TYPE":     8,
+	"PROVIDER": 8,
+	"DURATION": 7,
+	"STAGE":    6,
+	"CREATED":  5,
+	"OS":       4,
+	"ARCH":     4,
+	"LANGUAGE": 4,
+	"PROGRESS": 3,
+	"REF":      2,
+	"ETA":      1,
+	"TREND":    0,
+}
+
+// AutoWidth sizes a column to fit its widest value
+var AutoWidth = ColumnWidth{}
+
+// ParseColumnWidth parses "auto", a number of screen columns such as "20" or a percentage of the
+// width of the terminal such as "30%"
+func ParseColumnWidth(s string) (ColumnWidth, error) {
+	s = strings.TrimSpace(s)
+	if strings.ToLower(s) == "auto" {
+		return AutoWidth, nil
+	}
+
+	w := ColumnWidth{Percent: strings.HasSuffix(s, "%")}
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "%"))
+	if err != nil || n <= 0 || (w.Percent && n > 100) {
+		return ColumnWidth{}, fmt.Errorf("invalid column width %q (expected \"auto\", a positive number of columns or a percentage such as \"30%%\")", s)
+	}
+	w.Value = n
+
+	return w, nil
+}
+
+// Return the width of a column whose widest value is 'maxWidth' wide in a table 'tableWidth'
+// wide. Columns sized relatively to the table are at least one column wide.
+func (w ColumnWidth) width(tableWidth int, maxWidth int) int {
+	switch {
+	case w.Value == 0:
+		return utils.MaxInt(w.Min, maxWidth)
+	case w.Percent:
+		return utils.MaxInt(w.Min, utils.MaxInt(1, tableWidth*w.Value/100))
+	default:
+		return utils.MaxInt(w.Min, w.Value)
+	}
+}
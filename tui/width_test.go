@@ -0,0 +1,164 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+func TestParseColumnWidth(t *testing.T) {
+	testCases := []struct {
+		s        string
+		expected ColumnWidth
+	}{
+		{"auto", AutoWidth},
+		{"AUTO", AutoWidth},
+		{"20", ColumnWidth{Value: 20}},
+		{" 30% ", ColumnWidth{Value: 30, Percent: true}},
+		{"100%", ColumnWidth{Value: 100, Percent: true}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.s, func(t *testing.T) {
+			w, err := ParseColumnWidth(testCase.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w != testCase.expected {
+				t.Fatalf("expected %+v but got %+v", testCase.expected, w)
+			}
+		})
+	}
+
+	for _, s := range []string{"", "0", "-3", "101%", "%", "wide"} {
+		t.Run(s, func(t *testing.T) {
+			if _, err := ParseColumnWidth(s); err == nil {
+				t.Fatal("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestTable_SetColumnWidths(t *testing.T) {
+	wideSource := testSource{
+		rows: []testRow{
+			{value: "a"},
+			{value: "0123456789"},
+		},
+	}
+	table, err := NewTable(wideSource, 20, 4, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := func() []string {
+		values := make([]string, 0)
+		for _, text := range table.Text() {
+			values = append(values, text.S.String())
+		}
+		return values
+	}
+
+	testCases := []struct {
+		name     string
+		width    ColumnWidth
+		expected []string
+	}{
+		{
+			name:     "auto",
+			width:    AutoWidth,
+			expected: []string{"VALUE               ", "a                   ", "0123456789          "},
+		},
+		{
+			name:     "fixed",
+			width:    ColumnWidth{Value: 6},
+			expected: []string{"VALUE               ", "a                   ", "01234…              "},
+		},
+		{
+			name:     "proportional",
+			width:    ColumnWidth{Value: 20, Percent: true},
+			expected: []string{"VAL…                ", "a                   ", "012…                "},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			table.SetColumnWidths(map[string]ColumnWidth{"VALUE": testCase.width})
+			if diff := cmp.Diff(testCase.expected, lines()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("proportional widths follow the size of the terminal", func(t *testing.T) {
+		table.SetColumnWidths(map[string]ColumnWidth{"VALUE": {Value: 50, Percent: true}})
+		table.Resize(10, 4)
+		if w := table.columnWidth("VALUE"); w != 5 {
+			t.Fatalf("expected width 5 but got %d", w)
+		}
+	})
+}
+
+func TestTable_visibleHeaders(t *testing.T) {
+	c := cache.NewCache(nil, nil)
+	build := cache.Build{
+		Repository: &cache.Repository{Provider: cache.Provider{ID: "github", Name: "github"}},
+		ID:         "1",
+		Ref:        "master",
+		Stages:     map[int]*cache.Stage{},
+		Jobs:       []*cache.Job{{ID: "1", Name: "integration tests on linux"}},
+	}
+	if err := c.Save(build); err != nil {
+		t.Fatal(err)
+	}
+	source := NewBuildsByCommit(&c)
+	table, err := NewTable(&source, 1000, 10, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.SetTraversable(true, true)
+	sep := len(table.sep)
+
+	t.Run("columns are hidden by increasing priority", func(t *testing.T) {
+		previous := table.Headers()
+		if diff := cmp.Diff(previous, table.visibleHeaders()); diff != "" {
+			t.Fatalf("expected every column on a wide screen: %s", diff)
+		}
+		for width := 200; width >= 0; width-- {
+			table.Resize(width, 10)
+			headers := table.visibleHeaders()
+			visible := make(map[string]bool)
+			for _, header := range headers {
+				visible[header] = true
+			}
+			for _, header := range previous {
+				if !visible[header] {
+					for _, other := range headers {
+						if other != "STATE" && other != "NAME" && DefaultColumnPriorities[other] < DefaultColumnPriorities[header] {
+							t.Fatalf("column %s hidden before column %s of lower priority", header, other)
+						}
+					}
+				}
+			}
+			previous = headers
+		}
+		if diff := cmp.Diff([]string{"STATE", "NAME"}, previous); diff != "" {
+			t.Fatalf("expected STATE and NAME to stay visible: %s", diff)
+		}
+	})
+
+	t.Run("priorities set by the user", func(t *testing.T) {
+		table.SetColumnPriorities(map[string]int{"TREND": 100})
+		table.Resize(table.columnWidth("STATE")+table.columnWidth("TREND")+minLastColumnWidth+2*sep, 10)
+		if diff := cmp.Diff([]string{"STATE", "TREND", "NAME"}, table.visibleHeaders()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("minimum width of the last column", func(t *testing.T) {
+		table.SetColumnWidths(map[string]ColumnWidth{"NAME": {Min: 1}})
+		table.Resize(table.columnWidth("STATE")+table.columnWidth("TREND")+len("NAME")+2*sep, 10)
+		if diff := cmp.Diff([]string{"STATE", "TREND", "NAME"}, table.visibleHeaders()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
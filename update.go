@@ -0,0 +1,338 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// URL of the GitHub API endpoint describing the latest release of citop
+const latestReleaseURL = "https://api.github.com/repos/nbedos/citop/releases/latest"
+
+// Maximum duration of the passive check for a newer version run alongside the user interface
+const updateCheckTimeout = 5 * time.Second
+
+var ErrSelfUpdateDisabled = errors.New("self-update is disabled by the configuration file, " +
+	"use your package manager to update citop")
+
+// UpdateConfiguration controls how citop looks for newer versions of itself. Packagers
+// distributing citop through a package manager may disable self-update altogether.
+type UpdateConfiguration struct {
+	// Do not look for a newer version of citop when starting the user interface
+	DisableCheck bool `toml:"disable_check"`
+	// Forbid 'citop update' from replacing the executable
+	DisableSelfUpdate bool `toml:"disable_self_update"`
+}
+
+type releaseAsset struct {
+	Name        string `json:"name"`
+	DownloadURL string `json:"browser_download_url"`
+}
+
+type release struct {
+	TagName string         `json:"tag_name"`
+	WebURL  string         `json:"html_url"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+// Parse the numeric components of a version such as "0.2.1" or "v0.2.1-12-g5b2e4f1-dirty" as
+// produced by 'git describe'. Commits following the tag are ignored.
+func parseVersion(s string) ([]int, error) {
+	v := strings.TrimPrefix(s, "v")
+	if i := strings.IndexRune(v, '-'); i >= 0 {
+		v = v[:i]
+	}
+
+	numbers := make([]int, 0)
+	for _, field := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version: %q", s)
+		}
+		numbers = append(numbers, n)
+	}
+
+	return numbers, nil
+}
+
+// Return true if version 'latest' is more recent than version 'current'
+func isNewerVersion(latest string, current string) (bool, error) {
+	l, err := parseVersion(latest)
+	if err != nil {
+		return false, err
+	}
+	c, err := parseVersion(current)
+	if err != nil {
+		return false, err
+	}
+
+	for i := 0; i < len(l) || i < len(c); i++ {
+		var a, b int
+		if i < len(l) {
+			a = l[i]
+		}
+		if i < len(c) {
+			b = c[i]
+		}
+		if a != b {
+			return a > b, nil
+		}
+	}
+
+	return false, nil
+}
+
+func latestRelease(ctx context.Context, client *http.Client, u string) (release, error) {
+	var r release
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return r, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("User-Agent", userAgent())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return r, fmt.Errorf("GET %s returned status %q", u, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// Return the asset of the release built for the operating system and the architecture of the
+// machine. Assets are either executables or gzip compressed tarballs containing the executable.
+func (r release) asset(goos string, goarch string) (releaseAsset, bool) {
+	platform := fmt.Sprintf("-%s-%s", goos, goarch)
+	for _, a := range r.Assets {
+		name := strings.TrimSuffix(a.Name, ".tar.gz")
+		if strings.HasSuffix(name, platform) {
+			return a, true
+		}
+	}
+	return releaseAsset{}, false
+}
+
+// Return the asset listing the SHA-256 checksums of the other assets of the release
+func (r release) checksums() (releaseAsset, bool) {
+	for _, a := range r.Assets {
+		if strings.HasSuffix(a.Name, "checksums.txt") {
+			return a, true
+		}
+	}
+	return releaseAsset{}, false
+}
+
+// Return the SHA-256 checksum of the file 'name' listed in 'checksums', whose lines are written
+// in the format of sha256sum: the hexadecimal checksum followed by the name of the file
+func parseChecksum(checksums []byte, name string) ([]byte, error) {
+	for _, line := range strings.Split(string(checksums), "\n") {
+		fields := strings.Fields(line)
+		// sha256sum marks files read in binary mode with an asterisk
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum, err := hex.DecodeString(fields[0])
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid checksum for %s: %q", name, fields[0])
+		}
+		return sum, nil
+	}
+	return nil, fmt.Errorf("no checksum found for %s", name)
+}
+
+// Download the content of the asset
+func download(ctx context.Context, client *http.Client, asset releaseAsset) ([]byte, error) {
+	req, err := http.NewRequest("GET", asset.DownloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned status %q", asset.DownloadURL, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Return a reader of the executable contained in the asset
+func extractExecutable(asset releaseAsset, r io.Reader) (io.Reader, error) {
+	if !strings.HasSuffix(asset.Name, ".tar.gz") {
+		return r, nil
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no executable found in %s", asset.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg && filepath.Base(header.Name) == "citop" {
+			return tr, nil
+		}
+	}
+}
+
+// Atomically replace the file at 'path' by the content of 'r'. The new file is written in the
+// same directory as the original one so that both are on the same filesystem.
+func replaceExecutable(path string, r io.Reader) (err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".citop-update-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), info.Mode()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Download the latest release of citop and replace the executable being run if the release is
+// more recent than the current version. Progress is reported to 'w'.
+func selfUpdate(ctx context.Context, w io.Writer, client *http.Client, c UpdateConfiguration) error {
+	if c.DisableSelfUpdate {
+		return ErrSelfUpdateDisabled
+	}
+
+	r, err := latestRelease(ctx, client, latestReleaseURL)
+	if err != nil {
+		return err
+	}
+	newer, err := isNewerVersion(r.TagName, Version)
+	if err != nil {
+		return err
+	}
+	if !newer {
+		fmt.Fprintf(w, "citop %s is up to date\n", Version)
+		return nil
+	}
+
+	asset, exists := r.asset(runtime.GOOS, runtime.GOARCH)
+	if !exists {
+		return fmt.Errorf("release %s has no executable for %s/%s, see %s", r.TagName,
+			runtime.GOOS, runtime.GOARCH, r.WebURL)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return err
+	}
+
+	if err := updateExecutable(ctx, w, client, r, asset, executable); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "citop updated from %s to %s\n", Version, r.TagName)
+	return nil
+}
+
+// Replace the executable at 'path' by the one contained in 'asset' of release 'r'. The SHA-256
+// checksum of the asset must match the one published along with the release, otherwise the
+// executable is left untouched.
+func updateExecutable(ctx context.Context, w io.Writer, client *http.Client, r release, asset releaseAsset, path string) error {
+	checksumsAsset, exists := r.checksums()
+	if !exists {
+		return fmt.Errorf("release %s has no checksums to verify the executable, see %s",
+			r.TagName, r.WebURL)
+	}
+	checksums, err := download(ctx, client, checksumsAsset)
+	if err != nil {
+		return err
+	}
+	expected, err := parseChecksum(checksums, asset.Name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Downloading %s...\n", asset.DownloadURL)
+	bs, err := download(ctx, client, asset)
+	if err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(bs); !bytes.Equal(sum[:], expected) {
+		return fmt.Errorf("checksum mismatch for %s: expected %x but got %x", asset.Name,
+			expected, sum)
+	}
+
+	content, err := extractExecutable(asset, bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	return replaceExecutable(path, content)
+}
+
+// Return a message announcing the latest release of citop if it is more recent than the
+// current version, or an empty string otherwise
+func newVersionNotice(ctx context.Context, client *http.Client, u string, c UpdateConfiguration) string {
+	r, err := latestRelease(ctx, client, u)
+	if err != nil {
+		return ""
+	}
+	if newer, err := isNewerVersion(r.TagName, Version); err != nil || !newer {
+		return ""
+	}
+
+	if c.DisableSelfUpdate {
+		return fmt.Sprintf("citop %s is available (current version: %s)", r.TagName, Version)
+	}
+	return fmt.Sprintf("citop %s is available (current version: %s), run 'citop update' to "+
+		"upgrade", r.TagName, Version)
+}
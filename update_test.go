@@ -0,0 +1,296 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIsNewerVersion(t *testing.T) {
+	testCases := []struct {
+		latest   string
+		current  string
+		expected bool
+	}{
+		{"0.2.0", "0.1.0", true},
+		{"v0.2.0", "0.1.0-12-g5b2e4f1-dirty", true},
+		{"0.10.0", "0.9.3", true},
+		{"1.0", "0.9.3", true},
+		{"0.1.1", "0.1", true},
+		{"0.1.0", "0.1.0-12-g5b2e4f1", false},
+		{"0.1.0", "0.1.0", false},
+		{"0.1.0", "0.2.0", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(fmt.Sprintf("%s > %s", testCase.latest, testCase.current), func(t *testing.T) {
+			newer, err := isNewerVersion(testCase.latest, testCase.current)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if newer != testCase.expected {
+				t.Fatalf("expected %v but got %v", testCase.expected, newer)
+			}
+		})
+	}
+
+	t.Run("invalid version", func(t *testing.T) {
+		if _, err := isNewerVersion("0.1.0", "undefined"); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestLatestRelease(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"tag_name": "0.2.0",
+			"html_url": "https://github.com/nbedos/citop/releases/tag/0.2.0",
+			"assets": [
+				{
+					"name": "citop-0.2.0-linux-amd64.tar.gz",
+					"browser_download_url": "https://example.com/citop-0.2.0-linux-amd64.tar.gz"
+				},
+				{
+					"name": "citop-0.2.0-darwin-amd64",
+					"browser_download_url": "https://example.com/citop-0.2.0-darwin-amd64"
+				}
+			]
+		}`)
+	}))
+	defer ts.Close()
+
+	r, err := latestRelease(context.Background(), ts.Client(), ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := release{
+		TagName: "0.2.0",
+		WebURL:  "https://github.com/nbedos/citop/releases/tag/0.2.0",
+		Assets: []releaseAsset{
+			{
+				Name:        "citop-0.2.0-linux-amd64.tar.gz",
+				DownloadURL: "https://example.com/citop-0.2.0-linux-amd64.tar.gz",
+			},
+			{
+				Name:        "citop-0.2.0-darwin-amd64",
+				DownloadURL: "https://example.com/citop-0.2.0-darwin-amd64",
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, r); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	for _, platform := range [][2]string{{"linux", "amd64"}, {"darwin", "amd64"}} {
+		if _, exists := r.asset(platform[0], platform[1]); !exists {
+			t.Fatalf("no asset found for %s/%s", platform[0], platform[1])
+		}
+	}
+	if a, exists := r.asset("linux", "arm"); exists {
+		t.Fatalf("unexpected asset %q", a.Name)
+	}
+}
+
+func TestExtractExecutable(t *testing.T) {
+	content := []byte("#!/bin/sh\necho citop\n")
+
+	buf := bytes.Buffer{}
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{"citop-0.2.0/LICENSE", []byte("license")},
+		{"citop-0.2.0/citop", content},
+	}
+	for _, f := range files {
+		header := tar.Header{
+			Name:     f.name,
+			Mode:     0755,
+			Size:     int64(len(f.content)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(&header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := extractExecutable(releaseAsset{Name: "citop-0.2.0-linux-amd64.tar.gz"}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bs, content) {
+		t.Fatalf("expected %q but got %q", content, bs)
+	}
+}
+
+func TestReplaceExecutable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := ioutil.TempFile(dir, "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(f.Name(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := replaceExecutable(f.Name(), bytes.NewBufferString("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "new" {
+		t.Fatalf("expected %q but got %q", "new", string(bs))
+	}
+	info, err := os.Stat(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != 0755 {
+		t.Fatalf("expected mode %v but got %v", os.FileMode(0755), info.Mode())
+	}
+
+	// No temporary file must be left behind
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected a single file in %s but got %d", dir, len(entries))
+	}
+}
+
+func TestUpdateExecutable(t *testing.T) {
+	content := "new"
+	sum := sha256.Sum256([]byte(content))
+	checksums := fmt.Sprintf("%x  citop-0.2.0-linux-amd64\n", sum)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/citop-0.2.0-linux-amd64":
+			fmt.Fprint(w, content)
+		case "/tampered/citop-0.2.0-linux-amd64":
+			fmt.Fprint(w, "tampered")
+		case "/citop_0.2.0_checksums.txt":
+			fmt.Fprint(w, checksums)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	r := release{
+		TagName: "0.2.0",
+		Assets: []releaseAsset{
+			{Name: "citop-0.2.0-linux-amd64", DownloadURL: ts.URL + "/citop-0.2.0-linux-amd64"},
+			{Name: "citop_0.2.0_checksums.txt", DownloadURL: ts.URL + "/citop_0.2.0_checksums.txt"},
+		},
+	}
+	asset, _ := r.asset("linux", "amd64")
+
+	// Return the path of a new executable, a function returning its content and a function
+	// removing it
+	setup := func(t *testing.T) (string, func() string, func()) {
+		dir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := dir + "/citop"
+		if err := ioutil.WriteFile(path, []byte("old"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		read := func() string {
+			bs, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(bs)
+		}
+		return path, read, func() { os.RemoveAll(dir) }
+	}
+
+	t.Run("matching checksum", func(t *testing.T) {
+		path, read, teardown := setup(t)
+		defer teardown()
+		if err := updateExecutable(context.Background(), ioutil.Discard, ts.Client(), r, asset, path); err != nil {
+			t.Fatal(err)
+		}
+		if s := read(); s != content {
+			t.Fatalf("expected %q but got %q", content, s)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		path, read, teardown := setup(t)
+		defer teardown()
+		tampered := asset
+		tampered.DownloadURL = ts.URL + "/tampered/citop-0.2.0-linux-amd64"
+		if err := updateExecutable(context.Background(), ioutil.Discard, ts.Client(), r, tampered, path); err == nil {
+			t.Fatal("expected an error but got nil")
+		}
+		if s := read(); s != "old" {
+			t.Fatalf("the executable must not be replaced, got %q", s)
+		}
+	})
+
+	t.Run("no checksums", func(t *testing.T) {
+		path, read, teardown := setup(t)
+		defer teardown()
+		withoutChecksums := r
+		withoutChecksums.Assets = r.Assets[:1]
+		if err := updateExecutable(context.Background(), ioutil.Discard, ts.Client(), withoutChecksums, asset, path); err == nil {
+			t.Fatal("expected an error but got nil")
+		}
+		if s := read(); s != "old" {
+			t.Fatalf("the executable must not be replaced, got %q", s)
+		}
+	})
+}
+
+func TestSelfUpdate(t *testing.T) {
+	t.Run("disabled by configuration", func(t *testing.T) {
+		err := selfUpdate(context.Background(), ioutil.Discard, http.DefaultClient, UpdateConfiguration{DisableSelfUpdate: true})
+		if err != ErrSelfUpdateDisabled {
+			t.Fatalf("expected %v but got %v", ErrSelfUpdateDisabled, err)
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
@@ -230,43 +231,95 @@ type Commit struct {
 	Branches []string
 	Tags     []string
 	Head     string
+	// Outcome of the verification of the signature of the commit: "verified", "unsigned", or
+	// "unverified" followed by the reason. Empty if unknown.
+	Signature string
+	// Files changed by the commit, nil if unknown
+	Files []ChangedFile
+	// Pull request containing the commit, nil if unknown or if there is none
+	PullRequest *PullRequest
 }
 
-func (c Commit) Strings() []text.StyledString {
-	var title text.StyledString
-	commit := text.NewStyledString(fmt.Sprintf("commit %s", c.Sha), text.GitSha)
-	if len(c.Branches) > 0 || len(c.Tags) > 0 {
-		refs := make([]text.StyledString, 0, len(c.Branches)+len(c.Tags))
-		for _, tag := range c.Tags {
-			refs = append(refs, text.NewStyledString(fmt.Sprintf("tag: %s", tag), text.GitTag))
-		}
-		for _, branch := range c.Branches {
-			if branch == c.Head {
-				var s text.StyledString
-				s.Append("HEAD -> ", text.GitHead)
-				s.Append(branch, text.GitBranch)
-				refs = append([]text.StyledString{s}, refs...)
-			} else {
-				refs = append(refs, text.NewStyledString(branch, text.GitBranch))
-			}
+// PullRequest is a pull request, or a merge request, containing a commit
+type PullRequest struct {
+	Number int
+	Title  string
+	// "open", "merged" or "closed"
+	State string
+	// Outcome of the reviews of the pull request: "approved", "changes requested" or
+	// "review required". Empty if unknown.
+	Review string
+	WebURL string
+}
+
+func (p PullRequest) String() string {
+	state := p.State
+	if p.Review != "" {
+		state += ", " + p.Review
+	}
+	return fmt.Sprintf("#%d %s (%s)", p.Number, p.Title, state)
+}
+
+// ChangedFile is a file added, modified, deleted or renamed by a commit
+type ChangedFile struct {
+	// "added", "modified", "deleted", "renamed" or "copied"
+	Status string
+	Path   string
+	// Path of the file before the commit for renamed and copied files, empty otherwise
+	PreviousPath string
+}
+
+func (f ChangedFile) String() string {
+	if f.PreviousPath != "" {
+		return fmt.Sprintf("%s -> %s", f.PreviousPath, f.Path)
+	}
+	return f.Path
+}
+
+// Decoration returns the list of references pointing to the commit in the format used by
+// 'git log --decorate' or an empty string if there are none
+func (c Commit) Decoration() text.StyledString {
+	if len(c.Branches) == 0 && len(c.Tags) == 0 {
+		return text.StyledString{}
+	}
+
+	refs := make([]text.StyledString, 0, len(c.Branches)+len(c.Tags))
+	for _, tag := range c.Tags {
+		refs = append(refs, text.NewStyledString(fmt.Sprintf("tag: %s", tag), text.GitTag))
+	}
+	for _, branch := range c.Branches {
+		if branch == c.Head {
+			var s text.StyledString
+			s.Append("HEAD -> ", text.GitHead)
+			s.Append(branch, text.GitBranch)
+			refs = append([]text.StyledString{s}, refs...)
+		} else {
+			refs = append(refs, text.NewStyledString(branch, text.GitBranch))
 		}
+	}
 
-		title = text.Join([]text.StyledString{
-			commit,
-			text.NewStyledString(" (", text.GitSha),
-			text.Join(refs, text.NewStyledString(", ", text.GitSha)),
-			text.NewStyledString(")", text.GitSha),
-		}, text.NewStyledString(""))
-	} else {
-		title = commit
+	return text.Join([]text.StyledString{
+		text.NewStyledString("(", text.GitSha),
+		text.Join(refs, text.NewStyledString(", ", text.GitSha)),
+		text.NewStyledString(")", text.GitSha),
+	}, text.NewStyledString(""))
+}
+
+func (c Commit) Strings() []text.StyledString {
+	title := text.NewStyledString(fmt.Sprintf("commit %s", c.Sha), text.GitSha)
+	if decoration := c.Decoration(); decoration.Length() > 0 {
+		title = text.Join([]text.StyledString{title, decoration}, text.NewStyledString(" ", text.GitSha))
 	}
 
 	texts := []text.StyledString{
 		title,
 		text.NewStyledString(fmt.Sprintf("Author: %s", c.Author)),
 		text.NewStyledString(fmt.Sprintf("Date: %s", c.Date.Truncate(time.Second).String())),
-		text.NewStyledString(""),
 	}
+	if c.PullRequest != nil {
+		texts = append(texts, text.NewStyledString(fmt.Sprintf("Pull request: %s", c.PullRequest.String())))
+	}
+	texts = append(texts, text.NewStyledString(""))
 	for _, line := range strings.Split(c.Message, "\n") {
 		texts = append(texts, text.NewStyledString("    "+line))
 		break
@@ -275,7 +328,103 @@ func (c Commit) Strings() []text.StyledString {
 	return texts
 }
 
-func GitOriginURL(path string, sha string) (string, Commit, error) {
+// GitDir returns the absolute path of the git directory of the repository containing 'path'
+func GitDir(path string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--absolute-git-dir")
+	cmd.Dir = path
+	bs, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bs)), nil
+}
+
+// GitCommitRange returns the SHA identifiers of the commits reachable from 'last' but not from
+// 'first', from the oldest to the newest, following only the first parent of merge commits
+func GitCommitRange(path string, first string, last string) ([]string, error) {
+	cmd := exec.Command("git", "rev-list", "--first-parent", "--reverse", first+".."+last)
+	cmd.Dir = path
+	bs, err := cmd.Output()
+	if err != nil {
+		if err, ok := err.(*exec.ExitError); ok && len(err.Stderr) > 0 {
+			return nil, fmt.Errorf("git rev-list: %s", strings.TrimSpace(string(err.Stderr)))
+		}
+		return nil, err
+	}
+	return strings.Fields(string(bs)), nil
+}
+
+// Outcome of the verification of signatures as reported by the placeholder %G? of git log
+var gitSignatureStates = map[string]string{
+	"G": "verified",
+	"U": "verified (unknown validity of the key)",
+	"B": "unverified: bad signature",
+	"X": "unverified: expired signature",
+	"Y": "unverified: expired key",
+	"R": "unverified: revoked key",
+	"E": "unverified: signature cannot be checked",
+	"N": "unsigned",
+}
+
+// Return the outcome of the verification of the signature of the commit 'sha' by the local git
+// binary, or an empty string if it cannot be determined
+func gitSignature(path string, sha string) string {
+	cmd := exec.Command("git", "log", "-1", "--format=%G?", sha)
+	cmd.Dir = path
+	bs, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return gitSignatureStates[strings.TrimSpace(string(bs))]
+}
+
+// Status of changed files as reported by git diff-tree --name-status
+var gitFileStates = map[byte]string{
+	'A': "added",
+	'M': "modified",
+	'T': "modified",
+	'D': "deleted",
+	'R': "renamed",
+	'C': "copied",
+}
+
+// Return the files changed by the commit 'sha' compared to its first parent according to the
+// local git binary, or nil if they cannot be listed
+func gitChangedFiles(path string, sha string) []ChangedFile {
+	cmd := exec.Command("git", "diff-tree", "--no-commit-id", "--root", "-r", "-M", "--name-status", sha)
+	cmd.Dir = path
+	bs, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	return parseNameStatus(string(bs))
+}
+
+// Parse the output of git diff-tree --name-status. Each line is made of a status letter,
+// possibly followed by a similarity score, and one path or two paths for renames and copies.
+func parseNameStatus(s string) []ChangedFile {
+	files := make([]ChangedFile, 0)
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+		status, exists := gitFileStates[fields[0][0]]
+		if !exists {
+			status = "modified"
+		}
+		file := ChangedFile{Status: status, Path: fields[len(fields)-1]}
+		if len(fields) > 2 {
+			file.PreviousPath = fields[1]
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// Open the local repository containing 'path' and return it along with the URL of its remote
+// 'origin'
+func gitOrigin(path string) (*git.Repository, string, error) {
 	// If a path does not refer to an existing file or directory, go-git will continue
 	// running and will walk its way up the directory structure looking for a .git repository.
 	// This is not ideal for us since running 'citop -r github.com/owner/remoterepo' from
@@ -288,21 +437,36 @@ func GitOriginURL(path string, sha string) (string, Commit, error) {
 		if os.IsNotExist(err) {
 			err = plumbing.ErrObjectNotFound
 		}
-		return "", Commit{}, err
+		return nil, "", err
 	}
 
 	r, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
 	if err != nil {
-		return "", Commit{}, err
+		return nil, "", err
 	}
 
 	remote, err := r.Remote("origin")
 	if err != nil {
-		return "", Commit{}, err
+		return nil, "", err
 	}
 
 	if len(remote.Config().URLs) == 0 {
-		return "", Commit{}, fmt.Errorf("GIT repository %q: remote 'origin' has no associated URL", path)
+		return nil, "", fmt.Errorf("GIT repository %q: remote 'origin' has no associated URL", path)
+	}
+
+	return r, remote.Config().URLs[0], nil
+}
+
+// GitRemoteURL returns the URL of the remote 'origin' of the local repository containing 'path'
+func GitRemoteURL(path string) (string, error) {
+	_, origin, err := gitOrigin(path)
+	return origin, err
+}
+
+func GitOriginURL(path string, sha string) (string, Commit, error) {
+	r, origin, err := gitOrigin(path)
+	if err != nil {
+		return "", Commit{}, err
 	}
 
 	head, err := r.Head()
@@ -339,13 +503,15 @@ func GitOriginURL(path string, sha string) (string, Commit, error) {
 	}
 
 	c := Commit{
-		Sha:      commit.Hash.String(),
-		Author:   commit.Author.String(),
-		Date:     commit.Author.When,
-		Message:  commit.Message,
-		Branches: nil,
-		Tags:     nil,
-		Head:     head.Name().Short(),
+		Sha:       commit.Hash.String(),
+		Author:    commit.Author.String(),
+		Date:      commit.Author.When,
+		Message:   commit.Message,
+		Branches:  nil,
+		Tags:      nil,
+		Head:      head.Name().Short(),
+		Signature: gitSignature(path, commit.Hash.String()),
+		Files:     gitChangedFiles(path, commit.Hash.String()),
 	}
 
 	refs, err := r.References()
@@ -375,7 +541,7 @@ func GitOriginURL(path string, sha string) (string, Commit, error) {
 		return "", Commit{}, err
 	}
 
-	return remote.Config().URLs[0], c, nil
+	return origin, c, nil
 }
 
 type NullDuration struct {
@@ -427,3 +593,37 @@ func XDGConfigLocations(filename string) []string {
 
 	return locations
 }
+
+// Return the directory where user-specific non-essential data should be written based on
+// https://specifications.freedesktop.org/basedir-spec/basedir-spec-latest.html
+func XDGCacheHome() string {
+	return getEnvWithDefault("XDG_CACHE_HOME", path.Join(os.Getenv("HOME"), ".cache"))
+}
+
+// Return the directory where user-specific state data that should persist between runs should be
+// written based on https://specifications.freedesktop.org/basedir-spec/basedir-spec-latest.html
+func XDGStateHome() string {
+	return getEnvWithDefault("XDG_STATE_HOME", path.Join(os.Getenv("HOME"), ".local", "state"))
+}
+
+// Write 'bs' to the file at 'p' by replacing it with a temporary file written in the same
+// directory. Missing directories are created. Only the owner of the file can read it.
+func WriteFileAtomically(p string, bs []byte) error {
+	if err := os.MkdirAll(path.Dir(p), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(path.Dir(p), "."+path.Base(p)+"-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(bs); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
@@ -159,3 +159,46 @@ func TestRepositorySlugFromURL(t *testing.T) {
 		})
 	}
 }
+
+func TestGitCommitRange(t *testing.T) {
+	shas, err := GitCommitRange(".", "HEAD~2", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shas) != 2 {
+		t.Fatalf("expected 2 commits but got %d", len(shas))
+	}
+	for _, sha := range shas {
+		if len(sha) != 40 {
+			t.Fatalf("expected SHA identifier but got %q", sha)
+		}
+	}
+
+	if _, err := GitCommitRange(".", "HEAD", "unknown-revision"); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+}
+
+func TestParseNameStatus(t *testing.T) {
+	output := "M\tcache/cache.go\nA\ttui/commit.go\nD\told.go\nR087\ttui/a.go\ttui/b.go\nT\tlink\n\n"
+	expected := []ChangedFile{
+		{Status: "modified", Path: "cache/cache.go"},
+		{Status: "added", Path: "tui/commit.go"},
+		{Status: "deleted", Path: "old.go"},
+		{Status: "renamed", Path: "tui/b.go", PreviousPath: "tui/a.go"},
+		{Status: "modified", Path: "link"},
+	}
+	files := parseNameStatus(output)
+	if len(files) != len(expected) {
+		t.Fatalf("expected %d files but got %d", len(expected), len(files))
+	}
+	for i := range expected {
+		if files[i] != expected[i] {
+			t.Fatalf("expected %+v but got %+v", expected[i], files[i])
+		}
+	}
+
+	if files := gitChangedFiles(".", "HEAD"); files == nil {
+		t.Fatal("expected the files changed by HEAD to be listed")
+	}
+}